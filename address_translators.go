@@ -1,6 +1,9 @@
 package gocql
 
-import "net"
+import (
+	"context"
+	"net"
+)
 
 // AddressTranslator provides a way to translate node addresses (and ports) that are
 // discovered or received as a node event. This can be useful in an ec2 environment,
@@ -12,6 +15,44 @@ type AddressTranslator interface {
 	Translate(addr net.IP, port int) (net.IP, int)
 }
 
+// HostAddressTranslator is an AddressTranslator which also receives a context
+// and the host being translated, as read from system.local or system.peers, to
+// translate addresses by data center, rack or host ID, or to look them up,
+// such as the node ports of a kubernetes service:
+//
+//	cluster.AddressTranslator = gocql.HostAddressTranslatorFunc(
+//		func(ctx context.Context, host *gocql.HostInfo, addr net.IP, port int) (net.IP, int) {
+//			if nodePort, ok := nodePorts[host.HostID()]; ok {
+//				return proxyIP, nodePort
+//			}
+//			return addr, port
+//		})
+//
+// The translated address and port are those the control and data connections
+// of the host dial, and which the host is known by when it is refreshed after
+// a topology or status event.
+type HostAddressTranslator interface {
+	AddressTranslator
+	// TranslateHost translates the address and port of the host, addr and port
+	// being its untranslated connect address and port. The context is
+	// canceled if the session is closed. If no translation is possible,
+	// TranslateHost will return the address and port provided to it.
+	TranslateHost(ctx context.Context, host *HostInfo, addr net.IP, port int) (net.IP, int)
+}
+
+// HostAddressTranslatorFunc is a HostAddressTranslator function. When called
+// as an AddressTranslator, it receives a host with only its connect address
+// and port.
+type HostAddressTranslatorFunc func(ctx context.Context, host *HostInfo, addr net.IP, port int) (net.IP, int)
+
+func (fn HostAddressTranslatorFunc) Translate(addr net.IP, port int) (net.IP, int) {
+	return fn(context.Background(), &HostInfo{connectAddress: addr, port: port}, addr, port)
+}
+
+func (fn HostAddressTranslatorFunc) TranslateHost(ctx context.Context, host *HostInfo, addr net.IP, port int) (net.IP, int) {
+	return fn(ctx, host, addr, port)
+}
+
 type AddressTranslatorFunc func(addr net.IP, port int) (net.IP, int)
 
 func (fn AddressTranslatorFunc) Translate(addr net.IP, port int) (net.IP, int) {
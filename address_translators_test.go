@@ -1,6 +1,7 @@
 package gocql
 
 import (
+	"context"
 	"net"
 	"testing"
 )
@@ -32,3 +33,22 @@ func TestIdentityAddressTranslator_HostProvided(t *testing.T) {
 	}
 	assertEqual(t, "translated port", 9042, port)
 }
+
+func TestHostAddressTranslatorFunc_Translate(t *testing.T) {
+	hostIP := net.ParseIP("10.1.2.3")
+	var tr AddressTranslator = HostAddressTranslatorFunc(func(ctx context.Context, host *HostInfo, addr net.IP, port int) (net.IP, int) {
+		if ctx == nil {
+			t.Error("expected a context")
+		}
+		if !host.ConnectAddress().Equal(addr) || host.Port() != port {
+			t.Errorf("expected host %v:%d got %v", addr, port, host)
+		}
+		return addr, port + 1
+	})
+
+	addr, port := tr.Translate(hostIP, 9042)
+	if !hostIP.Equal(addr) {
+		t.Errorf("expected translated addr to be (%+v) but was (%+v) instead", hostIP, addr)
+	}
+	assertEqual(t, "translated port", 9043, port)
+}
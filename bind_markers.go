@@ -0,0 +1,103 @@
+package gocql
+
+import (
+	"strings"
+)
+
+// BindMarker is a bind marker of a prepared statement.
+type BindMarker struct {
+	// Name is the name of a named marker, as in ":name", or the name of the
+	// column a positional marker is bound to.
+	Name string
+	// Named reports whether the marker is a named marker.
+	Named bool
+}
+
+// bindMarkers returns the markers of stmt, with the names of the columns of
+// args for positional markers. It returns nil if the markers found in stmt do
+// not match args, which can happen for statements the scanner does not
+// understand.
+func bindMarkers(stmt string, args []ColumnInfo) []BindMarker {
+	markers := scanBindMarkers(stmt)
+	if len(markers) != len(args) {
+		return nil
+	}
+	for i := range markers {
+		if !markers[i].Named {
+			markers[i].Name = args[i].Name
+		}
+	}
+	return markers
+}
+
+// scanBindMarkers returns the markers of stmt in order, skipping string
+// literals, quoted identifiers and comments. Positional markers have no name.
+func scanBindMarkers(stmt string) []BindMarker {
+	var markers []BindMarker
+	for i := 0; i < len(stmt); i++ {
+		switch c := stmt[i]; {
+		case c == '\'' || c == '"':
+			i = skipQuoted(stmt, i, c)
+		case c == '$' && strings.HasPrefix(stmt[i:], "$$"):
+			end := strings.Index(stmt[i+2:], "$$")
+			if end < 0 {
+				return markers
+			}
+			i += end + 3
+		case c == '-' && strings.HasPrefix(stmt[i:], "--"), c == '/' && strings.HasPrefix(stmt[i:], "//"):
+			end := strings.IndexByte(stmt[i:], '\n')
+			if end < 0 {
+				return markers
+			}
+			i += end
+		case c == '/' && strings.HasPrefix(stmt[i:], "/*"):
+			end := strings.Index(stmt[i+2:], "*/")
+			if end < 0 {
+				return markers
+			}
+			i += end + 3
+		case c == '?':
+			markers = append(markers, BindMarker{})
+		case c == ':' && i+1 < len(stmt):
+			if stmt[i+1] == '"' {
+				end := skipQuoted(stmt, i+1, '"')
+				name := strings.Replace(stmt[i+2:end], `""`, `"`, -1)
+				markers = append(markers, BindMarker{Name: name, Named: true})
+				i = end
+			} else if isIdentStart(stmt[i+1]) {
+				end := i + 2
+				for end < len(stmt) && isIdentChar(stmt[end]) {
+					end++
+				}
+				// unquoted identifiers are case insensitive
+				markers = append(markers, BindMarker{Name: strings.ToLower(stmt[i+1 : end]), Named: true})
+				i = end - 1
+			}
+		}
+	}
+	return markers
+}
+
+// skipQuoted returns the index of the quote closing the literal or
+// identifier opened at i, a doubled quote being an escaped quote.
+func skipQuoted(stmt string, i int, quote byte) int {
+	for i++; i < len(stmt); i++ {
+		if stmt[i] != quote {
+			continue
+		}
+		if i+1 < len(stmt) && stmt[i+1] == quote {
+			i++
+			continue
+		}
+		return i
+	}
+	return len(stmt)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
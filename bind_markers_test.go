@@ -0,0 +1,62 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScanBindMarkers(t *testing.T) {
+	tests := []struct {
+		stmt    string
+		markers []BindMarker
+	}{
+		{"SELECT * FROM t", nil},
+		{"SELECT * FROM t WHERE a = ? AND b = ?", []BindMarker{{}, {}}},
+		{"INSERT INTO t (a, b) VALUES (:a, :Bee)", []BindMarker{{Name: "a", Named: true}, {Name: "bee", Named: true}}},
+		{`UPDATE t SET v = :"Value" WHERE id = :id_1`, []BindMarker{{Name: "Value", Named: true}, {Name: "id_1", Named: true}}},
+		{"SELECT * FROM t WHERE a = 'what?' AND b = ?", []BindMarker{{}}},
+		{"SELECT * FROM t WHERE a = 'it''s :not' AND b = :b", []BindMarker{{Name: "b", Named: true}}},
+		{`SELECT "what?" FROM t WHERE a = ?`, []BindMarker{{}}},
+		{"UPDATE t SET m = {'k':1} WHERE id = ? -- or :id?\n", []BindMarker{{}}},
+		{"SELECT * FROM t /* ? */ WHERE a = ? // :b", []BindMarker{{}}},
+		{"SELECT * FROM t WHERE a = $$ :x ? $$ AND b = ?", []BindMarker{{}}},
+	}
+	for _, test := range tests {
+		if markers := scanBindMarkers(test.stmt); !reflect.DeepEqual(markers, test.markers) {
+			t.Errorf("%q: expected %+v got %+v", test.stmt, test.markers, markers)
+		}
+	}
+}
+
+func TestBindMarkers(t *testing.T) {
+	args := []ColumnInfo{{Name: "a"}, {Name: "b"}}
+
+	info := &QueryInfo{Args: args, Markers: bindMarkers("SELECT * FROM t WHERE a = ? AND b = ?", args)}
+	if info.Named() {
+		t.Error("expected positional markers")
+	}
+	if want := []BindMarker{{Name: "a"}, {Name: "b"}}; !reflect.DeepEqual(info.Markers, want) {
+		t.Errorf("expected %+v got %+v", want, info.Markers)
+	}
+	_, err := bindNamedValues(info, map[string]interface{}{"a": 1})
+	if err == nil || !strings.Contains(err.Error(), "positional") || !strings.Contains(err.Error(), `"b"`) {
+		t.Errorf("expected an error naming the positional marker of b got %v", err)
+	}
+
+	info = &QueryInfo{Args: args, Markers: bindMarkers("SELECT * FROM t WHERE a = :a AND b = :b", args)}
+	if !info.Named() {
+		t.Error("expected named markers")
+	}
+	_, err = bindNamedValues(info, map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), `["a" "b"]`) {
+		t.Errorf("expected an error naming all missing markers got %v", err)
+	}
+
+	if markers := bindMarkers("SELECT * FROM t WHERE a = ?", args); markers != nil {
+		t.Errorf("expected no markers when they do not match the arguments got %+v", markers)
+	}
+}
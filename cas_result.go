@@ -0,0 +1,146 @@
+package gocql
+
+import (
+	"context"
+	"fmt"
+)
+
+const casAppliedColumn = "[applied]"
+
+// CASResult is the result of a lightweight transaction: whether it was
+// applied and the current row of the table, which the server returns when
+// the transaction was not applied (and Scylla also when it was applied). The
+// columns of the row are those returned by the server, as described by
+// Columns, so they can be scanned by name unlike with ScanCAS.
+type CASResult struct {
+	applied  bool
+	previous LazyRow
+}
+
+// ExecCAS executes a lightweight transaction, an INSERT, UPDATE or DELETE
+// statement with an IF clause, and returns its result. If the outcome of the
+// transaction is unknown and its retry policy is an LWTRetryPolicy with
+// ReadBack set, the result is the one determined by ReadBack, without the
+// current row. If the server returns no row, as for a statement without an
+// IF clause, the error wraps ErrNotFound.
+func (q *Query) ExecCAS(ctx context.Context) (*CASResult, error) {
+	qry := q.WithContext(ctx)
+	qry.disableSkipMetadata = true
+	iter := qry.Iter()
+	if err := iter.checkErrAndNotFound(); err != nil {
+		iter.Close()
+		if p, ok := qry.rt.(*LWTRetryPolicy); ok && p.ReadBack != nil && casOutcomeUnknown(err) {
+			if applied, readErr := p.ReadBack(ctx, q); readErr == nil {
+				return &CASResult{applied: applied}, nil
+			}
+		}
+		return nil, err
+	}
+
+	return casResultFromIter(iter)
+}
+
+// casResultFromIter returns the result of the first row of iter and closes
+// it. It returns an error wrapping ErrNotFound if iter has no row.
+func casResultFromIter(iter *Iter) (*CASResult, error) {
+	var row LazyRow
+	if !iter.NextRow(&row) {
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("gocql: no result row for the lightweight transaction: %w", ErrNotFound)
+	}
+	res, err := newCASResult(&row)
+	if closeErr := iter.Close(); closeErr != nil {
+		return nil, closeErr
+	}
+	return res, err
+}
+
+// newCASResult copies the row, whose cells are only valid until the iterator
+// moves on.
+func newCASResult(row *LazyRow) (*CASResult, error) {
+	res := &CASResult{}
+	applied := -1
+	for i, col := range row.columns {
+		if col.Name == casAppliedColumn {
+			applied = i
+			break
+		}
+	}
+	if applied < 0 {
+		return nil, fmt.Errorf("gocql: no %s column in the result of the lightweight transaction", casAppliedColumn)
+	}
+	cell, err := row.cell(applied)
+	if err != nil {
+		return nil, err
+	}
+	if err := Unmarshal(row.columns[applied].TypeInfo, cell, &res.applied); err != nil {
+		return nil, fmt.Errorf("gocql: can not scan column %s: %v", casAppliedColumn, err)
+	}
+
+	n := len(row.columns) - 1
+	res.previous.columns = make([]ColumnInfo, 0, n)
+	res.previous.cells = make([][]byte, 0, n)
+	for i, col := range row.columns {
+		if i != applied {
+			cell, err := row.cell(i)
+			if err != nil {
+				return nil, err
+			}
+			res.previous.columns = append(res.previous.columns, col)
+			res.previous.cells = append(res.previous.cells, copyBytes(cell))
+		}
+	}
+	return res, nil
+}
+
+// Applied reports whether the transaction was applied.
+func (r *CASResult) Applied() bool {
+	return r.applied
+}
+
+// HasPrevious reports whether the server returned the current row.
+func (r *CASResult) HasPrevious() bool {
+	return len(r.previous.columns) > 0
+}
+
+// Columns returns the columns of the current row, without [applied].
+func (r *CASResult) Columns() []ColumnInfo {
+	return r.previous.columns
+}
+
+// Scan unmarshals the column of the current row with the given name into
+// dest.
+func (r *CASResult) Scan(name string, dest ...interface{}) error {
+	return r.previous.Scan(name, dest...)
+}
+
+// ScanStruct unmarshals the current row into the fields of the struct
+// pointed at by dest, as mapped by mapping. A nil mapping is lenient.
+func (r *CASResult) ScanStruct(dest interface{}, mapping *StructMapping) error {
+	return r.previous.ScanStruct(dest, mapping)
+}
+
+// Map returns the current row as a map, with the value types of MapScan.
+func (r *CASResult) Map() (map[string]interface{}, error) {
+	rowData, err := newRowData(r.previous.columns)
+	if err != nil {
+		return nil, err
+	}
+	values := rowData.Values
+	for i, col := range r.previous.columns {
+		n := 1
+		if tuple, ok := col.TypeInfo.(TupleTypeInfo); ok {
+			n = len(tuple.Elems)
+		}
+		if err := r.previous.ScanIndex(i, values[:n]...); err != nil {
+			return nil, err
+		}
+		values = values[n:]
+	}
+
+	m := make(map[string]interface{}, len(rowData.Columns))
+	rowData.rowMap(m)
+	return m, nil
+}
@@ -0,0 +1,178 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNewCASResult(t *testing.T) {
+	intType := NativeType{proto: protoVersion4, typ: TypeInt}
+	textType := NativeType{proto: protoVersion4, typ: TypeVarchar}
+	boolType := NativeType{proto: protoVersion4, typ: TypeBoolean}
+
+	marshal := func(info TypeInfo, v interface{}) []byte {
+		b, err := Marshal(info, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	row := &LazyRow{
+		columns: []ColumnInfo{
+			{Name: "[applied]", TypeInfo: boolType},
+			{Name: "id", TypeInfo: intType},
+			{Name: "name", TypeInfo: textType},
+		},
+		cells: [][]byte{
+			marshal(boolType, false),
+			marshal(intType, 42),
+			marshal(textType, "previous"),
+		},
+	}
+
+	res, err := newCASResult(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the result must not share the cells of the iterator
+	row.cells[2][0] = 'x'
+
+	if res.Applied() {
+		t.Error("expected the transaction not to be applied")
+	}
+	if !res.HasPrevious() {
+		t.Fatal("expected the previous row")
+	}
+	if n := len(res.Columns()); n != 2 {
+		t.Fatalf("expected 2 columns without [applied], got %d", n)
+	}
+
+	var name string
+	if err := res.Scan("name", &name); err != nil {
+		t.Fatal(err)
+	} else if name != "previous" {
+		t.Errorf("expected name %q, got %q", "previous", name)
+	}
+	if err := res.Scan("[applied]", new(bool)); err == nil {
+		t.Error("expected an error scanning [applied] as a previous column")
+	}
+
+	m, err := res.Map()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["id"] != 42 || m["name"] != "previous" || len(m) != 2 {
+		t.Errorf("unexpected map %v", m)
+	}
+
+	var dest struct {
+		ID   int
+		Name string
+	}
+	if err := res.ScanStruct(&dest, nil); err != nil {
+		t.Fatal(err)
+	}
+	if dest.ID != 42 || dest.Name != "previous" {
+		t.Errorf("unexpected struct %+v", dest)
+	}
+}
+
+func TestNewCASResultApplied(t *testing.T) {
+	boolType := NativeType{proto: protoVersion4, typ: TypeBoolean}
+	applied, _ := Marshal(boolType, true)
+
+	res, err := newCASResult(&LazyRow{
+		columns: []ColumnInfo{{Name: "[applied]", TypeInfo: boolType}},
+		cells:   [][]byte{applied},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Applied() {
+		t.Error("expected the transaction to be applied")
+	}
+	if res.HasPrevious() {
+		t.Error("expected no previous row")
+	}
+}
+
+func TestNewCASResultNotLWT(t *testing.T) {
+	_, err := newCASResult(&LazyRow{
+		columns: []ColumnInfo{{Name: "id", TypeInfo: NativeType{proto: protoVersion4, typ: TypeInt}}},
+		cells:   [][]byte{{0, 0, 0, 1}},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a result without [applied]")
+	}
+}
+
+func TestCASResultFromIterNoRow(t *testing.T) {
+	res, err := casResultFromIter(&Iter{})
+	if res != nil || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected an error wrapping ErrNotFound got %v, %v", res, err)
+	}
+
+	iterErr := errors.New("iter failed")
+	if _, err := casResultFromIter(&Iter{err: iterErr}); err != iterErr {
+		t.Fatalf("expected the error of the iterator got %v", err)
+	}
+}
+
+func TestExecCASReadBack(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if _, err := db.Query("writetimeout").RetryPolicy(&LWTRetryPolicy{NumRetries: 3}).ExecCAS(ctx); err == nil {
+		t.Fatal("expected the write timeout without ReadBack")
+	} else if wt, ok := err.(*RequestErrWriteTimeout); !ok || wt.WriteType != "CAS" {
+		t.Fatalf("expected a CAS write timeout, got %v", err)
+	}
+
+	var readBacks int
+	rt := &LWTRetryPolicy{
+		NumRetries: 3,
+		ReadBack: func(ctx context.Context, q *Query) (bool, error) {
+			readBacks++
+			if q.Statement() != "writetimeout" {
+				t.Errorf("expected the transaction to be read back, got %q", q.Statement())
+			}
+			return true, nil
+		},
+	}
+	qry := db.Query("writetimeout").RetryPolicy(rt)
+	res, err := qry.ExecCAS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Applied() || res.HasPrevious() {
+		t.Errorf("expected the transaction applied without the current row, got %+v", res)
+	}
+	if readBacks != 1 {
+		t.Errorf("expected 1 read back, got %d", readBacks)
+	}
+	if attempts := qry.Attempts(); attempts != 1 {
+		t.Errorf("expected the transaction not to be retried, got %d attempts", attempts)
+	}
+
+	// the error of the transaction is returned if the read back fails
+	rt.ReadBack = func(ctx context.Context, q *Query) (bool, error) {
+		return false, errors.New("read back failed")
+	}
+	if _, err := db.Query("writetimeout").RetryPolicy(rt).ExecCAS(ctx); err == nil {
+		t.Fatal("expected an error")
+	} else if _, ok := err.(*RequestErrWriteTimeout); !ok {
+		t.Fatalf("expected the write timeout, got %v", err)
+	}
+}
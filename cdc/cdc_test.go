@@ -0,0 +1,188 @@
+package cdc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+func TestNewChangeRow(t *testing.T) {
+	now := gocql.TimeUUID()
+	row := newChangeRow(map[string]interface{}{
+		colStreamID:                    []byte{1, 2},
+		colTime:                        now,
+		colBatchSeqNo:                  3,
+		colOperation:                   int8(Update),
+		colTTL:                         int64(60),
+		colEndOfBatch:                  true,
+		"pk":                           1,
+		"v":                            "value",
+		"cdc$deleted_v":                false,
+		"cdc$deleted_s":                true,
+		"cdc$deleted_elements_s":       []string{"a"},
+		"cdc$deleted_elements_missing": nil,
+	})
+
+	if row.StreamID.String() != "0102" || row.Time != now || row.BatchSeqNo != 3 ||
+		row.Operation != Update || row.TTL != 60 || !row.EndOfBatch {
+		t.Fatalf("unexpected cdc columns %v", row)
+	}
+	if len(row.Columns()) != 2 {
+		t.Fatalf("expected 2 base columns got %v", row.Columns())
+	}
+	if v, ok := row.Value("v"); !ok || v != "value" {
+		t.Fatalf("unexpected value %v", v)
+	}
+	if row.IsDeleted("v") || !row.IsDeleted("s") {
+		t.Fatal("unexpected deleted columns")
+	}
+	if elems, ok := row.DeletedElements("s").([]string); !ok || len(elems) != 1 || elems[0] != "a" {
+		t.Fatalf("unexpected deleted elements %v", row.DeletedElements("s"))
+	}
+}
+
+func TestChangeAdd(t *testing.T) {
+	var change Change
+	for _, op := range []OperationType{PreImage, RowDelete, Insert, PostImage} {
+		change.add(&ChangeRow{Operation: op})
+	}
+	if len(change.PreImage) != 1 || len(change.Delta) != 2 || len(change.PostImage) != 1 {
+		t.Fatalf("unexpected change %+v", change)
+	}
+	if s := OperationType(42).String(); s != "UNKNOWN_OPERATION_42" {
+		t.Fatalf("unexpected operation %q", s)
+	}
+}
+
+func TestCompareTimeUUID(t *testing.T) {
+	now := time.Now()
+	uuid := gocql.UUIDFromTime(now)
+
+	tests := []struct {
+		a, b     gocql.UUID
+		expected int
+	}{
+		{uuid, uuid, 0},
+		{gocql.MinTimeUUID(now), uuid, -1},
+		{gocql.MaxTimeUUID(now), uuid, 1},
+		{gocql.MaxTimeUUID(now.Add(-time.Millisecond)), gocql.MinTimeUUID(now), -1},
+		{gocql.MinTimeUUID(now.Add(time.Millisecond)), gocql.MaxTimeUUID(now), 1},
+	}
+	for i, test := range tests {
+		if c := compareTimeUUID(test.a, test.b); c != test.expected {
+			t.Errorf("%d: expected %d got %d", i, test.expected, c)
+		}
+	}
+}
+
+func TestAssignStreams(t *testing.T) {
+	var streams []StreamID
+	for i := 0; i < 1000; i++ {
+		streams = append(streams, StreamID{byte(i >> 8), byte(i)})
+	}
+
+	const instances = 3
+	assigned := make(map[string]int)
+	for id := 0; id < instances; id++ {
+		mine := assignStreams(streams, id, instances)
+		if len(mine) == 0 {
+			t.Fatalf("no streams assigned to instance %d", id)
+		}
+		for _, stream := range mine {
+			assigned[string(stream)]++
+		}
+	}
+	if len(assigned) != len(streams) {
+		t.Fatalf("expected all %d streams to be assigned got %d", len(streams), len(assigned))
+	}
+	for stream, n := range assigned {
+		if n != 1 {
+			t.Fatalf("stream %x assigned to %d instances", stream, n)
+		}
+	}
+
+	if len(assignStreams(streams, 0, 1)) != len(streams) {
+		t.Fatal("expected a single instance to read all streams")
+	}
+}
+
+func TestNextGeneration(t *testing.T) {
+	base := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	starts := []time.Time{base, base.Add(time.Hour), base.Add(2 * time.Hour)}
+
+	tests := []struct {
+		after      time.Time
+		start, end time.Time
+	}{
+		{time.Time{}, starts[0], starts[1]},
+		{base.Add(30 * time.Minute), starts[0], starts[1]},
+		{starts[1], starts[1], starts[2]},
+		{base.Add(3 * time.Hour), starts[2], time.Time{}},
+	}
+	for _, test := range tests {
+		gen, ok := nextGeneration(starts, test.after)
+		if !ok || !gen.Start.Equal(test.start) || !gen.End.Equal(test.end) {
+			t.Errorf("after %v: expected [%v, %v) got [%v, %v)", test.after, test.start, test.end, gen.Start, gen.End)
+		}
+	}
+
+	if _, ok := nextGeneration(nil, base); ok {
+		t.Fatal("expected no generation")
+	}
+}
+
+func TestMemoryProgressStore(t *testing.T) {
+	ctx := context.Background()
+	gen := time.Now()
+	store := &MemoryProgressStore{}
+
+	if _, ok, err := store.GetProgress(ctx, gen, "ks.tbl", StreamID{1}); ok || err != nil {
+		t.Fatalf("expected no progress got %v, %v", ok, err)
+	}
+
+	last := gocql.TimeUUID()
+	if err := store.SaveProgress(ctx, gen, "ks.tbl", StreamID{1}, last); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := store.GetProgress(ctx, gen.In(time.FixedZone("test", 3600)), "ks.tbl", StreamID{1}); !ok || err != nil || got != last {
+		t.Fatalf("expected progress %v got %v, %v, %v", last, got, ok, err)
+	}
+	if _, ok, _ := store.GetProgress(ctx, gen, "ks.other", StreamID{1}); ok {
+		t.Fatal("expected no progress for another table")
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	consumer := ChangeConsumerFunc(func(ctx context.Context, change Change) error { return nil })
+
+	r, err := NewReader(&ReaderConfig{
+		Session:    &gocql.Session{},
+		TableNames: []string{"ks.tbl"},
+		Consumer:   consumer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(r.tables[0].query, `SELECT * FROM "ks"."tbl_scylla_cdc_log" WHERE "cdc$stream_id" IN ?`) {
+		t.Fatalf("unexpected query %q", r.tables[0].query)
+	}
+	if r.cfg.Consistency != gocql.Quorum || r.cfg.InstanceCount != 1 || r.cfg.Progress == nil {
+		t.Fatalf("unexpected defaults %+v", r.cfg)
+	}
+
+	invalid := []*ReaderConfig{
+		{TableNames: []string{"ks.tbl"}, Consumer: consumer},
+		{Session: &gocql.Session{}, TableNames: []string{"ks.tbl"}},
+		{Session: &gocql.Session{}, Consumer: consumer},
+		{Session: &gocql.Session{}, TableNames: []string{"tbl"}, Consumer: consumer},
+		{Session: &gocql.Session{}, TableNames: []string{"ks.tbl"}, Consumer: consumer, InstanceID: 2, InstanceCount: 2},
+	}
+	for i, cfg := range invalid {
+		if _, err := NewReader(cfg); err == nil {
+			t.Errorf("%d: expected configuration to be invalid", i)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Package cdc reads the Change Data Capture log of Scylla tables.
+//
+// Scylla writes the changes of a table with CDC enabled to a log table,
+// partitioned by streams. The set of streams changes with the topology of the
+// cluster, each set is a generation which is active from its start time until
+// the start of the next generation. A Reader discovers the generations, reads
+// the changes of the streams assigned to it in order, delivers them to a
+// ChangeConsumer and records its progress in a ProgressStore, so that it can
+// resume after a restart.
+//
+// Example:
+//
+//	reader, err := cdc.NewReader(&cdc.ReaderConfig{
+//		Session:    session,
+//		TableNames: []string{"ks.tbl"},
+//		Consumer: cdc.ChangeConsumerFunc(func(ctx context.Context, change cdc.Change) error {
+//			for _, row := range change.Delta {
+//				log.Println(row.Operation, row.Columns())
+//			}
+//			return nil
+//		}),
+//	})
+//	if err != nil {
+//		// handle error
+//	}
+//	err = reader.Run(ctx)
+package cdc
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+// StreamID is the partition key of a CDC log table.
+type StreamID []byte
+
+func (s StreamID) String() string {
+	return hex.EncodeToString(s)
+}
+
+// OperationType is the type of a row of a CDC log table.
+type OperationType int8
+
+const (
+	PreImage                  OperationType = 0
+	Update                    OperationType = 1
+	Insert                    OperationType = 2
+	RowDelete                 OperationType = 3
+	PartitionDelete           OperationType = 4
+	RangeDeleteStartInclusive OperationType = 5
+	RangeDeleteStartExclusive OperationType = 6
+	RangeDeleteEndInclusive   OperationType = 7
+	RangeDeleteEndExclusive   OperationType = 8
+	PostImage                 OperationType = 9
+)
+
+func (o OperationType) String() string {
+	switch o {
+	case PreImage:
+		return "PRE_IMAGE"
+	case Update:
+		return "UPDATE"
+	case Insert:
+		return "INSERT"
+	case RowDelete:
+		return "ROW_DELETE"
+	case PartitionDelete:
+		return "PARTITION_DELETE"
+	case RangeDeleteStartInclusive:
+		return "RANGE_DELETE_START_INCLUSIVE"
+	case RangeDeleteStartExclusive:
+		return "RANGE_DELETE_START_EXCLUSIVE"
+	case RangeDeleteEndInclusive:
+		return "RANGE_DELETE_END_INCLUSIVE"
+	case RangeDeleteEndExclusive:
+		return "RANGE_DELETE_END_EXCLUSIVE"
+	case PostImage:
+		return "POST_IMAGE"
+	default:
+		return fmt.Sprintf("UNKNOWN_OPERATION_%d", int8(o))
+	}
+}
+
+// Columns of the CDC log tables which are not copied from the base table.
+const (
+	colStreamID        = "cdc$stream_id"
+	colTime            = "cdc$time"
+	colBatchSeqNo      = "cdc$batch_seq_no"
+	colOperation       = "cdc$operation"
+	colTTL             = "cdc$ttl"
+	colEndOfBatch      = "cdc$end_of_batch"
+	colDeletedPrefix   = "cdc$deleted_"
+	colDeletedElemsPfx = "cdc$deleted_elements_"
+)
+
+// ChangeRow is a row of a CDC log table.
+type ChangeRow struct {
+	StreamID   StreamID
+	Time       gocql.UUID
+	BatchSeqNo int
+	Operation  OperationType
+	// TTL is the TTL in seconds of the written data, or 0 if none was set.
+	TTL        int64
+	EndOfBatch bool
+
+	columns         map[string]interface{}
+	deleted         map[string]bool
+	deletedElements map[string]interface{}
+}
+
+// Columns returns the values of the base table columns of the row.
+func (r *ChangeRow) Columns() map[string]interface{} {
+	return r.columns
+}
+
+// Value returns the value of the base table column, the value is nil if the
+// column was not changed by the operation.
+func (r *ChangeRow) Value(column string) (interface{}, bool) {
+	v, ok := r.columns[column]
+	return v, ok
+}
+
+// IsDeleted reports whether the operation deleted the value of the column.
+func (r *ChangeRow) IsDeleted(column string) bool {
+	return r.deleted[column]
+}
+
+// DeletedElements returns the elements removed from the collection column,
+// which are keys for maps and UDT field indexes for UDTs.
+func (r *ChangeRow) DeletedElements(column string) interface{} {
+	return r.deletedElements[column]
+}
+
+func (r *ChangeRow) String() string {
+	return fmt.Sprintf("[change stream=%s time=%s seq=%d op=%s columns=%v]",
+		r.StreamID, r.Time, r.BatchSeqNo, r.Operation, r.columns)
+}
+
+// newChangeRow converts a row scanned by Iter.MapScan to a ChangeRow.
+func newChangeRow(m map[string]interface{}) *ChangeRow {
+	r := &ChangeRow{
+		columns:         make(map[string]interface{}),
+		deleted:         make(map[string]bool),
+		deletedElements: make(map[string]interface{}),
+	}
+	for name, v := range m {
+		switch name {
+		case colStreamID:
+			r.StreamID, _ = v.([]byte)
+		case colTime:
+			r.Time, _ = v.(gocql.UUID)
+		case colBatchSeqNo:
+			r.BatchSeqNo, _ = v.(int)
+		case colOperation:
+			op, _ := v.(int8)
+			r.Operation = OperationType(op)
+		case colTTL:
+			r.TTL, _ = v.(int64)
+		case colEndOfBatch:
+			r.EndOfBatch, _ = v.(bool)
+		default:
+			switch {
+			case strings.HasPrefix(name, colDeletedElemsPfx):
+				r.deletedElements[strings.TrimPrefix(name, colDeletedElemsPfx)] = v
+			case strings.HasPrefix(name, colDeletedPrefix):
+				deleted, _ := v.(bool)
+				r.deleted[strings.TrimPrefix(name, colDeletedPrefix)] = deleted
+			default:
+				r.columns[name] = v
+			}
+		}
+	}
+	return r
+}
+
+// Change are the rows of the CDC log written by a single statement to a
+// partition of the base table, they share the stream and time of the change.
+type Change struct {
+	// Table is the fully qualified name of the base table.
+	Table    string
+	StreamID StreamID
+	Time     gocql.UUID
+
+	// PreImage are the rows before the change, if enabled for the table.
+	PreImage []*ChangeRow
+	// Delta are the rows describing the change.
+	Delta []*ChangeRow
+	// PostImage are the rows after the change, if enabled for the table.
+	PostImage []*ChangeRow
+}
+
+func (c *Change) add(row *ChangeRow) {
+	switch row.Operation {
+	case PreImage:
+		c.PreImage = append(c.PreImage, row)
+	case PostImage:
+		c.PostImage = append(c.PostImage, row)
+	default:
+		c.Delta = append(c.Delta, row)
+	}
+}
+
+// ChangeConsumer processes the changes read from the CDC log. The changes of
+// a stream are delivered in order, one at a time, while changes of different
+// streams can be delivered concurrently.
+type ChangeConsumer interface {
+	// Consume processes the change, the reader stops if an error is returned
+	// and the change is delivered again when the reader is restarted.
+	Consume(ctx context.Context, change Change) error
+}
+
+// ChangeConsumerFunc is a ChangeConsumer implemented by a function.
+type ChangeConsumerFunc func(ctx context.Context, change Change) error
+
+func (f ChangeConsumerFunc) Consume(ctx context.Context, change Change) error {
+	return f(ctx, change)
+}
+
+// compareTimeUUID compares timeuuids in the order used by Scylla, by their
+// timestamp and then by the signed bytes of their clock sequence and node.
+func compareTimeUUID(a, b gocql.UUID) int {
+	if ta, tb := a.Timestamp(), b.Timestamp(); ta != tb {
+		if ta < tb {
+			return -1
+		}
+		return 1
+	}
+	for i := 8; i < 16; i++ {
+		if x, y := int8(a[i]), int8(b[i]); x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
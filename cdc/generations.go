@@ -0,0 +1,91 @@
+package cdc
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Generation is a set of streams of the CDC log, which is used for the changes
+// written from its start until the start of the next generation.
+type Generation struct {
+	Start time.Time
+	// End is the start of the next generation, or the zero time if the
+	// generation is the current one.
+	End     time.Time
+	Streams []StreamID
+}
+
+// closed reports whether a newer generation replaced the generation.
+func (g *Generation) closed() bool {
+	return !g.End.IsZero()
+}
+
+const (
+	generationTimestampsQuery = `SELECT time FROM system_distributed.cdc_generation_timestamps WHERE key = 'timestamps'`
+	generationStreamsQuery    = `SELECT streams FROM system_distributed.cdc_streams_descriptions_v2 WHERE time = ?`
+)
+
+// fetchGenerationStarts returns the start times of the generations in ascending order.
+func fetchGenerationStarts(ctx context.Context, session *gocql.Session, cons gocql.Consistency) ([]time.Time, error) {
+	iter := session.Query(generationTimestampsQuery).WithContext(ctx).Consistency(cons).Iter()
+
+	var (
+		starts []time.Time
+		start  time.Time
+	)
+	for iter.Scan(&start) {
+		starts = append(starts, start)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i].Before(starts[j])
+	})
+	return starts, nil
+}
+
+// fetchGenerationStreams returns the streams of the generation starting at start.
+func fetchGenerationStreams(ctx context.Context, session *gocql.Session, cons gocql.Consistency, start time.Time) ([]StreamID, error) {
+	iter := session.Query(generationStreamsQuery, start).WithContext(ctx).Consistency(cons).Iter()
+
+	var (
+		streams []StreamID
+		vnode   [][]byte
+	)
+	for iter.Scan(&vnode) {
+		for _, stream := range vnode {
+			streams = append(streams, StreamID(stream))
+		}
+		vnode = nil
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
+
+// nextGeneration returns the first generation which is still in use after the
+// given time, which is the generation containing the time or the first one
+// starting after it. ok is false if there are no generations.
+func nextGeneration(starts []time.Time, after time.Time) (gen Generation, ok bool) {
+	if len(starts) == 0 {
+		return Generation{}, false
+	}
+
+	i := sort.Search(len(starts), func(i int) bool {
+		return starts[i].After(after)
+	})
+	if i > 0 {
+		i--
+	}
+	gen.Start = starts[i]
+	if i+1 < len(starts) {
+		gen.End = starts[i+1]
+	}
+	return gen, true
+}
@@ -0,0 +1,98 @@
+package cdc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// ProgressStore records the position of a Reader in each stream, so that
+// reading can be resumed after a restart.
+type ProgressStore interface {
+	// GetProgress returns the time of the last change of the stream of the
+	// table which was consumed in the generation, ok is false if none was.
+	GetProgress(ctx context.Context, generation time.Time, table string, stream StreamID) (last gocql.UUID, ok bool, err error)
+	// SaveProgress records that all changes of the stream of the table up to
+	// and including last were consumed in the generation.
+	SaveProgress(ctx context.Context, generation time.Time, table string, stream StreamID, last gocql.UUID) error
+}
+
+type progressKey struct {
+	generation time.Time
+	table      string
+	stream     string
+}
+
+// MemoryProgressStore is a ProgressStore which keeps the progress in memory,
+// a reader using it starts from the oldest generation when it is restarted.
+type MemoryProgressStore struct {
+	mu       sync.Mutex
+	progress map[progressKey]gocql.UUID
+}
+
+func (s *MemoryProgressStore) GetProgress(ctx context.Context, generation time.Time, table string, stream StreamID) (gocql.UUID, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.progress[progressKey{generation.UTC(), table, string(stream)}]
+	return last, ok, nil
+}
+
+func (s *MemoryProgressStore) SaveProgress(ctx context.Context, generation time.Time, table string, stream StreamID, last gocql.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.progress == nil {
+		s.progress = make(map[progressKey]gocql.UUID)
+	}
+	s.progress[progressKey{generation.UTC(), table, string(stream)}] = last
+	return nil
+}
+
+// TableProgressStore is a ProgressStore which keeps the progress in a table.
+type TableProgressStore struct {
+	session *gocql.Session
+	table   string
+	ttl     time.Duration
+}
+
+// NewTableProgressStore returns a ProgressStore which keeps the progress in
+// the table of the keyspace, creating the table if it does not exist. The
+// progress of a generation is removed after ttl, if it is not zero.
+func NewTableProgressStore(ctx context.Context, session *gocql.Session, keyspace, table string, ttl time.Duration) (*TableProgressStore, error) {
+	s := &TableProgressStore{
+		session: session,
+		table:   fmt.Sprintf("%q.%q", keyspace, table),
+		ttl:     ttl,
+	}
+
+	stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		table_name text,
+		generation timestamp,
+		stream_id blob,
+		last_time timeuuid,
+		PRIMARY KEY ((table_name, generation), stream_id))`, s.table)
+	if err := session.Query(stmt).WithContext(ctx).Exec(); err != nil {
+		return nil, fmt.Errorf("cdc: unable to create progress table %s: %v", s.table, err)
+	}
+	return s, nil
+}
+
+func (s *TableProgressStore) GetProgress(ctx context.Context, generation time.Time, table string, stream StreamID) (gocql.UUID, bool, error) {
+	stmt := fmt.Sprintf(`SELECT last_time FROM %s WHERE table_name = ? AND generation = ? AND stream_id = ?`, s.table)
+
+	var last gocql.UUID
+	err := s.session.Query(stmt, table, generation, []byte(stream)).WithContext(ctx).Scan(&last)
+	if err == gocql.ErrNotFound {
+		return gocql.UUID{}, false, nil
+	} else if err != nil {
+		return gocql.UUID{}, false, err
+	}
+	return last, true, nil
+}
+
+func (s *TableProgressStore) SaveProgress(ctx context.Context, generation time.Time, table string, stream StreamID, last gocql.UUID) error {
+	stmt := fmt.Sprintf(`INSERT INTO %s (table_name, generation, stream_id, last_time) VALUES (?, ?, ?, ?) USING TTL ?`, s.table)
+	return s.session.Query(stmt, table, generation, []byte(stream), last, int(s.ttl/time.Second)).WithContext(ctx).Exec()
+}
@@ -0,0 +1,410 @@
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// ReaderConfig is the configuration of a Reader.
+type ReaderConfig struct {
+	// Session is used to read the generations and the CDC log tables.
+	Session *gocql.Session
+	// TableNames are the fully qualified names (keyspace.table) of the tables
+	// whose changes are read.
+	TableNames []string
+	// Consumer processes the changes.
+	Consumer ChangeConsumer
+	// Progress records the position of the reader in the streams.
+	// Default: a MemoryProgressStore
+	Progress ProgressStore
+
+	// InstanceCount is the number of readers sharing the streams, and
+	// InstanceID the index in [0, InstanceCount) of this reader. Each reader
+	// reads the streams whose hash modulo InstanceCount is its InstanceID.
+	// Default: 1 instance
+	InstanceID    int
+	InstanceCount int
+
+	// StartTime skips the changes written before it.
+	// Default: all changes are read, starting from the oldest generation
+	StartTime time.Time
+
+	// Consistency of the queries of the reader.
+	// Default: Quorum
+	Consistency gocql.Consistency
+	// PollInterval is the time to wait for new changes once the reader caught up.
+	// Default: 1 second
+	PollInterval time.Duration
+	// ConfidenceWindow is the age of the most recent changes which are read,
+	// changes written by clients with skewed clocks or delayed by replication
+	// within the window are not missed.
+	// Default: 30 seconds
+	ConfidenceWindow time.Duration
+	// QueryWindow is the maximum time range of the changes read by a query.
+	// Default: 30 seconds
+	QueryWindow time.Duration
+	// StreamsPerQuery is the maximum number of streams read by a query.
+	// Default: 64
+	StreamsPerQuery int
+}
+
+// Reader reads the changes of tables from their CDC log.
+type Reader struct {
+	cfg    ReaderConfig
+	tables []logTable
+}
+
+type logTable struct {
+	name string
+	// query selects the changes of streams in a time range.
+	query string
+}
+
+// NewReader returns a Reader of the configured tables.
+func NewReader(cfg *ReaderConfig) (*Reader, error) {
+	if cfg.Session == nil {
+		return nil, errors.New("cdc: no session")
+	} else if cfg.Consumer == nil {
+		return nil, errors.New("cdc: no consumer")
+	} else if len(cfg.TableNames) == 0 {
+		return nil, errors.New("cdc: no tables")
+	}
+
+	r := &Reader{cfg: *cfg}
+	if r.cfg.Progress == nil {
+		r.cfg.Progress = &MemoryProgressStore{}
+	}
+	if r.cfg.InstanceCount <= 0 {
+		r.cfg.InstanceCount = 1
+	}
+	if r.cfg.InstanceID < 0 || r.cfg.InstanceID >= r.cfg.InstanceCount {
+		return nil, fmt.Errorf("cdc: instance id %d out of range for %d instances", r.cfg.InstanceID, r.cfg.InstanceCount)
+	}
+	if r.cfg.Consistency == gocql.Any {
+		r.cfg.Consistency = gocql.Quorum
+	}
+	if r.cfg.PollInterval <= 0 {
+		r.cfg.PollInterval = time.Second
+	}
+	if r.cfg.ConfidenceWindow <= 0 {
+		r.cfg.ConfidenceWindow = 30 * time.Second
+	}
+	if r.cfg.QueryWindow <= 0 {
+		r.cfg.QueryWindow = 30 * time.Second
+	}
+	if r.cfg.StreamsPerQuery <= 0 {
+		r.cfg.StreamsPerQuery = 64
+	}
+
+	for _, name := range cfg.TableNames {
+		parts := strings.SplitN(name, ".", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("cdc: table name %q is not fully qualified", name)
+		}
+		r.tables = append(r.tables, logTable{
+			name: name,
+			query: fmt.Sprintf(`SELECT * FROM %q.%q WHERE "%s" IN ? AND "%s" > ? AND "%s" < ?`,
+				parts[0], parts[1]+"_scylla_cdc_log", colStreamID, colTime, colTime),
+		})
+	}
+	return r, nil
+}
+
+// consumerError is an error of the consumer or the progress store, which
+// stops the reader, as opposed to query errors which are retried.
+type consumerError struct {
+	err error
+}
+
+func (e consumerError) Error() string {
+	return e.err.Error()
+}
+
+func (e consumerError) Unwrap() error {
+	return e.err
+}
+
+// Run reads the changes until the context is done or an error is returned by
+// the consumer or the progress store. Errors of the queries reading the CDC
+// log are logged and the queries are retried after the poll interval.
+func (r *Reader) Run(ctx context.Context) error {
+	after := r.cfg.StartTime
+	for {
+		gen, err := r.generation(ctx, after)
+		if err != nil {
+			return err
+		}
+		if err := r.readGeneration(ctx, gen); err != nil {
+			if ce, ok := err.(consumerError); ok {
+				return ce.err
+			}
+			return err
+		}
+		after = gen.End
+	}
+}
+
+func (r *Reader) sleep(ctx context.Context) error {
+	t := time.NewTimer(r.cfg.PollInterval)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// generation waits for the first generation in use after the time and
+// fetches its streams which are assigned to the reader.
+func (r *Reader) generation(ctx context.Context, after time.Time) (*Generation, error) {
+	for {
+		starts, err := fetchGenerationStarts(ctx, r.cfg.Session, r.cfg.Consistency)
+		if err == nil {
+			if gen, ok := nextGeneration(starts, after); ok {
+				var streams []StreamID
+				streams, err = fetchGenerationStreams(ctx, r.cfg.Session, r.cfg.Consistency, gen.Start)
+				if err == nil {
+					gen.Streams = r.assignedStreams(streams)
+					return &gen, nil
+				}
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			gocql.Logger.Printf("cdc: unable to fetch generations: %v\n", err)
+		}
+		if err := r.sleep(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// refreshEnd sets the end of the generation once a newer one was created.
+func (r *Reader) refreshEnd(ctx context.Context, gen *Generation) {
+	starts, err := fetchGenerationStarts(ctx, r.cfg.Session, r.cfg.Consistency)
+	if err != nil {
+		if ctx.Err() == nil {
+			gocql.Logger.Printf("cdc: unable to fetch generations: %v\n", err)
+		}
+		return
+	}
+	if next, ok := nextGeneration(starts, gen.Start); ok && next.Start.Equal(gen.Start) {
+		gen.End = next.End
+	}
+}
+
+// assignedStreams returns the streams read by this instance.
+func (r *Reader) assignedStreams(streams []StreamID) []StreamID {
+	return assignStreams(streams, r.cfg.InstanceID, r.cfg.InstanceCount)
+}
+
+func assignStreams(streams []StreamID, id, count int) []StreamID {
+	if count <= 1 {
+		return streams
+	}
+	var assigned []StreamID
+	for _, stream := range streams {
+		h := fnv.New32a()
+		h.Write(stream)
+		if int(h.Sum32()%uint32(count)) == id {
+			assigned = append(assigned, stream)
+		}
+	}
+	return assigned
+}
+
+// streamProgress is the position of the reader in a stream of a table.
+type streamProgress struct {
+	stream StreamID
+	last   gocql.UUID
+	dirty  bool
+}
+
+func (r *Reader) loadProgress(ctx context.Context, gen *Generation, table string) ([]*streamProgress, error) {
+	start := gen.Start
+	if r.cfg.StartTime.After(start) {
+		start = r.cfg.StartTime
+	}
+
+	progress := make([]*streamProgress, 0, len(gen.Streams))
+	for _, stream := range gen.Streams {
+		last, ok, err := r.cfg.Progress.GetProgress(ctx, gen.Start, table, stream)
+		if err != nil {
+			return nil, consumerError{fmt.Errorf("cdc: unable to load progress of stream %s: %v", stream, err)}
+		}
+		if !ok {
+			last = gocql.MinTimeUUID(start)
+		}
+		progress = append(progress, &streamProgress{stream: stream, last: last})
+	}
+	return progress, nil
+}
+
+func (r *Reader) saveProgress(ctx context.Context, gen *Generation, table string, progress []*streamProgress) error {
+	for _, p := range progress {
+		if !p.dirty {
+			continue
+		}
+		if err := r.cfg.Progress.SaveProgress(ctx, gen.Start, table, p.stream, p.last); err != nil {
+			return consumerError{fmt.Errorf("cdc: unable to save progress of stream %s: %v", p.stream, err)}
+		}
+		p.dirty = false
+	}
+	return nil
+}
+
+// readGeneration reads the changes of the generation until all of them were
+// consumed, which happens only once a newer generation replaced it.
+func (r *Reader) readGeneration(ctx context.Context, gen *Generation) error {
+	progress := make([][]*streamProgress, len(r.tables))
+	for i, table := range r.tables {
+		p, err := r.loadProgress(ctx, gen, table.name)
+		if err != nil {
+			return err
+		}
+		progress[i] = p
+	}
+
+	for {
+		if !gen.closed() {
+			r.refreshEnd(ctx, gen)
+		}
+
+		limit := time.Now().Add(-r.cfg.ConfidenceWindow)
+		if gen.closed() && gen.End.Before(limit) {
+			limit = gen.End
+		}
+
+		caughtUp, finished := true, gen.closed()
+		for i, table := range r.tables {
+			for start := 0; start < len(progress[i]); start += r.cfg.StreamsPerQuery {
+				end := start + r.cfg.StreamsPerQuery
+				if end > len(progress[i]) {
+					end = len(progress[i])
+				}
+				chunk := progress[i][start:end]
+
+				upper, err := r.readWindow(ctx, table, chunk, limit)
+				if err != nil {
+					if _, ok := err.(consumerError); ok || ctx.Err() != nil {
+						// keep the progress of the changes which were consumed
+						r.saveProgress(context.Background(), gen, table.name, progress[i])
+						return err
+					}
+					gocql.Logger.Printf("cdc: unable to read changes of %s: %v\n", table.name, err)
+					caughtUp, finished = true, false
+					break
+				}
+				if upper.Before(limit) {
+					caughtUp = false
+				}
+				if upper.Before(gen.End) {
+					finished = false
+				}
+			}
+			if err := r.saveProgress(ctx, gen, table.name, progress[i]); err != nil {
+				return err
+			}
+		}
+
+		if finished {
+			return nil
+		}
+		if caughtUp {
+			if err := r.sleep(ctx); err != nil {
+				return err
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// readWindow delivers the changes of the streams written after their progress,
+// within the query window and before the limit, and returns the end of the
+// time range which was read.
+func (r *Reader) readWindow(ctx context.Context, table logTable, chunk []*streamProgress, limit time.Time) (time.Time, error) {
+	lower := chunk[0].last
+	for _, p := range chunk[1:] {
+		if compareTimeUUID(p.last, lower) < 0 {
+			lower = p.last
+		}
+	}
+	upper := lower.Time().Add(r.cfg.QueryWindow)
+	if upper.After(limit) {
+		upper = limit
+	}
+	if !upper.After(lower.Time()) {
+		return lower.Time(), nil
+	}
+
+	streams := make([][]byte, len(chunk))
+	byStream := make(map[string]*streamProgress, len(chunk))
+	for i, p := range chunk {
+		streams[i] = p.stream
+		byStream[string(p.stream)] = p
+	}
+
+	iter := r.cfg.Session.Query(table.query, streams, lower, gocql.MinTimeUUID(upper)).
+		WithContext(ctx).Consistency(r.cfg.Consistency).Iter()
+
+	var change *Change
+	deliver := func() error {
+		if change == nil {
+			return nil
+		}
+		if err := r.cfg.Consumer.Consume(ctx, *change); err != nil {
+			return consumerError{err}
+		}
+		p := byStream[string(change.StreamID)]
+		p.last, p.dirty = change.Time, true
+		change = nil
+		return nil
+	}
+
+	for {
+		m := make(map[string]interface{})
+		if !iter.MapScan(m) {
+			break
+		}
+		row := newChangeRow(m)
+		p, ok := byStream[string(row.StreamID)]
+		if !ok || compareTimeUUID(row.Time, p.last) <= 0 {
+			// consumed before the reader was restarted
+			continue
+		}
+		if change != nil && (change.Time != row.Time || !bytes.Equal(change.StreamID, row.StreamID)) {
+			if err := deliver(); err != nil {
+				iter.Close()
+				return time.Time{}, err
+			}
+		}
+		if change == nil {
+			change = &Change{Table: table.name, StreamID: row.StreamID, Time: row.Time}
+		}
+		change.add(row)
+	}
+	if err := iter.Close(); err != nil {
+		// the last change might be incomplete, it is read again by the next query
+		return time.Time{}, err
+	}
+	if err := deliver(); err != nil {
+		return time.Time{}, err
+	}
+
+	// all changes up to upper were consumed
+	end := gocql.MinTimeUUID(upper)
+	for _, p := range chunk {
+		if compareTimeUUID(p.last, end) < 0 {
+			p.last, p.dirty = end, true
+		}
+	}
+	return upper, nil
+}
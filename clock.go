@@ -0,0 +1,73 @@
+package gocql
+
+import "time"
+
+// Clock is the source of time of the driver, used by the reconnection timers,
+// the speculative executions, the heartbeats and to generate the client side
+// timestamps. It is the system clock by default, tests can set
+// ClusterConfig.Clock to a fake clock to advance the time without sleeping.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer returns a timer sending the current time on its channel after d.
+	NewTimer(d time.Duration) Timer
+	// NewTicker returns a ticker sending the current time on its channel every d.
+	NewTicker(d time.Duration) Ticker
+	// AfterFunc calls f in its own goroutine after d, the channel of the
+	// returned timer is nil.
+	AfterFunc(d time.Duration, f func()) Timer
+	// Sleep pauses the current goroutine for d.
+	Sleep(d time.Duration)
+}
+
+// Timer is a timer of a Clock, see time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a ticker of a Clock, see time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// systemClock is the Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
@@ -0,0 +1,87 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingClock is a system clock stopped at a time, which records the
+// durations of the timers created.
+type recordingClock struct {
+	systemClock
+	now time.Time
+
+	mu     sync.Mutex
+	timers []time.Duration
+}
+
+func (c *recordingClock) Now() time.Time {
+	return c.now
+}
+
+func (c *recordingClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	c.timers = append(c.timers, d)
+	c.mu.Unlock()
+	return c.systemClock.NewTimer(d)
+}
+
+func TestClusterClock(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		timestamps []int64
+	)
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: protoVersion4,
+		recvHook: func(f *framer) {
+			if f.header.op != opQuery && f.header.op != opBatch {
+				return
+			}
+			// the default timestamp is the last parameter of queries and batches
+			mu.Lock()
+			timestamps = append(timestamps, int64(binary.BigEndian.Uint64(f.buf[len(f.buf)-8:])))
+			mu.Unlock()
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	clock := &recordingClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cluster := testCluster(protoVersion4, srv.Address)
+	cluster.NumConns = 1
+	cluster.Clock = clock
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Query("void").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	batch := db.NewBatch(UnloggedBatch)
+	batch.Query("void")
+	// batches are not supported by the test server
+	db.ExecuteBatch(batch)
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := clock.now.UnixNano() / 1000
+	if len(timestamps) != 2 || timestamps[0] != expected || timestamps[1] != expected {
+		t.Fatalf("expected the timestamp %d for the query and the batch got %v", expected, timestamps)
+	}
+
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	// the timers of the node and schema event debouncers and the heartbeat
+	// of the connection
+	if len(clock.timers) != 3 || clock.timers[0] != time.Second || clock.timers[1] != time.Second || clock.timers[2] != time.Second {
+		t.Fatalf("expected the timers of the event debouncers and the heartbeat of the connection got %v", clock.timers)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"errors"
 	"net"
+	"syscall"
 	"time"
 )
 
@@ -20,6 +21,13 @@ type PoolConfig struct {
 	// It is not supported to use a single HostSelectionPolicy in multiple sessions
 	// (even if you close the old session before using in a new session).
 	HostSelectionPolicy HostSelectionPolicy
+
+	// DeterministicConnPick picks the first connection of a host, or of a
+	// shard, with available streams in the order they were established,
+	// instead of the least busy connection. Together with
+	// DeterministicHostPolicy it makes the connection serving a request
+	// predictable in tests, it should not be used in production.
+	DeterministicConnPick bool
 }
 
 func (p PoolConfig) buildPool(session *Session) *policyConnPool {
@@ -37,6 +45,17 @@ type ClusterConfig struct {
 	// address, which is used to index connected hosts. If the domain name specified
 	// resolves to more than 1 IP address then the driver may connect multiple times to
 	// the same host, and will not mark the node being down or up from events.
+	// Addresses may have a port, as in "10.0.0.1:19042", to connect to nodes
+	// listening on different ports, or through a port-mapping proxy. The
+	// nodes discovered at the address of a host with a port are connected to
+	// on that port, unless system.peers_v2 has their native port. The hosts
+	// sharing an address with different ports are told apart by the port a
+	// node is connected on, and are not used for the other nodes.
+	// Addresses may also be the name of DNS SRV records, such as
+	// "_cql._tcp.cluster.example.com", to connect to the targets of the
+	// records on their ports. The records are resolved when the session is
+	// created, and again when the control connection falls back to the
+	// initial hosts to reconnect.
 	Hosts []string
 
 	// CQL version (default: 3.0.0)
@@ -83,9 +102,27 @@ type ClusterConfig struct {
 	Keyspace string
 
 	// Number of connections per host.
+	// Scylla nodes get at least one connection per shard.
 	// Default: 2
 	NumConns int
 
+	// DisableShardAwarePort disables connecting to the shard-aware port of Scylla nodes.
+	// By default connections to Scylla nodes advertising a shard-aware port (19042) are
+	// established through it, choosing the local port so that each connection lands on the
+	// shard with the fewest connections. Disable it if a NAT between the client and the
+	// cluster rewrites source ports, or if the port is not reachable.
+	// DisableShardAwarePort has no effect if HostDialer is provided.
+	// Default: false
+	DisableShardAwarePort bool
+
+	// ServiceLevel is the Scylla service level (workload prioritization) the
+	// connections of the session are attached to. It is sent during the
+	// connection startup to the nodes which advertise support for it, and is
+	// ignored by other nodes. Application tiers sharing a cluster with a
+	// different priority should use a session each, with their own service level.
+	// Default: unset, the service level of the role of the user is used
+	ServiceLevel string
+
 	// Default consistency level.
 	// Default: Quorum
 	Consistency Consistency
@@ -116,10 +153,81 @@ type ClusterConfig struct {
 	// SocketKeepalive is used to set up the default dialer and is ignored if Dialer or HostDialer is provided.
 	SocketKeepalive time.Duration
 
+	// SocketKeepaliveInterval is the time between keepalive probes sent after the first
+	// unanswered probe, and SocketKeepaliveCount is the number of unanswered probes
+	// after which the connection is considered dead. Zero values keep the
+	// operating system defaults. Only supported on Linux.
+	// Both are ignored if Dialer or HostDialer is provided.
+	SocketKeepaliveInterval time.Duration
+	SocketKeepaliveCount    int
+
+	// SocketReadBufferSize and SocketWriteBufferSize set the size of the operating system
+	// receive and send buffers of each connection (SO_RCVBUF and SO_SNDBUF).
+	// High bandwidth-delay links, such as cross datacenter connections, might need larger
+	// buffers than the defaults. Zero values keep the operating system defaults.
+	// Both are ignored if HostDialer is provided.
+	SocketReadBufferSize  int
+	SocketWriteBufferSize int
+
+	// DisableTCPNoDelay enables Nagle's algorithm on connections.
+	// By default TCP_NODELAY is set, as the driver does its own write coalescing.
+	// DisableTCPNoDelay is ignored if HostDialer is provided.
+	DisableTCPNoDelay bool
+
+	// SocketControl, if set, is called after creating each network connection
+	// but before dialing, allowing to set arbitrary socket options.
+	// See net.Dialer.Control for details.
+	// SocketControl is ignored if Dialer or HostDialer is provided.
+	SocketControl func(network, address string, c syscall.RawConn) error
+
+	// MaxResponseFrameSize is the maximum size in bytes of the body of a result frame.
+	// Bigger responses, for example of an accidental unbounded SELECT with a large
+	// page size, are discarded without being buffered and the query fails with
+	// an *ErrFrameTooLarge error. The connection remains usable.
+	// (default: 0, only the protocol limit of 256MB applies)
+	MaxResponseFrameSize int
+
+	// MaxRequestFrameSize is the maximum size in bytes of the body of a request
+	// frame, compressed if compression is enabled. Bigger queries and batches
+	// fail with an *ErrRequestTooLarge error without being sent, rather than
+	// the server closing the connection. Set it to the
+	// native_transport_max_frame_size of the servers (16MB by default since
+	// Cassandra 4.0).
+	// (default: 0, only the protocol limit of 256MB applies)
+	MaxRequestFrameSize int
+
+	// MaxStreamWait is the maximum time a request waits for a stream of its
+	// connection to be released when all of them are in use, the context of
+	// the request bounding the wait too. ObservedQuery.StreamWait and
+	// ObservedBatch.StreamWait report the time waited. A negative value
+	// disables waiting: saturated connections are skipped, and requests fail
+	// with ErrNoConnections or ErrNoStreams if all of them are.
+	// (default: 0, requests wait up to Timeout)
+	MaxStreamWait time.Duration
+
+	// ConnEviction evicts the connections whose requests fail too often
+	// while their host is up, and replaces them.
+	// (default: nil, connections are only closed by TimeoutLimit or errors)
+	ConnEviction *ConnEvictionPolicy
+
 	// Maximum cache size for prepared statements globally for gocql.
 	// Default: 1000
 	MaxPreparedStmts int
 
+	// Maximum number of prepared statements cached per keyspace, so a single keyspace
+	// generating many distinct statements can not evict the statements of the others.
+	// Default: 0 (only MaxPreparedStmts applies)
+	MaxPreparedStmtsPerKeyspace int
+
+	// PreparedStatementEvicted, if set, is called when a prepared statement is evicted
+	// from the cache because MaxPreparedStmts or MaxPreparedStmtsPerKeyspace was reached.
+	// It is called while the cache is locked and must not block or execute queries.
+	PreparedStatementEvicted func(hostID, keyspace, statement string)
+
+	// QueryRegistry, if set, is the catalog of the statements executed with
+	// Session.NamedQuery. Its statements are prepared again after schema changes.
+	QueryRegistry *QueryRegistry
+
 	// Maximum cache size for query info about statements for each session.
 	// Default: 1000
 	MaxRoutingKeyInfo int
@@ -129,9 +237,24 @@ type ClusterConfig struct {
 	PageSize int
 
 	// Consistency for the serial part of queries, values can be either SERIAL or LOCAL_SERIAL.
-	// Default: unset
+	// It is the default of all the queries and batches of the session, which
+	// can override it with Query.SerialConsistency and Batch.SerialConsistency.
+	// Setting it avoids running the Paxos phase of lightweight transactions
+	// across datacenters by forgetting to set LOCAL_SERIAL on a statement.
+	// Default: unset, the server then uses SERIAL.
 	SerialConsistency SerialConsistency
 
+	// TableConsistency and TableSerialConsistency are the default consistency
+	// and serial consistency of the queries of a table, by "keyspace.table",
+	// over Consistency and SerialConsistency, so that critical tables default
+	// to QUORUM while others default to ONE without setting it on every query.
+	// The table of a query is resolved from its statement when it is created,
+	// with the keyspace of the session if the statement does not name one.
+	// Quoted names keep their case, other names are lower case. Batches are
+	// not affected.
+	TableConsistency       map[string]Consistency
+	TableSerialConsistency map[string]SerialConsistency
+
 	// SslOpts configures TLS use when HostDialer is not set.
 	// SslOpts is ignored if HostDialer is set.
 	SslOpts *SslOptions
@@ -140,6 +263,14 @@ type ClusterConfig struct {
 	// Default: true, only enabled for protocol 3 and above.
 	DefaultTimestamp bool
 
+	// TimestampGenerator generates the client side timestamps sent with
+	// DefaultTimestamp. If nil, the timestamps are read from the clock and
+	// are not guaranteed to increase, use a MonotonicTimestampGenerator for
+	// strictly increasing timestamps, a ShardedTimestampGenerator for
+	// timestamps increasing per processor or a ServerTimestampGenerator for
+	// server side timestamps. Queries and batches can override it.
+	TimestampGenerator TimestampGenerator
+
 	// PoolConfig configures the underlying connection pool, allowing the
 	// configuration of host selection and connection selection policies.
 	PoolConfig PoolConfig
@@ -147,17 +278,59 @@ type ClusterConfig struct {
 	// If not zero, gocql attempt to reconnect known DOWN nodes in every ReconnectInterval.
 	ReconnectInterval time.Duration
 
+	// If not zero, gocql refreshes the hosts of the ring from system.local and
+	// system.peers every RingRefreshInterval, to keep the ring current when
+	// topology events are disabled or not delivered, such as behind a proxy.
+	// The ring can also be refreshed with Session.RefreshRing.
+	// Default: 0, the ring is refreshed on topology events only.
+	RingRefreshInterval time.Duration
+
 	// The maximum amount of time to wait for schema agreement in a cluster after
 	// receiving a schema change frame. (default: 60s)
 	MaxWaitSchemaAgreement time.Duration
 
+	// ValidateBindValues makes queries validate their values against the bind
+	// markers of their prepared statements before sending them, reporting
+	// invalid values, including null partition key values, as a BindError
+	// naming the column. It can be overridden per query with
+	// Query.ValidateValues. (default: false)
+	ValidateBindValues bool
+
+	// PrepareOnAllHosts makes the session prepare a statement on all the up
+	// hosts once it is first prepared, in the background, as other drivers do,
+	// instead of on each host the first time it is executed there. It can be
+	// overridden per query with Query.PrepareOnAllHosts. (default: false)
+	PrepareOnAllHosts bool
+
+	// PrepareOnAllHostsConcurrency is the number of hosts a statement is
+	// prepared on at a time with PrepareOnAllHosts. (default: 4)
+	PrepareOnAllHostsConcurrency int
+
+	// ReprepareOnSchemaChange makes the session prepare again, in the
+	// background, the cached prepared statements referencing a table once it
+	// is altered, instead of on the first request getting an UNPREPARED error
+	// or stale result metadata. Requires schema events.
+	ReprepareOnSchemaChange bool
+
+	// HostTagger, if set, gives tags to hosts for HostInfo.Tags. It is called
+	// when the metadata of a host is read from the system tables and when a
+	// connection to the host is established, with the features of the host
+	// known. It must not block.
+	HostTagger func(host *HostInfo) map[string]string
+
 	// HostFilter will filter all incoming events for host, any which don't pass
 	// the filter will be ignored. If set will take precedence over any options set
 	// via Discovery
 	HostFilter HostFilter
 
+	// PeerValidator validates the peers read from system.peers, the peers it
+	// rejects are ignored.
+	// Default: DefaultPeerValidator()
+	PeerValidator PeerValidator
+
 	// AddressTranslator will translate addresses found on peer discovery and/or
-	// node change events.
+	// node change events. A HostAddressTranslator also receives the host being
+	// translated.
 	AddressTranslator AddressTranslator
 
 	// If IgnorePeerAddr is true and the address in system.peers does not match
@@ -184,6 +357,18 @@ type ClusterConfig struct {
 		DisableTopologyEvents bool
 		// disable registering for schema events (keyspace/table/function removed/created/updated)
 		DisableSchemaEvents bool
+		// DebounceWindow is the time the driver waits for more node status and
+		// topology events after receiving one before handling them. Bursts of
+		// events, such as from a rolling restart or a flapping node, are
+		// coalesced into a single ring refresh and the latest status of each node.
+		// Ring refreshes requested by the driver are debounced the same way.
+		// Default: 1s
+		DebounceWindow time.Duration
+		// MaxDebounceDelay bounds the time the events of a burst wait while
+		// more events keep coming within DebounceWindow, and the delay of the
+		// ring refreshes they trigger.
+		// Default: 10s
+		MaxDebounceDelay time.Duration
 	}
 
 	// DisableSkipMetadata will override the internal result metadata cache so that the driver does not
@@ -202,14 +387,64 @@ type ClusterConfig struct {
 	// Use it to collect metrics / stats from batch queries by providing an implementation of BatchObserver.
 	BatchObserver BatchObserver
 
+	// ConsistencyObserver is notified when the retry policy changes the
+	// consistency of a query or batch, or when the server reports a
+	// consistency other than the one requested.
+	ConsistencyObserver ConsistencyObserver
+
+	// TracePropagator injects the trace context of the context of queries and
+	// batches, such as the W3C traceparent of their span, into their custom
+	// payload, so that servers and proxies can correlate the requests with the
+	// distributed traces. With OpenTelemetry:
+	//
+	//	cluster.TracePropagator = func(ctx context.Context, carrier gocql.PayloadCarrier) {
+	//		otel.GetTextMapPropagator().Inject(ctx, carrier)
+	//	}
+	//
+	// Custom payloads require protocol v4 or above, the trace context is not
+	// propagated with older protocols.
+	// (default: nil)
+	TracePropagator func(ctx context.Context, carrier PayloadCarrier)
+
+	// ErrorContext wraps the errors of queries and batches, except
+	// ErrNotFound, in a *QueryError with the fingerprint of the statement, its
+	// keyspace, table and consistency and the coordinator host, to speed up
+	// troubleshooting. Errors must then be handled with errors.Is and
+	// errors.As rather than compared.
+	ErrorContext bool
+
+	// ErrorContextValues adds the values bound to queries to their
+	// *QueryError when ErrorContext is enabled. The values can be sensitive
+	// and end up in logs.
+	ErrorContextValues bool
+
+	// ControlConnObserver is notified when the control connection is
+	// established, lost, or reestablished to another host.
+	ControlConnObserver ControlConnObserver
+
+	// QueryInterceptors wrap the execution of the queries and batches of the
+	// session, the first interceptor being the outermost.
+	QueryInterceptors []QueryInterceptor
+
 	// ConnectObserver will set the provided connect observer on all queries
 	// created from this session.
 	ConnectObserver ConnectObserver
 
+	// TLSHandshakeObserver is notified of the TLS handshakes of the
+	// connections when SslOpts is set and HostDialer is not.
+	TLSHandshakeObserver TLSHandshakeObserver
+
+	// ConnEvictionObserver is notified when ConnEviction evicts a connection.
+	ConnEvictionObserver ConnEvictionObserver
+
 	// FrameHeaderObserver will set the provided frame header observer on all frames' headers created from this session.
 	// Use it to collect metrics / stats from frames by providing an implementation of FrameHeaderObserver.
 	FrameHeaderObserver FrameHeaderObserver
 
+	// FrameDumper, if set, dumps the frames exchanged with the nodes once
+	// enabled, see FrameDumper.
+	FrameDumper *FrameDumper
+
 	// StreamObserver will be notified of stream state changes.
 	// This can be used to track in-flight protocol requests and responses.
 	StreamObserver StreamObserver
@@ -217,6 +452,15 @@ type ClusterConfig struct {
 	// Default idempotence for queries
 	DefaultIdempotence bool
 
+	// InferIdempotence marks the queries and batch statements which are
+	// known to be idempotent from their CQL as idempotent when they are
+	// created, so that they are retried and executed speculatively: SELECT
+	// statements, and INSERT, UPDATE and DELETE statements which are not
+	// lightweight transactions, do not call now() or uuid(), do not update
+	// counters, and do not append or prepend to lists. The idempotence can
+	// still be set with Query.Idempotent. (default: false)
+	InferIdempotence bool
+
 	// The time to wait for frames before flushing the frames connection to Cassandra.
 	// Can help reduce syscall overhead by making less calls to write. Set to 0 to
 	// disable.
@@ -224,6 +468,14 @@ type ClusterConfig struct {
 	// (default: 200 microseconds)
 	WriteCoalesceWaitTime time.Duration
 
+	// WriteCoalesceMaxBytes bounds the amount of data coalesced into a single write.
+	// Once the frames waiting to be written reach this size they are flushed
+	// immediately, without waiting for WriteCoalesceWaitTime to elapse.
+	// Set to 0 to only flush on WriteCoalesceWaitTime.
+	//
+	// (default: 0)
+	WriteCoalesceMaxBytes int
+
 	// Dialer will be used to establish all connections created for this Cluster.
 	// If not provided, a default dialer configured with ConnectTimeout will be used.
 	// Dialer is ignored if HostDialer is provided.
@@ -237,6 +489,12 @@ type ClusterConfig struct {
 	// If not specified, defaults to the global gocql.Logger.
 	Logger StdLogger
 
+	// Clock is the source of time of the reconnection timers, speculative
+	// executions, heartbeats, debouncing of events, downtime of the control
+	// connection and client side timestamps.
+	// If not specified, defaults to the system clock.
+	Clock Clock
+
 	// internal config for testing
 	disableControlConn bool
 }
@@ -272,6 +530,8 @@ func NewCluster(hosts ...string) *ClusterConfig {
 		ConvictionPolicy:       &SimpleConvictionPolicy{},
 		ReconnectionPolicy:     &ConstantReconnectionPolicy{MaxRetries: 3, Interval: 1 * time.Second},
 		WriteCoalesceWaitTime:  200 * time.Microsecond,
+
+		PrepareOnAllHostsConcurrency: 4,
 	}
 	return cfg
 }
@@ -283,6 +543,31 @@ func (cfg *ClusterConfig) logger() StdLogger {
 	return cfg.Logger
 }
 
+// timestamp returns the next client side timestamp, in microseconds.
+func (cfg *ClusterConfig) timestamp() int64 {
+	return cfg.timestampFrom(nil)
+}
+
+// timestampFrom returns the next client side timestamp of gen, or of the
+// generator of the session if gen is nil. It is 0 for a server side
+// timestamp.
+func (cfg *ClusterConfig) timestampFrom(gen TimestampGenerator) int64 {
+	if gen == nil {
+		gen = cfg.TimestampGenerator
+	}
+	if gen != nil {
+		return gen.Next()
+	}
+	return cfg.clock().Now().UnixNano() / 1000
+}
+
+func (cfg *ClusterConfig) clock() Clock {
+	if cfg.Clock == nil {
+		return systemClock{}
+	}
+	return cfg.Clock
+}
+
 // CreateSession initializes the cluster based on this config and returns a
 // session object that can be used to interact with the database.
 func (cfg *ClusterConfig) CreateSession() (*Session, error) {
@@ -304,12 +589,41 @@ func (cfg *ClusterConfig) translateAddressPort(addr net.IP, port int) (net.IP, i
 	return newAddr, newPort
 }
 
+// translateHostAddressPort translates the connect address and port of the
+// host, as discovered from system.local or system.peers, with the
+// AddressTranslator, passing it the host if it is a HostAddressTranslator.
+func (cfg *ClusterConfig) translateHostAddressPort(ctx context.Context, host *HostInfo) (net.IP, int) {
+	addr, port := host.ConnectAddress(), host.Port()
+	translator, ok := cfg.AddressTranslator.(HostAddressTranslator)
+	if !ok || len(addr) == 0 {
+		return cfg.translateAddressPort(addr, port)
+	}
+	newAddr, newPort := translator.TranslateHost(ctx, host, addr, port)
+	if gocqlDebug {
+		cfg.logger().Printf("gocql: translating address '%v:%d' of host %s to '%v:%d'", addr, port, host.HostID(), newAddr, newPort)
+	}
+	return newAddr, newPort
+}
+
+func (cfg *ClusterConfig) validatePeer(host *HostInfo) error {
+	if cfg.PeerValidator == nil {
+		return validatePeer(host)
+	}
+	return cfg.PeerValidator.ValidatePeer(host)
+}
+
 func (cfg *ClusterConfig) filterHost(host *HostInfo) bool {
 	return !(cfg.HostFilter == nil || cfg.HostFilter.Accept(host))
 }
 
+func (cfg *ClusterConfig) tagHost(host *HostInfo) {
+	if cfg.HostTagger != nil {
+		host.setTags(cfg.HostTagger(host))
+	}
+}
+
 var (
 	ErrNoHosts              = errors.New("no hosts provided")
-	ErrNoConnectionsStarted = errors.New("no connections were made when creating the session")
+	ErrNoConnectionsStarted = newCategorizedError(ErrConnectionCategory, "no connections were made when creating the session")
 	ErrHostQueryFailed      = errors.New("unable to populate Hosts")
 )
@@ -1,6 +1,7 @@
 package gocql
 
 import (
+	"context"
 	"net"
 	"reflect"
 	"testing"
@@ -49,6 +50,36 @@ func TestClusterConfig_translateAddressAndPort_EmptyAddr(t *testing.T) {
 	assertEqual(t, "translated port", 0, newPort)
 }
 
+func TestClusterConfig_translateHostAddressPort(t *testing.T) {
+	type ctxKey struct{}
+	nodePorts := map[string]int{"host1": 30042, "host2": 30043}
+
+	cfg := NewCluster()
+	cfg.AddressTranslator = HostAddressTranslatorFunc(func(ctx context.Context, host *HostInfo, addr net.IP, port int) (net.IP, int) {
+		assertEqual(t, "context value", "session", ctx.Value(ctxKey{}))
+		if nodePort, ok := nodePorts[host.HostID()]; ok {
+			return net.ParseIP("192.168.0.1"), nodePort
+		}
+		return addr, port
+	})
+	ctx := context.WithValue(context.Background(), ctxKey{}, "session")
+
+	host := &HostInfo{hostId: "host2", rpcAddress: net.ParseIP("10.0.0.2"), port: 9042}
+	newAddr, newPort := cfg.translateHostAddressPort(ctx, host)
+	assertTrue(t, "translated address", net.ParseIP("192.168.0.1").Equal(newAddr))
+	assertEqual(t, "translated port", 30043, newPort)
+
+	host = &HostInfo{hostId: "host3", rpcAddress: net.ParseIP("10.0.0.3"), port: 9042}
+	newAddr, newPort = cfg.translateHostAddressPort(ctx, host)
+	assertTrue(t, "untranslated address", net.ParseIP("10.0.0.3").Equal(newAddr))
+	assertEqual(t, "untranslated port", 9042, newPort)
+
+	cfg.AddressTranslator = staticAddressTranslator(net.ParseIP("10.10.10.10"), 5432)
+	newAddr, newPort = cfg.translateHostAddressPort(ctx, host)
+	assertTrue(t, "address translated by an AddressTranslator", net.ParseIP("10.10.10.10").Equal(newAddr))
+	assertEqual(t, "port translated by an AddressTranslator", 5432, newPort)
+}
+
 func TestClusterConfig_translateAddressAndPort_Success(t *testing.T) {
 	cfg := NewCluster()
 	cfg.AddressTranslator = staticAddressTranslator(net.ParseIP("10.10.10.10"), 5432)
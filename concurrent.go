@@ -0,0 +1,160 @@
+package gocql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ConcurrentStatement is a statement executed by ExecuteConcurrent, with the
+// values bound to it.
+type ConcurrentStatement struct {
+	Statement string
+	Values    []interface{}
+}
+
+// ConcurrentOptions configures ExecuteConcurrent.
+type ConcurrentOptions struct {
+	// Concurrency is the maximum number of statements executed at a time.
+	// (default: 100)
+	Concurrency int
+	// RetryPolicy overrides the retry policy of the session for each
+	// statement.
+	RetryPolicy RetryPolicy
+	// Consistency, if set, overrides the consistency of the session for each
+	// statement.
+	Consistency *Consistency
+	// Idempotent marks the statements as idempotent, which allows them to be
+	// executed speculatively and retried after rate limit errors. If false,
+	// the statements are as idempotent as the session makes them, see
+	// ClusterConfig.DefaultIdempotence and ClusterConfig.InferIdempotence.
+	Idempotent bool
+	// FailFast stops executing statements after the first failure. The
+	// statements which are not executed fail with ErrConcurrentAborted.
+	FailFast bool
+	// ReadRows reads the rows returned by each statement into its result,
+	// instead of discarding them.
+	ReadRows bool
+}
+
+// ErrConcurrentAborted is the error of the statements which ExecuteConcurrent
+// did not execute because of a previous failure with FailFast.
+var ErrConcurrentAborted = errors.New("gocql: statement not executed after a previous failure")
+
+// ConcurrentResult is the result of a statement executed by
+// ExecuteConcurrent.
+type ConcurrentResult struct {
+	Statement ConcurrentStatement
+	// Rows are the rows returned by the statement if ReadRows is set.
+	Rows []map[string]interface{}
+	Err  error
+}
+
+// ConcurrentError is the error returned by ExecuteConcurrent when some of the
+// statements failed. It unwraps to the error of the first of them.
+type ConcurrentError struct {
+	// Failed are the results of the statements which failed, in the order
+	// of the statements.
+	Failed []ConcurrentResult
+	// Total is the number of statements.
+	Total int
+}
+
+func (e *ConcurrentError) Error() string {
+	return fmt.Sprintf("gocql: %d of %d statements failed, first: %v", len(e.Failed), e.Total, e.Failed[0].Err)
+}
+
+func (e *ConcurrentError) Unwrap() error {
+	return e.Failed[0].Err
+}
+
+// ExecuteConcurrent executes independent statements on session, at most
+// opts.Concurrency at a time, and returns their results in the order of the
+// statements. Each statement goes through the host selection, retries and
+// speculative executions of a query. If any statement failed, the error is
+// a *ConcurrentError listing them.
+//
+// Statements which are not started before ctx is done fail with the error of
+// ctx.
+func ExecuteConcurrent(ctx context.Context, session *Session, statements []ConcurrentStatement, opts ConcurrentOptions) ([]ConcurrentResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 100
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		aborted bool
+		results = make([]ConcurrentResult, len(statements))
+	)
+	for i, stmt := range statements {
+		results[i].Statement = stmt
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		mu.Lock()
+		abort := aborted
+		mu.Unlock()
+		if abort {
+			<-sem
+			results[i].Err = ErrConcurrentAborted
+			continue
+		}
+		wg.Add(1)
+		go func(result *ConcurrentResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result.Rows, result.Err = executeConcurrent(ctx, session, result.Statement, opts)
+			if result.Err != nil && opts.FailFast {
+				mu.Lock()
+				aborted = true
+				mu.Unlock()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+
+	var failed []ConcurrentResult
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) > 0 {
+		return results, &ConcurrentError{Failed: failed, Total: len(statements)}
+	}
+	return results, nil
+}
+
+func executeConcurrent(ctx context.Context, session *Session, stmt ConcurrentStatement, opts ConcurrentOptions) ([]map[string]interface{}, error) {
+	qry := session.Query(stmt.Statement, stmt.Values...).WithContext(ctx)
+	defer qry.Release()
+	if opts.Idempotent {
+		qry.Idempotent(true)
+	}
+	if opts.RetryPolicy != nil {
+		qry.RetryPolicy(opts.RetryPolicy)
+	}
+	if opts.Consistency != nil {
+		qry.Consistency(*opts.Consistency)
+	}
+
+	iter := qry.Iter()
+	if !opts.ReadRows {
+		return nil, iter.Close()
+	}
+	rows, err := iter.SliceMap()
+	if err != nil {
+		iter.Close()
+		return nil, err
+	}
+	return rows, iter.Close()
+}
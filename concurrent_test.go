@@ -0,0 +1,98 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestExecuteConcurrent(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	statements := []ConcurrentStatement{
+		{Statement: "void"},
+		{Statement: "kill"},
+		{Statement: "void"},
+		{Statement: "void"},
+	}
+	results, err := ExecuteConcurrent(context.Background(), db, statements, ConcurrentOptions{Concurrency: 2})
+	var concurrentErr *ConcurrentError
+	if !errors.As(err, &concurrentErr) || len(concurrentErr.Failed) != 1 || concurrentErr.Total != 4 {
+		t.Fatalf("expected 1 of 4 statements to fail, got %v", err)
+	}
+	if !errors.Is(err, ErrOverloadedCategory) {
+		t.Errorf("expected an overloaded error, got %v", err)
+	}
+	if len(results) != len(statements) {
+		t.Fatalf("expected %d results, got %d", len(statements), len(results))
+	}
+	for i, result := range results {
+		if result.Statement.Statement != statements[i].Statement {
+			t.Errorf("%d: expected the result of %q, got %q", i, statements[i].Statement, result.Statement.Statement)
+		}
+		if failed := result.Err != nil; failed != (i == 1) {
+			t.Errorf("%d: unexpected error %v", i, result.Err)
+		}
+	}
+
+	results, err = ExecuteConcurrent(context.Background(), db, statements[1:], ConcurrentOptions{Concurrency: 1, FailFast: true})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for i, result := range results[1:] {
+		if result.Err != ErrConcurrentAborted {
+			t.Errorf("%d: expected the statement to be aborted, got %v", i, result.Err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = ExecuteConcurrent(ctx, db, statements[:1], ConcurrentOptions{})
+	if !errors.Is(err, context.Canceled) || results[0].Err == nil {
+		t.Errorf("expected the statement to be canceled, got %v", err)
+	}
+
+	// the idempotence of the session is kept unless the statements are
+	// marked idempotent
+	db.cfg.DefaultIdempotence = true
+	policy := &idempotenceRetryPolicy{}
+	ExecuteConcurrent(context.Background(), db, statements[1:2], ConcurrentOptions{RetryPolicy: policy})
+	if len(policy.idempotent) != 1 || !policy.idempotent[0] {
+		t.Errorf("expected the statement to be idempotent, got %v", policy.idempotent)
+	}
+
+	// the consistency of the session is kept unless it is overridden, by any
+	// consistency including ANY
+	consistency := Any
+	ExecuteConcurrent(context.Background(), db, statements[1:2], ConcurrentOptions{RetryPolicy: policy, Consistency: &consistency})
+	if len(policy.consistencies) != 2 || policy.consistencies[0] != db.cfg.Consistency || policy.consistencies[1] != Any {
+		t.Errorf("expected the consistencies [%v ANY], got %v", db.cfg.Consistency, policy.consistencies)
+	}
+}
+
+// idempotenceRetryPolicy records the idempotence and the consistency of the
+// queries it is asked to retry, and does not retry them.
+type idempotenceRetryPolicy struct {
+	idempotent    []bool
+	consistencies []Consistency
+}
+
+func (p *idempotenceRetryPolicy) Attempt(q RetryableQuery) bool {
+	p.idempotent = append(p.idempotent, q.(ExecutableQuery).IsIdempotent())
+	p.consistencies = append(p.consistencies, q.GetConsistency())
+	return false
+}
+
+func (p *idempotenceRetryPolicy) GetRetryType(error) RetryType {
+	return Rethrow
+}
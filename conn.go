@@ -8,6 +8,7 @@ import (
 	"bufio"
 	"context"
 	"crypto/tls"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -19,7 +20,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/gocql/gocql/internal/lru"
 	"github.com/gocql/gocql/internal/streams"
 )
 
@@ -70,12 +70,20 @@ type PasswordAuthenticator struct {
 	Username              string
 	Password              string
 	AllowedAuthenticators []string
+	// Source provides the username and password at each authentication
+	// instead of Username and Password, so that new connections use the
+	// current credentials when they are rotated.
+	Source CredentialSource
 }
 
 func (p PasswordAuthenticator) Challenge(req []byte) ([]byte, Authenticator, error) {
 	if !approve(string(req), p.AllowedAuthenticators) {
 		return nil, nil, fmt.Errorf("unexpected authenticator %q", req)
 	}
+	p, err := p.withCredentials(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
 	resp := make([]byte, 2+len(p.Username)+len(p.Password))
 	resp[0] = 0
 	copy(resp[1:], p.Username)
@@ -117,6 +125,13 @@ type SslOptions struct {
 	//
 	// See SslOptions documentation to see how EnableHostVerification interacts with the provided tls.Config.
 	EnableHostVerification bool
+
+	// KeyLogWriter receives the TLS master secrets of the connections in
+	// the NSS key log format, so that their traffic can be decrypted by
+	// tools such as Wireshark. It overrides Config.KeyLogWriter, and must
+	// only be used for debugging as it compromises the security of the
+	// connections.
+	KeyLogWriter io.Writer
 }
 
 type ConnConfig struct {
@@ -195,6 +210,9 @@ type Conn struct {
 	host            *HostInfo
 	isSchemaV2      bool
 
+	// scyllaSupported are the Scylla extensions supported by the node and its sharding information.
+	scyllaSupported scyllaSupported
+
 	session *Session
 
 	// true if connection close process for the connection started.
@@ -204,6 +222,12 @@ type Conn struct {
 	cancel context.CancelFunc
 
 	timeouts int64
+	health   connHealth
+
+	// streamReleased is closed when a stream is released while streamWaiters
+	// requests wait for one. It is protected by mu.
+	streamWaiters  int32
+	streamReleased chan struct{}
 
 	logger StdLogger
 }
@@ -213,19 +237,36 @@ func (s *Session) connect(ctx context.Context, host *HostInfo, errorHandler Conn
 	return s.dial(ctx, host, s.connCfg, errorHandler)
 }
 
+// connectShard establishes a connection to the given shard of a Scylla node through
+// its shard-aware port using session's connection config.
+func (s *Session) connectShard(ctx context.Context, host *HostInfo, errorHandler ConnErrorHandler, target shardTarget) (*Conn, error) {
+	return s.dialShard(ctx, host, s.connCfg, errorHandler, &target)
+}
+
 // dial establishes a connection to a Cassandra node and notifies the session's connectObserver.
 func (s *Session) dial(ctx context.Context, host *HostInfo, connConfig *ConnConfig, errorHandler ConnErrorHandler) (*Conn, error) {
+	return s.dialShard(ctx, host, connConfig, errorHandler, nil)
+}
+
+// dialShard is like dial, but connects to the shard-aware port of the node if target is set.
+func (s *Session) dialShard(ctx context.Context, host *HostInfo, connConfig *ConnConfig, errorHandler ConnErrorHandler, target *shardTarget) (*Conn, error) {
 	var obs ObservedConnect
 	if s.connectObserver != nil {
 		obs.Host = host
 		obs.Start = time.Now()
 	}
 
-	conn, err := s.dialWithoutObserver(ctx, host, connConfig, errorHandler)
+	conn, err := s.dialWithoutObserver(ctx, host, connConfig, errorHandler, target)
 
 	if s.connectObserver != nil {
 		obs.End = time.Now()
 		obs.Err = err
+		obs.Shard = -1
+		if conn != nil {
+			obs.Shard = conn.shard()
+		} else if target != nil {
+			obs.Shard = target.shard
+		}
 		s.connectObserver.ObserveConnect(obs)
 	}
 
@@ -235,8 +276,20 @@ func (s *Session) dial(ctx context.Context, host *HostInfo, connConfig *ConnConf
 // dialWithoutObserver establishes connection to a Cassandra node.
 //
 // dialWithoutObserver does not notify the connection observer, so you most probably want to call dial() instead.
-func (s *Session) dialWithoutObserver(ctx context.Context, host *HostInfo, cfg *ConnConfig, errorHandler ConnErrorHandler) (*Conn, error) {
-	dialedHost, err := cfg.HostDialer.DialHost(ctx, host)
+func (s *Session) dialWithoutObserver(ctx context.Context, host *HostInfo, cfg *ConnConfig, errorHandler ConnErrorHandler, target *shardTarget) (*Conn, error) {
+	var (
+		dialedHost *DialedHost
+		err        error
+	)
+	if target != nil {
+		sd, ok := cfg.HostDialer.(shardDialer)
+		if !ok {
+			return nil, errShardAwarePortUnavailable
+		}
+		dialedHost, err = sd.dialShard(ctx, host, *target)
+	} else {
+		dialedHost, err = cfg.HostDialer.DialHost(ctx, host)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -308,7 +361,8 @@ func (c *Conn) init(ctx context.Context, dialedHost *DialedHost) error {
 
 	// dont coalesce startup frames
 	if c.session.cfg.WriteCoalesceWaitTime > 0 && !c.cfg.disableCoalesce && !dialedHost.DisableCoalesce {
-		c.w = newWriteCoalescer(c.conn, c.writeTimeout, c.session.cfg.WriteCoalesceWaitTime, ctx.Done())
+		c.w = newWriteCoalescer(c.conn, c.writeTimeout, c.session.cfg.WriteCoalesceWaitTime,
+			c.session.cfg.WriteCoalesceMaxBytes, ctx.Done())
 	}
 
 	go c.serve(ctx)
@@ -347,6 +401,8 @@ func (c *Conn) Read(p []byte) (n int, err error) {
 type startupCoordinator struct {
 	conn        *Conn
 	frameTicker chan struct{}
+	// startupOpts are the options sent in the STARTUP frame.
+	startupOpts map[string]string
 }
 
 func (s *startupCoordinator) setupConn(ctx context.Context) error {
@@ -419,8 +475,17 @@ func (s *startupCoordinator) options(ctx context.Context) error {
 	if !ok {
 		return NewErrProtocol("Unknown type of response to startup frame: %T", frame)
 	}
+	s.conn.scyllaSupported = parseScyllaSupported(supported.supported)
 
-	return s.startup(ctx, supported.supported)
+	if err := s.startup(ctx, supported.supported); err != nil {
+		return err
+	}
+	s.conn.host.setFeatures(newHostFeatures(supported.supported, s.startupOpts, s.conn))
+	if s.conn.session != nil {
+		// tags may depend on the features of the host
+		s.conn.session.cfg.tagHost(s.conn.host)
+	}
+	return nil
 }
 
 func (s *startupCoordinator) startup(ctx context.Context, supported map[string][]string) error {
@@ -430,6 +495,20 @@ func (s *startupCoordinator) startup(ctx context.Context, supported map[string][
 		"DRIVER_VERSION": driverVersion,
 	}
 
+	if _, ok := supported[scyllaTabletsRoutingV1]; ok {
+		m[scyllaTabletsRoutingV1] = ""
+	}
+	if s.conn.scyllaSupported.rateLimitErrorCode != 0 {
+		m[scyllaRateLimitError] = ""
+	}
+	if sl := s.conn.session.cfg.ServiceLevel; sl != "" {
+		if _, ok := supported[scyllaServiceLevel]; ok {
+			m[scyllaServiceLevel] = sl
+		} else {
+			s.conn.logger.Printf("gocql: %s does not support service levels, ignoring service level %q\n", s.conn.addr, sl)
+		}
+	}
+
 	if s.conn.compressor != nil {
 		comp := supported["COMPRESSION"]
 		name := s.conn.compressor.Name()
@@ -445,6 +524,7 @@ func (s *startupCoordinator) startup(ctx context.Context, supported map[string][
 		}
 	}
 
+	s.startupOpts = m
 	frame, err := s.write(ctx, &writeStartupFrame{opts: m})
 	if err != nil {
 		return err
@@ -467,7 +547,16 @@ func (s *startupCoordinator) authenticateHandshake(ctx context.Context, authFram
 		return fmt.Errorf("authentication required (using %q)", authFrame.class)
 	}
 
-	resp, challenger, err := s.conn.auth.Challenge([]byte(authFrame.class))
+	auth := s.conn.auth
+	if p, ok := auth.(PasswordAuthenticator); ok {
+		// fetch the credentials with the context of the connection
+		var err error
+		if auth, err = p.withCredentials(ctx); err != nil {
+			return err
+		}
+	}
+
+	resp, challenger, err := auth.Challenge([]byte(authFrame.class))
 	if err != nil {
 		return err
 	}
@@ -481,6 +570,9 @@ func (s *startupCoordinator) authenticateHandshake(ctx context.Context, authFram
 
 		switch v := frame.(type) {
 		case error:
+			if reqErr, ok := v.(RequestError); ok && reqErr.Code() == ErrCodeCredentials {
+				invalidateCredentials(s.conn.auth)
+			}
 			return v
 		case *authSuccessFrame:
 			if challenger != nil {
@@ -518,19 +610,40 @@ func (c *Conn) closeWithError(err error) {
 
 	// We should attempt to deliver the error back to the caller if it
 	// exists. However, don't block c.mu while we are delivering the
-	// error to outstanding calls.
+	// error to outstanding calls. Asynchronous calls are completed even
+	// when the connection is closed without an error, as nothing else
+	// waits for the connection to be closed on their behalf.
 	if err != nil {
 		callsToClose = c.calls
 		// It is safe to change c.calls to nil. Nobody should use it after c.closed is set to true.
 		c.calls = nil
+	} else {
+		for stream, call := range c.calls {
+			if call.done != nil {
+				if callsToClose == nil {
+					callsToClose = make(map[int]*callReq)
+				}
+				callsToClose[stream] = call
+			}
+		}
 	}
 	c.mu.Unlock()
 
+	callErr := categorizeConnError(err)
+	if err == nil {
+		callErr = ErrConnectionClosed
+	}
 	for _, req := range callsToClose {
 		// we need to send the error to all waiting queries.
-		select {
-		case req.resp <- callResp{err: err}:
-		case <-req.timeout:
+		if req.done != nil {
+			if req.finish() {
+				req.done(callResp{err: callErr})
+			}
+		} else {
+			select {
+			case req.resp <- callResp{err: callErr}:
+			case <-req.timeout:
+			}
 		}
 		if req.streamObserverContext != nil {
 			req.streamObserverEndOnce.Do(func() {
@@ -594,7 +707,7 @@ func (p *protocolError) Error() string {
 
 func (c *Conn) heartBeat(ctx context.Context) {
 	sleepTime := 1 * time.Second
-	timer := time.NewTimer(sleepTime)
+	timer := c.session.cfg.clock().NewTimer(sleepTime)
 	defer timer.Stop()
 
 	var failures int
@@ -610,7 +723,7 @@ func (c *Conn) heartBeat(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case <-timer.C:
+		case <-timer.C():
 		}
 
 		framer, err := c.exec(context.Background(), &writeOptionsFrame{}, nil)
@@ -653,7 +766,7 @@ func (c *Conn) recv(ctx context.Context) error {
 	head, err := readHeader(c.r, c.headerBuf[:])
 	headEndTime := time.Now()
 	if err != nil {
-		return err
+		return c.frameCorruption(err)
 	}
 
 	if c.frameObserver != nil {
@@ -670,13 +783,17 @@ func (c *Conn) recv(ctx context.Context) error {
 	}
 
 	if head.stream > c.streams.NumStreams {
-		return fmt.Errorf("gocql: frame header stream is beyond call expected bounds: %d", head.stream)
+		return c.frameCorruption(&FrameCorruptionError{
+			Direction: FrameInbound,
+			Err:       fmt.Errorf("gocql: frame header stream is beyond call expected bounds: %d", head.stream),
+		})
 	} else if head.stream == -1 {
 		// TODO: handle cassandra event frames, we shouldnt get any currently
 		framer := newFramer(c.compressor, c.version)
 		if err := framer.readFrame(c, &head); err != nil {
-			return err
+			return c.frameCorruption(err)
 		}
+		c.session.cfg.FrameDumper.dumpReceived(c.host, head, framer.buf)
 		go c.session.handleEvent(framer)
 		return nil
 	} else if head.stream <= 0 {
@@ -684,7 +801,7 @@ func (c *Conn) recv(ctx context.Context) error {
 		// or a bug in Cassandra, this should be an error, parse it and return.
 		framer := newFramer(c.compressor, c.version)
 		if err := framer.readFrame(c, &head); err != nil {
-			return err
+			return c.frameCorruption(err)
 		}
 
 		frame, err := framer.parseFrame()
@@ -713,34 +830,106 @@ func (c *Conn) recv(ctx context.Context) error {
 	}
 
 	framer := newFramer(c.compressor, c.version)
-
-	err = framer.readFrame(c, &head)
-	if err != nil {
-		// only net errors should cause the connection to be closed. Though
-		// cassandra returning corrupt frames will be returned here as well.
+	if head.op == opResult {
+		framer.readLimit = c.session.cfg.MaxResponseFrameSize
+	}
+	framer.rateLimitErrorCode = c.scyllaSupported.rateLimitErrorCode
+
+	var corrupted *FrameCorruptionError
+	if threshold := call.streamThreshold; threshold > 0 && head.op == opResult &&
+		head.flags&flagCompress == 0 && head.length > threshold && head.length <= maxFrameSize {
+		// the body is read by the caller, compressed bodies can only be
+		// decompressed whole
+		framer.streamFrame(c, &head, threshold)
+		err = nil
+	} else if err = framer.readFrame(c, &head); err != nil {
+		// only net errors and corrupted frames should cause the connection
+		// to be closed.
 		if _, ok := err.(net.Error); ok {
 			return err
 		}
+		err = c.frameCorruption(err)
+		errors.As(err, &corrupted)
+	} else {
+		c.session.cfg.FrameDumper.dumpReceived(c.host, head, framer.buf)
 	}
 
-	// we either, return a response to the caller, the caller timedout, or the
-	// connection has closed. Either way we should never block indefinatly here
-	select {
-	case call.resp <- callResp{framer: framer, err: err}:
-	case <-call.timeout:
-		c.releaseStream(call)
-	case <-ctx.Done():
+	delivered := false
+	if call.done != nil {
+		if call.finish() {
+			call.done(callResp{framer: framer, err: err})
+			delivered = true
+		} else {
+			// the call timed out or was canceled
+			c.releaseStream(call)
+		}
+	} else {
+		// we either, return a response to the caller, the caller timedout, or the
+		// connection has closed. Either way we should never block indefinatly here
+		select {
+		case call.resp <- callResp{framer: framer, err: err}:
+			delivered = true
+		case <-call.timeout:
+			c.releaseStream(call)
+		case <-ctx.Done():
+		}
 	}
 
+	if body := framer.body; body != nil {
+		// the caller reads the body of a streamed frame, the next frames
+		// can only be read once it is done
+		if delivered {
+			select {
+			case <-body.released:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if _, err := io.Copy(ioutil.Discard, body); err != nil {
+			return err
+		}
+		if body.err != nil {
+			return body.err
+		}
+	}
+
+	if corrupted != nil {
+		// the following frames can not be trusted
+		return corrupted
+	}
 	return nil
 }
 
+// frameCorruption sets the connection of err and counts it if it is a
+// *FrameCorruptionError.
+func (c *Conn) frameCorruption(err error) error {
+	var corrupted *FrameCorruptionError
+	if !errors.As(err, &corrupted) {
+		return err
+	}
+	corrupted.Host = c.host
+	if c.conn != nil {
+		corrupted.LocalAddr = c.conn.LocalAddr().String()
+		corrupted.RemoteAddr = c.conn.RemoteAddr().String()
+	}
+	c.session.errorStats.record(c.host, corrupted)
+	return err
+}
+
 func (c *Conn) releaseStream(call *callReq) {
 	if call.timer != nil {
 		call.timer.Stop()
 	}
 
 	c.streams.Clear(call.streamID)
+	if atomic.LoadInt32(&c.streamWaiters) > 0 {
+		c.mu.Lock()
+		if c.streamReleased != nil {
+			close(c.streamReleased)
+			c.streamReleased = nil
+		}
+		c.mu.Unlock()
+	}
 
 	if call.streamObserverContext != nil {
 		call.streamObserverEndOnce.Do(func() {
@@ -765,12 +954,29 @@ type callReq struct {
 
 	timer *time.Timer
 
+	// streamThreshold is the threshold of Query.StreamCells, the result
+	// frames larger than it are streamed.
+	streamThreshold int
+
 	// streamObserverContext is notified about events regarding this stream
 	streamObserverContext StreamObserverContext
 
 	// streamObserverEndOnce ensures that either StreamAbandoned or StreamFinished is called,
 	// but not both.
 	streamObserverEndOnce sync.Once
+
+	// done, if set, is called with the response instead of sending it on
+	// resp, see execStreamAsync.
+	done func(callResp)
+	// finished is set by whichever of the response, the timeout, the
+	// context and the closing of the connection completes an asynchronous
+	// call first.
+	finished int32
+}
+
+// finish reports whether the asynchronous call is completed by the caller.
+func (call *callReq) finish() bool {
+	return atomic.CompareAndSwapInt32(&call.finished, 0, 1)
 }
 
 type callResp struct {
@@ -837,12 +1043,13 @@ func (c *deadlineContextWriter) writeContext(ctx context.Context, p []byte) (int
 }
 
 func newWriteCoalescer(conn deadlineWriter, writeTimeout, coalesceDuration time.Duration,
-	quit <-chan struct{}) *writeCoalescer {
+	maxBytes int, quit <-chan struct{}) *writeCoalescer {
 	wc := &writeCoalescer{
-		writeCh: make(chan writeRequest),
-		c:       conn,
-		quit:    quit,
-		timeout: writeTimeout,
+		writeCh:  make(chan writeRequest),
+		c:        conn,
+		quit:     quit,
+		timeout:  writeTimeout,
+		maxBytes: maxBytes,
 	}
 	go wc.writeFlusher(coalesceDuration)
 	return wc
@@ -858,6 +1065,11 @@ type writeCoalescer struct {
 
 	timeout time.Duration
 
+	// maxBytes, if positive, is the size budget of a single coalesced write.
+	// Pending frames are flushed as soon as their total size reaches maxBytes
+	// instead of waiting for the coalesce timer to fire.
+	maxBytes int
+
 	testEnqueuedHook func()
 	testFlushedHook  func()
 }
@@ -907,23 +1119,48 @@ func (w *writeCoalescer) writeFlusher(interval time.Duration) {
 		<-timer.C
 	}
 
-	w.writeFlusherImpl(timer.C, func() { timer.Reset(interval) })
+	w.writeFlusherImpl(systemTimer{timer}, interval)
 }
 
-func (w *writeCoalescer) writeFlusherImpl(timerC <-chan time.Time, resetTimer func()) {
+func (w *writeCoalescer) writeFlusherImpl(timer Timer, interval time.Duration) {
 	running := false
 
 	var buffers net.Buffers
 	var resultChans []chan<- writeResult
+	var pending int
 
 	for {
 		select {
 		case req := <-w.writeCh:
 			buffers = append(buffers, req.data)
 			resultChans = append(resultChans, req.resultChan)
+			pending += len(req.data)
+			if w.maxBytes > 0 && pending >= w.maxBytes {
+				// Size budget exhausted, don't wait for the timer. The timer is
+				// stopped, and drained if it fired already, so that the next
+				// write starts a full interval rather than being flushed
+				// early by the stale expiry.
+				if running {
+					if !timer.Stop() {
+						select {
+						case <-timer.C():
+						default:
+						}
+					}
+					running = false
+				}
+				w.flush(resultChans, buffers)
+				buffers = nil
+				resultChans = nil
+				pending = 0
+				if w.testFlushedHook != nil {
+					w.testFlushedHook()
+				}
+				continue
+			}
 			if !running {
 				// Start timer on first write.
-				resetTimer()
+				timer.Reset(interval)
 				running = true
 			}
 		case <-w.quit:
@@ -937,11 +1174,15 @@ func (w *writeCoalescer) writeFlusherImpl(timerC <-chan time.Time, resetTimer fu
 				resultChan <- result
 			}
 			return
-		case <-timerC:
+		case <-timer.C():
 			running = false
+			if len(buffers) == 0 {
+				continue
+			}
 			w.flush(resultChans, buffers)
 			buffers = nil
 			resultChans = nil
+			pending = 0
 			if w.testFlushedHook != nil {
 				w.testFlushedHook()
 			}
@@ -1007,24 +1248,85 @@ func (c *Conn) addCall(call *callReq) error {
 }
 
 func (c *Conn) exec(ctx context.Context, req frameBuilder, tracer Tracer) (*framer, error) {
+	stream, _, err := c.acquireStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.execStream(ctx, stream, req, tracer)
+}
+
+// acquireStream returns a free stream of the connection, waiting for one up
+// to ClusterConfig.MaxStreamWait and the deadline of ctx, and the time it
+// waited.
+func (c *Conn) acquireStream(ctx context.Context) (int, time.Duration, error) {
 	if ctxErr := ctx.Err(); ctxErr != nil {
-		return nil, ctxErr
+		return 0, 0, ctxErr
+	}
+	if stream, ok := c.streams.GetStream(); ok {
+		return stream, 0, nil
 	}
 
-	// TODO: move tracer onto conn
-	stream, ok := c.streams.GetStream()
-	if !ok {
-		return nil, ErrNoStreams
+	maxWait := c.session.cfg.MaxStreamWait
+	if maxWait == 0 {
+		maxWait = c.session.cfg.Timeout
+	}
+	if maxWait < 0 {
+		return 0, 0, ErrNoStreams
+	}
+
+	start := time.Now()
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	atomic.AddInt32(&c.streamWaiters, 1)
+	defer atomic.AddInt32(&c.streamWaiters, -1)
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return 0, time.Since(start), ErrConnectionClosed
+		}
+		if c.streamReleased == nil {
+			c.streamReleased = make(chan struct{})
+		}
+		released := c.streamReleased
+		c.mu.Unlock()
+
+		// a stream might have been released before waiting for the channel
+		if stream, ok := c.streams.GetStream(); ok {
+			return stream, time.Since(start), nil
+		}
+
+		select {
+		case <-released:
+		case <-timeout:
+			return 0, time.Since(start), ErrNoStreams
+		case <-ctx.Done():
+			return 0, time.Since(start), ctx.Err()
+		case <-c.ctx.Done():
+			return 0, time.Since(start), ErrConnectionClosed
+		}
 	}
+}
 
+// execStream sends the frame built by req on the stream acquired with
+// acquireStream and waits for the response.
+func (c *Conn) execStream(ctx context.Context, stream int, req frameBuilder, tracer Tracer) (*framer, error) {
+	// TODO: move tracer onto conn
 	// resp is basically a waiting semaphore protecting the framer
 	framer := newFramer(c.compressor, c.version)
+	framer.writeLimit = c.session.cfg.MaxRequestFrameSize
 
 	call := &callReq{
 		timeout:  make(chan struct{}),
 		streamID: stream,
 		resp:     make(chan callResp),
 	}
+	call.streamThreshold, _ = ctx.Value(streamCellsKey{}).(int)
 
 	if c.streamObserver != nil {
 		call.streamObserverContext = c.streamObserver.StreamContext(ctx)
@@ -1063,9 +1365,10 @@ func (c *Conn) exec(ctx context.Context, req frameBuilder, tracer Tracer) (*fram
 		// We need to release the stream after we remove the call from c.calls, otherwise the existingCall != nil
 		// check above could fail.
 		c.releaseStream(call)
-		return nil, err
+		return nil, c.frameCorruption(err)
 	}
 
+	c.session.cfg.FrameDumper.dumpSent(c.host, framer.buf)
 	n, err := c.w.writeContext(ctx, framer.buf)
 	if err != nil {
 		// closeWithError will block waiting for this stream to either receive a response
@@ -1091,11 +1394,11 @@ func (c *Conn) exec(ctx context.Context, req frameBuilder, tracer Tracer) (*fram
 			// send a frame on, with all the streams used up and not returned.
 			c.closeWithError(err)
 		}
-		return nil, err
+		return nil, categorizeConnError(err)
 	}
 
 	var timeoutCh <-chan time.Time
-	if c.timeout > 0 {
+	if timeout := c.requestTimeout(ctx); timeout > 0 {
 		if call.timer == nil {
 			call.timer = time.NewTimer(0)
 			<-call.timer.C
@@ -1108,7 +1411,7 @@ func (c *Conn) exec(ctx context.Context, req frameBuilder, tracer Tracer) (*fram
 			}
 		}
 
-		call.timer.Reset(c.timeout)
+		call.timer.Reset(timeout)
 		timeoutCh = call.timer.C
 	}
 
@@ -1121,6 +1424,7 @@ func (c *Conn) exec(ctx context.Context, req frameBuilder, tracer Tracer) (*fram
 	case resp := <-call.resp:
 		close(call.timeout)
 		if resp.err != nil {
+			c.recordRequest(resp.err)
 			if !c.Closed() {
 				// if the connection is closed then we cant release the stream,
 				// this is because the request is still outstanding and we have
@@ -1137,8 +1441,10 @@ func (c *Conn) exec(ctx context.Context, req frameBuilder, tracer Tracer) (*fram
 		// Ensure that the stream is not released if there are potentially outstanding
 		// requests on the stream to prevent nil pointer dereferences in recv().
 		defer c.releaseStream(call)
+		c.recordRequest(nil)
 
 		if v := resp.framer.header.version.version(); v != c.version {
+			resp.framer.releaseBody()
 			return nil, NewErrProtocol("unexpected protocol version in response: got %d expected %d", v, c.version)
 		}
 
@@ -1146,6 +1452,7 @@ func (c *Conn) exec(ctx context.Context, req frameBuilder, tracer Tracer) (*fram
 	case <-timeoutCh:
 		close(call.timeout)
 		c.handleTimeout()
+		c.recordRequest(ErrTimeoutNoResponse)
 		return nil, ErrTimeoutNoResponse
 	case <-ctxDone:
 		close(call.timeout)
@@ -1156,6 +1463,141 @@ func (c *Conn) exec(ctx context.Context, req frameBuilder, tracer Tracer) (*fram
 	}
 }
 
+// execStreamAsync is like execStream, but it calls done with the response
+// instead of waiting for it. The frame is written before execStreamAsync
+// returns, done is called once: from the goroutine reading the responses of
+// the connection when the response arrives, or when the request fails,
+// times out, ctx is done or the connection is closed. done must not block.
+func (c *Conn) execStreamAsync(ctx context.Context, stream int, req frameBuilder, tracer Tracer, done func(*framer, error)) {
+	framer := newFramer(c.compressor, c.version)
+	framer.writeLimit = c.session.cfg.MaxRequestFrameSize
+
+	var (
+		// mu protects the timer and the context callback of the call, which
+		// are set up after the frame is written, so the response can arrive
+		// before.
+		mu      sync.Mutex
+		stopped bool
+		timer   *time.Timer
+		stopCtx func() bool
+	)
+	stop := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		if timer != nil {
+			timer.Stop()
+		}
+		if stopCtx != nil {
+			stopCtx()
+		}
+	}
+
+	call := &callReq{
+		streamID: stream,
+	}
+	call.streamThreshold, _ = ctx.Value(streamCellsKey{}).(int)
+	call.done = func(resp callResp) {
+		stop()
+		if resp.err != nil {
+			c.recordRequest(resp.err)
+			if !c.Closed() {
+				c.releaseStream(call)
+			}
+			done(nil, resp.err)
+			return
+		}
+		c.releaseStream(call)
+		c.recordRequest(nil)
+
+		if v := resp.framer.header.version.version(); v != c.version {
+			resp.framer.releaseBody()
+			done(nil, NewErrProtocol("unexpected protocol version in response: got %d expected %d", v, c.version))
+			return
+		}
+		done(resp.framer, nil)
+	}
+
+	if c.streamObserver != nil {
+		call.streamObserverContext = c.streamObserver.StreamContext(ctx)
+	}
+
+	if err := c.addCall(call); err != nil {
+		done(nil, err)
+		return
+	}
+
+	if tracer != nil {
+		framer.trace()
+	}
+
+	if call.streamObserverContext != nil {
+		call.streamObserverContext.StreamStarted(ObservedStream{
+			Host: c.host,
+		})
+	}
+
+	if err := req.buildFrame(framer, stream); err != nil {
+		finished := call.finish()
+		c.mu.Lock()
+		if !c.closed {
+			delete(c.calls, call.streamID)
+		}
+		c.mu.Unlock()
+		c.releaseStream(call)
+		if finished {
+			done(nil, c.frameCorruption(err))
+		}
+		return
+	}
+
+	c.session.cfg.FrameDumper.dumpSent(c.host, framer.buf)
+	n, err := c.w.writeContext(ctx, framer.buf)
+	if err != nil {
+		// finish the call before closing the connection, so the error of
+		// the write is reported rather than the one of the connection
+		finished := call.finish()
+		if (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) && n == 0 {
+			c.mu.Lock()
+			if !c.closed {
+				delete(c.calls, call.streamID)
+			}
+			c.mu.Unlock()
+			c.releaseStream(call)
+		} else {
+			c.closeWithError(err)
+		}
+		if finished {
+			done(nil, categorizeConnError(err))
+		}
+		return
+	}
+
+	// when the call times out or ctx is done, the stream is kept until the
+	// response arrives, same as in execStream
+	mu.Lock()
+	defer mu.Unlock()
+	if stopped {
+		return
+	}
+	if timeout := c.requestTimeout(ctx); timeout > 0 {
+		timer = time.AfterFunc(timeout, func() {
+			if call.finish() {
+				stop()
+				c.handleTimeout()
+				c.recordRequest(ErrTimeoutNoResponse)
+				done(nil, ErrTimeoutNoResponse)
+			}
+		})
+	}
+	stopCtx = afterFunc(ctx, func() {
+		if call.finish() {
+			stop()
+			done(nil, ctx.Err())
+		}
+	})
+}
+
 // ObservedStream observes a single request/response stream.
 type ObservedStream struct {
 	// Host of the connection used to send the stream.
@@ -1206,23 +1648,74 @@ type preparedStatment struct {
 	id       []byte
 	request  preparedMetadata
 	response resultMetadata
+	// markers are the bind markers of the statement, scanned once when it
+	// is prepared.
+	markers []BindMarker
+}
+
+func (p *preparedStatment) queryInfo() *QueryInfo {
+	return &QueryInfo{
+		Id:          p.id,
+		Args:        p.request.columns,
+		Rval:        p.response.columns,
+		PKeyColumns: p.request.pkeyColumns,
+		Markers:     p.markers,
+	}
 }
 
 type inflightPrepare struct {
 	done chan struct{}
 	err  error
 
+	hostID    string
+	keyspace  string
+	statement string
+
 	preparedStatment *preparedStatment
+	// preparedAt is set once prepared, hits is guarded by the cache mutex.
+	preparedAt time.Time
+	hits       uint64
+}
+
+// wait waits for the statement to be prepared, or ctx to be done.
+func (f *inflightPrepare) wait(ctx context.Context) (*preparedStatment, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-f.done:
+		return f.preparedStatment, f.err
+	}
+}
+
+// stmtCacheKey returns the key of stmt prepared on the connection.
+func (c *Conn) stmtCacheKey(stmt string) string {
+	return c.session.stmtsLRU.keyFor(c.host.HostID(), c.currentKeyspace, stmt)
 }
 
 func (c *Conn) prepareStatement(ctx context.Context, stmt string, tracer Tracer) (*preparedStatment, error) {
-	stmtCacheKey := c.session.stmtsLRU.keyFor(c.host.HostID(), c.currentKeyspace, stmt)
-	flight, ok := c.session.stmtsLRU.execIfMissing(stmtCacheKey, func(lru *lru.Cache) *inflightPrepare {
-		flight := &inflightPrepare{
-			done: make(chan struct{}),
+	info, _, err := c.prepare(ctx, stmt, tracer)
+	return info, err
+}
+
+// prepare returns the prepared statement for stmt from the cache, or prepares
+// it, in which case prepared is true.
+func (c *Conn) prepare(ctx context.Context, stmt string, tracer Tracer) (info *preparedStatment, prepared bool, err error) {
+	flight, prepared := c.prepareFlight(stmt, tracer)
+	info, err = flight.wait(ctx)
+	return info, prepared, err
+}
+
+// prepareFlight returns the preparation of stmt from the cache, or starts
+// preparing it, in which case prepared is true.
+func (c *Conn) prepareFlight(stmt string, tracer Tracer) (flight *inflightPrepare, prepared bool) {
+	stmtCacheKey := c.stmtCacheKey(stmt)
+	flight, ok := c.session.stmtsLRU.execIfMissing(stmtCacheKey, func() *inflightPrepare {
+		return &inflightPrepare{
+			done:      make(chan struct{}),
+			hostID:    c.host.HostID(),
+			keyspace:  c.currentKeyspace,
+			statement: stmt,
 		}
-		lru.Add(stmtCacheKey, flight)
-		return flight
 	})
 
 	if !ok {
@@ -1261,6 +1754,7 @@ func (c *Conn) prepareStatement(ctx context.Context, stmt string, tracer Tracer)
 
 			switch x := frame.(type) {
 			case *resultPreparedFrame:
+				flight.preparedAt = time.Now()
 				flight.preparedStatment = &preparedStatment{
 					// defensively copy as we will recycle the underlying buffer after we
 					// return.
@@ -1269,6 +1763,7 @@ func (c *Conn) prepareStatement(ctx context.Context, stmt string, tracer Tracer)
 					// therefore we can just copy them directly.
 					request:  x.reqMeta,
 					response: x.respMeta,
+					markers:  bindMarkers(stmt, x.reqMeta.columns),
 				}
 			case error:
 				flight.err = x
@@ -1282,12 +1777,21 @@ func (c *Conn) prepareStatement(ctx context.Context, stmt string, tracer Tracer)
 		}()
 	}
 
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-flight.done:
-		return flight.preparedStatment, flight.err
+	return flight, !ok
+}
+
+// updateTablet stores the tablet information sent by the node when a query
+// was routed to a node which is not a replica of the tablet.
+func (c *Conn) updateTablet(keyspace, table string, payload []byte) {
+	if keyspace == "" || table == "" {
+		return
+	}
+	t, err := parseTabletPayload(payload)
+	if err != nil {
+		c.logger.Printf("gocql: unable to parse tablet information from %s: %v\n", c.addr, err)
+		return
 	}
+	c.session.tablets.add(keyspace, table, t)
 }
 
 func marshalQueryValue(typ TypeInfo, value interface{}, dst *queryValues) error {
@@ -1310,7 +1814,270 @@ func marshalQueryValue(typ TypeInfo, value interface{}, dst *queryValues) error
 	return nil
 }
 
+// ErrStalePreparedMetadata is returned when the number or the types of the
+// columns of the rows of a prepared statement no longer match the result
+// metadata cached when it was prepared, typically after its table was altered,
+// as protocol versions before v5 do not tell the driver that the metadata
+// changed. The statement is removed from the cache so that it is prepared
+// again on its next execution, and idempotent queries are retried once.
+//
+// errors.Is(err, ErrUnpreparedCategory) reports true for an
+// ErrStalePreparedMetadata.
+type ErrStalePreparedMetadata struct {
+	Statement string
+	// Expected is the number of columns of the cached metadata, Got the
+	// number of columns of the rows.
+	Expected, Got int
+}
+
+func (e *ErrStalePreparedMetadata) Error() string {
+	if e.Expected == e.Got {
+		return fmt.Sprintf("gocql: stale prepared metadata for %q: the types of the %d result columns changed", e.Statement, e.Got)
+	}
+	return fmt.Sprintf("gocql: stale prepared metadata for %q: expected %d result columns got %d", e.Statement, e.Expected, e.Got)
+}
+
+func (e *ErrStalePreparedMetadata) Is(target error) bool {
+	return target == ErrUnpreparedCategory
+}
+
+// columnsMatch reports whether the rows of a response whose metadata is got
+// can be decoded with the cached metadata of their prepared statement. The
+// types of the columns are compared if the response has metadata, or else
+// the lengths of the cells of the first row of rows are checked against the
+// columns of fixed length types. If partial is set, rows are the first
+// bytes of the rows of a streamed frame, only the cells they hold are
+// checked.
+func columnsMatch(cached, got *resultMetadata, numRows int, rows []byte, partial bool) bool {
+	if got.colCount != cached.colCount || len(cached.columns) != cached.colCount {
+		return false
+	}
+	if got.flags&flagNoMetaData == 0 {
+		for i, col := range got.columns {
+			expected := cached.columns[i].TypeInfo
+			if col.TypeInfo.Type() != expected.Type() || fmt.Sprint(col.TypeInfo) != fmt.Sprint(expected) {
+				return false
+			}
+		}
+		return true
+	}
+	if numRows == 0 {
+		return true
+	}
+	for _, col := range cached.columns {
+		if len(rows) < 4 {
+			return partial
+		}
+		n := int(int32(binary.BigEndian.Uint32(rows)))
+		rows = rows[4:]
+		if n <= 0 {
+			// null and empty values are valid for all the types
+			continue
+		}
+		if size := fixedTypeSize(col.TypeInfo); size > 0 && n != size {
+			return false
+		}
+		if len(rows) < n {
+			return partial
+		}
+		rows = rows[n:]
+	}
+	return true
+}
+
+// fixedTypeSize returns the length of the values of the type, 0 if it varies.
+func fixedTypeSize(info TypeInfo) int {
+	if _, ok := info.(NativeType); !ok {
+		return 0
+	}
+	switch info.Type() {
+	case TypeBoolean, TypeTinyInt:
+		return 1
+	case TypeSmallInt:
+		return 2
+	case TypeInt, TypeFloat, TypeDate:
+		return 4
+	case TypeBigInt, TypeCounter, TypeDouble, TypeTimestamp, TypeTime:
+		return 8
+	case TypeUUID, TypeTimeUUID:
+		return 16
+	}
+	return 0
+}
+
+// staleMetadataRetryKey marks the context of a query retried after its
+// prepared metadata was found stale.
+type staleMetadataRetryKey struct{}
+
+// streamCellsKey is the context key of the threshold of Query.StreamCells.
+type streamCellsKey struct{}
+
+// requestContext returns ctx with the values of the options of qry which
+// apply to its requests.
+func (qry *Query) requestContext(ctx context.Context) context.Context {
+	if qry.serverTimeout > 0 {
+		ctx = context.WithValue(ctx, requestTimeoutKey{}, qry.serverTimeout)
+	}
+	if qry.streamCells > 0 {
+		ctx = context.WithValue(ctx, streamCellsKey{}, qry.streamCells)
+	}
+	return ctx
+}
+
+// BindError is returned when a value of a query can not be bound to a bind
+// marker of its prepared statement.
+type BindError struct {
+	Statement string
+	// Index is the index of the bind marker, Column its column.
+	Index  int
+	Column ColumnInfo
+	Err    error
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("gocql: can not bind value %d to column %q of %s.%s (%v) in %q: %v",
+		e.Index, e.Column.Name, e.Column.Keyspace, e.Column.Table, e.Column.TypeInfo, e.Statement, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// errNullPartitionKey is the error of a BindError for a null or unset value
+// bound to a partition key column.
+var errNullPartitionKey = errors.New("partition key columns can not be null or unset")
+
+// bindValues marshals the values of the query for the bind markers of the
+// prepared statement. If validate is set, errors are reported as BindError,
+// and null or unset partition key values are rejected.
+func (qry *Query) bindValues(info *preparedStatment, validate bool) ([]queryValues, error) {
+	values := qry.values
+	if qry.binding != nil {
+		var err error
+		values, err = qry.binding(info.queryInfo())
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// a tuple bind marker takes a single value, such as a Tuple or a struct
+	if len(values) != len(info.request.columns) {
+		if validate {
+			names := make([]string, len(info.request.columns))
+			for i, col := range info.request.columns {
+				names[i] = col.Name
+			}
+			return nil, fmt.Errorf("gocql: expected %d values for columns %v of %q got %d",
+				len(info.request.columns), names, qry.stmt, len(values))
+		}
+		return nil, fmt.Errorf("gocql: expected %d values send got %d", len(info.request.columns), len(values))
+	}
+
+	queryValues := make([]queryValues, len(values))
+	for i := 0; i < len(values); i++ {
+		v := &queryValues[i]
+		value := values[i]
+		typ := info.request.columns[i].TypeInfo
+		if err := marshalQueryValue(typ, value, v); err != nil {
+			if validate {
+				return nil, &BindError{Statement: qry.stmt, Index: i, Column: info.request.columns[i], Err: err}
+			}
+			return nil, err
+		}
+	}
+
+	if validate {
+		for _, i := range info.request.pkeyColumns {
+			// named values may be in any order
+			if i < len(queryValues) && queryValues[i].name == "" &&
+				(queryValues[i].isUnset || queryValues[i].value == nil) {
+				return nil, &BindError{Statement: qry.stmt, Index: i, Column: info.request.columns[i], Err: errNullPartitionKey}
+			}
+		}
+	}
+	return queryValues, nil
+}
+
+// queryRequest is the request of a query to send on a connection.
+type queryRequest struct {
+	params queryParams
+	frame  frameBuilder
+	// info is the prepared statement the query is executed with, if any.
+	info *preparedStatment
+}
+
 func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
+	req := c.newQueryRequest(ctx, qry)
+	if !qry.skipPrepare && qry.shouldPrepare() {
+		// Prepare all DML queries. Other queries can not be prepared.
+		flight, prepared := c.prepareFlight(qry.stmt, qry.trace)
+		if err := c.bindPrepared(ctx, qry, req, flight, prepared); err != nil {
+			return &Iter{err: err}
+		}
+	}
+
+	ctx = qry.requestContext(ctx)
+	return c.sendQuery(ctx, qry, req)
+}
+
+// executeQueryAsync is like executeQuery, but it calls done with the
+// iterator instead of returning it. When the query can be sent right away,
+// done is called from the goroutine reading the responses of the connection
+// once the response arrives. Otherwise, when its statement is still being
+// prepared, the connection has no free stream or the response requires
+// further requests, the query is completed on a goroutine of its own. done
+// must not block.
+func (c *Conn) executeQueryAsync(ctx context.Context, qry *Query, done func(*Iter)) {
+	req := c.newQueryRequest(ctx, qry)
+	if !qry.skipPrepare && qry.shouldPrepare() {
+		flight, prepared := c.prepareFlight(qry.stmt, qry.trace)
+		select {
+		case <-flight.done:
+		default:
+			go func() {
+				if err := c.bindPrepared(ctx, qry, req, flight, prepared); err != nil {
+					done(&Iter{err: err})
+					return
+				}
+				ctx = qry.requestContext(ctx)
+				done(c.sendQuery(ctx, qry, req))
+			}()
+			return
+		}
+		if err := c.bindPrepared(ctx, qry, req, flight, prepared); err != nil {
+			done(&Iter{err: err})
+			return
+		}
+	}
+
+	ctx = qry.requestContext(ctx)
+	if err := ctx.Err(); err != nil {
+		done(&Iter{err: err})
+		return
+	}
+	stream, ok := c.streams.GetStream()
+	if !ok {
+		go func() {
+			done(c.sendQuery(ctx, qry, req))
+		}()
+		return
+	}
+	c.execStreamAsync(ctx, stream, req.frame, qry.trace, func(framer *framer, err error) {
+		iter, next := c.queryResult(ctx, qry, req, framer, err)
+		if next != nil {
+			go func() {
+				done(next())
+			}()
+			return
+		}
+		done(iter)
+	})
+}
+
+// newQueryRequest returns the request of qry, its frame is a query frame
+// unless the statement is prepared with bindPrepared.
+func (c *Conn) newQueryRequest(ctx context.Context, qry *Query) *queryRequest {
 	params := queryParams{
 		consistency: qry.cons,
 	}
@@ -1319,6 +2086,11 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 	params.serialConsistency = qry.serialCons
 	params.defaultTimestamp = qry.defaultTimestamp
 	params.defaultTimestampValue = qry.defaultTimestampValue
+	if params.defaultTimestamp && params.defaultTimestampValue == 0 {
+		params.defaultTimestampValue = c.session.cfg.timestampFrom(qry.timestampGenerator)
+		// the server assigns the timestamp
+		params.defaultTimestamp = params.defaultTimestampValue != 0
+	}
 
 	if len(qry.pageState) > 0 {
 		params.pagingState = qry.pageState
@@ -1330,85 +2102,129 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 		params.keyspace = c.currentKeyspace
 	}
 
-	var (
-		frame frameBuilder
-		info  *preparedStatment
-	)
-
-	if !qry.skipPrepare && qry.shouldPrepare() {
-		// Prepare all DML queries. Other queries can not be prepared.
-		var err error
-		info, err = c.prepareStatement(ctx, qry.stmt, qry.trace)
-		if err != nil {
-			return &Iter{err: err}
-		}
+	return &queryRequest{
+		params: params,
+		frame: &writeQueryFrame{
+			statement:     qry.stmt,
+			params:        params,
+			customPayload: c.requestPayload(ctx, qry.customPayload),
+		},
+	}
+}
 
-		values := qry.values
-		if qry.binding != nil {
-			values, err = qry.binding(&QueryInfo{
-				Id:          info.id,
-				Args:        info.request.columns,
-				Rval:        info.response.columns,
-				PKeyColumns: info.request.pkeyColumns,
-			})
+// bindPrepared waits for the statement of qry to be prepared by flight and
+// binds the values of qry to it, making req an execute request.
+func (c *Conn) bindPrepared(ctx context.Context, qry *Query, req *queryRequest, flight *inflightPrepare, prepared bool) error {
+	info, err := flight.wait(ctx)
+	if err != nil {
+		return err
+	}
+	if prepared && qry.prepareOnAllHosts {
+		go c.session.prepareOnAllHosts(c.host, c.currentKeyspace, qry.stmt)
+	}
 
-			if err != nil {
-				return &Iter{err: err}
-			}
-		}
+	params := req.params
+	params.values, err = qry.bindValues(info, qry.validateValues)
+	if err != nil {
+		return err
+	}
 
-		if len(values) != info.request.actualColCount {
-			return &Iter{err: fmt.Errorf("gocql: expected %d values send got %d", info.request.actualColCount, len(values))}
-		}
+	params.skipMeta = !(c.session.cfg.DisableSkipMetadata || qry.disableSkipMetadata)
 
-		params.values = make([]queryValues, len(values))
-		for i := 0; i < len(values); i++ {
-			v := &params.values[i]
-			value := values[i]
-			typ := info.request.columns[i].TypeInfo
-			if err := marshalQueryValue(typ, value, v); err != nil {
-				return &Iter{err: err}
-			}
-		}
+	req.params = params
+	req.info = info
+	req.frame = &writeExecuteFrame{
+		preparedID:    info.id,
+		params:        params,
+		customPayload: c.requestPayload(ctx, qry.customPayload),
+	}
 
-		params.skipMeta = !(c.session.cfg.DisableSkipMetadata || qry.disableSkipMetadata)
+	// Set "keyspace" and "table" property in the query if it is present in preparedMetadata
+	qry.routingInfo.mu.Lock()
+	qry.routingInfo.keyspace = info.request.keyspace
+	qry.routingInfo.table = info.request.table
+	qry.routingInfo.parsed = true
+	qry.routingInfo.prepared = info
+	qry.routingInfo.mu.Unlock()
+	return nil
+}
 
-		frame = &writeExecuteFrame{
-			preparedID:    info.id,
-			params:        params,
-			customPayload: qry.customPayload,
-		}
+// sendQuery sends the request of qry and waits for its response.
+func (c *Conn) sendQuery(ctx context.Context, qry *Query, req *queryRequest) (iter *Iter) {
+	stream, streamWait, err := c.acquireStream(ctx)
+	if err != nil {
+		return &Iter{err: err, streamWait: streamWait}
+	}
+	defer func() {
+		iter.streamWait = streamWait
+	}()
+	framer, err := c.execStream(ctx, stream, req.frame, qry.trace)
+	iter, next := c.queryResult(ctx, qry, req, framer, err)
+	if next != nil {
+		return next()
+	}
+	return iter
+}
 
-		// Set "keyspace" and "table" property in the query if it is present in preparedMetadata
-		qry.routingInfo.mu.Lock()
-		qry.routingInfo.keyspace = info.request.keyspace
-		qry.routingInfo.table = info.request.table
-		qry.routingInfo.mu.Unlock()
-	} else {
-		frame = &writeQueryFrame{
-			statement:     qry.stmt,
-			params:        params,
-			customPayload: qry.customPayload,
-		}
+// queryResult returns the iterator of the response to the request of qry,
+// read by framer, or the error of the request. If the response requires
+// further requests, such as to prepare the statement again or to wait for
+// schema agreement, it returns the function making them and returning the
+// iterator instead, which must not be called by the goroutine reading the
+// responses of the connection.
+func (c *Conn) queryResult(ctx context.Context, qry *Query, req *queryRequest, framer *framer, err error) (*Iter, func() *Iter) {
+	iter, next := c.parseQueryResult(ctx, qry, req, framer, err)
+	if framer != nil && framer.body != nil && (iter == nil || iter.framer != framer || iter.err != nil || iter.numRows == 0) {
+		// only the rows of a streamed frame are read by the iterator
+		framer.releaseBody()
 	}
+	return iter, next
+}
 
-	framer, err := c.exec(ctx, frame, qry.trace)
+// parseQueryResult is queryResult before the body of a streamed frame is
+// released.
+func (c *Conn) parseQueryResult(ctx context.Context, qry *Query, req *queryRequest, framer *framer, err error) (*Iter, func() *Iter) {
 	if err != nil {
-		return &Iter{err: err}
+		switch tooLarge := err.(type) {
+		case *ErrFrameTooLarge:
+			tooLarge.Statement = qry.stmt
+		case *ErrRequestTooLarge:
+			tooLarge.Statement = qry.stmt
+		}
+		return &Iter{err: err}, nil
 	}
 
 	resp, err := framer.parseFrame()
 	if err != nil {
-		return &Iter{err: err}
+		return &Iter{err: err}, nil
 	}
 
+	iter, next := c.queryResponse(ctx, qry, req, framer, resp)
 	if len(framer.traceID) > 0 && qry.trace != nil {
-		qry.trace.Trace(framer.traceID)
+		// the tracer might query the trace, the iterator is returned too
+		// for a streamed frame to be released when it has no rows
+		traced := next
+		return iter, func() *Iter {
+			qry.trace.Trace(framer.traceID)
+			if traced != nil {
+				return traced()
+			}
+			return iter
+		}
+	}
+	return iter, next
+}
+
+// queryResponse is queryResult for the parsed response resp.
+func (c *Conn) queryResponse(ctx context.Context, qry *Query, req *queryRequest, framer *framer, resp frame) (*Iter, func() *Iter) {
+	params, info := req.params, req.info
+	if payload, ok := framer.customPayload[tabletsRoutingV1Payload]; ok && info != nil {
+		c.updateTablet(info.request.keyspace, info.request.table, payload)
 	}
 
 	switch x := resp.(type) {
 	case *resultVoidFrame:
-		return &Iter{framer: framer}
+		return &Iter{framer: framer}, nil
 	case *resultRowsFrame:
 		iter := &Iter{
 			meta:    x.meta,
@@ -1416,14 +2232,33 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 			numRows: x.numRows,
 		}
 
-		if params.skipMeta {
-			if info != nil {
-				iter.meta = info.response
-				iter.meta.pagingState = copyBytes(x.meta.pagingState)
-			} else {
-				return &Iter{framer: framer, err: errors.New("gocql: did not receive metadata but prepared info is nil")}
+		if params.skipMeta && x.meta.flags&flagNoMetaData == flagNoMetaData {
+			if info == nil {
+				return &Iter{framer: framer, err: errors.New("gocql: did not receive metadata but prepared info is nil")}, nil
+			}
+			if !columnsMatch(&info.response, &x.meta, x.numRows, framer.buf, framer.body != nil) {
+				// the rows can not be decoded with the cached metadata
+				stmtCacheKey := c.stmtCacheKey(qry.stmt)
+				c.session.stmtsLRU.evictPreparedID(stmtCacheKey, info.id)
+				if qry.IsIdempotent() && ctx.Value(staleMetadataRetryKey{}) == nil {
+					return nil, func() *Iter {
+						return c.executeQuery(context.WithValue(ctx, staleMetadataRetryKey{}, true), qry)
+					}
+				}
+				return &Iter{framer: framer, err: &ErrStalePreparedMetadata{
+					Statement: qry.stmt,
+					Expected:  info.response.colCount,
+					Got:       x.meta.colCount,
+				}}, nil
 			}
+			iter.meta = info.response
+			iter.meta.pagingState = copyBytes(x.meta.pagingState)
 		} else {
+			if params.skipMeta && info != nil && !columnsMatch(&info.response, &x.meta, x.numRows, framer.buf, framer.body != nil) {
+				// the server sent the metadata as it changed, the next
+				// executions must not skip it
+				c.session.stmtsLRU.evictPreparedID(c.stmtCacheKey(qry.stmt), info.id)
+			}
 			iter.meta = x.meta
 		}
 
@@ -1443,30 +2278,35 @@ func (c *Conn) executeQuery(ctx context.Context, qry *Query) *Iter {
 			}
 		}
 
-		return iter
+		return iter, nil
 	case *resultKeyspaceFrame:
-		return &Iter{framer: framer}
+		return &Iter{framer: framer}, nil
 	case *schemaChangeKeyspace, *schemaChangeTable, *schemaChangeFunction, *schemaChangeAggregate, *schemaChangeType:
-		iter := &Iter{framer: framer}
-		if err := c.awaitSchemaAgreement(ctx); err != nil {
-			// TODO: should have this behind a flag
-			c.logger.Println(err)
-		}
-		// dont return an error from this, might be a good idea to give a warning
-		// though. The impact of this returning an error would be that the cluster
-		// is not consistent with regards to its schema.
-		return iter
+		return nil, func() *Iter {
+			iter := &Iter{framer: framer}
+			if err := c.awaitSchemaAgreement(ctx); err != nil {
+				// TODO: should have this behind a flag
+				c.logger.Println(err)
+			}
+			// dont return an error from this, might be a good idea to give a warning
+			// though. The impact of this returning an error would be that the cluster
+			// is not consistent with regards to its schema.
+			return iter
+		}
 	case *RequestErrUnprepared:
-		stmtCacheKey := c.session.stmtsLRU.keyFor(c.host.HostID(), c.currentKeyspace, qry.stmt)
+		c.session.errorStats.record(c.host, x)
+		stmtCacheKey := c.stmtCacheKey(qry.stmt)
 		c.session.stmtsLRU.evictPreparedID(stmtCacheKey, x.StatementId)
-		return c.executeQuery(ctx, qry)
+		return nil, func() *Iter {
+			return c.executeQuery(ctx, qry)
+		}
 	case error:
-		return &Iter{err: x, framer: framer}
+		return &Iter{err: x, framer: framer}, nil
 	default:
 		return &Iter{
 			err:    NewErrProtocol("Unknown type in response to execute query (%T): %s", x, x),
 			framer: framer,
-		}
+		}, nil
 	}
 }
 
@@ -1518,7 +2358,7 @@ func (c *Conn) UseKeyspace(keyspace string) error {
 	return nil
 }
 
-func (c *Conn) executeBatch(ctx context.Context, batch *Batch) *Iter {
+func (c *Conn) executeBatch(ctx context.Context, batch *Batch) (iter *Iter) {
 	if c.version == protoVersion1 {
 		return &Iter{err: ErrUnsupported}
 	}
@@ -1531,7 +2371,12 @@ func (c *Conn) executeBatch(ctx context.Context, batch *Batch) *Iter {
 		serialConsistency:     batch.serialCons,
 		defaultTimestamp:      batch.defaultTimestamp,
 		defaultTimestampValue: batch.defaultTimestampValue,
-		customPayload:         batch.CustomPayload,
+		customPayload:         c.requestPayload(batch.Context(), batch.CustomPayload),
+	}
+	if req.defaultTimestamp && req.defaultTimestampValue == 0 {
+		req.defaultTimestampValue = c.session.cfg.timestampFrom(batch.timestampGenerator)
+		// the server assigns the timestamp
+		req.defaultTimestamp = req.defaultTimestampValue != 0
 	}
 
 	stmts := make(map[string]string, len(batch.Entries))
@@ -1550,27 +2395,22 @@ func (c *Conn) executeBatch(ctx context.Context, batch *Batch) *Iter {
 			if entry.binding == nil {
 				values = entry.Args
 			} else {
-				values, err = entry.binding(&QueryInfo{
-					Id:          info.id,
-					Args:        info.request.columns,
-					Rval:        info.response.columns,
-					PKeyColumns: info.request.pkeyColumns,
-				})
+				values, err = entry.binding(info.queryInfo())
 				if err != nil {
 					return &Iter{err: err}
 				}
 			}
 
-			if len(values) != info.request.actualColCount {
-				return &Iter{err: fmt.Errorf("gocql: batch statement %d expected %d values send got %d", i, info.request.actualColCount, len(values))}
+			if len(values) != len(info.request.columns) {
+				return &Iter{err: fmt.Errorf("gocql: batch statement %d expected %d values send got %d", i, len(info.request.columns), len(values))}
 			}
 
 			b.preparedID = info.id
 			stmts[string(info.id)] = entry.Stmt
 
-			b.values = make([]queryValues, info.request.actualColCount)
+			b.values = make([]queryValues, len(values))
 
-			for j := 0; j < info.request.actualColCount; j++ {
+			for j := range values {
 				v := &b.values[j]
 				value := values[j]
 				typ := info.request.columns[j].TypeInfo
@@ -1583,7 +2423,14 @@ func (c *Conn) executeBatch(ctx context.Context, batch *Batch) *Iter {
 		}
 	}
 
-	framer, err := c.exec(batch.Context(), req, batch.trace)
+	stream, streamWait, err := c.acquireStream(batch.Context())
+	if err != nil {
+		return &Iter{err: err, streamWait: streamWait}
+	}
+	defer func() {
+		iter.streamWait = streamWait
+	}()
+	framer, err := c.execStream(batch.Context(), stream, req, batch.trace)
 	if err != nil {
 		return &Iter{err: err}
 	}
@@ -1601,9 +2448,10 @@ func (c *Conn) executeBatch(ctx context.Context, batch *Batch) *Iter {
 	case *resultVoidFrame:
 		return &Iter{}
 	case *RequestErrUnprepared:
+		c.session.errorStats.record(c.host, x)
 		stmt, found := stmts[string(x.StatementId)]
 		if found {
-			key := c.session.stmtsLRU.keyFor(c.host.HostID(), c.currentKeyspace, stmt)
+			key := c.stmtCacheKey(stmt)
 			c.session.stmtsLRU.evictPreparedID(key, x.StatementId)
 		}
 		return c.executeBatch(ctx, batch)
@@ -1642,12 +2490,13 @@ func (c *Conn) querySystemPeers(ctx context.Context, version cassVersion) *Iter
 	c.mu.Unlock()
 
 	if version.AtLeast(4, 0, 0) && isSchemaV2 {
-		// Try "system.peers_v2" and fallback to "system.peers" if it's not found
+		// Try "system.peers_v2", which has the native port of each peer, and
+		// fallback to "system.peers" if it's not found
 		iter := c.query(ctx, peerV2Schemas)
 
 		err := iter.checkErrAndNotFound()
 		if err != nil {
-			if errFrame, ok := err.(errorFrame); ok && errFrame.code == ErrCodeInvalid { // system.peers_v2 not found, try system.peers
+			if isPeersV2NotFound(err) {
 				c.mu.Lock()
 				c.isSchemaV2 = false
 				c.mu.Unlock()
@@ -1662,6 +2511,13 @@ func (c *Conn) querySystemPeers(ctx context.Context, version cassVersion) *Iter
 	}
 }
 
+// isPeersV2NotFound reports whether err is the error of a node with no
+// system.peers_v2 table, such as a node upgraded from Cassandra 3.
+func isPeersV2NotFound(err error) bool {
+	var frame errorFrame
+	return errors.As(err, &frame) && frame.code == ErrCodeInvalid
+}
+
 func (c *Conn) querySystemLocal(ctx context.Context) *Iter {
 	return c.query(ctx, "SELECT * FROM system.local WHERE key='local'")
 }
@@ -1685,7 +2541,7 @@ func (c *Conn) awaitSchemaAgreement(ctx context.Context) (err error) {
 		}
 
 		for _, row := range rows {
-			host, err := c.session.hostInfoFromMap(row, &HostInfo{connectAddress: c.host.ConnectAddress(), port: c.session.cfg.Port})
+			host, err := c.session.hostInfoFromMap(ctx, row, &HostInfo{connectAddress: c.host.ConnectAddress(), port: c.session.cfg.Port})
 			if err != nil {
 				goto cont
 			}
@@ -1738,8 +2594,9 @@ func (c *Conn) awaitSchemaAgreement(ctx context.Context) (err error) {
 
 var (
 	ErrQueryArgLength    = errors.New("gocql: query argument length mismatch")
-	ErrTimeoutNoResponse = errors.New("gocql: no response received from cassandra within timeout period")
-	ErrTooManyTimeouts   = errors.New("gocql: too many query timeouts on the connection")
-	ErrConnectionClosed  = errors.New("gocql: connection closed waiting for response")
-	ErrNoStreams         = errors.New("gocql: no streams available on connection")
+	ErrTimeoutNoResponse = newCategorizedError(ErrTimeoutCategory, "gocql: no response received from cassandra within timeout period")
+	ErrTooManyTimeouts   = newCategorizedError(ErrConnectionCategory, "gocql: too many query timeouts on the connection")
+	ErrConnEvicted       = newCategorizedError(ErrConnectionCategory, "gocql: connection evicted after too many failed requests")
+	ErrConnectionClosed  = newCategorizedError(ErrConnectionCategory, "gocql: connection closed waiting for response")
+	ErrNoStreams         = newCategorizedError(ErrConnectionCategory, "gocql: no streams available on connection")
 )
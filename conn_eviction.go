@@ -0,0 +1,137 @@
+package gocql
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ConnEvictionPolicy evicts the connections which fail while their host is up,
+// for example because of a faulty network interface or a proxy resetting
+// them. A request fails when it gets no response: it timed out or its
+// connection failed. Evicted connections are closed with ErrConnEvicted and
+// replaced by new connections to their host.
+type ConnEvictionPolicy struct {
+	// MaxConsecutiveErrors evicts a connection once that many of its requests
+	// failed in a row, 0 disabling the limit.
+	MaxConsecutiveErrors int
+	// MaxErrorRate evicts a connection once the ratio of its requests which
+	// failed within Window exceeds it, 0 disabling the limit.
+	MaxErrorRate float64
+	// MinRequests is the number of requests within Window below which
+	// MaxErrorRate does not apply.
+	MinRequests int
+	// Window is the period over which the error rate is computed, 1 minute
+	// if zero.
+	Window time.Duration
+}
+
+// ObservedConnEviction describes the eviction of a connection.
+type ObservedConnEviction struct {
+	Host *HostInfo
+	// LocalAddr is the local address of the evicted connection.
+	LocalAddr string
+	// ConsecutiveErrors is the number of requests which failed in a row.
+	ConsecutiveErrors int
+	// Requests and Errors are the numbers of requests and failed requests
+	// within the window of the policy.
+	Requests int
+	Errors   int
+	// Err is the error of the last failed request.
+	Err error
+}
+
+// ConnEvictionObserver is the interface implemented by connection eviction
+// observers. ObserveConnEviction is called from the goroutine of the request
+// which caused the eviction and must not block.
+type ConnEvictionObserver interface {
+	ObserveConnEviction(ObservedConnEviction)
+}
+
+// connHealth tracks the failures of the requests of a connection.
+type connHealth struct {
+	mu          sync.Mutex
+	consecutive int
+	windowStart time.Time
+	requests    int
+	errors      int
+}
+
+// record records the outcome of a request at now, and returns whether the
+// connection must be evicted according to policy.
+func (h *connHealth) record(policy *ConnEvictionPolicy, now time.Time, failed bool) (ObservedConnEviction, bool) {
+	window := policy.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now.Sub(h.windowStart) >= window {
+		h.windowStart = now
+		h.requests, h.errors = 0, 0
+	}
+	h.requests++
+	if failed {
+		h.errors++
+		h.consecutive++
+	} else {
+		h.consecutive = 0
+	}
+
+	evicted := ObservedConnEviction{
+		ConsecutiveErrors: h.consecutive,
+		Requests:          h.requests,
+		Errors:            h.errors,
+	}
+	if !failed {
+		return evicted, false
+	}
+	if policy.MaxConsecutiveErrors > 0 && h.consecutive >= policy.MaxConsecutiveErrors {
+		return evicted, true
+	}
+	if policy.MaxErrorRate > 0 && h.requests >= policy.MinRequests &&
+		float64(h.errors)/float64(h.requests) > policy.MaxErrorRate {
+		return evicted, true
+	}
+	return evicted, false
+}
+
+// connRequestFailed reports whether err means the connection did not get a
+// response to a request. Canceled requests and responses which were discarded
+// because of their size are not failures of the connection.
+func connRequestFailed(err error) bool {
+	if err == nil || errors.Is(err, ErrFrameTooBig) {
+		return false
+	}
+	return err == ErrTimeoutNoResponse || errors.Is(err, ErrConnectionCategory)
+}
+
+// recordRequest records the outcome of a request of the connection, and
+// evicts the connection if configured so.
+func (c *Conn) recordRequest(err error) {
+	policy := c.session.cfg.ConnEviction
+	if policy == nil {
+		return
+	}
+	failed := connRequestFailed(err)
+	if !failed && err != nil {
+		// canceled by the caller, which tells nothing of the connection
+		return
+	}
+
+	evicted, evict := c.health.record(policy, c.session.cfg.clock().Now(), failed)
+	if !evict || c.Closed() {
+		return
+	}
+
+	if observer := c.session.cfg.ConnEvictionObserver; observer != nil {
+		evicted.Host = c.host
+		if c.conn != nil {
+			evicted.LocalAddr = c.conn.LocalAddr().String()
+		}
+		evicted.Err = err
+		observer.ObserveConnEviction(evicted)
+	}
+	c.closeWithError(ErrConnEvicted)
+}
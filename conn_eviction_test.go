@@ -0,0 +1,120 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnHealth(t *testing.T) {
+	start := time.Unix(1000, 0)
+	type request struct {
+		at     time.Duration
+		failed bool
+		evict  bool
+	}
+	tests := []struct {
+		name     string
+		policy   ConnEvictionPolicy
+		requests []request
+	}{
+		{
+			name:   "consecutive errors",
+			policy: ConnEvictionPolicy{MaxConsecutiveErrors: 2},
+			requests: []request{
+				{failed: true}, {}, {failed: true}, {failed: true, evict: true},
+			},
+		},
+		{
+			name:   "error rate",
+			policy: ConnEvictionPolicy{MaxErrorRate: 0.5, MinRequests: 4, Window: time.Second},
+			requests: []request{
+				{failed: true}, {failed: true}, {}, {}, {failed: true, evict: true},
+			},
+		},
+		{
+			name:   "error rate window",
+			policy: ConnEvictionPolicy{MaxErrorRate: 0.5, MinRequests: 2, Window: time.Second},
+			requests: []request{
+				{}, {at: 500 * time.Millisecond, failed: true},
+				{at: time.Second, failed: true}, {at: 1100 * time.Millisecond}, {at: 1200 * time.Millisecond, failed: true, evict: true},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var h connHealth
+			for i, req := range test.requests {
+				if _, evict := h.record(&test.policy, start.Add(req.at), req.failed); evict != req.evict {
+					t.Fatalf("request %d: expected eviction %v, got %v", i, req.evict, evict)
+				}
+			}
+		})
+	}
+}
+
+type recordingConnEvictionObserver struct {
+	mu        sync.Mutex
+	evictions []ObservedConnEviction
+}
+
+func (o *recordingConnEvictionObserver) ObserveConnEviction(e ObservedConnEviction) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.evictions = append(o.evictions, e)
+}
+
+func (o *recordingConnEvictionObserver) observed() []ObservedConnEviction {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]ObservedConnEviction(nil), o.evictions...)
+}
+
+func TestConnEviction(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	observer := &recordingConnEvictionObserver{}
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 1
+	cluster.Timeout = 50 * time.Millisecond
+	cluster.ConnEviction = &ConnEvictionPolicy{MaxConsecutiveErrors: 2}
+	cluster.ConnEvictionObserver = observer
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := db.Query("timeout").Exec(); !errors.Is(err, ErrTimeoutNoResponse) {
+			t.Fatalf("expected a timeout, got %v", err)
+		}
+	}
+
+	evictions := observer.observed()
+	if len(evictions) != 1 {
+		t.Fatalf("expected 1 eviction, got %v", evictions)
+	}
+	if e := evictions[0]; e.Host == nil || e.LocalAddr == "" || e.ConsecutiveErrors != 2 || e.Err != ErrTimeoutNoResponse {
+		t.Errorf("unexpected eviction %+v", e)
+	}
+
+	// the evicted connection is replaced
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := db.Query("void").Exec()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no connection after the eviction: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
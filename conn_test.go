@@ -19,9 +19,11 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"regexp"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -90,6 +92,94 @@ func TestSimple(t *testing.T) {
 	}
 }
 
+func TestSocketOptions(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	var controlCalls int32
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.SocketReadBufferSize = 1 << 16
+	cluster.SocketWriteBufferSize = 1 << 16
+	cluster.DisableTCPNoDelay = true
+	cluster.SocketControl = func(network, address string, c syscall.RawConn) error {
+		atomic.AddInt32(&controlCalls, 1)
+		return nil
+	}
+	if keepaliveParamsSupported() {
+		cluster.SocketKeepaliveInterval = 5 * time.Second
+		cluster.SocketKeepaliveCount = 3
+	}
+
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("0x%x: NewCluster: %v", defaultProto, err)
+	}
+	defer db.Close()
+
+	if err := db.Query("void").Exec(); err != nil {
+		t.Fatalf("0x%x: %v", defaultProto, err)
+	}
+	if atomic.LoadInt32(&controlCalls) == 0 {
+		t.Fatal("expected SocketControl to be called")
+	}
+}
+
+func TestMaxResponseFrameSize(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.MaxResponseFrameSize = 1
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("0x%x: NewCluster: %v", defaultProto, err)
+	}
+	defer db.Close()
+
+	// the connection must remain usable after a response was discarded
+	for i := 0; i < 2; i++ {
+		err := db.Query("void").Exec()
+		tooLarge, ok := err.(*ErrFrameTooLarge)
+		if !ok {
+			t.Fatalf("expected *ErrFrameTooLarge got %v", err)
+		}
+		if tooLarge.Statement != "void" {
+			t.Fatalf("expected statement %q got %q", "void", tooLarge.Statement)
+		}
+	}
+}
+
+func TestMaxRequestFrameSize(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.MaxRequestFrameSize = 64
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("0x%x: NewCluster: %v", defaultProto, err)
+	}
+	defer db.Close()
+
+	stmt := "void " + strings.Repeat("x", 64)
+	err = db.Query(stmt).Exec()
+	tooLarge, ok := err.(*ErrRequestTooLarge)
+	if !ok {
+		t.Fatalf("expected *ErrRequestTooLarge got %v", err)
+	}
+	if tooLarge.Statement != stmt || tooLarge.Limit != 64 || tooLarge.Size <= 64 {
+		t.Fatalf("unexpected error %+v", tooLarge)
+	}
+	if !errors.Is(err, ErrFrameTooBig) {
+		t.Fatalf("expected ErrFrameTooBig, got %v", err)
+	}
+
+	// the connection remains usable
+	if err := db.Query("void").Exec(); err != nil {
+		t.Fatalf("0x%x: %v", defaultProto, err)
+	}
+}
+
 func TestSSLSimple(t *testing.T) {
 	srv := NewSSLTestServer(t, defaultProto, context.Background())
 	defer srv.Stop()
@@ -118,6 +208,56 @@ func TestSSLSimpleNoClientCert(t *testing.T) {
 	}
 }
 
+type tlsHandshakeRecorder struct {
+	mu         sync.Mutex
+	handshakes []ObservedTLSHandshake
+	keyLog     bytes.Buffer
+}
+
+func (r *tlsHandshakeRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.keyLog.Write(p)
+}
+
+func (r *tlsHandshakeRecorder) ObserveTLSHandshake(obs ObservedTLSHandshake) {
+	r.mu.Lock()
+	r.handshakes = append(r.handshakes, obs)
+	r.mu.Unlock()
+}
+
+func TestSSLHandshakeDiagnostics(t *testing.T) {
+	srv := NewSSLTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	recorder := &tlsHandshakeRecorder{}
+	cluster := createTestSslCluster(srv.Address, defaultProto, true)
+	cluster.NumConns = 1
+	cluster.SslOpts.KeyLogWriter = recorder
+	cluster.TLSHandshakeObserver = recorder
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.handshakes) == 0 {
+		t.Fatal("expected a TLS handshake")
+	}
+	obs := recorder.handshakes[0]
+	if obs.Err != nil || obs.Host == nil || obs.Version == 0 || obs.CipherSuite == 0 || !obs.End.After(obs.Start) {
+		t.Errorf("unexpected handshake %+v", obs)
+	}
+	if len(obs.PeerCertificates) == 0 || obs.PeerCertificates[0].Subject == "" {
+		t.Errorf("expected the certificate of the server, got %+v", obs.PeerCertificates)
+	}
+	if recorder.keyLog.Len() == 0 {
+		t.Error("expected the TLS secrets to be logged")
+	}
+}
+
 func createTestSslCluster(addr string, proto protoVersion, useClientCert bool) *ClusterConfig {
 	cluster := testCluster(proto, addr)
 	sslOpts := &SslOptions{
@@ -381,6 +521,294 @@ func TestQueryRetry(t *testing.T) {
 	}
 }
 
+func TestQuerySetHost(t *testing.T) {
+	srv1 := NewTestServer(t, defaultProto, context.Background())
+	defer srv1.Stop()
+	srv2 := NewTestServer(t, defaultProto, context.Background())
+	defer srv2.Stop()
+
+	db, err := newTestSession(defaultProto, srv1.Address, srv2.Address)
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	var pinned *HostInfo
+	for _, host := range db.ring.allHosts() {
+		if host.ConnectAddressAndPort() == srv2.Address {
+			pinned = host
+		}
+	}
+	if pinned == nil {
+		t.Fatalf("host %s not found", srv2.Address)
+	}
+
+	for i := 0; i < 5; i++ {
+		iter := db.Query("void").SetHost(pinned).Iter()
+		if err := iter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if iter.Host() != pinned {
+			t.Fatalf("expected the query to be executed on %s, got %s", pinned, iter.Host())
+		}
+	}
+
+	// pinned queries are not retried on other hosts
+	rt := &SimpleRetryPolicy{NumRetries: 3}
+	if err := db.Query("kill").SetHostID(pinned.HostID()).RetryPolicy(rt).Exec(); err == nil {
+		t.Fatal("expected error")
+	}
+	if requests := atomic.LoadInt64(&srv1.nKillReq); requests != 0 {
+		t.Errorf("expected no request on %s, got %d", srv1.Address, requests)
+	}
+	if requests := atomic.LoadInt64(&srv2.nKillReq); requests != 1 {
+		t.Errorf("expected 1 request on %s, got %d", srv2.Address, requests)
+	}
+
+	if err := db.Query("void").SetHostID("unknown").Exec(); err != ErrHostNotFound {
+		t.Errorf("expected %v, got %v", ErrHostNotFound, err)
+	}
+}
+
+// alwaysRetryPolicy retries queries on the same host forever.
+type alwaysRetryPolicy struct{}
+
+func (alwaysRetryPolicy) Attempt(RetryableQuery) bool  { return true }
+func (alwaysRetryPolicy) GetRetryType(error) RetryType { return Retry }
+
+func TestQueryMaxRetries(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	db, err := newTestSession(defaultProto, srv.Address)
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	qry := db.Query("kill").RetryPolicy(alwaysRetryPolicy{}).MaxRetries(2)
+	iter := qry.Iter()
+	if err := iter.Close(); err == nil {
+		t.Fatal("expected error")
+	}
+	if requests := atomic.LoadInt64(&srv.nKillReq); requests != 3 {
+		t.Errorf("expected the query to be sent 3 times, got %d", requests)
+	}
+	if iter.Attempts() != 3 || qry.Attempts() != 3 {
+		t.Errorf("expected 3 attempts, got %d for the iterator and %d for the query", iter.Attempts(), qry.Attempts())
+	}
+
+	iter = db.Query("void").RetryPolicy(alwaysRetryPolicy{}).MaxRetries(0).Iter()
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if iter.Attempts() != 1 {
+		t.Errorf("expected 1 attempt, got %d", iter.Attempts())
+	}
+}
+
+func TestFrameCorruption(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	err = db.Query("corrupt").RetryPolicy(nil).Exec()
+	var corrupted *FrameCorruptionError
+	if !errors.As(err, &corrupted) {
+		t.Fatalf("expected a *FrameCorruptionError, got %v", err)
+	}
+	if corrupted.Direction != FrameInbound || corrupted.Host == nil || corrupted.RemoteAddr != srv.Address || corrupted.LocalAddr == "" {
+		t.Errorf("unexpected connection of %v", corrupted)
+	}
+	if !errors.Is(err, ErrConnectionCategory) {
+		t.Errorf("expected a connection error, got %v", err)
+	}
+	if stats := db.ErrorStats(); stats.Corrupted != 1 || stats.Connection != 0 {
+		t.Errorf("expected 1 corrupted frame, got %+v", stats)
+	}
+}
+
+type streamWaitObserver struct {
+	mu      sync.Mutex
+	waited  int
+	maxWait time.Duration
+}
+
+func (o *streamWaitObserver) ObserveQuery(ctx context.Context, q ObservedQuery) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if q.StreamWait > 0 {
+		o.waited++
+	}
+	if q.StreamWait > o.maxWait {
+		o.maxWait = q.StreamWait
+	}
+}
+
+func TestStreamWait(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	observer := &streamWaitObserver{}
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 1
+	cluster.QueryObserver = observer
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	// more concurrent queries than the streams of the connection
+	numQueries := 2 * streams.New(int(defaultProto)).NumStreams
+	errs := make(chan error, numQueries)
+	for i := 0; i < numQueries; i++ {
+		go func() {
+			errs <- db.Query("slow").Exec()
+		}()
+	}
+	for i := 0; i < numQueries; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+	}
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	if observer.waited == 0 || observer.maxWait < 10*time.Millisecond {
+		t.Errorf("expected queries waiting for streams, got %d waiting up to %v", observer.waited, observer.maxWait)
+	}
+}
+
+func TestQueryAttemptErrors(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	qry := db.Query("kill").RetryPolicy(&testRetryPolicy{NumRetries: 2}).Name("kill_node")
+	if err := qry.Exec(); err == nil {
+		t.Fatal("expected error")
+	}
+	attempts := qry.AttemptErrors()
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 failed attempts, got %d", len(attempts))
+	}
+	for i, attempt := range attempts {
+		if attempt.Attempt != i || attempt.Host == nil || attempt.Host.ConnectAddressAndPort() != srv.Address {
+			t.Errorf("attempt %d: unexpected attempt %d on %v", i, attempt.Attempt, attempt.Host)
+		}
+		if !errors.Is(&attempt, ErrOverloadedCategory) {
+			t.Errorf("attempt %d: expected an overloaded error, got %v", i, attempt.Err)
+		}
+		if attempt.Start.IsZero() || attempt.End.Before(attempt.Start) {
+			t.Errorf("attempt %d: unexpected timing %v-%v", i, attempt.Start, attempt.End)
+		}
+		if attempt.Name != "kill_node" || !strings.Contains(attempt.Error(), "of kill_node on") {
+			t.Errorf("attempt %d: expected the name of the query, got %v", i, attempt.Error())
+		}
+	}
+
+	// the errors of the previous executions of a reused query are forgotten
+	if err := qry.Exec(); err == nil {
+		t.Fatal("expected error")
+	}
+	// the retry policy counts the attempts of all the executions
+	if attempts := qry.AttemptErrors(); len(attempts) != 1 || attempts[0].Attempt != 3 {
+		t.Fatalf("expected the failed attempt of the last execution, got %v", attempts)
+	}
+
+	qry = db.Query("void")
+	if err := qry.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if attempts := qry.AttemptErrors(); len(attempts) != 0 {
+		t.Errorf("expected no failed attempts, got %v", attempts)
+	}
+}
+
+type recordingConsistencyObserver struct {
+	mu       sync.Mutex
+	observed []ObservedConsistency
+}
+
+func (o *recordingConsistencyObserver) ObserveConsistency(ctx context.Context, c ObservedConsistency) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observed = append(o.observed, c)
+}
+
+func TestConsistencyObserver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := NewTestServer(t, defaultProto, ctx)
+	defer srv.Stop()
+
+	observer := &recordingConsistencyObserver{}
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.ConsistencyObserver = observer
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	rt := &DowngradingConsistencyRetryPolicy{ConsistencyLevelsToTry: []Consistency{Two, One}}
+	if err := db.Query("unavailable").Consistency(Quorum).RetryPolicy(rt).Exec(); err == nil {
+		t.Fatal("expected error")
+	}
+	expected := []ObservedConsistency{
+		{Type: ConsistencyDowngraded, Requested: Quorum, Actual: Two, Attempt: 0},
+		{Type: ConsistencyDowngraded, Requested: Two, Actual: One, Attempt: 1},
+	}
+	checkObserved := func() {
+		t.Helper()
+		observer.mu.Lock()
+		defer observer.mu.Unlock()
+		if len(observer.observed) != len(expected) {
+			t.Fatalf("expected %d observations, got %+v", len(expected), observer.observed)
+		}
+		for i, o := range observer.observed {
+			want := expected[i]
+			if o.Type != want.Type || o.Requested != want.Requested || o.Actual != want.Actual || o.Attempt != want.Attempt {
+				t.Errorf("observation %d: expected %v %v->%v attempt %d, got %v %v->%v attempt %d",
+					i, want.Type, want.Requested, want.Actual, want.Attempt, o.Type, o.Requested, o.Actual, o.Attempt)
+			}
+			if o.Host == nil || o.Err == nil {
+				t.Errorf("observation %d: expected the host and error of the attempt, got %+v", i, o)
+			}
+		}
+		observer.observed = nil
+	}
+	checkObserved()
+
+	// the Paxos phase of lightweight transactions is compared with the
+	// serial consistency
+	if err := db.Query("unavailable serial").Consistency(Quorum).Exec(); err == nil {
+		t.Fatal("expected error")
+	}
+	expected = nil
+	checkObserved()
+
+	if err := db.Query("unavailable serial").Consistency(Quorum).SerialConsistency(LocalSerial).Exec(); err == nil {
+		t.Fatal("expected error")
+	}
+	expected = []ObservedConsistency{
+		{Type: ConsistencyMismatch, Requested: Consistency(LocalSerial), Actual: Consistency(Serial), Attempt: 0},
+	}
+	checkObserved()
+}
+
 func TestQueryMultinodeWithMetrics(t *testing.T) {
 	log := &testLogger{}
 	defer func() {
@@ -489,7 +917,8 @@ func TestSpeculativeExecution(t *testing.T) {
 	sp := &SimpleSpeculativeExecution{NumAttempts: 1, TimeoutDelay: 200 * time.Millisecond}
 
 	// Build the query
-	qry := db.Query("speculative").RetryPolicy(rt).SetSpeculativeExecutionPolicy(sp).Idempotent(true)
+	observer := &attemptsObserver{}
+	qry := db.Query("speculative").RetryPolicy(rt).SetSpeculativeExecutionPolicy(sp).Idempotent(true).Observer(observer)
 
 	// Execute the query and close, check that it doesn't error out
 	if err := qry.Exec(); err != nil {
@@ -514,6 +943,38 @@ func TestSpeculativeExecution(t *testing.T) {
 	if requests1+requests2+requests3 > 6 {
 		t.Errorf("error: expected to see 6 attempts, got %v\n", requests1+requests2+requests3)
 	}
+
+	// the successful attempt reports the attempts of both executions
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	attempts := observer.succeeded.Attempts
+	if len(attempts) < 4 || attempts[len(attempts)-1].Err != nil || attempts[len(attempts)-1].Host != observer.succeeded.Host {
+		t.Fatalf("unexpected attempts %+v", attempts)
+	}
+	var speculative, main int
+	for _, attempt := range attempts {
+		if attempt.Speculative {
+			speculative++
+		} else {
+			main++
+		}
+	}
+	if speculative == 0 || main == 0 {
+		t.Errorf("expected attempts of the main and speculative executions, got %d and %d", main, speculative)
+	}
+}
+
+type attemptsObserver struct {
+	mu        sync.Mutex
+	succeeded ObservedQuery
+}
+
+func (o *attemptsObserver) ObserveQuery(ctx context.Context, q ObservedQuery) {
+	if q.Err == nil {
+		o.mu.Lock()
+		o.succeeded = q
+		o.mu.Unlock()
+	}
 }
 
 // This tests that the policy connection pool handles SSL correctly
@@ -542,6 +1003,57 @@ func TestPolicyConnPoolSSL(t *testing.T) {
 	}
 }
 
+func TestQueryStreamCells(t *testing.T) {
+	const size = 1 << 20
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 1
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	query := fmt.Sprintf("blobs 3 %d", size)
+	for attempt := 0; attempt < 2; attempt++ {
+		// the rest of the frame is discarded on close
+		iter := db.Query(query).StreamCells(64 * 1024).Iter()
+		var row LazyRow
+		if !iter.NextRow(&row) {
+			t.Fatal(iter.Close())
+		}
+		if err := iter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// the connection reads the next frames
+		if err := db.Query("void").Exec(); err != nil {
+			t.Fatal(err)
+		}
+
+		iter = db.Query(query).StreamCells(64 * 1024).Iter()
+		var (
+			id int
+			sw sumWriter
+		)
+		for i := 0; iter.NextRow(&row); i++ {
+			if err := row.Scan("id", &id); err != nil || id != i {
+				t.Fatalf("expected id %d got %d: %v", i, id, err)
+			}
+			if _, err := row.WriteColumnTo(1, &sw); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := iter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if sw.n != 3*size || sw.sum != 3*size {
+			t.Fatalf("expected %d bytes to be written, got %d (sum %d)", 3*size, sw.n, sw.sum)
+		}
+	}
+}
+
 func TestQueryTimeout(t *testing.T) {
 	srv := NewTestServer(t, defaultProto, context.Background())
 	defer srv.Stop()
@@ -856,9 +1368,10 @@ func TestWriteCoalescing(t *testing.T) {
 			client.Close()
 		},
 	}
-	timerC := make(chan time.Time, 1)
+	timer := newCoalesceTimer()
+	timer.resets = resetTimer
 	go func() {
-		w.writeFlusherImpl(timerC, func() { resetTimer <- struct{}{} })
+		w.writeFlusherImpl(timer, time.Millisecond)
 	}()
 
 	go func() {
@@ -878,7 +1391,7 @@ func TestWriteCoalescing(t *testing.T) {
 	<-enqueued
 
 	// flush
-	timerC <- time.Now()
+	timer.fire()
 
 	<-done
 
@@ -887,6 +1400,202 @@ func TestWriteCoalescing(t *testing.T) {
 	}
 }
 
+func TestWriteCoalescing_MaxBytes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, client, err := tcpConnPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	var buf bytes.Buffer
+	go func() {
+		defer close(done)
+		defer server.Close()
+		io.Copy(&buf, server)
+	}()
+
+	enqueued := make(chan struct{})
+	w := &writeCoalescer{
+		writeCh:  make(chan writeRequest),
+		c:        client,
+		quit:     ctx.Done(),
+		timeout:  500 * time.Millisecond,
+		maxBytes: 6,
+		testEnqueuedHook: func() {
+			enqueued <- struct{}{}
+		},
+		testFlushedHook: func() {
+			client.Close()
+		},
+	}
+	// the timer never fires, only the size budget can trigger the flush
+	go w.writeFlusherImpl(newCoalesceTimer(), time.Millisecond)
+
+	go func() {
+		if _, err := w.writeContext(context.Background(), []byte("one")); err != nil {
+			t.Error(err)
+		}
+	}()
+	<-enqueued
+
+	go func() {
+		if _, err := w.writeContext(context.Background(), []byte("two")); err != nil {
+			t.Error(err)
+		}
+	}()
+	<-enqueued
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for size triggered flush")
+	}
+
+	if got := buf.String(); got != "onetwo" {
+		t.Fatalf("expected to get %q got %q", "onetwo", got)
+	}
+}
+
+// coalesceTimer is a Timer fired by the tests. Like a time.Timer before
+// Go 1.23, an expiry stays in its channel until it is received, even if the
+// timer is stopped or reset.
+type coalesceTimer struct {
+	c chan time.Time
+	// resets, if set, receives the resets of the timer.
+	resets chan struct{}
+
+	mu    sync.Mutex
+	armed bool
+}
+
+func newCoalesceTimer() *coalesceTimer {
+	return &coalesceTimer{c: make(chan time.Time, 1)}
+}
+
+func (t *coalesceTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *coalesceTimer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	armed := t.armed
+	t.armed = false
+	return armed
+}
+
+func (t *coalesceTimer) Reset(d time.Duration) bool {
+	t.mu.Lock()
+	armed := t.armed
+	t.armed = true
+	t.mu.Unlock()
+	if t.resets != nil {
+		t.resets <- struct{}{}
+	}
+	return armed
+}
+
+// fire expires the timer if it is armed.
+func (t *coalesceTimer) fire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.armed {
+		t.armed = false
+		select {
+		case t.c <- time.Now():
+		default:
+		}
+	}
+}
+
+// nopDeadlineWriter is a deadlineWriter writing to a buffer.
+type nopDeadlineWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *nopDeadlineWriter) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (w *nopDeadlineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *nopDeadlineWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestWriteCoalescing_MaxBytesStopsTimer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	timer := newCoalesceTimer()
+	timer.resets = make(chan struct{}, 1)
+	var (
+		conn    nopDeadlineWriter
+		flushes int
+	)
+	flushed := make(chan struct{}, 1)
+	w := &writeCoalescer{
+		writeCh:  make(chan writeRequest),
+		c:        &conn,
+		quit:     ctx.Done(),
+		maxBytes: 6,
+	}
+	write := func(p string) {
+		go func() {
+			if _, err := w.writeContext(context.Background(), []byte(p)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	w.testFlushedHook = func() {
+		flushes++
+		if flushes%2 == 1 {
+			// the timer of the first write expires while the size triggered
+			// flush is written, and the next write is waiting once it is done
+			timer.fire()
+			write("ccc")
+			time.Sleep(time.Millisecond)
+		}
+		flushed <- struct{}{}
+	}
+	go w.writeFlusherImpl(timer, time.Millisecond)
+
+	var expected string
+	for i := 0; i < 20; i++ {
+		write("aaa")
+		<-timer.resets
+		write("bbb")
+		<-flushed
+		expected += "aaabbb"
+		if got := conn.String(); got != expected {
+			t.Fatalf("expected to get %q got %q", expected, got)
+		}
+
+		// the next write waits for its own interval
+		<-timer.resets
+		select {
+		case <-flushed:
+			t.Fatalf("write flushed early by the timer of the previous writes, got %q", conn.String())
+		case <-time.After(5 * time.Millisecond):
+		}
+		timer.fire()
+		<-flushed
+		expected += "ccc"
+		if got := conn.String(); got != expected {
+			t.Fatalf("expected to get %q got %q", expected, got)
+		}
+	}
+}
+
 func TestWriteCoalescing_WriteAfterClose(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -904,7 +1613,7 @@ func TestWriteCoalescing_WriteAfterClose(t *testing.T) {
 		server.Close()
 		close(done)
 	}()
-	w := newWriteCoalescer(client, 0, 5*time.Millisecond, ctx.Done())
+	w := newWriteCoalescer(client, 0, 5*time.Millisecond, 0, ctx.Done())
 
 	// ensure 1 write works
 	if _, err := w.writeContext(context.Background(), []byte("one")); err != nil {
@@ -992,6 +1701,8 @@ type newTestServerOpts struct {
 	addr     string
 	protocol uint8
 	recvHook func(*framer)
+	// supported returns the options sent in SUPPORTED frames to the connection.
+	supported func(conn net.Conn) map[string][]string
 }
 
 func (nts newTestServerOpts) newServer(t testing.TB, ctx context.Context) *TestServer {
@@ -1020,7 +1731,8 @@ func (nts newTestServerOpts) newServer(t testing.TB, ctx context.Context) *TestS
 		ctx:        ctx,
 		cancel:     cancel,
 
-		onRecv: nts.recvHook,
+		onRecv:    nts.recvHook,
+		supported: nts.supported,
 	}
 
 	go srv.closeWatch()
@@ -1073,6 +1785,10 @@ func NewSSLTestServer(t testing.TB, protocol uint8, ctx context.Context) *TestSe
 	return srv
 }
 
+// bindMarkerPattern matches the positional and named bind markers of the
+// statements prepared by the test server.
+var bindMarkerPattern = regexp.MustCompile(`\?|:(\w+)`)
+
 type TestServer struct {
 	Address          string
 	TimeoutOnStartup int32
@@ -1090,6 +1806,8 @@ type TestServer struct {
 
 	// onRecv is a hook point for tests, called in receive loop.
 	onRecv func(*framer)
+
+	supported func(conn net.Conn) map[string][]string
 }
 
 func (srv *TestServer) closeWatch() {
@@ -1184,7 +1902,16 @@ func (srv *TestServer) process(conn net.Conn, reqFrame *framer) {
 		respFrame.writeHeader(0, opReady, head.stream)
 	case opOptions:
 		respFrame.writeHeader(0, opSupported, head.stream)
-		respFrame.writeShort(0)
+		if srv.supported == nil {
+			respFrame.writeShort(0)
+			break
+		}
+		supported := srv.supported(conn)
+		respFrame.writeShort(uint16(len(supported)))
+		for k, v := range supported {
+			respFrame.writeString(k)
+			respFrame.writeStringList(v)
+		}
 	case opQuery:
 		query := reqFrame.readLongString()
 		first := query
@@ -1197,12 +1924,75 @@ func (srv *TestServer) process(conn net.Conn, reqFrame *framer) {
 			respFrame.writeHeader(0, opError, head.stream)
 			respFrame.writeInt(0x1001)
 			respFrame.writeString("query killed")
+		case "ratelimit":
+			// ratelimit read|write
+			atomic.AddInt64(&srv.nKillReq, 1)
+			respFrame.writeHeader(0, opError, head.stream)
+			respFrame.writeInt(0xF000)
+			respFrame.writeString("rate limit reached")
+			if strings.HasSuffix(query, "write") {
+				respFrame.writeByte(byte(OpTypeWrite))
+			} else {
+				respFrame.writeByte(byte(OpTypeRead))
+			}
+			respFrame.writeByte(1)
+		case "unavailable":
+			// unavailable [serial], reporting the consistency of the query or
+			// SERIAL
+			cons := reqFrame.readConsistency()
+			if strings.HasSuffix(query, "serial") {
+				cons = Consistency(Serial)
+			}
+			respFrame.writeHeader(0, opError, head.stream)
+			respFrame.writeInt(ErrCodeUnavailable)
+			respFrame.writeString("cannot achieve consistency level")
+			respFrame.writeConsistency(cons)
+			respFrame.writeInt(2)
+			respFrame.writeInt(1)
+		case "writetimeout":
+			// the Paxos round of a lightweight transaction timed out
+			respFrame.writeHeader(0, opError, head.stream)
+			respFrame.writeInt(ErrCodeWriteTimeout)
+			respFrame.writeString("timeout during CAS write query")
+			respFrame.writeConsistency(Consistency(Serial))
+			respFrame.writeInt(1)
+			respFrame.writeInt(2)
+			respFrame.writeString("CAS")
 		case "use":
 			respFrame.writeInt(resultKindKeyspace)
 			respFrame.writeString(strings.TrimSpace(query[3:]))
 		case "void":
 			respFrame.writeHeader(0, opResult, head.stream)
 			respFrame.writeInt(resultKindVoid)
+		case "corrupt":
+			// flag the response as compressed, which no compressor was
+			// negotiated for
+			respFrame.writeHeader(0, opResult, head.stream)
+			respFrame.writeInt(resultKindVoid)
+			respFrame.buf[0] = srv.protocol | 0x80
+			respFrame.finish()
+			respFrame.buf[1] |= flagCompress
+			respFrame.writeTo(conn)
+			return
+		case "blobs":
+			// blobs n size: n rows of an int id and a blob of size bytes
+			var n, size int
+			fmt.Sscanf(query, "blobs %d %d", &n, &size)
+			respFrame.writeHeader(0, opResult, head.stream)
+			respFrame.writeInt(resultKindRows)
+			respFrame.writeInt(int32(flagGlobalTableSpec))
+			respFrame.writeInt(2)
+			respFrame.writeString("ks")
+			respFrame.writeString("t")
+			respFrame.writeString("id")
+			respFrame.writeShort(uint16(TypeInt))
+			respFrame.writeString("data")
+			respFrame.writeShort(uint16(TypeBlob))
+			respFrame.writeInt(int32(n))
+			for i := 0; i < n; i++ {
+				respFrame.writeBytes([]byte{0, 0, 0, byte(i)})
+				respFrame.writeBytes(bytes.Repeat([]byte{byte(i)}, size))
+			}
 		case "timeout":
 			<-srv.ctx.Done()
 			return
@@ -1234,9 +2024,76 @@ func (srv *TestServer) process(conn net.Conn, reqFrame *framer) {
 				<-time.After(time.Millisecond * 120)
 			}
 		default:
+			if strings.Contains(query, "system.peers_v2") {
+				// nodes upgraded from Cassandra 3 have no system.peers_v2
+				respFrame.writeHeader(0, opError, head.stream)
+				respFrame.writeInt(ErrCodeInvalid)
+				respFrame.writeString("unconfigured table peers_v2")
+				break
+			}
 			respFrame.writeHeader(0, opResult, head.stream)
 			respFrame.writeInt(resultKindVoid)
 		}
+	case opPrepare:
+		// the bind markers of the statement are int columns of the table ks.t,
+		// named after the named markers or c0, c1... for positional ones,
+		// statements referring to a missing table are invalid
+		query := reqFrame.readLongString()
+		if strings.Contains(query, "missing") {
+			respFrame.writeHeader(0, opError, head.stream)
+			respFrame.writeInt(ErrCodeInvalid)
+			respFrame.writeString("unconfigured table missing")
+			break
+		}
+		markers := bindMarkerPattern.FindAllStringSubmatch(query, -1)
+		respFrame.writeHeader(0, opResult, head.stream)
+		respFrame.writeInt(resultKindPrepared)
+		respFrame.writeShortBytes([]byte(query))
+		respFrame.writeInt(int32(flagGlobalTableSpec))
+		respFrame.writeInt(int32(len(markers)))
+		if srv.protocol >= protoVersion4 {
+			// markers named pk... are the partition key
+			var pkey []int
+			for i, marker := range markers {
+				if strings.HasPrefix(marker[1], "pk") {
+					pkey = append(pkey, i)
+				}
+			}
+			respFrame.writeInt(int32(len(pkey)))
+			for _, i := range pkey {
+				respFrame.writeShort(uint16(i))
+			}
+		}
+		respFrame.writeString("ks")
+		respFrame.writeString("t")
+		for i, marker := range markers {
+			name := marker[1]
+			if name == "" {
+				name = fmt.Sprintf("c%d", i)
+			}
+			respFrame.writeString(name)
+			respFrame.writeShort(uint16(TypeInt))
+		}
+		if srv.protocol >= protoVersion2 {
+			respFrame.writeInt(int32(flagNoMetaData))
+			respFrame.writeInt(0)
+		}
+	case opExecute:
+		// statements prepared by the test server have no result columns, the
+		// rows of "stale" statements have a column nonetheless
+		if id := reqFrame.readShortBytes(); strings.Contains(string(id), "stale") {
+			respFrame.writeHeader(0, opResult, head.stream)
+			respFrame.writeInt(resultKindRows)
+			respFrame.writeInt(int32(flagNoMetaData))
+			respFrame.writeInt(1)
+			respFrame.writeInt(0)
+			break
+		}
+		respFrame.writeHeader(0, opResult, head.stream)
+		respFrame.writeInt(resultKindVoid)
+	case opBatch:
+		respFrame.writeHeader(0, opResult, head.stream)
+		respFrame.writeInt(resultKindVoid)
 	case opError:
 		respFrame.writeHeader(0, opError, head.stream)
 		respFrame.buf = append(respFrame.buf, reqFrame.buf...)
@@ -14,6 +14,7 @@ import (
 	"net"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -73,6 +74,10 @@ func setupTLSConfig(sslOpts *SslOptions) (*tls.Config, error) {
 		tlsConfig.Certificates = append(tlsConfig.Certificates, mycert)
 	}
 
+	if sslOpts.KeyLogWriter != nil {
+		tlsConfig.KeyLogWriter = sslOpts.KeyLogWriter
+	}
+
 	return tlsConfig, nil
 }
 
@@ -105,6 +110,11 @@ func connConfig(cfg *ClusterConfig) (*ConnConfig, error) {
 			}
 		}
 
+		sockOpts := socketOptions{
+			readBufferSize:  cfg.SocketReadBufferSize,
+			writeBufferSize: cfg.SocketWriteBufferSize,
+			noDelay:         !cfg.DisableTCPNoDelay,
+		}
 		dialer := cfg.Dialer
 		if dialer == nil {
 			d := &net.Dialer{
@@ -113,12 +123,22 @@ func connConfig(cfg *ClusterConfig) (*ConnConfig, error) {
 			if cfg.SocketKeepalive > 0 {
 				d.KeepAlive = cfg.SocketKeepalive
 			}
+			if cfg.SocketKeepaliveInterval > 0 || cfg.SocketKeepaliveCount > 0 {
+				if !keepaliveParamsSupported() {
+					return nil, errors.New("SocketKeepaliveInterval and SocketKeepaliveCount are not supported on this platform")
+				}
+			}
+			d.Control = cfg.SocketControl
 			dialer = d
+			sockOpts.keepaliveInterval = cfg.SocketKeepaliveInterval
+			sockOpts.keepaliveCount = cfg.SocketKeepaliveCount
 		}
 
 		hostDialer = &defaultHostDialer{
-			dialer:    dialer,
-			tlsConfig: tlsConfig,
+			dialer:      dialer,
+			tlsConfig:   tlsConfig,
+			tlsObserver: cfg.TLSHandshakeObserver,
+			sockOpts:    sockOpts,
 		}
 	}
 
@@ -282,6 +302,20 @@ type hostConnPool struct {
 	closed  bool
 	filling bool
 
+	// sharding is the sharding information of a Scylla node, learned from the
+	// first connection to it. pendingShards are the shards being connected to.
+	sharding      scyllaSupported
+	pendingShards []int
+	// shardPortDisabledUntil is set when the shard-aware port is unreachable or
+	// its connections land on other shards, it is used again after that time.
+	// shardPortFailures is the number of consecutive times it was disabled.
+	shardPortDisabledUntil time.Time
+	shardPortFailures      int
+	// rebalanceTimer is set while a rebalancing of the connections between the
+	// shards is scheduled, rebalanceAttempts is the number of consecutive attempts.
+	rebalanceTimer    Timer
+	rebalanceAttempts int
+
 	pos    uint32
 	logger StdLogger
 }
@@ -314,6 +348,12 @@ func newHostConnPool(session *Session, host *HostInfo, port, size int,
 
 // Pick a connection from this connection pool for the given query.
 func (pool *hostConnPool) Pick() *Conn {
+	return pool.pick(false)
+}
+
+// pick returns a connection of the pool. If peek is true, the pool is not
+// filled and the connection the next pick would start from is not changed.
+func (pool *hostConnPool) pick(peek bool) *Conn {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
 
@@ -322,16 +362,27 @@ func (pool *hostConnPool) Pick() *Conn {
 	}
 
 	size := len(pool.conns)
-	if size < pool.size {
+	if size < pool.size && !peek {
 		// try to fill the pool
 		go pool.fill()
+	}
+	if size == 0 {
+		return nil
+	}
 
-		if size == 0 {
-			return nil
+	if pool.session.cfg.PoolConfig.DeterministicConnPick {
+		if conn := firstAvailableConn(pool.conns, -1); conn != nil || pool.session.cfg.MaxStreamWait < 0 {
+			return conn
 		}
+		return pool.conns[0]
 	}
 
-	pos := int(atomic.AddUint32(&pool.pos, 1) - 1)
+	var pos int
+	if peek {
+		pos = int(atomic.LoadUint32(&pool.pos))
+	} else {
+		pos = int(atomic.AddUint32(&pool.pos, 1) - 1)
+	}
 
 	var (
 		leastBusyConn    *Conn
@@ -346,10 +397,91 @@ func (pool *hostConnPool) Pick() *Conn {
 			streamsAvailable = streams
 		}
 	}
+	if leastBusyConn == nil && pool.session.cfg.MaxStreamWait >= 0 {
+		// all the streams are in use, wait for one to be released
+		leastBusyConn = pool.conns[pos%size]
+	}
+
+	return leastBusyConn
+}
+
+// pickFor picks a connection to the shard of the selected host owning
+// the token of the query, if known, or any connection otherwise.
+func (pool *hostConnPool) pickFor(selectedHost SelectedHost) *Conn {
+	return pool.pickForShard(selectedHost, false)
+}
+
+// peekFor returns the connection pickFor would pick, without filling the
+// pool nor changing the connection the next pick starts from.
+func (pool *hostConnPool) peekFor(selectedHost SelectedHost) *Conn {
+	return pool.pickForShard(selectedHost, true)
+}
+
+func (pool *hostConnPool) pickForShard(selectedHost SelectedHost, peek bool) *Conn {
+	sh, ok := selectedHost.(*shardSelectedHost)
+	if !ok {
+		return pool.pick(peek)
+	}
+
+	pool.mu.RLock()
+	sharding := pool.sharding
+	pool.mu.RUnlock()
+	if sharding.nrShards <= 1 {
+		return pool.pick(peek)
+	}
+
+	shard := sh.targetShard(sharding)
+	if shard < 0 {
+		return pool.pick(peek)
+	}
+	if conn := pool.pickShard(shard); conn != nil {
+		return conn
+	}
+	return pool.pick(peek)
+}
+
+// pickShard returns the least busy connection to the shard, or nil if there is none.
+func (pool *hostConnPool) pickShard(shard int) *Conn {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	if pool.closed {
+		return nil
+	}
+	if pool.session.cfg.PoolConfig.DeterministicConnPick {
+		return firstAvailableConn(pool.conns, shard)
+	}
 
+	var (
+		leastBusyConn    *Conn
+		streamsAvailable int
+	)
+	for _, conn := range pool.conns {
+		if conn.scyllaSupported.shard != shard {
+			continue
+		}
+		if streams := conn.AvailableStreams(); streams > streamsAvailable {
+			leastBusyConn = conn
+			streamsAvailable = streams
+		}
+	}
 	return leastBusyConn
 }
 
+// firstAvailableConn returns the first connection to the shard, or to any
+// shard if shard is negative, with available streams.
+func firstAvailableConn(conns []*Conn, shard int) *Conn {
+	for _, conn := range conns {
+		if shard >= 0 && conn.scyllaSupported.shard != shard {
+			continue
+		}
+		if conn.AvailableStreams() > 0 {
+			return conn
+		}
+	}
+	return nil
+}
+
 // Size returns the number of connections currently active in the pool
 func (pool *hostConnPool) Size() int {
 	pool.mu.RLock()
@@ -381,6 +513,11 @@ func (pool *hostConnPool) Close() {
 	conns := pool.conns
 	pool.conns = nil
 
+	if pool.rebalanceTimer != nil {
+		pool.rebalanceTimer.Stop()
+		pool.rebalanceTimer = nil
+	}
+
 	pool.mu.Unlock()
 
 	// close the connections
@@ -444,8 +581,11 @@ func (pool *hostConnPool) fill() {
 		// notify the session that this node is connected
 		go pool.session.handleNodeConnected(pool.host)
 
-		// filled one
-		fillCount--
+		// the size of the pool can grow once the first connection
+		// discovered the number of shards of the node
+		pool.mu.RLock()
+		fillCount = pool.size - len(pool.conns)
+		pool.mu.RUnlock()
 	}
 
 	// fill the rest of the pool asynchronously
@@ -504,11 +644,15 @@ func (pool *hostConnPool) fillingStopped(err error) {
 			pool.session.handleNodeDown(host.ConnectAddress(), port)
 		}
 	}
+
+	if err == nil {
+		pool.scheduleRebalance()
+	}
 }
 
 // connectMany creates new connections concurrent.
 func (pool *hostConnPool) connectMany(count int) error {
-	if count == 0 {
+	if count <= 0 {
 		return nil
 	}
 	var (
@@ -541,9 +685,13 @@ func (pool *hostConnPool) connect() (err error) {
 	// be able to detect hosts that come up by trying to connect to downed ones.
 	// try to connect
 	var conn *Conn
+	target := pool.reserveShard()
+	if target != nil {
+		defer pool.releaseShard(target.shard)
+	}
 	reconnectionPolicy := pool.session.cfg.ReconnectionPolicy
 	for i := 0; i < reconnectionPolicy.GetMaxRetries(); i++ {
-		conn, err = pool.session.connect(pool.session.ctx, pool.host, pool)
+		conn, err = pool.dial(target)
 		if err == nil {
 			break
 		}
@@ -558,7 +706,7 @@ func (pool *hostConnPool) connect() (err error) {
 			pool.logger.Printf("gocql: connection failed %q: %v, reconnecting with %T\n",
 				pool.host.ConnectAddress(), err, reconnectionPolicy)
 		}
-		time.Sleep(reconnectionPolicy.GetInterval(i))
+		pool.session.cfg.clock().Sleep(reconnectionPolicy.GetInterval(i))
 	}
 
 	if err != nil {
@@ -582,11 +730,243 @@ func (pool *hostConnPool) connect() (err error) {
 		return nil
 	}
 
+	if si := conn.scyllaSupported; si.nrShards > 0 {
+		if pool.sharding.nrShards == 0 {
+			pool.sharding = si
+			// keep at least one connection to every shard
+			if pool.size < si.nrShards {
+				pool.size = si.nrShards
+			}
+		}
+		if target != nil && target.dialed {
+			if si.shard != target.shard {
+				// most likely the connection is translated by a NAT
+				pool.disableShardPortLocked(fmt.Sprintf("connection landed on shard %d instead of %d", si.shard, target.shard))
+			} else {
+				pool.shardPortFailures = 0
+			}
+		}
+	}
+
 	pool.conns = append(pool.conns, conn)
 
 	return nil
 }
 
+// dial connects to the shard-aware port of the node if target is set,
+// falling back to the regular port.
+func (pool *hostConnPool) dial(target *shardTarget) (*Conn, error) {
+	if target != nil {
+		conn, err := pool.session.connectShard(pool.session.ctx, pool.host, pool, *target)
+		if err == nil {
+			target.dialed = true
+			return conn, nil
+		}
+
+		if shardPortUnreachable(err) {
+			pool.mu.Lock()
+			pool.disableShardPortLocked(err.Error())
+			pool.mu.Unlock()
+		} else if gocqlDebug {
+			pool.logger.Printf("gocql: unable to connect to shard-aware port of %q, falling back to the regular port: %v\n",
+				pool.host.ConnectAddress(), err)
+		}
+	}
+
+	return pool.session.connect(pool.session.ctx, pool.host, pool)
+}
+
+// shardPortUnreachable returns true if err, the error connecting through the
+// shard-aware port, shows the port can not be used rather than a transient
+// failure, like a timeout, which would fail connecting to the regular port too.
+func shardPortUnreachable(err error) bool {
+	return err == errShardAwarePortUnavailable ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH)
+}
+
+// disableShardPortLocked stops using the shard-aware port until the next
+// interval of the reconnection policy elapsed, consecutive failures being
+// delayed by the following intervals, pool.mu must be held.
+func (pool *hostConnPool) disableShardPortLocked(reason string) {
+	now := pool.session.cfg.clock().Now()
+	if now.Before(pool.shardPortDisabledUntil) {
+		return
+	}
+
+	policy := pool.session.cfg.ReconnectionPolicy
+	attempt := pool.shardPortFailures
+	if max := policy.GetMaxRetries(); max > 0 && attempt >= max {
+		attempt = max - 1
+	}
+	interval := policy.GetInterval(attempt)
+	pool.shardPortFailures++
+	pool.shardPortDisabledUntil = now.Add(interval)
+	pool.logger.Printf("gocql: not using shard-aware port of %q for %v: %s\n",
+		pool.host.ConnectAddress(), interval, reason)
+}
+
+// reserveShard returns the shard with the least connections which a new connection
+// should be established to, or nil if the shard-aware port can not be used.
+func (pool *hostConnPool) reserveShard() *shardTarget {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	si := pool.sharding
+	if si.nrShards <= 1 || pool.session.cfg.DisableShardAwarePort {
+		return nil
+	} else if pool.session.cfg.clock().Now().Before(pool.shardPortDisabledUntil) {
+		return nil
+	} else if si.shardAwarePort == 0 && si.shardAwarePortSSL == 0 {
+		return nil
+	}
+
+	counts := make([]int, si.nrShards)
+	for _, conn := range pool.conns {
+		if shard := conn.scyllaSupported.shard; shard < len(counts) {
+			counts[shard]++
+		}
+	}
+	for _, shard := range pool.pendingShards {
+		counts[shard]++
+	}
+
+	shard := 0
+	for i := range counts {
+		if counts[i] < counts[shard] {
+			shard = i
+		}
+	}
+	pool.pendingShards = append(pool.pendingShards, shard)
+
+	return &shardTarget{
+		shard:    shard,
+		nrShards: si.nrShards,
+		port:     si.shardAwarePort,
+		portSSL:  si.shardAwarePortSSL,
+	}
+}
+
+func (pool *hostConnPool) releaseShard(shard int) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for i, pending := range pool.pendingShards {
+		if pending == shard {
+			pool.pendingShards = append(pool.pendingShards[:i], pool.pendingShards[i+1:]...)
+			return
+		}
+	}
+}
+
+// shardSurplusLocked returns the connections of a full pool to a Scylla node
+// which exceed the expected number of connections to their shard while other
+// shards have too few, pool.mu must be held.
+func (pool *hostConnPool) shardSurplusLocked() []*Conn {
+	nrShards := pool.sharding.nrShards
+	if nrShards <= 1 || len(pool.conns) < pool.size {
+		return nil
+	}
+
+	minPerShard := pool.size / nrShards
+	maxPerShard := (pool.size + nrShards - 1) / nrShards
+	byShard := make([][]*Conn, nrShards)
+	for _, conn := range pool.conns {
+		if shard := conn.scyllaSupported.shard; shard < nrShards {
+			byShard[shard] = append(byShard[shard], conn)
+		}
+	}
+
+	var (
+		missing int
+		surplus []*Conn
+	)
+	for _, conns := range byShard {
+		if len(conns) < minPerShard {
+			missing += minPerShard - len(conns)
+		} else if len(conns) > maxPerShard {
+			surplus = append(surplus, conns[maxPerShard:]...)
+		}
+	}
+	if len(surplus) > missing {
+		surplus = surplus[:missing]
+	}
+	return surplus
+}
+
+// scheduleRebalance schedules the rebalancing of the connections between the
+// shards if the pool is imbalanced, which happens when connections land on
+// random shards after reconnecting without the shard-aware port. Consecutive
+// attempts are delayed by the intervals of the reconnection policy.
+func (pool *hostConnPool) scheduleRebalance() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if pool.closed || pool.rebalanceTimer != nil {
+		return
+	}
+	if len(pool.shardSurplusLocked()) == 0 {
+		pool.rebalanceAttempts = 0
+		return
+	}
+
+	policy := pool.session.cfg.ReconnectionPolicy
+	attempt := pool.rebalanceAttempts
+	if max := policy.GetMaxRetries(); max > 0 && attempt >= max {
+		attempt = max - 1
+	}
+	pool.rebalanceTimer = pool.session.cfg.clock().AfterFunc(policy.GetInterval(attempt), pool.rebalance)
+}
+
+// rebalance closes the connections in excess on some shards and fills the pool
+// again to connect to the shards which have too few connections.
+func (pool *hostConnPool) rebalance() {
+	pool.mu.Lock()
+	pool.rebalanceTimer = nil
+	if pool.closed || pool.filling {
+		// filling schedules a rebalance once it is done
+		pool.mu.Unlock()
+		return
+	}
+
+	surplus := pool.shardSurplusLocked()
+	if len(surplus) == 0 {
+		pool.rebalanceAttempts = 0
+		pool.mu.Unlock()
+		return
+	}
+	pool.rebalanceAttempts++
+	for _, conn := range surplus {
+		for i, candidate := range pool.conns {
+			if candidate == conn {
+				pool.conns[i], pool.conns = pool.conns[len(pool.conns)-1], pool.conns[:len(pool.conns)-1]
+				break
+			}
+		}
+	}
+	pool.mu.Unlock()
+
+	if gocqlDebug {
+		pool.logger.Printf("gocql: rebalancing %d connections between the shards of %q\n", len(surplus), pool.host.ConnectAddress())
+	}
+	for _, conn := range surplus {
+		go pool.drainAndClose(conn)
+	}
+	pool.fill()
+}
+
+// drainAndClose closes a connection removed from the pool once its in flight
+// requests completed, or after the request timeout.
+func (pool *hostConnPool) drainAndClose(conn *Conn) {
+	deadline := time.Now().Add(pool.session.cfg.Timeout)
+	// the stream 0 is reserved
+	for conn.AvailableStreams() < conn.streams.NumStreams-1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn.Close()
+}
+
 // handle any error from a Conn
 func (pool *hostConnPool) HandleError(conn *Conn, err error, closed bool) {
 	if !closed {
@@ -4,8 +4,10 @@
 package gocql
 
 import (
+	"context"
 	"crypto/tls"
 	"testing"
+	"time"
 )
 
 func TestSetupTLSConfig(t *testing.T) {
@@ -83,3 +85,38 @@ func TestSetupTLSConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestDeterministicConnPick(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 3
+	cluster.PoolConfig.DeterministicConnPick = true
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	pool, ok := db.pool.getPool(db.ring.allHosts()[0])
+	if !ok {
+		t.Fatal("no pool for host")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.Size() < cluster.NumConns {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d connections got %d", cluster.NumConns, pool.Size())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	pool.mu.RLock()
+	first := pool.conns[0]
+	pool.mu.RUnlock()
+	for i := 0; i < 10; i++ {
+		if conn := pool.Pick(); conn != first {
+			t.Fatalf("expected the first connection %p got %p", first, conn)
+		}
+	}
+}
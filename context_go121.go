@@ -0,0 +1,12 @@
+//go:build go1.21
+// +build go1.21
+
+package gocql
+
+import "context"
+
+// afterFunc calls f in its own goroutine once ctx is done, unless the
+// returned stop function is called before. See context.AfterFunc.
+func afterFunc(ctx context.Context, f func()) (stop func() bool) {
+	return context.AfterFunc(ctx, f)
+}
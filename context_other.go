@@ -0,0 +1,26 @@
+//go:build !go1.21
+// +build !go1.21
+
+package gocql
+
+import (
+	"context"
+	"time"
+)
+
+// afterFunc calls f in its own goroutine once ctx is done, unless the
+// returned stop function is called before. Before Go 1.21 only the deadline
+// of ctx is watched, so that no goroutine waits on ctx in the meantime: f is
+// not called when ctx is canceled before its deadline.
+func afterFunc(ctx context.Context, f func()) (stop func() bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return func() bool { return true }
+	}
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		// the timer of ctx might fire after this one
+		<-ctx.Done()
+		f()
+	})
+	return timer.Stop
+}
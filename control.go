@@ -10,6 +10,7 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -47,6 +48,12 @@ type controlConn struct {
 	retry RetryPolicy
 
 	quit chan struct{}
+
+	// lostAt is when the control connection was lost to lostHost, zero while
+	// it is up
+	mu       sync.Mutex
+	lostAt   time.Time
+	lostHost *HostInfo
 }
 
 func createControlConn(session *Session) *controlConn {
@@ -67,7 +74,7 @@ func (c *controlConn) heartBeat() {
 	}
 
 	sleepTime := 1 * time.Second
-	timer := time.NewTimer(sleepTime)
+	timer := c.session.cfg.clock().NewTimer(sleepTime)
 	defer timer.Stop()
 
 	for {
@@ -76,7 +83,7 @@ func (c *controlConn) heartBeat() {
 		select {
 		case <-c.quit:
 			return
-		case <-timer.C:
+		case <-timer.C():
 		}
 
 		resp, err := c.writeFrame(&writeOptionsFrame{})
@@ -84,12 +91,13 @@ func (c *controlConn) heartBeat() {
 			goto reconn
 		}
 
-		switch resp.(type) {
+		switch x := resp.(type) {
 		case *supportedFrame:
 			// Everything ok
 			sleepTime = 5 * time.Second
 			continue
 		case error:
+			err = x
 			goto reconn
 		default:
 			panic(fmt.Sprintf("gocql: unknown frame in response to options: %T", resp))
@@ -98,6 +106,7 @@ func (c *controlConn) heartBeat() {
 	reconn:
 		// try to connect a bit faster
 		sleepTime = 1 * time.Second
+		c.lost(err)
 		c.reconnect()
 		continue
 	}
@@ -118,6 +127,53 @@ func hostInfo(addr string, defaultPort int) ([]*HostInfo, error) {
 		}
 	}
 
+	if isSRVName(host) {
+		return srvHostInfo(host)
+	}
+	return lookupHostInfo(host, port)
+}
+
+// isSRVName reports whether host is the name of DNS SRV records, such as
+// _cql._tcp.cluster.example.com, which hostnames can not start with.
+func isSRVName(host string) bool {
+	return strings.HasPrefix(host, "_") && (strings.Contains(host, "._tcp.") || strings.Contains(host, "._udp."))
+}
+
+// lookupSRV is net.LookupSRV, replaced in tests.
+var lookupSRV = net.LookupSRV
+
+// srvHostInfo returns the hosts of the targets of the DNS SRV records name,
+// with the ports of the records.
+func srvHostInfo(name string) ([]*HostInfo, error) {
+	_, records, err := lookupSRV("", "", name)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		hosts     []*HostInfo
+		lookupErr error
+	)
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		resolved, err := lookupHostInfo(target, int(record.Port))
+		if err != nil {
+			// the other targets might resolve
+			lookupErr = fmt.Errorf("resolve target %q of SRV records %q: %w", target, name, err)
+			continue
+		}
+		hosts = append(hosts, resolved...)
+	}
+	if len(hosts) == 0 {
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		return nil, fmt.Errorf("no targets returned from DNS SRV lookup for %q", name)
+	}
+	return hosts, nil
+}
+
+func lookupHostInfo(host string, port int) ([]*HostInfo, error) {
 	var hosts []*HostInfo
 
 	// Check if host is a literal IP address
@@ -131,7 +187,7 @@ func hostInfo(addr string, defaultPort int) ([]*HostInfo, error) {
 	if err != nil {
 		return nil, err
 	} else if len(ips) == 0 {
-		return nil, fmt.Errorf("no IP's returned from DNS lookup for %q", addr)
+		return nil, fmt.Errorf("no IP's returned from DNS lookup for %q", host)
 	}
 
 	// Filter to v4 addresses if any present
@@ -270,7 +326,7 @@ type connHost struct {
 func (c *controlConn) setupConn(conn *Conn) error {
 	// we need up-to-date host info for the filterHost call below
 	iter := conn.querySystemLocal(context.TODO())
-	host, err := c.session.hostInfoFromIter(iter, conn.host.connectAddress, conn.conn.RemoteAddr().(*net.TCPAddr).Port)
+	host, err := c.session.hostInfoFromIter(c.session.ctx, iter, conn.host.connectAddress, conn.conn.RemoteAddr().(*net.TCPAddr).Port)
 	if err != nil {
 		return err
 	}
@@ -291,6 +347,7 @@ func (c *controlConn) setupConn(conn *Conn) error {
 	}
 
 	c.conn.Store(ch)
+	c.established(host)
 	if c.session.initialized() {
 		// We connected to control conn, so add the connect the host in pool as well.
 		// Notify session we can start trying to connect to the node.
@@ -428,9 +485,56 @@ func (c *controlConn) HandleError(conn *Conn, err error, closed bool) {
 		return
 	}
 
+	c.lost(err)
 	c.reconnect()
 }
 
+// lost notes that the control connection was lost for err, unless it already
+// was.
+func (c *controlConn) lost(err error) {
+	if atomic.LoadInt32(&c.state) == controlConnClosing {
+		return
+	}
+	var host *HostInfo
+	if ch := c.getConn(); ch != nil {
+		host = ch.host
+	}
+
+	c.mu.Lock()
+	if !c.lostAt.IsZero() {
+		c.mu.Unlock()
+		return
+	}
+	c.lostAt = c.session.cfg.clock().Now()
+	c.lostHost = host
+	c.mu.Unlock()
+
+	c.observe(ObservedControlConn{Type: ControlConnLost, Host: host, Err: err})
+}
+
+// established notes that the control connection was established to host.
+func (c *controlConn) established(host *HostInfo) {
+	c.mu.Lock()
+	lostAt, lostHost := c.lostAt, c.lostHost
+	c.lostAt, c.lostHost = time.Time{}, nil
+	c.mu.Unlock()
+
+	o := ObservedControlConn{Type: ControlConnEstablished, Host: host, PreviousHost: lostHost}
+	if !lostAt.IsZero() {
+		o.Downtime = c.session.cfg.clock().Now().Sub(lostAt)
+		if lostHost != nil && lostHost.HostID() != host.HostID() {
+			o.Type = ControlConnMigrated
+		}
+	}
+	c.observe(o)
+}
+
+func (c *controlConn) observe(o ObservedControlConn) {
+	if observer := c.session.cfg.ControlConnObserver; observer != nil {
+		observer.ObserveControlConn(o)
+	}
+}
+
 func (c *controlConn) getConn() *connHost {
 	return c.conn.Load().(*connHost)
 }
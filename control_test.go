@@ -1,8 +1,10 @@
 package gocql
 
 import (
+	"errors"
 	"net"
 	"testing"
+	"time"
 )
 
 func TestHostInfo_Lookup(t *testing.T) {
@@ -31,6 +33,39 @@ func TestHostInfo_Lookup(t *testing.T) {
 	}
 }
 
+func TestHostInfo_LookupSRV(t *testing.T) {
+	defer func(lookup func(string, string, string) (string, []*net.SRV, error)) { lookupSRV = lookup }(lookupSRV)
+	var records []*net.SRV
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		if name != "_cql._tcp.cluster.example.com" {
+			t.Errorf("unexpected SRV lookup of %q", name)
+		}
+		return "", records, nil
+	}
+
+	records = []*net.SRV{
+		{Target: "127.0.0.1.", Port: 19042},
+		{Target: "127.0.0.2.", Port: 29042},
+	}
+	hosts, err := hostInfo("_cql._tcp.cluster.example.com", 9042)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("expected 2 hosts, got %v", hosts)
+	}
+	for i, want := range []string{"127.0.0.1:19042", "127.0.0.2:29042"} {
+		if got := hosts[i].ConnectAddressAndPort(); got != want {
+			t.Errorf("%d: expected %s, got %s", i, want, got)
+		}
+	}
+
+	records = nil
+	if _, err := hostInfo("_cql._tcp.cluster.example.com", 9042); err == nil {
+		t.Error("expected an error without SRV records")
+	}
+}
+
 func TestParseProtocol(t *testing.T) {
 	tests := [...]struct {
 		err   error
@@ -65,3 +100,71 @@ func TestParseProtocol(t *testing.T) {
 		}
 	}
 }
+
+type recordingControlConnObserver []ObservedControlConn
+
+func (r *recordingControlConnObserver) ObserveControlConn(o ObservedControlConn) {
+	*r = append(*r, o)
+}
+
+func TestControlConnObserver(t *testing.T) {
+	var observed recordingControlConnObserver
+	// the control connection is down for a minute on the clock
+	clock := &steppingClock{step: time.Minute}
+	s := &Session{cfg: ClusterConfig{ControlConnObserver: &observed, Clock: clock}}
+	c := createControlConn(s)
+
+	host1 := &HostInfo{hostId: "host1"}
+	host2 := &HostInfo{hostId: "host2"}
+
+	c.established(host1)
+	c.conn.Store(&connHost{host: host1})
+	c.lost(errors.New("heartbeat failed"))
+	// only the first loss is observed
+	c.lost(errors.New("connection closed"))
+	c.established(host2)
+	c.conn.Store(&connHost{host: host2})
+	c.lost(nil)
+	c.established(host2)
+
+	want := []ControlConnEventType{
+		ControlConnEstablished,
+		ControlConnLost,
+		ControlConnMigrated,
+		ControlConnLost,
+		ControlConnEstablished,
+	}
+	if len(observed) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(observed), observed)
+	}
+	for i, o := range observed {
+		if o.Type != want[i] {
+			t.Errorf("event %d: expected %v, got %v", i, want[i], o.Type)
+		}
+	}
+
+	if observed[0].Downtime != 0 || observed[0].PreviousHost != nil {
+		t.Errorf("first connection should have no downtime nor previous host: %+v", observed[0])
+	}
+	if observed[1].Host != host1 || observed[1].Err == nil || observed[1].Err.Error() != "heartbeat failed" {
+		t.Errorf("unexpected lost event: %+v", observed[1])
+	}
+	if observed[2].Host != host2 || observed[2].PreviousHost != host1 || observed[2].Downtime != time.Minute {
+		t.Errorf("unexpected migrated event: %+v", observed[2])
+	}
+	if observed[4].PreviousHost != host2 {
+		t.Errorf("unexpected reestablished event: %+v", observed[4])
+	}
+}
+
+func TestControlConnObserverClosing(t *testing.T) {
+	var observed recordingControlConnObserver
+	s := &Session{cfg: ClusterConfig{ControlConnObserver: &observed}}
+	c := createControlConn(s)
+	c.state = controlConnClosing
+
+	c.lost(errors.New("closed"))
+	if len(observed) != 0 {
+		t.Fatalf("expected no events while closing, got %+v", observed)
+	}
+}
@@ -0,0 +1,277 @@
+// Package cqlsql is a database/sql driver backed by gocql, so that code and
+// middleware built on database/sql can query Cassandra and Scylla clusters.
+//
+// The driver is registered as "cql" and opened with a DSN of the form
+//
+//	cql://[user[:password]@]host[:port][,host[:port]...][/keyspace][?param=value&...]
+//
+// with the parameters:
+//
+//	consistency      default consistency, such as quorum or local_one
+//	timeout          timeout of the queries, such as 5s
+//	connect_timeout  timeout of the initial connection to the nodes
+//	proto_version    version of the native protocol
+//	num_conns        number of connections per host
+//	page_size        default page size of the queries
+//
+// NewConnector uses a ClusterConfig instead, with sql.OpenDB. The connections
+// of a sql.DB share a single gocql.Session, created on the first connection
+// and closed with the DB.
+//
+// Statements are prepared by gocql, so sql.DB.Prepare is cheap. Arguments are
+// either all positional, for "?" markers, or all named with sql.Named, for
+// ":name" markers. Values are passed to gocql unconverted, except for
+// driver.Valuer values. Columns are scanned into int64, float64, bool,
+// string, []byte, time.Time or nil, uuid, varint, decimal and inet columns
+// into their string form, time columns into nanoseconds and the other types,
+// such as collections, into the Go values gocql unmarshals them into.
+//
+// Transactions are not supported, and Cassandra does not report affected
+// rows or insert ids.
+//
+// Example:
+//
+//	db, err := sql.Open("cql", "cql://127.0.0.1/ks?consistency=quorum")
+//	if err != nil {
+//		// handle error
+//	}
+//	defer db.Close()
+//	var name string
+//	err = db.QueryRowContext(ctx, "SELECT name FROM users WHERE id = :id", sql.Named("id", 1)).Scan(&name)
+package cqlsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+func init() {
+	sql.Register("cql", &Driver{})
+}
+
+var (
+	errTransactions = errors.New("cqlsql: transactions are not supported")
+	errMixedArgs    = errors.New("cqlsql: arguments must be either all positional or all named")
+)
+
+// Driver is the database/sql driver registered as "cql".
+type Driver struct{}
+
+var (
+	_ driver.Driver        = (*Driver)(nil)
+	_ driver.DriverContext = (*Driver)(nil)
+)
+
+// Open returns a new connection to the cluster of the DSN. It is called by
+// database/sql only when OpenConnector is not implemented.
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+// OpenConnector parses the DSN and returns a connector to its cluster.
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	cluster, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	c := NewConnector(cluster).(*connector)
+	c.driver = d
+	return c, nil
+}
+
+// NewConnector returns a connector to the cluster, for sql.OpenDB:
+//
+//	db := sql.OpenDB(cqlsql.NewConnector(cluster))
+func NewConnector(cluster *gocql.ClusterConfig) driver.Connector {
+	return &connector{cluster: cluster, driver: &Driver{}}
+}
+
+type connector struct {
+	cluster *gocql.ClusterConfig
+	driver  *Driver
+
+	mu      sync.Mutex
+	session *gocql.Session
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil || c.session.Closed() {
+		session, err := c.cluster.CreateSession()
+		if err != nil {
+			return nil, err
+		}
+		c.session = session
+	}
+	return &conn{session: c.session}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Close closes the session, it is called by sql.DB.Close.
+func (c *connector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil {
+		c.session.Close()
+		c.session = nil
+	}
+	return nil
+}
+
+// conn is a database/sql connection, all the connections of a connector
+// share its session.
+type conn struct {
+	session *gocql.Session
+}
+
+var (
+	_ driver.Conn               = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if c.session.Closed() {
+		return nil, driver.ErrBadConn
+	}
+	// gocql prepares the statements on the nodes when they are executed
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errTransactions
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if c.session.Closed() {
+		return driver.ErrBadConn
+	}
+	return c.session.Query("SELECT now() FROM system.local").WithContext(ctx).Exec()
+}
+
+// CheckNamedValue passes the values to gocql unconverted, so that it marshals
+// them according to the types of the columns.
+func (c *conn) CheckNamedValue(v *driver.NamedValue) error {
+	if _, ok := v.Value.(driver.Valuer); ok {
+		return driver.ErrSkip
+	}
+	return nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	q, err := c.query(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.Exec(); err != nil {
+		return nil, err
+	}
+	return driver.ResultNoRows, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, err := c.query(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(q.Iter())
+}
+
+func (c *conn) query(ctx context.Context, query string, args []driver.NamedValue) (*gocql.Query, error) {
+	if c.session.Closed() {
+		return nil, driver.ErrBadConn
+	}
+	q := c.session.Query(query).WithContext(ctx)
+	if len(args) == 0 {
+		return q, nil
+	}
+
+	if args[0].Name != "" {
+		named := make(map[string]interface{}, len(args))
+		for _, arg := range args {
+			if arg.Name == "" {
+				return nil, errMixedArgs
+			}
+			named[arg.Name] = arg.Value
+		}
+		return q.BindMap(named), nil
+	}
+
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errMixedArgs
+		}
+		values[i] = arg.Value
+	}
+	return q.Bind(values...), nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+var (
+	_ driver.Stmt             = (*stmt)(nil)
+	_ driver.StmtExecContext  = (*stmt)(nil)
+	_ driver.StmtQueryContext = (*stmt)(nil)
+)
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1 as the number of markers is only known once the
+// statement is prepared, gocql checks the number of arguments then.
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
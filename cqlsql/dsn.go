@@ -0,0 +1,96 @@
+package cqlsql
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+const dsnScheme = "cql://"
+
+// parseDSN returns the cluster config of a DSN, see the package documentation
+// for its format.
+func parseDSN(dsn string) (*gocql.ClusterConfig, error) {
+	if !strings.HasPrefix(dsn, dsnScheme) {
+		return nil, fmt.Errorf("cqlsql: DSN must start with %q", dsnScheme)
+	}
+	rest := dsn[len(dsnScheme):]
+
+	var query string
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		rest, query = rest[:i], rest[i+1:]
+	}
+	var userinfo string
+	if i := strings.LastIndexByte(rest, '@'); i >= 0 {
+		userinfo, rest = rest[:i], rest[i+1:]
+	}
+	var keyspace string
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		rest, keyspace = rest[:i], rest[i+1:]
+	}
+
+	var hosts []string
+	for _, host := range strings.Split(rest, ",") {
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("cqlsql: no host in DSN")
+	}
+	cluster := gocql.NewCluster(hosts...)
+	cluster.Keyspace = keyspace
+
+	if userinfo != "" {
+		var password string
+		username := userinfo
+		if i := strings.IndexByte(userinfo, ':'); i >= 0 {
+			username, password = userinfo[:i], userinfo[i+1:]
+		}
+		var err error
+		auth := gocql.PasswordAuthenticator{}
+		if auth.Username, err = url.PathUnescape(username); err != nil {
+			return nil, fmt.Errorf("cqlsql: invalid user in DSN: %v", err)
+		}
+		if auth.Password, err = url.PathUnescape(password); err != nil {
+			return nil, fmt.Errorf("cqlsql: invalid password in DSN: %v", err)
+		}
+		cluster.Authenticator = auth
+	}
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("cqlsql: invalid DSN parameters: %v", err)
+	}
+	for name, values := range params {
+		if err := setParam(cluster, name, values[len(values)-1]); err != nil {
+			return nil, fmt.Errorf("cqlsql: invalid DSN parameter %s: %v", name, err)
+		}
+	}
+	return cluster, nil
+}
+
+func setParam(cluster *gocql.ClusterConfig, name, value string) error {
+	var err error
+	switch name {
+	case "consistency":
+		cluster.Consistency, err = gocql.ParseConsistencyWrapper(value)
+	case "timeout":
+		cluster.Timeout, err = time.ParseDuration(value)
+	case "connect_timeout":
+		cluster.ConnectTimeout, err = time.ParseDuration(value)
+	case "proto_version":
+		cluster.ProtoVersion, err = strconv.Atoi(value)
+	case "num_conns":
+		cluster.NumConns, err = strconv.Atoi(value)
+	case "page_size":
+		cluster.PageSize, err = strconv.Atoi(value)
+	default:
+		err = fmt.Errorf("unknown parameter")
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package cqlsql
+
+import (
+	"database/sql/driver"
+	"io"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+)
+
+type rows struct {
+	iter    *gocql.Iter
+	columns []gocql.ColumnInfo
+	// values are pointers to pointers to the Go types of the columns, so that
+	// null values are told apart from zero values
+	values []interface{}
+}
+
+var (
+	_ driver.Rows                           = (*rows)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*rows)(nil)
+)
+
+func newRows(iter *gocql.Iter) (*rows, error) {
+	columns := iter.Columns()
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		v, err := column.TypeInfo.NewWithError()
+		if err != nil {
+			iter.Close()
+			return nil, err
+		}
+		values[i] = reflect.New(reflect.TypeOf(v)).Interface()
+	}
+	return &rows{iter: iter, columns: columns, values: values}, nil
+}
+
+func (r *rows) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(r.columns[index].TypeInfo.Type().String())
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if !r.iter.Scan(r.values...) {
+		if err := r.iter.Close(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	for i, v := range r.values {
+		ptr := reflect.ValueOf(v).Elem()
+		if ptr.IsNil() {
+			dest[i] = nil
+			continue
+		}
+		dest[i] = driverValue(ptr.Elem().Interface())
+	}
+	return nil
+}
+
+func (r *rows) Close() error {
+	return r.iter.Close()
+}
+
+// driverValue converts the scalar values unmarshaled by gocql to the types of
+// driver.Value, other values are returned as is.
+func driverValue(v interface{}) driver.Value {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case time.Duration:
+		return int64(v)
+	case gocql.UUID:
+		return v.String()
+	case *big.Int:
+		return v.String()
+	case *inf.Dec:
+		return v.String()
+	case net.IP:
+		return v.String()
+	}
+	return v
+}
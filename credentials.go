@@ -0,0 +1,128 @@
+package gocql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Credentials are the username and password of a PasswordAuthenticator.
+type Credentials struct {
+	Username string
+	Password string
+	// Expiry is when the credentials expire, such as the end of the lease
+	// of dynamic credentials, or zero if they do not.
+	Expiry time.Time
+}
+
+// CredentialSource provides the credentials of a PasswordAuthenticator, for
+// example from a secret store such as Vault.
+type CredentialSource interface {
+	// Credentials returns the current credentials. ctx is the context of
+	// the connection being established.
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialSourceFunc is a function implementing CredentialSource.
+type CredentialSourceFunc func(ctx context.Context) (Credentials, error)
+
+func (f CredentialSourceFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// CachedCredentials is a CredentialSource caching the credentials of another
+// one, which are fetched lazily when a connection needs them and refreshed
+// once they expire or are older than MaxAge. When the cluster rejects the
+// credentials, the cache is invalidated so that the next connections fetch
+// rotated credentials.
+//
+// A CachedCredentials is safe for concurrent use by multiple goroutines.
+type CachedCredentials struct {
+	source CredentialSource
+	// MaxAge is how long credentials are cached for, 0 for as long as they
+	// do not expire.
+	MaxAge time.Duration
+	// RefreshBefore is how long before their expiry credentials are
+	// refreshed, so that connections are not established with credentials
+	// about to expire. (default: 1 minute)
+	RefreshBefore time.Duration
+
+	mu          sync.Mutex
+	credentials Credentials
+	fetched     time.Time
+	valid       bool
+}
+
+// NewCachedCredentials returns a cache of the credentials of source.
+func NewCachedCredentials(source CredentialSource, maxAge time.Duration) *CachedCredentials {
+	return &CachedCredentials{
+		source:        source,
+		MaxAge:        maxAge,
+		RefreshBefore: time.Minute,
+	}
+}
+
+// Credentials returns the cached credentials, fetching them from the source
+// if they are missing or stale. If the source fails, credentials which have
+// not expired yet are returned.
+func (c *CachedCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.valid && !c.stale(now) {
+		return c.credentials, nil
+	}
+
+	credentials, err := c.source.Credentials(ctx)
+	if err != nil {
+		if c.valid && (c.credentials.Expiry.IsZero() || now.Before(c.credentials.Expiry)) {
+			return c.credentials, nil
+		}
+		return Credentials{}, err
+	}
+	c.credentials = credentials
+	c.fetched = now
+	c.valid = true
+	return credentials, nil
+}
+
+func (c *CachedCredentials) stale(now time.Time) bool {
+	if c.MaxAge > 0 && now.Sub(c.fetched) >= c.MaxAge {
+		return true
+	}
+	return !c.credentials.Expiry.IsZero() && !now.Before(c.credentials.Expiry.Add(-c.RefreshBefore))
+}
+
+// Invalidate drops the cached credentials, so that the next call to
+// Credentials fetches them from the source.
+func (c *CachedCredentials) Invalidate() {
+	c.mu.Lock()
+	c.valid = false
+	c.mu.Unlock()
+}
+
+// withCredentials returns p with the credentials of its source, if any.
+func (p PasswordAuthenticator) withCredentials(ctx context.Context) (PasswordAuthenticator, error) {
+	if p.Source == nil {
+		return p, nil
+	}
+	credentials, err := p.Source.Credentials(ctx)
+	if err != nil {
+		return p, fmt.Errorf("gocql: unable to fetch credentials: %w", err)
+	}
+	p.Username, p.Password = credentials.Username, credentials.Password
+	p.Source = nil
+	return p, nil
+}
+
+// invalidateCredentials invalidates the credentials of the source of auth,
+// after the cluster rejected them.
+func invalidateCredentials(auth Authenticator) {
+	if p, ok := auth.(PasswordAuthenticator); ok {
+		if source, ok := p.Source.(interface{ Invalidate() }); ok {
+			source.Invalidate()
+		}
+	}
+}
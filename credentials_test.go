@@ -0,0 +1,81 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCachedCredentials(t *testing.T) {
+	var (
+		fetches int
+		expiry  time.Time
+		fail    error
+	)
+	cache := NewCachedCredentials(CredentialSourceFunc(func(ctx context.Context) (Credentials, error) {
+		if fail != nil {
+			return Credentials{}, fail
+		}
+		fetches++
+		return Credentials{Username: "user", Password: string(rune('0' + fetches)), Expiry: expiry}, nil
+	}), 0)
+
+	password := func() string {
+		credentials, err := cache.Credentials(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return credentials.Password
+	}
+	if password() != "1" || password() != "1" {
+		t.Fatalf("expected the credentials to be cached, fetched %d times", fetches)
+	}
+
+	cache.Invalidate()
+	if password() != "2" {
+		t.Fatalf("expected the credentials to be fetched after invalidation")
+	}
+
+	// credentials expiring within RefreshBefore are refreshed
+	expiry = time.Now().Add(time.Hour)
+	cache.Invalidate()
+	password()
+	cache.RefreshBefore = 2 * time.Hour
+	if password() != "4" {
+		t.Fatalf("expected the expiring credentials to be refreshed")
+	}
+
+	// credentials which did not expire yet are kept while the source fails
+	fail = errors.New("sealed")
+	if password() != "4" {
+		t.Fatalf("expected the cached credentials")
+	}
+	cache.Invalidate()
+	if _, err := cache.Credentials(context.Background()); err != fail {
+		t.Fatalf("expected %v, got %v", fail, err)
+	}
+}
+
+func TestPasswordAuthenticatorSource(t *testing.T) {
+	cache := NewCachedCredentials(CredentialSourceFunc(func(ctx context.Context) (Credentials, error) {
+		return Credentials{Username: "user", Password: "pass"}, nil
+	}), time.Hour)
+	auth := PasswordAuthenticator{Source: cache}
+
+	resp, _, err := auth.Challenge([]byte("org.apache.cassandra.auth.PasswordAuthenticator"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != "\x00user\x00pass" {
+		t.Errorf("unexpected response %q", resp)
+	}
+
+	invalidateCredentials(auth)
+	if cache.valid {
+		t.Error("expected the credentials to be invalidated")
+	}
+}
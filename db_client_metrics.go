@@ -0,0 +1,158 @@
+package gocql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The names of the database client metrics of the OpenTelemetry semantic
+// conventions recorded by DBClientMetrics.
+const (
+	// MetricOperationDuration is the histogram of the durations in seconds
+	// of the attempts to execute queries and batches.
+	MetricOperationDuration = "db.client.operation.duration"
+	// MetricConnectionCount is the gauge of the open connections of a pool
+	// by state, idle or used.
+	MetricConnectionCount = "db.client.connection.count"
+	// MetricConnectionMax is the gauge of the size of a pool.
+	MetricConnectionMax = "db.client.connection.max"
+	// MetricPendingRequests is the gauge of the requests of a pool waiting
+	// for a response.
+	MetricPendingRequests = "db.client.connection.pending_requests"
+)
+
+// MetricAttribute is an attribute of a metric, named after the OpenTelemetry
+// semantic conventions, such as db.operation.name.
+type MetricAttribute struct {
+	Key   string
+	Value string
+}
+
+// MetricsRecorder records the metrics of DBClientMetrics, typically into the
+// instruments of an OpenTelemetry meter, created once per name.
+type MetricsRecorder interface {
+	// RecordHistogram records value in the histogram name.
+	RecordHistogram(ctx context.Context, name string, value float64, attrs []MetricAttribute)
+	// RecordGauge records the current value of the gauge name.
+	RecordGauge(ctx context.Context, name string, value int64, attrs []MetricAttribute)
+}
+
+// DBClientMetrics produces the database client metrics of the OpenTelemetry
+// semantic conventions. Set it as the QueryObserver and BatchObserver of a
+// session to record the duration of operations, their errors being recorded
+// as the error.type attribute, and call RecordPools periodically, for
+// example from an OpenTelemetry callback, to record the usage of the
+// connection pools.
+type DBClientMetrics struct {
+	recorder MetricsRecorder
+}
+
+// NewDBClientMetrics returns the metrics of sessions recorded by recorder.
+func NewDBClientMetrics(recorder MetricsRecorder) *DBClientMetrics {
+	return &DBClientMetrics{recorder: recorder}
+}
+
+// ObserveQuery records the duration of an attempt to execute a query.
+func (m *DBClientMetrics) ObserveQuery(ctx context.Context, q ObservedQuery) {
+	keyspace, table := statementTable(q.Statement)
+	if keyspace == "" {
+		keyspace = q.Keyspace
+	}
+	attrs := operationAttributes(statementOperation(q.Statement), keyspace, table, q.Host, q.Err)
+	m.recorder.RecordHistogram(ctx, MetricOperationDuration, q.End.Sub(q.Start).Seconds(), attrs)
+}
+
+// ObserveBatch records the duration of an attempt to execute a batch.
+func (m *DBClientMetrics) ObserveBatch(ctx context.Context, b ObservedBatch) {
+	attrs := operationAttributes("BATCH", b.Keyspace, "", b.Host, b.Err)
+	m.recorder.RecordHistogram(ctx, MetricOperationDuration, b.End.Sub(b.Start).Seconds(), attrs)
+}
+
+// RecordPools records the connections and pending requests of the pools of
+// the session, named after the address of their host.
+func (m *DBClientMetrics) RecordPools(ctx context.Context, s *Session) {
+	for _, host := range s.ring.allHosts() {
+		pool, ok := s.pool.getPool(host)
+		if !ok {
+			continue
+		}
+		size, idle, used, pending := pool.usage()
+		name := MetricAttribute{Key: "db.client.connection.pool.name", Value: host.ConnectAddressAndPort()}
+		m.recorder.RecordGauge(ctx, MetricConnectionCount, int64(idle), []MetricAttribute{
+			name, {Key: "db.client.connection.state", Value: "idle"},
+		})
+		m.recorder.RecordGauge(ctx, MetricConnectionCount, int64(used), []MetricAttribute{
+			name, {Key: "db.client.connection.state", Value: "used"},
+		})
+		m.recorder.RecordGauge(ctx, MetricConnectionMax, int64(size), []MetricAttribute{name})
+		m.recorder.RecordGauge(ctx, MetricPendingRequests, int64(pending), []MetricAttribute{name})
+	}
+}
+
+// usage returns the size of the pool, its numbers of connections without
+// and with requests in flight, and the number of requests in flight.
+func (pool *hostConnPool) usage() (size, idle, used, pending int) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	for _, conn := range pool.conns {
+		inFlight := conn.streams.NumStreams - 1 - conn.AvailableStreams()
+		if inFlight > 0 {
+			used++
+		} else {
+			idle++
+		}
+		pending += inFlight
+	}
+	return pool.size, idle, used, pending
+}
+
+func operationAttributes(operation, keyspace, table string, host *HostInfo, err error) []MetricAttribute {
+	attrs := []MetricAttribute{{Key: "db.system", Value: "cassandra"}}
+	if operation != "" {
+		attrs = append(attrs, MetricAttribute{Key: "db.operation.name", Value: operation})
+	}
+	if keyspace != "" {
+		attrs = append(attrs, MetricAttribute{Key: "db.namespace", Value: keyspace})
+	}
+	if table != "" {
+		attrs = append(attrs, MetricAttribute{Key: "db.collection.name", Value: table})
+	}
+	if host != nil {
+		attrs = append(attrs,
+			MetricAttribute{Key: "server.address", Value: host.ConnectAddress().String()},
+			MetricAttribute{Key: "server.port", Value: strconv.Itoa(host.Port())},
+		)
+		if dc := host.DataCenter(); dc != "" {
+			attrs = append(attrs, MetricAttribute{Key: "db.cassandra.coordinator.dc", Value: dc})
+		}
+	}
+	if err != nil {
+		attrs = append(attrs, MetricAttribute{Key: "error.type", Value: errorType(err)})
+	}
+	return attrs
+}
+
+// statementOperation returns the upper case first keyword of stmt.
+func statementOperation(stmt string) string {
+	tok, quoted, _ := nextToken(stmt, 0)
+	if quoted || tok == "" || !isIdentStart(tok[0]) {
+		return ""
+	}
+	return strings.ToUpper(tok)
+}
+
+// errorType returns a low cardinality description of err: the hexadecimal
+// code of server errors, or its Go type.
+func errorType(err error) string {
+	var reqErr RequestError
+	if errors.As(err, &reqErr) {
+		return fmt.Sprintf("0x%04X", reqErr.Code())
+	}
+	if errors.Is(err, ErrTimeoutNoResponse) {
+		return "timeout"
+	}
+	return fmt.Sprintf("%T", err)
+}
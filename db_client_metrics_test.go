@@ -0,0 +1,104 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type recordedMetric struct {
+	name  string
+	value float64
+	attrs map[string]string
+}
+
+type testMetricsRecorder struct {
+	mu      sync.Mutex
+	metrics []recordedMetric
+}
+
+func (r *testMetricsRecorder) record(name string, value float64, attrs []MetricAttribute) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := recordedMetric{name: name, value: value, attrs: make(map[string]string)}
+	for _, attr := range attrs {
+		m.attrs[attr.Key] = attr.Value
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+func (r *testMetricsRecorder) RecordHistogram(ctx context.Context, name string, value float64, attrs []MetricAttribute) {
+	r.record(name, value, attrs)
+}
+
+func (r *testMetricsRecorder) RecordGauge(ctx context.Context, name string, value int64, attrs []MetricAttribute) {
+	r.record(name, float64(value), attrs)
+}
+
+func (r *testMetricsRecorder) recorded() []recordedMetric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]recordedMetric(nil), r.metrics...)
+}
+
+func TestDBClientMetrics(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	recorder := &testMetricsRecorder{}
+	metrics := NewDBClientMetrics(recorder)
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 2
+	cluster.QueryObserver = metrics
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Query("void FROM ks.tbl").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Query("kill").Exec(); err == nil {
+		t.Fatal("expected error")
+	}
+
+	recorded := recorder.recorded()
+	if len(recorded) != 2 {
+		t.Fatalf("expected 2 operations, got %v", recorded)
+	}
+	expected := []map[string]string{
+		{"db.operation.name": "VOID", "db.namespace": "ks", "db.collection.name": "tbl"},
+		{"db.operation.name": "KILL", "error.type": "0x1001"},
+	}
+	for i, m := range recorded {
+		if m.name != MetricOperationDuration || m.value <= 0 {
+			t.Errorf("operation %d: unexpected metric %v", i, m)
+		}
+		if m.attrs["db.system"] != "cassandra" || m.attrs["server.address"] != "127.0.0.1" || m.attrs["server.port"] == "" {
+			t.Errorf("operation %d: unexpected attributes %v", i, m.attrs)
+		}
+		for key, value := range expected[i] {
+			if m.attrs[key] != value {
+				t.Errorf("operation %d: expected %s=%s, got %v", i, key, value, m.attrs)
+			}
+		}
+	}
+
+	recorder.metrics = nil
+	metrics.RecordPools(context.Background(), db)
+	gauges := make(map[string]float64)
+	for _, m := range recorder.recorded() {
+		if m.attrs["db.client.connection.pool.name"] != srv.Address {
+			t.Errorf("unexpected pool %v", m.attrs)
+		}
+		gauges[m.name+" "+m.attrs["db.client.connection.state"]] = m.value
+	}
+	if gauges[MetricConnectionMax+" "] != 2 || gauges[MetricConnectionCount+" idle"] != 2 ||
+		gauges[MetricConnectionCount+" used"] != 0 || gauges[MetricPendingRequests+" "] != 0 {
+		t.Errorf("unexpected pool metrics %v", gauges)
+	}
+}
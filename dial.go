@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"time"
 )
 
 // HostDialer allows customizing connection to cluster nodes.
@@ -31,8 +32,53 @@ type DialedHost struct {
 
 // defaultHostDialer dials host in a default way.
 type defaultHostDialer struct {
-	dialer    Dialer
-	tlsConfig *tls.Config
+	dialer      Dialer
+	tlsConfig   *tls.Config
+	tlsObserver TLSHandshakeObserver
+	sockOpts    socketOptions
+}
+
+// socketOptions are applied to TCP connections once they are established.
+type socketOptions struct {
+	readBufferSize  int
+	writeBufferSize int
+	noDelay         bool
+	// keepaliveInterval and keepaliveCount are set after the connection is
+	// established, as net.Dialer overwrites them with its defaults when it
+	// enables keepalives.
+	keepaliveInterval time.Duration
+	keepaliveCount    int
+}
+
+func (o socketOptions) apply(conn net.Conn) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+
+	if err := tcpConn.SetNoDelay(o.noDelay); err != nil {
+		return err
+	}
+	if o.readBufferSize > 0 {
+		if err := tcpConn.SetReadBuffer(o.readBufferSize); err != nil {
+			return err
+		}
+	}
+	if o.writeBufferSize > 0 {
+		if err := tcpConn.SetWriteBuffer(o.writeBufferSize); err != nil {
+			return err
+		}
+	}
+	if o.keepaliveInterval > 0 || o.keepaliveCount > 0 {
+		rawConn, err := tcpConn.SyscallConn()
+		if err != nil {
+			return err
+		}
+		if err := setKeepaliveParams(rawConn, o.keepaliveInterval, o.keepaliveCount); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (hd *defaultHostDialer) DialHost(ctx context.Context, host *HostInfo) (*DialedHost, error) {
@@ -50,8 +96,11 @@ func (hd *defaultHostDialer) DialHost(ctx context.Context, host *HostInfo) (*Dia
 	if err != nil {
 		return nil, err
 	}
-	addr := host.HostnameAndPort()
-	return WrapTLS(ctx, conn, addr, hd.tlsConfig)
+	if err := hd.sockOpts.apply(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to set socket options: %v", err)
+	}
+	return hd.wrapTLS(ctx, conn, host)
 }
 
 func tlsConfigForAddr(tlsConfig *tls.Config, addr string) *tls.Config {
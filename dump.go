@@ -0,0 +1,149 @@
+package gocql
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FrameDumper writes the headers of the frames sent to and received from the
+// nodes, and optionally their bodies, to a writer in order to diagnose
+// protocol issues. It is set with ClusterConfig.FrameDumper and is disabled
+// until Enable is called, it can be toggled at any time while the session is
+// in use.
+//
+// Bodies of sent frames are dumped as written, after compression, bodies of
+// received frames once decompressed.
+type FrameDumper struct {
+	enabled int32
+
+	mu      sync.Mutex
+	w       io.Writer
+	bodies  bool
+	hosts   map[string]struct{}
+	streams map[int]struct{}
+}
+
+// NewFrameDumper returns a disabled dumper writing to w.
+func NewFrameDumper(w io.Writer) *FrameDumper {
+	return &FrameDumper{w: w}
+}
+
+// Enable starts dumping frames.
+func (d *FrameDumper) Enable() {
+	atomic.StoreInt32(&d.enabled, 1)
+}
+
+// Disable stops dumping frames.
+func (d *FrameDumper) Disable() {
+	atomic.StoreInt32(&d.enabled, 0)
+}
+
+// Enabled reports whether frames are dumped.
+func (d *FrameDumper) Enabled() bool {
+	return d != nil && atomic.LoadInt32(&d.enabled) == 1
+}
+
+// DumpBodies sets whether the bodies of the frames are dumped in hexadecimal
+// in addition to their headers.
+func (d *FrameDumper) DumpBodies(enable bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.bodies = enable
+}
+
+// FilterHosts restricts the dump to the frames exchanged with the hosts,
+// given by their address with or without port. All hosts are dumped if none
+// is given.
+func (d *FrameDumper) FilterHosts(addrs ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hosts = nil
+	if len(addrs) > 0 {
+		d.hosts = make(map[string]struct{}, len(addrs))
+		for _, addr := range addrs {
+			d.hosts[addr] = struct{}{}
+		}
+	}
+}
+
+// FilterStreams restricts the dump to the frames of the streams. All streams
+// are dumped if none is given.
+func (d *FrameDumper) FilterStreams(streams ...int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.streams = nil
+	if len(streams) > 0 {
+		d.streams = make(map[int]struct{}, len(streams))
+		for _, stream := range streams {
+			d.streams[stream] = struct{}{}
+		}
+	}
+}
+
+func (d *FrameDumper) selected(host *HostInfo, stream int) bool {
+	if d.streams != nil {
+		if _, ok := d.streams[stream]; !ok {
+			return false
+		}
+	}
+	if d.hosts != nil {
+		if host == nil {
+			return false
+		}
+		_, ok := d.hosts[host.ConnectAddress().String()]
+		if !ok {
+			_, ok = d.hosts[host.ConnectAddressAndPort()]
+		}
+		return ok
+	}
+	return true
+}
+
+// dumpSent dumps a frame written to the host, with its header.
+func (d *FrameDumper) dumpSent(host *HostInfo, frame []byte) {
+	if !d.Enabled() {
+		return
+	}
+	var p [9]byte
+	head, err := readHeader(bytes.NewReader(frame), p[:])
+	if err != nil {
+		return
+	}
+	if head.length > len(frame) {
+		return
+	}
+	d.dump("->", host, head, frame[len(frame)-head.length:])
+}
+
+// dumpReceived dumps a frame read from the host.
+func (d *FrameDumper) dumpReceived(host *HostInfo, head frameHeader, body []byte) {
+	if !d.Enabled() {
+		return
+	}
+	d.dump("<-", host, head, body)
+}
+
+func (d *FrameDumper) dump(direction string, host *HostInfo, head frameHeader, body []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.selected(host, head.stream) {
+		return
+	}
+
+	addr := "<unknown>"
+	if host != nil {
+		addr = host.ConnectAddressAndPort()
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "gocql: %s %s %s version=%d flags=0x%x stream=%d op=%s length=%d\n",
+		time.Now().Format(time.RFC3339Nano), direction, addr, head.version.version(), head.flags, head.stream, head.op, head.length)
+	if d.bodies && len(body) > 0 {
+		buf.WriteString(hex.Dump(body))
+	}
+	d.w.Write(buf.Bytes())
+}
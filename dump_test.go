@@ -0,0 +1,101 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// take returns the content of the buffer and empties it.
+func (b *syncBuffer) take() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.buf.String()
+	b.buf.Reset()
+	return s
+}
+
+func TestFrameDumper(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	out := &syncBuffer{}
+	dumper := NewFrameDumper(out)
+	cluster := testCluster(protoVersion4, srv.Address)
+	cluster.NumConns = 1
+	cluster.FrameDumper = dumper
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	exec := func() string {
+		t.Helper()
+		out.take()
+		if err := db.Query("void").Exec(); err != nil {
+			t.Fatal(err)
+		}
+		return out.take()
+	}
+
+	if dump := exec(); dump != "" {
+		t.Fatalf("expected no dump while disabled got %q", dump)
+	}
+
+	dumper.Enable()
+	dump := exec()
+	for _, expected := range []string{
+		"-> " + srv.Address + " version=4 flags=0x0 stream=",
+		" op=QUERY length=",
+		"<- " + srv.Address + " version=4 flags=0x0 stream=",
+		" op=RESULT length=4\n",
+	} {
+		if !strings.Contains(dump, expected) {
+			t.Fatalf("expected %q in dump %q", expected, dump)
+		}
+	}
+	if strings.Contains(dump, "void") {
+		t.Fatalf("expected no bodies in dump %q", dump)
+	}
+
+	dumper.DumpBodies(true)
+	if dump := exec(); !strings.Contains(dump, "|....void") {
+		t.Fatalf("expected the query body in dump %q", dump)
+	}
+
+	dumper.FilterHosts("10.0.0.1")
+	if dump := exec(); dump != "" {
+		t.Fatalf("expected no dump for other hosts got %q", dump)
+	}
+	dumper.FilterHosts(srv.Address)
+	dumper.FilterStreams(-1)
+	if dump := exec(); dump != "" {
+		t.Fatalf("expected no dump for other streams got %q", dump)
+	}
+	dumper.FilterStreams()
+	if dump := exec(); dump == "" {
+		t.Fatal("expected a dump once the stream filter was removed")
+	}
+
+	dumper.Disable()
+	if dump := exec(); dump != "" {
+		t.Fatalf("expected no dump once disabled got %q", dump)
+	}
+}
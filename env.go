@@ -0,0 +1,141 @@
+package gocql
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ApplyEnvOverrides overlays the settings of environment variables named
+// after prefix onto cfg, such as CASSANDRA_HOSTS for the prefix
+// "CASSANDRA_". The variables which are unset or empty leave cfg unchanged.
+//
+//	HOSTS                  Hosts, separated by commas
+//	PORT                   Port
+//	KEYSPACE               Keyspace
+//	USERNAME, PASSWORD     the credentials of a PasswordAuthenticator
+//	CONSISTENCY            Consistency, as in LOCAL_QUORUM
+//	SERIAL_CONSISTENCY     SerialConsistency, as in LOCAL_SERIAL
+//	TIMEOUT                Timeout, as in 600ms
+//	CONNECT_TIMEOUT        ConnectTimeout
+//	WRITE_TIMEOUT          WriteTimeout
+//	PROTO_VERSION          ProtoVersion
+//	NUM_CONNS              NumConns
+//	PAGE_SIZE              PageSize
+//	TLS_CERT_PATH          SslOpts.CertPath
+//	TLS_KEY_PATH           SslOpts.KeyPath
+//	TLS_CA_PATH            SslOpts.CaPath
+//	TLS_HOST_VERIFICATION  SslOpts.EnableHostVerification, as in true
+//
+// The credentials update a PasswordAuthenticator set as Authenticator,
+// keeping its AllowedAuthenticators, or replace any other Authenticator.
+// Setting any TLS variable enables TLS.
+//
+// An error is returned for the first invalid variable, the variables before
+// it having been applied.
+func (cfg *ClusterConfig) ApplyEnvOverrides(prefix string) error {
+	env := func(name string) string {
+		return os.Getenv(prefix + name)
+	}
+
+	if hosts := env("HOSTS"); hosts != "" {
+		cfg.Hosts = nil
+		for _, host := range strings.Split(hosts, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				cfg.Hosts = append(cfg.Hosts, host)
+			}
+		}
+	}
+	if keyspace := env("KEYSPACE"); keyspace != "" {
+		cfg.Keyspace = keyspace
+	}
+
+	username, password := env("USERNAME"), env("PASSWORD")
+	if username != "" || password != "" {
+		auth, _ := cfg.Authenticator.(PasswordAuthenticator)
+		if username != "" {
+			auth.Username = username
+		}
+		if password != "" {
+			auth.Password = password
+		}
+		cfg.Authenticator = auth
+	}
+
+	ints := []struct {
+		name  string
+		value *int
+	}{
+		{"PORT", &cfg.Port},
+		{"PROTO_VERSION", &cfg.ProtoVersion},
+		{"NUM_CONNS", &cfg.NumConns},
+		{"PAGE_SIZE", &cfg.PageSize},
+	}
+	for _, v := range ints {
+		if s := env(v.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("gocql: invalid %s%s: %v", prefix, v.name, err)
+			}
+			*v.value = n
+		}
+	}
+
+	durations := []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"TIMEOUT", &cfg.Timeout},
+		{"CONNECT_TIMEOUT", &cfg.ConnectTimeout},
+		{"WRITE_TIMEOUT", &cfg.WriteTimeout},
+	}
+	for _, v := range durations {
+		if s := env(v.name); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("gocql: invalid %s%s: %v", prefix, v.name, err)
+			}
+			*v.value = d
+		}
+	}
+
+	if s := env("CONSISTENCY"); s != "" {
+		consistency, err := ParseConsistencyWrapper(s)
+		if err != nil {
+			return fmt.Errorf("gocql: invalid %sCONSISTENCY: %v", prefix, err)
+		}
+		cfg.Consistency = consistency
+	}
+	if s := env("SERIAL_CONSISTENCY"); s != "" {
+		if err := cfg.SerialConsistency.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("gocql: invalid %sSERIAL_CONSISTENCY: %v", prefix, err)
+		}
+	}
+
+	certPath, keyPath, caPath := env("TLS_CERT_PATH"), env("TLS_KEY_PATH"), env("TLS_CA_PATH")
+	hostVerification := env("TLS_HOST_VERIFICATION")
+	if certPath != "" || keyPath != "" || caPath != "" || hostVerification != "" {
+		if cfg.SslOpts == nil {
+			cfg.SslOpts = &SslOptions{}
+		}
+		if certPath != "" {
+			cfg.SslOpts.CertPath = certPath
+		}
+		if keyPath != "" {
+			cfg.SslOpts.KeyPath = keyPath
+		}
+		if caPath != "" {
+			cfg.SslOpts.CaPath = caPath
+		}
+		if hostVerification != "" {
+			verify, err := strconv.ParseBool(hostVerification)
+			if err != nil {
+				return fmt.Errorf("gocql: invalid %sTLS_HOST_VERIFICATION: %v", prefix, err)
+			}
+			cfg.SslOpts.EnableHostVerification = verify
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package gocql
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func setEnv(t *testing.T, env map[string]string) func() {
+	for name, value := range env {
+		if err := os.Setenv(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for name := range env {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	defer setEnv(t, map[string]string{
+		"TEST_CQL_HOSTS":                 "10.0.0.1, 10.0.0.2:19042",
+		"TEST_CQL_KEYSPACE":              "ks",
+		"TEST_CQL_PASSWORD":              "secret",
+		"TEST_CQL_CONSISTENCY":           "LOCAL_QUORUM",
+		"TEST_CQL_SERIAL_CONSISTENCY":    "LOCAL_SERIAL",
+		"TEST_CQL_TIMEOUT":               "2s",
+		"TEST_CQL_NUM_CONNS":             "4",
+		"TEST_CQL_TLS_CA_PATH":           "/etc/ca.pem",
+		"TEST_CQL_TLS_HOST_VERIFICATION": "true",
+		"TEST_CQL_PAGE_SIZE":             "",
+	})()
+
+	cfg := NewCluster("127.0.0.1")
+	cfg.Authenticator = PasswordAuthenticator{Username: "user", Password: "password", AllowedAuthenticators: []string{"a"}}
+	if err := cfg.ApplyEnvOverrides("TEST_CQL_"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cfg.Hosts, []string{"10.0.0.1", "10.0.0.2:19042"}) {
+		t.Errorf("unexpected hosts %v", cfg.Hosts)
+	}
+	expectedAuth := PasswordAuthenticator{Username: "user", Password: "secret", AllowedAuthenticators: []string{"a"}}
+	if !reflect.DeepEqual(cfg.Authenticator, expectedAuth) {
+		t.Errorf("expected authenticator %+v, got %+v", expectedAuth, cfg.Authenticator)
+	}
+	if cfg.Keyspace != "ks" || cfg.Consistency != LocalQuorum || cfg.SerialConsistency != LocalSerial ||
+		cfg.Timeout != 2*time.Second || cfg.NumConns != 4 || cfg.PageSize != 5000 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if cfg.SslOpts == nil || cfg.SslOpts.CaPath != "/etc/ca.pem" || !cfg.SslOpts.EnableHostVerification {
+		t.Errorf("unexpected TLS options %+v", cfg.SslOpts)
+	}
+}
+
+func TestApplyEnvOverridesInvalid(t *testing.T) {
+	defer setEnv(t, map[string]string{"TEST_CQL_CONNECT_TIMEOUT": "soon"})()
+
+	cfg := NewCluster()
+	if err := cfg.ApplyEnvOverrides("TEST_CQL_"); err == nil {
+		t.Error("expected an error for an invalid duration")
+	}
+}
@@ -0,0 +1,168 @@
+package gocql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QueryError is the error of a query or batch with the context of its
+// execution, returned when ClusterConfig.ErrorContext is enabled. Use
+// errors.Is and errors.As to handle the error it wraps.
+type QueryError struct {
+	// Statement is the fingerprint of the statement, its literals replaced by
+	// ? and its comments removed, or of the statements of a batch separated
+	// by semicolons.
+	Statement   string
+	Keyspace    string
+	Table       string
+	Consistency Consistency
+	// Host is the coordinator of the last attempt, nil if the statement was
+	// not sent.
+	Host *HostInfo
+	// Values are the values bound to a query, only set when
+	// ClusterConfig.ErrorContextValues is enabled.
+	Values []interface{}
+	// Name and Tags are the name and tags of a query.
+	Name string
+	Tags map[string]string
+
+	Err error
+}
+
+func (e *QueryError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v [", e.Err)
+	if e.Name != "" {
+		fmt.Fprintf(&b, "name=%q ", e.Name)
+	}
+	fmt.Fprintf(&b, "statement=%q keyspace=%q table=%q consistency=%v", e.Statement, e.Keyspace, e.Table, e.Consistency)
+	if e.Host != nil {
+		fmt.Fprintf(&b, " host=%s", e.Host.ConnectAddressAndPort())
+	}
+	if e.Values != nil {
+		fmt.Fprintf(&b, " values=%v", e.Values)
+	}
+	if e.Tags != nil {
+		fmt.Fprintf(&b, " tags=%v", e.Tags)
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// withErrorContext wraps the error of iter, the result of qry, in a
+// *QueryError if the session is configured so.
+func (s *Session) withErrorContext(qry ExecutableQuery, iter *Iter) *Iter {
+	if !s.cfg.ErrorContext || iter.err == nil || iter.err == ErrNotFound {
+		return iter
+	}
+
+	qerr := &QueryError{
+		Keyspace:    qry.Keyspace(),
+		Table:       qry.Table(),
+		Consistency: qry.GetConsistency(),
+		Host:        iter.host,
+		Err:         iter.err,
+	}
+	switch qry := qry.(type) {
+	case *Query:
+		qerr.Statement = statementFingerprint(qry.stmt)
+		qerr.Name, qerr.Tags = qry.name, qry.tags
+		if s.cfg.ErrorContextValues {
+			qerr.Values = qry.values
+		}
+	case *Batch:
+		stmts := make([]string, len(qry.Entries))
+		for i, entry := range qry.Entries {
+			stmts[i] = statementFingerprint(entry.Stmt)
+		}
+		qerr.Statement = strings.Join(stmts, "; ")
+	}
+	iter.err = qerr
+	return iter
+}
+
+// statementFingerprint returns stmt with its string, number, blob and UUID
+// literals replaced by ?, its comments removed and its whitespaces collapsed.
+// UUIDs starting with a letter are not recognized.
+func statementFingerprint(stmt string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(stmt); {
+		c := stmt[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			space = true
+			i++
+			continue
+		}
+
+		var end int
+		switch {
+		case strings.HasPrefix(stmt[i:], "--") || strings.HasPrefix(stmt[i:], "//"):
+			end = strings.IndexByte(stmt[i:], '\n')
+			if end < 0 {
+				end = len(stmt)
+			} else {
+				end += i + 1
+			}
+			space = true
+			i = end
+			continue
+		case strings.HasPrefix(stmt[i:], "/*"):
+			end = strings.Index(stmt[i+2:], "*/")
+			if end < 0 {
+				end = len(stmt)
+			} else {
+				end += i + 4
+			}
+			space = true
+			i = end
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+
+		switch {
+		case c == '\'':
+			b.WriteByte('?')
+			end = skipQuoted(stmt, i, '\'') + 1
+		case c == '$' && strings.HasPrefix(stmt[i:], "$$"):
+			b.WriteByte('?')
+			end = strings.Index(stmt[i+2:], "$$")
+			if end < 0 {
+				end = len(stmt)
+			} else {
+				end += i + 4
+			}
+		case c >= '0' && c <= '9':
+			b.WriteByte('?')
+			end = i + 1
+			for end < len(stmt) && (isIdentChar(stmt[end]) || stmt[end] == '.' || stmt[end] == '-') {
+				end++
+			}
+		case c == '"':
+			end = skipQuoted(stmt, i, '"') + 1
+			if end > len(stmt) {
+				end = len(stmt)
+			}
+			b.WriteString(stmt[i:end])
+		case isIdentStart(c):
+			end = i + 1
+			for end < len(stmt) && isIdentChar(stmt[end]) {
+				end++
+			}
+			b.WriteString(stmt[i:end])
+		default:
+			end = i + 1
+			b.WriteByte(c)
+		}
+		i = end
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStatementFingerprint(t *testing.T) {
+	tests := []struct {
+		stmt        string
+		fingerprint string
+	}{
+		{"SELECT * FROM ks.tbl WHERE id = ?", "SELECT * FROM ks.tbl WHERE id = ?"},
+		{"SELECT  *\n\tFROM tbl WHERE name = 'it''s' AND n = 42", "SELECT * FROM tbl WHERE name = ? AND n = ?"},
+		{"INSERT INTO t2 (id, b) VALUES (123e4567-e89b-12d3-a456-426614174000, 0xcafe)", "INSERT INTO t2 (id, b) VALUES (?, ?)"},
+		{"UPDATE \"Tbl 1\" SET v = 1.5e-3 -- secret\nWHERE k = $$x$$", "UPDATE \"Tbl 1\" SET v = ? WHERE k = ?"},
+		{"DELETE FROM tbl /* 'secret' */ WHERE k = -7;", "DELETE FROM tbl WHERE k = -?;"},
+	}
+	for _, test := range tests {
+		if got := statementFingerprint(test.stmt); got != test.fingerprint {
+			t.Errorf("statementFingerprint(%q) = %q, expected %q", test.stmt, got, test.fingerprint)
+		}
+	}
+}
+
+func TestQueryErrorContext(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.ErrorContext = true
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	err = db.Query("kill 'secret'", "value").Consistency(LocalQuorum).Exec()
+	var qerr *QueryError
+	if !errors.As(err, &qerr) {
+		t.Fatalf("expected a *QueryError, got %#v", err)
+	}
+	if !errors.Is(err, ErrOverloadedCategory) {
+		t.Errorf("expected an overloaded error, got %v", qerr.Err)
+	}
+	if qerr.Statement != "kill ?" || qerr.Consistency != LocalQuorum || qerr.Values != nil {
+		t.Errorf("unexpected context %+v", qerr)
+	}
+	if qerr.Host == nil || qerr.Host.ConnectAddressAndPort() != srv.Address {
+		t.Errorf("expected host %s, got %v", srv.Address, qerr.Host)
+	}
+	if msg := err.Error(); strings.Contains(msg, "secret") || strings.Contains(msg, "value") {
+		t.Errorf("error leaks literals or values: %s", msg)
+	}
+
+	err = db.Query("kill").Name("kill_node").Tag("tenant", "acme").Exec()
+	if !errors.As(err, &qerr) || qerr.Name != "kill_node" || qerr.Tags["tenant"] != "acme" {
+		t.Errorf("expected the name and tags of the query, got %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, `name="kill_node"`) || !strings.Contains(msg, "tags=map[tenant:acme]") {
+		t.Errorf("expected the name and tags in the message: %s", msg)
+	}
+
+	db.cfg.ErrorContextValues = true
+	err = db.Query("kill", "value").Exec()
+	if !errors.As(err, &qerr) || len(qerr.Values) != 1 || qerr.Values[0] != "value" {
+		t.Errorf("expected the bound values, got %v", err)
+	}
+
+	if err := db.Query("void").Exec(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
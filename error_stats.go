@@ -0,0 +1,144 @@
+package gocql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrorStats are the numbers of errors of the attempts to execute queries and
+// batches, by category.
+type ErrorStats struct {
+	// ClientTimeouts are the attempts which got no response within the
+	// timeout of the connection or the deadline of their context.
+	ClientTimeouts uint64
+	// ReadTimeouts and WriteTimeouts are the server side timeouts, write
+	// timeouts including the CAS_WRITE_UNKNOWN errors.
+	ReadTimeouts  uint64
+	WriteTimeouts uint64
+	// Unavailable are the attempts rejected as not enough replicas were alive,
+	// or the coordinator was bootstrapping.
+	Unavailable uint64
+	// Overloaded are the attempts rejected by an overloaded or rate limiting
+	// coordinator.
+	Overloaded uint64
+	// Connection are the attempts which failed because of their connection.
+	Connection uint64
+	// Unprepared are the executions of statements the server did not know,
+	// which were prepared again.
+	Unprepared uint64
+	// Corrupted are the corrupted frames, counted once per frame rather than
+	// per attempt, whose connections were closed unless they were outbound.
+	Corrupted uint64
+	// Other are the errors of the other categories.
+	Other uint64
+}
+
+type errorCategory int
+
+const (
+	errorClientTimeout errorCategory = iota
+	errorReadTimeout
+	errorWriteTimeout
+	errorUnavailable
+	errorOverloaded
+	errorConnection
+	errorUnprepared
+	errorCorrupted
+	errorOther
+	numErrorCategories
+)
+
+type errorCounters [numErrorCategories]uint64
+
+func (c *errorCounters) stats() ErrorStats {
+	return ErrorStats{
+		ClientTimeouts: atomic.LoadUint64(&c[errorClientTimeout]),
+		ReadTimeouts:   atomic.LoadUint64(&c[errorReadTimeout]),
+		WriteTimeouts:  atomic.LoadUint64(&c[errorWriteTimeout]),
+		Unavailable:    atomic.LoadUint64(&c[errorUnavailable]),
+		Overloaded:     atomic.LoadUint64(&c[errorOverloaded]),
+		Connection:     atomic.LoadUint64(&c[errorConnection]),
+		Unprepared:     atomic.LoadUint64(&c[errorUnprepared]),
+		Corrupted:      atomic.LoadUint64(&c[errorCorrupted]),
+		Other:          atomic.LoadUint64(&c[errorOther]),
+	}
+}
+
+// sessionErrorStats counts the errors of a session, in total and per host ID.
+type sessionErrorStats struct {
+	total errorCounters
+
+	mu    sync.RWMutex
+	hosts map[string]*errorCounters
+}
+
+// record counts err for host, which can be nil.
+func (s *sessionErrorStats) record(host *HostInfo, err error) {
+	category := classifyError(err)
+	atomic.AddUint64(&s.total[category], 1)
+	if host == nil {
+		return
+	}
+
+	hostID := host.HostID()
+	s.mu.RLock()
+	counters, ok := s.hosts[hostID]
+	s.mu.RUnlock()
+	if !ok {
+		s.mu.Lock()
+		if counters, ok = s.hosts[hostID]; !ok {
+			if s.hosts == nil {
+				s.hosts = make(map[string]*errorCounters)
+			}
+			counters = &errorCounters{}
+			s.hosts[hostID] = counters
+		}
+		s.mu.Unlock()
+	}
+	atomic.AddUint64(&counters[category], 1)
+}
+
+func classifyError(err error) errorCategory {
+	switch err.(type) {
+	case *RequestErrReadTimeout:
+		return errorReadTimeout
+	case *RequestErrWriteTimeout, *RequestErrCASWriteUnknown:
+		return errorWriteTimeout
+	}
+	var corrupted *FrameCorruptionError
+	switch {
+	case errors.As(err, &corrupted):
+		return errorCorrupted
+	case errors.Is(err, ErrTimeoutNoResponse), errors.Is(err, context.DeadlineExceeded):
+		return errorClientTimeout
+	case errors.Is(err, ErrUnavailableCategory):
+		return errorUnavailable
+	case errors.Is(err, ErrOverloadedCategory):
+		return errorOverloaded
+	case errors.Is(err, ErrConnectionCategory):
+		return errorConnection
+	case errors.Is(err, ErrUnpreparedCategory):
+		return errorUnprepared
+	}
+	return errorOther
+}
+
+// ErrorStats returns the numbers of errors of the attempts to execute the
+// queries and batches of the session, so that alerting does not depend on
+// logs. Canceled queries and ErrNotFound are not counted.
+func (s *Session) ErrorStats() ErrorStats {
+	return s.errorStats.total.stats()
+}
+
+// HostErrorStats returns the numbers of errors of the session per host ID.
+func (s *Session) HostErrorStats() map[string]ErrorStats {
+	s.errorStats.mu.RLock()
+	defer s.errorStats.mu.RUnlock()
+	stats := make(map[string]ErrorStats, len(s.errorStats.hosts))
+	for hostID, counters := range s.errorStats.hosts {
+		stats[hostID] = counters.stats()
+	}
+	return stats
+}
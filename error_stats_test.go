@@ -0,0 +1,73 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err      error
+		category errorCategory
+	}{
+		{ErrTimeoutNoResponse, errorClientTimeout},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), errorClientTimeout},
+		{&RequestErrReadTimeout{errorFrame: errorFrame{code: ErrCodeReadTimeout}}, errorReadTimeout},
+		{&RequestErrWriteTimeout{errorFrame: errorFrame{code: ErrCodeWriteTimeout}}, errorWriteTimeout},
+		{&RequestErrCASWriteUnknown{errorFrame: errorFrame{code: ErrCodeCASWriteUnknown}}, errorWriteTimeout},
+		{&RequestErrUnavailable{errorFrame: errorFrame{code: ErrCodeUnavailable}}, errorUnavailable},
+		{&errorFrame{code: ErrCodeBootstrapping}, errorUnavailable},
+		{&errorFrame{code: ErrCodeOverloaded}, errorOverloaded},
+		{&RequestErrRateLimitReached{errorFrame: errorFrame{code: 0xf0}}, errorOverloaded},
+		{ErrConnectionClosed, errorConnection},
+		{ErrNoConnections, errorConnection},
+		{&RequestErrUnprepared{errorFrame: errorFrame{code: ErrCodeUnprepared}}, errorUnprepared},
+		{&FrameCorruptionError{Direction: FrameInbound, Err: io.ErrUnexpectedEOF}, errorCorrupted},
+		{&errorFrame{code: ErrCodeSyntax}, errorOther},
+	}
+	for _, test := range tests {
+		if category := classifyError(test.err); category != test.category {
+			t.Errorf("%v: expected category %d got %d", test.err, test.category, category)
+		}
+	}
+}
+
+func TestSessionErrorStats(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{"kill", "kill", "unavailable", "writetimeout", "void"} {
+		db.Query(stmt).Exec()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := db.Query("timeout").WithContext(ctx).Exec(); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+
+	expected := ErrorStats{ClientTimeouts: 1, WriteTimeouts: 1, Unavailable: 1, Overloaded: 2}
+	if stats := db.ErrorStats(); stats != expected {
+		t.Errorf("expected %+v got %+v", expected, stats)
+	}
+	hosts := db.HostErrorStats()
+	if len(hosts) != 1 {
+		t.Fatalf("expected the errors of 1 host, got %v", hosts)
+	}
+	for hostID, stats := range hosts {
+		if stats != expected {
+			t.Errorf("host %s: expected %+v got %+v", hostID, expected, stats)
+		}
+	}
+}
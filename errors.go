@@ -1,6 +1,14 @@
 package gocql
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
 
 // See CQL Binary Protocol v5, section 8 for more details.
 // https://github.com/apache/cassandra/blob/7337fc0/doc/native_protocol_v5.spec
@@ -87,6 +95,197 @@ const (
 	ErrCodeUnprepared = 0x2500
 )
 
+// Error categories group the errors of the driver and of the server by their
+// cause, so applications can handle them with errors.Is instead of matching
+// error types or messages:
+//
+//	if errors.Is(err, gocql.ErrTimeoutCategory) {
+//		// retry later
+//	}
+//
+// An error belongs to at most one category. The typed errors, such as
+// *RequestErrReadTimeout, remain available through errors.As for their
+// details.
+var (
+	// ErrTimeoutCategory is the category of the read and write timeouts of
+	// the server and of the requests getting no response in time, such as
+	// ErrTimeoutNoResponse.
+	ErrTimeoutCategory = errors.New("gocql: timeout")
+	// ErrUnavailableCategory is the category of the errors of the server
+	// missing replicas or bootstrapping.
+	ErrUnavailableCategory = errors.New("gocql: unavailable")
+	// ErrOverloadedCategory is the category of the errors of overloaded or
+	// rate limiting servers.
+	ErrOverloadedCategory = errors.New("gocql: overloaded")
+	// ErrFailureCategory is the category of the read, write and function
+	// failures of the server.
+	ErrFailureCategory = errors.New("gocql: execution failure")
+	// ErrAlreadyExistsCategory is the category of the errors of the server
+	// creating an existing keyspace or table.
+	ErrAlreadyExistsCategory = errors.New("gocql: already exists")
+	// ErrUnpreparedCategory is the category of the errors of the server not
+	// knowing a prepared statement.
+	ErrUnpreparedCategory = errors.New("gocql: unprepared")
+	// ErrInvalidQueryCategory is the category of the syntax, invalid query
+	// and configuration errors of the server.
+	ErrInvalidQueryCategory = errors.New("gocql: invalid query")
+	// ErrAuthCategory is the category of the authentication and authorization
+	// errors of the server.
+	ErrAuthCategory = errors.New("gocql: unauthorized")
+	// ErrServerCategory is the category of the internal, protocol and
+	// truncation errors of the server.
+	ErrServerCategory = errors.New("gocql: server error")
+	// ErrConnectionCategory is the category of the errors of the connections
+	// and their pools, such as ErrConnectionClosed or ErrNoConnections.
+	ErrConnectionCategory = errors.New("gocql: connection error")
+)
+
+// errorCodeCategory returns the category of the server error code, nil if it
+// has none.
+func errorCodeCategory(code int) error {
+	switch code {
+	case ErrCodeReadTimeout, ErrCodeWriteTimeout, ErrCodeCASWriteUnknown:
+		return ErrTimeoutCategory
+	case ErrCodeUnavailable, ErrCodeBootstrapping:
+		return ErrUnavailableCategory
+	case ErrCodeOverloaded:
+		return ErrOverloadedCategory
+	case ErrCodeReadFailure, ErrCodeWriteFailure, ErrCodeCDCWriteFailure, ErrCodeFunctionFailure:
+		return ErrFailureCategory
+	case ErrCodeAlreadyExists:
+		return ErrAlreadyExistsCategory
+	case ErrCodeUnprepared:
+		return ErrUnpreparedCategory
+	case ErrCodeSyntax, ErrCodeInvalid, ErrCodeConfig:
+		return ErrInvalidQueryCategory
+	case ErrCodeCredentials, ErrCodeUnauthorized:
+		return ErrAuthCategory
+	case ErrCodeServer, ErrCodeProtocol, ErrCodeTruncate:
+		return ErrServerCategory
+	}
+	return nil
+}
+
+// IsRetryable reports whether err is a transient error, which executing the
+// statement again, possibly on another host, can resolve: timeouts,
+// unavailable replicas, overloaded servers and connection errors. Whether it
+// is safe to retry depends on the statement being idempotent, unless
+// IsIdempotentSafe reports that the statement was not applied.
+//
+// It is the classification of the driver, for applications retrying
+// statements themselves once the retry policy gave up.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var (
+		writeTimeout *RequestErrWriteTimeout
+		writeUnknown *RequestErrCASWriteUnknown
+	)
+	return IsIdempotentSafe(err) || errors.As(err, &writeTimeout) || errors.As(err, &writeUnknown) ||
+		errors.Is(err, ErrTimeoutCategory) || errors.Is(err, ErrOverloadedCategory) || errors.Is(err, ErrConnectionCategory)
+}
+
+// IsIdempotentSafe reports whether err guarantees that the statement was not
+// applied, so that it can be retried even if it is not idempotent: the
+// statement was rejected by the coordinator before being sent to the
+// replicas, as unavailable, overloaded or bootstrapping, it was not sent at
+// all, or it was a read. Timeouts and the errors of connections while
+// waiting for the response leave it unknown whether a write was applied.
+func IsIdempotentSafe(err error) bool {
+	var rateLimit *RequestErrRateLimitReached
+	if errors.As(err, &rateLimit) {
+		// a rate limited write might have been applied by some replicas
+		return rateLimit.OpType == OpTypeRead
+	}
+	var (
+		readTimeout *RequestErrReadTimeout
+		unavailable *RequestErrUnavailable
+	)
+	if errors.As(err, &readTimeout) || errors.As(err, &unavailable) {
+		return true
+	}
+	return errors.Is(err, ErrUnavailableCategory) || errors.Is(err, ErrOverloadedCategory) ||
+		errors.Is(err, ErrNoConnections) || errors.Is(err, ErrNoStreams)
+}
+
+// categorizedError is a sentinel error of the driver belonging to a category.
+type categorizedError struct {
+	message  string
+	category error
+}
+
+// connectionError is a network error of a connection, such as io.EOF or a
+// *net.OpError, categorized as ErrConnectionCategory.
+type connectionError struct {
+	err error
+}
+
+// categorizeConnError returns err categorized as ErrConnectionCategory if it
+// is a network error of the connection.
+func categorizeConnError(err error) error {
+	var netErr net.Error
+	if !errors.As(err, &netErr) && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	if errors.Is(err, ErrConnectionCategory) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return &connectionError{err: err}
+}
+
+func (e *connectionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *connectionError) Unwrap() error {
+	return e.err
+}
+
+func (e *connectionError) Is(target error) bool {
+	return target == ErrConnectionCategory
+}
+
+func newCategorizedError(category error, message string) error {
+	return &categorizedError{message: message, category: category}
+}
+
+func (e *categorizedError) Error() string {
+	return e.message
+}
+
+func (e *categorizedError) Is(target error) bool {
+	return target == e.category
+}
+
+// HostAttemptError is the error of an attempt to execute a query or batch on
+// a host.
+type HostAttemptError struct {
+	Host *HostInfo
+	// Attempt is the index of the attempt, the first attempt is number zero.
+	Attempt int
+	// Name is the name of the query, as set with Query.Name.
+	Name string
+	// Start and End are the times the attempt was sent and failed.
+	Start, End time.Time
+	Err        error
+}
+
+func (e *HostAttemptError) Error() string {
+	host := "<nil>"
+	if e.Host != nil {
+		host = e.Host.ConnectAddressAndPort()
+	}
+	if e.Name != "" {
+		return fmt.Sprintf("attempt %d of %s on %s: %v", e.Attempt, e.Name, host, e.Err)
+	}
+	return fmt.Sprintf("attempt %d on %s: %v", e.Attempt, host, e.Err)
+}
+
+func (e *HostAttemptError) Unwrap() error {
+	return e.Err
+}
+
 type RequestError interface {
 	Code() int
 	Message() string
@@ -112,6 +311,12 @@ func (e errorFrame) Error() string {
 	return e.Message()
 }
 
+// Is reports whether target is the category of the error code, see
+// ErrTimeoutCategory.
+func (e errorFrame) Is(target error) bool {
+	return target != nil && target == errorCodeCategory(e.code)
+}
+
 func (e errorFrame) String() string {
 	return fmt.Sprintf("[error code=%x message=%q]", e.code, e.message)
 }
@@ -127,14 +332,74 @@ func (e *RequestErrUnavailable) String() string {
 	return fmt.Sprintf("[request_error_unavailable consistency=%s required=%d alive=%d]", e.Consistency, e.Required, e.Alive)
 }
 
+// ErrorMap is the failure reason code per replica address of a read or write
+// failure, only sent with protocol v5 and above.
 type ErrorMap map[string]uint16
 
+// Reasons returns the failure reason per replica address.
+func (m ErrorMap) Reasons() map[string]FailureReason {
+	reasons := make(map[string]FailureReason, len(m))
+	for addr, code := range m {
+		reasons[addr] = FailureReason(code)
+	}
+	return reasons
+}
+
+// FailureReason is the reason a replica failed a read or write.
+type FailureReason uint16
+
+const (
+	FailureReasonUnknown               FailureReason = 0x0000
+	FailureReasonReadTooManyTombstones FailureReason = 0x0001
+	FailureReasonIndexNotAvailable     FailureReason = 0x0002
+	FailureReasonCDCSpaceFull          FailureReason = 0x0003
+	FailureReasonCounterWrite          FailureReason = 0x0004
+	FailureReasonTableNotFound         FailureReason = 0x0005
+	FailureReasonKeyspaceNotFound      FailureReason = 0x0006
+)
+
+func (r FailureReason) String() string {
+	switch r {
+	case FailureReasonUnknown:
+		return "UNKNOWN"
+	case FailureReasonReadTooManyTombstones:
+		return "READ_TOO_MANY_TOMBSTONES"
+	case FailureReasonIndexNotAvailable:
+		return "INDEX_NOT_AVAILABLE"
+	case FailureReasonCDCSpaceFull:
+		return "CDC_SPACE_FULL"
+	case FailureReasonCounterWrite:
+		return "COUNTER_WRITE"
+	case FailureReasonTableNotFound:
+		return "TABLE_NOT_FOUND"
+	case FailureReasonKeyspaceNotFound:
+		return "KEYSPACE_NOT_FOUND"
+	}
+	return fmt.Sprintf("UNKNOWN_%d", uint16(r))
+}
+
+// The write types of RequestErrWriteTimeout and RequestErrWriteFailure.
+const (
+	WriteTypeSimple        = "SIMPLE"
+	WriteTypeBatch         = "BATCH"
+	WriteTypeUnloggedBatch = "UNLOGGED_BATCH"
+	WriteTypeCounter       = "COUNTER"
+	WriteTypeBatchLog      = "BATCH_LOG"
+	WriteTypeCAS           = "CAS"
+	WriteTypeView          = "VIEW"
+	WriteTypeCDC           = "CDC"
+)
+
 type RequestErrWriteTimeout struct {
 	errorFrame
 	Consistency Consistency
 	Received    int
 	BlockFor    int
 	WriteType   string
+	// Contentions is the number of contentions of the Paxos round of a
+	// lightweight transaction, for the CAS write type with protocol v5 and
+	// above.
+	Contentions int
 }
 
 type RequestErrWriteFailure struct {
@@ -180,13 +445,34 @@ type RequestErrReadFailure struct {
 	ErrorMap    ErrorMap
 }
 
+// RequestErrFunctionFailure is the error of a user defined function which
+// failed while executing a query.
 type RequestErrFunctionFailure struct {
 	errorFrame
+	// Keyspace and Function are the keyspace and the name of the function.
 	Keyspace string
 	Function string
+	// ArgTypes are the CQL types of the arguments of the function, which
+	// identify it among its overloads.
 	ArgTypes []string
 }
 
+// Signature returns the qualified name and argument types of the function,
+// as in ks.fn(int, text).
+func (e *RequestErrFunctionFailure) Signature() string {
+	return fmt.Sprintf("%s.%s(%s)", e.Keyspace, e.Function, strings.Join(e.ArgTypes, ", "))
+}
+
+// Error returns the message of the server followed by the signature of the
+// function.
+func (e *RequestErrFunctionFailure) Error() string {
+	return fmt.Sprintf("%s (function %s)", e.message, e.Signature())
+}
+
+func (e *RequestErrFunctionFailure) String() string {
+	return fmt.Sprintf("[request_error_function_failure keyspace=%s function=%s arg_types=%v message=%q]", e.Keyspace, e.Function, e.ArgTypes, e.message)
+}
+
 // RequestErrCASWriteUnknown is distinct error for ErrCodeCasWriteUnknown.
 //
 // See https://github.com/apache/cassandra/blob/7337fc0/doc/native_protocol_v5.spec#L1387-L1397
@@ -196,3 +482,46 @@ type RequestErrCASWriteUnknown struct {
 	Received    int
 	BlockFor    int
 }
+
+// OpType is the type of operation rejected by Scylla's per partition rate limiting.
+type OpType byte
+
+const (
+	OpTypeRead  OpType = 0
+	OpTypeWrite OpType = 1
+)
+
+func (o OpType) String() string {
+	switch o {
+	case OpTypeRead:
+		return "READ"
+	case OpTypeWrite:
+		return "WRITE"
+	default:
+		return fmt.Sprintf("UNKNOWN_OP_%d", byte(o))
+	}
+}
+
+// RequestErrRateLimitReached is returned by Scylla when an operation exceeded the
+// per partition rate limit of the table. The error code is negotiated with the node
+// when connecting, so it has no ErrCode constant.
+//
+// Rate limited reads are retried according to the retry policy of the query,
+// rate limited writes are only retried if the query is idempotent.
+type RequestErrRateLimitReached struct {
+	errorFrame
+	OpType OpType
+	// RejectedByCoordinator is true if the operation was rejected by the
+	// coordinator and not by a replica.
+	RejectedByCoordinator bool
+}
+
+// Is reports whether target is ErrOverloadedCategory, the code of the error
+// being negotiated.
+func (e *RequestErrRateLimitReached) Is(target error) bool {
+	return target == ErrOverloadedCategory
+}
+
+func (e *RequestErrRateLimitReached) String() string {
+	return fmt.Sprintf("[request_error_rate_limit_reached op_type=%s rejected_by_coordinator=%t]", e.OpType, e.RejectedByCoordinator)
+}
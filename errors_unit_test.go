@@ -0,0 +1,190 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestErrorCategories(t *testing.T) {
+	categories := []error{
+		ErrTimeoutCategory,
+		ErrUnavailableCategory,
+		ErrOverloadedCategory,
+		ErrFailureCategory,
+		ErrAlreadyExistsCategory,
+		ErrUnpreparedCategory,
+		ErrInvalidQueryCategory,
+		ErrAuthCategory,
+		ErrServerCategory,
+		ErrConnectionCategory,
+	}
+	tests := []struct {
+		err      error
+		category error
+	}{
+		{&RequestErrReadTimeout{errorFrame: errorFrame{code: ErrCodeReadTimeout}}, ErrTimeoutCategory},
+		{&RequestErrWriteTimeout{errorFrame: errorFrame{code: ErrCodeWriteTimeout}}, ErrTimeoutCategory},
+		{ErrTimeoutNoResponse, ErrTimeoutCategory},
+		{&RequestErrUnavailable{errorFrame: errorFrame{code: ErrCodeUnavailable}}, ErrUnavailableCategory},
+		{ErrUnavailable, ErrUnavailableCategory},
+		{&errorFrame{code: ErrCodeOverloaded}, ErrOverloadedCategory},
+		{&RequestErrRateLimitReached{errorFrame: errorFrame{code: 0xf0}}, ErrOverloadedCategory},
+		{&RequestErrWriteFailure{errorFrame: errorFrame{code: ErrCodeWriteFailure}}, ErrFailureCategory},
+		{&RequestErrAlreadyExists{errorFrame: errorFrame{code: ErrCodeAlreadyExists}}, ErrAlreadyExistsCategory},
+		{&RequestErrUnprepared{errorFrame: errorFrame{code: ErrCodeUnprepared}}, ErrUnpreparedCategory},
+		{&errorFrame{code: ErrCodeSyntax}, ErrInvalidQueryCategory},
+		{&errorFrame{code: ErrCodeUnauthorized}, ErrAuthCategory},
+		{&errorFrame{code: ErrCodeServer}, ErrServerCategory},
+		{NewErrProtocol("unexpected frame"), ErrServerCategory},
+		{ErrConnectionClosed, ErrConnectionCategory},
+		{ErrNoConnections, ErrConnectionCategory},
+		{ErrNoStreams, ErrConnectionCategory},
+		{ErrNoConnectionsStarted, ErrConnectionCategory},
+		{errPreparedInfoNoConn, ErrConnectionCategory},
+		{ErrFrameTooBig, ErrConnectionCategory},
+		{&ErrFrameTooLarge{Length: 2, Limit: 1}, ErrConnectionCategory},
+		{categorizeConnError(io.EOF), ErrConnectionCategory},
+		{categorizeConnError(&net.OpError{Op: "write", Net: "tcp", Err: syscall.ECONNRESET}), ErrConnectionCategory},
+		{categorizeConnError(ErrConnectionClosed), ErrConnectionCategory},
+		{categorizeConnError(context.Canceled), nil},
+		{ErrNotFound, nil},
+	}
+	for _, test := range tests {
+		wrapped := fmt.Errorf("query failed: %w", test.err)
+		for _, category := range categories {
+			if got := errors.Is(wrapped, category); got != (category == test.category) {
+				t.Errorf("errors.Is(%T(%v), %v) = %t", test.err, test.err, category, got)
+			}
+		}
+		if !errors.Is(wrapped, test.err) {
+			t.Errorf("%v does not wrap itself", test.err)
+		}
+	}
+
+	if err := categorizeConnError(io.EOF); !errors.Is(err, io.EOF) {
+		t.Errorf("%v does not wrap io.EOF", err)
+	}
+
+	var alreadyExists *RequestErrAlreadyExists
+	err := fmt.Errorf("create: %w", &RequestErrAlreadyExists{errorFrame: errorFrame{code: ErrCodeAlreadyExists}, Table: "t"})
+	if !errors.As(err, &alreadyExists) || alreadyExists.Table != "t" {
+		t.Fatalf("errors.As failed for %v", err)
+	}
+}
+
+func TestParseErrorDetails(t *testing.T) {
+	newErrorFramer := func(code int) *framer {
+		f := newFramer(nil, protoVersion5)
+		f.header = &frameHeader{version: protoVersion5, op: opError}
+		f.writeInt(int32(code))
+		f.writeString("error")
+		return f
+	}
+
+	f := newErrorFramer(ErrCodeWriteTimeout)
+	f.writeConsistency(Consistency(Serial))
+	f.writeInt(1)
+	f.writeInt(2)
+	f.writeString(WriteTypeCAS)
+	f.writeShort(3)
+	var writeTimeout *RequestErrWriteTimeout
+	err := fmt.Errorf("insert: %w", f.parseErrorFrame().(error))
+	if !errors.As(err, &writeTimeout) {
+		t.Fatalf("expected a *RequestErrWriteTimeout, got %v", err)
+	}
+	if writeTimeout.Consistency != Consistency(Serial) || writeTimeout.Received != 1 || writeTimeout.BlockFor != 2 ||
+		writeTimeout.WriteType != WriteTypeCAS || writeTimeout.Contentions != 3 {
+		t.Errorf("unexpected write timeout %+v", writeTimeout)
+	}
+
+	f = newErrorFramer(ErrCodeReadFailure)
+	f.writeConsistency(Quorum)
+	f.writeInt(1)
+	f.writeInt(2)
+	f.writeInt(1)
+	f.writeByte(4)
+	f.buf = append(f.buf, 10, 0, 0, 1)
+	f.writeShort(uint16(FailureReasonReadTooManyTombstones))
+	f.writeByte(1)
+	var readFailure *RequestErrReadFailure
+	err = fmt.Errorf("select: %w", f.parseErrorFrame().(error))
+	if !errors.As(err, &readFailure) {
+		t.Fatalf("expected a *RequestErrReadFailure, got %v", err)
+	}
+	if readFailure.NumFailures != 1 || !readFailure.DataPresent {
+		t.Errorf("unexpected read failure %+v", readFailure)
+	}
+	reasons := readFailure.ErrorMap.Reasons()
+	if reason := reasons["10.0.0.1"]; len(reasons) != 1 || reason != FailureReasonReadTooManyTombstones {
+		t.Errorf("expected the reason %v for 10.0.0.1, got %v", FailureReasonReadTooManyTombstones, reasons)
+	}
+	if s := FailureReasonReadTooManyTombstones.String(); s != "READ_TOO_MANY_TOMBSTONES" {
+		t.Errorf("unexpected reason string %q", s)
+	}
+
+	f = newErrorFramer(ErrCodeFunctionFailure)
+	f.writeString("ks")
+	f.writeString("checked_div")
+	f.writeStringList([]string{"int", "int"})
+	var functionFailure *RequestErrFunctionFailure
+	err = fmt.Errorf("select: %w", f.parseErrorFrame().(error))
+	if !errors.As(err, &functionFailure) {
+		t.Fatalf("expected a *RequestErrFunctionFailure, got %v", err)
+	}
+	if sig := functionFailure.Signature(); sig != "ks.checked_div(int, int)" {
+		t.Errorf("unexpected signature %q", sig)
+	}
+	if msg := functionFailure.Error(); msg != "error (function ks.checked_div(int, int))" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if !errors.Is(err, ErrFailureCategory) {
+		t.Errorf("expected an execution failure, got %v", err)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err            error
+		retryable      bool
+		idempotentSafe bool
+	}{
+		{nil, false, false},
+		{&RequestErrReadTimeout{errorFrame: errorFrame{code: ErrCodeReadTimeout}}, true, true},
+		{&RequestErrWriteTimeout{errorFrame: errorFrame{code: ErrCodeWriteTimeout}}, true, false},
+		{&RequestErrCASWriteUnknown{errorFrame: errorFrame{code: ErrCodeCASWriteUnknown}}, true, false},
+		{ErrTimeoutNoResponse, true, false},
+		{&RequestErrUnavailable{errorFrame: errorFrame{code: ErrCodeUnavailable}}, true, true},
+		{&errorFrame{code: ErrCodeBootstrapping}, true, true},
+		{&errorFrame{code: ErrCodeOverloaded}, true, true},
+		{&RequestErrRateLimitReached{errorFrame: errorFrame{code: 0xf0}, OpType: OpTypeRead}, true, true},
+		{&RequestErrRateLimitReached{errorFrame: errorFrame{code: 0xf0}, OpType: OpTypeWrite}, true, false},
+		{ErrNoConnections, true, true},
+		{ErrNoStreams, true, true},
+		{ErrConnectionClosed, true, false},
+		{&RequestErrWriteFailure{errorFrame: errorFrame{code: ErrCodeWriteFailure}}, false, false},
+		{&errorFrame{code: ErrCodeSyntax}, false, false},
+		{&RequestErrAlreadyExists{errorFrame: errorFrame{code: ErrCodeAlreadyExists}}, false, false},
+		{ErrNotFound, false, false},
+		{context.Canceled, false, false},
+	}
+	for _, test := range tests {
+		wrapped := test.err
+		if wrapped != nil {
+			wrapped = fmt.Errorf("query: %w", test.err)
+		}
+		if got := IsRetryable(wrapped); got != test.retryable {
+			t.Errorf("IsRetryable(%v) = %t", test.err, got)
+		}
+		if got := IsIdempotentSafe(wrapped); got != test.idempotentSafe {
+			t.Errorf("IsIdempotentSafe(%v) = %t", test.err, got)
+		}
+	}
+}
@@ -1,16 +1,23 @@
 package gocql
 
 import (
+	"fmt"
 	"net"
+	"strings"
 	"sync"
 	"time"
 )
 
 type eventDebouncer struct {
-	name   string
-	timer  *time.Timer
-	mu     sync.Mutex
-	events []frame
+	name     string
+	interval time.Duration
+	// maxDelay bounds the time the first event of a burst waits, 0 if unbounded
+	maxDelay time.Duration
+	deadline time.Time
+	clock    Clock
+	timer    Timer
+	mu       sync.Mutex
+	events   []frame
 
 	callback func([]frame)
 	quit     chan struct{}
@@ -18,11 +25,17 @@ type eventDebouncer struct {
 	logger StdLogger
 }
 
-func newEventDebouncer(name string, eventHandler func([]frame), logger StdLogger) *eventDebouncer {
+// newEventDebouncer returns a debouncer calling eventHandler with the events
+// received until none was received for interval, or until maxDelay elapsed
+// since the first of them if maxDelay is positive, as measured by clock.
+func newEventDebouncer(name string, interval, maxDelay time.Duration, clock Clock, eventHandler func([]frame), logger StdLogger) *eventDebouncer {
 	e := &eventDebouncer{
 		name:     name,
+		interval: interval,
+		maxDelay: maxDelay,
 		quit:     make(chan struct{}),
-		timer:    time.NewTimer(eventDebounceTime),
+		clock:    clock,
+		timer:    clock.NewTimer(interval),
 		callback: eventHandler,
 		logger:   logger,
 	}
@@ -40,7 +53,7 @@ func (e *eventDebouncer) stop() {
 func (e *eventDebouncer) flusher() {
 	for {
 		select {
-		case <-e.timer.C:
+		case <-e.timer.C():
 			e.mu.Lock()
 			e.flush()
 			e.mu.Unlock()
@@ -51,10 +64,26 @@ func (e *eventDebouncer) flusher() {
 }
 
 const (
-	eventBufferSize   = 1000
-	eventDebounceTime = 1 * time.Second
+	eventBufferSize       = 1000
+	eventDebounceTime     = 1 * time.Second
+	eventMaxDebounceDelay = 10 * time.Second
 )
 
+// debounceDelay returns the delay before flushing a burst of events ending
+// at now, which must not exceed deadline if maxDelay is positive.
+func debounceDelay(now time.Time, interval, maxDelay time.Duration, deadline time.Time) time.Duration {
+	if maxDelay <= 0 {
+		return interval
+	}
+	if left := deadline.Sub(now); left < interval {
+		if left < 0 {
+			return 0
+		}
+		return left
+	}
+	return interval
+}
+
 // flush must be called with mu locked
 func (e *eventDebouncer) flush() {
 	if len(e.events) == 0 {
@@ -70,7 +99,11 @@ func (e *eventDebouncer) flush() {
 
 func (e *eventDebouncer) debounce(frame frame) {
 	e.mu.Lock()
-	e.timer.Reset(eventDebounceTime)
+	now := e.clock.Now()
+	if len(e.events) == 0 {
+		e.deadline = now.Add(e.maxDelay)
+	}
+	e.timer.Reset(debounceDelay(now, e.interval, e.maxDelay, e.deadline))
 
 	// TODO: probably need a warning to track if this threshold is too low
 	if len(e.events) < eventBufferSize {
@@ -105,15 +138,62 @@ func (s *Session) handleEvent(framer *framer) {
 	}
 }
 
+// InjectTopologyEvent processes a synthetic TOPOLOGY_CHANGE event, change
+// being NEW_NODE, REMOVED_NODE or MOVED_NODE, as if a node had pushed it. It
+// is meant to test host selection policies and pools under node churn
+// without changing the cluster. Like a pushed event, it refreshes the ring
+// from the control connection asynchronously.
+func (s *Session) InjectTopologyEvent(change string, ip net.IP, port int) error {
+	switch change {
+	case "NEW_NODE", "REMOVED_NODE", "MOVED_NODE":
+	default:
+		return fmt.Errorf("gocql: invalid topology change %q", change)
+	}
+	return s.injectNodeEvent(&topologyChangeEventFrame{change: change, host: ip, port: port})
+}
+
+// InjectStatusEvent processes a synthetic STATUS_CHANGE event, change being UP
+// or DOWN, as if a node had pushed it. Like in pushed events, ip is the
+// broadcast address of the node, which may differ from the address the driver
+// connects to. It is meant to test host selection policies and pools under
+// node churn without stopping nodes. A DOWN event marks the host down and
+// removes its pool before returning, an UP event starts refilling the pool,
+// the host is marked up once connected.
+func (s *Session) InjectStatusEvent(change string, ip net.IP, port int) error {
+	switch change {
+	case "UP", "DOWN":
+	default:
+		return fmt.Errorf("gocql: invalid status change %q", change)
+	}
+	return s.injectNodeEvent(&statusChangeEventFrame{change: change, host: ip, port: port})
+}
+
+func (s *Session) injectNodeEvent(f frame) error {
+	if s.Closed() {
+		return ErrSessionClosed
+	}
+	// bypass the debouncer so that the event is processed once we return
+	s.handleNodeEvent([]frame{f})
+	return nil
+}
+
 func (s *Session) handleSchemaEvent(frames []frame) {
+	s.reprepareNamedQueries()
 	// TODO: debounce events
 	for _, frame := range frames {
 		switch f := frame.(type) {
 		case *schemaChangeKeyspace:
 			s.schemaDescriber.clearSchema(f.keyspace)
+			s.tablets.removeTable(f.keyspace, "")
 			s.handleKeyspaceChange(f.keyspace, f.change)
 		case *schemaChangeTable:
 			s.schemaDescriber.clearSchema(f.keyspace)
+			if f.change == "DROPPED" {
+				s.tablets.removeTable(f.keyspace, f.object)
+			}
+			if f.change == "UPDATED" && s.cfg.ReprepareOnSchemaChange {
+				go s.reprepareTableStatements(f.keyspace, f.object)
+			}
 		case *schemaChangeAggregate:
 			s.schemaDescriber.clearSchema(f.keyspace)
 		case *schemaChangeFunction:
@@ -124,6 +204,91 @@ func (s *Session) handleSchemaEvent(frames []frame) {
 	}
 }
 
+// reprepareTableStatements prepares again on their hosts the cached statements
+// referencing the altered table, once the schema agreed, so that requests do
+// not have to hit UNPREPARED or stale result metadata first.
+func (s *Session) reprepareTableStatements(keyspace, table string) {
+	if s.control != nil {
+		if err := s.control.awaitSchemaAgreement(); err != nil {
+			s.logger.Printf("gocql: unable to reprepare statements of %s.%s: %v\n", keyspace, table, err)
+			return
+		}
+	}
+
+	stmts := s.stmtsLRU.takeStatements(func(ifp *inflightPrepare) bool {
+		return statementReferencesTable(ifp.statement, ifp.keyspace, keyspace, table)
+	})
+	if len(stmts) == 0 {
+		return
+	}
+
+	byHost := make(map[string][]*inflightPrepare)
+	for _, ifp := range stmts {
+		byHost[ifp.hostID] = append(byHost[ifp.hostID], ifp)
+	}
+	for hostID, stmts := range byHost {
+		host := s.ring.getHost(hostID)
+		if host == nil {
+			continue
+		}
+		pool, ok := s.pool.getPool(host)
+		if !ok {
+			continue
+		}
+		go func(pool *hostConnPool, stmts []*inflightPrepare) {
+			for _, ifp := range stmts {
+				conn := pool.Pick()
+				if conn == nil {
+					return
+				}
+				if conn.currentKeyspace != ifp.keyspace {
+					// prepared on its next use
+					continue
+				}
+				if _, err := conn.prepareStatement(s.ctx, ifp.statement, nil); err != nil {
+					if s.ctx.Err() != nil {
+						return
+					}
+					s.logger.Printf("gocql: unable to reprepare %q on %s: %v\n", ifp.statement, pool.host.ConnectAddress(), err)
+				}
+			}
+		}(pool, stmts)
+	}
+}
+
+// statementReferencesTable reports whether stmt, prepared in stmtKeyspace,
+// may reference keyspace.table. Identifiers are compared ignoring case, which
+// at worst prepares some statements again needlessly.
+func statementReferencesTable(stmt, stmtKeyspace, keyspace, table string) bool {
+	stmt = strings.ToLower(stmt)
+	if !containsIdentifier(stmt, strings.ToLower(table)) {
+		return false
+	}
+	return stmtKeyspace == keyspace || containsIdentifier(stmt, strings.ToLower(keyspace))
+}
+
+// containsIdentifier reports whether s contains ident not surrounded by
+// other identifier characters.
+func containsIdentifier(s, ident string) bool {
+	if ident == "" {
+		return false
+	}
+	isIdent := func(c byte) bool {
+		return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+	for i := 0; ; {
+		j := strings.Index(s[i:], ident)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(ident)
+		if (start == 0 || !isIdent(s[start-1])) && (end == len(s) || !isIdent(s[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
 func (s *Session) handleKeyspaceChange(keyspace, change string) {
 	s.control.awaitSchemaAgreement()
 	s.policy.KeyspaceChanged(KeyspaceUpdateEvent{Keyspace: keyspace, Change: change})
@@ -224,6 +389,7 @@ func (s *Session) handleNodeConnected(host *HostInfo) {
 
 	if !s.cfg.filterHost(host) {
 		s.policy.HostUp(host)
+		s.nodeEventSubscribers.publish(NodeEventUp, host)
 	}
 }
 
@@ -242,5 +408,6 @@ func (s *Session) handleNodeDown(ip net.IP, port int) {
 		s.policy.HostDown(host)
 		hostID := host.HostID()
 		s.pool.removeHost(hostID)
+		s.nodeEventSubscribers.publish(NodeEventDown, host)
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"net"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestEventDebounce(t *testing.T) {
@@ -12,7 +13,7 @@ func TestEventDebounce(t *testing.T) {
 	wg.Add(1)
 
 	eventsSeen := 0
-	debouncer := newEventDebouncer("testDebouncer", func(events []frame) {
+	debouncer := newEventDebouncer("testDebouncer", eventDebounceTime, 0, systemClock{}, func(events []frame) {
 		defer wg.Done()
 		eventsSeen += len(events)
 	}, &defaultLogger{})
@@ -31,3 +32,106 @@ func TestEventDebounce(t *testing.T) {
 		t.Fatalf("expected to see %d events but got %d", eventCount, eventsSeen)
 	}
 }
+
+func TestEventDebounceMaxDelay(t *testing.T) {
+	flushed := make(chan int, 10)
+	debouncer := newEventDebouncer("testDebouncer", 100*time.Millisecond, 300*time.Millisecond, systemClock{}, func(events []frame) {
+		flushed <- len(events)
+	}, &defaultLogger{})
+	defer debouncer.stop()
+
+	// events keep coming within the debounce window, as from a flapping node
+	start := time.Now()
+	stop := time.After(time.Second)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	var first time.Duration
+	flushes := 0
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			debouncer.debounce(&statusChangeEventFrame{change: "DOWN", host: net.IPv4(127, 0, 0, 1), port: 9042})
+		case n := <-flushed:
+			if flushes == 0 {
+				first = time.Since(start)
+			}
+			if n == 0 {
+				t.Fatal("flushed no events")
+			}
+			flushes++
+		case <-stop:
+			break loop
+		}
+	}
+
+	if flushes == 0 {
+		t.Fatal("events were never flushed while they kept coming")
+	}
+	if first > 600*time.Millisecond {
+		t.Fatalf("first flush after %v, expected about 300ms", first)
+	}
+	if flushes > 5 {
+		t.Fatalf("expected bursts to be coalesced, got %d flushes", flushes)
+	}
+}
+
+// steppingClock is a system clock whose time advances by step on every call
+// of Now.
+type steppingClock struct {
+	systemClock
+	step time.Duration
+
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *steppingClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(c.step)
+	return c.now
+}
+
+func TestEventDebounceClock(t *testing.T) {
+	flushed := make(chan int, 1)
+	clock := &steppingClock{step: time.Hour}
+	debouncer := newEventDebouncer("testDebouncer", 2*time.Hour, time.Hour, clock, func(events []frame) {
+		flushed <- len(events)
+	}, &defaultLogger{})
+	defer debouncer.stop()
+
+	// the second event comes an hour after the first on the clock, when the
+	// max delay is reached
+	for i := 0; i < 2; i++ {
+		debouncer.debounce(&statusChangeEventFrame{change: "DOWN", host: net.IPv4(127, 0, 0, 1), port: 9042})
+	}
+
+	select {
+	case n := <-flushed:
+		if n != 2 {
+			t.Fatalf("expected 2 events got %d", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the events were not flushed once the max delay elapsed on the clock")
+	}
+}
+
+func TestDebounceDelay(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		interval, maxDelay time.Duration
+		deadline           time.Time
+		expected           time.Duration
+	}{
+		{time.Second, 0, now, time.Second},
+		{time.Second, 10 * time.Second, now.Add(5 * time.Second), time.Second},
+		{time.Second, 10 * time.Second, now.Add(300 * time.Millisecond), 300 * time.Millisecond},
+		{time.Second, 10 * time.Second, now.Add(-time.Second), 0},
+	}
+	for i, test := range tests {
+		if got := debounceDelay(now, test.interval, test.maxDelay, test.deadline); got != test.expected {
+			t.Errorf("%d: expected %v got %v", i, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package gocql
+
+// RoutingExplanation is how a query would be routed, see Session.ExplainRouting.
+type RoutingExplanation struct {
+	// Keyspace and Table the query is executed against. Table is only known
+	// when the routing key was computed from the metadata of the statement.
+	Keyspace string
+	Table    string
+
+	// RoutingKey is nil if it could not be determined.
+	RoutingKey []byte
+	// Token of the routing key, empty unless the host selection policy is
+	// token aware.
+	Token string
+
+	// Hosts are the hosts in the order the host selection policy would try
+	// them, starting with the replicas if the policy is token aware.
+	Hosts []*HostInfo
+
+	// Host is the first host of the plan which is up and has a connection,
+	// nil if there is none.
+	Host *HostInfo
+	// Shard is the shard of Host owning the token, or -1 if unknown.
+	Shard int
+	// ConnShard is the shard of the connection which would be picked, or -1
+	// if the host is not sharded. It differs from Shard if no connection to
+	// the shard owning the token is available.
+	ConnShard int
+	// ConnLocalAddr is the local address of the connection which would be
+	// picked, empty if there is none.
+	ConnLocalAddr string
+}
+
+// ExplainRouting returns how the query would be routed if it was executed
+// now, without executing it. The statement may be prepared to determine its
+// routing key. The state of the builtin host selection policies and of the
+// connection pools is not changed, such as the offset of a round robin, so
+// that explaining does not change the routing of the next query; a
+// HostPoolHostPolicy or a custom policy is picked from as for a query.
+func (s *Session) ExplainRouting(qry *Query) (*RoutingExplanation, error) {
+	if s.Closed() {
+		return nil, ErrSessionClosed
+	}
+
+	routingKey, err := qry.GetRoutingKey()
+	if err != nil {
+		return nil, err
+	}
+	e := &RoutingExplanation{
+		Keyspace:   qry.Keyspace(),
+		Table:      qry.Table(),
+		RoutingKey: routingKey,
+		Shard:      -1,
+		ConnShard:  -1,
+	}
+
+	hostIter := peekPlan(s.policy, qry)
+	seen := make(map[*HostInfo]bool)
+	for selectedHost := hostIter(); selectedHost != nil; selectedHost = hostIter() {
+		host := selectedHost.Info()
+		if host == nil {
+			continue
+		}
+		// stop if the policy cycles through the hosts
+		if seen[host] {
+			break
+		}
+		seen[host] = true
+		e.Hosts = append(e.Hosts, host)
+
+		sh, isShardSelected := selectedHost.(*shardSelectedHost)
+		if isShardSelected && e.Token == "" && sh.token != nil {
+			e.Token = sh.token.String()
+		}
+
+		if e.Host != nil || !host.IsUp() {
+			continue
+		}
+		pool, ok := s.pool.getPool(host)
+		if !ok {
+			continue
+		}
+		conn := pool.peekFor(selectedHost)
+		if conn == nil {
+			continue
+		}
+
+		e.Host = host
+		e.ConnShard = conn.shard()
+		e.ConnLocalAddr = conn.conn.LocalAddr().String()
+		if isShardSelected {
+			pool.mu.RLock()
+			sharding := pool.sharding
+			pool.mu.RUnlock()
+			if sharding.nrShards > 1 {
+				e.Shard = sh.targetShard(sharding)
+			}
+		}
+	}
+	return e, nil
+}
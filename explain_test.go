@@ -0,0 +1,129 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExplainRouting(t *testing.T) {
+	const (
+		keyspace = "ks"
+		nrShards = 2
+	)
+	var (
+		mu    sync.Mutex
+		conns int
+	)
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		supported: func(conn net.Conn) map[string][]string {
+			mu.Lock()
+			defer mu.Unlock()
+			shard := strconv.Itoa(conns % nrShards)
+			conns++
+			return map[string][]string{
+				scyllaShard:             {shard},
+				scyllaNrShards:          {strconv.Itoa(nrShards)},
+				scyllaPartitioner:       {"org.apache.cassandra.dht.Murmur3Partitioner"},
+				scyllaShardingAlgorithm: {"biased-token-round-robin"},
+				scyllaShardingIgnoreMSB: {"12"},
+			}
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	policy := TokenAwareHostPolicy(RoundRobinHostPolicy())
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = nrShards
+	cluster.DisableShardAwarePort = true
+	cluster.PoolConfig.HostSelectionPolicy = policy
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	host := db.ring.allHosts()[0]
+	pool, ok := db.pool.getPool(host)
+	if !ok {
+		t.Fatal("no pool for host")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.Size() < nrShards {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d connections got %d", nrShards, pool.Size())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the query is routed using the metadata of the cluster
+	host.mu.Lock()
+	host.tokens = []string{"0"}
+	host.mu.Unlock()
+	policyInternal := policy.(*tokenAwareHostPolicy)
+	policyInternal.getKeyspaceName = func() string { return keyspace }
+	policyInternal.getKeyspaceMetadata = func(ks string) (*KeyspaceMetadata, error) {
+		return &KeyspaceMetadata{
+			Name:          keyspace,
+			StrategyClass: "SimpleStrategy",
+			StrategyOptions: map[string]interface{}{
+				"class":              "SimpleStrategy",
+				"replication_factor": 1,
+			},
+		}, nil
+	}
+	policy.SetPartitioner("Murmur3Partitioner")
+
+	routingKey := []byte("key")
+	qry := db.Query("void").RoutingKey(routingKey)
+	qry.getKeyspace = func() string { return keyspace }
+	e, err := db.ExplainRouting(qry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := murmur3Partitioner{}.Hash(routingKey).(murmur3Token)
+	shard := parseScyllaSupported(map[string][]string{
+		scyllaShard:             {"0"},
+		scyllaNrShards:          {strconv.Itoa(nrShards)},
+		scyllaShardingIgnoreMSB: {"12"},
+	}).shardForToken(int64(token))
+
+	if e.Keyspace != keyspace || !bytes.Equal(e.RoutingKey, routingKey) || e.Token != token.String() {
+		t.Fatalf("expected keyspace %q routing key %q token %v got %+v", keyspace, routingKey, token, e)
+	}
+	if len(e.Hosts) != 1 || e.Hosts[0] != host || e.Host != host {
+		t.Fatalf("expected the host %v got %+v", host, e)
+	}
+	if e.Shard != shard || e.ConnShard != shard || e.ConnLocalAddr == "" {
+		t.Fatalf("expected a connection to the shard %d got %+v", shard, e)
+	}
+
+	// explaining does not change the routing of the next query
+	rr := policyInternal.fallback.(*roundRobinHostPolicy)
+	lastUsedHostIdx, pos := atomic.LoadUint64(&rr.lastUsedHostIdx), atomic.LoadUint32(&pool.pos)
+	if _, err := db.ExplainRouting(qry); err != nil {
+		t.Fatal(err)
+	}
+	if conn := pool.peekFor((*selectedHost)(host)); conn == nil {
+		t.Fatal("expected a connection")
+	}
+	if atomic.LoadUint64(&rr.lastUsedHostIdx) != lastUsedHostIdx || atomic.LoadUint32(&pool.pos) != pos {
+		t.Fatal("explaining the routing changed the state of the policy or the pool")
+	}
+
+	db.Close()
+	if _, err := db.ExplainRouting(qry); err != ErrSessionClosed {
+		t.Fatalf("expected %v got %v", ErrSessionClosed, err)
+	}
+}
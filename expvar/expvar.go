@@ -0,0 +1,75 @@
+// Package expvar publishes the live statistics of a gocql session under
+// expvar, for applications scraping /debug/vars.
+//
+// Importing the standard expvar package registers /debug/vars on
+// http.DefaultServeMux, which serves the command line and the memory
+// statistics of the process, so it is only imported by the applications
+// importing this package.
+package expvar
+
+import (
+	"expvar"
+	"net"
+	"strconv"
+
+	"github.com/gocql/gocql"
+)
+
+// stats are the statistics of a session, see gocql.Session.
+type stats interface {
+	TopologySnapshot() *gocql.TopologySnapshot
+	ErrorStats() gocql.ErrorStats
+	HostErrorStats() map[string]gocql.ErrorStats
+	LatencyStats() gocql.LatencyStats
+	PreparedCacheStats() gocql.PreparedCacheStats
+	PeerStats() gocql.PeerStats
+}
+
+// Publish publishes the live statistics of session under expvar, as a map
+// named prefix served by /debug/vars with the entries:
+//
+//	pools           the PoolSnapshot of each host, by address
+//	errors          the ErrorStats of the session
+//	host_errors     the ErrorStats of each host, by host ID
+//	latencies       the LatencyStats of the session, in nanoseconds
+//	prepared_cache  the PreparedCacheStats of the session
+//	peers           the PeerStats of the session
+//
+// The statistics are read when the variables are. Publishing another session
+// under the same prefix, for example after reconnecting, replaces the
+// session. It panics if prefix is the name of another expvar variable.
+func Publish(session *gocql.Session, prefix string) {
+	publish(session, prefix)
+}
+
+func publish(session stats, prefix string) {
+	vars, ok := expvar.Get(prefix).(*expvar.Map)
+	if !ok {
+		vars = expvar.NewMap(prefix)
+	}
+
+	vars.Set("pools", expvar.Func(func() interface{} {
+		pools := make(map[string]*gocql.PoolSnapshot)
+		for _, host := range session.TopologySnapshot().Hosts {
+			if host.Pool != nil {
+				pools[net.JoinHostPort(host.ConnectAddress, strconv.Itoa(host.Port))] = host.Pool
+			}
+		}
+		return pools
+	}))
+	vars.Set("errors", expvar.Func(func() interface{} {
+		return session.ErrorStats()
+	}))
+	vars.Set("host_errors", expvar.Func(func() interface{} {
+		return session.HostErrorStats()
+	}))
+	vars.Set("latencies", expvar.Func(func() interface{} {
+		return session.LatencyStats()
+	}))
+	vars.Set("prepared_cache", expvar.Func(func() interface{} {
+		return session.PreparedCacheStats()
+	}))
+	vars.Set("peers", expvar.Func(func() interface{} {
+		return session.PeerStats()
+	}))
+}
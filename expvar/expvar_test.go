@@ -0,0 +1,83 @@
+package expvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+type fakeSession struct {
+	errors gocql.ErrorStats
+}
+
+func (s *fakeSession) TopologySnapshot() *gocql.TopologySnapshot {
+	return &gocql.TopologySnapshot{Hosts: []gocql.HostSnapshot{
+		{ConnectAddress: "10.0.0.1", Port: 9042, Pool: &gocql.PoolSnapshot{Size: 2, Conns: 2}},
+		{ConnectAddress: "10.0.0.2", Port: 9042},
+	}}
+}
+
+func (s *fakeSession) ErrorStats() gocql.ErrorStats {
+	return s.errors
+}
+
+func (s *fakeSession) HostErrorStats() map[string]gocql.ErrorStats {
+	return map[string]gocql.ErrorStats{"host1": s.errors}
+}
+
+func (s *fakeSession) LatencyStats() gocql.LatencyStats {
+	return gocql.LatencyStats{
+		Attempts: 2,
+		Total:    3 * time.Millisecond,
+		Buckets:  []gocql.LatencyBucket{{UpperBound: time.Millisecond, Count: 1}, {UpperBound: 2 * time.Millisecond, Count: 1}},
+	}
+}
+
+func (s *fakeSession) PreparedCacheStats() gocql.PreparedCacheStats {
+	return gocql.PreparedCacheStats{Hits: 3, Misses: 1, Size: 1}
+}
+
+func (s *fakeSession) PeerStats() gocql.PeerStats {
+	return gocql.PeerStats{Accepted: 2}
+}
+
+func TestPublish(t *testing.T) {
+	session := &fakeSession{errors: gocql.ErrorStats{Overloaded: 1}}
+	publish(session, "gocql_test")
+
+	var vars struct {
+		Pools      map[string]gocql.PoolSnapshot `json:"pools"`
+		Errors     gocql.ErrorStats              `json:"errors"`
+		HostErrors map[string]gocql.ErrorStats   `json:"host_errors"`
+		Latencies  gocql.LatencyStats            `json:"latencies"`
+		Prepared   gocql.PreparedCacheStats      `json:"prepared_cache"`
+		Peers      gocql.PeerStats               `json:"peers"`
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("gocql_test").String()), &vars); err != nil {
+		t.Fatal(err)
+	}
+	if len(vars.Pools) != 1 || vars.Pools["10.0.0.1:9042"].Conns != 2 {
+		t.Errorf("expected the pool of 10.0.0.1:9042, got %v", vars.Pools)
+	}
+	if vars.Errors.Overloaded != 1 || vars.HostErrors["host1"].Overloaded != 1 {
+		t.Errorf("expected 1 overloaded error, got %+v and %+v", vars.Errors, vars.HostErrors)
+	}
+	if vars.Latencies.Attempts != 2 || len(vars.Latencies.Buckets) != 2 {
+		t.Errorf("expected 2 attempts, got %+v", vars.Latencies)
+	}
+	if vars.Prepared.Hits != 3 || vars.Peers.Accepted != 2 {
+		t.Errorf("unexpected statistics %+v and %+v", vars.Prepared, vars.Peers)
+	}
+
+	// publishing again replaces the session
+	publish(&fakeSession{}, "gocql_test")
+	if err := json.Unmarshal([]byte(expvar.Get("gocql_test").String()), &vars); err != nil {
+		t.Fatal(err)
+	}
+	if vars.Errors.Overloaded != 0 {
+		t.Errorf("expected the errors of the new session, got %+v", vars.Errors)
+	}
+}
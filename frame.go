@@ -6,13 +6,13 @@ package gocql
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -306,9 +306,112 @@ const (
 )
 
 var (
-	ErrFrameTooBig = errors.New("frame length is bigger than the maximum allowed")
+	ErrFrameTooBig = newCategorizedError(ErrConnectionCategory, "frame length is bigger than the maximum allowed")
 )
 
+// ErrFrameTooLarge is returned when the response to a query is bigger than
+// ClusterConfig.MaxResponseFrameSize. The response is discarded without being
+// buffered and the connection it was received on remains usable.
+//
+// errors.Is(err, ErrFrameTooBig) and errors.Is(err, ErrConnectionCategory)
+// report true for an ErrFrameTooLarge.
+type ErrFrameTooLarge struct {
+	// Statement is the statement of the query which caused the response, if known.
+	Statement string
+	// Length is the length of the response frame body.
+	Length int
+	// Limit is the configured MaxResponseFrameSize.
+	Limit int
+}
+
+func (e *ErrFrameTooLarge) Error() string {
+	if e.Statement == "" {
+		return fmt.Sprintf("gocql: response frame of %d bytes exceeds the maximum response frame size of %d bytes", e.Length, e.Limit)
+	}
+	return fmt.Sprintf("gocql: response frame of %d bytes exceeds the maximum response frame size of %d bytes for statement %q", e.Length, e.Limit, e.Statement)
+}
+
+func (e *ErrFrameTooLarge) Is(target error) bool {
+	return target == ErrFrameTooBig || target == ErrConnectionCategory
+}
+
+// ErrRequestTooLarge is returned when a request is bigger than
+// ClusterConfig.MaxRequestFrameSize. The request is not sent, instead of the
+// server closing the connection it was sent on.
+//
+// errors.Is(err, ErrFrameTooBig) reports true for an ErrRequestTooLarge.
+type ErrRequestTooLarge struct {
+	// Statement is the statement of the query, if the request was one.
+	Statement string
+	// Size is the size of the request frame body, compressed if compression
+	// is enabled.
+	Size int
+	// Limit is the configured MaxRequestFrameSize.
+	Limit int
+}
+
+func (e *ErrRequestTooLarge) Error() string {
+	if e.Statement == "" {
+		return fmt.Sprintf("gocql: request frame of %d bytes exceeds the maximum request frame size of %d bytes", e.Size, e.Limit)
+	}
+	return fmt.Sprintf("gocql: request frame of %d bytes exceeds the maximum request frame size of %d bytes for statement %q", e.Size, e.Limit, e.Statement)
+}
+
+func (e *ErrRequestTooLarge) Is(target error) bool {
+	return target == ErrFrameTooBig || target == ErrConnectionCategory
+}
+
+// FrameDirection is the direction of a frame on a connection.
+type FrameDirection int
+
+const (
+	// FrameInbound is a frame received from the host.
+	FrameInbound FrameDirection = iota
+	// FrameOutbound is a frame sent to the host.
+	FrameOutbound
+)
+
+func (d FrameDirection) String() string {
+	switch d {
+	case FrameInbound:
+		return "inbound"
+	case FrameOutbound:
+		return "outbound"
+	}
+	return fmt.Sprintf("unknown direction %d", int(d))
+}
+
+// FrameCorruptionError is returned when a frame is corrupted: the header of a
+// received frame is invalid or its body can not be decompressed, or the body
+// of a frame to send can not be compressed.
+//
+// The connection an inbound corrupted frame was received on is closed, since
+// the frames following it can not be trusted, and the error is returned to
+// all the queries waiting on the connection. Inbound errors therefore belong
+// to ErrConnectionCategory. Outbound frames which can not be compressed are
+// not sent and the connection remains usable.
+type FrameCorruptionError struct {
+	Host *HostInfo
+	// LocalAddr and RemoteAddr are the addresses of the connection.
+	LocalAddr  string
+	RemoteAddr string
+	Direction  FrameDirection
+	Err        error
+}
+
+func (e *FrameCorruptionError) Error() string {
+	return fmt.Sprintf("gocql: corrupted %v frame on connection %s->%s: %v", e.Direction, e.LocalAddr, e.RemoteAddr, e.Err)
+}
+
+func (e *FrameCorruptionError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrConnectionCategory for inbound frames.
+func (e *FrameCorruptionError) Is(target error) bool {
+	return e.Direction == FrameInbound && target == ErrConnectionCategory
+}
+
 const maxFrameHeaderSize = 9
 
 func readInt(p []byte) int32 {
@@ -382,6 +485,20 @@ type framer struct {
 	buf []byte
 
 	customPayload map[string][]byte
+
+	// readLimit is the maximum size of a frame body read by readFrame, if
+	// it is set and lower than maxFrameSize.
+	readLimit int
+	// writeLimit is the maximum size of a frame body built by finish, if it
+	// is set.
+	writeLimit int
+	// rateLimitErrorCode is the error code of Scylla's rate limit error
+	// negotiated with the node, if any.
+	rateLimitErrorCode int
+
+	// body is the part of the body not read yet of a frame read with
+	// streamFrame, nil for frames read whole.
+	body *frameBody
 }
 
 func newFramer(compressor Compressor, version byte) *framer {
@@ -429,7 +546,10 @@ func readHeader(r io.Reader, p []byte) (head frameHeader, err error) {
 	version := p[0] & protoVersionMask
 
 	if version < protoVersion1 || version > protoVersion5 {
-		return frameHeader{}, fmt.Errorf("gocql: unsupported protocol response version: %d", version)
+		return frameHeader{}, &FrameCorruptionError{
+			Direction: FrameInbound,
+			Err:       fmt.Errorf("gocql: unsupported protocol response version: %d", version),
+		}
 	}
 
 	headSize := 9
@@ -481,7 +601,10 @@ func (f *framer) payload() {
 // reads a frame form the wire into the framers buffer
 func (f *framer) readFrame(r io.Reader, head *frameHeader) error {
 	if head.length < 0 {
-		return fmt.Errorf("frame body length can not be less than 0: %d", head.length)
+		return &FrameCorruptionError{
+			Direction: FrameInbound,
+			Err:       fmt.Errorf("frame body length can not be less than 0: %d", head.length),
+		}
 	} else if head.length > maxFrameSize {
 		// need to free up the connection to be used again
 		_, err := io.CopyN(ioutil.Discard, r, int64(head.length))
@@ -489,6 +612,13 @@ func (f *framer) readFrame(r io.Reader, head *frameHeader) error {
 			return fmt.Errorf("error whilst trying to discard frame with invalid length: %v", err)
 		}
 		return ErrFrameTooBig
+	} else if f.readLimit > 0 && head.length > f.readLimit {
+		// discard the body so that the connection can be used again
+		_, err := io.CopyN(ioutil.Discard, r, int64(head.length))
+		if err != nil {
+			return fmt.Errorf("error whilst trying to discard frame with invalid length: %v", err)
+		}
+		return &ErrFrameTooLarge{Length: head.length, Limit: f.readLimit}
 	}
 
 	if cap(f.readBuffer) >= head.length {
@@ -506,12 +636,18 @@ func (f *framer) readFrame(r io.Reader, head *frameHeader) error {
 
 	if head.flags&flagCompress == flagCompress {
 		if f.compres == nil {
-			return NewErrProtocol("no compressor available with compressed frame body")
+			return &FrameCorruptionError{
+				Direction: FrameInbound,
+				Err:       NewErrProtocol("no compressor available with compressed frame body"),
+			}
 		}
 
 		f.buf, err = f.compres.Decode(f.buf)
 		if err != nil {
-			return err
+			return &FrameCorruptionError{Direction: FrameInbound, Err: err}
+		}
+		if f.readLimit > 0 && len(f.buf) > f.readLimit {
+			return &ErrFrameTooLarge{Length: len(f.buf), Limit: f.readLimit}
 		}
 	}
 
@@ -519,6 +655,160 @@ func (f *framer) readFrame(r io.Reader, head *frameHeader) error {
 	return nil
 }
 
+// frameBody is the rest of the body of a frame which is read from the
+// connection as the frame is parsed and its rows are consumed, see
+// Query.StreamCells.
+type frameBody struct {
+	r *io.LimitedReader
+	// threshold is the size of the cells which LazyRow does not buffer.
+	threshold int
+	// err is the error which occurred reading r, the connection can not be
+	// used anymore.
+	err error
+
+	// released is closed once the frame was consumed, so the connection can
+	// read the next frames.
+	released chan struct{}
+	once     sync.Once
+}
+
+// streamChunkSize is the size of the reads of the body of a streamed frame.
+const streamChunkSize = 64 * 1024
+
+// streamFrame is like readFrame, but it leaves the body of the frame in r,
+// it is read as the frame is parsed, see fill. releaseBody must be called
+// once the frame was consumed.
+func (f *framer) streamFrame(r io.Reader, head *frameHeader, threshold int) {
+	f.buf = f.readBuffer[:0]
+	f.body = &frameBody{
+		r:         &io.LimitedReader{R: r, N: int64(head.length)},
+		threshold: threshold,
+		released:  make(chan struct{}),
+	}
+	f.header = head
+}
+
+// fill reads the body of a streamed frame until buf holds at least n bytes
+// or the body was read, it panics if the read fails. It does nothing for
+// frames which were read whole.
+func (f *framer) fill(n int) {
+	if err := f.fillBytes(n); err != nil {
+		panic(err)
+	}
+}
+
+// fillBytes is like fill, but it returns the error.
+func (f *framer) fillBytes(n int) error {
+	b := f.body
+	if b == nil || len(f.buf) >= n || b.r.N == 0 {
+		return nil
+	}
+	if b.err != nil {
+		return b.err
+	}
+
+	// the bytes of buf might be referenced by the values read already, so
+	// they are copied to a new buffer
+	size := n
+	if size < streamChunkSize {
+		size = streamChunkSize
+	}
+	if left := len(f.buf) + int(b.r.N); size > left {
+		size = left
+	}
+	buf := make([]byte, size)
+	copy(buf, f.buf)
+	m, err := io.ReadFull(b, buf[len(f.buf):])
+	f.buf = buf[:len(f.buf)+m]
+	return err
+}
+
+// copyBytes writes the next n bytes of the body to w, the ones which are
+// not buffered are copied from the connection. The n bytes are consumed
+// even if writing them fails.
+func (f *framer) copyBytes(w io.Writer, n int) (int64, error) {
+	buffered := n
+	if buffered > len(f.buf) {
+		buffered = len(f.buf)
+	}
+	written, err := w.Write(f.buf[:buffered])
+	f.buf = f.buf[buffered:]
+	if err != nil || buffered == n {
+		if serr := f.skipStreamed(n - buffered); serr != nil {
+			return int64(written), serr
+		}
+		return int64(written), err
+	}
+
+	b := f.body
+	if b == nil || b.r.N < int64(n-buffered) {
+		return int64(written), fmt.Errorf("not enough bytes in buffer to read bytes require %d got: %d", n, buffered)
+	}
+	if b.err != nil {
+		return int64(written), b.err
+	}
+	copied, err := io.CopyN(w, b, int64(n-buffered))
+	if b.err != nil {
+		return int64(written) + copied, b.err
+	}
+	if err != nil {
+		if serr := f.skipStreamed(n - buffered - int(copied)); serr != nil {
+			return int64(written) + copied, serr
+		}
+	}
+	return int64(written) + copied, err
+}
+
+// peekInt returns the next int of the body without consuming it.
+func (f *framer) peekInt() (int, error) {
+	if err := f.fillBytes(4); err != nil {
+		return 0, err
+	}
+	if len(f.buf) < 4 {
+		return 0, fmt.Errorf("not enough bytes in buffer to read int require 4 got: %d", len(f.buf))
+	}
+	return int(int32(f.buf[0])<<24 | int32(f.buf[1])<<16 | int32(f.buf[2])<<8 | int32(f.buf[3])), nil
+}
+
+// skipBytes discards the next n bytes of the body.
+func (f *framer) skipBytes(n int) error {
+	_, err := f.copyBytes(ioutil.Discard, n)
+	return err
+}
+
+// skipStreamed discards the next n bytes of the body which are not buffered.
+func (f *framer) skipStreamed(n int) error {
+	b := f.body
+	if b == nil || n <= 0 {
+		return nil
+	}
+	if b.err != nil {
+		return b.err
+	}
+	_, err := io.CopyN(ioutil.Discard, b, int64(n))
+	return err
+}
+
+// Read reads the body from the connection, recording the error if it fails.
+func (b *frameBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && b.err == nil && !(err == io.EOF && b.r.N == 0) {
+		b.err = fmt.Errorf("unable to read frame body: %v", err)
+	}
+	return n, err
+}
+
+// releaseBody lets the connection read the next frames once a streamed
+// frame was consumed, the rest of its body is discarded.
+func (f *framer) releaseBody() {
+	if f == nil || f.body == nil {
+		return
+	}
+	f.body.once.Do(func() {
+		close(f.body.released)
+	})
+}
+
 func (f *framer) parseFrame() (frame frame, err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -580,6 +870,16 @@ func (f *framer) parseErrorFrame() frame {
 		message:     msg,
 	}
 
+	if f.rateLimitErrorCode != 0 && code == f.rateLimitErrorCode {
+		opType := OpType(f.readByte())
+		rejectedByCoordinator := f.readByte() != 0
+		return &RequestErrRateLimitReached{
+			errorFrame:            errD,
+			OpType:                opType,
+			RejectedByCoordinator: rejectedByCoordinator,
+		}
+	}
+
 	switch code {
 	case ErrCodeUnavailable:
 		cl := f.readConsistency()
@@ -596,12 +896,17 @@ func (f *framer) parseErrorFrame() frame {
 		received := f.readInt()
 		blockfor := f.readInt()
 		writeType := f.readString()
+		var contentions int
+		if f.proto > protoVersion4 && writeType == WriteTypeCAS {
+			contentions = int(f.readShort())
+		}
 		return &RequestErrWriteTimeout{
 			errorFrame:  errD,
 			Consistency: cl,
 			Received:    received,
 			BlockFor:    blockfor,
 			WriteType:   writeType,
+			Contentions: contentions,
 		}
 	case ErrCodeReadTimeout:
 		cl := f.readConsistency()
@@ -756,12 +1061,15 @@ func (f *framer) finish() error {
 		// TODO: only compress frames which are big enough
 		compressed, err := f.compres.Encode(f.buf[f.headSize:])
 		if err != nil {
-			return err
+			return &FrameCorruptionError{Direction: FrameOutbound, Err: err}
 		}
 
 		f.buf = append(f.buf[:f.headSize], compressed...)
 	}
 	length := len(f.buf) - f.headSize
+	if f.writeLimit > 0 && length > f.writeLimit {
+		return &ErrRequestTooLarge{Size: length, Limit: f.writeLimit}
+	}
 	f.setLength(length)
 
 	return nil
@@ -1749,6 +2057,7 @@ func (f *framer) writeRegisterFrame(streamID int, w *writeRegisterFrame) error {
 }
 
 func (f *framer) readByte() byte {
+	f.fill(1)
 	if len(f.buf) < 1 {
 		panic(fmt.Errorf("not enough bytes in buffer to read byte require 1 got: %d", len(f.buf)))
 	}
@@ -1759,6 +2068,7 @@ func (f *framer) readByte() byte {
 }
 
 func (f *framer) readInt() (n int) {
+	f.fill(4)
 	if len(f.buf) < 4 {
 		panic(fmt.Errorf("not enough bytes in buffer to read int require 4 got: %d", len(f.buf)))
 	}
@@ -1769,6 +2079,7 @@ func (f *framer) readInt() (n int) {
 }
 
 func (f *framer) readShort() (n uint16) {
+	f.fill(2)
 	if len(f.buf) < 2 {
 		panic(fmt.Errorf("not enough bytes in buffer to read short require 2 got: %d", len(f.buf)))
 	}
@@ -1780,6 +2091,7 @@ func (f *framer) readShort() (n uint16) {
 func (f *framer) readString() (s string) {
 	size := f.readShort()
 
+	f.fill(int(size))
 	if len(f.buf) < int(size) {
 		panic(fmt.Errorf("not enough bytes in buffer to read string require %d got: %d", size, len(f.buf)))
 	}
@@ -1792,6 +2104,7 @@ func (f *framer) readString() (s string) {
 func (f *framer) readLongString() (s string) {
 	size := f.readInt()
 
+	f.fill(size)
 	if len(f.buf) < size {
 		panic(fmt.Errorf("not enough bytes in buffer to read long string require %d got: %d", size, len(f.buf)))
 	}
@@ -1802,6 +2115,7 @@ func (f *framer) readLongString() (s string) {
 }
 
 func (f *framer) readUUID() *UUID {
+	f.fill(16)
 	if len(f.buf) < 16 {
 		panic(fmt.Errorf("not enough bytes in buffer to read uuid require %d got: %d", 16, len(f.buf)))
 	}
@@ -1824,11 +2138,17 @@ func (f *framer) readStringList() []string {
 }
 
 func (f *framer) readBytesInternal() ([]byte, error) {
+	if err := f.fillBytes(4); err != nil {
+		return nil, err
+	}
 	size := f.readInt()
 	if size < 0 {
 		return nil, nil
 	}
 
+	if err := f.fillBytes(size); err != nil {
+		return nil, err
+	}
 	if len(f.buf) < size {
 		return nil, fmt.Errorf("not enough bytes in buffer to read bytes require %d got: %d", size, len(f.buf))
 	}
@@ -1850,6 +2170,7 @@ func (f *framer) readBytes() []byte {
 
 func (f *framer) readShortBytes() []byte {
 	size := f.readShort()
+	f.fill(int(size))
 	if len(f.buf) < int(size) {
 		panic(fmt.Errorf("not enough bytes in buffer to read short bytes: require %d got %d", size, len(f.buf)))
 	}
@@ -1861,6 +2182,7 @@ func (f *framer) readShortBytes() []byte {
 }
 
 func (f *framer) readInetAdressOnly() net.IP {
+	f.fill(1)
 	if len(f.buf) < 1 {
 		panic(fmt.Errorf("not enough bytes in buffer to read inet size require %d got: %d", 1, len(f.buf)))
 	}
@@ -1872,6 +2194,7 @@ func (f *framer) readInetAdressOnly() net.IP {
 		panic(fmt.Errorf("invalid IP size: %d", size))
 	}
 
+	f.fill(int(size))
 	if len(f.buf) < 1 {
 		panic(fmt.Errorf("not enough bytes in buffer to read inet require %d got: %d", size, len(f.buf)))
 	}
@@ -2,6 +2,7 @@ package gocql
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"testing"
 )
@@ -103,3 +104,39 @@ func TestFrameReadTooLong(t *testing.T) {
 		t.Fatalf("expected to get header %v got %v", opReady, head.op)
 	}
 }
+
+func TestFrameReadLimit(t *testing.T) {
+	r := &bytes.Buffer{}
+	r.Write(make([]byte, 1024))
+	// write a new header right after this frame to verify that we can read it
+	r.Write([]byte{0x02, 0x00, 0x00, byte(opReady), 0x00, 0x00, 0x00, 0x00})
+
+	framer := newFramer(nil, 2)
+	framer.readLimit = 512
+
+	head := frameHeader{
+		version: 2,
+		op:      opResult,
+		length:  1024,
+	}
+
+	err := framer.readFrame(r, &head)
+	tooLarge, ok := err.(*ErrFrameTooLarge)
+	if !ok {
+		t.Fatalf("expected to get *ErrFrameTooLarge got %v", err)
+	}
+	if tooLarge.Length != 1024 || tooLarge.Limit != 512 {
+		t.Fatalf("unexpected error details: %+v", tooLarge)
+	}
+	if !errors.Is(err, ErrFrameTooBig) {
+		t.Fatalf("expected %v to match %v", err, ErrFrameTooBig)
+	}
+
+	head, err = readHeader(r, make([]byte, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.op != opReady {
+		t.Fatalf("expected to get header %v got %v", opReady, head.op)
+	}
+}
@@ -0,0 +1,165 @@
+package gocqltest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Clock is a fake gocql.Clock whose time only changes when it is advanced,
+// firing the timers and tickers which expire, so that tests can exercise
+// reconnections or speculative executions without sleeping:
+//
+//	clock := gocqltest.NewClock(time.Now())
+//	cluster.Clock = clock
+//	...
+//	clock.WaitForTimers(1)
+//	clock.Advance(time.Second)
+type Clock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers map[*timer]struct{}
+}
+
+var _ gocql.Clock = (*Clock)(nil)
+
+// NewClock returns a fake clock set to now.
+func NewClock(now time.Time) *Clock {
+	c := &Clock{now: now, timers: make(map[*timer]struct{})}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the time of the clock forward by d, firing the timers expiring
+// meanwhile in order.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := c.now.Add(d)
+	for {
+		var next *timer
+		for t := range c.timers {
+			if !t.deadline.After(end) && (next == nil || t.deadline.Before(next.deadline)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		c.now = next.deadline
+		next.fire()
+	}
+	c.now = end
+}
+
+// WaitForTimers blocks until at least n timers, tickers or sleeps are pending,
+// to synchronize with the goroutines of the driver before advancing the clock.
+func (c *Clock) WaitForTimers(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+func (c *Clock) newTimer(d, period time.Duration, f func()) *timer {
+	t := &timer{clock: c, period: period, f: f}
+	if f == nil {
+		t.c = make(chan time.Time, 1)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.startLocked(d)
+	return t
+}
+
+func (c *Clock) NewTimer(d time.Duration) gocql.Timer {
+	return c.newTimer(d, 0, nil)
+}
+
+// NewTicker returns a fake ticker, it panics if d is not positive.
+func (c *Clock) NewTicker(d time.Duration) gocql.Ticker {
+	if d <= 0 {
+		panic("gocqltest: non-positive interval for NewTicker")
+	}
+	return ticker{c.newTimer(d, d, nil)}
+}
+
+func (c *Clock) AfterFunc(d time.Duration, f func()) gocql.Timer {
+	return c.newTimer(d, 0, f)
+}
+
+func (c *Clock) Sleep(d time.Duration) {
+	<-c.newTimer(d, 0, nil).c
+}
+
+// timer is a timer of a fake Clock, repeated if period is set.
+type timer struct {
+	clock    *Clock
+	deadline time.Time
+	period   time.Duration
+	c        chan time.Time
+	f        func()
+}
+
+func (t *timer) startLocked(d time.Duration) {
+	t.deadline = t.clock.now.Add(d)
+	t.clock.timers[t] = struct{}{}
+	t.clock.cond.Broadcast()
+}
+
+func (t *timer) fire() {
+	if t.period > 0 {
+		t.deadline = t.deadline.Add(t.period)
+	} else {
+		delete(t.clock.timers, t)
+	}
+
+	if t.f != nil {
+		go t.f()
+		return
+	}
+	// like the time package, ticks are dropped for slow receivers
+	select {
+	case t.c <- t.clock.now:
+	default:
+	}
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	_, active := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return active
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	_, active := t.clock.timers[t]
+	t.startLocked(d)
+	return active
+}
+
+type ticker struct {
+	*timer
+}
+
+func (t ticker) Stop() {
+	t.timer.Stop()
+}
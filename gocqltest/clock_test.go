@@ -0,0 +1,66 @@
+package gocqltest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewClock(start)
+
+	timer := clock.NewTimer(2 * time.Second)
+	stopped := clock.NewTimer(time.Second)
+	ticker := clock.NewTicker(time.Second)
+	called := make(chan time.Time, 1)
+	clock.AfterFunc(3*time.Second, func() { called <- clock.Now() })
+
+	if !stopped.Stop() {
+		t.Fatal("expected the timer to be active")
+	}
+
+	clock.Advance(1500 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired before its deadline")
+	case <-stopped.C():
+		t.Fatal("stopped timer fired")
+	case now := <-ticker.C():
+		if now != start.Add(time.Second) {
+			t.Fatalf("expected a tick at %v got %v", start.Add(time.Second), now)
+		}
+	default:
+		t.Fatal("expected a tick")
+	}
+
+	clock.Advance(2 * time.Second)
+	if now := <-timer.C(); now != start.Add(2*time.Second) {
+		t.Fatalf("expected the timer to fire at %v got %v", start.Add(2*time.Second), now)
+	}
+	if now := <-called; now != start.Add(3500*time.Millisecond) {
+		t.Fatalf("expected the function to be called once the clock was advanced, got %v", now)
+	}
+	// the ticks at 2s and 3s were dropped but one
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Fatal("expected ticks to be dropped for a slow receiver")
+	default:
+	}
+
+	ticker.Stop()
+	if timer.Reset(time.Second) {
+		t.Fatal("expected the timer to be expired")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		clock.Sleep(time.Minute)
+		close(done)
+	}()
+	// the reset timer and the sleep
+	clock.WaitForTimers(2)
+	clock.Advance(time.Minute)
+	<-done
+	<-timer.C()
+}
@@ -0,0 +1,417 @@
+package gocqltest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+const (
+	opPrepare = 0x09
+
+	resultKindPrepared = 4
+)
+
+type faultKind int
+
+const (
+	faultDelay faultKind = iota
+	faultDrop
+	faultDuplicate
+	faultCorrupt
+)
+
+// Fault is injected into the responses to the requests executing the
+// statements matching a pattern.
+type Fault struct {
+	injector *FaultInjector
+	kind     faultKind
+	pattern  *regexp.Regexp
+	delay    time.Duration
+
+	// guarded by the mutex of the injector
+	host      string
+	remaining int
+	injected  int
+}
+
+// FaultInjector is a gocql.HostDialer injecting faults into the responses of
+// the nodes, to exercise retry policies, speculative execution and failover
+// deterministically in tests:
+//
+//	injector := gocqltest.NewFaultInjector(nil)
+//	injector.Drop(`^SELECT`).OnHost("127.0.0.1").Times(1)
+//	cluster.HostDialer = injector
+//
+// Faults with a statement pattern apply to the responses to QUERY, EXECUTE and
+// BATCH requests whose statement, or one of the statements of a batch,
+// matches the pattern. The responses to other requests, such as STARTUP or
+// PREPARE, and to compressed requests have no statement and are only affected
+// by faults with an empty pattern.
+//
+// Only protocol versions 3 and 4 are supported. Connections are not
+// established to specific shards of Scylla nodes and read deadlines set by
+// the driver are ignored.
+type FaultInjector struct {
+	dialer gocql.HostDialer
+
+	mu       sync.Mutex
+	faults   []*Fault
+	prepared map[string]string
+}
+
+// NewFaultInjector returns an injector connecting to the nodes with the dialer,
+// or over plain TCP if the dialer is nil.
+func NewFaultInjector(dialer gocql.HostDialer) *FaultInjector {
+	return &FaultInjector{
+		dialer:   dialer,
+		prepared: make(map[string]string),
+	}
+}
+
+func (fi *FaultInjector) add(kind faultKind, pattern string, delay time.Duration) *Fault {
+	f := &Fault{
+		injector:  fi,
+		kind:      kind,
+		pattern:   regexp.MustCompile(pattern),
+		delay:     delay,
+		remaining: -1,
+	}
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.faults = append(fi.faults, f)
+	return f
+}
+
+// Delay delays the responses by d. It panics if the pattern is invalid, as do
+// the other methods adding a fault. Faults added last take precedence.
+func (fi *FaultInjector) Delay(pattern string, d time.Duration) *Fault {
+	return fi.add(faultDelay, pattern, d)
+}
+
+// Drop drops the responses, so that the requests time out.
+func (fi *FaultInjector) Drop(pattern string) *Fault {
+	return fi.add(faultDrop, pattern, 0)
+}
+
+// Duplicate sends the responses twice.
+func (fi *FaultInjector) Duplicate(pattern string) *Fault {
+	return fi.add(faultDuplicate, pattern, 0)
+}
+
+// Corrupt inverts the bits of the bodies of the responses.
+func (fi *FaultInjector) Corrupt(pattern string) *Fault {
+	return fi.add(faultCorrupt, pattern, 0)
+}
+
+// Clear removes all the faults.
+func (fi *FaultInjector) Clear() {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.faults = nil
+}
+
+// OnHost restricts the fault to the responses of the host, given by its
+// address with or without port.
+func (f *Fault) OnHost(addr string) *Fault {
+	f.injector.mu.Lock()
+	defer f.injector.mu.Unlock()
+	f.host = addr
+	return f
+}
+
+// Times restricts the fault to the first n responses it applies to.
+func (f *Fault) Times(n int) *Fault {
+	f.injector.mu.Lock()
+	defer f.injector.mu.Unlock()
+	f.remaining = n
+	return f
+}
+
+// Injected returns the number of responses the fault was injected into.
+func (f *Fault) Injected() int {
+	f.injector.mu.Lock()
+	defer f.injector.mu.Unlock()
+	return f.injected
+}
+
+// match returns the fault to inject into the response of a request to the
+// host at the addresses.
+func (fi *FaultInjector) match(addrs [2]string, stmts []string) *Fault {
+	if len(stmts) == 0 {
+		stmts = []string{""}
+	}
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	for i := len(fi.faults) - 1; i >= 0; i-- {
+		f := fi.faults[i]
+		if f.remaining == 0 || (f.host != "" && f.host != addrs[0] && f.host != addrs[1]) {
+			continue
+		}
+		for _, stmt := range stmts {
+			if f.pattern.MatchString(stmt) {
+				if f.remaining > 0 {
+					f.remaining--
+				}
+				f.injected++
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+func (fi *FaultInjector) DialHost(ctx context.Context, host *gocql.HostInfo) (*gocql.DialedHost, error) {
+	var dialed *gocql.DialedHost
+	if fi.dialer != nil {
+		var err error
+		if dialed, err = fi.dialer.DialHost(ctx, host); err != nil {
+			return nil, err
+		}
+	} else {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", host.ConnectAddressAndPort())
+		if err != nil {
+			return nil, err
+		}
+		dialed = &gocql.DialedHost{Conn: conn}
+	}
+
+	pr, pw := io.Pipe()
+	c := &faultConn{
+		Conn:     dialed.Conn,
+		injector: fi,
+		addrs:    [2]string{host.ConnectAddress().String(), host.ConnectAddressAndPort()},
+		requests: make(map[int16]request),
+		pr:       pr,
+		pw:       pw,
+	}
+	go c.serve()
+
+	// writes of net.Buffers are not vectored for the wrapped connection
+	return &gocql.DialedHost{Conn: c, DisableCoalesce: true}, nil
+}
+
+// request is a request waiting for its response.
+type request struct {
+	op    byte
+	stmts []string
+}
+
+// faultConn parses the requests written to the connection and forwards the
+// responses, injecting the faults, to a pipe read by the driver.
+type faultConn struct {
+	net.Conn
+	injector *FaultInjector
+	addrs    [2]string
+
+	mu       sync.Mutex
+	buf      []byte
+	requests map[int16]request
+
+	pr  *io.PipeReader
+	pw  *io.PipeWriter
+	pmu sync.Mutex
+}
+
+func (c *faultConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	c.buf = append(c.buf, p...)
+	for len(c.buf) >= headerSize {
+		n := headerSize + int(binary.BigEndian.Uint32(c.buf[5:]))
+		if len(c.buf) < n {
+			break
+		}
+		f, err := readFrame(bytes.NewReader(c.buf[:n]))
+		if err == nil {
+			c.requests[f.stream()] = c.injector.request(f)
+		}
+		c.buf = c.buf[n:]
+	}
+	c.mu.Unlock()
+
+	return c.Conn.Write(p)
+}
+
+func (c *faultConn) Read(p []byte) (int, error) {
+	return c.pr.Read(p)
+}
+
+func (c *faultConn) SetDeadline(t time.Time) error {
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func (c *faultConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *faultConn) Close() error {
+	c.pr.Close()
+	return c.Conn.Close()
+}
+
+func (c *faultConn) emit(f *frame) {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+	c.pw.Write(f.bytes())
+}
+
+func (c *faultConn) serve() {
+	for {
+		f, err := readFrame(c.Conn)
+		if err != nil {
+			c.pw.CloseWithError(err)
+			return
+		}
+
+		c.mu.Lock()
+		req, ok := c.requests[f.stream()]
+		delete(c.requests, f.stream())
+		c.mu.Unlock()
+		// events are pushed by the node and not faulted
+		if !ok || f.stream() < 0 {
+			c.emit(f)
+			continue
+		}
+
+		if req.op == opPrepare {
+			c.injector.prepare(req.stmts, f)
+			req.stmts = nil
+		}
+		fault := c.injector.match(c.addrs, req.stmts)
+		if fault == nil {
+			c.emit(f)
+			continue
+		}
+
+		switch fault.kind {
+		case faultDelay:
+			time.AfterFunc(fault.delay, func() { c.emit(f) })
+		case faultDrop:
+		case faultDuplicate:
+			c.emit(f)
+			c.emit(f)
+		case faultCorrupt:
+			for i := range f.body {
+				f.body[i] ^= 0xFF
+			}
+			c.emit(f)
+		}
+	}
+}
+
+// request returns the statements of the request.
+func (fi *FaultInjector) request(f *frame) request {
+	req := request{op: f.op()}
+	if f.header[1]&flagCompress != 0 {
+		return req
+	}
+
+	r := bytes.NewReader(f.body)
+	switch f.op() {
+	case opQuery, opPrepare:
+		if stmt, ok := readLongString(r); ok {
+			req.stmts = []string{stmt}
+		}
+	case opExecute:
+		if id, ok := readShortBytes(r); ok {
+			fi.mu.Lock()
+			req.stmts = []string{fi.prepared[string(id)]}
+			fi.mu.Unlock()
+		}
+	case opBatch:
+		req.stmts = fi.batchStatements(r)
+	}
+	return req
+}
+
+func (fi *FaultInjector) batchStatements(r *bytes.Reader) []string {
+	var count uint16
+	if _, err := r.ReadByte(); err != nil || binary.Read(r, binary.BigEndian, &count) != nil {
+		return nil
+	}
+
+	stmts := make([]string, 0, count)
+	for i := 0; i < int(count); i++ {
+		kind, err := r.ReadByte()
+		if err != nil {
+			return stmts
+		}
+		if kind == 0 {
+			stmt, ok := readLongString(r)
+			if !ok {
+				return stmts
+			}
+			stmts = append(stmts, stmt)
+		} else {
+			id, ok := readShortBytes(r)
+			if !ok {
+				return stmts
+			}
+			fi.mu.Lock()
+			stmts = append(stmts, fi.prepared[string(id)])
+			fi.mu.Unlock()
+		}
+
+		var values uint16
+		if binary.Read(r, binary.BigEndian, &values) != nil {
+			return stmts
+		}
+		for j := 0; j < int(values); j++ {
+			var n int32
+			if binary.Read(r, binary.BigEndian, &n) != nil {
+				return stmts
+			}
+			if n > 0 {
+				if _, err := r.Seek(int64(n), io.SeekCurrent); err != nil {
+					return stmts
+				}
+			}
+		}
+	}
+	return stmts
+}
+
+// prepare records the statement of a prepared id, so that faults can be
+// injected into the responses of its executions.
+func (fi *FaultInjector) prepare(stmts []string, resp *frame) {
+	r := bytes.NewReader(resp.body)
+	var kind int32
+	if len(stmts) != 1 || resp.op() != opResult || binary.Read(r, binary.BigEndian, &kind) != nil || kind != resultKindPrepared {
+		return
+	}
+	if id, ok := readShortBytes(r); ok {
+		fi.mu.Lock()
+		fi.prepared[string(id)] = stmts[0]
+		fi.mu.Unlock()
+	}
+}
+
+func readLongString(r *bytes.Reader) (string, bool) {
+	var n int32
+	if binary.Read(r, binary.BigEndian, &n) != nil || n < 0 || int(n) > r.Len() {
+		return "", false
+	}
+	b := make([]byte, n)
+	r.Read(b)
+	return string(b), true
+}
+
+func readShortBytes(r *bytes.Reader) ([]byte, bool) {
+	var n uint16
+	if binary.Read(r, binary.BigEndian, &n) != nil || int(n) > r.Len() {
+		return nil, false
+	}
+	b := make([]byte, n)
+	r.Read(b)
+	return b, true
+}
@@ -0,0 +1,97 @@
+package gocqltest
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+type hostDialerFunc func(ctx context.Context, host *gocql.HostInfo) (*gocql.DialedHost, error)
+
+func (f hostDialerFunc) DialHost(ctx context.Context, host *gocql.HostInfo) (*gocql.DialedHost, error) {
+	return f(ctx, host)
+}
+
+func TestFaultInjector(t *testing.T) {
+	node := startNode(t)
+	defer node.Close()
+
+	injector := NewFaultInjector(hostDialerFunc(func(ctx context.Context, host *gocql.HostInfo) (*gocql.DialedHost, error) {
+		conn, err := net.Dial("tcp", node.Addr().String())
+		if err != nil {
+			return nil, err
+		}
+		return &gocql.DialedHost{Conn: conn}, nil
+	}))
+	other := injector.Drop("").OnHost("10.0.0.1")
+	duplicate := injector.Duplicate("^SELECT a").Times(1)
+	drop := injector.Drop("^SELECT b").Times(1)
+	delay := injector.Delay("^SELECT c", 50*time.Millisecond)
+	corrupt := injector.Corrupt("^UPDATE")
+
+	host := (&gocql.HostInfo{}).SetConnectAddress(net.ParseIP("127.0.0.1"))
+	dialed, err := injector.DialHost(context.Background(), host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := dialed.Conn
+	defer conn.Close()
+
+	expectStreams := func(streams ...int16) []*frame {
+		t.Helper()
+		frames := make([]*frame, len(streams))
+		for i, stream := range streams {
+			f, err := readFrame(conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.stream() != stream {
+				t.Fatalf("expected response %d for stream %d got stream %d", i, stream, f.stream())
+			}
+			frames[i] = f
+		}
+		return frames
+	}
+
+	conn.Write(queryFrame(1, "SELECT a FROM t", 0))
+	expectStreams(1, 1)
+	conn.Write(queryFrame(2, "SELECT a FROM t", 0))
+	expectStreams(2)
+
+	conn.Write(queryFrame(3, "SELECT b FROM t", 0))
+	conn.Write(queryFrame(4, "SELECT b FROM t", 0))
+	expectStreams(4)
+
+	conn.Write(queryFrame(5, "SELECT c FROM t", 0))
+	conn.Write(queryFrame(6, "SELECT d FROM t", 0))
+	expectStreams(6, 5)
+
+	stmt := "UPDATE t SET v = 1"
+	conn.Write(newFrame(4, opPrepare, 7, append([]byte{0, 0, 0, byte(len(stmt))}, stmt...)).bytes())
+	expectStreams(7)
+	conn.Write(newFrame(4, opExecute, 8, []byte{0, 2, 'i', 'd', 0, 1, 0}).bytes())
+	resp := expectStreams(8)[0]
+	if resp.op() != opResult || resp.body[0] != 0xFF {
+		t.Fatalf("expected a corrupted result got opcode 0x%02X body %v", resp.op(), resp.body)
+	}
+
+	tests := []struct {
+		name     string
+		fault    *Fault
+		injected int
+	}{
+		{"other host", other, 0},
+		{"duplicate", duplicate, 1},
+		{"drop", drop, 1},
+		{"delay", delay, 1},
+		{"corrupt", corrupt, 1},
+	}
+	for _, test := range tests {
+		if n := test.fault.Injected(); n != test.injected {
+			t.Errorf("%s: expected %d injected faults got %d", test.name, test.injected, n)
+		}
+	}
+}
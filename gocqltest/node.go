@@ -0,0 +1,272 @@
+package gocqltest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+const (
+	// DefaultImage is the Docker image started by StartDocker by default.
+	DefaultImage = "scylladb/scylla"
+
+	defaultStartTimeout = 3 * time.Minute
+)
+
+// NodeConfig configures the node started by StartDocker.
+type NodeConfig struct {
+	// Image is the Docker image of the node, such as "cassandra:4.1".
+	// Defaults to DefaultImage.
+	Image string
+	// Args are passed to the entrypoint of the image. Defaults to the
+	// options starting Scylla with a single shard and little memory when the
+	// default image is used.
+	Args []string
+	// StartTimeout bounds the time waiting for the node to accept CQL
+	// connections. Defaults to 3 minutes.
+	StartTimeout time.Duration
+}
+
+// Node is a single node cluster for integration tests, either a disposable
+// Docker container or an existing cluster such as one managed by CCM:
+//
+//	node, err := gocqltest.StartDocker(gocqltest.NodeConfig{})
+//	...
+//	defer node.Stop()
+//	session, err := node.ScratchSession()
+//	...
+//	defer session.Close()
+type Node struct {
+	hosts      []string
+	translator gocql.AddressTranslator
+	container  string
+
+	mu        sync.Mutex
+	keyspaces []string
+}
+
+// StartDocker starts a disposable node in a Docker container and waits until
+// it accepts CQL connections. The container is removed by Stop.
+func StartDocker(cfg NodeConfig) (*Node, error) {
+	if cfg.Image == "" {
+		cfg.Image = DefaultImage
+		if cfg.Args == nil {
+			cfg.Args = []string{"--smp", "1", "--memory", "512M", "--overprovisioned", "1", "--developer-mode", "1"}
+		}
+	}
+	if cfg.StartTimeout <= 0 {
+		cfg.StartTimeout = defaultStartTimeout
+	}
+
+	args := append([]string{"run", "--detach", "--rm", "--publish", "127.0.0.1::9042", cfg.Image}, cfg.Args...)
+	out, err := docker(args...)
+	if err != nil {
+		return nil, err
+	}
+	n := &Node{container: strings.TrimSpace(out)}
+
+	out, err = docker("port", n.container, "9042/tcp")
+	if err != nil {
+		n.Stop()
+		return nil, err
+	}
+	addr, err := parseDockerPort(out)
+	if err != nil {
+		n.Stop()
+		return nil, err
+	}
+	n.hosts = []string{addr.String()}
+	// the node advertises the address of the container, which may not be
+	// reachable from the host
+	n.translator = gocql.AddressTranslatorFunc(func(net.IP, int) (net.IP, int) {
+		return addr.IP, addr.Port
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.StartTimeout)
+	defer cancel()
+	if err := n.WaitReady(ctx); err != nil {
+		n.Stop()
+		return nil, err
+	}
+	return n, nil
+}
+
+// AttachCCM returns the node of the live nodes of the current CCM cluster.
+func AttachCCM() (*Node, error) {
+	cmd := exec.Command("ccm", "liveset")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gocqltest: ccm liveset: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var hosts []string
+	for _, host := range strings.Split(strings.TrimSpace(string(out)), ",") {
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("gocqltest: no live node in the CCM cluster")
+	}
+	return Attach(hosts...), nil
+}
+
+// Attach returns the node of an existing cluster reachable at the hosts.
+func Attach(hosts ...string) *Node {
+	return &Node{hosts: hosts}
+}
+
+// Hosts returns the addresses of the node.
+func (n *Node) Hosts() []string {
+	return append([]string(nil), n.hosts...)
+}
+
+// Cluster returns a cluster config connecting to the node.
+func (n *Node) Cluster() *gocql.ClusterConfig {
+	cluster := gocql.NewCluster(n.hosts...)
+	cluster.Consistency = gocql.One
+	if n.translator != nil {
+		cluster.AddressTranslator = n.translator
+		// only the CQL port of the container is published
+		cluster.DisableShardAwarePort = true
+	}
+	return cluster
+}
+
+// WaitReady waits until the node accepts CQL connections.
+func (n *Node) WaitReady(ctx context.Context) error {
+	for {
+		err := n.ping()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gocqltest: node not ready: %v", err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (n *Node) ping() error {
+	cluster := n.Cluster()
+	cluster.ConnectTimeout = time.Second
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	return session.Query("SELECT now() FROM system.local").Exec()
+}
+
+// ScratchKeyspace creates a keyspace with a random name replicated to the
+// node. The keyspace is dropped by Stop if the node was attached.
+func (n *Node) ScratchKeyspace() (string, error) {
+	keyspace, err := scratchKeyspaceName()
+	if err != nil {
+		return "", err
+	}
+
+	session, err := n.Cluster().CreateSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	stmt := fmt.Sprintf("CREATE KEYSPACE %s WITH replication = {'class': 'SimpleStrategy', 'replication_factor': 1}", keyspace)
+	if err := session.Query(stmt).Exec(); err != nil {
+		return "", err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.keyspaces = append(n.keyspaces, keyspace)
+	return keyspace, nil
+}
+
+// ScratchSession returns a session using a new scratch keyspace.
+func (n *Node) ScratchSession() (*gocql.Session, error) {
+	keyspace, err := n.ScratchKeyspace()
+	if err != nil {
+		return nil, err
+	}
+	cluster := n.Cluster()
+	cluster.Keyspace = keyspace
+	return cluster.CreateSession()
+}
+
+// Stop removes the container of the node, or drops the scratch keyspaces
+// created in an attached cluster.
+func (n *Node) Stop() error {
+	if n.container != "" {
+		_, err := docker("rm", "--force", n.container)
+		return err
+	}
+
+	n.mu.Lock()
+	keyspaces := n.keyspaces
+	n.keyspaces = nil
+	n.mu.Unlock()
+	if len(keyspaces) == 0 {
+		return nil
+	}
+
+	session, err := n.Cluster().CreateSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	for _, keyspace := range keyspaces {
+		if err := session.Query("DROP KEYSPACE IF EXISTS " + keyspace).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func docker(args ...string) (string, error) {
+	cmd := exec.Command("docker", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("gocqltest: docker %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// parseDockerPort returns the first IPv4 address of the output of docker port.
+func parseDockerPort(out string) (*net.TCPAddr, error) {
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(line))
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(host)
+		n, err := strconv.Atoi(port)
+		if ip == nil || ip.To4() == nil || err != nil {
+			continue
+		}
+		return &net.TCPAddr{IP: ip, Port: n}, nil
+	}
+	return nil, fmt.Errorf("gocqltest: no published port in %q", out)
+}
+
+func scratchKeyspaceName() (string, error) {
+	var b [6]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return "gocqltest_" + hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,45 @@
+package gocqltest
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestParseDockerPort(t *testing.T) {
+	tests := []struct {
+		out      string
+		expected string
+	}{
+		{"127.0.0.1:49153\n", "127.0.0.1:49153"},
+		{"[::]:49154\n0.0.0.0:49154\n", "0.0.0.0:49154"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		addr, err := parseDockerPort(test.out)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("%q: expected an error got %v", test.out, addr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.out, err)
+		} else if addr.String() != test.expected {
+			t.Errorf("%q: expected %s got %s", test.out, test.expected, addr)
+		}
+	}
+}
+
+func TestScratchKeyspaceName(t *testing.T) {
+	a, err := scratchKeyspaceName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := scratchKeyspaceName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || !regexp.MustCompile(`^[a-z][a-z0-9_]{0,47}$`).MatchString(a) {
+		t.Fatalf("expected distinct valid keyspace names got %q and %q", a, b)
+	}
+}
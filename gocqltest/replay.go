@@ -0,0 +1,418 @@
+package gocqltest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// Frames are recorded and replayed for the native protocol versions 3 and 4,
+// without compression.
+const (
+	headerSize = 9
+
+	flagCompress = 0x01
+
+	opError   = 0x00
+	opQuery   = 0x07
+	opResult  = 0x08
+	opExecute = 0x0A
+	opBatch   = 0x0D
+
+	queryFlagTimestamp = 0x20
+)
+
+var errUnsupportedFrame = errors.New("gocqltest: unsupported protocol version")
+
+type frame struct {
+	header [headerSize]byte
+	body   []byte
+}
+
+func (f *frame) version() byte { return f.header[0] & 0x7F }
+func (f *frame) stream() int16 { return int16(binary.BigEndian.Uint16(f.header[2:])) }
+func (f *frame) op() byte      { return f.header[4] }
+
+func (f *frame) setStream(stream int16) {
+	binary.BigEndian.PutUint16(f.header[2:], uint16(stream))
+}
+
+func readFrame(r io.Reader) (*frame, error) {
+	f := &frame{}
+	if _, err := io.ReadFull(r, f.header[:]); err != nil {
+		return nil, err
+	}
+	if v := f.version(); v < 3 || v > 4 {
+		return nil, errUnsupportedFrame
+	}
+	f.body = make([]byte, binary.BigEndian.Uint32(f.header[5:]))
+	if _, err := io.ReadFull(r, f.body); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *frame) bytes() []byte {
+	return append(f.header[:len(f.header):len(f.header)], f.body...)
+}
+
+// requestKey identifies a request independently of its stream and of the
+// client side timestamp of the statement.
+func requestKey(f *frame) string {
+	body := f.body
+	if f.header[1]&flagCompress == 0 {
+		if n := timestampOffset(f.op(), body); n >= 0 {
+			body = body[:n]
+		}
+	}
+	return string([]byte{f.version(), f.op()}) + string(body)
+}
+
+// timestampOffset returns the offset of the timestamp which ends the query
+// parameters of a QUERY, EXECUTE or BATCH request body, or -1.
+func timestampOffset(op byte, body []byte) int {
+	r := bytes.NewReader(body)
+	skip := func(n int) bool {
+		if n < 0 || n > r.Len() {
+			return false
+		}
+		_, err := r.Seek(int64(n), io.SeekCurrent)
+		return err == nil
+	}
+	readInt := func() (int, bool) {
+		var n int32
+		return int(n), binary.Read(r, binary.BigEndian, &n) == nil
+	}
+	readShort := func() (int, bool) {
+		var n uint16
+		return int(n), binary.Read(r, binary.BigEndian, &n) == nil
+	}
+
+	switch op {
+	case opQuery:
+		n, ok := readInt()
+		if !ok || !skip(n) {
+			return -1
+		}
+	case opExecute:
+		n, ok := readShort()
+		if !ok || !skip(n) {
+			return -1
+		}
+	case opBatch:
+		if !skip(1) {
+			return -1
+		}
+		count, ok := readShort()
+		if !ok {
+			return -1
+		}
+		for i := 0; i < count; i++ {
+			kind, err := r.ReadByte()
+			if err != nil {
+				return -1
+			}
+			if kind == 0 {
+				n, ok := readInt()
+				if !ok || !skip(n) {
+					return -1
+				}
+			} else {
+				n, ok := readShort()
+				if !ok || !skip(n) {
+					return -1
+				}
+			}
+			values, ok := readShort()
+			if !ok {
+				return -1
+			}
+			for j := 0; j < values; j++ {
+				n, ok := readInt()
+				if !ok || (n > 0 && !skip(n)) {
+					return -1
+				}
+			}
+		}
+	default:
+		return -1
+	}
+
+	// consistency and flags
+	if !skip(2) {
+		return -1
+	}
+	flags, err := r.ReadByte()
+	if err != nil || flags&queryFlagTimestamp == 0 || len(body) < 8 {
+		return -1
+	}
+	// the timestamp is the last parameter in protocol versions 3 and 4
+	return len(body) - 8
+}
+
+// Exchange is a request and the response of the node to it.
+type Exchange struct {
+	Request  []byte
+	Response []byte
+}
+
+// Recording are the frames exchanged between the driver and a node.
+type Recording struct {
+	Exchanges []Exchange
+}
+
+// Save writes the recording to w.
+func (r *Recording) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// LoadRecording reads a recording written by Recording.Save.
+func LoadRecording(r io.Reader) (*Recording, error) {
+	rec := &Recording{}
+	if err := json.NewDecoder(r).Decode(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// proxy accepts the connections of the driver and serves them.
+type proxy struct {
+	ln    net.Listener
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newProxy(serve func(conn net.Conn)) (*proxy, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &proxy{ln: ln, conns: make(map[net.Conn]struct{})}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			p.track(conn, true)
+			p.wg.Add(1)
+			go func() {
+				defer p.wg.Done()
+				defer p.track(conn, false)
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+	return p, nil
+}
+
+func (p *proxy) track(conn net.Conn, add bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if add {
+		p.conns[conn] = struct{}{}
+	} else {
+		delete(p.conns, conn)
+	}
+}
+
+func (p *proxy) close() error {
+	err := p.ln.Close()
+	p.mu.Lock()
+	for conn := range p.conns {
+		conn.Close()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	return err
+}
+
+// Recorder is a proxy between the driver and a node which records the frames
+// they exchange. The driver must connect to the address of the recorder with
+// protocol version 3 or 4, without compression, and should not discover the
+// other nodes of the cluster.
+type Recorder struct {
+	upstream string
+	proxy    *proxy
+
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+// NewRecorder returns a recorder proxying the connections to the node at the upstream address.
+func NewRecorder(upstream string) (*Recorder, error) {
+	r := &Recorder{upstream: upstream}
+	p, err := newProxy(r.serve)
+	if err != nil {
+		return nil, err
+	}
+	r.proxy = p
+	return r, nil
+}
+
+// Addr returns the address the driver should connect to.
+func (r *Recorder) Addr() string {
+	return r.proxy.ln.Addr().String()
+}
+
+// Recording returns the frames recorded so far.
+func (r *Recorder) Recording() *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Recording{Exchanges: append([]Exchange(nil), r.exchanges...)}
+}
+
+// Close stops the recorder and closes the proxied connections.
+func (r *Recorder) Close() error {
+	return r.proxy.close()
+}
+
+func (r *Recorder) serve(client net.Conn) {
+	node, err := net.Dial("tcp", r.upstream)
+	if err != nil {
+		return
+	}
+	defer node.Close()
+
+	var (
+		mu       sync.Mutex
+		requests = make(map[int16][]byte)
+	)
+	go func() {
+		defer node.Close()
+		defer client.Close()
+		for {
+			f, err := readFrame(client)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			requests[f.stream()] = f.bytes()
+			mu.Unlock()
+			if _, err := node.Write(f.bytes()); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		f, err := readFrame(node)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		req, ok := requests[f.stream()]
+		delete(requests, f.stream())
+		mu.Unlock()
+		// events are pushed by the node and not replayed
+		if ok && f.stream() >= 0 {
+			r.mu.Lock()
+			r.exchanges = append(r.exchanges, Exchange{Request: req, Response: f.bytes()})
+			r.mu.Unlock()
+		}
+		if _, err := client.Write(f.bytes()); err != nil {
+			return
+		}
+	}
+}
+
+// Replayer is a fake node answering requests with the responses of a
+// recording. Identical requests are answered with the recorded responses in
+// order, the last response being repeated once they were all replayed.
+// Requests which were not recorded fail with a server error.
+type Replayer struct {
+	proxy *proxy
+
+	mu        sync.Mutex
+	responses map[string][][]byte
+}
+
+// NewReplayer returns a replayer of the recording.
+func NewReplayer(rec *Recording) (*Replayer, error) {
+	r := &Replayer{responses: make(map[string][][]byte)}
+	for _, ex := range rec.Exchanges {
+		req, err := readFrame(bytes.NewReader(ex.Request))
+		if err != nil {
+			return nil, fmt.Errorf("gocqltest: invalid recorded request: %v", err)
+		}
+		if _, err := readFrame(bytes.NewReader(ex.Response)); err != nil {
+			return nil, fmt.Errorf("gocqltest: invalid recorded response: %v", err)
+		}
+		key := requestKey(req)
+		r.responses[key] = append(r.responses[key], ex.Response)
+	}
+
+	p, err := newProxy(r.serve)
+	if err != nil {
+		return nil, err
+	}
+	r.proxy = p
+	return r, nil
+}
+
+// Addr returns the address the driver should connect to.
+func (r *Replayer) Addr() string {
+	return r.proxy.ln.Addr().String()
+}
+
+// Close stops the replayer and closes its connections.
+func (r *Replayer) Close() error {
+	return r.proxy.close()
+}
+
+func (r *Replayer) response(req *frame) *frame {
+	key := requestKey(req)
+
+	r.mu.Lock()
+	var recorded []byte
+	if responses := r.responses[key]; len(responses) > 0 {
+		recorded = responses[0]
+		if len(responses) > 1 {
+			r.responses[key] = responses[1:]
+		}
+	}
+	r.mu.Unlock()
+
+	if recorded != nil {
+		resp, _ := readFrame(bytes.NewReader(recorded))
+		resp.setStream(req.stream())
+		return resp
+	}
+
+	msg := fmt.Sprintf("gocqltest: no recorded response for request with opcode 0x%02X", req.op())
+	resp := &frame{}
+	resp.header[0] = req.version() | 0x80
+	resp.setStream(req.stream())
+	resp.header[4] = opError
+	resp.body = make([]byte, 6, 6+len(msg))
+	binary.BigEndian.PutUint16(resp.body[4:], uint16(len(msg)))
+	resp.body = append(resp.body, msg...)
+	binary.BigEndian.PutUint32(resp.header[5:], uint32(len(resp.body)))
+	return resp
+}
+
+func (r *Replayer) serve(conn net.Conn) {
+	var mu sync.Mutex
+	for {
+		req, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		resp := r.response(req)
+		mu.Lock()
+		_, err = conn.Write(resp.bytes())
+		mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
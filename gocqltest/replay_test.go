@@ -0,0 +1,148 @@
+package gocqltest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func queryFrame(stream int16, stmt string, timestamp int64) []byte {
+	body := make([]byte, 4, 4+len(stmt)+11)
+	binary.BigEndian.PutUint32(body, uint32(len(stmt)))
+	body = append(body, stmt...)
+	body = append(body, 0, 1, queryFlagTimestamp)
+	body = append(body, make([]byte, 8)...)
+	binary.BigEndian.PutUint64(body[len(body)-8:], uint64(timestamp))
+
+	return newFrame(4, opQuery, stream, body).bytes()
+}
+
+func newFrame(version, op byte, stream int16, body []byte) *frame {
+	f := &frame{body: body}
+	f.header[0] = version
+	f.setStream(stream)
+	f.header[4] = op
+	binary.BigEndian.PutUint32(f.header[5:], uint32(len(body)))
+	return f
+}
+
+// startNode starts a node answering each request with a result holding the
+// number of requests it received, or with the prepared id "id" for PREPARE
+// requests.
+func startNode(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		var n int32
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					req, err := readFrame(conn)
+					if err != nil {
+						return
+					}
+					n++
+					body := make([]byte, 4)
+					binary.BigEndian.PutUint32(body, uint32(n))
+					if req.op() == opPrepare {
+						body = []byte{0, 0, 0, resultKindPrepared, 0, 2, 'i', 'd'}
+					}
+					resp := newFrame(0x84, opResult, req.stream(), body)
+					if _, err := conn.Write(resp.bytes()); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln
+}
+
+func roundTrip(t *testing.T, conn net.Conn, req []byte) *frame {
+	t.Helper()
+	if _, err := conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := readFrame(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestRecordReplay(t *testing.T) {
+	node := startNode(t)
+	defer node.Close()
+
+	rec, err := NewRecorder(node.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", rec.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, conn, queryFrame(1, "SELECT a FROM t", 100))
+	roundTrip(t, conn, queryFrame(2, "SELECT a FROM t", 200))
+	roundTrip(t, conn, queryFrame(3, "SELECT b FROM t", 300))
+	conn.Close()
+	rec.Close()
+
+	var buf bytes.Buffer
+	if err := rec.Recording().Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	recording, err := LoadRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recording.Exchanges) != 3 {
+		t.Fatalf("expected 3 exchanges got %d", len(recording.Exchanges))
+	}
+
+	replayer, err := NewReplayer(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayer.Close()
+	conn, err = net.Dial("tcp", replayer.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		stream int16
+		stmt   string
+		result uint32
+	}{
+		{7, "SELECT b FROM t", 3},
+		{8, "SELECT a FROM t", 1},
+		{9, "SELECT a FROM t", 2},
+		// the last response is repeated
+		{10, "SELECT a FROM t", 2},
+	}
+	for _, test := range tests {
+		resp := roundTrip(t, conn, queryFrame(test.stream, test.stmt, int64(test.stream)))
+		if resp.stream() != test.stream {
+			t.Errorf("%s: expected stream %d got %d", test.stmt, test.stream, resp.stream())
+		}
+		if resp.op() != opResult || binary.BigEndian.Uint32(resp.body) != test.result {
+			t.Errorf("%s: expected result %d got opcode 0x%02X body %v", test.stmt, test.result, resp.op(), resp.body)
+		}
+	}
+
+	resp := roundTrip(t, conn, queryFrame(11, "SELECT c FROM t", 0))
+	if resp.op() != opError || resp.stream() != 11 {
+		t.Fatalf("expected an error for a query not recorded, got opcode 0x%02X", resp.op())
+	}
+}
@@ -0,0 +1,395 @@
+// Package gocqltest provides a fake gocql.SessionInterface, which returns
+// canned rows for the statements matching a pattern, to unit test code
+// executing CQL statements without a cluster.
+//
+// A Recorder proxies the connections of the driver to a node and records the
+// frames they exchange, a Replayer later answers the same requests with the
+// recorded responses, for integration tests not depending on a cluster. A
+// FaultInjector delays, drops, duplicates or corrupts the responses of the
+// nodes to exercise the error handling of the driver and of the application.
+// A Clock is advanced by the test instead of sleeping. A Node starts a
+// disposable node with Docker, or attaches to a CCM cluster, and creates
+// scratch keyspaces for integration tests.
+//
+// Example:
+//
+//	session := gocqltest.NewSession()
+//	session.On(`SELECT name FROM users WHERE id = \?`).Return([]string{"name"}, []interface{}{"alice"})
+//
+//	var name string
+//	err := session.Query("SELECT name FROM users WHERE id = ?", 1).Scan(&name)
+package gocqltest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+// Statement is a statement executed by a fake Session.
+type Statement struct {
+	Stmt   string
+	Values []interface{}
+}
+
+// Stub are the results of the statements matching a pattern.
+type Stub struct {
+	pattern *regexp.Regexp
+	columns []string
+	rows    [][]interface{}
+	err     error
+}
+
+// Return sets the rows returned by the statements, each row has a value per column.
+func (s *Stub) Return(columns []string, rows ...[]interface{}) *Stub {
+	s.columns = columns
+	s.rows = rows
+	return s
+}
+
+// ReturnError sets the error returned by the statements.
+func (s *Stub) ReturnError(err error) *Stub {
+	s.err = err
+	return s
+}
+
+// Session is a fake gocql.SessionInterface. Statements which do not match any
+// stub fail with an error, so every statement executed by the code under test
+// must be stubbed, with no rows for statements not returning any.
+type Session struct {
+	mu       sync.Mutex
+	stubs    []*Stub
+	executed []Statement
+	closed   bool
+}
+
+var _ gocql.SessionInterface = (*Session)(nil)
+
+// NewSession returns a fake session with no stubs.
+func NewSession() *Session {
+	return &Session{}
+}
+
+// On adds a stub for the statements matching the regular expression, it
+// panics if the expression is invalid. Stubs added last take precedence.
+func (s *Session) On(pattern string) *Stub {
+	stub := &Stub{pattern: regexp.MustCompile(pattern)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stubs = append(s.stubs, stub)
+	return stub
+}
+
+// Executed returns the statements executed by the session, in order.
+func (s *Session) Executed() []Statement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Statement(nil), s.executed...)
+}
+
+// execute records the statement and returns the stub matching it.
+func (s *Session) execute(stmt string, values []interface{}) (*Stub, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, gocql.ErrSessionClosed
+	}
+	s.executed = append(s.executed, Statement{Stmt: stmt, Values: values})
+	for i := len(s.stubs) - 1; i >= 0; i-- {
+		if s.stubs[i].pattern.MatchString(stmt) {
+			return s.stubs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("gocqltest: no stub for statement %q", stmt)
+}
+
+func (s *Session) Query(stmt string, values ...interface{}) gocql.QueryInterface {
+	return &Query{session: s, stmt: stmt, values: values, ctx: context.Background()}
+}
+
+func (s *Session) NewBatch(typ gocql.BatchType) gocql.BatchInterface {
+	return &Batch{session: s, ctx: context.Background()}
+}
+
+// ExecuteBatch executes the statements of the batch, it fails with the error
+// of the first statement whose stub returns an error.
+func (s *Session) ExecuteBatch(batch gocql.BatchInterface) error {
+	b, ok := batch.(*Batch)
+	if !ok {
+		return fmt.Errorf("gocqltest: batch %T was not created by the session", batch)
+	}
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	for _, entry := range b.entries {
+		stub, err := s.execute(entry.Stmt, entry.Values)
+		if err != nil {
+			return err
+		} else if stub.err != nil {
+			return stub.err
+		}
+	}
+	return nil
+}
+
+func (s *Session) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+}
+
+func (s *Session) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Query is a query of a fake Session.
+type Query struct {
+	session *Session
+	stmt    string
+	values  []interface{}
+	ctx     context.Context
+}
+
+func (q *Query) WithContext(ctx context.Context) gocql.QueryInterface {
+	q.ctx = ctx
+	return q
+}
+
+func (q *Query) Consistency(c gocql.Consistency) gocql.QueryInterface {
+	return q
+}
+
+func (q *Query) SerialConsistency(cons gocql.SerialConsistency) gocql.QueryInterface {
+	return q
+}
+
+func (q *Query) PageSize(n int) gocql.QueryInterface {
+	return q
+}
+
+func (q *Query) PageState(state []byte) gocql.QueryInterface {
+	return q
+}
+
+func (q *Query) Idempotent(value bool) gocql.QueryInterface {
+	return q
+}
+
+func (q *Query) Bind(v ...interface{}) gocql.QueryInterface {
+	q.values = v
+	return q
+}
+
+func (q *Query) Statement() string {
+	return q.stmt
+}
+
+func (q *Query) Exec() error {
+	return q.Iter().Close()
+}
+
+func (q *Query) Scan(dest ...interface{}) error {
+	iter := q.Iter()
+	if !iter.Scan(dest...) {
+		if err := iter.Close(); err != nil {
+			return err
+		}
+		return gocql.ErrNotFound
+	}
+	return iter.Close()
+}
+
+func (q *Query) MapScan(m map[string]interface{}) error {
+	iter := q.Iter()
+	if !iter.MapScan(m) {
+		if err := iter.Close(); err != nil {
+			return err
+		}
+		return gocql.ErrNotFound
+	}
+	return iter.Close()
+}
+
+// ScanCAS scans the first row like a conditional statement, the statement was
+// applied if the first column of the stub is not [applied] or is true.
+func (q *Query) ScanCAS(dest ...interface{}) (bool, error) {
+	iter := q.Iter().(*Iter)
+	if len(iter.columns) == 0 || iter.columns[0] != "[applied]" {
+		return true, iter.Close()
+	}
+
+	var applied bool
+	iter.Scan(append([]interface{}{&applied}, dest...)...)
+	return applied, iter.Close()
+}
+
+func (q *Query) Iter() gocql.IterInterface {
+	if err := q.ctx.Err(); err != nil {
+		return &Iter{err: err}
+	}
+	stub, err := q.session.execute(q.stmt, q.values)
+	if err != nil {
+		return &Iter{err: err}
+	} else if stub.err != nil {
+		return &Iter{err: stub.err}
+	}
+	return &Iter{columns: stub.columns, rows: stub.rows}
+}
+
+func (q *Query) Release() {}
+
+// Iter iterates over the rows of a stub.
+type Iter struct {
+	columns []string
+	rows    [][]interface{}
+	pos     int
+	err     error
+}
+
+func (iter *Iter) next() ([]interface{}, bool) {
+	if iter.err != nil || iter.pos >= len(iter.rows) {
+		return nil, false
+	}
+	row := iter.rows[iter.pos]
+	iter.pos++
+	if len(row) != len(iter.columns) {
+		iter.err = fmt.Errorf("gocqltest: row has %d values for %d columns", len(row), len(iter.columns))
+		return nil, false
+	}
+	return row, true
+}
+
+func (iter *Iter) Scan(dest ...interface{}) bool {
+	row, ok := iter.next()
+	if !ok {
+		return false
+	}
+	if len(dest) != len(row) {
+		iter.err = fmt.Errorf("gocqltest: not enough columns to scan into: have %d want %d", len(row), len(dest))
+		return false
+	}
+	for i, d := range dest {
+		if d == nil {
+			continue
+		}
+		if err := assign(d, row[i]); err != nil {
+			iter.err = fmt.Errorf("gocqltest: can not scan column %q: %v", iter.columns[i], err)
+			return false
+		}
+	}
+	return true
+}
+
+func (iter *Iter) MapScan(m map[string]interface{}) bool {
+	row, ok := iter.next()
+	if !ok {
+		return false
+	}
+	for i, column := range iter.columns {
+		m[column] = row[i]
+	}
+	return true
+}
+
+func (iter *Iter) SliceMap() ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	for {
+		m := make(map[string]interface{}, len(iter.columns))
+		if !iter.MapScan(m) {
+			break
+		}
+		rows = append(rows, m)
+	}
+	return rows, iter.err
+}
+
+func (iter *Iter) PageState() []byte {
+	return nil
+}
+
+func (iter *Iter) NumRows() int {
+	return len(iter.rows)
+}
+
+func (iter *Iter) Close() error {
+	return iter.err
+}
+
+// assign stores the value into the pointer dest, converting between numeric
+// types and between strings and byte slices.
+func assign(dest, value interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("destination %T is not a non-nil pointer", dest)
+	}
+	elem := rv.Elem()
+	if value == nil {
+		elem.Set(reflect.Zero(elem.Type()))
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(elem.Type()) {
+		elem.Set(v)
+		return nil
+	}
+	if elem.Kind() == reflect.Ptr && v.Type().AssignableTo(elem.Type().Elem()) {
+		p := reflect.New(elem.Type().Elem())
+		p.Elem().Set(v)
+		elem.Set(p)
+		return nil
+	}
+	if convertible(v.Kind(), elem.Kind()) && v.Type().ConvertibleTo(elem.Type()) {
+		elem.Set(v.Convert(elem.Type()))
+		return nil
+	}
+	return fmt.Errorf("can not assign %T to %T", value, dest)
+}
+
+func isNumber(k reflect.Kind) bool {
+	return (reflect.Int <= k && k <= reflect.Uint64) || k == reflect.Float32 || k == reflect.Float64
+}
+
+func convertible(from, to reflect.Kind) bool {
+	switch {
+	case isNumber(from) && isNumber(to):
+		return true
+	case from == reflect.String && to == reflect.Slice, from == reflect.Slice && to == reflect.String:
+		return true
+	}
+	return from == to
+}
+
+// Batch is a batch of a fake Session.
+type Batch struct {
+	session *Session
+	entries []Statement
+	ctx     context.Context
+}
+
+func (b *Batch) Query(stmt string, args ...interface{}) {
+	b.entries = append(b.entries, Statement{Stmt: stmt, Values: args})
+}
+
+func (b *Batch) WithContext(ctx context.Context) gocql.BatchInterface {
+	b.ctx = ctx
+	return b
+}
+
+func (b *Batch) SetConsistency(c gocql.Consistency) {}
+
+func (b *Batch) SerialConsistency(cons gocql.SerialConsistency) gocql.BatchInterface {
+	return b
+}
+
+func (b *Batch) Size() int {
+	return len(b.entries)
+}
@@ -0,0 +1,134 @@
+package gocqltest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gocql/gocql"
+)
+
+// userName is code under test depending on the session interface.
+func userName(session gocql.SessionInterface, id int) (string, error) {
+	var name string
+	err := session.Query(`SELECT name FROM users WHERE id = ?`, id).Scan(&name)
+	return name, err
+}
+
+func TestQueryScan(t *testing.T) {
+	session := NewSession()
+	session.On(`^SELECT name FROM users`).Return([]string{"name"}, []interface{}{"alice"})
+
+	name, err := userName(session, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "alice" {
+		t.Fatalf("expected alice got %q", name)
+	}
+
+	expected := []Statement{{Stmt: `SELECT name FROM users WHERE id = ?`, Values: []interface{}{1}}}
+	if executed := session.Executed(); !reflect.DeepEqual(executed, expected) {
+		t.Fatalf("expected %v got %v", expected, executed)
+	}
+
+	session.On(`^SELECT name FROM users`).Return([]string{"name"})
+	if _, err := userName(session, 1); err != gocql.ErrNotFound {
+		t.Fatalf("expected the last stub to return no rows, got %v", err)
+	}
+
+	if err := session.Query(`DELETE FROM users WHERE id = ?`, 1).Exec(); err == nil {
+		t.Fatal("expected an error for a statement with no stub")
+	}
+}
+
+func TestIter(t *testing.T) {
+	session := NewSession()
+	session.On(`FROM events`).Return([]string{"id", "count", "payload"},
+		[]interface{}{1, int64(10), []byte("a")},
+		[]interface{}{2, int64(20), nil},
+	)
+
+	iter := session.Query(`SELECT id, count, payload FROM events`).Iter()
+	if iter.NumRows() != 2 {
+		t.Fatalf("expected 2 rows got %d", iter.NumRows())
+	}
+	var (
+		id      int64
+		count   int
+		payload string
+		ids     []int64
+		total   int
+	)
+	for iter.Scan(&id, &count, &payload) {
+		ids = append(ids, id)
+		total += count
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2}) || total != 30 || payload != "" {
+		t.Fatalf("unexpected rows %v %d %q", ids, total, payload)
+	}
+
+	rows, err := session.Query(`SELECT * FROM events`).Iter().SliceMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1]["count"] != int64(20) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	var invalid struct{}
+	iter = session.Query(`SELECT id, count, payload FROM events`).Iter()
+	if iter.Scan(&invalid, &count, &payload) || iter.Close() == nil {
+		t.Fatal("expected an error scanning an int into a struct")
+	}
+}
+
+func TestScanCAS(t *testing.T) {
+	session := NewSession()
+	session.On(`IF NOT EXISTS`).Return([]string{"[applied]", "name"}, []interface{}{false, "bob"})
+
+	var name string
+	applied, err := session.Query(`INSERT INTO users (id, name) VALUES (?, ?) IF NOT EXISTS`, 1, "alice").ScanCAS(&name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied || name != "bob" {
+		t.Fatalf("expected not applied with existing name bob, got %v %q", applied, name)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	session := NewSession()
+	timeout := errors.New("timeout")
+	session.On(`INSERT`).ReturnError(timeout)
+	session.On(`UPDATE`).Return(nil)
+
+	if err := session.Query(`INSERT INTO t (k) VALUES (1)`).Exec(); err != timeout {
+		t.Fatalf("expected %v got %v", timeout, err)
+	}
+
+	batch := session.NewBatch(gocql.LoggedBatch)
+	batch.Query(`UPDATE t SET v = 1 WHERE k = 1`)
+	batch.Query(`INSERT INTO t (k) VALUES (1)`)
+	if batch.Size() != 2 {
+		t.Fatalf("expected 2 statements got %d", batch.Size())
+	}
+	if err := session.ExecuteBatch(batch); err != timeout {
+		t.Fatalf("expected %v got %v", timeout, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := session.Query(`UPDATE t SET v = 1 WHERE k = 1`).WithContext(ctx).Exec(); err != context.Canceled {
+		t.Fatalf("expected %v got %v", context.Canceled, err)
+	}
+
+	session.Close()
+	if err := session.Query(`UPDATE t SET v = 1 WHERE k = 1`).Exec(); err != gocql.ErrSessionClosed {
+		t.Fatalf("expected %v got %v", gocql.ErrSessionClosed, err)
+	}
+}
@@ -306,11 +306,15 @@ func (iter *Iter) RowData() (RowData, error) {
 	if iter.err != nil {
 		return RowData{}, iter.err
 	}
+	return newRowData(iter.Columns())
+}
 
-	columns := make([]string, 0, len(iter.Columns()))
-	values := make([]interface{}, 0, len(iter.Columns()))
+// newRowData returns the RowData to scan the columns into.
+func newRowData(cols []ColumnInfo) (RowData, error) {
+	columns := make([]string, 0, len(cols))
+	values := make([]interface{}, 0, len(cols))
 
-	for _, column := range iter.Columns() {
+	for _, column := range cols {
 		if c, ok := column.TypeInfo.(TupleTypeInfo); !ok {
 			val, err := column.TypeInfo.NewWithError()
 			if err != nil {
@@ -431,6 +435,170 @@ func (iter *Iter) MapScan(m map[string]interface{}) bool {
 	return false
 }
 
+// MapScanOptions sets the Go types of the values returned by
+// MapScanWithOptions and SliceMapWithOptions, by default the types are those
+// of MapScan. The options apply to the elements of collections, tuples and
+// UDTs too, UDTs are returned as map[string]interface{}.
+type MapScanOptions struct {
+	// TimestampsAsInt64 returns timestamp values as milliseconds since the
+	// epoch instead of time.Time.
+	TimestampsAsInt64 bool
+	// WideNumerics returns tinyint, smallint and int values as int64 and
+	// float values as float64, instead of the Go types of their CQL width.
+	WideNumerics bool
+}
+
+// MapScanWithOptions is like MapScan with the types of the values set by opts.
+func (iter *Iter) MapScanWithOptions(m map[string]interface{}, opts MapScanOptions) bool {
+	if !iter.MapScan(m) {
+		return false
+	}
+	opts.convertRow(iter.Columns(), m)
+	return true
+}
+
+// SliceMapWithOptions is like SliceMap with the types of the values set by
+// opts.
+func (iter *Iter) SliceMapWithOptions(opts MapScanOptions) ([]map[string]interface{}, error) {
+	rows, err := iter.SliceMap()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range rows {
+		opts.convertRow(iter.Columns(), m)
+	}
+	return rows, nil
+}
+
+func (o MapScanOptions) convertRow(columns []ColumnInfo, m map[string]interface{}) {
+	if o == (MapScanOptions{}) {
+		return
+	}
+	for _, column := range columns {
+		if tuple, ok := column.TypeInfo.(TupleTypeInfo); ok {
+			for i, elem := range tuple.Elems {
+				name := TupleColumnName(column.Name, i)
+				if v, ok := m[name]; ok {
+					m[name] = o.convert(elem, v)
+				}
+			}
+		} else if v, ok := m[column.Name]; ok {
+			m[column.Name] = o.convert(column.TypeInfo, v)
+		}
+	}
+}
+
+// goType returns the Go type of the values of t once converted.
+func (o MapScanOptions) goType(t TypeInfo) (reflect.Type, error) {
+	switch t.Type() {
+	case TypeTimestamp:
+		if o.TimestampsAsInt64 {
+			return reflect.TypeOf(int64(0)), nil
+		}
+	case TypeInt, TypeSmallInt, TypeTinyInt:
+		if o.WideNumerics {
+			return reflect.TypeOf(int64(0)), nil
+		}
+	case TypeFloat:
+		if o.WideNumerics {
+			return reflect.TypeOf(float64(0)), nil
+		}
+	case TypeList, TypeSet:
+		elemType, err := o.goType(t.(CollectionType).Elem)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elemType), nil
+	case TypeMap:
+		keyType, err := o.goType(t.(CollectionType).Key)
+		if err != nil {
+			return nil, err
+		}
+		valueType, err := o.goType(t.(CollectionType).Elem)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(keyType, valueType), nil
+	}
+	return goType(t)
+}
+
+// convert converts a value unmarshaled into the Go type of t by MapScan.
+func (o MapScanOptions) convert(t TypeInfo, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	switch t.Type() {
+	case TypeTimestamp:
+		if ts, ok := v.(time.Time); ok && o.TimestampsAsInt64 {
+			return ts.Unix()*1e3 + int64(ts.Nanosecond())/1e6
+		}
+	case TypeInt, TypeSmallInt, TypeTinyInt:
+		if o.WideNumerics {
+			switch rv.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+				return rv.Int()
+			}
+		}
+	case TypeFloat:
+		if f, ok := v.(float32); ok && o.WideNumerics {
+			return float64(f)
+		}
+	case TypeList, TypeSet:
+		typ, err := o.goType(t)
+		if err != nil || rv.Kind() != reflect.Slice || rv.IsNil() {
+			return v
+		}
+		elem := t.(CollectionType).Elem
+		out := reflect.MakeSlice(typ, rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			out.Index(i).Set(convertedValue(typ.Elem(), o.convert(elem, rv.Index(i).Interface())))
+		}
+		return out.Interface()
+	case TypeMap:
+		typ, err := o.goType(t)
+		if err != nil || rv.Kind() != reflect.Map || rv.IsNil() {
+			return v
+		}
+		c := t.(CollectionType)
+		out := reflect.MakeMapWithSize(typ, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := convertedValue(typ.Key(), o.convert(c.Key, iter.Key().Interface()))
+			out.SetMapIndex(key, convertedValue(typ.Elem(), o.convert(c.Elem, iter.Value().Interface())))
+		}
+		return out.Interface()
+	case TypeTuple:
+		values, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+		tuple := t.(TupleTypeInfo)
+		for i := 0; i < len(values) && i < len(tuple.Elems); i++ {
+			values[i] = o.convert(tuple.Elems[i], values[i])
+		}
+	case TypeUDT:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		for _, e := range t.(UDTTypeInfo).Elements {
+			if ev, ok := m[e.Name]; ok {
+				m[e.Name] = o.convert(e.Type, ev)
+			}
+		}
+	}
+	return v
+}
+
+func convertedValue(typ reflect.Type, v interface{}) reflect.Value {
+	if v == nil {
+		return reflect.Zero(typ)
+	}
+	return reflect.ValueOf(v)
+}
+
 func copyBytes(p []byte) []byte {
 	b := make([]byte, len(p))
 	copy(b, p)
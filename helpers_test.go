@@ -3,6 +3,7 @@ package gocql
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestGetCassandraType_Set(t *testing.T) {
@@ -212,3 +213,60 @@ func TestGetCassandraType(t *testing.T) {
 		})
 	}
 }
+
+func TestMapScanOptions(t *testing.T) {
+	native := func(typ Type) NativeType {
+		return NewNativeType(protoVersion4, typ, "")
+	}
+	udt := UDTTypeInfo{
+		NativeType: native(TypeUDT),
+		Elements: []UDTField{
+			{Name: "f", Type: native(TypeFloat)},
+			{Name: "ts", Type: native(TypeTimestamp)},
+		},
+	}
+	columns := []ColumnInfo{
+		{Name: "i", TypeInfo: native(TypeInt)},
+		{Name: "s", TypeInfo: native(TypeSmallInt)},
+		{Name: "ts", TypeInfo: native(TypeTimestamp)},
+		{Name: "l", TypeInfo: CollectionType{NativeType: native(TypeList), Elem: native(TypeTinyInt)}},
+		{Name: "m", TypeInfo: CollectionType{NativeType: native(TypeMap), Key: native(TypeInt), Elem: udt}},
+		{Name: "t", TypeInfo: TupleTypeInfo{NativeType: native(TypeTuple), Elems: []TypeInfo{native(TypeText), native(TypeFloat)}}},
+		{Name: "u", TypeInfo: udt},
+	}
+	ts := time.Unix(1, 5e6)
+	row := func() map[string]interface{} {
+		return map[string]interface{}{
+			"i":    int(1),
+			"s":    int16(2),
+			"ts":   ts,
+			"l":    []int8{3, 4},
+			"m":    map[int]map[string]interface{}{5: {"f": float32(0.5), "ts": ts}, 6: nil},
+			"t[0]": "a",
+			"t[1]": float32(1.5),
+			"u":    map[string]interface{}{"f": float32(2.5), "ts": ts},
+		}
+	}
+
+	m := row()
+	MapScanOptions{}.convertRow(columns, m)
+	if !reflect.DeepEqual(m, row()) {
+		t.Fatalf("expected no conversion without options got %v", m)
+	}
+
+	m = row()
+	MapScanOptions{TimestampsAsInt64: true, WideNumerics: true}.convertRow(columns, m)
+	expected := map[string]interface{}{
+		"i":    int64(1),
+		"s":    int64(2),
+		"ts":   int64(1005),
+		"l":    []int64{3, 4},
+		"m":    map[int64]map[string]interface{}{5: {"f": float64(0.5), "ts": int64(1005)}, 6: nil},
+		"t[0]": "a",
+		"t[1]": float64(1.5),
+		"u":    map[string]interface{}{"f": float64(2.5), "ts": int64(1005)},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expected %#v got %#v", expected, m)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -130,9 +132,98 @@ type HostInfo struct {
 	partitioner      string
 	clusterName      string
 	version          cassVersion
+	releaseVersion   string
+	cqlVersion       string
 	state            nodeState
 	schemaVersion    string
 	tokens           []string
+	features         *HostFeatures
+	tags             map[string]string
+}
+
+// ServerType is the implementation of the CQL protocol run by a host.
+type ServerType int
+
+const (
+	// ServerTypeUnknown is the type of hosts the driver did not connect to yet.
+	ServerTypeUnknown ServerType = iota
+	ServerTypeCassandra
+	ServerTypeScylla
+	// ServerTypeDSE is the type of DataStax Enterprise hosts.
+	ServerTypeDSE
+)
+
+func (t ServerType) String() string {
+	switch t {
+	case ServerTypeUnknown:
+		return "unknown"
+	case ServerTypeCassandra:
+		return "cassandra"
+	case ServerTypeScylla:
+		return "scylla"
+	case ServerTypeDSE:
+		return "dse"
+	}
+	return fmt.Sprintf("unknown_%d", int(t))
+}
+
+// HostFeatures are the protocol features and extensions negotiated with a host
+// when connecting to it. Nodes of a cluster can support different features,
+// for example during a rolling upgrade.
+type HostFeatures struct {
+	// Supported are the options advertised by the host in the SUPPORTED frame,
+	// including the extensions the driver did not request.
+	Supported map[string][]string
+	// Compression is the name of the negotiated compression, if any.
+	Compression string
+
+	// ShardAware reports whether the host is a sharded Scylla node whose
+	// shards the driver can compute from the tokens.
+	ShardAware bool
+	// Shards is the number of shards of a Scylla node, or 0.
+	Shards int
+	// ShardAwarePort and ShardAwarePortSSL are the shard-aware ports of a
+	// Scylla node, or 0 if not advertised.
+	ShardAwarePort    int
+	ShardAwarePortSSL int
+
+	// RateLimitError reports whether the host was asked to report rate
+	// limited requests with RequestErrRateLimitReached.
+	RateLimitError bool
+	// TabletsRouting reports whether the host was asked to send the tablets
+	// of misrouted requests.
+	TabletsRouting bool
+	// ServiceLevel reports whether the connection was attached to the
+	// service level of ClusterConfig.ServiceLevel.
+	ServiceLevel bool
+}
+
+// newHostFeatures returns the features of the host of c, from the options
+// it advertised in supported and the ones the driver sent in startup.
+func newHostFeatures(supported map[string][]string, startup map[string]string, c *Conn) *HostFeatures {
+	f := &HostFeatures{
+		Supported:         copySupported(supported),
+		ShardAware:        c.scyllaSupported.isShardAware(),
+		Shards:            c.scyllaSupported.nrShards,
+		ShardAwarePort:    c.scyllaSupported.shardAwarePort,
+		ShardAwarePortSSL: c.scyllaSupported.shardAwarePortSSL,
+		Compression:       startup["COMPRESSION"],
+	}
+	_, f.RateLimitError = startup[scyllaRateLimitError]
+	_, f.TabletsRouting = startup[scyllaTabletsRoutingV1]
+	_, f.ServiceLevel = startup[scyllaServiceLevel]
+	return f
+}
+
+func copySupported(supported map[string][]string) map[string][]string {
+	if supported == nil {
+		return nil
+	}
+	c := make(map[string][]string, len(supported))
+	for k, v := range supported {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
 }
 
 func (h *HostInfo) Equal(host *HostInfo) bool {
@@ -300,6 +391,89 @@ func (h *HostInfo) Version() cassVersion {
 	return h.version
 }
 
+// ReleaseVersion returns the release_version of the host as reported in the
+// system tables, for example "3.0.8" or "5.4.0-rc1" on Scylla.
+func (h *HostInfo) ReleaseVersion() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.releaseVersion
+}
+
+// CQLVersion returns the CQL version of the host, it is only known for the
+// host of the control connection as system.peers does not have it.
+func (h *HostInfo) CQLVersion() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.cqlVersion
+}
+
+// ServerType returns the implementation run by the host. Scylla hosts are
+// only recognized once connected to, from the options they advertise.
+func (h *HostInfo) ServerType() ServerType {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	switch {
+	case h.dseVersion != "":
+		return ServerTypeDSE
+	case h.features == nil:
+		return ServerTypeUnknown
+	}
+	for option := range h.features.Supported {
+		if strings.HasPrefix(option, "SCYLLA_") {
+			return ServerTypeScylla
+		}
+	}
+	return ServerTypeCassandra
+}
+
+// ShardCount returns the number of shards of a Scylla host, or 0.
+func (h *HostInfo) ShardCount() int {
+	return h.Features().Shards
+}
+
+// ScyllaExtensions returns the sorted Scylla protocol extensions advertised by
+// the host, for example SCYLLA_LWT_ADD_METADATA_MARK.
+func (h *HostInfo) ScyllaExtensions() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.features == nil {
+		return nil
+	}
+	var extensions []string
+	for option := range h.features.Supported {
+		if strings.HasPrefix(option, "SCYLLA_") {
+			extensions = append(extensions, option)
+		}
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
+// Tags returns the tags given to the host by ClusterConfig.HostTagger.
+func (h *HostInfo) Tags() map[string]string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	tags := make(map[string]string, len(h.tags))
+	for k, v := range h.tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// Tag returns the value of the tag key of the host.
+func (h *HostInfo) Tag(key string) (string, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	v, ok := h.tags[key]
+	return v, ok
+}
+
+func (h *HostInfo) setTags(tags map[string]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tags = tags
+}
+
 func (h *HostInfo) State() nodeState {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -319,6 +493,25 @@ func (h *HostInfo) Tokens() []string {
 	return h.tokens
 }
 
+// Features returns the features negotiated with the host by the last connection
+// established to it, the zero value is returned until a connection is established.
+func (h *HostInfo) Features() HostFeatures {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.features == nil {
+		return HostFeatures{}
+	}
+	f := *h.features
+	f.Supported = copySupported(f.Supported)
+	return f
+}
+
+func (h *HostInfo) setFeatures(features *HostFeatures) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.features = features
+}
+
 func (h *HostInfo) Port() int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -382,9 +575,21 @@ func (h *HostInfo) update(from *HostInfo) {
 	if h.version == (cassVersion{}) {
 		h.version = from.version
 	}
+	if h.releaseVersion == "" {
+		h.releaseVersion = from.releaseVersion
+	}
+	if h.cqlVersion == "" {
+		h.cqlVersion = from.cqlVersion
+	}
 	if h.tokens == nil {
 		h.tokens = from.tokens
 	}
+	if h.features == nil {
+		h.features = from.features
+	}
+	if h.tags == nil {
+		h.tags = from.tags
+	}
 }
 
 func (h *HostInfo) IsUp() bool {
@@ -423,6 +628,11 @@ func (h *HostInfo) String() string {
 
 // Polls system.peers at a specific interval to find new hosts
 type ringDescriber struct {
+	// acceptedPeers and rejectedPeers count the validated peers, they are
+	// first for the alignment of atomic operations
+	acceptedPeers uint64
+	rejectedPeers uint64
+
 	session         *Session
 	mu              sync.Mutex
 	prevHosts       []*HostInfo
@@ -447,9 +657,11 @@ func checkSystemSchema(control *controlConn) (bool, error) {
 
 // Given a map that represents a row from either system.local or system.peers
 // return as much information as we can in *HostInfo
-func (s *Session) hostInfoFromMap(row map[string]interface{}, host *HostInfo) (*HostInfo, error) {
+func (s *Session) hostInfoFromMap(ctx context.Context, row map[string]interface{}, host *HostInfo) (*HostInfo, error) {
 	const assertErrorMsg = "Assertion failed for %s"
 	var ok bool
+	// rowPort is set if the row has the native port of the host
+	var rowPort bool
 
 	// Default to our connected port if the cluster doesn't have port information
 	for key, value := range row {
@@ -476,6 +688,12 @@ func (s *Session) hostInfoFromMap(row map[string]interface{}, host *HostInfo) (*
 				return nil, fmt.Errorf(assertErrorMsg, "release_version")
 			}
 			host.version.Set(version)
+			host.releaseVersion = version
+		case "cql_version":
+			host.cqlVersion, ok = value.(string)
+			if !ok {
+				return nil, fmt.Errorf(assertErrorMsg, "cql_version")
+			}
 		case "peer":
 			ip, ok := value.(string)
 			if !ok {
@@ -528,6 +746,7 @@ func (s *Session) hostInfoFromMap(row map[string]interface{}, host *HostInfo) (*
 				return nil, fmt.Errorf(assertErrorMsg, "native_port")
 			}
 			host.port = native_port
+			rowPort = true
 		case "workload":
 			host.workload, ok = value.(string)
 			if !ok {
@@ -559,14 +778,23 @@ func (s *Session) hostInfoFromMap(row map[string]interface{}, host *HostInfo) (*
 		// Not sure what the port field will be called until the JIRA issue is complete
 	}
 
-	ip, port := s.cfg.translateAddressPort(host.ConnectAddress(), host.port)
+	if !rowPort {
+		// the port of a contact point given as host:port is the port of its
+		// node, system.peers has no native port
+		if port, ok := s.ring.endpointPort(host.ConnectAddress(), host.port); ok {
+			host.port = port
+		}
+	}
+
+	ip, port := s.cfg.translateHostAddressPort(ctx, host)
 	host.connectAddress = ip
 	host.port = port
+	s.cfg.tagHost(host)
 
 	return host, nil
 }
 
-func (s *Session) hostInfoFromIter(iter *Iter, connectAddress net.IP, defaultPort int) (*HostInfo, error) {
+func (s *Session) hostInfoFromIter(ctx context.Context, iter *Iter, connectAddress net.IP, defaultPort int) (*HostInfo, error) {
 	rows, err := iter.SliceMap()
 	if err != nil {
 		// TODO(zariel): make typed error
@@ -577,7 +805,7 @@ func (s *Session) hostInfoFromIter(iter *Iter, connectAddress net.IP, defaultPor
 		return nil, errors.New("query returned 0 rows")
 	}
 
-	host, err := s.hostInfoFromMap(rows[0], &HostInfo{connectAddress: connectAddress, port: defaultPort})
+	host, err := s.hostInfoFromMap(ctx, rows[0], &HostInfo{connectAddress: connectAddress, port: defaultPort})
 	if err != nil {
 		return nil, err
 	}
@@ -590,7 +818,13 @@ func (r *ringDescriber) getLocalHostInfo() (*HostInfo, error) {
 		return nil, errNoControl
 	}
 
+	// the local node listens on the port of the control connection, which
+	// may differ from the default port of the cluster, unless it is translated
+	port := r.session.cfg.Port
 	iter := r.session.control.withConnHost(func(ch *connHost) *Iter {
+		if hostPort := ch.host.Port(); hostPort > 0 && r.session.cfg.AddressTranslator == nil {
+			port = hostPort
+		}
 		return ch.conn.querySystemLocal(context.TODO())
 	})
 
@@ -598,7 +832,7 @@ func (r *ringDescriber) getLocalHostInfo() (*HostInfo, error) {
 		return nil, errNoControl
 	}
 
-	host, err := r.session.hostInfoFromIter(iter, nil, r.session.cfg.Port)
+	host, err := r.session.hostInfoFromIter(r.session.ctx, iter, nil, port)
 	if err != nil {
 		return nil, fmt.Errorf("could not retrieve local host info: %w", err)
 	}
@@ -628,15 +862,17 @@ func (r *ringDescriber) getClusterPeerInfo(localHost *HostInfo) ([]*HostInfo, er
 
 	for _, row := range rows {
 		// extract all available info about the peer
-		host, err := r.session.hostInfoFromMap(row, &HostInfo{port: r.session.cfg.Port})
+		host, err := r.session.hostInfoFromMap(r.session.ctx, row, &HostInfo{port: r.session.cfg.Port})
 		if err != nil {
 			return nil, err
-		} else if !isValidPeer(host) {
+		} else if err := r.session.cfg.validatePeer(host); err != nil {
 			// If it's not a valid peer
-			r.session.logger.Printf("Found invalid peer '%s' "+
-				"Likely due to a gossip or snitch issue, this host will be ignored", host)
+			atomic.AddUint64(&r.rejectedPeers, 1)
+			r.session.logger.Printf("Found invalid peer '%s': %v. "+
+				"Likely due to a gossip or snitch issue, this host will be ignored", host, err)
 			continue
 		}
+		atomic.AddUint64(&r.acceptedPeers, 1)
 
 		peers = append(peers, host)
 	}
@@ -646,11 +882,7 @@ func (r *ringDescriber) getClusterPeerInfo(localHost *HostInfo) ([]*HostInfo, er
 
 // Return true if the host is a valid peer
 func isValidPeer(host *HostInfo) bool {
-	return !(len(host.RPCAddress()) == 0 ||
-		host.hostId == "" ||
-		host.dataCenter == "" ||
-		host.rack == "" ||
-		len(host.tokens) == 0)
+	return validatePeer(host) == nil
 }
 
 // GetHosts returns a list of hosts found via queries to system.local and system.peers
@@ -706,6 +938,7 @@ func refreshRing(r *ringDescriber) error {
 		}
 
 		if host, ok := r.session.ring.addHostIfMissing(h); !ok {
+			r.session.nodeEventSubscribers.publish(NodeEventNew, h)
 			r.session.startPoolFill(h)
 		} else {
 			// host (by hostID) already exists; determine if IP has changed
@@ -714,17 +947,19 @@ func refreshRing(r *ringDescriber) error {
 			if !ok {
 				return fmt.Errorf("get existing host=%s from prevHosts: %w", h, ErrCannotFindHost)
 			}
-			if h.connectAddress.Equal(existing.connectAddress) && h.nodeToNodeAddress().Equal(existing.nodeToNodeAddress()) {
-				// no host IP change
+			if h.connectAddress.Equal(existing.connectAddress) && h.nodeToNodeAddress().Equal(existing.nodeToNodeAddress()) &&
+				h.Port() == existing.Port() {
+				// no host IP or port change
 				host.update(h)
 			} else {
-				// host IP has changed
+				// host IP or port has changed
 				// remove old HostInfo (w/old IP)
 				r.session.removeHost(existing)
 				if _, alreadyExists := r.session.ring.addHostIfMissing(h); alreadyExists {
 					return fmt.Errorf("add new host=%s after removal: %w", h, ErrHostAlreadyExists)
 				}
 				// add new HostInfo (same hostID, new IP)
+				r.session.nodeEventSubscribers.publish(NodeEventNew, h)
 				r.session.startPoolFill(h)
 			}
 		}
@@ -740,29 +975,32 @@ func refreshRing(r *ringDescriber) error {
 	return nil
 }
 
-const (
-	ringRefreshDebounceTime = 1 * time.Second
-)
-
 // debounces requests to call a refresh function (currently used for ring refresh). It also supports triggering a refresh immediately.
 type refreshDebouncer struct {
 	mu           sync.Mutex
 	stopped      bool
 	broadcaster  *errorBroadcaster
 	interval     time.Duration
+	maxDelay     time.Duration
+	pending      bool      // a debounced refresh is pending
+	deadline     time.Time // of the pending refresh if maxDelay is positive
 	timer        *time.Timer
 	refreshNowCh chan struct{}
 	quit         chan struct{}
 	refreshFn    func() error
 }
 
-func newRefreshDebouncer(interval time.Duration, refreshFn func() error) *refreshDebouncer {
+// newRefreshDebouncer returns a debouncer calling refreshFn once no refresh
+// was requested for interval, or once maxDelay elapsed since the first
+// request if maxDelay is positive.
+func newRefreshDebouncer(interval, maxDelay time.Duration, refreshFn func() error) *refreshDebouncer {
 	d := &refreshDebouncer{
 		stopped:      false,
 		broadcaster:  nil,
 		refreshNowCh: make(chan struct{}, 1),
 		quit:         make(chan struct{}),
 		interval:     interval,
+		maxDelay:     maxDelay,
 		timer:        time.NewTimer(interval),
 		refreshFn:    refreshFn,
 	}
@@ -778,7 +1016,12 @@ func (d *refreshDebouncer) debounce() {
 	if d.stopped {
 		return
 	}
-	d.timer.Reset(d.interval)
+	now := time.Now()
+	if !d.pending {
+		d.pending = true
+		d.deadline = now.Add(d.maxDelay)
+	}
+	d.timer.Reset(debounceDelay(now, d.interval, d.maxDelay, d.deadline))
 }
 
 // requests an immediate refresh which will cancel pending refresh requests
@@ -828,6 +1071,7 @@ func (d *refreshDebouncer) flusher() {
 
 		curBroadcaster := d.broadcaster
 		d.broadcaster = nil
+		d.pending = false
 		d.mu.Unlock()
 
 		err := d.refreshFn()
@@ -4,8 +4,10 @@
 package gocql
 
 import (
+	"context"
 	"errors"
 	"net"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -73,6 +75,160 @@ func TestIsValidPeer(t *testing.T) {
 	}
 }
 
+func TestHostInfoFromPeersV2(t *testing.T) {
+	s := &Session{cfg: ClusterConfig{Port: 9042}}
+	row := map[string]interface{}{
+		"peer":           "10.0.0.2",
+		"peer_port":      7000,
+		"native_address": "10.0.1.2",
+		"native_port":    19042,
+		"data_center":    "dc1",
+		"rack":           "rack1",
+	}
+	host, err := s.hostInfoFromMap(context.Background(), row, &HostInfo{port: s.cfg.Port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := host.ConnectAddress(); !addr.Equal(net.ParseIP("10.0.1.2")) {
+		t.Errorf("expected the native address as connect address got %v", addr)
+	}
+	if host.Port() != 19042 {
+		t.Errorf("expected the native port got %d", host.Port())
+	}
+
+	delete(row, "native_port")
+	if host, err = s.hostInfoFromMap(context.Background(), row, &HostInfo{port: s.cfg.Port}); err != nil {
+		t.Fatal(err)
+	} else if host.Port() != 9042 {
+		t.Errorf("expected the default port got %d", host.Port())
+	}
+}
+
+func TestHostInfoFromMapContactPointPort(t *testing.T) {
+	s := &Session{cfg: ClusterConfig{Port: 9042}}
+	endpoints, err := addrsToHosts([]string{"10.0.0.1:19042", "10.0.0.2:19043", "10.0.0.4:19044", "10.0.0.4:19045"}, s.cfg.Port, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ring.endpoints = endpoints
+
+	tests := []struct {
+		row         map[string]interface{}
+		defaultPort int
+		port        int
+	}{
+		{map[string]interface{}{"rpc_address": "10.0.0.2"}, s.cfg.Port, 19043},
+		{map[string]interface{}{"rpc_address": "10.0.0.2", "native_port": 29043}, s.cfg.Port, 29043},
+		{map[string]interface{}{"rpc_address": "10.0.0.3"}, s.cfg.Port, 9042},
+		// the contact points sharing an address are matched on the port
+		// the host is connected on, as for system.local
+		{map[string]interface{}{"rpc_address": "10.0.0.4"}, 19045, 19045},
+		// and are ambiguous for a peer
+		{map[string]interface{}{"rpc_address": "10.0.0.4"}, s.cfg.Port, 9042},
+	}
+	for _, test := range tests {
+		host, err := s.hostInfoFromMap(context.Background(), test.row, &HostInfo{port: test.defaultPort})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if host.Port() != test.port {
+			t.Errorf("%v: expected port %d got %d", test.row, test.port, host.Port())
+		}
+	}
+}
+
+func TestHostInfoFromMapExtendedMetadata(t *testing.T) {
+	s := &Session{cfg: ClusterConfig{
+		HostTagger: func(host *HostInfo) map[string]string {
+			return map[string]string{"dc": host.DataCenter(), "server": host.ServerType().String()}
+		},
+	}}
+	row := map[string]interface{}{
+		"rpc_address":     "10.0.0.1",
+		"data_center":     "dc1",
+		"release_version": "3.0.8",
+		"cql_version":     "3.4.0",
+		"workload":        "Analytics",
+	}
+	host, err := s.hostInfoFromMap(context.Background(), row, &HostInfo{port: 9042})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := host.ReleaseVersion(); v != "3.0.8" {
+		t.Errorf("expected release version 3.0.8, got %q", v)
+	}
+	if v := host.CQLVersion(); v != "3.4.0" {
+		t.Errorf("expected CQL version 3.4.0, got %q", v)
+	}
+	if w := host.WorkLoad(); w != "Analytics" {
+		t.Errorf("expected workload Analytics, got %q", w)
+	}
+	if v, ok := host.Tag("dc"); !ok || v != "dc1" {
+		t.Errorf("expected tag dc=dc1, got %q", v)
+	}
+	if v, _ := host.Tag("server"); v != "unknown" {
+		t.Errorf("expected tag server=unknown, got %q", v)
+	}
+}
+
+func TestHostInfoServerType(t *testing.T) {
+	tests := []struct {
+		host       *HostInfo
+		serverType ServerType
+		extensions []string
+	}{
+		{&HostInfo{}, ServerTypeUnknown, nil},
+		{&HostInfo{dseVersion: "6.8.0"}, ServerTypeDSE, nil},
+		{&HostInfo{features: &HostFeatures{Supported: map[string][]string{"CQL_VERSION": {"3.4.5"}}}}, ServerTypeCassandra, nil},
+		{&HostInfo{features: &HostFeatures{
+			Supported: map[string][]string{
+				"CQL_VERSION":            {"3.3.1"},
+				scyllaShard:              {"0"},
+				scyllaLWTAddMetadataMark: {"LWT_OPTIMIZATION_META_BIT_MASK=2147483648"},
+			},
+			Shards: 4,
+		}}, ServerTypeScylla, []string{scyllaLWTAddMetadataMark, scyllaShard}},
+	}
+	for i, test := range tests {
+		if st := test.host.ServerType(); st != test.serverType {
+			t.Errorf("%d: expected server type %v, got %v", i, test.serverType, st)
+		}
+		if ext := test.host.ScyllaExtensions(); !reflect.DeepEqual(ext, test.extensions) {
+			t.Errorf("%d: expected extensions %v, got %v", i, test.extensions, ext)
+		}
+	}
+	if n := tests[3].host.ShardCount(); n != 4 {
+		t.Errorf("expected 4 shards, got %d", n)
+	}
+}
+
+func TestQuerySystemPeersFallback(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	conn := db.getConn()
+	if conn == nil {
+		t.Fatal("no connection")
+	}
+	iter := conn.querySystemPeers(context.Background(), cassVersion{Major: 4})
+	if err := iter.Close(); err != nil {
+		t.Fatalf("expected a fallback to system.peers got %v", err)
+	}
+	conn.mu.Lock()
+	isSchemaV2 := conn.isSchemaV2
+	conn.mu.Unlock()
+	if isSchemaV2 {
+		t.Fatal("expected system.peers_v2 to be disabled on the connection")
+	}
+}
+
 func TestHostInfo_ConnectAddress(t *testing.T) {
 	var localhost = net.IPv4(127, 0, 0, 1)
 	tests := []struct {
@@ -114,7 +270,7 @@ func TestRefreshDebouncer_MultipleEvents(t *testing.T) {
 	}
 	beforeEvents := time.Now()
 	wg := sync.WaitGroup{}
-	d := newRefreshDebouncer(2*time.Second, fn)
+	d := newRefreshDebouncer(2*time.Second, 0, fn)
 	defer d.stop()
 	for i := 0; i < numberOfEvents; i++ {
 		wg.Add(1)
@@ -144,6 +300,43 @@ func TestRefreshDebouncer_MultipleEvents(t *testing.T) {
 	}
 }
 
+// This test sends debounce requests more often than the interval and checks that the
+// refresh function is called once the max delay elapsed.
+func TestRefreshDebouncer_MaxDelay(t *testing.T) {
+	channel := make(chan int, 10)
+	fn := func() error {
+		channel <- 0
+		return nil
+	}
+	beforeEvents := time.Now()
+	d := newRefreshDebouncer(200*time.Millisecond, 500*time.Millisecond, fn)
+	defer d.stop()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.debounce()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-channel:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout elapsed without refresh function being called")
+	}
+	if elapsed := time.Since(beforeEvents); elapsed < 400*time.Millisecond || elapsed > 1500*time.Millisecond {
+		t.Fatalf("function was called after %v instead of ~500ms", elapsed)
+	}
+}
+
 // This test:
 //
 //	1 - Sends debounce requests when test starts
@@ -159,7 +352,7 @@ func TestRefreshDebouncer_RefreshNow(t *testing.T) {
 	}
 	beforeEvents := time.Now()
 	eventsWg := sync.WaitGroup{}
-	d := newRefreshDebouncer(2*time.Second, fn)
+	d := newRefreshDebouncer(2*time.Second, 0, fn)
 	defer d.stop()
 	for i := 0; i < numberOfEvents; i++ {
 		eventsWg.Add(1)
@@ -225,7 +418,7 @@ func TestRefreshDebouncer_EventsAfterRefreshNow(t *testing.T) {
 	}
 	beforeEvents := time.Now()
 	wg := sync.WaitGroup{}
-	d := newRefreshDebouncer(3*time.Second, fn)
+	d := newRefreshDebouncer(3*time.Second, 0, fn)
 	defer d.stop()
 	for i := 0; i < numberOfEvents; i++ {
 		wg.Add(1)
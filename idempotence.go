@@ -0,0 +1,79 @@
+package gocql
+
+// nonIdempotentFunctions are the CQL functions returning a different value
+// at each call.
+var nonIdempotentFunctions = map[string]bool{
+	"now":              true,
+	"uuid":             true,
+	"currenttimeuuid":  true,
+	"currenttimestamp": true,
+	"currentdate":      true,
+	"currenttime":      true,
+}
+
+// inferIdempotence reports whether stmt is known to be idempotent: a SELECT,
+// or an INSERT, UPDATE or DELETE which is not a lightweight transaction,
+// does not call a function such as now() or uuid(), does not update a counter
+// nor append or prepend to a list, and does not delete a list element by
+// index. Statements which can not be told apart, such as "SET c = c + ?"
+// which may update a counter, are not idempotent.
+func inferIdempotence(stmt string) bool {
+	verb, quoted, i := nextToken(stmt, 0)
+	if quoted {
+		return false
+	}
+	switch verb {
+	case "select":
+		return true
+	case "insert", "update", "delete":
+	default:
+		return false
+	}
+
+	// the previous three unquoted tokens, most recent first, quoted tokens
+	// being identifiers
+	var prev [3]string
+	inDeletion := verb == "delete"
+	for {
+		tok, quoted, next := nextToken(stmt, i)
+		if tok == "" && !quoted {
+			return true
+		}
+		i = next
+		if quoted {
+			tok = `"` + tok
+		}
+
+		switch {
+		case quoted:
+		case tok == "if":
+			// IF NOT EXISTS, IF EXISTS or conditions
+			return false
+		case tok == "from" || tok == "using":
+			inDeletion = false
+		case tok == "(" && nonIdempotentFunctions[prev[0]]:
+			return false
+		case tok == "[" && inDeletion:
+			// deletion of a list element by index, or of a map entry
+			return false
+		case (tok == "+" || tok == "-") && prev[1] == "=" && prev[0] == prev[2] && isColumnToken(prev[0]):
+			// c = c + x updates a counter or appends to a list unless x is
+			// a set or a map, and c = c - x decrements a counter unless x
+			// is a collection
+			operand, operandQuoted, _ := nextToken(stmt, i)
+			if operandQuoted || operand != "{" && (tok == "+" || operand != "[") {
+				return false
+			}
+		case tok == "+" && (prev[0] == "]" || prev[1] == "="):
+			// prepends to a list, as in l = [1] + l or l = ? + l
+			return false
+		}
+		prev[2], prev[1], prev[0] = prev[1], prev[0], tok
+	}
+}
+
+// isColumnToken reports whether tok, as tracked by inferIdempotence, is an
+// identifier.
+func isColumnToken(tok string) bool {
+	return tok != "" && (tok[0] == '"' || isIdentStart(tok[0]))
+}
@@ -0,0 +1,81 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInferIdempotence(t *testing.T) {
+	tests := []struct {
+		stmt       string
+		idempotent bool
+	}{
+		{"SELECT * FROM ks.tbl WHERE id = ?", true},
+		{"select now() from system.local", true},
+		{"INSERT INTO tbl (id, v) VALUES (?, ?)", true},
+		{"INSERT INTO tbl (id, v) VALUES (?, ?) IF NOT EXISTS", false},
+		{"INSERT INTO tbl (id, v) VALUES (now(), ?)", false},
+		{"INSERT INTO tbl (id, v) VALUES (uuid(), 'if')", false},
+		{"INSERT INTO tbl (id, v) VALUES (?, 'now()') USING TTL 10", true},
+		{"UPDATE tbl SET v = ? WHERE id = ?", true},
+		{"UPDATE tbl SET v = ? WHERE id = ? IF v = ?", false},
+		{"UPDATE tbl SET c = c + 1 WHERE id = ?", false},
+		{"UPDATE tbl SET c = c - ? WHERE id = ?", false},
+		{`UPDATE tbl SET "C" = "C" + 1 WHERE id = ?`, false},
+		{"UPDATE tbl SET l = l + [1] WHERE id = ?", false},
+		{"UPDATE tbl SET l = [1, 2] + l WHERE id = ?", false},
+		{"UPDATE tbl SET l = ? + l WHERE id = ?", false},
+		{"UPDATE tbl SET l = l - [1] WHERE id = ?", true},
+		{"UPDATE tbl SET s = s + {1}, m = m + {'a': 1} WHERE id = ?", true},
+		{"UPDATE tbl SET l[0] = ? WHERE id = -1", true},
+		{"UPDATE tbl SET t = toTimestamp(now()) WHERE id = ?", false},
+		{"DELETE FROM tbl WHERE id = ?", true},
+		{"DELETE l[0] FROM tbl WHERE id = ?", false},
+		{"DELETE v FROM tbl WHERE id = ? IF EXISTS", false},
+		{"TRUNCATE tbl", false},
+		{"CREATE TABLE tbl (id int PRIMARY KEY)", false},
+		{`"select"`, false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := inferIdempotence(test.stmt); got != test.idempotent {
+			t.Errorf("%q: expected idempotent %v, got %v", test.stmt, test.idempotent, got)
+		}
+	}
+}
+
+func TestInferIdempotenceSession(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.InferIdempotence = true
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	if !db.Query("SELECT * FROM tbl").IsIdempotent() {
+		t.Error("expected the SELECT to be idempotent")
+	}
+	if db.Query("UPDATE tbl SET c = c + 1 WHERE id = 1").IsIdempotent() {
+		t.Error("expected the counter update not to be idempotent")
+	}
+	if !db.Query("UPDATE tbl SET c = c + 1 WHERE id = 1").Idempotent(true).IsIdempotent() {
+		t.Error("expected the idempotence to be overridden")
+	}
+
+	batch := db.NewBatch(LoggedBatch)
+	batch.Query("INSERT INTO tbl (id) VALUES (1)")
+	if !batch.IsIdempotent() {
+		t.Error("expected the batch to be idempotent")
+	}
+	batch.Query("INSERT INTO tbl (id) VALUES (2) IF NOT EXISTS")
+	if batch.IsIdempotent() {
+		t.Error("expected the conditional batch not to be idempotent")
+	}
+}
@@ -0,0 +1,54 @@
+package gocql
+
+import (
+	"context"
+	"errors"
+)
+
+// QueryHandler executes a query or batch, returning its iterator.
+type QueryHandler func(ctx context.Context, qry ExecutableQuery) *Iter
+
+// QueryInterceptor wraps the execution of the queries and batches of a
+// session, typically to check, rewrite or rate limit them. It calls next to
+// carry on with the execution, possibly with another context or query, or
+// returns an iterator without calling it, such as an iterator of an error
+// made with NewErrIter.
+//
+// qry is a *Query or a *Batch. Interceptors are called for each page of the
+// results of queries, and once for each execution of a statement however
+// many times it is retried or executed speculatively.
+type QueryInterceptor func(ctx context.Context, qry ExecutableQuery, next QueryHandler) *Iter
+
+// ErrNilIter is returned when a QueryInterceptor returns a nil iterator.
+var ErrNilIter = errors.New("gocql: query interceptor returned a nil iterator")
+
+// NewErrIter returns an iterator failing with err, for QueryInterceptor to
+// fail queries without executing them.
+func NewErrIter(err error) *Iter {
+	return &Iter{err: err}
+}
+
+// queryHandler returns the handler executing queries through interceptors,
+// the first of them being the outermost.
+func (s *Session) queryHandler(interceptors []QueryInterceptor) QueryHandler {
+	handler := func(ctx context.Context, qry ExecutableQuery) *Iter {
+		if ctx != qry.Context() {
+			qry = qry.withContext(ctx)
+		}
+		iter, err := s.executor.executeQuery(qry)
+		if err != nil {
+			return &Iter{err: err}
+		}
+		return iter
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, qry ExecutableQuery) *Iter {
+			if iter := interceptor(ctx, qry, next); iter != nil {
+				return iter
+			}
+			return &Iter{err: ErrNilIter}
+		}
+	}
+	return handler
+}
@@ -0,0 +1,70 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestQueryInterceptors(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	var calls []string
+	errDenied := errors.New("denied")
+	type ctxKey struct{}
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.QueryInterceptors = []QueryInterceptor{
+		func(ctx context.Context, qry ExecutableQuery, next QueryHandler) *Iter {
+			calls = append(calls, "outer")
+			if q, ok := qry.(*Query); ok && q.Statement() == "kill" {
+				return NewErrIter(errDenied)
+			}
+			return next(context.WithValue(ctx, ctxKey{}, "value"), qry)
+		},
+		func(ctx context.Context, qry ExecutableQuery, next QueryHandler) *Iter {
+			calls = append(calls, "inner")
+			if ctx.Value(ctxKey{}) != "value" {
+				t.Error("expected the context of the outer interceptor")
+			}
+			if _, ok := qry.(*Batch); ok {
+				return nil
+			}
+			return next(ctx, qry)
+		},
+	}
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Query("void").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Query("kill").Exec(); err != errDenied {
+		t.Errorf("expected the query to be denied, got %v", err)
+	}
+	if got := srv.nKillReq; got != 0 {
+		t.Errorf("expected the denied query not to be sent, got %d requests", got)
+	}
+	batch := db.NewBatch(LoggedBatch)
+	batch.Query("void")
+	if err := db.ExecuteBatch(batch); err != ErrNilIter {
+		t.Errorf("expected %v, got %v", ErrNilIter, err)
+	}
+
+	expected := []string{"outer", "inner", "outer", "outer", "inner"}
+	if len(calls) != len(expected) {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("expected calls %v, got %v", expected, calls)
+			break
+		}
+	}
+}
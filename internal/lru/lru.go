@@ -98,6 +98,38 @@ func (c *Cache) Remove(key string) bool {
 	return false
 }
 
+// RemoveFunc removes the items for which fn returns true and returns their
+// number.
+func (c *Cache) RemoveFunc(fn func(key string, value interface{}) bool) int {
+	if c.cache == nil {
+		return 0
+	}
+
+	removed := 0
+	for e := c.ll.Front(); e != nil; {
+		next := e.Next()
+		if kv := e.Value.(*entry); fn(kv.key, kv.value) {
+			c.removeElement(e)
+			removed++
+		}
+		e = next
+	}
+	return removed
+}
+
+// Each calls fn with the items of the cache, from the most to the least
+// recently used, until fn returns false. It does not update their recency.
+func (c *Cache) Each(fn func(key string, value interface{}) bool) {
+	if c.cache == nil {
+		return
+	}
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		if kv := e.Value.(*entry); !fn(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
 // RemoveOldest removes the oldest item from the cache.
 func (c *Cache) RemoveOldest() {
 	if c.cache == nil {
@@ -18,6 +18,7 @@ limitations under the License.
 package lru
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -60,3 +61,37 @@ func TestRemove(t *testing.T) {
 		t.Fatal("TestRemove returned a removed entry")
 	}
 }
+
+func TestRemoveFunc(t *testing.T) {
+	lru := New(0)
+	for i := 0; i < 5; i++ {
+		lru.Add(fmt.Sprint(i), i)
+	}
+
+	n := lru.RemoveFunc(func(key string, value interface{}) bool {
+		return value.(int)%2 == 0
+	})
+	if n != 3 || lru.Len() != 2 {
+		t.Fatalf("expected 3 items removed and 2 left got %d and %d", n, lru.Len())
+	}
+	if _, ok := lru.Get("1"); !ok {
+		t.Fatal("expected odd items to be left")
+	}
+}
+
+func TestEach(t *testing.T) {
+	lru := New(0)
+	for i := 0; i < 3; i++ {
+		lru.Add(fmt.Sprint(i), i)
+	}
+	lru.Get("0")
+
+	var keys []string
+	lru.Each(func(key string, value interface{}) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if len(keys) != 2 || keys[0] != "0" || keys[1] != "2" {
+		t.Fatalf("expected the most recently used items first got %v", keys)
+	}
+}
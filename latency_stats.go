@@ -0,0 +1,75 @@
+package gocql
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the buckets of LatencyStats.
+var latencyBounds = [...]time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyBucket is a bucket of the latency histogram of LatencyStats.
+type LatencyBucket struct {
+	// UpperBound is the inclusive upper bound of the latencies of the
+	// bucket, 0 for the last bucket which is unbounded.
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// LatencyStats are the latencies of the attempts to execute queries and
+// batches, successful or not.
+type LatencyStats struct {
+	Attempts uint64
+	// Total is the sum of the latencies, the average latency being
+	// Total / Attempts.
+	Total time.Duration
+	// Buckets is the histogram of the latencies, by increasing upper bound.
+	Buckets []LatencyBucket
+}
+
+type sessionLatencyStats struct {
+	attempts uint64
+	total    int64
+	buckets  [len(latencyBounds) + 1]uint64
+}
+
+func (s *sessionLatencyStats) record(latency time.Duration) {
+	atomic.AddUint64(&s.attempts, 1)
+	atomic.AddInt64(&s.total, int64(latency))
+	i := 0
+	for i < len(latencyBounds) && latency > latencyBounds[i] {
+		i++
+	}
+	atomic.AddUint64(&s.buckets[i], 1)
+}
+
+// LatencyStats returns the latencies of the attempts to execute the queries
+// and batches of the session.
+func (s *Session) LatencyStats() LatencyStats {
+	stats := LatencyStats{
+		Attempts: atomic.LoadUint64(&s.latencyStats.attempts),
+		Total:    time.Duration(atomic.LoadInt64(&s.latencyStats.total)),
+		Buckets:  make([]LatencyBucket, len(s.latencyStats.buckets)),
+	}
+	for i := range stats.Buckets {
+		if i < len(latencyBounds) {
+			stats.Buckets[i].UpperBound = latencyBounds[i]
+		}
+		stats.Buckets[i].Count = atomic.LoadUint64(&s.latencyStats.buckets[i])
+	}
+	return stats
+}
@@ -0,0 +1,308 @@
+package gocql
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrCellStreamed is returned when reading a cell larger than the threshold
+// of Query.StreamCells which was already written or discarded.
+var ErrCellStreamed = errors.New("gocql: the cell was streamed from the connection and can not be read again")
+
+// errRowClosed is returned when reading a cell of a streamed row which was
+// not read before the iterator was closed.
+var errRowClosed = errors.New("gocql: the cell can not be read after the iterator was closed")
+
+// LazyRow is a single row of a result set whose columns are only unmarshaled
+// when they are scanned. It holds the raw cells of the row as received from
+// the server, so reading a few columns of a wide row does not pay for decoding
+// all of them.
+//
+// The cells reference the buffer of the current page of the iterator and are
+// only valid until the next call to NextRow, Scan or Close on the Iter the row
+// was read from. A LazyRow can be reused between calls to NextRow to avoid allocations.
+//
+// The cells of a row of a frame streamed with Query.StreamCells are read
+// from the connection in order as they are accessed. The cells larger than
+// the threshold are not buffered: they can be written once with
+// WriteColumnTo, and are discarded when a later column is accessed.
+type LazyRow struct {
+	columns []ColumnInfo
+	cells   [][]byte
+	index   map[string]int
+
+	// streaming is set for rows of streamed frames, iter is set until the
+	// cells were read, read is the number of cells read so far and streamed
+	// records the cells larger than the threshold which were written or
+	// discarded.
+	streaming bool
+	iter      *Iter
+	read      int
+	streamed  []bool
+}
+
+// NextRow consumes the next row of the iterator into row without unmarshaling
+// any of its columns. Columns are decoded on demand with the Scan methods of row.
+// Like Scan, NextRow might send additional queries to the database to retrieve
+// the next set of rows if paging was enabled.
+//
+// NextRow returns true if a row was read or false if the end of the result set
+// was reached or if an error occurred. Close should be called afterwards to
+// retrieve any potential errors.
+func (iter *Iter) NextRow(row *LazyRow) bool {
+	iter.finishRow()
+	if iter.err != nil {
+		iter.framer.releaseBody()
+		return false
+	}
+
+	if iter.pos >= iter.numRows {
+		iter.framer.releaseBody()
+		if iter.next != nil {
+			*iter = *iter.next.fetch()
+			return iter.NextRow(row)
+		}
+		return false
+	}
+
+	if iter.next != nil && iter.pos >= iter.next.pos {
+		iter.next.fetchAsync()
+	}
+
+	if len(row.columns) != len(iter.meta.columns) || (len(row.columns) > 0 && &row.columns[0] != &iter.meta.columns[0]) {
+		row.columns = iter.meta.columns
+		row.index = nil
+	}
+	if cap(row.cells) < len(row.columns) {
+		row.cells = make([][]byte, len(row.columns))
+	}
+	row.cells = row.cells[:len(row.columns)]
+
+	row.streaming = iter.framer.body != nil
+	if row.streaming {
+		// the cells are read as they are accessed
+		if cap(row.streamed) < len(row.columns) {
+			row.streamed = make([]bool, len(row.columns))
+		}
+		row.streamed = row.streamed[:len(row.columns)]
+		for i := range row.streamed {
+			row.streamed[i] = false
+		}
+		row.iter, row.read = iter, 0
+		iter.row = row
+		iter.pos++
+		return true
+	}
+
+	for i := range row.columns {
+		cell, err := iter.readColumn()
+		if err != nil {
+			iter.err = err
+			return false
+		}
+		row.cells[i] = cell
+	}
+
+	iter.pos++
+	return true
+}
+
+// finishRow discards the cells of the current row of a streamed frame which
+// were not read yet.
+func (iter *Iter) finishRow() {
+	row := iter.row
+	if row == nil {
+		return
+	}
+	iter.row = nil
+	if err := row.readCells(len(row.cells)); err != nil && iter.err == nil {
+		iter.err = err
+	}
+	row.iter = nil
+}
+
+// readCells reads the cells of a streamed row up to the one at index to,
+// the cells larger than the threshold are discarded.
+func (r *LazyRow) readCells(to int) error {
+	f := r.iter.framer
+	for r.read < to {
+		size, err := f.peekInt()
+		if err != nil {
+			return err
+		}
+		if size > f.body.threshold {
+			f.readInt()
+			if err := f.skipBytes(size); err != nil {
+				return err
+			}
+			r.cells[r.read] = nil
+			r.streamed[r.read] = true
+		} else {
+			cell, err := f.readBytesInternal()
+			if err != nil {
+				return err
+			}
+			r.cells[r.read] = cell
+		}
+		r.read++
+	}
+	return nil
+}
+
+// cell returns the cell at index i, reading it from the connection if the
+// row is streamed.
+func (r *LazyRow) cell(i int) ([]byte, error) {
+	if i < 0 || i >= len(r.cells) {
+		return nil, fmt.Errorf("gocql: column index %d out of range [0, %d)", i, len(r.cells))
+	}
+	if !r.streaming {
+		return r.cells[i], nil
+	}
+	if i < r.read {
+		if r.streamed[i] {
+			return nil, ErrCellStreamed
+		}
+		return r.cells[i], nil
+	}
+	if r.iter == nil {
+		return nil, errRowClosed
+	}
+
+	if err := r.readCells(i); err != nil {
+		return nil, r.fail(err)
+	}
+	cell, err := r.iter.framer.readBytesInternal()
+	if err != nil {
+		return nil, r.fail(err)
+	}
+	r.cells[i] = cell
+	r.read++
+	return cell, nil
+}
+
+// fail records err, the error reading the cells of the row, on the
+// iterator.
+func (r *LazyRow) fail(err error) error {
+	if r.iter.err == nil {
+		r.iter.err = err
+	}
+	return err
+}
+
+// RawRow consumes the next row of the iterator and returns its cells still
+// encoded in the native protocol format together with the metadata of the columns,
+// which allows rows to be forwarded without unmarshaling and marshaling them again.
+// Null values are returned as nil cells.
+//
+// The cells are only valid until the next call to RawRow, NextRow, Scan or Close.
+// RawRow returns nil if the end of the result set was reached or if an error
+// occurred. Close should be called afterwards to retrieve any potential errors.
+func (iter *Iter) RawRow() ([][]byte, []ColumnInfo) {
+	var row LazyRow
+	if !iter.NextRow(&row) {
+		return nil, nil
+	}
+	if row.streaming {
+		for i := range row.cells {
+			if _, err := row.cell(i); err != nil {
+				return nil, nil
+			}
+		}
+	}
+	return row.cells, row.columns
+}
+
+// Columns returns the name and type of the columns of the row.
+func (r *LazyRow) Columns() []ColumnInfo {
+	return r.columns
+}
+
+// RawColumn returns the serialized value of the column at index i,
+// or nil if the value is null. It returns nil as well for the cells of a
+// streamed row which can not be read anymore, see WriteColumnTo.
+func (r *LazyRow) RawColumn(i int) []byte {
+	cell, _ := r.cell(i)
+	return cell
+}
+
+// WriteColumnTo writes the serialized value of the column at index i to w.
+// For blob and text columns this is the column value itself. Nothing is
+// written if the value is null.
+//
+// If the rows are streamed with Query.StreamCells and the cell is larger
+// than the threshold, it is copied from the connection to w as it is read,
+// without being buffered. Such a cell can only be written once, and not
+// anymore once a later column of the row was accessed.
+func (r *LazyRow) WriteColumnTo(i int, w io.Writer) (int64, error) {
+	if i < 0 || i >= len(r.cells) {
+		return 0, fmt.Errorf("gocql: column index %d out of range [0, %d)", i, len(r.cells))
+	}
+
+	if r.streaming && i >= r.read && r.iter != nil {
+		if err := r.readCells(i); err != nil {
+			return 0, r.fail(err)
+		}
+		f := r.iter.framer
+		size, err := f.peekInt()
+		if err != nil {
+			return 0, r.fail(err)
+		}
+		if size > f.body.threshold {
+			f.readInt()
+			r.cells[i] = nil
+			r.streamed[i] = true
+			r.read++
+			n, err := f.copyBytes(w, size)
+			if f.body.err != nil {
+				return n, r.fail(f.body.err)
+			}
+			return n, err
+		}
+	}
+
+	cell, err := r.cell(i)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(cell)
+	return int64(n), err
+}
+
+// ScanIndex unmarshals the column at index i into dest. Tuple columns are
+// unmarshaled into a single dest value, or into one dest value per element,
+// same as with Iter.Scan.
+func (r *LazyRow) ScanIndex(i int, dest ...interface{}) error {
+	cell, err := r.cell(i)
+	if err != nil {
+		return err
+	}
+
+	col := r.columns[i]
+	want := 1
+	if tuple, ok := col.TypeInfo.(TupleTypeInfo); ok {
+		want = len(tuple.Elems)
+	}
+	if len(dest) != want && len(dest) != 1 {
+		return fmt.Errorf("gocql: wrong number of values to scan column %q into: have %d want %d", col.Name, len(dest), want)
+	}
+
+	_, err = scanColumn(cell, col, dest, len(dest) == want)
+	return err
+}
+
+// Scan unmarshals the column with the given name into dest.
+func (r *LazyRow) Scan(name string, dest ...interface{}) error {
+	if r.index == nil {
+		r.index = make(map[string]int, len(r.columns))
+		for i, col := range r.columns {
+			r.index[col.Name] = i
+		}
+	}
+
+	i, ok := r.index[name]
+	if !ok {
+		return fmt.Errorf("gocql: column %q not found in row", name)
+	}
+	return r.ScanIndex(i, dest...)
+}
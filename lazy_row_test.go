@@ -0,0 +1,460 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestIterNextRow(t *testing.T) {
+	columns := []ColumnInfo{
+		{Name: "id", TypeInfo: NativeType{proto: protoVersion4, typ: TypeInt}},
+		{Name: "name", TypeInfo: NativeType{proto: protoVersion4, typ: TypeVarchar}},
+		{Name: "pair", TypeInfo: TupleTypeInfo{
+			NativeType: NativeType{proto: protoVersion4, typ: TypeTuple},
+			Elems: []TypeInfo{
+				NativeType{proto: protoVersion4, typ: TypeInt},
+				NativeType{proto: protoVersion4, typ: TypeVarchar},
+			},
+		}},
+	}
+
+	f := newFramer(nil, protoVersion4)
+	for _, id := range []int{1, 2} {
+		idBytes, err := Marshal(columns[0].TypeInfo, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.writeBytes(idBytes)
+		f.writeBytes(nil)
+		f.writeBytes([]byte{0, 0, 0, 4, 0, 0, 0, 7, 0, 0, 0, 1, 'x'})
+	}
+
+	iter := &Iter{
+		meta:    resultMetadata{columns: columns, colCount: 3, actualColCount: 4},
+		numRows: 2,
+		framer:  f,
+	}
+
+	var (
+		row  LazyRow
+		ids  []int
+		name string
+	)
+	for iter.NextRow(&row) {
+		var id int
+		if err := row.Scan("id", &id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+
+		if err := row.Scan("name", &name); err != nil {
+			t.Fatal(err)
+		} else if name != "" {
+			t.Fatalf("expected empty name for null column, got %q", name)
+		}
+		if row.RawColumn(1) != nil {
+			t.Fatalf("expected nil raw value for null column, got %v", row.RawColumn(1))
+		}
+
+		var (
+			n int
+			s string
+		)
+		if err := row.ScanIndex(2, &n, &s); err != nil {
+			t.Fatal(err)
+		} else if n != 7 || s != "x" {
+			t.Fatalf("expected tuple (7, x), got (%d, %s)", n, s)
+		}
+		if err := row.ScanIndex(2, &n); err == nil {
+			t.Fatal("expected error when scanning tuple into too few values")
+		}
+		var buf bytes.Buffer
+		if n, err := row.WriteColumnTo(0, &buf); err != nil {
+			t.Fatal(err)
+		} else if n != 4 || !bytes.Equal(buf.Bytes(), row.RawColumn(0)) {
+			t.Fatalf("expected raw id to be written, got %v", buf.Bytes())
+		}
+		if err := row.Scan("missing", &n); err == nil {
+			t.Fatal("expected error for unknown column")
+		}
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("expected ids [1 2], got %v", ids)
+	}
+}
+
+func TestIterScanTuple(t *testing.T) {
+	pair := TupleTypeInfo{
+		NativeType: NativeType{proto: protoVersion4, typ: TypeTuple},
+		Elems: []TypeInfo{
+			NativeType{proto: protoVersion4, typ: TypeInt},
+			NativeType{proto: protoVersion4, typ: TypeVarchar},
+		},
+	}
+	columns := []ColumnInfo{
+		{Name: "id", TypeInfo: NativeType{proto: protoVersion4, typ: TypeInt}},
+		{Name: "pair", TypeInfo: pair},
+	}
+
+	// the tuple is bound from a single value
+	info := &preparedStatment{request: preparedMetadata{resultMetadata: resultMetadata{columns: columns, colCount: 2, actualColCount: 3}}}
+	qry := &Query{values: []interface{}{1, Tuple{7, "x"}}}
+	values, err := qry.bindValues(info, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 {
+		t.Fatalf("expected 2 values got %d", len(values))
+	}
+	row := values[1].value
+
+	newIter := func(rows int) *Iter {
+		f := newFramer(nil, protoVersion4)
+		for i := 0; i < rows; i++ {
+			f.writeBytes(values[0].value)
+			f.writeBytes(row)
+		}
+		return &Iter{
+			meta:    resultMetadata{columns: columns, colCount: 2, actualColCount: 3},
+			numRows: rows,
+			framer:  f,
+		}
+	}
+
+	var (
+		id int
+		n  int
+		s  string
+	)
+	iter := newIter(3)
+	// one value per element
+	if !iter.Scan(&id, &n, &s) || id != 1 || n != 7 || s != "x" {
+		t.Fatalf("expected (1, (7, x)) got (%d, (%d, %s)): %v", id, n, s, iter.Close())
+	}
+	// a Tuple
+	n, s = 0, ""
+	if !iter.Scan(&id, Tuple{&n, &s}) || n != 7 || s != "x" {
+		t.Fatalf("expected (7, x) got (%d, %s): %v", n, s, iter.Close())
+	}
+	// a struct
+	var p struct {
+		N int
+		S string
+	}
+	if !iter.Scan(&id, &p) || p.N != 7 || p.S != "x" {
+		t.Fatalf("expected (7, x) got %+v: %v", p, iter.Close())
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	iter = newIter(1)
+	if iter.Scan(&id) {
+		t.Fatal("expected scanning into too few values to fail")
+	}
+	if err := iter.Close(); err == nil || !strings.Contains(err.Error(), "have 1 want 3, or 2") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	scanner := newIter(1).Scanner()
+	if !scanner.Next() {
+		t.Fatal(scanner.Err())
+	}
+	n, s = 0, ""
+	if err := scanner.Scan(&id, Tuple{&n, &s}); err != nil || n != 7 || s != "x" {
+		t.Fatalf("expected (7, x) got (%d, %s): %v", n, s, err)
+	}
+}
+
+func TestIterScanSingleElementTuple(t *testing.T) {
+	single := TupleTypeInfo{
+		NativeType: NativeType{proto: protoVersion4, typ: TypeTuple},
+		Elems:      []TypeInfo{NativeType{proto: protoVersion4, typ: TypeInt}},
+	}
+	columns := []ColumnInfo{{Name: "single", TypeInfo: single}}
+	meta := resultMetadata{columns: columns, colCount: 1, actualColCount: 1}
+
+	cell, err := Marshal(single, Tuple{7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newIter := func() *Iter {
+		f := newFramer(nil, protoVersion4)
+		f.writeBytes(cell)
+		return &Iter{meta: meta, numRows: 1, framer: f}
+	}
+
+	// the element
+	var n int
+	if iter := newIter(); !iter.Scan(&n) || n != 7 {
+		t.Fatalf("expected 7 got %d: %v", n, iter.Close())
+	}
+	// a Tuple
+	n = 0
+	if iter := newIter(); !iter.Scan(Tuple{&n}) || n != 7 {
+		t.Fatalf("expected 7 got %d: %v", n, iter.Close())
+	}
+	// a struct
+	var p struct{ N int }
+	if iter := newIter(); !iter.Scan(&p) || p.N != 7 {
+		t.Fatalf("expected 7 got %+v: %v", p, iter.Close())
+	}
+	// a slice or an array
+	var (
+		s []int
+		a [1]int
+	)
+	if iter := newIter(); !iter.Scan(&s) || len(s) != 1 || s[0] != 7 {
+		t.Fatalf("expected [7] got %v: %v", s, iter.Close())
+	}
+	if iter := newIter(); !iter.Scan(&a) || a[0] != 7 {
+		t.Fatalf("expected [7] got %v: %v", a, iter.Close())
+	}
+	// an Unmarshaler receives the element, and is not called again for the
+	// whole tuple when it fails
+	u := &failingUnmarshaler{}
+	if iter := newIter(); iter.Scan(u) || iter.Close() == nil {
+		t.Fatal("expected the error of the Unmarshaler")
+	}
+	if len(u.infos) != 1 || u.infos[0].Type() != TypeInt {
+		t.Fatalf("expected the Unmarshaler to be called once with the element, got %v", u.infos)
+	}
+
+	// LazyRow scans it the same
+	var row LazyRow
+	if iter := newIter(); !iter.NextRow(&row) {
+		t.Fatal(iter.Close())
+	}
+	n, p.N = 0, 0
+	if err := row.ScanIndex(0, &n); err != nil || n != 7 {
+		t.Fatalf("expected 7 got %d: %v", n, err)
+	}
+	if err := row.ScanIndex(0, &p); err != nil || p.N != 7 {
+		t.Fatalf("expected 7 got %+v: %v", p, err)
+	}
+}
+
+// failingUnmarshaler records the types it is asked to unmarshal, and fails.
+type failingUnmarshaler struct {
+	infos []TypeInfo
+}
+
+func (u *failingUnmarshaler) UnmarshalCQL(info TypeInfo, data []byte) error {
+	u.infos = append(u.infos, info)
+	return errors.New("can not unmarshal")
+}
+
+func TestIterScanSingleElementTupleOfList(t *testing.T) {
+	list := CollectionType{
+		NativeType: NativeType{proto: protoVersion4, typ: TypeList},
+		Elem:       NativeType{proto: protoVersion4, typ: TypeInt},
+	}
+	single := TupleTypeInfo{
+		NativeType: NativeType{proto: protoVersion4, typ: TypeTuple},
+		Elems:      []TypeInfo{list},
+	}
+	cell, err := Marshal(single, Tuple{[]int{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newFramer(nil, protoVersion4)
+	f.writeBytes(cell)
+	iter := &Iter{
+		meta:    resultMetadata{columns: []ColumnInfo{{Name: "single", TypeInfo: single}}, colCount: 1, actualColCount: 1},
+		numRows: 1,
+		framer:  f,
+	}
+
+	// the slice is the element
+	var s []int
+	if !iter.Scan(&s) || len(s) != 2 || s[0] != 1 || s[1] != 2 {
+		t.Fatalf("expected [1 2] got %v: %v", s, iter.Close())
+	}
+}
+
+func TestIterRawRow(t *testing.T) {
+	columns := []ColumnInfo{
+		{Name: "id", TypeInfo: NativeType{proto: protoVersion4, typ: TypeInt}},
+		{Name: "data", TypeInfo: NativeType{proto: protoVersion4, typ: TypeBlob}},
+	}
+
+	f := newFramer(nil, protoVersion4)
+	f.writeBytes([]byte{0, 0, 0, 1})
+	f.writeBytes([]byte("abc"))
+
+	iter := &Iter{
+		meta:    resultMetadata{columns: columns, colCount: 2, actualColCount: 2},
+		numRows: 1,
+		framer:  f,
+	}
+
+	cells, cols := iter.RawRow()
+	if len(cols) != 2 || cols[1].Name != "data" {
+		t.Fatalf("unexpected columns %v", cols)
+	}
+	if len(cells) != 2 || !bytes.Equal(cells[0], []byte{0, 0, 0, 1}) || !bytes.Equal(cells[1], []byte("abc")) {
+		t.Fatalf("unexpected cells %v", cells)
+	}
+
+	if cells, cols := iter.RawRow(); cells != nil || cols != nil {
+		t.Fatalf("expected no more rows, got %v", cells)
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readRecorder records the largest read from r.
+type readRecorder struct {
+	r   io.Reader
+	max int
+}
+
+func (r *readRecorder) Read(p []byte) (int, error) {
+	if len(p) > r.max {
+		r.max = len(p)
+	}
+	return r.r.Read(p)
+}
+
+// sumWriter sums the bytes written to it.
+type sumWriter struct {
+	n   int
+	sum int
+}
+
+func (w *sumWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		w.sum += int(b)
+	}
+	w.n += len(p)
+	return len(p), nil
+}
+
+func TestLazyRowStreamCells(t *testing.T) {
+	const size = 1 << 20
+	columns := []ColumnInfo{
+		{Name: "id", TypeInfo: NativeType{proto: protoVersion4, typ: TypeInt}},
+		{Name: "data", TypeInfo: NativeType{proto: protoVersion4, typ: TypeBlob}},
+		{Name: "name", TypeInfo: NativeType{proto: protoVersion4, typ: TypeVarchar}},
+	}
+	meta := resultMetadata{columns: columns, colCount: 3, actualColCount: 3}
+
+	w := newFramer(nil, protoVersion4)
+	for i := 0; i < 3; i++ {
+		w.writeBytes([]byte{0, 0, 0, byte(i)})
+		w.writeBytes(bytes.Repeat([]byte{byte(i + 1)}, size))
+		w.writeBytes([]byte{'a' + byte(i)})
+	}
+	body := w.buf
+
+	newIter := func() (*Iter, *readRecorder) {
+		r := &readRecorder{r: bytes.NewReader(body)}
+		f := newFramer(nil, protoVersion4)
+		f.streamFrame(r, &frameHeader{length: len(body)}, 1024)
+		return &Iter{meta: meta, numRows: 3, framer: f}, r
+	}
+
+	iter, r := newIter()
+	body0 := iter.framer.body
+	var (
+		row  LazyRow
+		id   int
+		name string
+	)
+
+	// the cell is copied from the connection
+	if !iter.NextRow(&row) {
+		t.Fatal(iter.Close())
+	}
+	if err := row.Scan("id", &id); err != nil || id != 0 {
+		t.Fatalf("expected id 0 got %d: %v", id, err)
+	}
+	var sw sumWriter
+	if n, err := row.WriteColumnTo(1, &sw); err != nil {
+		t.Fatal(err)
+	} else if n != size || sw.n != size || sw.sum != size {
+		t.Fatalf("expected %d bytes of 1 to be written, got %d (%d, sum %d)", size, n, sw.n, sw.sum)
+	}
+	if _, err := row.WriteColumnTo(1, &sw); err != ErrCellStreamed {
+		t.Fatalf("expected ErrCellStreamed writing the cell again, got %v", err)
+	}
+	if err := row.Scan("name", &name); err != nil || name != "a" {
+		t.Fatalf("expected name a got %q: %v", name, err)
+	}
+
+	// the cell is discarded when a later column is accessed
+	if !iter.NextRow(&row) {
+		t.Fatal(iter.Close())
+	}
+	if err := row.Scan("name", &name); err != nil || name != "b" {
+		t.Fatalf("expected name b got %q: %v", name, err)
+	}
+	if _, err := row.WriteColumnTo(1, &sw); err != ErrCellStreamed {
+		t.Fatalf("expected ErrCellStreamed writing a discarded cell, got %v", err)
+	}
+	if row.RawColumn(1) != nil {
+		t.Fatal("expected nil raw value for a discarded cell")
+	}
+	if err := row.Scan("id", &id); err != nil || id != 1 {
+		t.Fatalf("expected id 1 got %d: %v", id, err)
+	}
+
+	// the row is discarded by the next call to NextRow
+	if !iter.NextRow(&row) {
+		t.Fatal(iter.Close())
+	}
+	if iter.NextRow(&row) {
+		t.Fatal("expected 3 rows")
+	}
+	select {
+	case <-body0.released:
+	default:
+		t.Fatal("expected the body to be released at the end of the rows")
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r.max > streamChunkSize {
+		t.Fatalf("expected reads of at most %d bytes, got %d", streamChunkSize, r.max)
+	}
+
+	// the cells are buffered when they are scanned
+	iter, _ = newIter()
+	var data []byte
+	for i := 0; iter.Scan(&id, &data, &name); i++ {
+		if id != i || len(data) != size || data[0] != byte(i+1) || name != string(rune('a'+i)) {
+			t.Fatalf("unexpected row %d: (%d, %d bytes, %q)", i, id, len(data), name)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the body is released on close
+	iter, _ = newIter()
+	body0 = iter.framer.body
+	if !iter.NextRow(&row) {
+		t.Fatal(iter.Close())
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-body0.released:
+	default:
+		t.Fatal("expected the body to be released on close")
+	}
+	if _, err := row.WriteColumnTo(1, &sw); err != errRowClosed {
+		t.Fatalf("expected errRowClosed after close, got %v", err)
+	}
+}
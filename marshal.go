@@ -1980,8 +1980,21 @@ func unmarshalInet(info TypeInfo, data []byte, value interface{}) error {
 	return unmarshalErrorf("cannot unmarshal %s into %T", info, value)
 }
 
+// Tuple holds the elements of a tuple column, in order: the values to bind,
+// or the pointers to the values to scan the elements into, as in
+//
+//	var x, y int
+//	iter.Scan(&id, gocql.Tuple{&x, &y})
+//
+// A tuple column can also be bound from and scanned into a struct, whose
+// fields are the elements of the tuple in order, or a slice or an array.
+type Tuple []interface{}
+
 func marshalTuple(info TypeInfo, value interface{}) ([]byte, error) {
 	tuple := info.(TupleTypeInfo)
+	if v, ok := value.(Tuple); ok {
+		value = []interface{}(v)
+	}
 	switch v := value.(type) {
 	case unsetColumn:
 		return nil, unmarshalErrorf("Invalid request: UnsetValue is unsupported for tuples")
@@ -2091,6 +2104,11 @@ func unmarshalTuple(info TypeInfo, data []byte, value interface{}) error {
 
 	tuple := info.(TupleTypeInfo)
 	switch v := value.(type) {
+	case Tuple:
+		if len(v) != len(tuple.Elems) {
+			return unmarshalErrorf("can not unmarshal tuple into Tuple of length %d need %d elements", len(v), len(tuple.Elems))
+		}
+		return unmarshalTuple(info, data, []interface{}(v))
 	case []interface{}:
 		for i, elem := range tuple.Elems {
 			// each element inside data is a [bytes]
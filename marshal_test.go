@@ -1859,6 +1859,16 @@ func TestMarshalTuple(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:       "tuple:two-strings",
+			expected:   []byte("\x00\x00\x00\x03foo\x00\x00\x00\x03bar"),
+			value:      Tuple{"foo", "bar"},
+			checkValue: Tuple{&s1, &s2},
+			check: func(t *testing.T, v interface{}) {
+				checkString(t, "foo", *s1)
+				checkString(t, "bar", *s2)
+			},
+		},
 		{
 			name:     "struct:two-strings",
 			expected: []byte("\x00\x00\x00\x03foo\x00\x00\x00\x03bar"),
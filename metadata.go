@@ -117,6 +117,9 @@ type MaterializedViewMetadata struct {
 	MinIndexInterval        int
 	ReadRepairChance        float64
 	SpeculativeRetry        string
+	// PartitionKey is the partition key of the view itself, which differs
+	// from the one of its base table.
+	PartitionKey []*ColumnMetadata
 
 	baseTableName string
 }
@@ -391,6 +394,13 @@ func compileMetadata(
 	} else {
 		compileV2Metadata(tables, logger)
 	}
+
+	// the columns of the views are those of the matching entries of Tables
+	for _, view := range keyspace.MaterializedViews {
+		if table, ok := keyspace.Tables[view.Name]; ok {
+			view.PartitionKey = table.PartitionKey
+		}
+	}
 }
 
 // Compiles derived information from TableMetadata which have had
@@ -0,0 +1,183 @@
+package gocql
+
+import (
+	"fmt"
+	"sync"
+)
+
+// NodeEventType is the type of a NodeEvent.
+type NodeEventType int
+
+const (
+	// NodeEventNew is published when a node joins the ring of the session.
+	NodeEventNew NodeEventType = iota
+	// NodeEventRemoved is published when a node leaves the ring of the
+	// session.
+	NodeEventRemoved
+	// NodeEventUp is published when the session connects to a node which was
+	// new or down.
+	NodeEventUp
+	// NodeEventDown is published when the session marks a node down, after a
+	// DOWN event or once it could not reconnect to it.
+	NodeEventDown
+)
+
+func (t NodeEventType) String() string {
+	switch t {
+	case NodeEventNew:
+		return "NEW"
+	case NodeEventRemoved:
+		return "REMOVED"
+	case NodeEventUp:
+		return "UP"
+	case NodeEventDown:
+		return "DOWN"
+	}
+	return fmt.Sprintf("UNKNOWN_%d", int(t))
+}
+
+// NodeEvent is a change of the nodes of the cluster, as seen by the session.
+// Unlike the events pushed by the nodes, node events are published once the
+// session reconciled them with the system tables and its connection pools,
+// and are not repeated: a flapping node is published UP and DOWN at most once
+// per transition.
+type NodeEvent struct {
+	Type NodeEventType
+	Host *HostInfo
+}
+
+func (e NodeEvent) String() string {
+	return fmt.Sprintf("[node_event type=%s host=%s]", e.Type, e.Host.HostID())
+}
+
+type nodeEventSubscribers struct {
+	mu     sync.Mutex
+	closed bool
+	nextID int
+	subs   map[int]func(NodeEvent)
+	// closers close the channels of the subscribers when the session closes
+	closers map[int]func()
+	// states is the last state published for each host, by host ID
+	states map[string]NodeEventType
+}
+
+// SubscribeNodeEvents registers fn to be called with the node events of the
+// session, until unsubscribe is called or the session is closed. fn is called
+// from the goroutine handling the event and must not block, events may be
+// delivered concurrently.
+func (s *Session) SubscribeNodeEvents(fn func(NodeEvent)) (unsubscribe func()) {
+	return s.nodeEventSubscribers.subscribe(fn, nil)
+}
+
+// NodeEvents returns a channel receiving the node events of the session,
+// buffering up to size events. Events are dropped if the buffer is full. The
+// channel is closed once cancel is called or the session is closed.
+func (s *Session) NodeEvents(size int) (events <-chan NodeEvent, cancel func()) {
+	ch := make(chan NodeEvent, size)
+	var mu sync.Mutex
+	closed := false
+	send := func(e NodeEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+			s.logger.Printf("gocql: node event channel full, dropping %s\n", e)
+		}
+	}
+	closeCh := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			closed = true
+			close(ch)
+		}
+	}
+	unsubscribe := s.nodeEventSubscribers.subscribe(send, closeCh)
+	return ch, func() {
+		unsubscribe()
+		closeCh()
+	}
+}
+
+// subscribe registers fn and returns its unsubscribe function. onClose is
+// called if the subscribers are closed while fn is registered.
+func (n *nodeEventSubscribers) subscribe(fn func(NodeEvent), onClose func()) func() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		if onClose != nil {
+			onClose()
+		}
+		return func() {}
+	}
+	if n.subs == nil {
+		n.subs = make(map[int]func(NodeEvent))
+		n.closers = make(map[int]func())
+	}
+	id := n.nextID
+	n.nextID++
+	n.subs[id] = fn
+	if onClose != nil {
+		n.closers[id] = onClose
+	}
+	return func() {
+		n.mu.Lock()
+		delete(n.subs, id)
+		delete(n.closers, id)
+		n.mu.Unlock()
+	}
+}
+
+// publish calls the subscribers with the event, unless it repeats the last
+// state published for the host.
+func (n *nodeEventSubscribers) publish(typ NodeEventType, host *HostInfo) {
+	hostID := host.HostID()
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	if n.states == nil {
+		n.states = make(map[string]NodeEventType)
+	}
+	last, known := n.states[hostID]
+	switch typ {
+	case NodeEventUp, NodeEventDown:
+		if known && last == typ {
+			n.mu.Unlock()
+			return
+		}
+		n.states[hostID] = typ
+	case NodeEventNew:
+		n.states[hostID] = typ
+	case NodeEventRemoved:
+		delete(n.states, hostID)
+	}
+	subs := make([]func(NodeEvent), 0, len(n.subs))
+	for _, fn := range n.subs {
+		subs = append(subs, fn)
+	}
+	n.mu.Unlock()
+
+	event := NodeEvent{Type: typ, Host: host}
+	for _, fn := range subs {
+		fn(event)
+	}
+}
+
+func (n *nodeEventSubscribers) close() {
+	n.mu.Lock()
+	n.closed = true
+	closers := n.closers
+	n.subs = nil
+	n.closers = nil
+	n.mu.Unlock()
+
+	for _, onClose := range closers {
+		onClose()
+	}
+}
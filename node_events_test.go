@@ -0,0 +1,96 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNodeEventSubscribers(t *testing.T) {
+	var subs nodeEventSubscribers
+	host := &HostInfo{hostId: "h1"}
+
+	var got []NodeEventType
+	unsubscribe := subs.subscribe(func(e NodeEvent) {
+		if e.Host != host {
+			t.Errorf("unexpected host %v", e.Host)
+		}
+		got = append(got, e.Type)
+	}, nil)
+
+	for _, typ := range []NodeEventType{NodeEventNew, NodeEventUp, NodeEventUp, NodeEventDown, NodeEventDown, NodeEventUp, NodeEventRemoved} {
+		subs.publish(typ, host)
+	}
+	unsubscribe()
+	subs.publish(NodeEventNew, host)
+
+	expected := []NodeEventType{NodeEventNew, NodeEventUp, NodeEventDown, NodeEventUp, NodeEventRemoved}
+	if len(got) != len(expected) {
+		t.Fatalf("expected events %v got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected events %v got %v", expected, got)
+		}
+	}
+}
+
+func TestSessionNodeEvents(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	events, cancel := db.NodeEvents(10)
+	defer cancel()
+	callbacks := make(chan NodeEvent, 10)
+	unsubscribe := db.SubscribeNodeEvents(func(e NodeEvent) { callbacks <- e })
+	defer unsubscribe()
+
+	host := db.ring.allHosts()[0]
+	// skip the delay before connecting to nodes reported up
+	host.mu.Lock()
+	host.version = cassVersion{Major: 3, Minor: 11}
+	host.mu.Unlock()
+
+	expect := func(ch <-chan NodeEvent, typ NodeEventType) {
+		t.Helper()
+		select {
+		case e := <-ch:
+			if e.Type != typ || e.Host != host {
+				t.Fatalf("expected %s event of %v got %s", typ, host, e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %s event", typ)
+		}
+	}
+
+	if err := db.InjectStatusEvent("DOWN", host.nodeToNodeAddress(), host.Port()); err != nil {
+		t.Fatal(err)
+	}
+	expect(events, NodeEventDown)
+	expect(callbacks, NodeEventDown)
+
+	if err := db.InjectStatusEvent("UP", host.nodeToNodeAddress(), host.Port()); err != nil {
+		t.Fatal(err)
+	}
+	expect(events, NodeEventUp)
+	expect(callbacks, NodeEventUp)
+
+	db.Close()
+	select {
+	case e, ok := <-events:
+		if ok {
+			t.Fatalf("unexpected event %s", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the channel to be closed with the session")
+	}
+}
@@ -0,0 +1,101 @@
+package gocql
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// PeerValidator validates the hosts read from system.peers when the ring is
+// refreshed. A peer for which ValidatePeer returns an error is ignored: it is
+// not added to the ring nor connected to, the error is logged and the peer is
+// counted in Session.PeerStats.
+//
+// Entries of system.peers left behind by gossip or snitch issues typically
+// have no host ID or tokens and can not be dialed, DefaultPeerValidator
+// rejects them.
+type PeerValidator interface {
+	ValidatePeer(host *HostInfo) error
+}
+
+// PeerValidatorFunc converts a func(host *HostInfo) error into a PeerValidator.
+type PeerValidatorFunc func(host *HostInfo) error
+
+func (fn PeerValidatorFunc) ValidatePeer(host *HostInfo) error {
+	return fn(host)
+}
+
+// DefaultPeerValidator rejects the peers with no rpc address, host ID, data
+// center, rack or tokens.
+func DefaultPeerValidator() PeerValidator {
+	return PeerValidatorFunc(validatePeer)
+}
+
+// DataCenterPeerValidator rejects the peers outside of the data centers.
+// Unlike DataCentreHostFilter, the rejected peers are not part of the ring
+// and are not used to compute the replicas of the token aware policy.
+func DataCenterPeerValidator(dataCenters ...string) PeerValidator {
+	allowed := make(map[string]bool, len(dataCenters))
+	for _, dc := range dataCenters {
+		allowed[dc] = true
+	}
+	return PeerValidatorFunc(func(host *HostInfo) error {
+		if dc := host.DataCenter(); !allowed[dc] {
+			return fmt.Errorf("data center %q is not allowed", dc)
+		}
+		return nil
+	})
+}
+
+// PeerValidators returns a PeerValidator rejecting the peers rejected by any
+// of the validators, such as:
+//
+//	cluster.PeerValidator = gocql.PeerValidators(
+//		gocql.DefaultPeerValidator(),
+//		gocql.DataCenterPeerValidator("dc1", "dc2"),
+//	)
+func PeerValidators(validators ...PeerValidator) PeerValidator {
+	return PeerValidatorFunc(func(host *HostInfo) error {
+		for _, v := range validators {
+			if err := v.ValidatePeer(host); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func validatePeer(host *HostInfo) error {
+	switch {
+	case len(host.RPCAddress()) == 0:
+		return errors.New("no rpc address")
+	case host.HostID() == "":
+		return errors.New("no host ID")
+	case host.DataCenter() == "":
+		return errors.New("no data center")
+	case host.Rack() == "":
+		return errors.New("no rack")
+	case len(host.Tokens()) == 0:
+		return errors.New("no tokens")
+	}
+	return nil
+}
+
+// PeerStats are the statistics of the validation of the peers of a session,
+// counted at each ring refresh.
+type PeerStats struct {
+	// Accepted is the number of peers which were validated.
+	Accepted uint64
+	// Rejected is the number of peers which were rejected by the
+	// PeerValidator.
+	Rejected uint64
+}
+
+// PeerStats returns the statistics of the validation of the peers read from
+// system.peers, see ClusterConfig.PeerValidator.
+func (s *Session) PeerStats() PeerStats {
+	return PeerStats{
+		Accepted: atomic.LoadUint64(&s.hostSource.acceptedPeers),
+		Rejected: atomic.LoadUint64(&s.hostSource.rejectedPeers),
+	}
+}
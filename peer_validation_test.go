@@ -0,0 +1,55 @@
+package gocql
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPeerValidators(t *testing.T) {
+	valid := func() *HostInfo {
+		return &HostInfo{
+			rpcAddress: net.ParseIP("10.0.0.1"),
+			hostId:     "host1",
+			dataCenter: "dc1",
+			rack:       "rack1",
+			tokens:     []string{"0"},
+		}
+	}
+	noTokens := valid()
+	noTokens.tokens = nil
+	noHostID := valid()
+	noHostID.hostId = ""
+	otherDC := valid()
+	otherDC.dataCenter = "dc3"
+
+	validator := PeerValidators(DefaultPeerValidator(), DataCenterPeerValidator("dc1", "dc2"))
+	tests := []struct {
+		name  string
+		host  *HostInfo
+		valid bool
+	}{
+		{"valid", valid(), true},
+		{"no tokens", noTokens, false},
+		{"no host id", noHostID, false},
+		{"other data center", otherDC, false},
+	}
+	for _, test := range tests {
+		if err := validator.ValidatePeer(test.host); (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %t got %v", test.name, test.valid, err)
+		}
+	}
+
+	var cfg ClusterConfig
+	if err := cfg.validatePeer(noTokens); err == nil {
+		t.Error("expected the default validator to reject a peer without tokens")
+	}
+	cfg.PeerValidator = PeerValidatorFunc(func(host *HostInfo) error { return nil })
+	if err := cfg.validatePeer(noTokens); err != nil {
+		t.Errorf("expected the configured validator to accept the peer got %v", err)
+	}
+
+	s := &Session{hostSource: &ringDescriber{acceptedPeers: 3, rejectedPeers: 2}}
+	if stats := s.PeerStats(); stats != (PeerStats{Accepted: 3, Rejected: 2}) {
+		t.Errorf("unexpected peer stats %+v", stats)
+	}
+}
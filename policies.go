@@ -8,11 +8,14 @@ package gocql
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
 	"net"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -226,13 +229,13 @@ func (d *DowngradingConsistencyRetryPolicy) GetRetryType(err error) RetryType {
 		}
 		return Rethrow
 	case *RequestErrWriteTimeout:
-		if t.WriteType == "SIMPLE" || t.WriteType == "BATCH" || t.WriteType == "COUNTER" {
+		if t.WriteType == WriteTypeSimple || t.WriteType == WriteTypeBatch || t.WriteType == WriteTypeCounter {
 			if t.Received > 0 {
 				return Ignore
 			}
 			return Rethrow
 		}
-		if t.WriteType == "UNLOGGED_BATCH" {
+		if t.WriteType == WriteTypeUnloggedBatch {
 			return Retry
 		}
 		return Rethrow
@@ -243,6 +246,49 @@ func (d *DowngradingConsistencyRetryPolicy) GetRetryType(err error) RetryType {
 	}
 }
 
+// LWTRetryPolicy is a retry policy for lightweight transactions, statements
+// with an IF condition, which only retries them when they were not applied
+// for sure: retrying a transaction which was applied could apply it twice,
+// or report it as not applied because its condition no longer holds.
+//
+// Transactions rejected by the coordinator before their Paxos round started,
+// as unavailable, overloaded or bootstrapping, and the SERIAL reads which
+// timed out are retried on the next host. Write timeouts, whatever the Paxos
+// phase, write failures, CAS_WRITE_UNKNOWN errors and the errors of the
+// connections leave the outcome of the transaction unknown and are returned.
+//
+// Set ReadBack to resolve unknown outcomes with Query.ExecCAS.
+type LWTRetryPolicy struct {
+	NumRetries int
+
+	// ReadBack, if set, is called by Query.ExecCAS with the transaction when
+	// its outcome is unknown, to determine whether it was applied, typically
+	// by reading the row at SERIAL or LOCAL_SERIAL consistency. ExecCAS
+	// returns the error of the transaction if it fails.
+	ReadBack func(ctx context.Context, q *Query) (applied bool, err error)
+}
+
+func (p *LWTRetryPolicy) Attempt(q RetryableQuery) bool {
+	return q.Attempts() <= p.NumRetries
+}
+
+func (p *LWTRetryPolicy) GetRetryType(err error) RetryType {
+	if IsIdempotentSafe(err) && !casOutcomeUnknown(err) {
+		return RetryNextHost
+	}
+	return Rethrow
+}
+
+// casOutcomeUnknown reports whether err leaves the outcome of a lightweight
+// transaction unknown.
+func casOutcomeUnknown(err error) bool {
+	switch err.(type) {
+	case *RequestErrWriteTimeout, *RequestErrWriteFailure, *RequestErrCASWriteUnknown:
+		return true
+	}
+	return errors.Is(err, ErrTimeoutNoResponse) || errors.Is(err, ErrConnectionClosed)
+}
+
 func (e *ExponentialBackoffRetryPolicy) napTime(attempts int) time.Duration {
 	return getExponentialTime(e.Min, e.Max, attempts)
 }
@@ -313,6 +359,22 @@ func RoundRobinHostPolicy() HostSelectionPolicy {
 	return &roundRobinHostPolicy{}
 }
 
+// planPeeker is implemented by the host selection policies whose Pick changes
+// their state, such as the offset of a round robin, to return the query plan
+// the next Pick would return without changing it.
+type planPeeker interface {
+	peek(qry ExecutableQuery) NextHost
+}
+
+// peekPlan returns the query plan of the policy for qry, without changing
+// the state of the policy if it implements planPeeker.
+func peekPlan(policy HostSelectionPolicy, qry ExecutableQuery) NextHost {
+	if p, ok := policy.(planPeeker); ok {
+		return p.peek(qry)
+	}
+	return policy.Pick(qry)
+}
+
 type roundRobinHostPolicy struct {
 	hosts           cowHostList
 	lastUsedHostIdx uint64
@@ -328,6 +390,11 @@ func (r *roundRobinHostPolicy) Pick(qry ExecutableQuery) NextHost {
 	return roundRobbin(int(nextStartOffset), r.hosts.get())
 }
 
+func (r *roundRobinHostPolicy) peek(qry ExecutableQuery) NextHost {
+	nextStartOffset := atomic.LoadUint64(&r.lastUsedHostIdx) + 1
+	return roundRobbin(int(nextStartOffset), r.hosts.get())
+}
+
 func (r *roundRobinHostPolicy) AddHost(host *HostInfo) {
 	r.hosts.add(host)
 }
@@ -344,6 +411,76 @@ func (r *roundRobinHostPolicy) HostDown(host *HostInfo) {
 	r.RemoveHost(host)
 }
 
+// DeterministicHostPolicy is a host selection policy trying the hosts in the
+// same order for every query, a permutation of the hosts determined by the
+// seed and their addresses. The order does not depend on the order the hosts
+// were added in nor on time, so that tests asserting on the host serving a
+// request are not flaky. It does not balance the load, use it in tests only,
+// possibly as the fallback of a token aware policy without ShuffleReplicas.
+func DeterministicHostPolicy(seed int64) HostSelectionPolicy {
+	return &deterministicHostPolicy{seed: seed}
+}
+
+type deterministicHostPolicy struct {
+	seed  int64
+	hosts cowHostList
+}
+
+func (d *deterministicHostPolicy) IsLocal(*HostInfo) bool              { return true }
+func (d *deterministicHostPolicy) KeyspaceChanged(KeyspaceUpdateEvent) {}
+func (d *deterministicHostPolicy) SetPartitioner(partitioner string)   {}
+func (d *deterministicHostPolicy) Init(*Session)                       {}
+
+// rank returns the position of the host in the order of the seed.
+func (d *deterministicHostPolicy) rank(host *HostInfo) uint64 {
+	h := fnv.New64a()
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], uint64(d.seed))
+	h.Write(seed[:])
+	h.Write([]byte(host.ConnectAddressAndPort()))
+	return h.Sum64()
+}
+
+func (d *deterministicHostPolicy) Pick(qry ExecutableQuery) NextHost {
+	// the list is shared with the other queries
+	hosts := append([]*HostInfo(nil), d.hosts.get()...)
+	ranks := make(map[*HostInfo]uint64, len(hosts))
+	for _, host := range hosts {
+		ranks[host] = d.rank(host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return ranks[hosts[i]] < ranks[hosts[j]]
+	})
+
+	var i int
+	return func() SelectedHost {
+		for i < len(hosts) {
+			host := hosts[i]
+			i++
+			if host.IsUp() {
+				return (*selectedHost)(host)
+			}
+		}
+		return nil
+	}
+}
+
+func (d *deterministicHostPolicy) AddHost(host *HostInfo) {
+	d.hosts.add(host)
+}
+
+func (d *deterministicHostPolicy) RemoveHost(host *HostInfo) {
+	d.hosts.remove(host.ConnectAddress())
+}
+
+func (d *deterministicHostPolicy) HostUp(host *HostInfo) {
+	d.AddHost(host)
+}
+
+func (d *deterministicHostPolicy) HostDown(host *HostInfo) {
+	d.RemoveHost(host)
+}
+
 func ShuffleReplicas() func(*tokenAwareHostPolicy) {
 	return func(t *tokenAwareHostPolicy) {
 		t.shuffleReplicas = true
@@ -398,6 +535,10 @@ type tokenAwareHostPolicy struct {
 	partitioner string
 	metadata    atomic.Value // *clusterMeta
 
+	// tablets and getHost are used to route queries to tables of keyspaces using tablets.
+	tablets *tabletMap
+	getHost func(hostID string) *HostInfo
+
 	logger StdLogger
 }
 
@@ -411,6 +552,8 @@ func (t *tokenAwareHostPolicy) Init(s *Session) {
 	}
 	t.getKeyspaceMetadata = s.KeyspaceMetadata
 	t.getKeyspaceName = func() string { return s.cfg.Keyspace }
+	t.tablets = s.tablets
+	t.getHost = s.ring.getHost
 	t.logger = s.logger
 }
 
@@ -559,27 +702,70 @@ func (m *clusterMeta) resetTokenRing(partitioner string, hosts []*HostInfo, logg
 }
 
 func (t *tokenAwareHostPolicy) Pick(qry ExecutableQuery) NextHost {
+	return t.pick(qry, t.fallback.Pick)
+}
+
+func (t *tokenAwareHostPolicy) peek(qry ExecutableQuery) NextHost {
+	return t.pick(qry, func(qry ExecutableQuery) NextHost {
+		return peekPlan(t.fallback, qry)
+	})
+}
+
+// pick returns the query plan of qry, using fallbackPick for the plan of
+// the fallback policy.
+func (t *tokenAwareHostPolicy) pick(qry ExecutableQuery, fallbackPick func(ExecutableQuery) NextHost) NextHost {
 	if qry == nil {
-		return t.fallback.Pick(qry)
+		return fallbackPick(qry)
+	}
+	q, _ := qry.(*Query)
+	if q != nil && q.noTokenAwareRouting {
+		return fallbackPick(qry)
 	}
 
-	routingKey, err := qry.GetRoutingKey()
-	if err != nil {
-		return t.fallback.Pick(qry)
-	} else if routingKey == nil {
-		return t.fallback.Pick(qry)
+	var routingKey []byte
+	if q == nil || q.routingToken == "" {
+		var err error
+		routingKey, err = qry.GetRoutingKey()
+		if err != nil {
+			return fallbackPick(qry)
+		} else if routingKey == nil {
+			return fallbackPick(qry)
+		}
 	}
 
 	meta := t.getMetadataReadOnly()
 	if meta == nil || meta.tokenRing == nil {
-		return t.fallback.Pick(qry)
+		return fallbackPick(qry)
 	}
 
-	token := meta.tokenRing.partitioner.Hash(routingKey)
+	var token Token
+	if routingKey != nil {
+		token = meta.tokenRing.partitioner.Hash(routingKey)
+	} else {
+		var err error
+		if token, err = parseToken(meta.tokenRing.partitioner, q.routingToken); err != nil {
+			return fallbackPick(qry)
+		}
+	}
 	ht := meta.replicas[qry.Keyspace()].replicasFor(token)
 
-	var replicas []*HostInfo
-	if ht == nil {
+	var (
+		replicas     []*HostInfo
+		tabletShards map[*HostInfo]int
+	)
+	if tablet, ok := t.findTablet(qry, token); ok {
+		replicas = make([]*HostInfo, 0, len(tablet.replicas))
+		tabletShards = make(map[*HostInfo]int, len(tablet.replicas))
+		for _, replica := range tablet.replicas {
+			if host := t.getHost(replica.hostID); host != nil {
+				replicas = append(replicas, host)
+				tabletShards[host] = replica.shard
+			}
+		}
+		if t.shuffleReplicas {
+			replicas = shuffleHosts(replicas)
+		}
+	} else if ht == nil {
 		host, _ := meta.tokenRing.GetHostForToken(token)
 		replicas = []*HostInfo{host}
 	} else {
@@ -608,6 +794,16 @@ func (t *tokenAwareHostPolicy) Pick(qry ExecutableQuery) NextHost {
 		remote = make([][]*HostInfo, maxTier)
 	}
 
+	selected := func(h *HostInfo) SelectedHost {
+		sh := &shardSelectedHost{host: h, token: token, shard: -1}
+		if shard, ok := tabletShards[h]; ok {
+			sh.shard = shard
+			sh.tablets = t.tablets
+			sh.keyspace, sh.table = qry.Keyspace(), qry.Table()
+		}
+		return sh
+	}
+
 	used := make(map[*HostInfo]bool, len(replicas))
 	return func() SelectedHost {
 		for i < len(replicas) {
@@ -632,7 +828,7 @@ func (t *tokenAwareHostPolicy) Pick(qry ExecutableQuery) NextHost {
 
 			if h.IsUp() {
 				used[h] = true
-				return (*selectedHost)(h)
+				return selected(h)
 			}
 		}
 
@@ -648,14 +844,14 @@ func (t *tokenAwareHostPolicy) Pick(qry ExecutableQuery) NextHost {
 
 				if h.IsUp() {
 					used[h] = true
-					return (*selectedHost)(h)
+					return selected(h)
 				}
 			}
 		}
 
 		if fallbackIter == nil {
 			// fallback
-			fallbackIter = t.fallback.Pick(qry)
+			fallbackIter = fallbackPick(qry)
 		}
 
 		// filter the token aware selected hosts from the fallback hosts
@@ -670,6 +866,15 @@ func (t *tokenAwareHostPolicy) Pick(qry ExecutableQuery) NextHost {
 	}
 }
 
+// findTablet returns the tablet owning the token of the query, if the table of the query uses tablets.
+func (t *tokenAwareHostPolicy) findTablet(qry ExecutableQuery, token Token) (tablet, bool) {
+	mt, ok := token.(murmur3Token)
+	if !ok || t.tablets == nil || qry.Table() == "" {
+		return tablet{}, false
+	}
+	return t.tablets.find(qry.Keyspace(), qry.Table(), int64(mt))
+}
+
 // HostPoolHostPolicy is a host policy which uses the bitly/go-hostpool library
 // to distribute queries between hosts and prevent sending queries to
 // unresponsive hosts. When creating the host pool that is passed to the policy
@@ -899,6 +1104,11 @@ func (d *dcAwareRR) Pick(q ExecutableQuery) NextHost {
 	return roundRobbin(int(nextStartOffset), d.localHosts.get(), d.remoteHosts.get())
 }
 
+func (d *dcAwareRR) peek(q ExecutableQuery) NextHost {
+	nextStartOffset := atomic.LoadUint64(&d.lastUsedHostIdx) + 1
+	return roundRobbin(int(nextStartOffset), d.localHosts.get(), d.remoteHosts.get())
+}
+
 // RackAwareRoundRobinPolicy is a host selection policies which will prioritize and
 // return hosts which are in the local rack, before hosts in the local datacenter but
 // a different rack, before hosts in all other datercentres
@@ -961,6 +1171,11 @@ func (d *rackAwareRR) Pick(q ExecutableQuery) NextHost {
 	return roundRobbin(int(nextStartOffset), d.hosts[0].get(), d.hosts[1].get(), d.hosts[2].get())
 }
 
+func (d *rackAwareRR) peek(q ExecutableQuery) NextHost {
+	nextStartOffset := atomic.LoadUint64(&d.lastUsedHostIdx) + 1
+	return roundRobbin(int(nextStartOffset), d.hosts[0].get(), d.hosts[1].get(), d.hosts[2].get())
+}
+
 // ReadyPolicy defines a policy for when a HostSelectionPolicy can be used. After
 // each host connects during session initialization, the Ready method will be
 // called. If you only need a single Host to be up you can wrap a
@@ -43,6 +43,63 @@ func TestRoundRobbin(t *testing.T) {
 	}
 }
 
+func TestHostPolicy_Deterministic(t *testing.T) {
+	hosts := [...]*HostInfo{
+		{hostId: "0", connectAddress: net.IPv4(10, 0, 0, 1), port: 9042},
+		{hostId: "1", connectAddress: net.IPv4(10, 0, 0, 2), port: 9042},
+		{hostId: "2", connectAddress: net.IPv4(10, 0, 0, 3), port: 9042},
+		{hostId: "3", connectAddress: net.IPv4(10, 0, 0, 4), port: 9042},
+	}
+	plan := func(policy HostSelectionPolicy) []string {
+		var ids []string
+		it := policy.Pick(nil)
+		for h := it(); h != nil; h = it() {
+			ids = append(ids, h.Info().hostId)
+		}
+		return ids
+	}
+
+	policy := DeterministicHostPolicy(1)
+	for _, host := range hosts {
+		policy.AddHost(host)
+	}
+	// the order does not depend on the order the hosts were added in
+	reversed := DeterministicHostPolicy(1)
+	for i := len(hosts) - 1; i >= 0; i-- {
+		reversed.AddHost(hosts[i])
+	}
+
+	expected := plan(policy)
+	if len(expected) != len(hosts) {
+		t.Fatalf("expected %d hosts got %v", len(hosts), expected)
+	}
+	for i := 0; i < 3; i++ {
+		assertDeepEqual(t, "plan", expected, plan(policy))
+	}
+	assertDeepEqual(t, "plan of reversed hosts", expected, plan(reversed))
+
+	seeds := make(map[string]bool)
+	for seed := int64(0); seed < 8; seed++ {
+		other := DeterministicHostPolicy(seed)
+		for _, host := range hosts {
+			other.AddHost(host)
+		}
+		seeds[fmt.Sprint(plan(other))] = true
+	}
+	if len(seeds) < 2 {
+		t.Fatalf("expected the order of the hosts to depend on the seed, got %v", seeds)
+	}
+
+	first := hosts[0]
+	for _, host := range hosts {
+		if host.hostId == expected[0] {
+			first = host
+		}
+	}
+	first.setState(NodeDown)
+	assertDeepEqual(t, "plan without down host", expected[1:], plan(policy))
+}
+
 // Tests of the token-aware host selection policy implementation with a
 // round-robin host selection policy fallback.
 func TestHostPolicy_TokenAware_SimpleStrategy(t *testing.T) {
@@ -374,6 +431,40 @@ func TestDowngradingConsistencyRetryPolicy(t *testing.T) {
 	}
 }
 
+func TestLWTRetryPolicy(t *testing.T) {
+	q := &Query{cons: LocalQuorum, routingInfo: &queryRoutingInfo{}}
+	rt := &LWTRetryPolicy{NumRetries: 2}
+
+	cases := []struct {
+		err       error
+		retryType RetryType
+	}{
+		{&RequestErrUnavailable{Consistency: Consistency(Serial)}, RetryNextHost},
+		{&RequestErrReadTimeout{Consistency: Consistency(Serial)}, RetryNextHost},
+		{&RequestErrWriteTimeout{WriteType: "CAS"}, Rethrow},
+		{&RequestErrWriteTimeout{WriteType: "SIMPLE", Received: 1}, Rethrow},
+		{&RequestErrWriteFailure{WriteType: "CAS"}, Rethrow},
+		{&RequestErrCASWriteUnknown{}, Rethrow},
+		{errorFrame{code: ErrCodeOverloaded}, RetryNextHost},
+		{errorFrame{code: ErrCodeBootstrapping}, RetryNextHost},
+		{errorFrame{code: ErrCodeInvalid}, Rethrow},
+		{ErrTimeoutNoResponse, Rethrow},
+		{fmt.Errorf("wrapped: %w", ErrConnectionClosed), Rethrow},
+	}
+	for _, c := range cases {
+		if retryType := rt.GetRetryType(c.err); retryType != c.retryType {
+			t.Errorf("%v: expected retry type %v got %v", c.err, c.retryType, retryType)
+		}
+	}
+
+	for attempts, allow := range []bool{true, true, true, false} {
+		q.metrics = preFilledQueryMetrics(map[string]*hostMetrics{"127.0.0.1": {Attempts: attempts}})
+		if rt.Attempt(q) != allow {
+			t.Errorf("after %d attempts: expected Attempt to return %v", attempts, allow)
+		}
+	}
+}
+
 // expectHosts makes sure that the next len(hostIDs) returned from iter is a permutation of hostIDs.
 func expectHosts(t *testing.T, msg string, iter NextHost, hostIDs ...string) {
 	t.Helper()
@@ -566,6 +657,20 @@ func TestHostPolicy_TokenAware(t *testing.T) {
 	// next are in non-deterministic order
 	expectHosts(t, "rest", iter, "0", "1", "2", "3", "5", "6", "7", "8", "9", "10", "11")
 	expectNoMoreHosts(t, iter)
+
+	// the routing token overrides the routing key
+	query.WithRoutingToken("38")
+	iter = policy.Pick(query)
+	expectHosts(t, "matching routing token from local DC", iter, "7")
+	expectHosts(t, "rest", iter, "0", "1", "2", "3", "4", "5", "6", "8", "9", "10", "11")
+	expectNoMoreHosts(t, iter)
+
+	// the fallback policy routes the query without token aware routing
+	query.NoTokenAwareRouting()
+	iter = policy.Pick(query)
+	expectHosts(t, "local DC", iter, "1", "4", "7", "10")
+	expectHosts(t, "remote DCs", iter, "0", "2", "3", "5", "6", "8", "9", "11")
+	expectNoMoreHosts(t, iter)
 }
 
 // Tests of the token-aware host selection policy implementation with a
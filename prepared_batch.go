@@ -0,0 +1,199 @@
+package gocql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// PreparedBatch is a batch whose statements are prepared on all the up hosts
+// of the session, concurrently, before it is executed, so that executing it
+// never prepares statements inline on the coordinator. Statements prepared by
+// a previous execution are not prepared again, unless they were evicted from
+// the prepared statement cache since.
+//
+// Unless it is set with RoutingKey, its routing key is the one of the first
+// statement for which it can be computed, instead of only the first
+// statement of the batch.
+type PreparedBatch struct {
+	*Batch
+
+	// prepared are the statements prepared by a previous execution, they are
+	// checked against the prepared statement cache as it can evict them.
+	prepared map[string]struct{}
+	// inferredKey is the routing key computed from the statements, it is not
+	// recomputed once the routing key of the batch is set to another one.
+	inferredKey []byte
+	err         error
+}
+
+// NewPreparedBatch creates a new prepared batch using defaults defined in
+// the cluster.
+func (s *Session) NewPreparedBatch(typ BatchType) *PreparedBatch {
+	return &PreparedBatch{
+		Batch:    s.NewBatch(typ),
+		prepared: make(map[string]struct{}),
+	}
+}
+
+// Query adds the query to the batch. The statement must be preparable,
+// executing the batch fails otherwise.
+func (b *PreparedBatch) Query(stmt string, args ...interface{}) {
+	if b.err == nil && !(&Query{stmt: stmt}).shouldPrepare() {
+		b.err = fmt.Errorf("gocql: statement can not be prepared in a prepared batch: %q", stmt)
+	}
+	b.Batch.Query(stmt, args...)
+}
+
+// Prepare prepares the statements of the batch which were not prepared yet
+// on all the up hosts. It fails if a statement could not be prepared on any
+// host, the hosts a statement could not be prepared on prepare it inline.
+func (b *PreparedBatch) Prepare(ctx context.Context) error {
+	if b.err != nil {
+		return b.err
+	}
+	s := b.session
+	if s == nil || s.Closed() {
+		return ErrSessionClosed
+	}
+
+	conns := s.upConns()
+	var stmts []string
+	for _, entry := range b.Entries {
+		if len(entry.Args) == 0 && entry.binding == nil {
+			// sent as is
+			continue
+		}
+		if _, ok := b.prepared[entry.Stmt]; ok && preparedOn(conns, entry.Stmt) {
+			continue
+		}
+		b.prepared[entry.Stmt] = struct{}{}
+		stmts = append(stmts, entry.Stmt)
+	}
+	if len(stmts) > 0 {
+		if err := s.prepareOnHosts(ctx, conns, stmts); err != nil {
+			for _, stmt := range stmts {
+				delete(b.prepared, stmt)
+			}
+			return err
+		}
+	}
+
+	if b.routingKey != nil && !bytes.Equal(b.routingKey, b.inferredKey) {
+		// set explicitly
+		return nil
+	}
+	b.routingKey = nil
+	b.inferredKey = nil
+	for _, entry := range b.Entries {
+		if entry.binding != nil || len(entry.Args) == 0 {
+			continue
+		}
+		info, err := s.routingKeyInfo(ctx, entry.Stmt)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			continue
+		}
+		key, err := createRoutingKey(info, entry.Args)
+		if err != nil {
+			return err
+		}
+		if key != nil {
+			b.routingKey = key
+			b.inferredKey = key
+			break
+		}
+	}
+	return nil
+}
+
+// Exec prepares the batch and executes it.
+func (b *PreparedBatch) Exec() error {
+	return b.ExecContext(b.Context())
+}
+
+// ExecContext prepares the batch and executes it with ctx.
+func (b *PreparedBatch) ExecContext(ctx context.Context) error {
+	if err := b.Prepare(ctx); err != nil {
+		return err
+	}
+	return b.session.ExecuteBatchContext(ctx, b.Batch)
+}
+
+// upConns returns a connection to each of the up hosts of the session.
+func (s *Session) upConns() []*Conn {
+	var conns []*Conn
+	for _, host := range s.ring.allHosts() {
+		if !host.IsUp() {
+			continue
+		}
+		if pool, ok := s.pool.getPool(host); ok {
+			if conn := pool.Pick(); conn != nil {
+				conns = append(conns, conn)
+			}
+		}
+	}
+	return conns
+}
+
+// preparedOn reports whether stmt is in the prepared statement cache for
+// all of conns.
+func preparedOn(conns []*Conn, stmt string) bool {
+	for _, conn := range conns {
+		if !conn.session.stmtsLRU.contains(conn.stmtCacheKey(stmt)) {
+			return false
+		}
+	}
+	return true
+}
+
+// prepareOnHosts prepares stmts on conns, the connections to the up hosts of
+// the session, on at most PrepareOnAllHostsConcurrency hosts at a time. It
+// fails if a statement could not be prepared on any host.
+func (s *Session) prepareOnHosts(ctx context.Context, conns []*Conn, stmts []string) error {
+	if len(conns) == 0 {
+		return ErrNoConnections
+	}
+
+	concurrency := s.cfg.PrepareOnAllHostsConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		prepared = make(map[string]bool, len(stmts))
+		errs     = make(map[string]error, len(stmts))
+	)
+	for _, conn := range conns {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(conn *Conn) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			for _, stmt := range stmts {
+				_, err := conn.prepareStatement(ctx, stmt, nil)
+				mu.Lock()
+				if err == nil {
+					prepared[stmt] = true
+				} else {
+					errs[stmt] = err
+				}
+				mu.Unlock()
+			}
+		}(conn)
+	}
+	wg.Wait()
+
+	for _, stmt := range stmts {
+		if !prepared[stmt] {
+			return fmt.Errorf("gocql: unable to prepare %q: %w", stmt, errs[stmt])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestPreparedBatch(t *testing.T) {
+	srv1 := NewTestServer(t, protoVersion4, context.Background())
+	defer srv1.Stop()
+	srv2 := NewTestServer(t, protoVersion4, context.Background())
+	defer srv2.Stop()
+
+	db, err := testCluster(protoVersion4, srv1.Address, srv2.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	b := db.NewPreparedBatch(LoggedBatch)
+	b.Query("UPDATE t SET c = 1 WHERE id = 2")
+	b.Query("INSERT INTO t (pk, c) VALUES (:pk, :c)", 3, 4)
+	b.Query("INSERT INTO t (pk, c) VALUES (:pk, :c)", 3, 5)
+	if err := b.Prepare(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the statement with values is prepared on both hosts
+	stats := db.PreparedCacheStats()
+	if stats.Size != 2 {
+		t.Fatalf("expected 2 prepared statements, got %+v", stats)
+	}
+
+	// the first statement has no routing key
+	info, err := db.Query("INSERT INTO t (pk, c) VALUES (:pk, :c)").RoutingKeyInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := info.RoutingKey(3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := b.GetRoutingKey(); err != nil || !bytes.Equal(key, expected) {
+		t.Errorf("expected routing key %x got %x (%v)", expected, key, err)
+	}
+
+	if err := b.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if misses := db.PreparedCacheStats().Misses; misses != stats.Misses {
+		t.Errorf("expected no statement to be prepared by the execution, %d were", misses-stats.Misses)
+	}
+
+	// statements evicted from the cache are prepared again
+	db.stmtsLRU.clear()
+	if err := b.Prepare(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if size := db.PreparedCacheStats().Size; size != 2 {
+		t.Errorf("expected the evicted statement to be prepared on both hosts, %d are cached", size)
+	}
+
+	// a routing key set explicitly is kept
+	b.RoutingKey([]byte("key"))
+	if err := b.Prepare(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := b.GetRoutingKey(); err != nil || string(key) != "key" {
+		t.Errorf("expected the explicit routing key got %x (%v)", key, err)
+	}
+}
+
+func TestPreparedBatchInvalid(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	b := db.NewPreparedBatch(LoggedBatch)
+	b.Query("TRUNCATE t")
+	if err := b.Exec(); err == nil {
+		t.Error("expected an error for a statement which can not be prepared")
+	}
+
+	b = db.NewPreparedBatch(LoggedBatch)
+	b.Query("INSERT INTO missing (c) VALUES (?)", 1)
+	if err := b.Exec(); err == nil {
+		t.Error("expected an error for a statement which fails to prepare")
+	}
+}
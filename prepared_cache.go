@@ -2,54 +2,271 @@ package gocql
 
 import (
 	"bytes"
-	"github.com/gocql/gocql/internal/lru"
 	"sync"
+	"time"
+
+	"github.com/gocql/gocql/internal/lru"
 )
 
 const defaultMaxPreparedStmts = 1000
 
+// PreparedCacheStats are the statistics of the prepared statement cache of a session.
+type PreparedCacheStats struct {
+	// Hits is the number of statements which were found in the cache.
+	Hits uint64
+	// Misses is the number of statements which had to be prepared.
+	Misses uint64
+	// Evictions is the number of statements evicted because the cache was full.
+	Evictions uint64
+	// Size is the current number of statements in the cache.
+	Size int
+}
+
 // preparedLRU is the prepared statement cache
 type preparedLRU struct {
 	mu  sync.Mutex
 	lru *lru.Cache
+
+	// keyspaces limits the number of statements per keyspace when
+	// maxPerKeyspace is set, each keyspace is tracked in its own lru of keys.
+	keyspaces      map[string]*lru.Cache
+	maxPerKeyspace int
+
+	// removing is set while statements are explicitly removed, so they are
+	// not accounted as evictions.
+	removing  bool
+	onEvicted func(host, keyspace, statement string)
+
+	hits, misses, evictions uint64
+}
+
+func newPreparedLRU(maxEntries, maxPerKeyspace int, onEvicted func(host, keyspace, statement string)) *preparedLRU {
+	p := &preparedLRU{
+		lru:            lru.New(maxEntries),
+		maxPerKeyspace: maxPerKeyspace,
+		onEvicted:      onEvicted,
+	}
+	if maxPerKeyspace > 0 {
+		p.keyspaces = make(map[string]*lru.Cache)
+	}
+	p.lru.OnEvicted = p.evicted
+	return p
+}
+
+// evicted is called with p.mu held whenever a statement leaves the cache.
+func (p *preparedLRU) evicted(key string, val interface{}) {
+	ifp, _ := val.(*inflightPrepare)
+	if ifp != nil && p.keyspaces != nil {
+		if ks, ok := p.keyspaces[ifp.keyspace]; ok {
+			ks.Remove(key)
+			if ks.Len() == 0 {
+				delete(p.keyspaces, ifp.keyspace)
+			}
+		}
+	}
+
+	if p.removing {
+		return
+	}
+	p.evictions++
+	if p.onEvicted != nil && ifp != nil {
+		p.onEvicted(ifp.hostID, ifp.keyspace, ifp.statement)
+	}
+}
+
+// addLocked adds the statement to the cache, p.mu must be held.
+func (p *preparedLRU) addLocked(key string, val *inflightPrepare) {
+	p.lru.Add(key, val)
+	if p.keyspaces == nil {
+		return
+	}
+
+	ks, ok := p.keyspaces[val.keyspace]
+	if !ok {
+		ks = lru.New(p.maxPerKeyspace)
+		ks.OnEvicted = func(key string, _ interface{}) {
+			p.lru.Remove(key)
+		}
+		p.keyspaces[val.keyspace] = ks
+	}
+	ks.Add(key, struct{}{})
 }
 
 func (p *preparedLRU) clear() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.removing = true
 	for p.lru.Len() > 0 {
 		p.lru.RemoveOldest()
 	}
+	p.removing = false
 }
 
 func (p *preparedLRU) add(key string, val *inflightPrepare) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.lru.Add(key, val)
+	p.addLocked(key, val)
 }
 
 func (p *preparedLRU) remove(key string) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	return p.removeLocked(key)
+}
+
+// removeStatements removes the statements for which fn returns true, on all
+// hosts and keyspaces, so that they are prepared again on their next use.
+func (p *preparedLRU) removeStatements(fn func(statement string) bool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removing = true
+	defer func() { p.removing = false }()
+	return p.lru.RemoveFunc(func(_ string, val interface{}) bool {
+		ifp, ok := val.(*inflightPrepare)
+		return ok && fn(ifp.statement)
+	})
+}
+
+// removeKeyspace removes the statements prepared in keyspace, on all hosts.
+func (p *preparedLRU) removeKeyspace(keyspace string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removing = true
+	defer func() { p.removing = false }()
+	return p.lru.RemoveFunc(func(_ string, val interface{}) bool {
+		ifp, ok := val.(*inflightPrepare)
+		return ok && ifp.keyspace == keyspace
+	})
+}
+
+// takeStatements removes the prepared statements for which fn returns true,
+// and returns them. Statements still being prepared are left in the cache.
+func (p *preparedLRU) takeStatements(fn func(ifp *inflightPrepare) bool) []*inflightPrepare {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removing = true
+	defer func() { p.removing = false }()
+
+	var taken []*inflightPrepare
+	p.lru.RemoveFunc(func(_ string, val interface{}) bool {
+		ifp, ok := val.(*inflightPrepare)
+		if !ok {
+			return false
+		}
+		select {
+		case <-ifp.done:
+		default:
+			return false
+		}
+		if ifp.err != nil || !fn(ifp) {
+			return false
+		}
+		taken = append(taken, ifp)
+		return true
+	})
+	return taken
+}
+
+// contains reports whether key is in the cache, prepared or being prepared.
+func (p *preparedLRU) contains(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.lru.Get(key)
+	return ok
+}
+
+func (p *preparedLRU) removeLocked(key string) bool {
+	p.removing = true
+	defer func() { p.removing = false }()
 	return p.lru.Remove(key)
 }
 
-func (p *preparedLRU) execIfMissing(key string, fn func(lru *lru.Cache) *inflightPrepare) (*inflightPrepare, bool) {
+// execIfMissing returns the cached statement for key, or adds the one returned by fn.
+func (p *preparedLRU) execIfMissing(key string, fn func() *inflightPrepare) (*inflightPrepare, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	val, ok := p.lru.Get(key)
 	if ok {
-		return val.(*inflightPrepare), true
+		p.hits++
+		ifp := val.(*inflightPrepare)
+		ifp.hits++
+		if p.keyspaces != nil {
+			if ks, ok := p.keyspaces[ifp.keyspace]; ok {
+				ks.Get(key)
+			}
+		}
+		return ifp, true
 	}
 
-	return fn(p.lru), false
+	p.misses++
+	ifp := fn()
+	p.addLocked(key, ifp)
+	return ifp, false
 }
 
+// keyFor returns the key of statement prepared on hostID in keyspace.
+// Keyspaces and host IDs can not contain NUL.
 func (p *preparedLRU) keyFor(hostID, keyspace, statement string) string {
-	// TODO: we should just use a struct for the key in the map
-	return hostID + keyspace + statement
+	return hostID + "\x00" + keyspace + "\x00" + statement
+}
+
+// PreparedStatementInfo describes a statement of the prepared statement cache.
+type PreparedStatementInfo struct {
+	HostID    string
+	Keyspace  string
+	Statement string
+	ID        []byte
+	// PreparedAt is when the statement was prepared on the host.
+	PreparedAt time.Time
+	// Hits is the number of times the cached statement was used.
+	Hits uint64
+}
+
+// statements returns the prepared statements of the cache, from the most to
+// the least recently used, leaving out statements being prepared.
+func (p *preparedLRU) statements() []PreparedStatementInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stmts := make([]PreparedStatementInfo, 0, p.lru.Len())
+	p.lru.Each(func(_ string, val interface{}) bool {
+		ifp, ok := val.(*inflightPrepare)
+		if !ok {
+			return true
+		}
+		select {
+		case <-ifp.done:
+		default:
+			return true
+		}
+		if ifp.err != nil || ifp.preparedStatment == nil {
+			return true
+		}
+		stmts = append(stmts, PreparedStatementInfo{
+			HostID:     ifp.hostID,
+			Keyspace:   ifp.keyspace,
+			Statement:  ifp.statement,
+			ID:         copyBytes(ifp.preparedStatment.id),
+			PreparedAt: ifp.preparedAt,
+			Hits:       ifp.hits,
+		})
+		return true
+	})
+	return stmts
+}
+
+func (p *preparedLRU) stats() PreparedCacheStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return PreparedCacheStats{
+		Hits:      p.hits,
+		Misses:    p.misses,
+		Evictions: p.evictions,
+		Size:      p.lru.Len(),
+	}
 }
 
 func (p *preparedLRU) evictPreparedID(key string, id []byte) {
@@ -69,7 +286,7 @@ func (p *preparedLRU) evictPreparedID(key string, id []byte) {
 	select {
 	case <-ifp.done:
 		if bytes.Equal(id, ifp.preparedStatment.id) {
-			p.lru.Remove(key)
+			p.removeLocked(key)
 		}
 	default:
 	}
@@ -0,0 +1,184 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPreparedLRUStats(t *testing.T) {
+	var evicted []string
+	p := newPreparedLRU(2, 0, func(hostID, keyspace, statement string) {
+		evicted = append(evicted, statement)
+	})
+
+	prepare := func(keyspace, stmt string) bool {
+		_, ok := p.execIfMissing(p.keyFor("host", keyspace, stmt), func() *inflightPrepare {
+			return &inflightPrepare{done: make(chan struct{}), hostID: "host", keyspace: keyspace, statement: stmt}
+		})
+		return ok
+	}
+
+	prepare("ks", "a")
+	prepare("ks", "b")
+	if !prepare("ks", "a") {
+		t.Fatal("expected statement a to be cached")
+	}
+	prepare("ks", "c")
+
+	if !p.remove(p.keyFor("host", "ks", "c")) {
+		t.Fatal("expected statement c to be removed")
+	}
+
+	stats := p.stats()
+	expected := PreparedCacheStats{Hits: 1, Misses: 3, Evictions: 1, Size: 1}
+	if stats != expected {
+		t.Fatalf("expected stats %+v, got %+v", expected, stats)
+	}
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("expected only statement b to be evicted, got %v", evicted)
+	}
+}
+
+func TestPreparedLRUPerKeyspace(t *testing.T) {
+	p := newPreparedLRU(10, 2, nil)
+
+	prepare := func(keyspace, stmt string) bool {
+		_, ok := p.execIfMissing(p.keyFor("host", keyspace, stmt), func() *inflightPrepare {
+			return &inflightPrepare{done: make(chan struct{}), hostID: "host", keyspace: keyspace, statement: stmt}
+		})
+		return ok
+	}
+
+	prepare("ks1", "a")
+	prepare("ks2", "a")
+	prepare("ks1", "b")
+	prepare("ks1", "a")
+	prepare("ks1", "c")
+
+	if !prepare("ks2", "a") {
+		t.Fatal("expected statement of other keyspace to stay cached")
+	}
+	if !prepare("ks1", "a") {
+		t.Fatal("expected recently used statement to stay cached")
+	}
+	if prepare("ks1", "b") {
+		t.Fatal("expected least recently used statement of the keyspace to be evicted")
+	}
+
+	if size := p.stats().Size; size != 3 {
+		t.Fatalf("expected 3 cached statements, got %d", size)
+	}
+
+	p.clear()
+	if stats := p.stats(); stats.Size != 0 || stats.Evictions != 2 {
+		t.Fatalf("expected empty cache with 2 evictions, got %+v", stats)
+	}
+	if len(p.keyspaces) != 0 {
+		t.Fatalf("expected keyspace partitions to be cleared, got %d", len(p.keyspaces))
+	}
+}
+
+func TestPreparedLRUTakeStatements(t *testing.T) {
+	p := newPreparedLRU(10, 0, nil)
+
+	prepare := func(stmt string, done bool) {
+		ifp := &inflightPrepare{done: make(chan struct{}), hostID: "host", keyspace: "ks", statement: stmt}
+		if done {
+			close(ifp.done)
+		}
+		p.add(p.keyFor("host", "ks", stmt), ifp)
+	}
+	prepare("SELECT * FROM users", true)
+	prepare("SELECT * FROM users_by_email", true)
+	prepare("INSERT INTO users (id) VALUES (?)", false)
+
+	taken := p.takeStatements(func(ifp *inflightPrepare) bool {
+		return statementReferencesTable(ifp.statement, ifp.keyspace, "ks", "users")
+	})
+	if len(taken) != 1 || taken[0].statement != "SELECT * FROM users" {
+		t.Fatalf("expected to take the prepared statement on users, got %v", taken)
+	}
+	if stats := p.stats(); stats.Size != 2 || stats.Evictions != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestStatementReferencesTable(t *testing.T) {
+	tests := []struct {
+		stmt, stmtKeyspace string
+		expected           bool
+	}{
+		{"SELECT * FROM users WHERE id = ?", "ks", true},
+		{"select * from Users", "ks", true},
+		{"SELECT * FROM ks.users", "other", true},
+		{`SELECT * FROM "ks"."users"`, "", true},
+		{"SELECT * FROM users", "other", false},
+		{"SELECT * FROM users_by_email", "ks", false},
+		{"SELECT * FROM ks.old_users", "ks", false},
+	}
+	for _, test := range tests {
+		if got := statementReferencesTable(test.stmt, test.stmtKeyspace, "ks", "users"); got != test.expected {
+			t.Errorf("%q in %q: expected %v, got %v", test.stmt, test.stmtKeyspace, test.expected, got)
+		}
+	}
+}
+
+func TestPreparedLRURemoveKeyspace(t *testing.T) {
+	p := newPreparedLRU(10, 2, nil)
+	for _, key := range [][2]string{{"ks1", "a"}, {"ks1", "b"}, {"ks2", "a"}} {
+		p.add(p.keyFor("host", key[0], key[1]), &inflightPrepare{done: make(chan struct{}), hostID: "host", keyspace: key[0], statement: key[1]})
+	}
+
+	if n := p.removeKeyspace("ks1"); n != 2 {
+		t.Fatalf("expected 2 statements removed, got %d", n)
+	}
+	if stats := p.stats(); stats.Size != 1 || stats.Evictions != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if _, ok := p.keyspaces["ks1"]; ok {
+		t.Fatal("expected keyspace ks1 to be untracked")
+	}
+}
+
+func TestPreparedLRUKeyFor(t *testing.T) {
+	p := newPreparedLRU(10, 0, nil)
+	if p.keyFor("host", "ks", "1SELECT") == p.keyFor("host", "ks1", "SELECT") {
+		t.Error("expected keys of different keyspaces not to collide")
+	}
+}
+
+func TestPreparedLRUStatements(t *testing.T) {
+	p := newPreparedLRU(10, 0, nil)
+	preparedAt := time.Now()
+	add := func(stmt string, done bool) {
+		ifp := &inflightPrepare{done: make(chan struct{}), hostID: "host", keyspace: "ks", statement: stmt}
+		if done {
+			ifp.preparedStatment = &preparedStatment{id: []byte(stmt)}
+			ifp.preparedAt = preparedAt
+			close(ifp.done)
+		}
+		p.add(p.keyFor("host", "ks", stmt), ifp)
+	}
+	add("a", true)
+	add("b", true)
+	add("c", false)
+	for i := 0; i < 2; i++ {
+		p.execIfMissing(p.keyFor("host", "ks", "a"), nil)
+	}
+
+	stmts := p.statements()
+	if len(stmts) != 2 {
+		t.Fatalf("expected the 2 prepared statements got %+v", stmts)
+	}
+	expected := PreparedStatementInfo{HostID: "host", Keyspace: "ks", Statement: "a", ID: []byte("a"), PreparedAt: preparedAt, Hits: 2}
+	if !reflect.DeepEqual(stmts[0], expected) {
+		t.Errorf("expected %+v got %+v", expected, stmts[0])
+	}
+	if stmts[1].Statement != "b" || stmts[1].Hits != 0 {
+		t.Errorf("unexpected statement %+v", stmts[1])
+	}
+}
@@ -0,0 +1,107 @@
+package gocql
+
+import (
+	"context"
+	"time"
+)
+
+const defaultProbeStatement = "SELECT release_version FROM system.local"
+
+// ProbeOptions configures Session.Probe.
+type ProbeOptions struct {
+	// Statement is the probe query.
+	// (default: SELECT release_version FROM system.local)
+	Statement string
+	// Timeout limits the duration of the probe, along with the deadline of
+	// its context.
+	// (default: 1 second)
+	Timeout time.Duration
+	// Consistency is the consistency of the probe query.
+	// (default: LocalOne)
+	Consistency Consistency
+}
+
+// ProbeResult is the result of Session.Probe, which marshals to JSON for
+// health endpoints.
+type ProbeResult struct {
+	Healthy bool `json:"healthy"`
+	// Host and DataCenter are the address and data center of the host which
+	// answered the probe, or of the last host probed if none did.
+	Host       string `json:"host,omitempty"`
+	DataCenter string `json:"data_center,omitempty"`
+	// Latency is the duration of the probe.
+	Latency time.Duration `json:"latency"`
+	// Err is the error of the probe if it failed, and Error its message.
+	Err   error  `json:"-"`
+	Error string `json:"error,omitempty"`
+}
+
+// Probe runs a probe query against the hosts of the local data center, as
+// reported by the host selection policy, until one of them answers, to check
+// the readiness or liveness of the session for HTTP health endpoints.
+//
+// The probe is executed on the connections of the hosts, without retries nor
+// speculative executions, and is not reported to the observers and
+// statistics of the session nor counted against the hosts.
+func (s *Session) Probe(ctx context.Context, opts ProbeOptions) ProbeResult {
+	if opts.Statement == "" {
+		opts.Statement = defaultProbeStatement
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.Consistency == Any {
+		opts.Consistency = LocalOne
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	result := ProbeResult{Err: ErrNoConnections}
+	if s.Closed() {
+		result.Err = ErrSessionClosed
+	}
+	for _, host := range s.ring.allHosts() {
+		if s.Closed() || ctx.Err() != nil {
+			break
+		}
+		if !host.IsUp() || !s.policy.IsLocal(host) {
+			continue
+		}
+		pool, ok := s.pool.getPool(host)
+		if !ok {
+			continue
+		}
+		conn := pool.Pick()
+		if conn == nil {
+			continue
+		}
+
+		result.Host = host.ConnectAddressAndPort()
+		result.DataCenter = host.DataCenter()
+		result.Err = s.probe(ctx, conn, opts)
+		if result.Err == nil {
+			break
+		}
+	}
+	result.Latency = time.Since(start)
+	if result.Err == ErrNoConnections && ctx.Err() != nil {
+		// no host was probed in time
+		result.Err = ctx.Err()
+	}
+
+	result.Healthy = result.Err == nil
+	if result.Err != nil {
+		result.Error = result.Err.Error()
+	}
+	return result
+}
+
+func (s *Session) probe(ctx context.Context, conn *Conn, opts ProbeOptions) error {
+	qry := s.Query(opts.Statement).Consistency(opts.Consistency).Idempotent(true)
+	defer qry.Release()
+	qry.observer = nil
+	qry.context = ctx
+	return conn.executeQuery(ctx, qry).Close()
+}
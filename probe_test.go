@@ -0,0 +1,49 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	result := db.Probe(context.Background(), ProbeOptions{})
+	if !result.Healthy || result.Err != nil || result.Host != srv.Address || result.Latency <= 0 {
+		t.Errorf("expected a healthy probe, got %+v", result)
+	}
+
+	result = db.Probe(context.Background(), ProbeOptions{Statement: "kill"})
+	if result.Healthy || !errors.Is(result.Err, ErrOverloadedCategory) {
+		t.Errorf("expected an overloaded error, got %+v", result)
+	}
+	if stats := db.ErrorStats(); stats != (ErrorStats{}) {
+		t.Errorf("probe counted in the statistics of the session: %+v", stats)
+	}
+
+	result = db.Probe(context.Background(), ProbeOptions{Statement: "timeout", Timeout: 20 * time.Millisecond})
+	if result.Healthy || !errors.Is(result.Err, context.DeadlineExceeded) {
+		t.Errorf("expected a timeout, got %+v", result)
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"healthy":false`) || !strings.Contains(string(body), `"error":"context deadline exceeded"`) {
+		t.Errorf("unexpected JSON %s", body)
+	}
+}
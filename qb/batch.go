@@ -0,0 +1,73 @@
+package qb
+
+import "strings"
+
+type batchEntry struct {
+	prefix string
+	stmt   Builder
+}
+
+// BatchBuilder builds a BATCH statement.
+type BatchBuilder struct {
+	typ     string
+	entries []batchEntry
+}
+
+// Batch returns a builder of a logged BATCH statement.
+func Batch() *BatchBuilder {
+	return &BatchBuilder{}
+}
+
+// Unlogged makes the batch unlogged.
+func (b *BatchBuilder) Unlogged() *BatchBuilder {
+	b.typ = "UNLOGGED "
+	return b
+}
+
+// Counter makes the batch a counter batch.
+func (b *BatchBuilder) Counter() *BatchBuilder {
+	b.typ = "COUNTER "
+	return b
+}
+
+// Add adds a statement to the batch, with the names of its markers
+// unchanged.
+func (b *BatchBuilder) Add(stmt Builder) *BatchBuilder {
+	return b.AddWithPrefix("", stmt)
+}
+
+// AddWithPrefix adds a statement to the batch, with the names of its markers
+// prefixed, so that statements of the batch bound to different values do not
+// share markers.
+func (b *BatchBuilder) AddWithPrefix(prefix string, stmt Builder) *BatchBuilder {
+	b.entries = append(b.entries, batchEntry{prefix: prefix, stmt: stmt})
+	return b
+}
+
+func (b *BatchBuilder) ToCql() (string, []string) {
+	return b.cql("")
+}
+
+func (b *BatchBuilder) cql(prefix string) (string, []string) {
+	var (
+		cql   strings.Builder
+		names []string
+	)
+	cql.WriteString("BEGIN " + b.typ + "BATCH ")
+	for _, entry := range b.entries {
+		stmt, entryNames := entry.stmt.cql(prefix + entry.prefix)
+		cql.WriteString(stmt)
+		cql.WriteString("; ")
+		names = append(names, entryNames...)
+	}
+	cql.WriteString("APPLY BATCH")
+	return cql.String(), names
+}
+
+func (b *BatchBuilder) references() []reference {
+	var refs []reference
+	for _, entry := range b.entries {
+		refs = append(refs, entry.stmt.references()...)
+	}
+	return refs
+}
@@ -0,0 +1,94 @@
+package qb
+
+import "strings"
+
+// Cmp is a comparison of a column with a bind marker, in WHERE and IF
+// clauses.
+type Cmp struct {
+	column string
+	op     string
+	name   string
+}
+
+// Named returns the comparison with the marker named name instead of the
+// name of the column.
+func (c Cmp) Named(name string) Cmp {
+	c.name = name
+	return c
+}
+
+func (c Cmp) cql(prefix string) (string, string) {
+	name := markerName(prefix, c.column)
+	if c.name != "" {
+		name = prefix + c.name
+	}
+	return c.column + " " + c.op + " :" + name, name
+}
+
+// Eq compares a column for equality with a marker.
+func Eq(column string) Cmp {
+	return Cmp{column: column, op: "="}
+}
+
+// Ne compares a column for inequality with a marker, only in IF clauses.
+func Ne(column string) Cmp {
+	return Cmp{column: column, op: "!="}
+}
+
+// Lt compares a column with a marker for lesser values.
+func Lt(column string) Cmp {
+	return Cmp{column: column, op: "<"}
+}
+
+// LtOrEq compares a column with a marker for lesser or equal values.
+func LtOrEq(column string) Cmp {
+	return Cmp{column: column, op: "<="}
+}
+
+// Gt compares a column with a marker for greater values.
+func Gt(column string) Cmp {
+	return Cmp{column: column, op: ">"}
+}
+
+// GtOrEq compares a column with a marker for greater or equal values.
+func GtOrEq(column string) Cmp {
+	return Cmp{column: column, op: ">="}
+}
+
+// In compares a column with the values of a marker bound to a slice.
+func In(column string) Cmp {
+	return Cmp{column: column, op: "IN"}
+}
+
+// Contains compares a collection column with a marker for an element.
+func Contains(column string) Cmp {
+	return Cmp{column: column, op: "CONTAINS"}
+}
+
+// ContainsKey compares a map column with a marker for a key.
+func ContainsKey(column string) Cmp {
+	return Cmp{column: column, op: "CONTAINS KEY"}
+}
+
+// writeCmps writes the comparisons of a clause, if any, joined by AND.
+func writeCmps(b *strings.Builder, clause, prefix string, cmps []Cmp, names []string) []string {
+	for i, cmp := range cmps {
+		if i == 0 {
+			b.WriteString(clause)
+		} else {
+			b.WriteString(" AND ")
+		}
+		stmt, name := cmp.cql(prefix)
+		b.WriteString(stmt)
+		names = append(names, name)
+	}
+	return names
+}
+
+func cmpRefs(table string, cmps []Cmp) []reference {
+	refs := make([]reference, len(cmps))
+	for i, cmp := range cmps {
+		refs[i] = reference{table: table, column: cmp.column}
+	}
+	return refs
+}
@@ -0,0 +1,87 @@
+package qb
+
+import (
+	"strings"
+	"time"
+)
+
+// DeleteBuilder builds a DELETE statement.
+type DeleteBuilder struct {
+	table    string
+	columns  []string
+	where    []Cmp
+	ifs      []Cmp
+	existing bool
+	using    using
+}
+
+// Delete returns a builder of a DELETE statement of the table, deleting
+// whole rows unless Columns is called.
+func Delete(table string) *DeleteBuilder {
+	return &DeleteBuilder{table: table}
+}
+
+// Columns adds columns to delete.
+func (b *DeleteBuilder) Columns(columns ...string) *DeleteBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// Where adds comparisons to the WHERE clause.
+func (b *DeleteBuilder) Where(cmps ...Cmp) *DeleteBuilder {
+	b.where = append(b.where, cmps...)
+	return b
+}
+
+// If adds conditions to the IF clause of a lightweight transaction.
+func (b *DeleteBuilder) If(cmps ...Cmp) *DeleteBuilder {
+	b.ifs = append(b.ifs, cmps...)
+	return b
+}
+
+// Existing deletes the row only if it exists, with IF EXISTS.
+func (b *DeleteBuilder) Existing() *DeleteBuilder {
+	b.existing = true
+	return b
+}
+
+// Timestamp sets the time of the deletion.
+func (b *DeleteBuilder) Timestamp(ts time.Time) *DeleteBuilder {
+	b.using.setTimestamp(ts)
+	return b
+}
+
+// TimestampNamed sets the time of the deletion, in microseconds, to the
+// value of a marker.
+func (b *DeleteBuilder) TimestampNamed(name string) *DeleteBuilder {
+	b.using.setTimestampNamed(name)
+	return b
+}
+
+func (b *DeleteBuilder) ToCql() (string, []string) {
+	return b.cql("")
+}
+
+func (b *DeleteBuilder) cql(prefix string) (string, []string) {
+	var cql strings.Builder
+	cql.WriteString("DELETE ")
+	if len(b.columns) > 0 {
+		cql.WriteString(strings.Join(b.columns, ","))
+		cql.WriteString(" ")
+	}
+	cql.WriteString("FROM ")
+	cql.WriteString(b.table)
+	names := b.using.write(&cql, prefix, nil)
+	names = writeCmps(&cql, " WHERE ", prefix, b.where, names)
+	names = writeCmps(&cql, " IF ", prefix, b.ifs, names)
+	if b.existing && len(b.ifs) == 0 {
+		cql.WriteString(" IF EXISTS")
+	}
+	return cql.String(), names
+}
+
+func (b *DeleteBuilder) references() []reference {
+	refs := append([]reference{{table: b.table}}, columnRefs(b.table, b.columns)...)
+	refs = append(refs, cmpRefs(b.table, b.where)...)
+	return append(refs, cmpRefs(b.table, b.ifs)...)
+}
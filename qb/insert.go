@@ -0,0 +1,88 @@
+package qb
+
+import (
+	"strings"
+	"time"
+)
+
+// InsertBuilder builds an INSERT statement.
+type InsertBuilder struct {
+	table   string
+	columns []string
+	unique  bool
+	using   using
+}
+
+// Insert returns a builder of an INSERT statement into the table.
+func Insert(table string) *InsertBuilder {
+	return &InsertBuilder{table: table}
+}
+
+// Columns adds columns set to the markers named after them.
+func (b *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// Unique inserts the row only if it does not exist, with IF NOT EXISTS.
+func (b *InsertBuilder) Unique() *InsertBuilder {
+	b.unique = true
+	return b
+}
+
+// TTL sets the time to live of the inserted values, truncated to seconds.
+func (b *InsertBuilder) TTL(ttl time.Duration) *InsertBuilder {
+	b.using.setTTL(ttl)
+	return b
+}
+
+// TTLNamed sets the time to live of the inserted values, in seconds, to the
+// value of a marker.
+func (b *InsertBuilder) TTLNamed(name string) *InsertBuilder {
+	b.using.setTTLNamed(name)
+	return b
+}
+
+// Timestamp sets the write time of the inserted values.
+func (b *InsertBuilder) Timestamp(ts time.Time) *InsertBuilder {
+	b.using.setTimestamp(ts)
+	return b
+}
+
+// TimestampNamed sets the write time of the inserted values, in
+// microseconds, to the value of a marker.
+func (b *InsertBuilder) TimestampNamed(name string) *InsertBuilder {
+	b.using.setTimestampNamed(name)
+	return b
+}
+
+func (b *InsertBuilder) ToCql() (string, []string) {
+	return b.cql("")
+}
+
+func (b *InsertBuilder) cql(prefix string) (string, []string) {
+	var cql strings.Builder
+	cql.WriteString("INSERT INTO ")
+	cql.WriteString(b.table)
+	cql.WriteString(" (")
+	cql.WriteString(strings.Join(b.columns, ","))
+	cql.WriteString(") VALUES (")
+	names := make([]string, len(b.columns))
+	for i, column := range b.columns {
+		if i > 0 {
+			cql.WriteString(",")
+		}
+		names[i] = markerName(prefix, column)
+		cql.WriteString(":" + names[i])
+	}
+	cql.WriteString(")")
+	if b.unique {
+		cql.WriteString(" IF NOT EXISTS")
+	}
+	names = b.using.write(&cql, prefix, names)
+	return cql.String(), names
+}
+
+func (b *InsertBuilder) references() []reference {
+	return append([]reference{{table: b.table}}, columnRefs(b.table, b.columns)...)
+}
@@ -0,0 +1,157 @@
+// Package qb builds CQL statements with named bind markers, to be bound with
+// gocql.Query.BindMap, instead of concatenating strings.
+//
+// The columns referenced by a statement are checked against the schema of
+// the table by Validate, and by Query when the table name is qualified by its
+// keyspace and the session can fetch its metadata.
+//
+// Example:
+//
+//	stmt := qb.Select("ks.users").Columns("id", "name").Where(qb.Eq("id"))
+//	q, err := qb.Query(session, stmt)
+//	if err != nil {
+//		// handle error
+//	}
+//	err = q.BindMap(map[string]interface{}{"id": id}).Scan(&id, &name)
+//
+// Markers are named after their columns, Cmp.Named renames them, for
+// instance to bound a column from both sides:
+//
+//	qb.Select("ks.events").Where(qb.Eq("id"), qb.Gt("ts").Named("from"), qb.Lt("ts").Named("to"))
+package qb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+// Builder is a statement builder of this package.
+type Builder interface {
+	// ToCql returns the statement and the names of its bind markers, in the
+	// order they appear in the statement.
+	ToCql() (stmt string, names []string)
+
+	// cql renders the statement with the names of its markers prefixed.
+	cql(prefix string) (stmt string, names []string)
+	// references returns the columns referenced by the statement.
+	references() []reference
+}
+
+type reference struct {
+	table  string
+	column string
+}
+
+// Query validates the statement against the metadata of its tables when
+// their names are qualified by their keyspace, and returns a query of the
+// session executing it.
+func Query(session *gocql.Session, b Builder) (*gocql.Query, error) {
+	var tables []*gocql.TableMetadata
+	seen := make(map[string]bool)
+	for _, ref := range b.references() {
+		if seen[ref.table] {
+			continue
+		}
+		seen[ref.table] = true
+		if table := tableMetadata(session, ref.table); table != nil {
+			tables = append(tables, table)
+		}
+	}
+	if err := validate(b, tables, false); err != nil {
+		return nil, err
+	}
+	stmt, _ := b.ToCql()
+	return session.Query(stmt), nil
+}
+
+func tableMetadata(session *gocql.Session, name string) *gocql.TableMetadata {
+	i := strings.IndexByte(name, '.')
+	if i < 0 {
+		return nil
+	}
+	keyspace, err := session.KeyspaceMetadata(unquote(name[:i]))
+	if err != nil {
+		return nil
+	}
+	return keyspace.Tables[unquote(name[i+1:])]
+}
+
+// Validate checks that the columns referenced by the statement exist in the
+// tables.
+func Validate(b Builder, tables ...*gocql.TableMetadata) error {
+	return validate(b, tables, true)
+}
+
+// validate checks the columns of the tables of the statement, those of the
+// tables with no metadata are only checked if strict.
+func validate(b Builder, tables []*gocql.TableMetadata, strict bool) error {
+	for _, ref := range b.references() {
+		table := findTable(tables, ref.table)
+		if table == nil {
+			if strict {
+				return fmt.Errorf("qb: no metadata for table %s", ref.table)
+			}
+			continue
+		}
+		if ref.column == "" || !isIdentifier(ref.column) {
+			continue
+		}
+		if _, ok := table.Columns[unquote(ref.column)]; !ok {
+			return fmt.Errorf("qb: unknown column %s in table %s.%s", ref.column, table.Keyspace, table.Name)
+		}
+	}
+	return nil
+}
+
+func findTable(tables []*gocql.TableMetadata, name string) *gocql.TableMetadata {
+	keyspace := ""
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		keyspace, name = unquote(name[:i]), name[i+1:]
+	}
+	name = unquote(name)
+	for _, table := range tables {
+		if table.Name == name && (keyspace == "" || table.Keyspace == keyspace) {
+			return table
+		}
+	}
+	return nil
+}
+
+// isIdentifier reports whether the column is a plain or quoted column name,
+// rather than an expression such as a function call.
+func isIdentifier(column string) bool {
+	if len(column) > 1 && column[0] == '"' && column[len(column)-1] == '"' {
+		return true
+	}
+	for i, r := range column {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return column != ""
+}
+
+// unquote returns the name of a quoted identifier, or the lower case name of
+// an unquoted one.
+func unquote(name string) string {
+	if len(name) > 1 && name[0] == '"' && name[len(name)-1] == '"' {
+		return strings.Replace(name[1:len(name)-1], `""`, `"`, -1)
+	}
+	return strings.ToLower(name)
+}
+
+// markerName returns the name of the marker of an identifier, which must not
+// be quoted.
+func markerName(prefix, name string) string {
+	return prefix + strings.ToLower(strings.Trim(name, `"`))
+}
+
+func columnRefs(table string, columns []string) []reference {
+	refs := make([]reference, len(columns))
+	for i, column := range columns {
+		refs[i] = reference{table: table, column: column}
+	}
+	return refs
+}
@@ -0,0 +1,112 @@
+package qb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+func TestToCql(t *testing.T) {
+	ts := time.Unix(1, 0)
+	tests := []struct {
+		b     Builder
+		stmt  string
+		names []string
+	}{
+		{
+			Select("ks.users"),
+			"SELECT * FROM ks.users",
+			nil,
+		},
+		{
+			Select("ks.events").Columns("id", "writetime(v)").Where(Eq("id"), Gt("ts").Named("from"), LtOrEq("ts").Named("to")).
+				OrderBy("ts", DESC).LimitNamed("n").AllowFiltering(),
+			"SELECT id,writetime(v) FROM ks.events WHERE id = :id AND ts > :from AND ts <= :to ORDER BY ts DESC LIMIT :n ALLOW FILTERING",
+			[]string{"id", "from", "to", "n"},
+		},
+		{
+			Select("users").Distinct().Columns("id").Where(In("id")).Limit(10),
+			"SELECT DISTINCT id FROM users WHERE id IN :id LIMIT 10",
+			[]string{"id"},
+		},
+		{
+			Insert("ks.users").Columns("id", "name").Unique().TTL(time.Minute).Timestamp(ts),
+			"INSERT INTO ks.users (id,name) VALUES (:id,:name) IF NOT EXISTS USING TTL 60 AND TIMESTAMP 1000000",
+			[]string{"id", "name"},
+		},
+		{
+			Update("ks.users").TTLNamed("ttl").Set("name").Add("tags").Remove("roles").Where(Eq("id")).If(Ne("name").Named("old")),
+			"UPDATE ks.users USING TTL :ttl SET name = :name,tags = tags + :tags,roles = roles - :roles WHERE id = :id IF name != :old",
+			[]string{"ttl", "name", "tags", "roles", "id", "old"},
+		},
+		{
+			Update("ks.users").Set("name").Where(Eq("id")).Existing(),
+			"UPDATE ks.users SET name = :name WHERE id = :id IF EXISTS",
+			[]string{"name", "id"},
+		},
+		{
+			Delete("ks.users").Columns("name").TimestampNamed("ts").Where(Eq("id"), Contains("tags")),
+			"DELETE name FROM ks.users USING TIMESTAMP :ts WHERE id = :id AND tags CONTAINS :tags",
+			[]string{"ts", "id", "tags"},
+		},
+		{
+			Delete("ks.users").Where(Eq("id")).Existing(),
+			"DELETE FROM ks.users WHERE id = :id IF EXISTS",
+			[]string{"id"},
+		},
+		{
+			Batch().Unlogged().AddWithPrefix("a_", Insert("ks.users").Columns("id")).AddWithPrefix("b_", Delete("ks.users").Where(Eq("id"))),
+			"BEGIN UNLOGGED BATCH INSERT INTO ks.users (id) VALUES (:a_id); DELETE FROM ks.users WHERE id = :b_id; APPLY BATCH",
+			[]string{"a_id", "b_id"},
+		},
+	}
+	for _, test := range tests {
+		stmt, names := test.b.ToCql()
+		if stmt != test.stmt {
+			t.Errorf("expected %q got %q", test.stmt, stmt)
+		}
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("%s: expected markers %v got %v", stmt, test.names, names)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	users := &gocql.TableMetadata{
+		Keyspace: "ks",
+		Name:     "users",
+		Columns: map[string]*gocql.ColumnMetadata{
+			"id":   {Name: "id"},
+			"name": {Name: "name"},
+			"Tags": {Name: "Tags"},
+		},
+	}
+
+	valid := []Builder{
+		Select("ks.users").Columns("id", "name", `"Tags"`, "writetime(name)").Where(Eq("id")),
+		Insert("users").Columns("id", "NAME"),
+		Batch().Add(Update("ks.users").Set("name").Where(Eq("id"))),
+	}
+	for _, b := range valid {
+		if err := Validate(b, users); err != nil {
+			t.Errorf("%v: %v", b, err)
+		}
+	}
+
+	invalid := []Builder{
+		Select("ks.users").Columns("missing"),
+		Select("ks.users").Columns("tags"),
+		Update("ks.users").Set("name").Where(Eq("missing")),
+		Delete("ks.users").Where(Eq("id")).If(Eq("missing")),
+		Select("ks.other"),
+		Select("other.users"),
+	}
+	for _, b := range invalid {
+		if err := Validate(b, users); err == nil {
+			stmt, _ := b.ToCql()
+			t.Errorf("%s: expected an error", stmt)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package qb
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Order is the order of the rows of a SELECT statement by a clustering column.
+type Order bool
+
+// Orders of the rows.
+const (
+	ASC  Order = false
+	DESC Order = true
+)
+
+func (o Order) String() string {
+	if o == DESC {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+type ordering struct {
+	column string
+	order  Order
+}
+
+// SelectBuilder builds a SELECT statement.
+type SelectBuilder struct {
+	table          string
+	columns        []string
+	distinct       bool
+	where          []Cmp
+	orderBy        []ordering
+	limit          uint
+	limitName      string
+	allowFiltering bool
+}
+
+// Select returns a builder of a SELECT statement of the table, selecting all
+// the columns unless Columns is called.
+func Select(table string) *SelectBuilder {
+	return &SelectBuilder{table: table}
+}
+
+// Columns adds columns, or expressions such as "writetime(v)", to the
+// selection.
+func (b *SelectBuilder) Columns(columns ...string) *SelectBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// Distinct selects distinct partitions.
+func (b *SelectBuilder) Distinct() *SelectBuilder {
+	b.distinct = true
+	return b
+}
+
+// Where adds comparisons to the WHERE clause.
+func (b *SelectBuilder) Where(cmps ...Cmp) *SelectBuilder {
+	b.where = append(b.where, cmps...)
+	return b
+}
+
+// OrderBy orders the rows by a clustering column.
+func (b *SelectBuilder) OrderBy(column string, order Order) *SelectBuilder {
+	b.orderBy = append(b.orderBy, ordering{column: column, order: order})
+	return b
+}
+
+// Limit limits the number of rows returned.
+func (b *SelectBuilder) Limit(limit uint) *SelectBuilder {
+	b.limit = limit
+	b.limitName = ""
+	return b
+}
+
+// LimitNamed limits the number of rows returned by the value of a marker.
+func (b *SelectBuilder) LimitNamed(name string) *SelectBuilder {
+	b.limit = 0
+	b.limitName = name
+	return b
+}
+
+// AllowFiltering allows the filtering of the rows by the node.
+func (b *SelectBuilder) AllowFiltering() *SelectBuilder {
+	b.allowFiltering = true
+	return b
+}
+
+func (b *SelectBuilder) ToCql() (string, []string) {
+	return b.cql("")
+}
+
+func (b *SelectBuilder) cql(prefix string) (string, []string) {
+	var cql strings.Builder
+	cql.WriteString("SELECT ")
+	if b.distinct {
+		cql.WriteString("DISTINCT ")
+	}
+	if len(b.columns) == 0 {
+		cql.WriteString("*")
+	} else {
+		cql.WriteString(strings.Join(b.columns, ","))
+	}
+	cql.WriteString(" FROM ")
+	cql.WriteString(b.table)
+
+	names := writeCmps(&cql, " WHERE ", prefix, b.where, nil)
+	for i, o := range b.orderBy {
+		if i == 0 {
+			cql.WriteString(" ORDER BY ")
+		} else {
+			cql.WriteString(",")
+		}
+		cql.WriteString(o.column + " " + o.order.String())
+	}
+	if b.limitName != "" {
+		cql.WriteString(" LIMIT :" + prefix + b.limitName)
+		names = append(names, prefix+b.limitName)
+	} else if b.limit > 0 {
+		cql.WriteString(" LIMIT " + strconv.FormatUint(uint64(b.limit), 10))
+	}
+	if b.allowFiltering {
+		cql.WriteString(" ALLOW FILTERING")
+	}
+	return cql.String(), names
+}
+
+func (b *SelectBuilder) references() []reference {
+	refs := []reference{{table: b.table}}
+	refs = append(refs, columnRefs(b.table, b.columns)...)
+	refs = append(refs, cmpRefs(b.table, b.where)...)
+	for _, o := range b.orderBy {
+		refs = append(refs, reference{table: b.table, column: o.column})
+	}
+	return refs
+}
@@ -0,0 +1,132 @@
+package qb
+
+import (
+	"strings"
+	"time"
+)
+
+type assignment struct {
+	column string
+	op     string
+}
+
+// UpdateBuilder builds an UPDATE statement.
+type UpdateBuilder struct {
+	table    string
+	set      []assignment
+	where    []Cmp
+	ifs      []Cmp
+	existing bool
+	using    using
+}
+
+// Update returns a builder of an UPDATE statement of the table.
+func Update(table string) *UpdateBuilder {
+	return &UpdateBuilder{table: table}
+}
+
+// Set sets columns to the markers named after them.
+func (b *UpdateBuilder) Set(columns ...string) *UpdateBuilder {
+	for _, column := range columns {
+		b.set = append(b.set, assignment{column: column})
+	}
+	return b
+}
+
+// Add adds the value of the marker named after the column to a counter or
+// collection column.
+func (b *UpdateBuilder) Add(column string) *UpdateBuilder {
+	b.set = append(b.set, assignment{column: column, op: "+"})
+	return b
+}
+
+// Remove removes the value of the marker named after the column from a
+// counter or collection column.
+func (b *UpdateBuilder) Remove(column string) *UpdateBuilder {
+	b.set = append(b.set, assignment{column: column, op: "-"})
+	return b
+}
+
+// Where adds comparisons to the WHERE clause.
+func (b *UpdateBuilder) Where(cmps ...Cmp) *UpdateBuilder {
+	b.where = append(b.where, cmps...)
+	return b
+}
+
+// If adds conditions to the IF clause of a lightweight transaction.
+func (b *UpdateBuilder) If(cmps ...Cmp) *UpdateBuilder {
+	b.ifs = append(b.ifs, cmps...)
+	return b
+}
+
+// Existing updates the row only if it exists, with IF EXISTS.
+func (b *UpdateBuilder) Existing() *UpdateBuilder {
+	b.existing = true
+	return b
+}
+
+// TTL sets the time to live of the updated values, truncated to seconds.
+func (b *UpdateBuilder) TTL(ttl time.Duration) *UpdateBuilder {
+	b.using.setTTL(ttl)
+	return b
+}
+
+// TTLNamed sets the time to live of the updated values, in seconds, to the
+// value of a marker.
+func (b *UpdateBuilder) TTLNamed(name string) *UpdateBuilder {
+	b.using.setTTLNamed(name)
+	return b
+}
+
+// Timestamp sets the write time of the updated values.
+func (b *UpdateBuilder) Timestamp(ts time.Time) *UpdateBuilder {
+	b.using.setTimestamp(ts)
+	return b
+}
+
+// TimestampNamed sets the write time of the updated values, in
+// microseconds, to the value of a marker.
+func (b *UpdateBuilder) TimestampNamed(name string) *UpdateBuilder {
+	b.using.setTimestampNamed(name)
+	return b
+}
+
+func (b *UpdateBuilder) ToCql() (string, []string) {
+	return b.cql("")
+}
+
+func (b *UpdateBuilder) cql(prefix string) (string, []string) {
+	var cql strings.Builder
+	cql.WriteString("UPDATE ")
+	cql.WriteString(b.table)
+	names := b.using.write(&cql, prefix, nil)
+	for i, a := range b.set {
+		if i == 0 {
+			cql.WriteString(" SET ")
+		} else {
+			cql.WriteString(",")
+		}
+		name := markerName(prefix, a.column)
+		if a.op == "" {
+			cql.WriteString(a.column + " = :" + name)
+		} else {
+			cql.WriteString(a.column + " = " + a.column + " " + a.op + " :" + name)
+		}
+		names = append(names, name)
+	}
+	names = writeCmps(&cql, " WHERE ", prefix, b.where, names)
+	names = writeCmps(&cql, " IF ", prefix, b.ifs, names)
+	if b.existing && len(b.ifs) == 0 {
+		cql.WriteString(" IF EXISTS")
+	}
+	return cql.String(), names
+}
+
+func (b *UpdateBuilder) references() []reference {
+	refs := []reference{{table: b.table}}
+	for _, a := range b.set {
+		refs = append(refs, reference{table: b.table, column: a.column})
+	}
+	refs = append(refs, cmpRefs(b.table, b.where)...)
+	return append(refs, cmpRefs(b.table, b.ifs)...)
+}
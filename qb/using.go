@@ -0,0 +1,56 @@
+package qb
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// using is the USING clause of INSERT, UPDATE and DELETE statements.
+type using struct {
+	ttl           time.Duration
+	ttlName       string
+	timestamp     time.Time
+	timestampName string
+}
+
+func (u *using) setTTL(ttl time.Duration) {
+	u.ttl = ttl
+	u.ttlName = ""
+}
+
+func (u *using) setTTLNamed(name string) {
+	u.ttl = 0
+	u.ttlName = name
+}
+
+func (u *using) setTimestamp(ts time.Time) {
+	u.timestamp = ts
+	u.timestampName = ""
+}
+
+func (u *using) setTimestampNamed(name string) {
+	u.timestamp = time.Time{}
+	u.timestampName = name
+}
+
+func (u *using) write(b *strings.Builder, prefix string, names []string) []string {
+	var options []string
+	if u.ttlName != "" {
+		options = append(options, "TTL :"+prefix+u.ttlName)
+		names = append(names, prefix+u.ttlName)
+	} else if u.ttl > 0 {
+		options = append(options, "TTL "+strconv.FormatInt(int64(u.ttl/time.Second), 10))
+	}
+	if u.timestampName != "" {
+		options = append(options, "TIMESTAMP :"+prefix+u.timestampName)
+		names = append(names, prefix+u.timestampName)
+	} else if !u.timestamp.IsZero() {
+		options = append(options, "TIMESTAMP "+strconv.FormatInt(u.timestamp.UnixNano()/1000, 10))
+	}
+	if len(options) > 0 {
+		b.WriteString(" USING ")
+		b.WriteString(strings.Join(options, " AND "))
+	}
+	return names
+}
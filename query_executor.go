@@ -2,6 +2,7 @@ package gocql
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 )
@@ -10,8 +11,10 @@ type ExecutableQuery interface {
 	borrowForExecution()    // Used to ensure that the query stays alive for lifetime of a particular execution goroutine.
 	releaseAfterExecution() // Used when a goroutine finishes its execution attempts, either with ok result or an error.
 	execute(ctx context.Context, conn *Conn) *Iter
-	attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo)
+	attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo, shard int)
 	retryPolicy() RetryPolicy
+	retryLimit() (int, bool)
+	serialConsistency() SerialConsistency
 	speculativeExecutionPolicy() SpeculativeExecutionPolicy
 	GetRoutingKey() ([]byte, error)
 	Keyspace() string
@@ -28,26 +31,31 @@ type queryExecutor struct {
 	policy HostSelectionPolicy
 }
 
-func (q *queryExecutor) attemptQuery(ctx context.Context, qry ExecutableQuery, conn *Conn) *Iter {
-	start := time.Now()
-	iter := qry.execute(ctx, conn)
-	end := time.Now()
-
-	qry.attempt(q.pool.keyspace, end, start, iter, conn.host)
+// queryExecution records the attempts of an execution of a query, made by
+// its main and speculative executions.
+type queryExecution struct {
+	mu       sync.Mutex
+	attempts []ObservedAttempt
+}
 
-	return iter
+// record records attempt and returns the attempts recorded so far.
+func (e *queryExecution) record(attempt ObservedAttempt) []ObservedAttempt {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.attempts = append(e.attempts, attempt)
+	return append([]ObservedAttempt(nil), e.attempts...)
 }
 
 func (q *queryExecutor) speculate(ctx context.Context, qry ExecutableQuery, sp SpeculativeExecutionPolicy,
-	hostIter NextHost, results chan *Iter) *Iter {
-	ticker := time.NewTicker(sp.Delay())
+	hostIter NextHost, exec *queryExecution, results chan *Iter) *Iter {
+	ticker := q.pool.session.cfg.clock().NewTicker(sp.Delay())
 	defer ticker.Stop()
 
 	for i := 0; i < sp.Attempts(); i++ {
 		select {
-		case <-ticker.C:
+		case <-ticker.C():
 			qry.borrowForExecution() // ensure liveness in case of executing Query to prevent races with Query.Release().
-			go q.run(ctx, qry, hostIter, results)
+			go q.run(ctx, qry, hostIter, exec, true, results)
 		case <-ctx.Done():
 			return &Iter{err: ctx.Err()}
 		case iter := <-results:
@@ -59,13 +67,21 @@ func (q *queryExecutor) speculate(ctx context.Context, qry ExecutableQuery, sp S
 }
 
 func (q *queryExecutor) executeQuery(qry ExecutableQuery) (*Iter, error) {
+	if pinned, ok := qry.(*Query); ok && pinned.hostID != "" {
+		hostIter, err := q.pinnedHost(pinned.hostID)
+		if err != nil {
+			return nil, err
+		}
+		return q.do(qry.Context(), qry, hostIter, &queryExecution{}, false), nil
+	}
+
 	hostIter := q.policy.Pick(qry)
 
 	// check if the query is not marked as idempotent, if
 	// it is, we force the policy to NonSpeculative
 	sp := qry.speculativeExecutionPolicy()
 	if !qry.IsIdempotent() || sp.Attempts() == 0 {
-		return q.do(qry.Context(), qry, hostIter), nil
+		return q.do(qry.Context(), qry, hostIter, &queryExecution{}, false), nil
 	}
 
 	// When speculative execution is enabled, we could be accessing the host iterator from multiple goroutines below.
@@ -82,15 +98,16 @@ func (q *queryExecutor) executeQuery(qry ExecutableQuery) (*Iter, error) {
 	defer cancel()
 
 	results := make(chan *Iter, 1)
+	exec := &queryExecution{}
 
 	// Launch the main execution
 	qry.borrowForExecution() // ensure liveness in case of executing Query to prevent races with Query.Release().
-	go q.run(ctx, qry, hostIter, results)
+	go q.run(ctx, qry, hostIter, exec, false, results)
 
 	// The speculative executions are launched _in addition_ to the main
 	// execution, on a timer. So Speculation{2} would make 3 executions running
 	// in total.
-	if iter := q.speculate(ctx, qry, sp, hostIter, results); iter != nil {
+	if iter := q.speculate(ctx, qry, sp, hostIter, exec, results); iter != nil {
 		return iter, nil
 	}
 
@@ -102,78 +119,357 @@ func (q *queryExecutor) executeQuery(qry ExecutableQuery) (*Iter, error) {
 	}
 }
 
-func (q *queryExecutor) do(ctx context.Context, qry ExecutableQuery, hostIter NextHost) *Iter {
-	selectedHost := hostIter()
-	rt := qry.retryPolicy()
+// executeQueryAsync is like executeQuery, but it calls done with the
+// iterator instead of returning it, see doAsync. The speculative executions
+// are started from the timers of the clock of the session.
+func (q *queryExecutor) executeQueryAsync(qry *Query, done func(*Iter)) {
+	if qry.hostID != "" {
+		hostIter, err := q.pinnedHost(qry.hostID)
+		if err != nil {
+			done(&Iter{err: err})
+			return
+		}
+		q.doAsync(q.newQueryAttempts(qry.Context(), qry, hostIter, &queryExecution{}, false), qry, done)
+		return
+	}
+
+	hostIter := q.policy.Pick(qry)
+
+	sp := qry.speculativeExecutionPolicy()
+	if !qry.IsIdempotent() || sp.Attempts() == 0 {
+		q.doAsync(q.newQueryAttempts(qry.Context(), qry, hostIter, &queryExecution{}, false), qry, done)
+		return
+	}
+
+	var mu sync.Mutex
+	origHostIter := hostIter
+	hostIter = func() SelectedHost {
+		mu.Lock()
+		defer mu.Unlock()
+		return origHostIter()
+	}
+
+	ctx, cancel := context.WithCancel(qry.Context())
+	exec := &queryExecution{}
+	spec := &asyncSpeculation{}
+	finish := func(iter *Iter) {
+		if spec.finish() {
+			cancel()
+			done(iter)
+		}
+		qry.releaseAfterExecution()
+	}
+	execute := func(speculative bool) {
+		qry.borrowForExecution() // ensure liveness in case of executing Query to prevent races with Query.Release().
+		q.doAsync(q.newQueryAttempts(ctx, qry, hostIter, exec, speculative), qry, finish)
+	}
+
+	// Launch the main execution, then the speculative ones on a timer.
+	execute(false)
+
+	spec.mu.Lock()
+	defer spec.mu.Unlock()
+	if spec.finished {
+		return
+	}
+	spec.timer = q.pool.session.cfg.clock().AfterFunc(sp.Delay(), func() {
+		spec.mu.Lock()
+		if spec.finished {
+			spec.mu.Unlock()
+			return
+		}
+		spec.launched++
+		if spec.launched < sp.Attempts() {
+			spec.timer.Reset(sp.Delay())
+		}
+		spec.mu.Unlock()
+		execute(true)
+	})
+}
+
+// asyncSpeculation tracks the speculative executions of a query executed
+// asynchronously.
+type asyncSpeculation struct {
+	mu       sync.Mutex
+	timer    Timer
+	launched int
+	finished bool
+}
+
+// finish reports whether the execution is the first to finish, and stops
+// launching speculative executions.
+func (s *asyncSpeculation) finish() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return false
+	}
+	s.finished = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	return true
+}
+
+func (q *queryExecutor) do(ctx context.Context, qry ExecutableQuery, hostIter NextHost, exec *queryExecution, speculative bool) *Iter {
+	a := q.newQueryAttempts(ctx, qry, hostIter, exec, speculative)
+	for conn := a.next(); conn != nil; conn = a.next() {
+		consistency := qry.GetConsistency()
+		start := q.pool.session.cfg.clock().Now()
+		if iter := a.done(conn, consistency, start, qry.execute(ctx, conn)); iter != nil {
+			return iter
+		}
+	}
+	return a.exhausted()
+}
 
-	var lastErr error
-	var iter *Iter
-	for selectedHost != nil {
-		host := selectedHost.Info()
+// doAsync is like do for a query executed asynchronously, it calls done with
+// the iterator instead of returning it. Each attempt is completed by the
+// goroutine reading the responses of its connection, see
+// Conn.executeQueryAsync, the retries are sent from a new goroutine.
+func (q *queryExecutor) doAsync(a *queryAttempts, qry *Query, done func(*Iter)) {
+	conn := a.next()
+	if conn == nil {
+		done(a.exhausted())
+		return
+	}
+
+	consistency := qry.GetConsistency()
+	start := q.pool.session.cfg.clock().Now()
+	conn.executeQueryAsync(a.ctx, qry, func(iter *Iter) {
+		if iter := a.done(conn, consistency, start, iter); iter != nil {
+			done(iter)
+			return
+		}
+		// sending the retry might block, which must not be done while
+		// reading the responses of conn
+		go q.doAsync(a, qry, done)
+	})
+}
+
+// queryAttempts are the attempts of an execution of a query, made on the
+// hosts of hostIter following the retry policy of the query.
+type queryAttempts struct {
+	q           *queryExecutor
+	ctx         context.Context
+	qry         ExecutableQuery
+	hostIter    NextHost
+	exec        *queryExecution
+	speculative bool
+
+	rt           RetryPolicy
+	maxRetries   int
+	limitRetries bool
+
+	selectedHost SelectedHost
+	lastErr      error
+	attempts     int
+}
+
+func (q *queryExecutor) newQueryAttempts(ctx context.Context, qry ExecutableQuery, hostIter NextHost, exec *queryExecution, speculative bool) *queryAttempts {
+	a := &queryAttempts{
+		q:            q,
+		ctx:          ctx,
+		qry:          qry,
+		hostIter:     hostIter,
+		exec:         exec,
+		speculative:  speculative,
+		rt:           qry.retryPolicy(),
+		selectedHost: hostIter(),
+	}
+	a.maxRetries, a.limitRetries = qry.retryLimit()
+	return a
+}
+
+// next returns the connection to make the next attempt on, or nil if there
+// is no host left.
+func (a *queryAttempts) next() *Conn {
+	for a.selectedHost != nil {
+		host := a.selectedHost.Info()
 		if host == nil || !host.IsUp() {
-			selectedHost = hostIter()
+			a.selectedHost = a.hostIter()
 			continue
 		}
 
-		pool, ok := q.pool.getPool(host)
+		pool, ok := a.q.pool.getPool(host)
 		if !ok {
-			selectedHost = hostIter()
+			a.selectedHost = a.hostIter()
 			continue
 		}
 
-		conn := pool.Pick()
+		conn := pool.pickFor(a.selectedHost)
 		if conn == nil {
-			selectedHost = hostIter()
+			a.selectedHost = a.hostIter()
 			continue
 		}
+		return conn
+	}
+	return nil
+}
 
-		iter = q.attemptQuery(ctx, qry, conn)
-		iter.host = selectedHost.Info()
-		// Update host
-		switch iter.err {
-		case context.Canceled, context.DeadlineExceeded, ErrNotFound:
-			// those errors represents logical errors, they should not count
-			// toward removing a node from the pool
-			selectedHost.Mark(nil)
-			return iter
-		default:
-			selectedHost.Mark(iter.err)
+// exhausted returns the iterator of the execution once there is no host
+// left.
+func (a *queryAttempts) exhausted() *Iter {
+	iter := &Iter{err: ErrNoConnections}
+	if a.lastErr != nil {
+		iter.err = a.lastErr
+	}
+	iter.attempts = a.attempts
+	return iter
+}
+
+// done records iter, the result of the attempt made on conn with
+// consistency which started at start. It returns the iterator of the
+// execution, or nil if the query is to be attempted again.
+func (a *queryAttempts) done(conn *Conn, consistency Consistency, start time.Time, iter *Iter) *Iter {
+	q, qry, ctx := a.q, a.qry, a.ctx
+	end := q.pool.session.cfg.clock().Now()
+	iter.execution, iter.speculative = a.exec, a.speculative
+	q.pool.session.latencyStats.record(end.Sub(start))
+	qry.attempt(q.pool.keyspace, end, start, iter, conn.host, conn.shard())
+
+	a.attempts++
+	iter.attempts = a.attempts
+	iter.host = a.selectedHost.Info()
+	if reported, ok := reportedConsistency(iter.err); ok {
+		if requested := requestedConsistency(qry, consistency, reported); reported != requested {
+			q.observeConsistency(ctx, qry, ObservedConsistency{
+				Type:      ConsistencyMismatch,
+				Requested: requested,
+				Actual:    reported,
+				Host:      iter.host,
+				Err:       iter.err,
+			})
 		}
+	}
+	// Update host
+	switch iter.err {
+	case context.Canceled, context.DeadlineExceeded, ErrNotFound:
+		// those errors represents logical errors, they should not count
+		// toward removing a node from the pool
+		a.selectedHost.Mark(nil)
+		if iter.err == context.DeadlineExceeded {
+			q.pool.session.errorStats.record(iter.host, iter.err)
+		}
+		return iter
+	default:
+		a.selectedHost.Mark(iter.err)
+		var corrupted *FrameCorruptionError
+		if iter.err != nil && !errors.Is(iter.err, ErrUnpreparedCategory) && !errors.As(iter.err, &corrupted) {
+			// unprepared statements are counted as they are prepared
+			// again, and corrupted frames as they are received
+			q.pool.session.errorStats.record(iter.host, iter.err)
+		}
+	}
 
-		// Exit if the query was successful
-		// or no retry policy defined or retry attempts were reached
-		if iter.err == nil || rt == nil || !rt.Attempt(qry) {
-			return iter
+	// Exit if the query was successful
+	// or no retry policy defined or retry attempts were reached
+	rt := a.rt
+	if iter.err == nil || rt == nil || a.limitRetries && a.attempts > a.maxRetries || !rt.Attempt(qry) {
+		return iter
+	}
+	// A rate limited write might have been applied by some of the replicas.
+	if _, ok := iter.err.(*RequestErrRateLimitReached); ok && !qry.IsIdempotent() && !IsIdempotentSafe(iter.err) {
+		return iter
+	}
+	a.lastErr = iter.err
+
+	// If query is unsuccessful, check the error with RetryPolicy to retry
+	retryType := rt.GetRetryType(iter.err)
+	if retryType == Retry || retryType == RetryNextHost {
+		if c := qry.GetConsistency(); c != consistency {
+			q.observeConsistency(ctx, qry, ObservedConsistency{
+				Type:      ConsistencyDowngraded,
+				Requested: consistency,
+				Actual:    c,
+				Host:      iter.host,
+				Err:       iter.err,
+			})
 		}
-		lastErr = iter.err
+	}
+	switch retryType {
+	case Retry:
+		// retry on the same host
+		return nil
+	case Rethrow, Ignore:
+		return iter
+	case RetryNextHost:
+		// retry on the next host
+		a.selectedHost = a.hostIter()
+		return nil
+	default:
+		// Undefined? Return nil and error, this will panic in the requester
+		return &Iter{err: ErrUnknownRetryType, attempts: a.attempts}
+	}
+}
 
-		// If query is unsuccessful, check the error with RetryPolicy to retry
-		switch rt.GetRetryType(iter.err) {
-		case Retry:
-			// retry on the same host
-			continue
-		case Rethrow, Ignore:
-			return iter
-		case RetryNextHost:
-			// retry on the next host
-			selectedHost = hostIter()
-			continue
-		default:
-			// Undefined? Return nil and error, this will panic in the requester
-			return &Iter{err: ErrUnknownRetryType}
+// pinnedHost returns the host iterator of a query pinned to the host with the
+// ID hostID, which returns that host only.
+func (q *queryExecutor) pinnedHost(hostID string) (NextHost, error) {
+	host := q.pool.session.ring.getHost(hostID)
+	if host == nil {
+		return nil, ErrHostNotFound
+	}
+	if !host.IsUp() {
+		return nil, ErrHostDown
+	}
+	picked := false
+	return func() SelectedHost {
+		if picked {
+			return nil
 		}
+		picked = true
+		return (*selectedHost)(host)
+	}, nil
+}
+
+func (q *queryExecutor) observeConsistency(ctx context.Context, qry ExecutableQuery, o ObservedConsistency) {
+	observer := q.pool.session.cfg.ConsistencyObserver
+	if observer == nil {
+		return
 	}
+	o.Keyspace = qry.Keyspace()
+	o.Table = qry.Table()
+	o.Attempt = qry.Attempts() - 1
+	observer.ObserveConsistency(ctx, o)
+}
 
-	if lastErr != nil {
-		return &Iter{err: lastErr}
+// reportedConsistency returns the consistency reported by the server in err.
+func reportedConsistency(err error) (Consistency, bool) {
+	switch e := err.(type) {
+	case *RequestErrUnavailable:
+		return e.Consistency, true
+	case *RequestErrReadTimeout:
+		return e.Consistency, true
+	case *RequestErrWriteTimeout:
+		return e.Consistency, true
+	case *RequestErrReadFailure:
+		return e.Consistency, true
+	case *RequestErrWriteFailure:
+		return e.Consistency, true
+	case *RequestErrCASWriteUnknown:
+		return e.Consistency, true
 	}
+	return 0, false
+}
 
-	return &Iter{err: ErrNoConnections}
+// requestedConsistency returns the consistency of qry the server reported
+// consistency is to be compared with: the serial consistency of qry if the
+// server reports the one of the Paxos phase of a lightweight transaction,
+// SERIAL by default, or else consistency.
+func requestedConsistency(qry ExecutableQuery, consistency, reported Consistency) Consistency {
+	if reported != Consistency(Serial) && reported != Consistency(LocalSerial) {
+		return consistency
+	}
+	if serial := qry.serialConsistency(); serial != 0 {
+		return Consistency(serial)
+	}
+	return Consistency(Serial)
 }
 
-func (q *queryExecutor) run(ctx context.Context, qry ExecutableQuery, hostIter NextHost, results chan<- *Iter) {
+func (q *queryExecutor) run(ctx context.Context, qry ExecutableQuery, hostIter NextHost, exec *queryExecution, speculative bool, results chan<- *Iter) {
 	select {
-	case results <- q.do(ctx, qry, hostIter):
+	case results <- q.do(ctx, qry, hostIter, exec, speculative):
 	case <-ctx.Done():
 	}
 	qry.releaseAfterExecution()
@@ -0,0 +1,116 @@
+package gocql
+
+import (
+	"context"
+)
+
+// QueryFuture is the pending result of a query started with Query.IterAsync
+// or Query.ExecAsync. It completes once the response for the query arrives,
+// after any retries and speculative executions configured for the query
+// have been performed.
+//
+// Waiting for the response does not take a goroutine: the future is
+// completed by the goroutine reading the responses of the connection the
+// query was sent on. Only a query which can not be sent right away, such as
+// one which statement is being prepared or which connection has no free
+// stream, a retry, or a query executed through ClusterConfig.QueryInterceptors
+// runs on a goroutine of its own.
+//
+// A QueryFuture is safe for concurrent use by multiple goroutines.
+type QueryFuture struct {
+	done chan struct{}
+	iter *Iter
+}
+
+func newQueryFuture(qry *Query, closeIter bool) *QueryFuture {
+	f := &QueryFuture{done: make(chan struct{})}
+	qry.iterAsync(func(iter *Iter) {
+		if closeIter {
+			iter.Close()
+		}
+		f.iter = iter
+		close(f.done)
+	})
+	return f
+}
+
+// Done returns a channel which is closed once the query has completed.
+func (f *QueryFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Iter blocks until the query has completed and returns its iterator.
+// For futures returned by Query.ExecAsync the iterator is already closed.
+func (f *QueryFuture) Iter() *Iter {
+	<-f.done
+	return f.iter
+}
+
+// Err blocks until the query has completed and returns the error of the query, if any.
+func (f *QueryFuture) Err() error {
+	<-f.done
+	return f.iter.err
+}
+
+// IterAsync starts executing the query with the given context and returns a
+// future of its iterator without waiting for the response. The query goes
+// through the same host selection, retry and speculative execution logic as
+// Iter. The future completes with the error of ctx once its deadline has
+// passed or, when built with Go 1.21 or later, once it is canceled.
+//
+// The QueryObserver and ConsistencyObserver of the query may be called
+// from the goroutine reading the responses of a connection, and must not
+// block. The query must not be modified or released until the future has
+// completed.
+func (q *Query) IterAsync(ctx context.Context) *QueryFuture {
+	return newQueryFuture(q.WithContext(ctx), false)
+}
+
+// ExecAsync is like IterAsync, but it discards the rows of the result,
+// same as Exec does.
+func (q *Query) ExecAsync(ctx context.Context) *QueryFuture {
+	return newQueryFuture(q.WithContext(ctx), true)
+}
+
+// iterAsync is like Iter, but it calls done with the iterator instead of
+// returning it.
+func (q *Query) iterAsync(done func(*Iter)) {
+	if q.err != nil {
+		done(&Iter{err: q.err})
+		return
+	}
+	if q.routingTokenErr != nil {
+		done(&Iter{err: q.routingTokenErr})
+		return
+	}
+	if isUseStatement(q.stmt) {
+		done(&Iter{err: ErrUseStmt})
+		return
+	}
+	if q.conn != nil {
+		q.conn.executeQueryAsync(q.Context(), q, done)
+		return
+	}
+	q.session.executeQueryAsync(q, done)
+}
+
+// WhenAll waits until all futures complete and returns the first error
+// encountered in the order the futures were passed. If ctx is done before
+// all futures complete, the context error is returned. Queries that are
+// still in flight are governed by their own contexts and are not canceled.
+func WhenAll(ctx context.Context, futures ...*QueryFuture) error {
+	for _, f := range futures {
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for _, f := range futures {
+		if err := f.iter.err; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueryExecAsync(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	futures := make([]*QueryFuture, 0, 10)
+	for i := 0; i < 10; i++ {
+		futures = append(futures, db.Query("void").ExecAsync(ctx))
+	}
+
+	if err := WhenAll(ctx, futures...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, f := range futures {
+		select {
+		case <-f.Done():
+		default:
+			t.Fatalf("future %d not done after WhenAll returned", i)
+		}
+	}
+
+	failed := db.Query("kill").ExecAsync(ctx)
+	err = WhenAll(ctx, db.Query("void").ExecAsync(ctx), failed)
+	if reqErr, ok := err.(RequestError); !ok || reqErr.Code() != ErrCodeOverloaded {
+		t.Fatalf("expected overloaded error from killed query, got %v", err)
+	}
+}
+
+func TestWhenAllContextDone(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	queryCtx, cancelQuery := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelQuery()
+	f := db.Query("timeout").IterAsync(queryCtx)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := WhenAll(ctx, f); err != context.Canceled {
+		t.Fatalf("expected %v got %v", context.Canceled, err)
+	}
+
+	if err := f.Err(); err != context.DeadlineExceeded {
+		t.Fatalf("expected query to exceed its deadline, got %v", err)
+	}
+}
+
+func TestQueryIterAsyncRetry(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	qry := db.Query("kill").RetryPolicy(alwaysRetryPolicy{}).MaxRetries(2)
+	iter := qry.IterAsync(context.Background()).Iter()
+	if err := iter.Close(); err == nil {
+		t.Fatal("expected the killed query to fail")
+	}
+	if iter.Attempts() != 3 || qry.Attempts() != 3 {
+		t.Fatalf("expected 3 attempts, got %d for the iterator and %d for the query", iter.Attempts(), qry.Attempts())
+	}
+	if n := atomic.LoadInt64(&srv.nKillReq); n != 3 {
+		t.Fatalf("expected the server to receive 3 requests got %d", n)
+	}
+}
+
+// clientGoroutines returns the number of goroutines which are not serving
+// the test servers.
+func clientGoroutines() int {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	count := 0
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if !strings.Contains(g, "(*TestServer)") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestQueryIterAsyncInFlight(t *testing.T) {
+	var queries int64
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		recvHook: func(f *framer) {
+			if f.header.op == opQuery {
+				atomic.AddInt64(&queries, 1)
+			}
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	const n = 200
+	sent := atomic.LoadInt64(&queries) + n
+	before := clientGoroutines()
+
+	// the server never responds to the queries, which complete once the
+	// deadline has passed
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	futures := make([]*QueryFuture, 0, n)
+	for i := 0; i < n; i++ {
+		futures = append(futures, db.Query("timeout").IterAsync(ctx))
+	}
+	for atomic.LoadInt64(&queries) < sent {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("server received %d of %d queries", n-(sent-atomic.LoadInt64(&queries)), n)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if started := clientGoroutines() - before; started >= n/10 {
+		t.Fatalf("%d goroutines started for %d queries in flight", started, n)
+	}
+
+	for i, f := range futures {
+		if err := f.Err(); err != context.DeadlineExceeded {
+			t.Fatalf("future %d: expected %v got %v", i, context.DeadlineExceeded, err)
+		}
+	}
+}
+
+func TestQueryIterAsyncSpeculativeExecution(t *testing.T) {
+	var addresses []string
+	for _, ip := range []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"} {
+		srv := NewTestServerWithAddress(ip+":0", t, defaultProto, context.Background())
+		defer srv.Stop()
+		addresses = append(addresses, srv.Address)
+	}
+
+	db, err := newTestSession(defaultProto, addresses...)
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	rt := &testRetryPolicy{NumRetries: 8}
+	sp := &SimpleSpeculativeExecution{NumAttempts: 1, TimeoutDelay: 200 * time.Millisecond}
+	observer := &attemptsObserver{}
+	qry := db.Query("speculative").RetryPolicy(rt).SetSpeculativeExecutionPolicy(sp).Idempotent(true).Observer(observer)
+	if err := qry.ExecAsync(context.Background()).Err(); err != nil {
+		t.Fatalf("The query failed with '%v'!\n", err)
+	}
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	var speculative, main int
+	for _, attempt := range observer.succeeded.Attempts {
+		if attempt.Speculative {
+			speculative++
+		} else {
+			main++
+		}
+	}
+	if speculative == 0 || main == 0 {
+		t.Errorf("expected attempts of the main and speculative executions, got %d and %d", main, speculative)
+	}
+}
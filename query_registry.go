@@ -0,0 +1,171 @@
+package gocql
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// QueryRegistry maps names to CQL statements, as a single catalog of the
+// statements of an application, executed with Session.NamedQuery once set as
+// ClusterConfig.QueryRegistry. Statements are prepared on their first use and
+// prepared again after schema changes. A registry is safe for concurrent use.
+type QueryRegistry struct {
+	mu    sync.RWMutex
+	stmts map[string]string
+	// names are the names of the statements by statement
+	names map[string]string
+}
+
+// NewQueryRegistry returns an empty registry.
+func NewQueryRegistry() *QueryRegistry {
+	return &QueryRegistry{
+		stmts: make(map[string]string),
+		names: make(map[string]string),
+	}
+}
+
+// LoadQueryRegistry returns a registry of the statements of a file, see
+// QueryRegistry.Load for its format.
+func LoadQueryRegistry(path string) (*QueryRegistry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := NewQueryRegistry()
+	if err := r.Load(f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return r, nil
+}
+
+// Register adds a statement named name, names must be unique.
+func (r *QueryRegistry) Register(name, stmt string) error {
+	stmt = strings.TrimRight(strings.TrimSpace(stmt), ";")
+	if name == "" || stmt == "" {
+		return fmt.Errorf("gocql: empty name or statement of named query %q", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.stmts[name]; ok {
+		return fmt.Errorf("gocql: named query %q already registered", name)
+	}
+	r.stmts[name] = stmt
+	r.names[stmt] = name
+	return nil
+}
+
+// Load registers the statements read from rd, each preceded by a line
+// naming it:
+//
+//	-- name: get_user
+//	SELECT name FROM users WHERE id = ?;
+//
+//	-- name: add_user
+//	INSERT INTO users (id, name) VALUES (?, ?);
+//
+// Lines before the first name must be blank or comments.
+func (r *QueryRegistry) Load(rd io.Reader) error {
+	var (
+		name string
+		stmt strings.Builder
+		line int
+	)
+	flush := func() error {
+		if name == "" {
+			return nil
+		}
+		return r.Register(name, stmt.String())
+	}
+
+	scanner := bufio.NewScanner(rd)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		if strings.HasPrefix(trimmed, "--") {
+			comment := strings.TrimSpace(strings.TrimPrefix(trimmed, "--"))
+			if strings.HasPrefix(comment, "name:") {
+				if err := flush(); err != nil {
+					return err
+				}
+				name = strings.TrimSpace(strings.TrimPrefix(comment, "name:"))
+				stmt.Reset()
+				continue
+			}
+		}
+		if name == "" {
+			if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return fmt.Errorf("gocql: line %d: statement with no name", line)
+			}
+			continue
+		}
+		stmt.WriteString(text)
+		stmt.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// Statement returns the statement named name.
+func (r *QueryRegistry) Statement(name string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	stmt, ok := r.stmts[name]
+	return stmt, ok
+}
+
+// Names returns the sorted names of the statements.
+func (r *QueryRegistry) Names() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.stmts))
+	for name := range r.stmts {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func (r *QueryRegistry) contains(stmt string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.names[stmt]
+	return ok
+}
+
+// NamedQuery returns a query executing the statement named name in the
+// registry of the session, with the values. Executing the query fails if
+// there is no such statement.
+func (s *Session) NamedQuery(name string, values ...interface{}) *Query {
+	var (
+		stmt string
+		ok   bool
+	)
+	if s.cfg.QueryRegistry != nil {
+		stmt, ok = s.cfg.QueryRegistry.Statement(name)
+	}
+	qry := s.Query(stmt, values...)
+	if !ok {
+		qry.err = fmt.Errorf("gocql: unknown named query %q", name)
+	}
+	return qry
+}
+
+// reprepareNamedQueries removes the statements of the registry from the
+// prepared statement cache, so that they are prepared again with the new
+// schema on their next use.
+func (s *Session) reprepareNamedQueries() {
+	if r := s.cfg.QueryRegistry; r != nil {
+		s.stmtsLRU.removeStatements(r.contains)
+	}
+}
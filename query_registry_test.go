@@ -0,0 +1,90 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQueryRegistryLoad(t *testing.T) {
+	r := NewQueryRegistry()
+	err := r.Load(strings.NewReader(`-- the queries of the users
+-- name: get_user
+SELECT name
+FROM users WHERE id = ?;
+
+-- name: add_user
+INSERT INTO users (id, name) VALUES (?, ?)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"add_user", "get_user"}) {
+		t.Fatalf("unexpected names %v", names)
+	}
+	if stmt, _ := r.Statement("get_user"); stmt != "SELECT name\nFROM users WHERE id = ?" {
+		t.Fatalf("unexpected statement %q", stmt)
+	}
+
+	for _, content := range []string{
+		"SELECT * FROM users\n-- name: a\nSELECT 1",
+		"-- name: a\nSELECT 1\n-- name: a\nSELECT 2",
+		"-- name: a\n",
+	} {
+		if err := NewQueryRegistry().Load(strings.NewReader(content)); err == nil {
+			t.Errorf("%q: expected an error", content)
+		}
+	}
+}
+
+func TestSessionNamedQuery(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	registry := NewQueryRegistry()
+	if err := registry.Register("add", "INSERT INTO t (c0) VALUES (?)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register("void", "void"); err != nil {
+		t.Fatal(err)
+	}
+	cluster := testCluster(protoVersion4, srv.Address)
+	cluster.QueryRegistry = registry
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.NamedQuery("void").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.NamedQuery("missing").Exec(); err == nil || !strings.Contains(err.Error(), `unknown named query "missing"`) {
+		t.Fatalf("expected an unknown named query error got %v", err)
+	}
+
+	if err := db.NamedQuery("add", 1).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Query("INSERT INTO t (c0, c1) VALUES (?, ?)", 1, 2).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if size := db.PreparedCacheStats().Size; size != 2 {
+		t.Fatalf("expected 2 prepared statements got %d", size)
+	}
+
+	db.handleSchemaEvent([]frame{&schemaChangeTable{keyspace: "ks", object: "t", change: "UPDATED"}})
+	if size := db.PreparedCacheStats().Size; size != 1 {
+		t.Fatalf("expected the named query to be removed from the prepared cache got %d statements", size)
+	}
+	if err := db.NamedQuery("add", 1).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if size := db.PreparedCacheStats().Size; size != 2 {
+		t.Fatalf("expected the named query to be prepared again got %d statements", size)
+	}
+}
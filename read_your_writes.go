@@ -0,0 +1,123 @@
+package gocql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ReadYourWrites records the client timestamps of the writes executed through
+// it per partition, so that reads of a partition written within its window
+// are executed at a consistency which guarantees they see the write, e.g. to
+// show a user the comment they just posted while other reads stay at a weaker
+// consistency. Writes and reads at LOCAL_QUORUM guarantee it within a data
+// center, writes and reads at QUORUM across data centers.
+//
+// Partitions are identified by the routing key of the queries, queries whose
+// routing key can not be computed are not tracked. A ReadYourWrites is safe
+// for concurrent use.
+type ReadYourWrites struct {
+	session     *Session
+	window      time.Duration
+	consistency Consistency
+
+	mu sync.Mutex
+	// writes are the timestamps of the last write per partition
+	writes    map[string]int64
+	lastSweep int64
+}
+
+// NewReadYourWrites creates a ReadYourWrites for the partitions written less
+// than window ago, read at consistency.
+func (s *Session) NewReadYourWrites(window time.Duration, consistency Consistency) *ReadYourWrites {
+	return &ReadYourWrites{
+		session:     s,
+		window:      window,
+		consistency: consistency,
+		writes:      make(map[string]int64),
+	}
+}
+
+// Write executes the write q with ctx and records its partition. The client
+// timestamp of the query is assigned first if it has none.
+func (r *ReadYourWrites) Write(ctx context.Context, q *Query) error {
+	if q.defaultTimestampValue == 0 {
+		ts := r.session.cfg.timestampFrom(q.timestampGenerator)
+		if ts == 0 {
+			// the timestamp of the write must be known
+			ts = r.session.cfg.clock().Now().UnixNano() / 1000
+		}
+		q.WithTimestamp(ts)
+	}
+	if err := q.ExecContext(ctx); err != nil {
+		return err
+	}
+
+	partition, err := r.partition(q)
+	if err != nil || partition == "" {
+		return err
+	}
+
+	ts := q.defaultTimestampValue
+	r.mu.Lock()
+	if last, ok := r.writes[partition]; ts >= r.cutoff() && (!ok || last < ts) {
+		r.writes[partition] = ts
+	}
+	r.sweep()
+	r.mu.Unlock()
+	return nil
+}
+
+// Read sets the consistency of the read q to the consistency of r if its
+// partition was written within the window and returns q.
+func (r *ReadYourWrites) Read(q *Query) *Query {
+	if _, ok := r.LastWrite(q); ok {
+		q.Consistency(r.consistency)
+	}
+	return q
+}
+
+// LastWrite returns the client timestamp, in microseconds since the Unix
+// epoch, of the last write to the partition of q if it was within the window.
+func (r *ReadYourWrites) LastWrite(q *Query) (int64, bool) {
+	partition, err := r.partition(q)
+	if err != nil || partition == "" {
+		return 0, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ts, ok := r.writes[partition]
+	if !ok || ts < r.cutoff() {
+		return 0, false
+	}
+	return ts, true
+}
+
+func (r *ReadYourWrites) partition(q *Query) (string, error) {
+	key, err := q.GetRoutingKey()
+	if err != nil || key == nil {
+		return "", err
+	}
+	return q.Keyspace() + "\x00" + q.Table() + "\x00" + string(key), nil
+}
+
+// cutoff returns the oldest timestamp within the window.
+func (r *ReadYourWrites) cutoff() int64 {
+	return (r.session.cfg.clock().Now().UnixNano() - int64(r.window)) / 1000
+}
+
+// sweep removes the partitions written before the window, at most once per
+// window. r.mu must be held.
+func (r *ReadYourWrites) sweep() {
+	cutoff := r.cutoff()
+	if r.lastSweep > cutoff {
+		return
+	}
+	for partition, ts := range r.writes {
+		if ts < cutoff {
+			delete(r.writes, partition)
+		}
+	}
+	r.lastSweep = cutoff + int64(r.window/time.Microsecond)
+}
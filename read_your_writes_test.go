@@ -0,0 +1,63 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	clock := &recordingClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cluster := testCluster(protoVersion4, srv.Address)
+	cluster.Clock = clock
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	const (
+		insert = "INSERT INTO t (pk, c) VALUES (:pk, :c)"
+		read   = "SELECT c FROM t WHERE pk = :pk"
+	)
+	ryw := db.NewReadYourWrites(time.Minute, LocalQuorum)
+	ctx := context.Background()
+
+	if err := ryw.Write(ctx, db.Query(insert, 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	qry := ryw.Read(db.Query(read, 1).Consistency(One))
+	if c := qry.GetConsistency(); c != LocalQuorum {
+		t.Errorf("expected the read of the written partition at %v, got %v", LocalQuorum, c)
+	}
+	if ts, ok := ryw.LastWrite(qry); !ok || ts != clock.now.UnixNano()/1000 {
+		t.Errorf("expected the last write at %d, got %d (%v)", clock.now.UnixNano()/1000, ts, ok)
+	}
+
+	qry = ryw.Read(db.Query(read, 2).Consistency(One))
+	if c := qry.GetConsistency(); c != One {
+		t.Errorf("expected the read of another partition at %v, got %v", One, c)
+	}
+
+	// a write before the window is not tracked
+	old := clock.now.Add(-2*time.Minute).UnixNano() / 1000
+	if err := ryw.Write(ctx, db.Query(insert, 3, 4).WithTimestamp(old)); err != nil {
+		t.Fatal(err)
+	}
+	qry = ryw.Read(db.Query(read, 3).Consistency(One))
+	if c := qry.GetConsistency(); c != One {
+		t.Errorf("expected the read of a partition written before the window at %v, got %v", One, c)
+	}
+
+	ryw.mu.Lock()
+	defer ryw.mu.Unlock()
+	if len(ryw.writes) != 1 {
+		t.Errorf("expected the partitions written before the window to be removed, got %d partitions", len(ryw.writes))
+	}
+}
@@ -2,6 +2,7 @@ package gocql
 
 import (
 	"fmt"
+	"net"
 	"sync"
 	"sync/atomic"
 )
@@ -25,6 +26,32 @@ type ring struct {
 	// TODO: we should store the ring metadata here also.
 }
 
+// endpointPort returns the port of the endpoint with the address addr, such
+// as the port of a contact point given as host:port. An endpoint with both
+// addr and port is matched first. Otherwise the endpoints with the address
+// addr must all have the same port, as the port of a node behind a
+// port-mapping proxy can not be told apart from those of the other nodes
+// at the same address.
+func (r *ring) endpointPort(addr net.IP, port int) (int, bool) {
+	var (
+		matched, ambiguous bool
+		matchedPort        int
+	)
+	for _, h := range r.endpoints {
+		if !h.connectAddress.Equal(addr) {
+			continue
+		}
+		if h.port == port {
+			return port, true
+		}
+		if matched && h.port != matchedPort {
+			ambiguous = true
+		}
+		matched, matchedPort = true, h.port
+	}
+	return matchedPort, matched && !ambiguous
+}
+
 func (r *ring) rrHost() *HostInfo {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -0,0 +1,184 @@
+package gocql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ScriptError is the error of a statement of a script executed by
+// Session.ExecScript.
+type ScriptError struct {
+	// Index is the index of the statement in the script, from 0.
+	Index int
+	// Line is the line of the script the statement starts at, from 1.
+	Line      int
+	Statement string
+	Err       error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("gocql: statement %d at line %d: %v", e.Index+1, e.Line, e.Err)
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+type scriptStatement struct {
+	stmt string
+	line int
+}
+
+// ExecScript executes the statements of a CQL script in order, such as a
+// schema migration. Statements are separated by semicolons, which are ignored
+// in strings, comments and batches. After a CREATE, ALTER or DROP statement
+// ExecScript waits for the nodes to agree on the schema before executing the
+// next statement.
+//
+// ExecScript stops at the first statement failing and returns a *ScriptError,
+// the previous statements are not rolled back. USE statements are not
+// supported, statements must qualify their tables by their keyspace unless
+// the session has one.
+func (s *Session) ExecScript(ctx context.Context, script string) error {
+	stmts, err := splitScript(script)
+	if err != nil {
+		return err
+	}
+
+	for i, stmt := range stmts {
+		fail := func(err error) error {
+			return &ScriptError{Index: i, Line: stmt.line, Statement: stmt.stmt, Err: err}
+		}
+		if err := s.Query(stmt.stmt).WithContext(ctx).Exec(); err != nil {
+			return fail(err)
+		}
+		if isSchemaStatement(stmt.stmt) {
+			// without a control connection the connection executing the
+			// statement already waited for the agreement
+			if err := s.AwaitSchemaAgreement(ctx); err != nil && err != errNoControl {
+				return fail(err)
+			}
+		}
+	}
+	return nil
+}
+
+func isSchemaStatement(stmt string) bool {
+	var keyword string
+	if fields := strings.Fields(stmt); len(fields) > 0 {
+		keyword = strings.ToUpper(fields[0])
+	}
+	switch keyword {
+	case "CREATE", "ALTER", "DROP":
+		return true
+	}
+	return false
+}
+
+// splitScript splits a script into its statements, without their comments
+// and trailing semicolons.
+func splitScript(script string) ([]scriptStatement, error) {
+	var (
+		stmts []scriptStatement
+		cur   strings.Builder
+		line  = 1
+		start int
+	)
+	emit := func() {
+		if stmt := strings.TrimSpace(cur.String()); stmt != "" {
+			stmts = append(stmts, scriptStatement{stmt: stmt, line: start})
+		}
+		cur.Reset()
+		start = 0
+	}
+	// quoted writes the quoted string, identifier or $$ string starting at
+	// i and returns the index following it
+	quoted := func(i int, quote string) (int, error) {
+		from := line
+		j := i + len(quote)
+		for {
+			n := strings.Index(script[j:], quote)
+			if n < 0 {
+				return 0, fmt.Errorf("gocql: unterminated %s at line %d of script", quote, from)
+			}
+			j += n + len(quote)
+			// quotes are escaped by doubling them, except in $$ strings
+			if quote == "$$" || j >= len(script) || script[j:j+1] != quote {
+				break
+			}
+			j++
+		}
+		line += strings.Count(script[i:j], "\n")
+		cur.WriteString(script[i:j])
+		return j, nil
+	}
+
+	for i := 0; i < len(script); {
+		c := script[i]
+		next := byte(0)
+		if i+1 < len(script) {
+			next = script[i+1]
+		}
+		if start == 0 && c != ';' && c != ' ' && c != '\t' && c != '\r' && c != '\n' &&
+			!(c == '-' && next == '-') && !(c == '/' && (next == '/' || next == '*')) {
+			start = line
+		}
+
+		switch {
+		case c == '\n':
+			line++
+			cur.WriteByte(c)
+			i++
+		case c == '-' && next == '-', c == '/' && next == '/':
+			n := strings.IndexByte(script[i:], '\n')
+			if n < 0 {
+				n = len(script) - i
+			}
+			i += n
+		case c == '/' && next == '*':
+			n := strings.Index(script[i+2:], "*/")
+			if n < 0 {
+				return nil, fmt.Errorf("gocql: unterminated comment at line %d of script", line)
+			}
+			line += strings.Count(script[i:i+2+n], "\n")
+			cur.WriteByte(' ')
+			i += n + 4
+		case c == '\'', c == '"', c == '$' && next == '$':
+			quote := string(c)
+			if c == '$' {
+				quote = "$$"
+			}
+			var err error
+			if i, err = quoted(i, quote); err != nil {
+				return nil, err
+			}
+		case c == ';':
+			if inBatch(cur.String()) {
+				cur.WriteByte(c)
+			} else {
+				emit()
+			}
+			i++
+		default:
+			cur.WriteByte(c)
+			i++
+		}
+	}
+	if inBatch(cur.String()) {
+		return nil, fmt.Errorf("gocql: unterminated batch at line %d of script", start)
+	}
+	emit()
+	return stmts, nil
+}
+
+// inBatch reports whether stmt is the beginning of a batch, which ends with
+// APPLY BATCH.
+func inBatch(stmt string) bool {
+	fields := strings.Fields(strings.ToUpper(stmt))
+	if len(fields) == 0 || fields[0] != "BEGIN" {
+		return false
+	}
+	n := len(fields)
+	return n < 2 || fields[n-2] != "APPLY" || fields[n-1] != "BATCH"
+}
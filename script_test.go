@@ -0,0 +1,71 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSplitScript(t *testing.T) {
+	script := `-- create the schema
+CREATE TABLE ks.t (id int PRIMARY KEY, v text); /* a
+comment; */ INSERT INTO ks.t (id, v) VALUES (1, 'it''s; here');
+// a comment; with a semicolon
+BEGIN UNLOGGED BATCH
+  INSERT INTO ks.t (id, v) VALUES (2, "a;b");
+  DELETE FROM ks.t WHERE id = 1;
+APPLY BATCH;
+CREATE FUNCTION ks.f (v text) RETURNS NULL ON NULL INPUT RETURNS text LANGUAGE lua AS $$ return v; $$
+;;`
+	stmts, err := splitScript(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []scriptStatement{
+		{"CREATE TABLE ks.t (id int PRIMARY KEY, v text)", 2},
+		{"INSERT INTO ks.t (id, v) VALUES (1, 'it''s; here')", 3},
+		{"BEGIN UNLOGGED BATCH\n  INSERT INTO ks.t (id, v) VALUES (2, \"a;b\");\n  DELETE FROM ks.t WHERE id = 1;\nAPPLY BATCH", 5},
+		{"CREATE FUNCTION ks.f (v text) RETURNS NULL ON NULL INPUT RETURNS text LANGUAGE lua AS $$ return v; $$", 9},
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Fatalf("expected %q got %q", expected, stmts)
+	}
+
+	for _, script := range []string{
+		"SELECT 'a FROM t",
+		"SELECT a FROM t /* comment",
+		"BEGIN BATCH INSERT INTO t (a) VALUES (1);",
+		"SELECT $$ FROM t",
+	} {
+		if _, err := splitScript(script); err == nil {
+			t.Errorf("%q: expected an error", script)
+		}
+	}
+}
+
+func TestSessionExecScript(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.ExecScript(context.Background(), "CREATE TABLE ks.t (id int PRIMARY KEY);\nvoid;"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.ExecScript(context.Background(), "void;\n\n-- fails\nkill;\nvoid")
+	scriptErr, ok := err.(*ScriptError)
+	if !ok {
+		t.Fatalf("expected a script error got %v", err)
+	}
+	if scriptErr.Index != 1 || scriptErr.Line != 4 || scriptErr.Statement != "kill" || scriptErr.Err == nil {
+		t.Fatalf("unexpected script error %+v", scriptErr)
+	}
+}
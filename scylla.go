@@ -0,0 +1,365 @@
+package gocql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Options advertised by Scylla nodes in the SUPPORTED frame.
+const (
+	scyllaShard              = "SCYLLA_SHARD"
+	scyllaNrShards           = "SCYLLA_NR_SHARDS"
+	scyllaPartitioner        = "SCYLLA_PARTITIONER"
+	scyllaShardingAlgorithm  = "SCYLLA_SHARDING_ALGORITHM"
+	scyllaShardingIgnoreMSB  = "SCYLLA_SHARDING_IGNORE_MSB"
+	scyllaShardAwarePort     = "SCYLLA_SHARD_AWARE_PORT"
+	scyllaShardAwarePortSSL  = "SCYLLA_SHARD_AWARE_PORT_SSL"
+	scyllaRateLimitError     = "SCYLLA_RATE_LIMIT_ERROR"
+	scyllaServiceLevel       = "SCYLLA_SERVICE_LEVEL"
+	scyllaLWTAddMetadataMark = "SCYLLA_LWT_ADD_METADATA_MARK"
+)
+
+// scyllaSupported are the Scylla protocol extensions supported by a node and
+// its sharding information, the zero value is used for Cassandra nodes.
+type scyllaSupported struct {
+	shard             int
+	nrShards          int
+	msbIgnore         uint64
+	partitioner       string
+	shardingAlgorithm string
+	shardAwarePort    int
+	shardAwarePortSSL int
+	// rateLimitErrorCode is the error code of rate limit errors, if the node supports them.
+	rateLimitErrorCode int
+}
+
+func parseScyllaSupported(supported map[string][]string) scyllaSupported {
+	first := func(key string) string {
+		if v := supported[key]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	atoi := func(key string) int {
+		n, err := strconv.Atoi(first(key))
+		if err != nil {
+			return 0
+		}
+		return n
+	}
+
+	si := scyllaSupported{
+		shard:             atoi(scyllaShard),
+		nrShards:          atoi(scyllaNrShards),
+		partitioner:       first(scyllaPartitioner),
+		shardingAlgorithm: first(scyllaShardingAlgorithm),
+		shardAwarePort:    atoi(scyllaShardAwarePort),
+		shardAwarePortSSL: atoi(scyllaShardAwarePortSSL),
+	}
+	if msb, err := strconv.ParseUint(first(scyllaShardingIgnoreMSB), 10, 64); err == nil {
+		si.msbIgnore = msb
+	}
+	if code := strings.TrimPrefix(first(scyllaRateLimitError), "ERROR_CODE="); code != "" {
+		if n, err := strconv.Atoi(code); err == nil {
+			si.rateLimitErrorCode = n
+		}
+	}
+	if si.nrShards <= 0 || si.shard < 0 || si.shard >= si.nrShards {
+		return scyllaSupported{rateLimitErrorCode: si.rateLimitErrorCode}
+	}
+	return si
+}
+
+// isShardAware reports whether the node is sharded and uses the sharding
+// algorithm the driver can compute shards for.
+func (s scyllaSupported) isShardAware() bool {
+	return s.nrShards > 0 &&
+		s.partitioner == "org.apache.cassandra.dht.Murmur3Partitioner" &&
+		s.shardingAlgorithm == "biased-token-round-robin"
+}
+
+// shardForToken returns the shard owning the murmur3 token.
+func (s scyllaSupported) shardForToken(token int64) int {
+	if s.nrShards <= 1 {
+		return 0
+	}
+	biased := uint64(token) + (1 << 63)
+	biased <<= s.msbIgnore
+	shard, _ := bits.Mul64(biased, uint64(s.nrShards))
+	return int(shard)
+}
+
+// shard returns the shard the connection is established to, or -1 if the
+// node is not sharded.
+func (c *Conn) shard() int {
+	if c.scyllaSupported.nrShards == 0 {
+		return -1
+	}
+	return c.scyllaSupported.shard
+}
+
+// shardTarget is the shard a new connection should be established to.
+type shardTarget struct {
+	shard    int
+	nrShards int
+	port     int
+	portSSL  int
+	// dialed is set once connected through the shard-aware port, rather than
+	// the regular port it fell back to.
+	dialed bool
+}
+
+// shardDialer is implemented by host dialers which can connect to a specific
+// shard of a Scylla node through its shard-aware port.
+type shardDialer interface {
+	dialShard(ctx context.Context, host *HostInfo, target shardTarget) (*DialedHost, error)
+}
+
+const (
+	shardAwarePortLow  = 49152
+	shardAwarePortHigh = 65535
+)
+
+// errShardAwarePortUnavailable is returned when a connection can not be
+// established through the shard-aware port of a node.
+var errShardAwarePortUnavailable = errors.New("gocql: shard-aware port unavailable")
+
+// dialShard connects to the shard-aware port of the host from a local port
+// which is mapped by Scylla to the requested shard (local port % nrShards == shard).
+func (hd *defaultHostDialer) dialShard(ctx context.Context, host *HostInfo, target shardTarget) (*DialedHost, error) {
+	nd, ok := hd.dialer.(*net.Dialer)
+	if !ok {
+		return nil, errShardAwarePortUnavailable
+	}
+
+	port := target.port
+	if hd.tlsConfig != nil {
+		port = target.portSSL
+	}
+	ip := host.ConnectAddress()
+	if !validIpAddr(ip) {
+		return nil, fmt.Errorf("host missing connect ip address: %v", ip)
+	} else if port == 0 {
+		return nil, errShardAwarePortUnavailable
+	}
+	connAddr := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+
+	const maxAttempts = 8
+	localPort := randomShardPort(target.shard, target.nrShards)
+	var lastErr error
+	for i := 0; i < maxAttempts; i++ {
+		d := *nd
+		d.LocalAddr = &net.TCPAddr{Port: localPort}
+		conn, err := d.DialContext(ctx, "tcp", connAddr)
+		if err == nil {
+			if err := hd.sockOpts.apply(conn); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("unable to set socket options: %v", err)
+			}
+			return hd.wrapTLS(ctx, conn, host)
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) && !errors.Is(err, syscall.EADDRNOTAVAIL) {
+			return nil, err
+		}
+		lastErr = err
+
+		localPort += target.nrShards
+		if localPort > shardAwarePortHigh {
+			localPort = firstShardPort(target.shard, target.nrShards)
+		}
+	}
+	return nil, lastErr
+}
+
+// firstShardPort returns the lowest local port mapped to the shard.
+func firstShardPort(shard, nrShards int) int {
+	port := shardAwarePortLow + (nrShards-shardAwarePortLow%nrShards+shard)%nrShards
+	return port
+}
+
+// randomShardPort returns a random local port mapped to the shard.
+func randomShardPort(shard, nrShards int) int {
+	first := firstShardPort(shard, nrShards)
+	n := (shardAwarePortHigh-first)/nrShards + 1
+	return first + rand.Intn(n)*nrShards
+}
+
+// cqlToken is a token of a CQL statement, depth is the nesting level of
+// parentheses, brackets and braces the token is in.
+type cqlToken struct {
+	text       string
+	start, end int
+	depth      int
+}
+
+func (t cqlToken) is(keyword string) bool {
+	return t.depth == 0 && strings.EqualFold(t.text, keyword)
+}
+
+func isCQLWordChar(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || c == '?' || c == ':' || c == '-' ||
+		('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c >= 0x80
+}
+
+// tokenizeCQL splits the statement into words, quoted literals and
+// punctuation, skipping whitespace and comments.
+func tokenizeCQL(stmt string) []cqlToken {
+	var (
+		tokens []cqlToken
+		depth  int
+	)
+	for i := 0; i < len(stmt); {
+		c := stmt[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case strings.HasPrefix(stmt[i:], "--") || strings.HasPrefix(stmt[i:], "//"):
+			if n := strings.IndexByte(stmt[i:], '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(stmt)
+			}
+			continue
+		case strings.HasPrefix(stmt[i:], "/*"):
+			if n := strings.Index(stmt[i+2:], "*/"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(stmt)
+			}
+			continue
+		case c == '\'' || c == '"':
+			// quotes are escaped by doubling them
+			i++
+			for i < len(stmt) {
+				if stmt[i] == c {
+					if i+1 < len(stmt) && stmt[i+1] == c {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+		case strings.HasPrefix(stmt[i:], "$$"):
+			if n := strings.Index(stmt[i+2:], "$$"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(stmt)
+			}
+		case isCQLWordChar(c):
+			for i < len(stmt) && isCQLWordChar(stmt[i]) {
+				i++
+			}
+		default:
+			i++
+		}
+
+		if c == ')' || c == ']' || c == '}' {
+			depth--
+		}
+		tokens = append(tokens, cqlToken{text: stmt[start:i], start: start, end: i, depth: depth})
+		if c == '(' || c == '[' || c == '{' {
+			depth++
+		}
+	}
+	return tokens
+}
+
+// formatCQLDuration formats the duration as a CQL duration literal with
+// millisecond precision, rounding up.
+func formatCQLDuration(d time.Duration) string {
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	if ms%1000 == 0 {
+		return strconv.FormatInt(int64(ms/1000), 10) + "s"
+	}
+	return strconv.FormatInt(int64(ms), 10) + "ms"
+}
+
+// setUsingTimeout adds or replaces the Scylla USING TIMEOUT clause of a
+// SELECT, INSERT, UPDATE, DELETE or BATCH statement. ok is false for other
+// statements, which do not support it.
+func setUsingTimeout(stmt string, timeout time.Duration) (_ string, ok bool) {
+	tokens := tokenizeCQL(stmt)
+	if len(tokens) == 0 {
+		return stmt, false
+	}
+	literal := formatCQLDuration(timeout)
+	insert := func(pos int, s string) string {
+		return stmt[:pos] + s + stmt[pos:]
+	}
+
+	// replace the timeout of an existing clause
+	for i := 1; i+1 < len(tokens); i++ {
+		if tokens[i].is("TIMEOUT") && (tokens[i-1].is("USING") || tokens[i-1].is("AND")) {
+			return stmt[:tokens[i+1].start] + literal + stmt[tokens[i+1].end:], true
+		}
+	}
+
+	find := func(keyword string) int {
+		for i, t := range tokens {
+			if t.is(keyword) {
+				return i
+			}
+		}
+		return -1
+	}
+	using := find("USING")
+
+	switch kind := strings.ToUpper(tokens[0].text); kind {
+	case "SELECT":
+		q := &Query{stmt: stmt}
+		return q.Hint("USING TIMEOUT " + literal).stmt, true
+	case "INSERT", "UPDATE", "DELETE":
+		if using >= 0 {
+			return insert(tokens[using].end, " TIMEOUT "+literal+" AND"), true
+		}
+		if kind == "INSERT" {
+			q := &Query{stmt: stmt}
+			return q.Hint("USING TIMEOUT " + literal).stmt, true
+		}
+		next := "SET"
+		if kind == "DELETE" {
+			next = "WHERE"
+		}
+		if i := find(next); i >= 0 {
+			return insert(tokens[i].start, "USING TIMEOUT "+literal+" "), true
+		}
+	case "BEGIN":
+		if i := find("BATCH"); i >= 0 {
+			if i+1 < len(tokens) && tokens[i+1].is("USING") {
+				return insert(tokens[i+1].end, " TIMEOUT "+literal+" AND"), true
+			}
+			return insert(tokens[i].end, " USING TIMEOUT "+literal), true
+		}
+	}
+	return stmt, false
+}
+
+type requestTimeoutKey struct{}
+
+// serverTimeoutMargin is added to the server side timeout of a query to get
+// the client side timeout, so the timeout error of the server is received.
+const serverTimeoutMargin = time.Second
+
+// requestTimeout returns the client side timeout of a request, which is the
+// timeout of the connection unless the request has a longer server side timeout.
+func (c *Conn) requestTimeout(ctx context.Context) time.Duration {
+	if c.timeout <= 0 {
+		return c.timeout
+	}
+	if d, ok := ctx.Value(requestTimeoutKey{}).(time.Duration); ok && d+serverTimeoutMargin > c.timeout {
+		return d + serverTimeoutMargin
+	}
+	return c.timeout
+}
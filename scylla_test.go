@@ -0,0 +1,633 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseScyllaSupported(t *testing.T) {
+	si := parseScyllaSupported(map[string][]string{
+		scyllaShard:             {"3"},
+		scyllaNrShards:          {"12"},
+		scyllaPartitioner:       {"org.apache.cassandra.dht.Murmur3Partitioner"},
+		scyllaShardingAlgorithm: {"biased-token-round-robin"},
+		scyllaShardingIgnoreMSB: {"12"},
+		scyllaShardAwarePort:    {"19042"},
+		scyllaShardAwarePortSSL: {"19142"},
+	})
+
+	expected := scyllaSupported{
+		shard:             3,
+		nrShards:          12,
+		msbIgnore:         12,
+		partitioner:       "org.apache.cassandra.dht.Murmur3Partitioner",
+		shardingAlgorithm: "biased-token-round-robin",
+		shardAwarePort:    19042,
+		shardAwarePortSSL: 19142,
+	}
+	if si != expected {
+		t.Fatalf("expected %+v got %+v", expected, si)
+	}
+	if !si.isShardAware() {
+		t.Fatal("expected node to be shard aware")
+	}
+
+	if si := parseScyllaSupported(map[string][]string{"COMPRESSION": {"snappy"}}); si != (scyllaSupported{}) {
+		t.Fatalf("expected no sharding information for Cassandra, got %+v", si)
+	}
+	if si := parseScyllaSupported(map[string][]string{scyllaShard: {"4"}, scyllaNrShards: {"4"}}); si != (scyllaSupported{}) {
+		t.Fatalf("expected invalid shard to be ignored, got %+v", si)
+	}
+}
+
+func TestScyllaShardForToken(t *testing.T) {
+	si := scyllaSupported{nrShards: 4}
+	tests := []struct {
+		token int64
+		shard int
+	}{
+		{math.MinInt64, 0},
+		{-1, 1},
+		{0, 2},
+		{math.MaxInt64, 3},
+	}
+	for _, test := range tests {
+		if shard := si.shardForToken(test.token); shard != test.shard {
+			t.Errorf("token %d: expected shard %d got %d", test.token, test.shard, shard)
+		}
+	}
+
+	// the ignored most significant bits spread consecutive token ranges over all shards
+	si.msbIgnore = 2
+	if shard := si.shardForToken(0); shard != 0 {
+		t.Errorf("expected shard 0 got %d", shard)
+	}
+	if shard := si.shardForToken(math.MinInt64 / 4); shard != 2 {
+		t.Errorf("expected shard 2 got %d", shard)
+	}
+}
+
+func TestShardPorts(t *testing.T) {
+	for _, nrShards := range []int{1, 3, 7, 16} {
+		for shard := 0; shard < nrShards; shard++ {
+			first := firstShardPort(shard, nrShards)
+			if first < shardAwarePortLow || first >= shardAwarePortLow+nrShards || first%nrShards != shard {
+				t.Fatalf("shard %d/%d: invalid first port %d", shard, nrShards, first)
+			}
+			for i := 0; i < 100; i++ {
+				port := randomShardPort(shard, nrShards)
+				if port < shardAwarePortLow || port > shardAwarePortHigh || port%nrShards != shard {
+					t.Fatalf("shard %d/%d: invalid port %d", shard, nrShards, port)
+				}
+			}
+		}
+	}
+}
+
+// newShardAwareTestServers starts a test server of a Scylla node with
+// nrShards shards and the server of its shard-aware port, counting the
+// connections to the shard-aware port. The shard of a connection is its
+// local port modulo nrShards.
+func newShardAwareTestServers(t *testing.T, nrShards int) (srv, shardSrv *TestServer, shardPortConns *int32) {
+	shardOf := func(conn net.Conn) string {
+		return strconv.Itoa(conn.RemoteAddr().(*net.TCPAddr).Port % nrShards)
+	}
+
+	shardPortConns = new(int32)
+	shardSrv = newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		supported: func(conn net.Conn) map[string][]string {
+			atomic.AddInt32(shardPortConns, 1)
+			return map[string][]string{
+				scyllaShard:    {shardOf(conn)},
+				scyllaNrShards: {strconv.Itoa(nrShards)},
+			}
+		},
+	}.newServer(t, context.Background())
+
+	_, shardPort, err := net.SplitHostPort(shardSrv.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv = newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		supported: func(conn net.Conn) map[string][]string {
+			return map[string][]string{
+				scyllaShard:          {shardOf(conn)},
+				scyllaNrShards:       {strconv.Itoa(nrShards)},
+				scyllaShardAwarePort: {shardPort},
+			}
+		},
+	}.newServer(t, context.Background())
+	return srv, shardSrv, shardPortConns
+}
+
+// fillPool waits for the pool of the single host of db to be filled with n
+// connections.
+func fillPool(t *testing.T, db *Session, n int) *hostConnPool {
+	pool, ok := db.pool.getPool(db.ring.allHosts()[0])
+	if !ok {
+		t.Fatal("no pool for host")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.Size() < n && time.Now().Before(deadline) {
+		// picking a connection fills the pool
+		pool.Pick()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if size := pool.Size(); size != n {
+		t.Fatalf("expected %d connections got %d", n, size)
+	}
+	return pool
+}
+
+func TestShardAwarePortPool(t *testing.T) {
+	const nrShards = 4
+	srv, shardSrv, shardPortConns := newShardAwareTestServers(t, nrShards)
+	defer srv.Stop()
+	defer shardSrv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 1
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	pool := fillPool(t, db, nrShards)
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	var shards [nrShards]int
+	for _, conn := range pool.conns {
+		shards[conn.scyllaSupported.shard]++
+	}
+	for shard, n := range shards {
+		if n != 1 {
+			t.Fatalf("expected one connection to shard %d got %d: %v", shard, n, shards)
+		}
+	}
+	if n := atomic.LoadInt32(shardPortConns); n != nrShards-1 {
+		t.Fatalf("expected %d connections through the shard-aware port got %d", nrShards-1, n)
+	}
+	if !pool.shardPortDisabledUntil.IsZero() {
+		t.Fatal("expected shard-aware port to remain enabled")
+	}
+}
+
+// failingShardDialer fails the first fails connections to the shard-aware
+// port with err.
+type failingShardDialer struct {
+	*defaultHostDialer
+	err   error
+	fails int32
+}
+
+func (d *failingShardDialer) dialShard(ctx context.Context, host *HostInfo, target shardTarget) (*DialedHost, error) {
+	if atomic.AddInt32(&d.fails, -1) >= 0 {
+		return nil, d.err
+	}
+	return d.defaultHostDialer.dialShard(ctx, host, target)
+}
+
+func TestShardAwarePortTransientError(t *testing.T) {
+	const nrShards = 4
+	srv, shardSrv, shardPortConns := newShardAwareTestServers(t, nrShards)
+	defer srv.Stop()
+	defer shardSrv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 1
+	cluster.HostDialer = &failingShardDialer{
+		defaultHostDialer: &defaultHostDialer{dialer: &net.Dialer{Timeout: cluster.ConnectTimeout}},
+		err:               &net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded},
+		fails:             1,
+	}
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	// the connection which failed falls back to the regular port
+	pool := fillPool(t, db, nrShards)
+	pool.mu.RLock()
+	disabled := !pool.shardPortDisabledUntil.IsZero()
+	pool.mu.RUnlock()
+	if disabled {
+		t.Fatal("expected shard-aware port to remain enabled after a timeout")
+	}
+	if n := atomic.LoadInt32(shardPortConns); n < nrShards-2 {
+		t.Fatalf("expected at least %d connections through the shard-aware port got %d", nrShards-2, n)
+	}
+}
+
+func TestShardAwarePortBackoff(t *testing.T) {
+	tests := []struct {
+		err         error
+		unreachable bool
+	}{
+		{errShardAwarePortUnavailable, true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.EADDRINUSE)}, false},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded}, false},
+		{errors.New("handshake failed"), false},
+	}
+	for _, test := range tests {
+		if unreachable := shardPortUnreachable(test.err); unreachable != test.unreachable {
+			t.Errorf("%v: expected unreachable=%v", test.err, test.unreachable)
+		}
+	}
+
+	clock := &recordingClock{now: time.Unix(1, 0)}
+	pool := &hostConnPool{
+		session: &Session{cfg: ClusterConfig{
+			Clock:              clock,
+			ReconnectionPolicy: &ExponentialReconnectionPolicy{MaxRetries: 3, InitialInterval: time.Second, MaxInterval: time.Minute},
+		}},
+		host:     &HostInfo{connectAddress: net.IPv4(127, 0, 0, 1)},
+		sharding: scyllaSupported{nrShards: 4, shardAwarePort: 19042},
+		logger:   &testLogger{},
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		pool.disableShardPortLocked("connection refused")
+		if target := pool.reserveShard(); target != nil {
+			t.Fatalf("attempt %d: expected the shard-aware port to be disabled", attempt)
+		}
+		// the port is used again after the interval of the attempt
+		clock.now = pool.shardPortDisabledUntil
+		if target := pool.reserveShard(); target == nil {
+			t.Fatalf("attempt %d: expected the shard-aware port to be enabled again", attempt)
+		}
+		pool.pendingShards = nil
+	}
+	if pool.shardPortFailures != 2 {
+		t.Fatalf("expected 2 consecutive failures got %d", pool.shardPortFailures)
+	}
+}
+
+func TestScyllaRateLimitError(t *testing.T) {
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		supported: func(conn net.Conn) map[string][]string {
+			return map[string][]string{
+				scyllaRateLimitError: {"ERROR_CODE=61440"},
+			}
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	rt := &testRetryPolicy{NumRetries: 2}
+	tests := []struct {
+		stmt       string
+		idempotent bool
+		opType     OpType
+		attempts   int64
+	}{
+		{"ratelimit read", false, OpTypeRead, 3},
+		{"ratelimit write", false, OpTypeWrite, 1},
+		{"ratelimit write", true, OpTypeWrite, 3},
+	}
+	for _, test := range tests {
+		atomic.StoreInt64(&srv.nKillReq, 0)
+
+		err := db.Query(test.stmt).Idempotent(test.idempotent).RetryPolicy(rt).Exec()
+		rl, ok := err.(*RequestErrRateLimitReached)
+		if !ok {
+			t.Fatalf("%s: expected *RequestErrRateLimitReached got %v", test.stmt, err)
+		}
+		if rl.OpType != test.opType || !rl.RejectedByCoordinator {
+			t.Fatalf("%s: unexpected error details %s", test.stmt, rl)
+		}
+		if n := atomic.LoadInt64(&srv.nKillReq); n != test.attempts {
+			t.Fatalf("%s (idempotent=%v): expected %d attempts got %d", test.stmt, test.idempotent, test.attempts, n)
+		}
+	}
+}
+
+type shardObserver struct {
+	mu       sync.Mutex
+	connects []int
+	queries  []int
+}
+
+func (o *shardObserver) ObserveConnect(c ObservedConnect) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.connects = append(o.connects, c.Shard)
+}
+
+func (o *shardObserver) ObserveQuery(ctx context.Context, q ObservedQuery) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.queries = append(o.queries, q.Shard)
+}
+
+func TestObservedShard(t *testing.T) {
+	tests := []struct {
+		name      string
+		supported map[string][]string
+		shard     int
+	}{
+		{"cassandra", nil, -1},
+		{"scylla", map[string][]string{scyllaShard: {"0"}, scyllaNrShards: {"1"}}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newTestServerOpts{
+				addr:     "127.0.0.1:0",
+				protocol: defaultProto,
+				supported: func(conn net.Conn) map[string][]string {
+					return test.supported
+				},
+			}.newServer(t, context.Background())
+			defer srv.Stop()
+
+			observer := &shardObserver{}
+			cluster := testCluster(defaultProto, srv.Address)
+			cluster.ConnectObserver = observer
+			cluster.QueryObserver = observer
+			db, err := cluster.CreateSession()
+			if err != nil {
+				t.Fatalf("NewCluster: %v", err)
+			}
+			defer db.Close()
+
+			if err := db.Query("void").Exec(); err != nil {
+				t.Fatal(err)
+			}
+
+			observer.mu.Lock()
+			defer observer.mu.Unlock()
+			if len(observer.connects) == 0 || len(observer.queries) != 1 {
+				t.Fatalf("expected connections and one query to be observed, got %v and %v", observer.connects, observer.queries)
+			}
+			for _, shard := range append(observer.connects, observer.queries...) {
+				if shard != test.shard {
+					t.Fatalf("expected shard %d got connections %v and queries %v", test.shard, observer.connects, observer.queries)
+				}
+			}
+		})
+	}
+}
+
+func TestSetUsingTimeout(t *testing.T) {
+	tests := []struct {
+		stmt     string
+		timeout  time.Duration
+		expected string
+	}{
+		{"SELECT * FROM t WHERE k = ?", 500 * time.Millisecond, "SELECT * FROM t WHERE k = ? USING TIMEOUT 500ms"},
+		{"select * from t bypass cache;", 2 * time.Second, "select * from t bypass cache USING TIMEOUT 2s"},
+		{"SELECT * FROM t USING TIMEOUT 1s", 3 * time.Second, "SELECT * FROM t USING TIMEOUT 3s"},
+		{"SELECT * FROM t", 1500 * time.Microsecond, "SELECT * FROM t USING TIMEOUT 2ms"},
+		{"INSERT INTO t (k, v) VALUES (?, 'using')", time.Second, "INSERT INTO t (k, v) VALUES (?, 'using') USING TIMEOUT 1s"},
+		{"INSERT INTO t (k, v) VALUES (?, ?) USING TTL 10", time.Second, "INSERT INTO t (k, v) VALUES (?, ?) USING TIMEOUT 1s AND TTL 10"},
+		{"INSERT INTO t (k, v) VALUES (?, ?) USING TTL 10 AND TIMEOUT 5s", time.Second, "INSERT INTO t (k, v) VALUES (?, ?) USING TTL 10 AND TIMEOUT 1s"},
+		{"UPDATE t SET v = ? WHERE k = ?", time.Second, "UPDATE t USING TIMEOUT 1s SET v = ? WHERE k = ?"},
+		{"UPDATE t USING TTL 10 SET v = ? WHERE k = ?", time.Second, "UPDATE t USING TIMEOUT 1s AND TTL 10 SET v = ? WHERE k = ?"},
+		{`DELETE "where" FROM t WHERE k = ?`, time.Second, `DELETE "where" FROM t USING TIMEOUT 1s WHERE k = ?`},
+		{"BEGIN BATCH INSERT INTO t (k) VALUES (1); APPLY BATCH", time.Second, "BEGIN BATCH USING TIMEOUT 1s INSERT INTO t (k) VALUES (1); APPLY BATCH"},
+		{"BEGIN UNLOGGED BATCH USING TIMESTAMP 1 INSERT INTO t (k) VALUES (1); APPLY BATCH", time.Second, "BEGIN UNLOGGED BATCH USING TIMEOUT 1s AND TIMESTAMP 1 INSERT INTO t (k) VALUES (1); APPLY BATCH"},
+		{"CREATE TABLE t (k int PRIMARY KEY)", time.Second, ""},
+		{"TRUNCATE t", time.Second, ""},
+	}
+	for _, test := range tests {
+		stmt, ok := setUsingTimeout(test.stmt, test.timeout)
+		if test.expected == "" {
+			if ok || stmt != test.stmt {
+				t.Errorf("%q: expected statement to be unsupported, got %q", test.stmt, stmt)
+			}
+			continue
+		}
+		if !ok || stmt != test.expected {
+			t.Errorf("%q: expected %q got %q (%v)", test.stmt, test.expected, stmt, ok)
+		}
+	}
+
+	q := (&Query{stmt: "SELECT * FROM t"}).ServerTimeout(time.Minute)
+	if q.Statement() != "SELECT * FROM t USING TIMEOUT 60s" || q.serverTimeout != time.Minute {
+		t.Fatalf("unexpected query %q with server timeout %v", q.Statement(), q.serverTimeout)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	c := &Conn{timeout: 10 * time.Second}
+	ctx := context.Background()
+	if d := c.requestTimeout(ctx); d != c.timeout {
+		t.Fatalf("expected connection timeout got %v", d)
+	}
+	if d := c.requestTimeout(context.WithValue(ctx, requestTimeoutKey{}, time.Second)); d != c.timeout {
+		t.Fatalf("expected connection timeout for a shorter server timeout got %v", d)
+	}
+	if d := c.requestTimeout(context.WithValue(ctx, requestTimeoutKey{}, time.Minute)); d != time.Minute+serverTimeoutMargin {
+		t.Fatalf("expected extended timeout got %v", d)
+	}
+	c.timeout = 0
+	if d := c.requestTimeout(context.WithValue(ctx, requestTimeoutKey{}, time.Minute)); d != 0 {
+		t.Fatalf("expected no timeout got %v", d)
+	}
+}
+
+func TestServiceLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		supported map[string][]string
+		expected  string
+	}{
+		{"supported", map[string][]string{scyllaServiceLevel: {}}, "analytics"},
+		{"unsupported", nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				startup []map[string]string
+			)
+			srv := newTestServerOpts{
+				addr:     "127.0.0.1:0",
+				protocol: defaultProto,
+				recvHook: func(f *framer) {
+					if f.header.op != opStartup {
+						return
+					}
+					opts := make(map[string]string)
+					for n := f.readShort(); n > 0; n-- {
+						k := f.readString()
+						opts[k] = f.readString()
+					}
+					mu.Lock()
+					startup = append(startup, opts)
+					mu.Unlock()
+				},
+				supported: func(conn net.Conn) map[string][]string {
+					return test.supported
+				},
+			}.newServer(t, context.Background())
+			defer srv.Stop()
+
+			cluster := testCluster(defaultProto, srv.Address)
+			cluster.ServiceLevel = "analytics"
+			cluster.Logger = &testLogger{}
+			db, err := cluster.CreateSession()
+			if err != nil {
+				t.Fatalf("NewCluster: %v", err)
+			}
+			defer db.Close()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(startup) == 0 {
+				t.Fatal("no startup frame received")
+			}
+			for _, opts := range startup {
+				if sl, ok := opts[scyllaServiceLevel]; sl != test.expected || ok != (test.expected != "") {
+					t.Fatalf("expected service level %q got %v", test.expected, opts)
+				}
+			}
+			if f := db.ring.allHosts()[0].Features(); f.ServiceLevel != (test.expected != "") {
+				t.Fatalf("expected the service level feature to be %v got %+v", test.expected != "", f)
+			}
+		})
+	}
+}
+
+func TestHostFeatures(t *testing.T) {
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		supported: func(conn net.Conn) map[string][]string {
+			return map[string][]string{
+				scyllaShard:              {"0"},
+				scyllaNrShards:           {"1"},
+				scyllaPartitioner:        {"org.apache.cassandra.dht.Murmur3Partitioner"},
+				scyllaShardingAlgorithm:  {"biased-token-round-robin"},
+				scyllaRateLimitError:     {"ERROR_CODE=61440"},
+				scyllaLWTAddMetadataMark: {"LWT_OPTIMIZATION_META_BIT_MASK=2147483648"},
+				scyllaTabletsRoutingV1:   {},
+				scyllaServiceLevel:       {},
+			}
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	// no service level is requested
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	host := db.ring.allHosts()[0]
+	f := host.Features()
+	if !f.ShardAware || f.Shards != 1 || !f.RateLimitError || !f.TabletsRouting || f.ServiceLevel {
+		t.Fatalf("unexpected features %+v", f)
+	}
+	if f.Compression != "" || f.ShardAwarePort != 0 || len(f.Supported) != 8 {
+		t.Fatalf("unexpected features %+v", f)
+	}
+
+	// the features returned are copies
+	f.Supported[scyllaShard][0] = "1"
+	delete(f.Supported, scyllaNrShards)
+	if f := host.Features(); f.Supported[scyllaShard][0] != "0" || len(f.Supported) != 8 {
+		t.Fatalf("expected the features of the host to be unchanged got %+v", f)
+	}
+
+	if f := (&HostInfo{}).Features(); f.Supported != nil {
+		t.Fatalf("expected no features before connecting got %+v", f)
+	}
+}
+
+func TestShardRebalance(t *testing.T) {
+	const nrShards = 4
+	// the first connections all land on the first two shards, the following
+	// ones on the shards with no connections
+	assigned := []string{"0", "0", "1", "1", "2", "3"}
+	var (
+		mu    sync.Mutex
+		conns int
+	)
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: defaultProto,
+		supported: func(conn net.Conn) map[string][]string {
+			mu.Lock()
+			defer mu.Unlock()
+			shard := strconv.Itoa(conns % nrShards)
+			if conns < len(assigned) {
+				shard = assigned[conns]
+			}
+			conns++
+			return map[string][]string{
+				scyllaShard:    {shard},
+				scyllaNrShards: {strconv.Itoa(nrShards)},
+			}
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 1
+	cluster.DisableShardAwarePort = true
+	cluster.ReconnectionPolicy = &ConstantReconnectionPolicy{MaxRetries: 3, Interval: 10 * time.Millisecond}
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	pool, ok := db.pool.getPool(db.ring.allHosts()[0])
+	if !ok {
+		t.Fatal("no pool for host")
+	}
+
+	shards := func() [nrShards]int {
+		pool.mu.RLock()
+		defer pool.mu.RUnlock()
+		var shards [nrShards]int
+		for _, conn := range pool.conns {
+			shards[conn.scyllaSupported.shard]++
+		}
+		return shards
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for shards() != [nrShards]int{1, 1, 1, 1} {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected one connection per shard got %v", shards())
+		}
+		pool.Pick()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// only the two surplus connections are replaced
+	mu.Lock()
+	defer mu.Unlock()
+	if conns != len(assigned) {
+		t.Fatalf("expected %d connections got %d", len(assigned), conns)
+	}
+}
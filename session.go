@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -45,12 +46,15 @@ type Session struct {
 	hostSource          *ringDescriber
 	ringRefresher       *refreshDebouncer
 	stmtsLRU            *preparedLRU
+	tablets             *tabletMap
 
 	connCfg *ConnConfig
 
 	executor *queryExecutor
-	pool     *policyConnPool
-	policy   HostSelectionPolicy
+	// handleQuery executes queries and batches through the interceptors.
+	handleQuery QueryHandler
+	pool        *policyConnPool
+	policy      HostSelectionPolicy
 
 	ring     ring
 	metadata clusterMetadata
@@ -63,6 +67,11 @@ type Session struct {
 	nodeEvents   *eventDebouncer
 	schemaEvents *eventDebouncer
 
+	nodeEventSubscribers nodeEventSubscribers
+
+	errorStats   sessionErrorStats
+	latencyStats sessionLatencyStats
+
 	// ring metadata
 	useSystemSchema           bool
 	hasAggregatesAndFunctions bool
@@ -97,7 +106,8 @@ func addrsToHosts(addrs []string, defaultPort int, logger StdLogger) ([]*HostInf
 		resolvedHosts, err := hostInfo(hostaddr, defaultPort)
 		if err != nil {
 			// Try other hosts if unable to resolve DNS name
-			if _, ok := err.(*net.DNSError); ok {
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) {
 				logger.Printf("gocql: dns error: %v\n", err)
 				continue
 			}
@@ -124,6 +134,15 @@ func NewSession(cfg ClusterConfig) (*Session, error) {
 		return nil, errors.New("Can't use both Authenticator and AuthProvider in cluster config.")
 	}
 
+	switch cfg.SerialConsistency {
+	case 0, Serial, LocalSerial:
+	default:
+		return nil, fmt.Errorf("gocql: invalid serial consistency %v, must be SERIAL or LOCAL_SERIAL", cfg.SerialConsistency)
+	}
+	if err := cfg.validateTableConsistency(); err != nil {
+		return nil, err
+	}
+
 	// TODO: we should take a context in here at some point
 	ctx, cancel := context.WithCancel(context.TODO())
 
@@ -132,7 +151,8 @@ func NewSession(cfg ClusterConfig) (*Session, error) {
 		prefetch:        0.25,
 		cfg:             cfg,
 		pageSize:        cfg.PageSize,
-		stmtsLRU:        &preparedLRU{lru: lru.New(cfg.MaxPreparedStmts)},
+		stmtsLRU:        newPreparedLRU(cfg.MaxPreparedStmts, cfg.MaxPreparedStmtsPerKeyspace, cfg.PreparedStatementEvicted),
+		tablets:         newTabletMap(),
 		connectObserver: cfg.ConnectObserver,
 		ctx:             ctx,
 		cancel:          cancel,
@@ -141,13 +161,20 @@ func NewSession(cfg ClusterConfig) (*Session, error) {
 
 	s.schemaDescriber = newSchemaDescriber(s)
 
-	s.nodeEvents = newEventDebouncer("NodeEvents", s.handleNodeEvent, s.logger)
-	s.schemaEvents = newEventDebouncer("SchemaEvents", s.handleSchemaEvent, s.logger)
+	debounceWindow, maxDebounceDelay := cfg.Events.DebounceWindow, cfg.Events.MaxDebounceDelay
+	if debounceWindow <= 0 {
+		debounceWindow = eventDebounceTime
+	}
+	if maxDebounceDelay <= 0 {
+		maxDebounceDelay = eventMaxDebounceDelay
+	}
+	s.nodeEvents = newEventDebouncer("NodeEvents", debounceWindow, maxDebounceDelay, cfg.clock(), s.handleNodeEvent, s.logger)
+	s.schemaEvents = newEventDebouncer("SchemaEvents", eventDebounceTime, 0, cfg.clock(), s.handleSchemaEvent, s.logger)
 
 	s.routingKeyInfoCache.lru = lru.New(cfg.MaxRoutingKeyInfo)
 
 	s.hostSource = &ringDescriber{session: s}
-	s.ringRefresher = newRefreshDebouncer(ringRefreshDebounceTime, func() error { return refreshRing(s.hostSource) })
+	s.ringRefresher = newRefreshDebouncer(debounceWindow, maxDebounceDelay, func() error { return refreshRing(s.hostSource) })
 
 	if cfg.PoolConfig.HostSelectionPolicy == nil {
 		cfg.PoolConfig.HostSelectionPolicy = RoundRobinHostPolicy()
@@ -161,6 +188,7 @@ func NewSession(cfg ClusterConfig) (*Session, error) {
 		pool:   s.pool,
 		policy: cfg.PoolConfig.HostSelectionPolicy,
 	}
+	s.handleQuery = s.queryHandler(cfg.QueryInterceptors)
 
 	s.queryObserver = cfg.QueryObserver
 	s.batchObserver = cfg.BatchObserver
@@ -316,6 +344,10 @@ func (s *Session) init() error {
 		go s.reconnectDownedHosts(s.cfg.ReconnectInterval)
 	}
 
+	if s.cfg.RingRefreshInterval > 0 && !s.cfg.disableControlConn {
+		go s.refreshRingPeriodically(s.cfg.RingRefreshInterval)
+	}
+
 	// If we disable the initial host lookup, we need to still check if the
 	// cluster is using the newer system schema or not... however, if control
 	// connection is disable, we really have no choice, so we just make our
@@ -362,12 +394,12 @@ func (s *Session) AwaitSchemaAgreement(ctx context.Context) error {
 }
 
 func (s *Session) reconnectDownedHosts(intv time.Duration) {
-	reconnectTicker := time.NewTicker(intv)
+	reconnectTicker := s.cfg.clock().NewTicker(intv)
 	defer reconnectTicker.Stop()
 
 	for {
 		select {
-		case <-reconnectTicker.C:
+		case <-reconnectTicker.C():
 			hosts := s.ring.allHosts()
 
 			// Print session.ring for debug.
@@ -392,6 +424,44 @@ func (s *Session) reconnectDownedHosts(intv time.Duration) {
 	}
 }
 
+func (s *Session) refreshRingPeriodically(intv time.Duration) {
+	refreshTicker := s.cfg.clock().NewTicker(intv)
+	defer refreshTicker.Stop()
+
+	for {
+		select {
+		case <-refreshTicker.C():
+			if err := s.refreshRing(); err != nil {
+				s.logger.Printf("gocql: unable to refresh ring: %v\n", err)
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// RefreshRing refreshes the hosts of the ring from system.local and
+// system.peers now, adding the new hosts and removing those which left the
+// cluster, and waits for the refresh to complete or ctx to be done. Pending
+// refreshes, such as after topology events, are merged into this one.
+func (s *Session) RefreshRing(ctx context.Context) error {
+	if s.Closed() {
+		return ErrSessionClosed
+	}
+	if s.control == nil {
+		return errNoControl
+	}
+	select {
+	case err, ok := <-s.ringRefresher.refreshNow():
+		if !ok {
+			return ErrSessionClosed
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // SetConsistency sets the default consistency level for this session. This
 // setting can also be changed on a per-query basis and the default value
 // is Quorum.
@@ -440,11 +510,27 @@ func (s *Session) Query(stmt string, values ...interface{}) *Query {
 	return qry
 }
 
+// QueryContext is like Query with the context of the query set to ctx, as
+// with Query.WithContext.
+func (s *Session) QueryContext(ctx context.Context, stmt string, values ...interface{}) *Query {
+	qry := s.Query(stmt, values...)
+	qry.context = ctx
+	return qry
+}
+
 type QueryInfo struct {
 	Id          []byte
 	Args        []ColumnInfo
 	Rval        []ColumnInfo
 	PKeyColumns []int
+	// Markers are the bind markers of the statement, in the order of Args,
+	// nil if they could not be told from the statement.
+	Markers []BindMarker
+}
+
+// Named reports whether the statement uses named bind markers.
+func (q *QueryInfo) Named() bool {
+	return len(q.Markers) > 0 && q.Markers[0].Named
 }
 
 // Bind generates a new query object based on the query statement passed in.
@@ -498,6 +584,8 @@ func (s *Session) Close() {
 		s.cancel()
 	}
 
+	s.nodeEventSubscribers.close()
+
 	s.sessionStateMu.Lock()
 	s.isClosed = true
 	s.sessionStateMu.Unlock()
@@ -510,6 +598,77 @@ func (s *Session) Closed() bool {
 	return closed
 }
 
+// PreparedCacheStats returns the statistics of the prepared statement cache of the session.
+func (s *Session) PreparedCacheStats() PreparedCacheStats {
+	return s.stmtsLRU.stats()
+}
+
+// PreparedStatements lists the statements of the prepared statement cache of
+// the session, for every host and keyspace they are prepared on, from the most
+// to the least recently used.
+func (s *Session) PreparedStatements() []PreparedStatementInfo {
+	return s.stmtsLRU.statements()
+}
+
+// InvalidatePrepared removes stmt from the prepared statement cache, for all
+// hosts and keyspaces, so that it is prepared again on its next execution. It
+// returns the number of removed entries. Tools altering tables out of band can
+// use it instead of relying on the UNPREPARED errors of the next executions.
+func (s *Session) InvalidatePrepared(stmt string) int {
+	return s.stmtsLRU.removeStatements(func(cached string) bool {
+		return cached == stmt
+	})
+}
+
+// InvalidateKeyspacePrepared removes the statements prepared in keyspace from
+// the prepared statement cache, so that they are prepared again on their next
+// execution. It returns the number of removed entries.
+func (s *Session) InvalidateKeyspacePrepared(keyspace string) int {
+	return s.stmtsLRU.removeKeyspace(keyspace)
+}
+
+// prepareOnAllHosts prepares stmt in keyspace on the up hosts other than
+// origin, on at most PrepareOnAllHostsConcurrency hosts at a time. Failures
+// are logged, the statement is then prepared on its first use on the host.
+func (s *Session) prepareOnAllHosts(origin *HostInfo, keyspace, stmt string) {
+	concurrency := s.cfg.PrepareOnAllHostsConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for _, host := range s.ring.allHosts() {
+		if host.HostID() == origin.HostID() || !host.IsUp() {
+			continue
+		}
+		pool, ok := s.pool.getPool(host)
+		if !ok {
+			continue
+		}
+		conn := pool.Pick()
+		if conn == nil || conn.currentKeyspace != keyspace {
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-s.ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(conn *Conn) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if _, err := conn.prepareStatement(s.ctx, stmt, nil); err != nil && s.ctx.Err() == nil {
+				s.logger.Printf("gocql: unable to prepare %q on %s: %v\n", stmt, conn.host.ConnectAddress(), err)
+			}
+		}(conn)
+	}
+	wg.Wait()
+}
+
 func (s *Session) initialized() bool {
 	s.sessionStateMu.RLock()
 	initialized := s.isInitialized
@@ -522,23 +681,40 @@ func (s *Session) executeQuery(qry *Query) (it *Iter) {
 	if s.Closed() {
 		return &Iter{err: ErrSessionClosed}
 	}
+	qry.metrics.resetFailures()
 
-	iter, err := s.executor.executeQuery(qry)
-	if err != nil {
-		return &Iter{err: err}
+	iter := s.handleQuery(qry.Context(), qry)
+	return s.withErrorContext(qry, iter)
+}
+
+// executeQueryAsync is like executeQuery, but it calls done with the
+// iterator instead of returning it. The interceptors are synchronous, so
+// when there are any the query is executed on a goroutine of its own.
+func (s *Session) executeQueryAsync(qry *Query, done func(*Iter)) {
+	if s.Closed() {
+		done(&Iter{err: ErrSessionClosed})
+		return
 	}
-	if iter == nil {
-		panic("nil iter")
+	if len(s.cfg.QueryInterceptors) > 0 {
+		go func() {
+			done(s.executeQuery(qry))
+		}()
+		return
 	}
+	qry.metrics.resetFailures()
 
-	return iter
+	s.executor.executeQueryAsync(qry, func(iter *Iter) {
+		done(s.withErrorContext(qry, iter))
+	})
 }
 
 func (s *Session) removeHost(h *HostInfo) {
+	s.nodeEventSubscribers.publish(NodeEventRemoved, h)
 	s.policy.RemoveHost(h)
 	hostID := h.HostID()
 	s.pool.removeHost(hostID)
 	s.ring.removeHost(hostID)
+	s.tablets.removeHost(hostID)
 }
 
 // KeyspaceMetadata returns the schema metadata for the keyspace specified. Returns an error if the keyspace does not exist.
@@ -553,6 +729,22 @@ func (s *Session) KeyspaceMetadata(keyspace string) (*KeyspaceMetadata, error) {
 	return s.schemaDescriber.getSchema(keyspace)
 }
 
+// viewBaseTable returns the name of the base table of the materialized view
+// keyspace.table, or "" if it is not a view or the metadata is unavailable.
+func (s *Session) viewBaseTable(keyspace, table string) string {
+	if s.control == nil || keyspace == "" {
+		return ""
+	}
+	keyspaceMetadata, err := s.KeyspaceMetadata(keyspace)
+	if err != nil {
+		return ""
+	}
+	if view, ok := keyspaceMetadata.MaterializedViews[table]; ok {
+		return view.baseTableName
+	}
+	return ""
+}
+
 func (s *Session) getConn() *Conn {
 	hosts := s.ring.allHosts()
 	for _, host := range hosts {
@@ -610,7 +802,7 @@ func (s *Session) routingKeyInfo(ctx context.Context, stmt string) (*routingKeyI
 	conn := s.getConn()
 	if conn == nil {
 		// TODO: better error?
-		inflight.err = errors.New("gocql: unable to fetch prepared info: no connection available")
+		inflight.err = errPreparedInfoNoConn
 		return nil, inflight.err
 	}
 
@@ -723,13 +915,10 @@ func (s *Session) executeBatch(batch *Batch) *Iter {
 	if batch.Size() > BatchSizeMaximum {
 		return &Iter{err: ErrTooManyStmts}
 	}
+	batch.metrics.resetFailures()
 
-	iter, err := s.executor.executeQuery(batch)
-	if err != nil {
-		return &Iter{err: err}
-	}
-
-	return iter
+	iter := s.handleQuery(batch.Context(), batch)
+	return s.withErrorContext(batch, iter)
 }
 
 // ExecuteBatch executes a batch operation and returns nil if successful
@@ -739,6 +928,12 @@ func (s *Session) ExecuteBatch(batch *Batch) error {
 	return iter.Close()
 }
 
+// ExecuteBatchContext is like ExecuteBatch with the context of the batch set
+// to ctx.
+func (s *Session) ExecuteBatchContext(ctx context.Context, batch *Batch) error {
+	return s.ExecuteBatch(batch.WithContext(ctx))
+}
+
 // ExecuteBatchCAS executes a batch operation and returns true if successful and
 // an iterator (to scan additional rows if more than one conditional statement)
 // was sent.
@@ -761,6 +956,12 @@ func (s *Session) ExecuteBatchCAS(batch *Batch, dest ...interface{}) (applied bo
 	return applied, iter, nil
 }
 
+// ExecuteBatchCASContext is like ExecuteBatchCAS with the context of the
+// batch set to ctx.
+func (s *Session) ExecuteBatchCASContext(ctx context.Context, batch *Batch, dest ...interface{}) (applied bool, iter *Iter, err error) {
+	return s.ExecuteBatchCAS(batch.WithContext(ctx), dest...)
+}
+
 // MapExecuteBatchCAS executes a batch operation much like ExecuteBatchCAS,
 // however it accepts a map rather than a list of arguments for the initial
 // scan.
@@ -780,6 +981,12 @@ func (s *Session) MapExecuteBatchCAS(batch *Batch, dest map[string]interface{})
 	return applied, iter, iter.err
 }
 
+// MapExecuteBatchCASContext is like MapExecuteBatchCAS with the context of
+// the batch set to ctx.
+func (s *Session) MapExecuteBatchCASContext(ctx context.Context, batch *Batch, dest map[string]interface{}) (applied bool, iter *Iter, err error) {
+	return s.MapExecuteBatchCAS(batch.WithContext(ctx), dest)
+}
+
 type hostMetrics struct {
 	// Attempts is count of how many times this query has been attempted for this host.
 	// An attempt is either a retry or fetching next page of results.
@@ -795,6 +1002,8 @@ type queryMetrics struct {
 	// totalAttempts is total number of attempts.
 	// Equal to sum of all hostMetrics' Attempts.
 	totalAttempts int
+	// failures are the errors of the failed attempts.
+	failures []HostAttemptError
 }
 
 // preFilledQueryMetrics initializes new queryMetrics based on per-host supplied data.
@@ -879,6 +1088,30 @@ func (qm *queryMetrics) attempt(addAttempts int, addLatency time.Duration,
 	return totalAttempts, hostMetricsCopy
 }
 
+// failed records the error of a failed attempt.
+func (qm *queryMetrics) failed(name string, host *HostInfo, attempt int, start, end time.Time, err error) {
+	if err == nil || err == ErrNotFound {
+		return
+	}
+	qm.l.Lock()
+	qm.failures = append(qm.failures, HostAttemptError{Host: host, Attempt: attempt, Name: name, Start: start, End: end, Err: err})
+	qm.l.Unlock()
+}
+
+// resetFailures forgets the errors of the attempts of the previous
+// executions, so that they do not accumulate on a reused query.
+func (qm *queryMetrics) resetFailures() {
+	qm.l.Lock()
+	qm.failures = nil
+	qm.l.Unlock()
+}
+
+func (qm *queryMetrics) attemptErrors() []HostAttemptError {
+	qm.l.RLock()
+	defer qm.l.RUnlock()
+	return append([]HostAttemptError(nil), qm.failures...)
+}
+
 // Query represents a CQL statement that can be executed.
 type Query struct {
 	stmt                  string
@@ -893,17 +1126,28 @@ type Query struct {
 	session               *Session
 	conn                  *Conn
 	rt                    RetryPolicy
+	maxRetries            int
+	limitRetries          bool
+	hostID                string
+	name                  string
+	tags                  map[string]string
+	routingToken          string
+	noTokenAwareRouting   bool
 	spec                  SpeculativeExecutionPolicy
 	binding               func(q *QueryInfo) ([]interface{}, error)
 	serialCons            SerialConsistency
 	defaultTimestamp      bool
 	defaultTimestampValue int64
+	timestampGenerator    TimestampGenerator
 	disableSkipMetadata   bool
+	prepareOnAllHosts     bool
+	validateValues        bool
 	context               context.Context
 	idempotent            bool
 	customPayload         map[string][]byte
 	metrics               *queryMetrics
 	refCount              uint32
+	serverTimeout         time.Duration
 
 	disableAutoPage bool
 
@@ -916,6 +1160,15 @@ type Query struct {
 
 	// routingInfo is a pointer because Query can be copied and copyable struct can't hold a mutex.
 	routingInfo *queryRoutingInfo
+
+	// err is returned by the execution of a query which can not be executed,
+	// such as a named query missing from the registry.
+	err error
+	// routingTokenErr is the error of parsing routingToken, returned by the
+	// execution of the query.
+	routingTokenErr error
+	// streamCells is the threshold of StreamCells.
+	streamCells int
 }
 
 type queryRoutingInfo struct {
@@ -925,6 +1178,13 @@ type queryRoutingInfo struct {
 	keyspace string
 
 	table string
+
+	// parsed is set once keyspace and table are known, from the statement
+	// or from the server.
+	parsed bool
+
+	// prepared is the statement the query was last prepared as.
+	prepared *preparedStatment
 }
 
 func (q *Query) defaultsFromSession() {
@@ -939,11 +1199,17 @@ func (q *Query) defaultsFromSession() {
 	q.rt = s.cfg.RetryPolicy
 	q.serialCons = s.cfg.SerialConsistency
 	q.defaultTimestamp = s.cfg.DefaultTimestamp
-	q.idempotent = s.cfg.DefaultIdempotence
+	q.idempotent = s.cfg.DefaultIdempotence || s.cfg.InferIdempotence && inferIdempotence(q.stmt)
+	q.prepareOnAllHosts = s.cfg.PrepareOnAllHosts
+	q.validateValues = s.cfg.ValidateBindValues
 	q.metrics = &queryMetrics{m: make(map[string]*hostMetrics)}
 
 	q.spec = &NonSpeculativeExecution{}
 	s.mu.RUnlock()
+
+	if len(s.cfg.TableConsistency) > 0 || len(s.cfg.TableSerialConsistency) > 0 {
+		q.tableConsistency()
+	}
 }
 
 // Statement returns the statement that was used to generate this query.
@@ -967,6 +1233,13 @@ func (q *Query) Attempts() int {
 	return q.metrics.attempts()
 }
 
+// AttemptErrors returns the errors of the failed attempts of the last
+// execution of the query, in order, so that callers and logs can show the
+// hosts a retried query was tried on and how each attempt failed.
+func (q *Query) AttemptErrors() []HostAttemptError {
+	return q.metrics.attemptErrors()
+}
+
 func (q *Query) AddAttempts(i int, host *HostInfo) {
 	q.metrics.attempt(i, 0, host, false)
 }
@@ -1035,6 +1308,21 @@ func (q *Query) PageSize(n int) *Query {
 	return q
 }
 
+// StreamCells makes the result frames of the query larger than threshold
+// bytes be read from the connection as their rows are consumed, rather than
+// buffered whole first. When the rows are read with Iter.NextRow, the cells
+// larger than threshold are not buffered at all: they can be copied to an
+// io.Writer with LazyRow.WriteColumnTo, and are discarded otherwise.
+//
+// No other response can be read from the connection while the rows of a
+// streamed frame are consumed, so the iterator must be read to the end or
+// closed promptly, and no other query should be executed meanwhile.
+// Compressed frames are always buffered whole.
+func (q *Query) StreamCells(threshold int) *Query {
+	q.streamCells = threshold
+	return q
+}
+
 // DefaultTimestamp will enable the with default timestamp flag on the query.
 // If enable, this will replace the server side assigned
 // timestamp as default timestamp. Note that a timestamp in the query itself
@@ -1058,6 +1346,29 @@ func (q *Query) WithTimestamp(timestamp int64) *Query {
 	return q
 }
 
+// WithWriteTimestamp is like WithTimestamp with the timestamp given as a
+// time, with microsecond precision.
+func (q *Query) WithWriteTimestamp(t time.Time) *Query {
+	return q.WithTimestamp(t.UnixNano() / 1000)
+}
+
+// NoClientTimestamp makes the server assign the timestamp of the query when
+// it receives it, even if the session sends client side timestamps, e.g. for
+// statements relying on the semantics of the now() function of the server.
+// It disables DefaultTimestamp and clears a timestamp set with WithTimestamp.
+func (q *Query) NoClientTimestamp() *Query {
+	q.defaultTimestamp = false
+	q.defaultTimestampValue = 0
+	return q
+}
+
+// TimestampGenerator sets the generator of the default timestamp of the query,
+// instead of the generator of the session.
+func (q *Query) TimestampGenerator(gen TimestampGenerator) *Query {
+	q.timestampGenerator = gen
+	return q
+}
+
 // RoutingKey sets the routing key to use when a token aware connection
 // pool is used to optimize the routing of this query.
 func (q *Query) RoutingKey(routingKey []byte) *Query {
@@ -1065,6 +1376,37 @@ func (q *Query) RoutingKey(routingKey []byte) *Query {
 	return q
 }
 
+// WithRoutingToken sets the token the query is routed by with a token aware
+// host selection policy, instead of the token of its routing key, for
+// example when the partition key can not be computed from the values of the
+// query. token is formatted as the tokens of system.local, such as
+// "-4069959284402364209" with the Murmur3Partitioner. If the partitioner of
+// the cluster is known and token is not one of its tokens, executing the
+// query returns an error; if it is not known yet, a token which turns out to
+// be invalid is ignored for routing.
+func (q *Query) WithRoutingToken(token string) *Query {
+	q.routingToken = token
+	q.routingTokenErr = nil
+	if q.session == nil {
+		return q
+	}
+	q.session.metadata.mu.RLock()
+	name := q.session.metadata.partitioner
+	q.session.metadata.mu.RUnlock()
+	if partitioner, err := newPartitioner(name); err == nil {
+		_, q.routingTokenErr = parseToken(partitioner, token)
+	}
+	return q
+}
+
+// NoTokenAwareRouting makes token aware host selection policies route the
+// query with their fallback policy, as if it had no routing key, for
+// example to spread the queries of a hot partition over all hosts.
+func (q *Query) NoTokenAwareRouting() *Query {
+	q.noTokenAwareRouting = true
+	return q
+}
+
 func (q *Query) withContext(ctx context.Context) ExecutableQuery {
 	// I really wish go had covariant types
 	return q.WithContext(ctx)
@@ -1091,22 +1433,40 @@ func (q *Query) execute(ctx context.Context, conn *Conn) *Iter {
 	return conn.executeQuery(ctx, q)
 }
 
-func (q *Query) attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo) {
+func (q *Query) attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo, shard int) {
 	latency := end.Sub(start)
 	attempt, metricsForHost := q.metrics.attempt(1, latency, host, q.observer != nil)
+	q.metrics.failed(q.name, host, attempt, start, end, iter.err)
 
 	if q.observer != nil {
+		var attempts []ObservedAttempt
+		if iter.execution != nil {
+			attempts = iter.execution.record(ObservedAttempt{
+				Host:        host,
+				Shard:       shard,
+				Start:       start,
+				End:         end,
+				Err:         iter.err,
+				Speculative: iter.speculative,
+				StreamWait:  iter.streamWait,
+			})
+		}
 		q.observer.ObserveQuery(q.Context(), ObservedQuery{
-			Keyspace:  keyspace,
-			Statement: q.stmt,
-			Values:    q.values,
-			Start:     start,
-			End:       end,
-			Rows:      iter.numRows,
-			Host:      host,
-			Metrics:   metricsForHost,
-			Err:       iter.err,
-			Attempt:   attempt,
+			Keyspace:   keyspace,
+			Statement:  q.stmt,
+			Values:     q.values,
+			Start:      start,
+			End:        end,
+			Rows:       iter.numRows,
+			Host:       host,
+			Shard:      shard,
+			Metrics:    metricsForHost,
+			Err:        iter.err,
+			Attempt:    attempt,
+			StreamWait: iter.streamWait,
+			Name:       q.name,
+			Tags:       q.tags,
+			Attempts:   attempts,
 		})
 	}
 }
@@ -1115,26 +1475,79 @@ func (q *Query) retryPolicy() RetryPolicy {
 	return q.rt
 }
 
-// Keyspace returns the keyspace the query will be executed against.
+func (q *Query) retryLimit() (int, bool) {
+	return q.maxRetries, q.limitRetries
+}
+
+func (q *Query) serialConsistency() SerialConsistency {
+	return q.serialCons
+}
+
+// Keyspace returns the keyspace the query will be executed against: the one
+// qualifying the table of the statement, as in "SELECT * FROM ks.t", or else
+// the keyspace of the session.
 func (q *Query) Keyspace() string {
 	if q.getKeyspace != nil {
 		return q.getKeyspace()
 	}
-	if q.routingInfo.keyspace != "" {
-		return q.routingInfo.keyspace
+	if keyspace, _ := q.statementTable(); keyspace != "" {
+		return keyspace
 	}
 
 	if q.session == nil {
 		return ""
 	}
-	// TODO(chbannis): this should be parsed from the query or we should let
-	// this be set by users.
+	// the statement does not qualify its table with a keyspace
 	return q.session.cfg.Keyspace
 }
 
-// Table returns name of the table the query will be executed against.
+// Table returns name of the table the query will be executed against, as
+// parsed from the statement or told by the server once it is prepared.
 func (q *Query) Table() string {
-	return q.routingInfo.table
+	_, table := q.statementTable()
+	return table
+}
+
+// statementTable returns the keyspace and table named by the statement, until
+// the statement is prepared and the server tells them. The statement is only
+// parsed once, when they are first needed.
+func (q *Query) statementTable() (keyspace, table string) {
+	q.routingInfo.mu.RLock()
+	keyspace, table, parsed := q.routingInfo.keyspace, q.routingInfo.table, q.routingInfo.parsed
+	q.routingInfo.mu.RUnlock()
+	if parsed {
+		return keyspace, table
+	}
+
+	keyspace, table = statementTable(q.stmt)
+	q.routingInfo.mu.Lock()
+	defer q.routingInfo.mu.Unlock()
+	if !q.routingInfo.parsed {
+		q.routingInfo.keyspace, q.routingInfo.table, q.routingInfo.parsed = keyspace, table, true
+	}
+	return q.routingInfo.keyspace, q.routingInfo.table
+}
+
+// PreparedInfo returns the metadata of the prepared statement the query was
+// last executed or validated as: the columns of its bind markers, the indexes
+// of the partition key columns among them (protocol v4+) and the result
+// columns. Rval is empty if the server did not return the result metadata.
+// ok is false until the query is prepared.
+func (q *Query) PreparedInfo() (info QueryInfo, ok bool) {
+	q.routingInfo.mu.RLock()
+	prepared := q.routingInfo.prepared
+	q.routingInfo.mu.RUnlock()
+	if prepared == nil {
+		return QueryInfo{}, false
+	}
+
+	info = *prepared.queryInfo()
+	info.Id = copyBytes(info.Id)
+	info.Args = append([]ColumnInfo(nil), info.Args...)
+	info.Rval = append([]ColumnInfo(nil), info.Rval...)
+	info.PKeyColumns = append([]int(nil), info.PKeyColumns...)
+	info.Markers = append([]BindMarker(nil), info.Markers...)
+	return info, true
 }
 
 // GetRoutingKey gets the routing key to use for routing this query. If
@@ -1163,11 +1576,64 @@ func (q *Query) GetRoutingKey() ([]byte, error) {
 		q.routingInfo.mu.Lock()
 		q.routingInfo.keyspace = routingKeyInfo.keyspace
 		q.routingInfo.table = routingKeyInfo.table
+		q.routingInfo.parsed = true
 		q.routingInfo.mu.Unlock()
 	}
 	return createRoutingKey(routingKeyInfo, q.values)
 }
 
+// RoutingKeyInfo returns the routing key information of the statement of the
+// query, preparing it if needed. It returns nil if the statement has no bind
+// markers or not all the partition key columns are bound.
+func (q *Query) RoutingKeyInfo() (*RoutingKeyInfo, error) {
+	if q.session == nil {
+		return nil, ErrSessionClosed
+	}
+	info, err := q.session.routingKeyInfo(q.Context(), q.stmt)
+	if err != nil || info == nil {
+		return nil, err
+	}
+	return &RoutingKeyInfo{
+		Keyspace:  info.keyspace,
+		Table:     info.table,
+		BaseTable: q.session.viewBaseTable(info.keyspace, info.table),
+		Indexes:   append([]int(nil), info.indexes...),
+		Types:     append([]TypeInfo(nil), info.types...),
+	}, nil
+}
+
+// Token returns the token of the partition of the query, as computed by the
+// driver to route it: the token set with WithRoutingToken, or else the token
+// of its routing key with the partitioner of the cluster. It returns
+// ErrNoRoutingKey if the query has no routing key, and ErrNoMetadata if the
+// partitioner of the cluster is not known yet.
+func (q *Query) Token() (Token, error) {
+	if q.session == nil {
+		return nil, ErrSessionClosed
+	}
+	q.session.metadata.mu.RLock()
+	name := q.session.metadata.partitioner
+	q.session.metadata.mu.RUnlock()
+	if name == "" {
+		return nil, ErrNoMetadata
+	}
+	partitioner, err := newPartitioner(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.routingToken != "" {
+		return parseToken(partitioner, q.routingToken)
+	}
+	routingKey, err := q.GetRoutingKey()
+	if err != nil {
+		return nil, err
+	} else if routingKey == nil {
+		return nil, ErrNoRoutingKey
+	}
+	return partitioner.Hash(routingKey), nil
+}
+
 func (q *Query) shouldPrepare() bool {
 
 	stmt := strings.TrimLeftFunc(strings.TrimRightFunc(q.stmt, func(r rune) bool {
@@ -1204,6 +1670,66 @@ func (q *Query) RetryPolicy(r RetryPolicy) *Query {
 	return q
 }
 
+// MaxRetries caps the number of times the query is retried after failing,
+// whatever its RetryPolicy decides, so that a misconfigured policy can not
+// retry it forever. Retries are counted for each execution, speculative
+// executions having their own. A negative n removes the cap.
+func (q *Query) MaxRetries(n int) *Query {
+	q.maxRetries, q.limitRetries = n, n >= 0
+	return q
+}
+
+// Name names the query after the logical operation it performs, such as
+// "get_user_by_id", so that observers, attempt errors and error contexts
+// can group queries by operation rather than by statement.
+func (q *Query) Name(name string) *Query {
+	q.name = name
+	return q
+}
+
+// GetName returns the name of the query, as set with Name.
+func (q *Query) GetName() string {
+	return q.name
+}
+
+// Tag tags the query with the value of key, such as "tenant", reported to
+// observers and in error contexts along with its name.
+func (q *Query) Tag(key, value string) *Query {
+	if q.tags == nil {
+		q.tags = make(map[string]string)
+	}
+	q.tags[key] = value
+	return q
+}
+
+// GetTags returns the tags of the query, as set with Tag. They must not be
+// modified.
+func (q *Query) GetTags() map[string]string {
+	return q.tags
+}
+
+// SetHostID pins the query to the host with the ID hostID: it is executed
+// on that host only, bypassing the host selection policy and speculative
+// executions, and fails rather than being retried on other hosts. It fails
+// with ErrHostNotFound if the session does not know the host, and with
+// ErrHostDown if the host is down. This is meant for queries reading the
+// local state of a node, such as system.local or virtual tables.
+// An empty hostID unpins the query.
+func (q *Query) SetHostID(hostID string) *Query {
+	q.hostID = hostID
+	return q
+}
+
+// SetHost pins the query to host, as with SetHostID.
+func (q *Query) SetHost(host *HostInfo) *Query {
+	return q.SetHostID(host.HostID())
+}
+
+// GetHostID returns the ID of the host the query is pinned to, if any.
+func (q *Query) GetHostID() string {
+	return q.hostID
+}
+
 // SetSpeculativeExecutionPolicy sets the execution policy
 func (q *Query) SetSpeculativeExecutionPolicy(sp SpeculativeExecutionPolicy) *Query {
 	q.spec = sp
@@ -1239,6 +1765,48 @@ func (q *Query) Bind(v ...interface{}) *Query {
 	return q
 }
 
+// BindMap sets query arguments of query by the names of the bind markers, as
+// in "INSERT INTO users (id, name) VALUES (:id, :name)". The values are put in
+// the order of the markers of the prepared statement, the execution fails if
+// a marker has no value or a value matches no marker. Positional markers are
+// named after the columns they are bound to. The statement must be preparable
+// and, as with Session.Bind, the routing key is not computed from the values.
+func (q *Query) BindMap(values map[string]interface{}) *Query {
+	q.values = nil
+	q.pageState = nil
+	q.binding = func(info *QueryInfo) ([]interface{}, error) {
+		return bindNamedValues(info, values)
+	}
+	return q
+}
+
+func bindNamedValues(info *QueryInfo, named map[string]interface{}) ([]interface{}, error) {
+	values := make([]interface{}, len(info.Args))
+	used := make(map[string]struct{}, len(named))
+	var missing []string
+	for i, arg := range info.Args {
+		v, ok := named[arg.Name]
+		if !ok {
+			missing = append(missing, arg.Name)
+			continue
+		}
+		values[i] = v
+		used[arg.Name] = struct{}{}
+	}
+	if len(missing) > 0 {
+		if len(info.Markers) > 0 && !info.Named() {
+			return nil, fmt.Errorf("gocql: no value for the positional bind markers of columns %q", missing)
+		}
+		return nil, fmt.Errorf("gocql: no value for bind markers %q", missing)
+	}
+	for name := range named {
+		if _, ok := used[name]; !ok {
+			return nil, fmt.Errorf("gocql: no bind marker for value %q", name)
+		}
+	}
+	return values, nil
+}
+
 // SerialConsistency sets the consistency level for the
 // serial phase of conditional updates. That consistency can only be
 // either SERIAL or LOCAL_SERIAL and if not present, it defaults to
@@ -1271,11 +1839,119 @@ func (q *Query) NoSkipMetadata() *Query {
 	return q
 }
 
+// ValidateValues sets whether the values of the query are validated against
+// the bind markers of its prepared statement before it is sent, as by
+// Validate. Invalid values are reported as a BindError naming the column
+// instead of a server error. It overrides ClusterConfig.ValidateBindValues.
+func (q *Query) ValidateValues(enabled bool) *Query {
+	q.validateValues = enabled
+	return q
+}
+
+// PrepareOnAllHosts sets whether the statement is prepared on all the hosts
+// of the session once it is first prepared, instead of on each host the first
+// time it is executed there. It overrides ClusterConfig.PrepareOnAllHosts.
+func (q *Query) PrepareOnAllHosts(enabled bool) *Query {
+	q.prepareOnAllHosts = enabled
+	return q
+}
+
+// Hint appends a trailing clause to the statement, such as the Scylla specific
+// BYPASS CACHE. Hints become part of the statement text, so they must be added
+// before the query is executed and a prepared statement is cached per set of
+// hints. A trailing semicolon is removed from the statement and a hint which
+// the statement already ends with is not added again.
+func (q *Query) Hint(clause string) *Query {
+	clause = strings.TrimSpace(clause)
+	if clause == "" {
+		return q
+	}
+
+	stmt := strings.TrimRightFunc(q.stmt, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ';'
+	})
+	if n := len(stmt) - len(clause); n > 0 && strings.EqualFold(stmt[n:], clause) &&
+		unicode.IsSpace(rune(stmt[n-1])) {
+		return q
+	}
+
+	// a line comment at the end of the statement would swallow the hint
+	sep := " "
+	lastLine := stmt[strings.LastIndexByte(stmt, '\n')+1:]
+	if strings.Contains(lastLine, "--") || strings.Contains(lastLine, "//") {
+		sep = "\n"
+	}
+	q.stmt = stmt + sep + clause
+	return q
+}
+
+// BypassCache makes Scylla read the data of a SELECT statement from disk
+// without populating the row cache, which avoids evicting hot rows during
+// full table scans. It is not supported by Cassandra.
+func (q *Query) BypassCache() *Query {
+	return q.Hint("BYPASS CACHE")
+}
+
+// ServerTimeout sets the Scylla USING TIMEOUT clause of the statement, which
+// replaces the server side timeout configured on the nodes for this query.
+// The client side timeout of the query is extended when the server timeout
+// is longer, so a slow query does not require to increase ClusterConfig.Timeout.
+// Like hints, the timeout is part of the statement text and must be set before
+// the query is executed. It is supported by SELECT, INSERT, UPDATE, DELETE and
+// BATCH statements, other statements are left unchanged.
+func (q *Query) ServerTimeout(timeout time.Duration) *Query {
+	if timeout <= 0 {
+		return q
+	}
+	if stmt, ok := setUsingTimeout(q.stmt, timeout); ok {
+		q.stmt = stmt
+		q.serverTimeout = timeout
+	}
+	return q
+}
+
 // Exec executes the query without returning any rows.
 func (q *Query) Exec() error {
 	return q.Iter().Close()
 }
 
+// ExecContext is like Exec with the context of the query set to ctx, q is
+// left unchanged.
+func (q *Query) ExecContext(ctx context.Context) error {
+	return q.WithContext(ctx).Exec()
+}
+
+// Validate prepares the statement of the query without executing it, which
+// checks its syntax and that the tables and columns it refers to exist, and
+// checks that the values bound to the query match the bind markers in number
+// and type, and that no partition key value is null. Only SELECT, INSERT, UPDATE, DELETE and BATCH statements can be
+// prepared and validated.
+func (q *Query) Validate(ctx context.Context) error {
+	if q.err != nil {
+		return q.err
+	}
+	if q.session == nil || q.session.Closed() {
+		return ErrSessionClosed
+	}
+	if !q.shouldPrepare() {
+		return fmt.Errorf("gocql: statement can not be prepared to be validated: %q", q.stmt)
+	}
+
+	conn := q.session.getConn()
+	if conn == nil {
+		return ErrNoConnections
+	}
+	info, err := conn.prepareStatement(ctx, q.stmt, nil)
+	if err != nil {
+		return err
+	}
+	q.routingInfo.mu.Lock()
+	q.routingInfo.prepared = info
+	q.routingInfo.mu.Unlock()
+	_, err = q.bindValues(info, true)
+	return err
+}
+
 func isUseStatement(stmt string) bool {
 	if len(stmt) < 3 {
 		return false
@@ -1287,6 +1963,12 @@ func isUseStatement(stmt string) bool {
 // Iter executes the query and returns an iterator capable of iterating
 // over all results.
 func (q *Query) Iter() *Iter {
+	if q.err != nil {
+		return &Iter{err: q.err}
+	}
+	if q.routingTokenErr != nil {
+		return &Iter{err: q.routingTokenErr}
+	}
 	if isUseStatement(q.stmt) {
 		return &Iter{err: ErrUseStmt}
 	}
@@ -1298,6 +1980,12 @@ func (q *Query) Iter() *Iter {
 	return q.session.executeQuery(q)
 }
 
+// IterContext is like Iter with the context of the query set to ctx, q is
+// left unchanged. The context applies to the fetching of the next pages too.
+func (q *Query) IterContext(ctx context.Context) *Iter {
+	return q.WithContext(ctx).Iter()
+}
+
 // MapScan executes the query, copies the columns of the first selected
 // row into the map pointed at by m and discards the rest. If no rows
 // were selected, ErrNotFound is returned.
@@ -1310,6 +1998,12 @@ func (q *Query) MapScan(m map[string]interface{}) error {
 	return iter.Close()
 }
 
+// MapScanContext is like MapScan with the context of the query set to ctx, q
+// is left unchanged.
+func (q *Query) MapScanContext(ctx context.Context, m map[string]interface{}) error {
+	return q.WithContext(ctx).MapScan(m)
+}
+
 // Scan executes the query, copies the columns of the first selected
 // row into the values pointed at by dest and discards the rest. If no rows
 // were selected, ErrNotFound is returned.
@@ -1322,6 +2016,12 @@ func (q *Query) Scan(dest ...interface{}) error {
 	return iter.Close()
 }
 
+// ScanContext is like Scan with the context of the query set to ctx, q is
+// left unchanged.
+func (q *Query) ScanContext(ctx context.Context, dest ...interface{}) error {
+	return q.WithContext(ctx).Scan(dest...)
+}
+
 // ScanCAS executes a lightweight transaction (i.e. an UPDATE or INSERT
 // statement containing an IF clause). If the transaction fails because
 // the existing values did not match, the previous values will be stored
@@ -1345,6 +2045,12 @@ func (q *Query) ScanCAS(dest ...interface{}) (applied bool, err error) {
 	return applied, iter.Close()
 }
 
+// ScanCASContext is like ScanCAS with the context of the query set to ctx, q
+// is left unchanged.
+func (q *Query) ScanCASContext(ctx context.Context, dest ...interface{}) (applied bool, err error) {
+	return q.WithContext(ctx).ScanCAS(dest...)
+}
+
 // MapScanCAS executes a lightweight transaction (i.e. an UPDATE or INSERT
 // statement containing an IF clause). If the transaction fails because
 // the existing values did not match, the previous values will be stored
@@ -1366,6 +2072,12 @@ func (q *Query) MapScanCAS(dest map[string]interface{}) (applied bool, err error
 	return applied, iter.Close()
 }
 
+// MapScanCASContext is like MapScanCAS with the context of the query set to
+// ctx, q is left unchanged.
+func (q *Query) MapScanCASContext(ctx context.Context, dest map[string]interface{}) (applied bool, err error) {
+	return q.WithContext(ctx).MapScanCAS(dest)
+}
+
 // Release releases a query back into a pool of queries. Released Queries
 // cannot be reused.
 //
@@ -1413,9 +2125,22 @@ type Iter struct {
 	numRows int
 	next    *nextIter
 	host    *HostInfo
+	// streamWait is the time the query waited for a free stream.
+	streamWait time.Duration
+	// attempts is the number of attempts made by the execution which
+	// returned the iterator.
+	attempts int
+	// execution records the attempts of the execution of the query, and
+	// speculative reports whether the iterator is the result of a
+	// speculative execution.
+	execution   *queryExecution
+	speculative bool
 
 	framer *framer
 	closed int32
+	// row is the row of a streamed frame whose cells are being read, see
+	// NextRow.
+	row *LazyRow
 }
 
 // Host returns the host which the query was sent to.
@@ -1423,6 +2148,13 @@ func (iter *Iter) Host() *HostInfo {
 	return iter.host
 }
 
+// Attempts returns the number of attempts, including retries, made to
+// execute the query of the iterator, or of its current page. Speculative
+// executions of the query count their attempts separately.
+func (iter *Iter) Attempts() int {
+	return iter.attempts
+}
+
 // Columns returns the name and type of the selected columns.
 func (iter *Iter) Columns() []ColumnInfo {
 	return iter.meta.columns
@@ -1455,11 +2187,14 @@ type iterScanner struct {
 
 func (is *iterScanner) Next() bool {
 	iter := is.iter
+	iter.finishRow()
 	if iter.err != nil {
+		iter.framer.releaseBody()
 		return false
 	}
 
 	if iter.pos >= iter.numRows {
+		iter.framer.releaseBody()
 		if iter.next != nil {
 			is.iter = iter.next.fetch()
 			return is.Next()
@@ -1481,28 +2216,84 @@ func (is *iterScanner) Next() bool {
 	return true
 }
 
-func scanColumn(p []byte, col ColumnInfo, dest []interface{}) (int, error) {
+// scanColumn unmarshals the column col into the first values of dest and
+// returns the number of values used. A tuple column is unmarshaled into one
+// value per element if expand is true, unless the value is a Tuple, or into
+// a single value otherwise. As both forms take a single value for tuples of
+// one element, their element is unmarshaled into the value, or else the
+// tuple if the value can not hold the element.
+func scanColumn(p []byte, col ColumnInfo, dest []interface{}, expand bool) (int, error) {
 	if dest[0] == nil {
 		return 1, nil
 	}
 
-	if col.TypeInfo.Type() == TypeTuple {
-		// this will panic, actually a bug, please report
-		tuple := col.TypeInfo.(TupleTypeInfo)
-
-		count := len(tuple.Elems)
-		// here we pass in a slice of the struct which has the number number of
-		// values as elements in the tuple
-		if err := Unmarshal(col.TypeInfo, p, dest[:count]); err != nil {
-			return 0, err
-		}
-		return count, nil
-	} else {
+	tuple, ok := col.TypeInfo.(TupleTypeInfo)
+	_, isTuple := dest[0].(Tuple)
+	if !ok || isTuple || !expand && len(tuple.Elems) != 1 || len(tuple.Elems) == 1 && scanWholeTuple(tuple.Elems[0], dest[0]) {
 		if err := Unmarshal(col.TypeInfo, p, dest[0]); err != nil {
 			return 0, err
 		}
 		return 1, nil
 	}
+
+	count := len(tuple.Elems)
+	// here we pass in a slice of the struct which has the number number of
+	// values as elements in the tuple
+	if err := Unmarshal(col.TypeInfo, p, dest[:count]); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// scanWholeTuple reports whether dest, the single value a tuple column of one
+// element is scanned into, is a value of the whole tuple rather than of its
+// element elem: a struct of one field, an array of one element or a slice,
+// unless elem is a collection, tuple or UDT which could be unmarshaled into
+// it, or a slice of bytes. Other values, including Unmarshalers, receive the
+// element.
+func scanWholeTuple(elem TypeInfo, dest interface{}) bool {
+	switch dest.(type) {
+	case []interface{}:
+		return true
+	case Unmarshaler:
+		return false
+	}
+
+	t := reflect.TypeOf(dest)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return false
+	}
+	var nested bool
+	switch elem.Type() {
+	case TypeList, TypeSet, TypeTuple, TypeUDT, TypeCustom:
+		nested = true
+	}
+	switch t = t.Elem(); t.Kind() {
+	case reflect.Struct:
+		return t.NumField() == 1 && !nested
+	case reflect.Array:
+		return t.Len() == 1 && !nested
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8 && !nested
+	}
+	return false
+}
+
+// expandTuples checks the number of values dest to scan a row into, and
+// reports whether its tuple columns are scanned into one value per element,
+// rather than into a single value per column.
+func (meta *resultMetadata) expandTuples(dest []interface{}) (bool, error) {
+	switch len(dest) {
+	case len(meta.columns):
+		return false, nil
+	case meta.actualColCount:
+		return true, nil
+	}
+	if meta.actualColCount != len(meta.columns) {
+		return false, fmt.Errorf("gocql: not enough columns to scan into: have %d want %d, or %d with one value per tuple column",
+			len(dest), meta.actualColCount, len(meta.columns))
+	}
+	return false, fmt.Errorf("gocql: not enough columns to scan into: have %d want %d", len(dest), meta.actualColCount)
 }
 
 func (is *iterScanner) Scan(dest ...interface{}) error {
@@ -1511,19 +2302,17 @@ func (is *iterScanner) Scan(dest ...interface{}) error {
 	}
 
 	iter := is.iter
-	// currently only support scanning into an expand tuple, such that its the same
-	// as scanning in more values from a single column
-	if len(dest) != iter.meta.actualColCount {
-		return fmt.Errorf("gocql: not enough columns to scan into: have %d want %d", len(dest), iter.meta.actualColCount)
+	expand, err := iter.meta.expandTuples(dest)
+	if err != nil {
+		return err
 	}
 
 	// i is the current position in dest, could posible replace it and just use
 	// slices of dest
 	i := 0
-	var err error
-	for _, col := range iter.meta.columns {
+	for c, col := range iter.meta.columns {
 		var n int
-		n, err = scanColumn(is.cols[i], col, dest[i:])
+		n, err = scanColumn(is.cols[c], col, dest[i:], expand)
 		if err != nil {
 			break
 		}
@@ -1558,18 +2347,24 @@ func (iter *Iter) readColumn() ([]byte, error) {
 
 // Scan consumes the next row of the iterator and copies the columns of the
 // current row into the values pointed at by dest. Use nil as a dest value
-// to skip the corresponding column. Scan might send additional queries
-// to the database to retrieve the next set of rows if paging was enabled.
+// to skip the corresponding column. A tuple column is scanned into a single
+// dest value, such as a Tuple or a pointer to a struct, or else into one dest
+// value per element of the tuple for all the tuple columns of the row. Scan
+// might send additional queries to the database to retrieve the next set of
+// rows if paging was enabled.
 //
 // Scan returns true if the row was successfully unmarshaled or false if the
 // end of the result set was reached or if an error occurred. Close should
 // be called afterwards to retrieve any potential errors.
 func (iter *Iter) Scan(dest ...interface{}) bool {
+	iter.finishRow()
 	if iter.err != nil {
+		iter.framer.releaseBody()
 		return false
 	}
 
 	if iter.pos >= iter.numRows {
+		iter.framer.releaseBody()
 		if iter.next != nil {
 			*iter = *iter.next.fetch()
 			return iter.Scan(dest...)
@@ -1581,10 +2376,9 @@ func (iter *Iter) Scan(dest ...interface{}) bool {
 		iter.next.fetchAsync()
 	}
 
-	// currently only support scanning into an expand tuple, such that its the same
-	// as scanning in more values from a single column
-	if len(dest) != iter.meta.actualColCount {
-		iter.err = fmt.Errorf("gocql: not enough columns to scan into: have %d want %d", len(dest), iter.meta.actualColCount)
+	expand, err := iter.meta.expandTuples(dest)
+	if err != nil {
+		iter.err = err
 		return false
 	}
 
@@ -1598,7 +2392,7 @@ func (iter *Iter) Scan(dest ...interface{}) bool {
 			return false
 		}
 
-		n, err := scanColumn(colBytes, col, dest[i:])
+		n, err := scanColumn(colBytes, col, dest[i:], expand)
 		if err != nil {
 			iter.err = err
 			return false
@@ -1638,7 +2432,12 @@ func (iter *Iter) Warnings() []string {
 // the query or the iteration.
 func (iter *Iter) Close() error {
 	if atomic.CompareAndSwapInt32(&iter.closed, 0, 1) {
+		if iter.row != nil {
+			iter.row.iter = nil
+			iter.row = nil
+		}
 		if iter.framer != nil {
+			iter.framer.releaseBody()
 			iter.framer = nil
 		}
 	}
@@ -1711,6 +2510,8 @@ type Batch struct {
 	routingKey            []byte
 	CustomPayload         map[string][]byte
 	rt                    RetryPolicy
+	maxRetries            int
+	limitRetries          bool
 	spec                  SpeculativeExecutionPolicy
 	trace                 Tracer
 	observer              BatchObserver
@@ -1718,6 +2519,7 @@ type Batch struct {
 	serialCons            SerialConsistency
 	defaultTimestamp      bool
 	defaultTimestampValue int64
+	timestampGenerator    TimestampGenerator
 	context               context.Context
 	cancelBatch           func()
 	keyspace              string
@@ -1775,11 +2577,21 @@ func (b *Batch) Observer(observer BatchObserver) *Batch {
 	return b
 }
 
+// Keyspace returns the keyspace of the table of the first statement of the
+// batch, which the batch is routed by, once its routing key is computed, or
+// else the keyspace of the session.
 func (b *Batch) Keyspace() string {
+	b.routingInfo.mu.RLock()
+	keyspace := b.routingInfo.keyspace
+	b.routingInfo.mu.RUnlock()
+	if keyspace != "" {
+		return keyspace
+	}
 	return b.keyspace
 }
 
-// Batch has no reasonable eqivalent of Query.Table().
+// Table returns the table of the first statement of the batch once its
+// routing key is computed.
 func (b *Batch) Table() string {
 	return b.routingInfo.table
 }
@@ -1789,6 +2601,12 @@ func (b *Batch) Attempts() int {
 	return b.metrics.attempts()
 }
 
+// AttemptErrors returns the errors of the failed attempts of the last
+// execution of the batch, in order.
+func (b *Batch) AttemptErrors() []HostAttemptError {
+	return b.metrics.attemptErrors()
+}
+
 func (b *Batch) AddAttempts(i int, host *HostInfo) {
 	b.metrics.attempt(i, 0, host, false)
 }
@@ -1841,26 +2659,47 @@ func (b *Batch) SpeculativeExecutionPolicy(sp SpeculativeExecutionPolicy) *Batch
 
 // Query adds the query to the batch operation
 func (b *Batch) Query(stmt string, args ...interface{}) {
-	b.Entries = append(b.Entries, BatchEntry{Stmt: stmt, Args: args})
+	b.Entries = append(b.Entries, BatchEntry{Stmt: stmt, Args: args, Idempotent: b.inferIdempotence(stmt)})
 }
 
 // Bind adds the query to the batch operation and correlates it with a binding callback
 // that will be invoked when the batch is executed. The binding callback allows the application
 // to define which query argument values will be marshalled as part of the batch execution.
 func (b *Batch) Bind(stmt string, bind func(q *QueryInfo) ([]interface{}, error)) {
-	b.Entries = append(b.Entries, BatchEntry{Stmt: stmt, binding: bind})
+	b.Entries = append(b.Entries, BatchEntry{Stmt: stmt, binding: bind, Idempotent: b.inferIdempotence(stmt)})
+}
+
+// inferIdempotence reports whether stmt is known to be idempotent when the
+// session infers the idempotence of statements.
+func (b *Batch) inferIdempotence(stmt string) bool {
+	return b.session != nil && b.session.cfg.InferIdempotence && inferIdempotence(stmt)
 }
 
 func (b *Batch) retryPolicy() RetryPolicy {
 	return b.rt
 }
 
+func (b *Batch) retryLimit() (int, bool) {
+	return b.maxRetries, b.limitRetries
+}
+
+func (b *Batch) serialConsistency() SerialConsistency {
+	return b.serialCons
+}
+
 // RetryPolicy sets the retry policy to use when executing the batch operation
 func (b *Batch) RetryPolicy(r RetryPolicy) *Batch {
 	b.rt = r
 	return b
 }
 
+// MaxRetries caps the number of times the batch is retried after failing,
+// as with Query.MaxRetries.
+func (b *Batch) MaxRetries(n int) *Batch {
+	b.maxRetries, b.limitRetries = n, n >= 0
+	return b
+}
+
 func (b *Batch) withContext(ctx context.Context) ExecutableQuery {
 	return b.WithContext(ctx)
 }
@@ -1922,9 +2761,24 @@ func (b *Batch) WithTimestamp(timestamp int64) *Batch {
 	return b
 }
 
-func (b *Batch) attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo) {
+// WithWriteTimestamp is like WithTimestamp with the timestamp given as a
+// time, with microsecond precision. It is the timestamp of all the statements
+// of the batch which do not set one with USING TIMESTAMP.
+func (b *Batch) WithWriteTimestamp(t time.Time) *Batch {
+	return b.WithTimestamp(t.UnixNano() / 1000)
+}
+
+// TimestampGenerator sets the generator of the default timestamp of the batch,
+// instead of the generator of the session.
+func (b *Batch) TimestampGenerator(gen TimestampGenerator) *Batch {
+	b.timestampGenerator = gen
+	return b
+}
+
+func (b *Batch) attempt(keyspace string, end, start time.Time, iter *Iter, host *HostInfo, shard int) {
 	latency := end.Sub(start)
 	attempt, metricsForHost := b.metrics.attempt(1, latency, host, b.observer != nil)
+	b.metrics.failed("", host, attempt, start, end, iter.err)
 
 	if b.observer == nil {
 		return
@@ -1945,13 +2799,23 @@ func (b *Batch) attempt(keyspace string, end, start time.Time, iter *Iter, host
 		Start:      start,
 		End:        end,
 		// Rows not used in batch observations // TODO - might be able to support it when using BatchCAS
-		Host:    host,
-		Metrics: metricsForHost,
-		Err:     iter.err,
-		Attempt: attempt,
+		Host:       host,
+		Shard:      shard,
+		Metrics:    metricsForHost,
+		Err:        iter.err,
+		Attempt:    attempt,
+		StreamWait: iter.streamWait,
 	})
 }
 
+// RoutingKey sets the routing key to use when a token aware connection
+// pool is used to optimize the routing of this batch, instead of the
+// routing key of its first statement.
+func (b *Batch) RoutingKey(routingKey []byte) *Batch {
+	b.routingKey = routingKey
+	return b
+}
+
 func (b *Batch) GetRoutingKey() ([]byte, error) {
 	if b.routingKey != nil {
 		return b.routingKey, nil
@@ -1972,6 +2836,12 @@ func (b *Batch) GetRoutingKey() ([]byte, error) {
 		return nil, err
 	}
 
+	if routingKeyInfo != nil {
+		b.routingInfo.mu.Lock()
+		b.routingInfo.keyspace = routingKeyInfo.keyspace
+		b.routingInfo.table = routingKeyInfo.table
+		b.routingInfo.mu.Unlock()
+	}
 	return createRoutingKey(routingKeyInfo, entry.Args)
 }
 
@@ -2065,6 +2935,33 @@ func (r *routingKeyInfo) String() string {
 	return fmt.Sprintf("routing key index=%v types=%v", r.indexes, r.types)
 }
 
+// RoutingKeyInfo is the routing key information the driver computed for a
+// prepared statement, for applications partitioning requests themselves.
+type RoutingKeyInfo struct {
+	Keyspace string
+	Table    string
+	// BaseTable is the base table of Table when Table is a materialized
+	// view, whose own partition key the routing key is computed from, as
+	// far as the keyspace metadata tells.
+	BaseTable string
+	// Indexes are the indexes among the values of the statement of the
+	// partition key columns, in the order of the partition key.
+	Indexes []int
+	// Types are the types of the partition key columns.
+	Types []TypeInfo
+}
+
+// RoutingKey returns the routing key of the statement executed with values,
+// as computed by the driver to route it.
+func (r *RoutingKeyInfo) RoutingKey(values ...interface{}) ([]byte, error) {
+	for _, i := range r.Indexes {
+		if i >= len(values) {
+			return nil, fmt.Errorf("gocql: no value for partition key column %d, got %d values", i, len(values))
+		}
+	}
+	return createRoutingKey(&routingKeyInfo{indexes: r.Indexes, types: r.Types}, values)
+}
+
 func (r *routingKeyInfoLRU) Remove(key string) {
 	r.mu.Lock()
 	r.lru.Remove(key)
@@ -2173,6 +3070,10 @@ type ObservedQuery struct {
 	// Host is the informations about the host that performed the query
 	Host *HostInfo
 
+	// Shard is the shard of the Scylla node that performed the query,
+	// or -1 if the node is not sharded.
+	Shard int
+
 	// The metrics per this host
 	Metrics *hostMetrics
 
@@ -2183,6 +3084,39 @@ type ObservedQuery struct {
 	// Attempt is the index of attempt at executing this query.
 	// The first attempt is number zero and any retries have non-zero attempt number.
 	Attempt int
+
+	// StreamWait is the time spent waiting for a free stream of the
+	// connection, all of them being in use.
+	StreamWait time.Duration
+
+	// Name and Tags are the name and tags of the query, as set with
+	// Query.Name and Query.Tag. Do not modify the tags.
+	Name string
+	Tags map[string]string
+
+	// Attempts are the attempts of the execution of the query so far, in
+	// the order they completed, the last one being the observed attempt.
+	// They include the attempts of the speculative executions which
+	// completed, but not those of the previous pages of the query.
+	Attempts []ObservedAttempt
+}
+
+// ObservedAttempt is an attempt at executing a query.
+type ObservedAttempt struct {
+	Host  *HostInfo
+	Shard int
+
+	Start time.Time
+	End   time.Time
+
+	Err error
+
+	// Speculative reports whether the attempt was made by a speculative
+	// execution.
+	Speculative bool
+
+	// StreamWait is the time spent waiting for a free stream.
+	StreamWait time.Duration
 }
 
 // QueryObserver is the interface implemented by query observers / stat collectors.
@@ -2210,6 +3144,10 @@ type ObservedBatch struct {
 	// Host is the informations about the host that performed the batch
 	Host *HostInfo
 
+	// Shard is the shard of the Scylla node that performed the batch,
+	// or -1 if the node is not sharded.
+	Shard int
+
 	// Err is the error in the batch query.
 	// It only tracks network errors or errors of bad cassandra syntax, in particular selects with no match return nil error
 	Err error
@@ -2220,6 +3158,10 @@ type ObservedBatch struct {
 	// Attempt is the index of attempt at executing this query.
 	// The first attempt is number zero and any retries have non-zero attempt number.
 	Attempt int
+
+	// StreamWait is the time spent waiting for a free stream of the
+	// connection, all of them being in use.
+	StreamWait time.Duration
 }
 
 // BatchObserver is the interface implemented by batch observers / stat collectors.
@@ -2236,6 +3178,11 @@ type ObservedConnect struct {
 	// Host is the information about the host about to connect
 	Host *HostInfo
 
+	// Shard is the shard of the Scylla node the connection was established
+	// to, or the shard it was meant for if it failed. It is -1 if the node
+	// is not sharded or the connection was not targeting a shard.
+	Shard int
+
 	Start time.Time // time immediately before the dial is called
 	End   time.Time // time immediately after the dial returned
 
@@ -2249,6 +3196,112 @@ type ConnectObserver interface {
 	ObserveConnect(ObservedConnect)
 }
 
+// ControlConnEventType is the type of an ObservedControlConn.
+type ControlConnEventType int
+
+const (
+	// ControlConnEstablished is observed when the control connection is
+	// established, or reestablished to the host it was lost to.
+	ControlConnEstablished ControlConnEventType = iota
+	// ControlConnLost is observed when the control connection is lost. Until
+	// it is reestablished the session receives no events and can not refresh
+	// its ring and schema metadata.
+	ControlConnLost
+	// ControlConnMigrated is observed when the control connection is
+	// reestablished to another host than the one it was lost to.
+	ControlConnMigrated
+)
+
+func (t ControlConnEventType) String() string {
+	switch t {
+	case ControlConnEstablished:
+		return "ESTABLISHED"
+	case ControlConnLost:
+		return "LOST"
+	case ControlConnMigrated:
+		return "MIGRATED"
+	}
+	return fmt.Sprintf("UNKNOWN_%d", int(t))
+}
+
+type ObservedControlConn struct {
+	Type ControlConnEventType
+	// Host is the host of the control connection, the host it was lost to
+	// for ControlConnLost, nil if unknown.
+	Host *HostInfo
+	// PreviousHost is the host the control connection was lost to, if it was.
+	PreviousHost *HostInfo
+	// Err is the reason the control connection was lost for ControlConnLost.
+	Err error
+	// Downtime is the time the session was without a control connection
+	// before it was reestablished, 0 for the first connection.
+	Downtime time.Duration
+}
+
+// ControlConnObserver is the interface implemented by control connection
+// observers. ObserveControlConn is called from the goroutine managing the
+// control connection and must not block.
+type ControlConnObserver interface {
+	ObserveControlConn(ObservedControlConn)
+}
+
+// ConsistencyChangeType is the type of an ObservedConsistency.
+type ConsistencyChangeType int
+
+const (
+	// ConsistencyDowngraded is observed when the retry policy lowers the
+	// consistency of a query before retrying it.
+	ConsistencyDowngraded ConsistencyChangeType = iota
+	// ConsistencyMismatch is observed when the server reports in an error the
+	// consistency it used, and it is not the consistency of the request.
+	ConsistencyMismatch
+)
+
+func (t ConsistencyChangeType) String() string {
+	switch t {
+	case ConsistencyDowngraded:
+		return "DOWNGRADED"
+	case ConsistencyMismatch:
+		return "MISMATCH"
+	}
+	return fmt.Sprintf("UNKNOWN_%d", int(t))
+}
+
+// ObservedConsistency is a change of the consistency of an attempt to execute
+// a query or batch, made by the retry policy or reported by the server, see
+// ConsistencyObserver.
+type ObservedConsistency struct {
+	Type ConsistencyChangeType
+
+	Keyspace string
+	// Table is the table of the query, empty if unknown.
+	Table string
+
+	// Requested is the consistency of the attempt, or its serial consistency
+	// if the server reports the consistency of the Paxos phase of a
+	// lightweight transaction. Actual is the consistency of the next attempt
+	// for ConsistencyDowngraded and the consistency reported by the server
+	// for ConsistencyMismatch.
+	Requested Consistency
+	Actual    Consistency
+
+	// Host is the host of the attempt.
+	Host *HostInfo
+
+	// Err is the error of the attempt.
+	Err error
+
+	// Attempt is the index of the attempt, the first attempt is number zero.
+	Attempt int
+}
+
+// ConsistencyObserver is the interface implemented by observers of the
+// consistency queries and batches are served at, e.g. to track how often
+// reads are served at a lower consistency than configured.
+type ConsistencyObserver interface {
+	ObserveConsistency(context.Context, ObservedConsistency)
+}
+
 type Error struct {
 	Code    int
 	Message string
@@ -2260,15 +3313,19 @@ func (e Error) Error() string {
 
 var (
 	ErrNotFound             = errors.New("not found")
-	ErrUnavailable          = errors.New("unavailable")
+	ErrUnavailable          = newCategorizedError(ErrUnavailableCategory, "unavailable")
 	ErrUnsupported          = errors.New("feature not supported")
 	ErrTooManyStmts         = errors.New("too many statements")
 	ErrUseStmt              = errors.New("use statements aren't supported. Please see https://github.com/gocql/gocql for explanation.")
 	ErrSessionClosed        = errors.New("session has been closed")
-	ErrNoConnections        = errors.New("gocql: no hosts available in the pool")
+	errPreparedInfoNoConn   = newCategorizedError(ErrConnectionCategory, "gocql: unable to fetch prepared info: no connection available")
+	ErrNoConnections        = newCategorizedError(ErrConnectionCategory, "gocql: no hosts available in the pool")
+	ErrHostNotFound         = newCategorizedError(ErrConnectionCategory, "gocql: host not found")
+	ErrHostDown             = newCategorizedError(ErrConnectionCategory, "gocql: host is down")
 	ErrNoKeyspace           = errors.New("no keyspace provided")
 	ErrKeyspaceDoesNotExist = errors.New("keyspace does not exist")
 	ErrNoMetadata           = errors.New("no metadata available")
+	ErrNoRoutingKey         = errors.New("gocql: the query has no routing key")
 )
 
 type ErrProtocol struct{ error }
@@ -2277,6 +3334,11 @@ func NewErrProtocol(format string, args ...interface{}) error {
 	return ErrProtocol{fmt.Errorf(format, args...)}
 }
 
+// Is reports whether target is ErrServerCategory.
+func (e ErrProtocol) Is(target error) bool {
+	return target == ErrServerCategory
+}
+
 // BatchSizeMaximum is the maximum number of statements a batch operation can have.
 // This limit is set by cassandra and could change in the future.
 const BatchSizeMaximum = 65535
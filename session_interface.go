@@ -0,0 +1,174 @@
+package gocql
+
+import (
+	"context"
+	"fmt"
+)
+
+// SessionInterface are the methods of a Session creating and executing
+// statements. Code depending on SessionInterface instead of *Session can be
+// unit tested with a fake session, such as the one of the gocqltest package.
+//
+// A Session is adapted to SessionInterface with NewSessionInterface.
+type SessionInterface interface {
+	Query(stmt string, values ...interface{}) QueryInterface
+	NewBatch(typ BatchType) BatchInterface
+	ExecuteBatch(batch BatchInterface) error
+	Close()
+	Closed() bool
+}
+
+// QueryInterface are the methods of a Query of a SessionInterface.
+type QueryInterface interface {
+	WithContext(ctx context.Context) QueryInterface
+	Consistency(c Consistency) QueryInterface
+	SerialConsistency(cons SerialConsistency) QueryInterface
+	PageSize(n int) QueryInterface
+	PageState(state []byte) QueryInterface
+	Idempotent(value bool) QueryInterface
+	Bind(v ...interface{}) QueryInterface
+	Statement() string
+
+	Exec() error
+	Scan(dest ...interface{}) error
+	MapScan(m map[string]interface{}) error
+	ScanCAS(dest ...interface{}) (applied bool, err error)
+	Iter() IterInterface
+	Release()
+}
+
+// IterInterface are the methods of an Iter of a QueryInterface.
+type IterInterface interface {
+	Scan(dest ...interface{}) bool
+	MapScan(m map[string]interface{}) bool
+	SliceMap() ([]map[string]interface{}, error)
+	PageState() []byte
+	NumRows() int
+	Close() error
+}
+
+// BatchInterface are the methods of a Batch of a SessionInterface.
+type BatchInterface interface {
+	Query(stmt string, args ...interface{})
+	WithContext(ctx context.Context) BatchInterface
+	SetConsistency(c Consistency)
+	SerialConsistency(cons SerialConsistency) BatchInterface
+	Size() int
+}
+
+// NewSessionInterface returns the SessionInterface of the session.
+func NewSessionInterface(s *Session) SessionInterface {
+	return sessionInterface{s}
+}
+
+type sessionInterface struct {
+	s *Session
+}
+
+func (s sessionInterface) Query(stmt string, values ...interface{}) QueryInterface {
+	return queryInterface{s.s.Query(stmt, values...)}
+}
+
+func (s sessionInterface) NewBatch(typ BatchType) BatchInterface {
+	return batchInterface{s.s.NewBatch(typ)}
+}
+
+func (s sessionInterface) ExecuteBatch(batch BatchInterface) error {
+	b, ok := batch.(batchInterface)
+	if !ok {
+		return fmt.Errorf("gocql: batch %T was not created by the session", batch)
+	}
+	return s.s.ExecuteBatch(b.b)
+}
+
+func (s sessionInterface) Close() {
+	s.s.Close()
+}
+
+func (s sessionInterface) Closed() bool {
+	return s.s.Closed()
+}
+
+type queryInterface struct {
+	q *Query
+}
+
+func (q queryInterface) WithContext(ctx context.Context) QueryInterface {
+	return queryInterface{q.q.WithContext(ctx)}
+}
+
+func (q queryInterface) Consistency(c Consistency) QueryInterface {
+	return queryInterface{q.q.Consistency(c)}
+}
+
+func (q queryInterface) SerialConsistency(cons SerialConsistency) QueryInterface {
+	return queryInterface{q.q.SerialConsistency(cons)}
+}
+
+func (q queryInterface) PageSize(n int) QueryInterface {
+	return queryInterface{q.q.PageSize(n)}
+}
+
+func (q queryInterface) PageState(state []byte) QueryInterface {
+	return queryInterface{q.q.PageState(state)}
+}
+
+func (q queryInterface) Idempotent(value bool) QueryInterface {
+	return queryInterface{q.q.Idempotent(value)}
+}
+
+func (q queryInterface) Bind(v ...interface{}) QueryInterface {
+	return queryInterface{q.q.Bind(v...)}
+}
+
+func (q queryInterface) Statement() string {
+	return q.q.Statement()
+}
+
+func (q queryInterface) Exec() error {
+	return q.q.Exec()
+}
+
+func (q queryInterface) Scan(dest ...interface{}) error {
+	return q.q.Scan(dest...)
+}
+
+func (q queryInterface) MapScan(m map[string]interface{}) error {
+	return q.q.MapScan(m)
+}
+
+func (q queryInterface) ScanCAS(dest ...interface{}) (bool, error) {
+	return q.q.ScanCAS(dest...)
+}
+
+func (q queryInterface) Iter() IterInterface {
+	return q.q.Iter()
+}
+
+func (q queryInterface) Release() {
+	q.q.Release()
+}
+
+type batchInterface struct {
+	b *Batch
+}
+
+func (b batchInterface) Query(stmt string, args ...interface{}) {
+	b.b.Query(stmt, args...)
+}
+
+func (b batchInterface) WithContext(ctx context.Context) BatchInterface {
+	return batchInterface{b.b.WithContext(ctx)}
+}
+
+func (b batchInterface) SetConsistency(c Consistency) {
+	b.b.SetConsistency(c)
+}
+
+func (b batchInterface) SerialConsistency(cons SerialConsistency) BatchInterface {
+	return batchInterface{b.b.SerialConsistency(cons)}
+}
+
+func (b batchInterface) Size() int {
+	return b.b.Size()
+}
@@ -25,6 +25,7 @@ func TestSessionAPI(t *testing.T) {
 		pool:   s.pool,
 		policy: s.policy,
 	}
+	s.handleQuery = s.queryHandler(nil)
 	defer s.Close()
 
 	s.SetConsistency(All)
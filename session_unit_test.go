@@ -4,8 +4,14 @@
 package gocql
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestAsyncSessionInit(t *testing.T) {
@@ -34,3 +40,781 @@ func TestAsyncSessionInit(t *testing.T) {
 		t.Fatalf("unexpected error from void")
 	}
 }
+
+func TestQueryHint(t *testing.T) {
+	tests := []struct {
+		stmt     string
+		hints    []string
+		expected string
+	}{
+		{"SELECT * FROM t", []string{"BYPASS CACHE"}, "SELECT * FROM t BYPASS CACHE"},
+		{"SELECT * FROM t ALLOW FILTERING;\n", []string{"BYPASS CACHE"}, "SELECT * FROM t ALLOW FILTERING BYPASS CACHE"},
+		{"SELECT * FROM t bypass cache", []string{"BYPASS CACHE"}, "SELECT * FROM t bypass cache"},
+		{"SELECT * FROM t", []string{"BYPASS CACHE", "BYPASS CACHE"}, "SELECT * FROM t BYPASS CACHE"},
+		{"SELECT * FROM t -- full scan", []string{"BYPASS CACHE"}, "SELECT * FROM t -- full scan\nBYPASS CACHE"},
+		{"SELECT * FROM t", []string{"BYPASS CACHE", " USING TIMEOUT 5s "}, "SELECT * FROM t BYPASS CACHE USING TIMEOUT 5s"},
+		{"SELECT * FROM t", []string{""}, "SELECT * FROM t"},
+	}
+	for _, test := range tests {
+		q := &Query{stmt: test.stmt}
+		for _, hint := range test.hints {
+			q.Hint(hint)
+		}
+		if q.Statement() != test.expected {
+			t.Errorf("%q with hints %q: expected %q got %q", test.stmt, test.hints, test.expected, q.Statement())
+		}
+	}
+
+	if q := (&Query{stmt: "SELECT * FROM t"}).BypassCache(); q.Statement() != "SELECT * FROM t BYPASS CACHE" {
+		t.Errorf("unexpected statement %q", q.Statement())
+	}
+}
+
+func TestSessionInterface(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(defaultProto, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	session := NewSessionInterface(db)
+	defer session.Close()
+
+	if err := session.Query("void").Consistency(One).Idempotent(true).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.ExecuteBatch(&testBatchInterface{}); err == nil {
+		t.Fatal("expected an error executing a batch of another session")
+	}
+
+	session.Close()
+	if !session.Closed() {
+		t.Fatal("expected session to be closed")
+	}
+}
+
+type testBatchInterface struct {
+	BatchInterface
+}
+
+func TestQueryValidate(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := db.Query("INSERT INTO t (c0, c1) VALUES (?, ?)", 1, 2).Validate(ctx); err != nil {
+		t.Fatalf("expected a valid query got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		qry  *Query
+	}{
+		{"arity", db.Query("INSERT INTO t (c0, c1) VALUES (?, ?)", 1)},
+		{"type", db.Query("INSERT INTO t (c0) VALUES (?)", "abc")},
+		{"unknown table", db.Query("SELECT * FROM missing")},
+		{"not preparable", db.Query("CREATE TABLE t (c0 int PRIMARY KEY)")},
+	}
+	for _, test := range tests {
+		if err := test.qry.Validate(ctx); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestQueryBindValuesValidate(t *testing.T) {
+	info := &preparedStatment{request: preparedMetadata{
+		resultMetadata: resultMetadata{
+			columns: []ColumnInfo{
+				{Keyspace: "ks", Table: "t", Name: "id", TypeInfo: NativeType{proto: 4, typ: TypeInt}},
+				{Keyspace: "ks", Table: "t", Name: "v", TypeInfo: NativeType{proto: 4, typ: TypeInt}},
+			},
+			actualColCount: 2,
+		},
+		pkeyColumns: []int{0},
+	}}
+	stmt := "UPDATE t SET v = ? WHERE id = ?"
+
+	tests := []struct {
+		name   string
+		values []interface{}
+		column string
+		err    error
+	}{
+		{"valid", []interface{}{1, 2}, "", nil},
+		{"type", []interface{}{1, "abc"}, "v", nil},
+		{"null partition key", []interface{}{nil, 2}, "id", errNullPartitionKey},
+		{"unset partition key", []interface{}{UnsetValue, 2}, "id", errNullPartitionKey},
+		{"null value", []interface{}{1, nil}, "", nil},
+	}
+	for _, test := range tests {
+		qry := &Query{stmt: stmt, values: test.values}
+		_, err := qry.bindValues(info, true)
+		if test.column == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		var bindErr *BindError
+		if !errors.As(err, &bindErr) {
+			t.Errorf("%s: expected a BindError got %v", test.name, err)
+			continue
+		}
+		if bindErr.Column.Name != test.column || bindErr.Statement != stmt {
+			t.Errorf("%s: unexpected BindError %v", test.name, bindErr)
+		}
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v got %v", test.name, test.err, err)
+		}
+
+		// without validation a null partition key is left to the server
+		if _, err := qry.bindValues(info, false); test.err != nil && err != nil {
+			t.Errorf("%s: unexpected error without validation %v", test.name, err)
+		}
+	}
+
+	_, err := (&Query{stmt: stmt, values: []interface{}{1}}).bindValues(info, true)
+	if err == nil || !strings.Contains(err.Error(), "[id v]") {
+		t.Errorf("expected an arity error naming the columns got %v", err)
+	}
+}
+
+func TestQueryStalePreparedMetadata(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	const stmt = "SELECT * FROM stale WHERE id = ?"
+	err = db.Query(stmt, 1).Exec()
+	var stale *ErrStalePreparedMetadata
+	if !errors.As(err, &stale) {
+		t.Fatalf("expected ErrStalePreparedMetadata got %v", err)
+	}
+	if stale.Statement != stmt || stale.Expected != 0 || stale.Got != 1 {
+		t.Errorf("unexpected error %+v", stale)
+	}
+	if !errors.Is(err, ErrUnpreparedCategory) {
+		t.Error("expected an error of the unprepared category")
+	}
+	if size := db.PreparedCacheStats().Size; size != 0 {
+		t.Errorf("expected the stale statement to be evicted, %d statements cached", size)
+	}
+
+	// idempotent queries are prepared again and retried once
+	misses := db.PreparedCacheStats().Misses
+	if err := db.Query(stmt, 1).Idempotent(true).Exec(); !errors.As(err, &stale) {
+		t.Fatalf("expected ErrStalePreparedMetadata got %v", err)
+	}
+	if n := db.PreparedCacheStats().Misses - misses; n != 2 {
+		t.Errorf("expected the statement to be prepared twice, got %d", n)
+	}
+}
+
+func TestColumnsMatch(t *testing.T) {
+	intType := NativeType{proto: protoVersion4, typ: TypeInt}
+	textType := NativeType{proto: protoVersion4, typ: TypeText}
+	cached := &resultMetadata{
+		colCount: 2,
+		columns:  []ColumnInfo{{Name: "id", TypeInfo: intType}, {Name: "v", TypeInfo: textType}},
+	}
+	withMeta := func(types ...TypeInfo) *resultMetadata {
+		meta := &resultMetadata{colCount: len(types)}
+		for _, typ := range types {
+			meta.columns = append(meta.columns, ColumnInfo{TypeInfo: typ})
+		}
+		return meta
+	}
+	noMeta := &resultMetadata{flags: flagNoMetaData, colCount: 2}
+	row := func(lengths ...int) []byte {
+		var buf []byte
+		for _, n := range lengths {
+			buf = appendInt(buf, int32(n))
+			if n > 0 {
+				buf = append(buf, make([]byte, n)...)
+			}
+		}
+		return buf
+	}
+
+	tests := []struct {
+		name    string
+		got     *resultMetadata
+		numRows int
+		rows    []byte
+		match   bool
+	}{
+		{"same types", withMeta(intType, textType), 1, nil, true},
+		{"fewer columns", withMeta(intType), 1, nil, false},
+		{"different types", withMeta(textType, textType), 1, nil, false},
+		{"no rows", noMeta, 0, nil, true},
+		{"matching cells", noMeta, 1, row(4, 10), true},
+		{"null and empty cells", noMeta, 1, row(-1, 0), true},
+		{"cell of another type", noMeta, 1, row(8, 10), false},
+		{"truncated row", noMeta, 1, row(4), false},
+	}
+	for _, test := range tests {
+		if match := columnsMatch(cached, test.got, test.numRows, test.rows, false); match != test.match {
+			t.Errorf("%s: expected %t got %t", test.name, test.match, match)
+		}
+	}
+}
+
+func TestQueryRoutingKeyInfo(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	info, err := db.Query("SELECT * FROM t WHERE pk1 = :pk1 AND c = :c AND pk2 = :pk2").RoutingKeyInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil || info.Keyspace != "ks" || info.Table != "t" || !reflect.DeepEqual(info.Indexes, []int{0, 2}) || len(info.Types) != 2 {
+		t.Fatalf("unexpected routing key info %+v", info)
+	}
+
+	key, err := info.RoutingKey(1, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := createRoutingKey(&routingKeyInfo{indexes: info.Indexes, types: info.Types}, []interface{}{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, expected) {
+		t.Errorf("expected routing key %x got %x", expected, key)
+	}
+	if _, err := info.RoutingKey(1, 2); err == nil {
+		t.Error("expected an error for a missing partition key value")
+	}
+
+	// the routing key of a query is the one computed from its info
+	qry := db.Query("SELECT * FROM t WHERE pk1 = :pk1 AND c = :c AND pk2 = :pk2", 1, 2, 3)
+	if key, err := qry.GetRoutingKey(); err != nil || !bytes.Equal(key, expected) {
+		t.Errorf("expected routing key %x got %x (%v)", expected, key, err)
+	}
+
+	if info, err := db.Query("SELECT * FROM t").RoutingKeyInfo(); err != nil || info != nil {
+		t.Errorf("expected no routing key info got %+v (%v)", info, err)
+	}
+}
+
+func TestQueryToken(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	const stmt = "SELECT * FROM t WHERE pk = :pk"
+	if _, err := db.Query(stmt, 1).Token(); err != ErrNoMetadata {
+		t.Fatalf("expected ErrNoMetadata without partitioner got %v", err)
+	}
+	db.metadata.setPartitioner("org.apache.cassandra.dht.Murmur3Partitioner")
+
+	qry := db.Query(stmt, 1)
+	tok, err := qry.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := qry.GetRoutingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (murmur3Partitioner{}).Hash(key); tok != expected {
+		t.Errorf("expected token %v got %v", expected, tok)
+	}
+
+	if tok, err := db.Query(stmt, 1).WithRoutingToken("-42").Token(); err != nil || tok != murmur3Token(-42) {
+		t.Errorf("expected the routing token -42 got %v (%v)", tok, err)
+	}
+	if tok, err := db.Query("SELECT * FROM t").Token(); err != ErrNoRoutingKey || tok != nil {
+		t.Errorf("expected %v got %v (%v)", ErrNoRoutingKey, tok, err)
+	}
+
+	// a malformed routing token is an error rather than token 0
+	qry = db.Query(stmt, 1).WithRoutingToken("not a token")
+	if _, err := qry.Token(); err == nil {
+		t.Error("expected an error for an invalid routing token")
+	}
+	if err := qry.Exec(); err == nil || !strings.Contains(err.Error(), "invalid Murmur3Partitioner token") {
+		t.Errorf("expected the execution to fail for an invalid routing token got %v", err)
+	}
+	if err := qry.WithRoutingToken("-42").Exec(); err != nil {
+		t.Errorf("expected a valid routing token to replace the invalid one got %v", err)
+	}
+}
+
+func TestQueryStatementKeyspace(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(protoVersion4, srv.Address)
+	cluster.Keyspace = ""
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		stmt     string
+		keyspace string
+		table    string
+	}{
+		{"SELECT * FROM Ks1.T1 WHERE pk = ?", "ks1", "t1"},
+		{`INSERT INTO "Ks2" . "T2" (pk) VALUES (?)`, "Ks2", "T2"},
+		{"UPDATE ks3.t3 SET v = 1 WHERE pk = ?", "ks3", "t3"},
+		{"DELETE v FROM ks4.t4 WHERE pk = ?", "ks4", "t4"},
+		{"SELECT * FROM t5", "", "t5"},
+		{"TRUNCATE ks6.t6", "", ""},
+	}
+	for _, test := range tests {
+		qry := db.Query(test.stmt)
+		if keyspace, table := qry.Keyspace(), qry.Table(); keyspace != test.keyspace || table != test.table {
+			t.Errorf("%s: expected %s.%s got %s.%s", test.stmt, test.keyspace, test.table, keyspace, table)
+		}
+	}
+
+	// the batch is routed by its first statement once its routing key is
+	// computed, the test server preparing the statements on ks.t
+	b := db.NewBatch(LoggedBatch)
+	b.Query("INSERT INTO other.t (pk) VALUES (:pk)", 1)
+	if keyspace := b.Keyspace(); keyspace != "" {
+		t.Errorf("expected no keyspace got %q", keyspace)
+	}
+	if _, err := b.GetRoutingKey(); err != nil {
+		t.Fatal(err)
+	}
+	if keyspace, table := b.Keyspace(), b.Table(); keyspace != "ks" || table != "t" {
+		t.Errorf("expected ks.t got %s.%s", keyspace, table)
+	}
+}
+
+func TestRoutingKeyInfoMaterializedView(t *testing.T) {
+	srv := NewTestServer(t, protoVersion3, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion3, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	// the test server prepares statements on ks.t, here a view of ks.base
+	// partitioned by v instead of pk
+	keyspace := &KeyspaceMetadata{Name: "ks"}
+	column := func(table, name string, kind ColumnKind) ColumnMetadata {
+		return ColumnMetadata{Keyspace: "ks", Table: table, Name: name, Kind: kind, ClusteringOrder: "none", Validator: "int"}
+	}
+	compileMetadata(protoVersion3, keyspace,
+		[]TableMetadata{{Keyspace: "ks", Name: "base"}, {Keyspace: "ks", Name: "t"}},
+		[]ColumnMetadata{
+			column("base", "pk", ColumnPartitionKey),
+			column("base", "v", ColumnRegular),
+			column("t", "v", ColumnPartitionKey),
+			column("t", "pk", ColumnClusteringKey),
+		},
+		nil, nil, nil,
+		[]MaterializedViewMetadata{{Keyspace: "ks", Name: "t", baseTableName: "base"}},
+		db.logger)
+	if view := keyspace.MaterializedViews["t"]; len(view.PartitionKey) != 1 || view.PartitionKey[0].Name != "v" || view.BaseTable != keyspace.Tables["base"] {
+		t.Fatalf("unexpected view metadata %+v", view)
+	}
+	db.schemaDescriber.cache["ks"] = keyspace
+
+	info, err := db.Query("SELECT * FROM t WHERE pk = :pk AND v = :v").RoutingKeyInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil || info.Table != "t" || !reflect.DeepEqual(info.Indexes, []int{1}) {
+		t.Fatalf("expected the routing key of the view, got %+v", info)
+	}
+}
+
+func TestSessionSerialConsistency(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(protoVersion4, srv.Address)
+	cluster.SerialConsistency = SerialConsistency(Quorum)
+	if _, err := cluster.CreateSession(); err == nil {
+		t.Fatal("expected an error for an invalid serial consistency")
+	}
+
+	cluster.SerialConsistency = LocalSerial
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	if cons := db.Query("UPDATE t SET v = 1 WHERE id = 1 IF v = 0").serialCons; cons != LocalSerial {
+		t.Errorf("expected query serial consistency %v got %v", LocalSerial, cons)
+	}
+	if cons := db.Query("UPDATE t SET v = 1 WHERE id = 1 IF v = 0").SerialConsistency(Serial).serialCons; cons != Serial {
+		t.Errorf("expected overridden query serial consistency %v got %v", Serial, cons)
+	}
+	if cons := db.NewBatch(LoggedBatch).serialCons; cons != LocalSerial {
+		t.Errorf("expected batch serial consistency %v got %v", LocalSerial, cons)
+	}
+}
+
+func TestQueryPreparedInfo(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	qry := db.Query("INSERT INTO t (a, b) VALUES (:a, :b)", 1, 2)
+	if _, ok := qry.PreparedInfo(); ok {
+		t.Fatal("expected no prepared info before preparation")
+	}
+	if err := qry.Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, ok := qry.PreparedInfo()
+	if !ok {
+		t.Fatal("expected prepared info once executed")
+	}
+	if len(info.Id) == 0 {
+		t.Error("expected a prepared id")
+	}
+	if len(info.Args) != 2 {
+		t.Fatalf("expected 2 bind markers, got %v", info.Args)
+	}
+	for i, name := range []string{"a", "b"} {
+		col := info.Args[i]
+		if col.Name != name || col.Keyspace != "ks" || col.Table != "t" || col.TypeInfo.Type() != TypeInt {
+			t.Errorf("unexpected column %d: %v", i, col)
+		}
+	}
+	if len(info.Rval) != 0 {
+		t.Errorf("expected no result columns, got %v", info.Rval)
+	}
+	if want := []BindMarker{{Name: "a", Named: true}, {Name: "b", Named: true}}; !reflect.DeepEqual(info.Markers, want) {
+		t.Errorf("expected the markers %+v got %+v", want, info.Markers)
+	}
+
+	// the returned metadata is a copy
+	info.Args[0].Name = "changed"
+	info.Markers[0].Name = "changed"
+	if info, _ := qry.PreparedInfo(); info.Args[0].Name != "a" || info.Markers[0].Name != "a" {
+		t.Error("prepared info was modified through a returned copy")
+	}
+}
+
+func TestSessionInvalidatePrepared(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	const stmt = "INSERT INTO t (c0) VALUES (?)"
+	for _, qry := range []*Query{db.Query(stmt, 1), db.Query("INSERT INTO t (c0, c1) VALUES (?, ?)", 1, 2)} {
+		if err := qry.Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := db.InvalidatePrepared(stmt); n != 1 {
+		t.Fatalf("expected 1 statement invalidated, got %d", n)
+	}
+	if n := db.InvalidatePrepared(stmt); n != 0 {
+		t.Fatalf("expected no statement invalidated, got %d", n)
+	}
+	if n := db.InvalidateKeyspacePrepared(""); n != 1 {
+		t.Fatalf("expected 1 statement invalidated in the session keyspace, got %d", n)
+	}
+
+	if stmts := db.PreparedStatements(); len(stmts) != 0 {
+		t.Fatalf("expected no cached statement got %+v", stmts)
+	}
+
+	misses := db.PreparedCacheStats().Misses
+	if err := db.Query(stmt, 1).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := db.PreparedCacheStats(); stats.Misses != misses+1 || stats.Size != 1 {
+		t.Fatalf("expected the statement to be prepared again, got %+v", stats)
+	}
+	if stmts := db.PreparedStatements(); len(stmts) != 1 || stmts[0].Statement != stmt || stmts[0].PreparedAt.IsZero() {
+		t.Fatalf("expected the statement to be listed got %+v", stmts)
+	}
+}
+
+func TestQueryPrepareOnAllHosts(t *testing.T) {
+	srv1 := NewTestServer(t, protoVersion4, context.Background())
+	defer srv1.Stop()
+	srv2 := NewTestServer(t, protoVersion4, context.Background())
+	defer srv2.Stop()
+
+	db, err := testCluster(protoVersion4, srv1.Address, srv2.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	waitForSize := func(size int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for db.PreparedCacheStats().Size != size {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d prepared statements, got %+v", size, db.PreparedCacheStats())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if err := db.Query("INSERT INTO t (c0) VALUES (?)", 1).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	waitForSize(1)
+
+	if err := db.Query("INSERT INTO t (c1) VALUES (?)", 1).PrepareOnAllHosts(true).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	waitForSize(3)
+}
+
+type hostEventPolicy struct {
+	HostSelectionPolicy
+	mu     sync.Mutex
+	events []string
+}
+
+func (p *hostEventPolicy) record(event string) {
+	p.mu.Lock()
+	p.events = append(p.events, event)
+	p.mu.Unlock()
+}
+
+func (p *hostEventPolicy) has(event string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, e := range p.events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *hostEventPolicy) HostUp(host *HostInfo) {
+	p.record("up")
+	p.HostSelectionPolicy.HostUp(host)
+}
+
+func (p *hostEventPolicy) HostDown(host *HostInfo) {
+	p.record("down")
+	p.HostSelectionPolicy.HostDown(host)
+}
+
+func TestSessionInjectStatusEvent(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	policy := &hostEventPolicy{HostSelectionPolicy: RoundRobinHostPolicy()}
+	cluster := testCluster(protoVersion4, srv.Address)
+	cluster.PoolConfig.HostSelectionPolicy = policy
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	host := db.ring.allHosts()[0]
+	// skip the delay before connecting to nodes reported up
+	host.mu.Lock()
+	host.version = cassVersion{Major: 3, Minor: 11}
+	host.mu.Unlock()
+	if err := db.InjectStatusEvent("GONE", host.nodeToNodeAddress(), host.Port()); err == nil {
+		t.Fatal("expected an error for an invalid status change")
+	}
+
+	if err := db.InjectStatusEvent("DOWN", host.nodeToNodeAddress(), host.Port()); err != nil {
+		t.Fatal(err)
+	}
+	if host.IsUp() || !policy.has("down") {
+		t.Fatal("expected the host to be marked down")
+	}
+	if _, ok := db.pool.getPool(host); ok {
+		t.Fatal("expected the pool of the host to be removed")
+	}
+	if err := db.Query("void").Exec(); err == nil {
+		t.Fatal("expected the query to fail with no host up")
+	}
+
+	if err := db.InjectStatusEvent("UP", host.nodeToNodeAddress(), host.Port()); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !host.IsUp() || !policy.has("up") {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the host to be marked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := db.Query("void").Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+	if err := db.InjectStatusEvent("UP", host.nodeToNodeAddress(), host.Port()); err != ErrSessionClosed {
+		t.Fatalf("expected %v got %v", ErrSessionClosed, err)
+	}
+}
+
+func TestQueryBindMap(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	// the markers of the statements prepared by the test server are named c0, c1...
+	stmt := "INSERT INTO t (c0, c1) VALUES (:c0, :c1)"
+	if err := db.Query(stmt).BindMap(map[string]interface{}{"c1": 2, "c0": 1}).Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+	}{
+		{"missing", map[string]interface{}{"c0": 1}},
+		{"unknown", map[string]interface{}{"c0": 1, "c1": 2, "c2": 3}},
+		{"type", map[string]interface{}{"c0": 1, "c1": "abc"}},
+	}
+	for _, test := range tests {
+		if err := db.Query(stmt).BindMap(test.values).Exec(); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestBindNamedValues(t *testing.T) {
+	args := []ColumnInfo{{Name: "id"}, {Name: "name"}, {Name: "id"}}
+	values, err := bindNamedValues(&QueryInfo{Args: args}, map[string]interface{}{"name": "alice", "id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 3 || values[0] != 1 || values[1] != "alice" || values[2] != 1 {
+		t.Fatalf("expected the values in the order of the markers got %v", values)
+	}
+}
+
+func TestContextVariants(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := db.QueryContext(canceled, "void").Exec(); err != context.Canceled {
+		t.Fatalf("expected %v got %v", context.Canceled, err)
+	}
+
+	q := db.Query("void")
+	if err := q.ExecContext(canceled); err != context.Canceled {
+		t.Fatalf("expected %v got %v", context.Canceled, err)
+	}
+	if err := q.IterContext(canceled).Close(); err != context.Canceled {
+		t.Fatalf("expected %v got %v", context.Canceled, err)
+	}
+	if q.Context() != context.Background() {
+		t.Fatal("expected the context of the query to be left unchanged")
+	}
+	if err := q.ExecContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	b := db.NewBatch(LoggedBatch)
+	b.Query("void")
+	if err := db.ExecuteBatchContext(canceled, b); err != context.Canceled {
+		t.Fatalf("expected %v got %v", context.Canceled, err)
+	}
+	if b.Context() != context.Background() {
+		t.Fatal("expected the context of the batch to be left unchanged")
+	}
+}
+
+func TestSessionRefreshRing(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := db.RefreshRing(ctx); err != errNoControl {
+		t.Fatalf("expected %v without a control connection got %v", errNoControl, err)
+	}
+
+	release := make(chan struct{})
+	refreshes := make(chan struct{}, 10)
+	db.ringRefresher.stop()
+	db.ringRefresher = newRefreshDebouncer(time.Hour, 0, func() error {
+		refreshes <- struct{}{}
+		<-release
+		return errors.New("refresh failed")
+	})
+	db.control = &controlConn{}
+	defer func() { db.control = nil }()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := db.RefreshRing(timeoutCtx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+	<-refreshes
+
+	done := make(chan error, 1)
+	go func() { done <- db.RefreshRing(ctx) }()
+	close(release)
+	if err := <-done; err == nil || err.Error() != "refresh failed" {
+		t.Fatalf("expected the error of the refresh got %v", err)
+	}
+}
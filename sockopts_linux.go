@@ -0,0 +1,38 @@
+//go:build linux
+// +build linux
+
+package gocql
+
+import (
+	"syscall"
+	"time"
+)
+
+// setKeepaliveParams sets the interval between TCP keepalive probes and the
+// number of unacknowledged probes after which the connection is dropped.
+// Zero values leave the system defaults untouched.
+func setKeepaliveParams(c syscall.RawConn, interval time.Duration, count int) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		if interval > 0 {
+			secs := int(interval / time.Second)
+			if secs < 1 {
+				secs = 1
+			}
+			if sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, secs); sockErr != nil {
+				return
+			}
+		}
+		if count > 0 {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, count)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+func keepaliveParamsSupported() bool {
+	return true
+}
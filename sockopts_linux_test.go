@@ -0,0 +1,60 @@
+//go:build linux && (all || unit)
+// +build linux
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSocketKeepaliveParams(t *testing.T) {
+	srv := NewTestServer(t, defaultProto, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(defaultProto, srv.Address)
+	cluster.NumConns = 1
+	cluster.SocketKeepalive = 30 * time.Second
+	cluster.SocketKeepaliveInterval = 5 * time.Second
+	cluster.SocketKeepaliveCount = 3
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	pool, ok := db.pool.getPool(db.ring.allHosts()[0])
+	if !ok {
+		t.Fatal("no pool for host")
+	}
+	conn := pool.Pick()
+	if conn == nil {
+		t.Fatal("no connection")
+	}
+	rawConn, err := conn.conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the options must survive the keepalive defaults set by net.Dialer
+	var interval, count int
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		if interval, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL); sockErr != nil {
+			return
+		}
+		count, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT)
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if sockErr != nil {
+		t.Fatal(sockErr)
+	}
+	if interval != 5 || count != 3 {
+		t.Fatalf("expected a keepalive interval of 5s and 3 probes got %ds and %d probes", interval, count)
+	}
+}
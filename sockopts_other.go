@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package gocql
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+func setKeepaliveParams(c syscall.RawConn, interval time.Duration, count int) error {
+	return errors.New("gocql: keepalive interval and count are not supported on this platform")
+}
+
+func keepaliveParamsSupported() bool {
+	return false
+}
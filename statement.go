@@ -0,0 +1,107 @@
+package gocql
+
+import "context"
+
+// Statement is an immutable template of a query: its CQL statement and
+// options are set once and each execution gets its own Query, with its own
+// values and context. Unlike a Query, a Statement is safe for concurrent use
+// by multiple goroutines:
+//
+//	getUser := session.Statement("SELECT name FROM users WHERE id = ?").
+//		WithConsistency(gocql.LocalQuorum).
+//		WithIdempotent(true)
+//
+//	// in any goroutine
+//	err := getUser.Query(ctx, id).Scan(&name)
+//
+// The With methods return a new Statement and leave the receiver unchanged.
+type Statement struct {
+	session *Session
+	stmt    string
+	opts    []func(*Query)
+}
+
+// Statement returns a template of queries executing stmt with the defaults of
+// the session.
+func (s *Session) Statement(stmt string) *Statement {
+	return &Statement{session: s, stmt: stmt}
+}
+
+// String returns the CQL statement of the template.
+func (st *Statement) String() string {
+	return st.stmt
+}
+
+func (st *Statement) with(opt func(*Query)) *Statement {
+	opts := make([]func(*Query), len(st.opts), len(st.opts)+1)
+	copy(opts, st.opts)
+	return &Statement{session: st.session, stmt: st.stmt, opts: append(opts, opt)}
+}
+
+// WithConsistency returns a template with the consistency of the queries set,
+// see Query.Consistency.
+func (st *Statement) WithConsistency(c Consistency) *Statement {
+	return st.with(func(q *Query) { q.Consistency(c) })
+}
+
+// WithSerialConsistency returns a template with the serial consistency of
+// the queries set, see Query.SerialConsistency.
+func (st *Statement) WithSerialConsistency(cons SerialConsistency) *Statement {
+	return st.with(func(q *Query) { q.SerialConsistency(cons) })
+}
+
+// WithPageSize returns a template with the page size of the queries set, see
+// Query.PageSize.
+func (st *Statement) WithPageSize(n int) *Statement {
+	return st.with(func(q *Query) { q.PageSize(n) })
+}
+
+// WithIdempotent returns a template with the idempotence of the queries set,
+// see Query.Idempotent.
+func (st *Statement) WithIdempotent(value bool) *Statement {
+	return st.with(func(q *Query) { q.Idempotent(value) })
+}
+
+// WithRetryPolicy returns a template with the retry policy of the queries
+// set, see Query.RetryPolicy. The policy must be safe for concurrent use.
+func (st *Statement) WithRetryPolicy(r RetryPolicy) *Statement {
+	return st.with(func(q *Query) { q.RetryPolicy(r) })
+}
+
+// WithSpeculativeExecutionPolicy returns a template with the speculative
+// execution policy of the queries set, see
+// Query.SetSpeculativeExecutionPolicy.
+func (st *Statement) WithSpeculativeExecutionPolicy(sp SpeculativeExecutionPolicy) *Statement {
+	return st.with(func(q *Query) { q.SetSpeculativeExecutionPolicy(sp) })
+}
+
+// WithObserver returns a template with the observer of the queries set, see
+// Query.Observer. The observer must be safe for concurrent use.
+func (st *Statement) WithObserver(observer QueryObserver) *Statement {
+	return st.with(func(q *Query) { q.Observer(observer) })
+}
+
+// Query returns a new query executing the statement with the values and ctx.
+// The query belongs to the caller, it may be further modified and released.
+func (st *Statement) Query(ctx context.Context, values ...interface{}) *Query {
+	q := st.session.Query(st.stmt, values...)
+	q.context = ctx
+	for _, opt := range st.opts {
+		opt(q)
+	}
+	return q
+}
+
+// Exec executes the statement with the values and ctx without returning any
+// rows.
+func (st *Statement) Exec(ctx context.Context, values ...interface{}) error {
+	q := st.Query(ctx, values...)
+	defer q.Release()
+	return q.Exec()
+}
+
+// Iter executes the statement with the values and ctx and returns an
+// iterator over the rows.
+func (st *Statement) Iter(ctx context.Context, values ...interface{}) *Iter {
+	return st.Query(ctx, values...).Iter()
+}
@@ -0,0 +1,60 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestStatement(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	base := db.Statement("void")
+	st := base.WithConsistency(LocalQuorum).WithPageSize(10).WithIdempotent(true)
+	if q := base.Query(context.Background()); q.GetConsistency() != db.cons || q.IsIdempotent() {
+		t.Fatal("expected the base template to be left unchanged")
+	}
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, 1)
+	q := st.Query(ctx, 1, 2)
+	if q.GetConsistency() != LocalQuorum || q.pageSize != 10 || !q.IsIdempotent() {
+		t.Fatalf("expected the options of the template got consistency %v page size %d idempotent %v", q.GetConsistency(), q.pageSize, q.IsIdempotent())
+	}
+	if q.Context() != ctx || len(q.Values()) != 2 || q.Statement() != "void" {
+		t.Fatal("expected the query to have the statement, values and context")
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- st.Exec(context.Background())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := st.Iter(canceled).Close(); err != context.Canceled {
+		t.Fatalf("expected %v got %v", context.Canceled, err)
+	}
+}
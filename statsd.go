@@ -0,0 +1,119 @@
+package gocql
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultStatsdTagNames are the tags of the attributes of the metrics of
+// DBClientMetrics sent by StatsdRecorder, by attribute.
+var DefaultStatsdTagNames = map[string]string{
+	"db.operation.name":              "operation",
+	"db.namespace":                   "keyspace",
+	"db.collection.name":             "table",
+	"server.address":                 "host",
+	"db.cassandra.coordinator.dc":    "dc",
+	"error.type":                     "error",
+	"db.client.connection.pool.name": "pool",
+	"db.client.connection.state":     "state",
+}
+
+// StatsdOptions configures a StatsdRecorder.
+type StatsdOptions struct {
+	// Prefix is prepended to the names of the metrics, as in "myapp.".
+	Prefix string
+	// Tags are sent with every metric, as in "cluster:production".
+	Tags []string
+	// TagNames are the names of the tags of the attributes of the metrics,
+	// by attribute. The attributes which are not in TagNames are not sent.
+	// (default: DefaultStatsdTagNames)
+	TagNames map[string]string
+}
+
+// StatsdRecorder is a MetricsRecorder sending metrics in the StatsD protocol,
+// with tags in the DogStatsD format understood by the Datadog agent, the
+// Prometheus statsd_exporter and Telegraf. Histograms are sent as timers in
+// milliseconds, and gauges as gauges.
+//
+// Use it with NewDBClientMetrics, for example:
+//
+//	conn, err := net.Dial("udp", "127.0.0.1:8125")
+//	...
+//	metrics := gocql.NewDBClientMetrics(gocql.NewStatsdRecorder(conn, gocql.StatsdOptions{
+//		Prefix: "myapp.",
+//		Tags:   []string{"cluster:production"},
+//	}))
+//	cluster.QueryObserver = metrics
+//	cluster.BatchObserver = metrics
+type StatsdRecorder struct {
+	w    io.Writer
+	opts StatsdOptions
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// NewStatsdRecorder returns a recorder writing each metric to w, usually a
+// UDP connection to a StatsD server. Write errors are ignored, as metrics
+// sent over UDP may be lost anyway.
+func NewStatsdRecorder(w io.Writer, opts StatsdOptions) *StatsdRecorder {
+	if opts.TagNames == nil {
+		opts.TagNames = DefaultStatsdTagNames
+	}
+	return &StatsdRecorder{w: w, opts: opts}
+}
+
+// RecordHistogram sends value, in seconds, as a timer in milliseconds.
+func (r *StatsdRecorder) RecordHistogram(ctx context.Context, name string, value float64, attrs []MetricAttribute) {
+	r.send(name, strconv.FormatFloat(value*1000, 'f', -1, 64), "ms", attrs)
+}
+
+// RecordGauge sends value as a gauge.
+func (r *StatsdRecorder) RecordGauge(ctx context.Context, name string, value int64, attrs []MetricAttribute) {
+	r.send(name, strconv.FormatInt(value, 10), "g", attrs)
+}
+
+func (r *StatsdRecorder) send(name, value, typ string, attrs []MetricAttribute) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf := append(r.buf[:0], r.opts.Prefix...)
+	buf = append(buf, name...)
+	buf = append(buf, ':')
+	buf = append(buf, value...)
+	buf = append(buf, '|')
+	buf = append(buf, typ...)
+
+	tagged := false
+	addTag := func(tag string) {
+		if tagged {
+			buf = append(buf, ',')
+		} else {
+			buf = append(buf, "|#"...)
+			tagged = true
+		}
+		buf = append(buf, tag...)
+	}
+	for _, tag := range r.opts.Tags {
+		addTag(statsdEscape(tag))
+	}
+	for _, attr := range attrs {
+		if tag, ok := r.opts.TagNames[attr.Key]; ok {
+			addTag(tag + ":" + statsdEscape(attr.Value))
+		}
+	}
+
+	r.buf = buf
+	r.w.Write(buf)
+}
+
+// statsdEscape replaces the characters separating the fields and tags of
+// metrics in s.
+func statsdEscape(s string) string {
+	return statsdReplacer.Replace(s)
+}
+
+var statsdReplacer = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_")
@@ -0,0 +1,52 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"testing"
+)
+
+type statsdLines []string
+
+func (l *statsdLines) Write(p []byte) (int, error) {
+	*l = append(*l, string(p))
+	return len(p), nil
+}
+
+func TestStatsdRecorder(t *testing.T) {
+	var lines statsdLines
+	recorder := NewStatsdRecorder(&lines, StatsdOptions{
+		Prefix: "app.",
+		Tags:   []string{"cluster:prod"},
+	})
+
+	recorder.RecordHistogram(context.Background(), MetricOperationDuration, 0.0025, []MetricAttribute{
+		{Key: "db.system", Value: "cassandra"},
+		{Key: "db.operation.name", Value: "SELECT"},
+		{Key: "server.address", Value: "10.0.0.1"},
+		{Key: "db.cassandra.coordinator.dc", Value: "dc1"},
+		{Key: "error.type", Value: "*gocql.a|b,c"},
+	})
+	recorder.RecordGauge(context.Background(), MetricConnectionMax, 2, nil)
+
+	recorder = NewStatsdRecorder(&lines, StatsdOptions{TagNames: map[string]string{}})
+	recorder.RecordGauge(context.Background(), MetricPendingRequests, 0, []MetricAttribute{
+		{Key: "db.client.connection.pool.name", Value: "10.0.0.1:9042"},
+	})
+
+	expected := []string{
+		"app.db.client.operation.duration:2.5|ms|#cluster:prod,operation:SELECT,host:10.0.0.1,dc:dc1,error:*gocql.a_b_c",
+		"app.db.client.connection.max:2|g|#cluster:prod",
+		"db.client.connection.pending_requests:0|g",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %q", len(expected), lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], lines[i])
+		}
+	}
+}
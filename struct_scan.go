@@ -0,0 +1,159 @@
+package gocql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// StructMappingMode sets how the columns of a row and the fields of a struct
+// must match when the row is scanned into the struct.
+type StructMappingMode int
+
+const (
+	// StructLenient ignores the columns with no field and the fields with no
+	// column.
+	StructLenient StructMappingMode = iota
+	// StructStrict fails if a column has no field or if a field with a cql tag
+	// has no column, to detect schema drift.
+	StructStrict
+	// StructAllowExtra ignores the columns with no field but fails if a field
+	// with a cql tag has no column.
+	StructAllowExtra
+)
+
+// StructMapping maps the columns of rows to the fields of structs for
+// ScanStruct. A field is mapped to the column named by its cql tag, or to
+// the name of the field converted by NameMapper. Fields tagged `cql:"-"`
+// and unexported fields are ignored, the fields of embedded structs are
+// mapped as fields of the struct.
+type StructMapping struct {
+	Mode StructMappingMode
+	// NameMapper returns the column of a field with no cql tag from the name
+	// of the field, such as SnakeCase. Defaults to strings.ToLower.
+	NameMapper func(field string) string
+}
+
+// SnakeCase converts a field name to a snake case column name, such as
+// UserID to user_id.
+func SnakeCase(field string) string {
+	runes := []rune(field)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// a new word starts at an upper case letter following a lower case
+			// one, or preceding one in an acronym, as in HTTPServer
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type structField struct {
+	index  []int
+	tagged bool
+}
+
+// fields returns the fields of the struct type by column.
+func (m *StructMapping) fields(t reflect.Type) map[string]structField {
+	mapper := strings.ToLower
+	if m != nil && m.NameMapper != nil {
+		mapper = m.NameMapper
+	}
+	fields := make(map[string]structField)
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("cql")
+			if tag == "-" {
+				continue
+			}
+			fieldIndex := append(append([]int(nil), index...), i)
+			if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct {
+				walk(sf.Type, fieldIndex)
+				continue
+			}
+			if sf.PkgPath != "" {
+				continue
+			}
+			name := tag
+			if name == "" {
+				name = mapper(sf.Name)
+			}
+			if _, ok := fields[name]; !ok || tag != "" {
+				fields[name] = structField{index: fieldIndex, tagged: tag != ""}
+			}
+		}
+	}
+	walk(t, nil)
+	return fields
+}
+
+// ScanStruct unmarshals the columns of the row into the fields of the struct
+// pointed at by dest, as mapped by mapping. A nil mapping is lenient.
+func (r *LazyRow) ScanStruct(dest interface{}, mapping *StructMapping) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gocql: can not scan a row into %T, want a pointer to a struct", dest)
+	}
+	v = v.Elem()
+
+	mode := StructLenient
+	if mapping != nil {
+		mode = mapping.Mode
+	}
+	fields := mapping.fields(v.Type())
+	seen := make(map[string]bool, len(r.columns))
+	for i, col := range r.columns {
+		field, ok := fields[col.Name]
+		if !ok {
+			if mode == StructStrict {
+				return fmt.Errorf("gocql: no field of %s for column %q", v.Type(), col.Name)
+			}
+			continue
+		}
+		seen[col.Name] = true
+		cell, err := r.cell(i)
+		if err != nil {
+			return err
+		}
+		if err := Unmarshal(col.TypeInfo, cell, v.FieldByIndex(field.index).Addr().Interface()); err != nil {
+			return fmt.Errorf("gocql: can not scan column %q: %v", col.Name, err)
+		}
+	}
+
+	if mode != StructLenient {
+		for name, field := range fields {
+			if field.tagged && !seen[name] {
+				return fmt.Errorf("gocql: no column for field %s.%s tagged %q", v.Type(), v.Type().FieldByIndex(field.index).Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// ScanStruct consumes the next row of the iterator and copies its columns
+// into the fields of the struct pointed at by dest, as mapped by mapping. A
+// nil mapping is lenient.
+//
+// ScanStruct returns true if the row was successfully unmarshaled or false if
+// the end of the result set was reached or if an error occurred. Close should
+// be called afterwards to retrieve any potential errors.
+func (iter *Iter) ScanStruct(dest interface{}, mapping *StructMapping) bool {
+	var row LazyRow
+	if !iter.NextRow(&row) {
+		return false
+	}
+	if err := row.ScanStruct(dest, mapping); err != nil {
+		iter.err = err
+		return false
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"strings"
+	"testing"
+)
+
+type scanBase struct {
+	ID int `cql:"id"`
+}
+
+type scanUser struct {
+	scanBase
+	UserName string
+	Age      int    `cql:"age"`
+	Ignored  string `cql:"-"`
+	internal string
+}
+
+func newStructScanRow(names ...string) *LazyRow {
+	row := &LazyRow{}
+	for _, name := range names {
+		typ := NewNativeType(protoVersion4, TypeInt, "")
+		cell, _ := Marshal(typ, 7)
+		if name == "user_name" || name == "username" || name == "ignored" {
+			typ = NewNativeType(protoVersion4, TypeVarchar, "")
+			cell = []byte("alice")
+		}
+		row.columns = append(row.columns, ColumnInfo{Name: name, TypeInfo: typ})
+		row.cells = append(row.cells, cell)
+	}
+	return row
+}
+
+func TestLazyRowScanStruct(t *testing.T) {
+	var u scanUser
+	if err := newStructScanRow("id", "username", "age").ScanStruct(&u, nil); err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 7 || u.UserName != "alice" || u.Age != 7 {
+		t.Fatalf("unexpected scan %+v", u)
+	}
+
+	u = scanUser{}
+	snake := &StructMapping{Mode: StructStrict, NameMapper: SnakeCase}
+	if err := newStructScanRow("id", "user_name", "age").ScanStruct(&u, snake); err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 7 || u.UserName != "alice" || u.Age != 7 {
+		t.Fatalf("unexpected scan %+v", u)
+	}
+
+	tests := []struct {
+		columns []string
+		mode    StructMappingMode
+		err     string
+	}{
+		{[]string{"id", "age", "ignored"}, StructLenient, ""},
+		{[]string{"id"}, StructLenient, ""},
+		{[]string{"id", "age", "ignored"}, StructAllowExtra, ""},
+		{[]string{"id"}, StructAllowExtra, `no column for field gocql.scanUser.Age tagged "age"`},
+		{[]string{"id", "age", "ignored"}, StructStrict, `no field of gocql.scanUser for column "ignored"`},
+		{[]string{"id", "age"}, StructStrict, ""},
+	}
+	for _, test := range tests {
+		err := newStructScanRow(test.columns...).ScanStruct(&scanUser{}, &StructMapping{Mode: test.mode})
+		if test.err == "" && err != nil {
+			t.Errorf("%v mode %d: %v", test.columns, test.mode, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%v mode %d: expected error %q got %v", test.columns, test.mode, test.err, err)
+		}
+	}
+
+	if err := newStructScanRow("id").ScanStruct(u, nil); err == nil {
+		t.Fatal("expected an error scanning into a non pointer")
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"Name":       "name",
+		"UserName":   "user_name",
+		"UserID":     "user_id",
+		"HTTPServer": "http_server",
+		"Address2":   "address2",
+		"V2Name":     "v2_name",
+	}
+	for field, expected := range tests {
+		if got := SnakeCase(field); got != expected {
+			t.Errorf("%s: expected %s got %s", field, expected, got)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package gocql
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (cfg *ClusterConfig) validateTableConsistency() error {
+	for name := range cfg.TableConsistency {
+		if !strings.Contains(name, ".") {
+			return fmt.Errorf("gocql: invalid table %q in TableConsistency, must be keyspace.table", name)
+		}
+	}
+	for name, cons := range cfg.TableSerialConsistency {
+		if !strings.Contains(name, ".") {
+			return fmt.Errorf("gocql: invalid table %q in TableSerialConsistency, must be keyspace.table", name)
+		}
+		if cons != Serial && cons != LocalSerial {
+			return fmt.Errorf("gocql: invalid serial consistency %v for table %q, must be SERIAL or LOCAL_SERIAL", cons, name)
+		}
+	}
+	return nil
+}
+
+// tableConsistency sets the consistencies of the table of the statement of q
+// configured in ClusterConfig.TableConsistency and TableSerialConsistency.
+func (q *Query) tableConsistency() {
+	keyspace, table := q.statementTable()
+	if table == "" {
+		return
+	}
+	cfg := &q.session.cfg
+	if keyspace == "" {
+		keyspace = cfg.Keyspace
+	}
+
+	name := keyspace + "." + table
+	if cons, ok := cfg.TableConsistency[name]; ok {
+		q.cons = cons
+	}
+	if cons, ok := cfg.TableSerialConsistency[name]; ok {
+		q.serialCons = cons
+	}
+}
+
+// statementTable returns the table a SELECT, INSERT, UPDATE or DELETE
+// statement is executed against, and its keyspace if the statement names it.
+// It returns an empty table for other statements.
+func statementTable(stmt string) (keyspace, table string) {
+	for i := 0; ; {
+		tok, quoted, next := nextToken(stmt, i)
+		if tok == "" && !quoted {
+			return "", ""
+		}
+		i = next
+		if quoted || tok != "from" && tok != "into" && tok != "update" {
+			continue
+		}
+
+		name, quoted, next := nextToken(stmt, i)
+		if !quoted && (name == "" || !isIdentStart(name[0])) {
+			return "", ""
+		}
+		if dot, quotedDot, next := nextToken(stmt, next); dot == "." && !quotedDot {
+			keyspace = name
+			name, quoted, _ = nextToken(stmt, next)
+			if !quoted && (name == "" || !isIdentStart(name[0])) {
+				return "", ""
+			}
+		}
+		return keyspace, name
+	}
+}
+
+// nextToken returns the token of stmt starting at or after i, and the index
+// following it, skipping whitespaces and comments. Unquoted identifiers and
+// keywords are lower case, quoted identifiers are unquoted and string literals
+// are returned as a single quote. tok is empty and quoted false at the end of
+// stmt.
+func nextToken(stmt string, i int) (tok string, quoted bool, next int) {
+	for i < len(stmt) {
+		switch c := stmt[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(stmt[i:], "--") || strings.HasPrefix(stmt[i:], "//"):
+			end := strings.IndexByte(stmt[i:], '\n')
+			if end < 0 {
+				return "", false, len(stmt)
+			}
+			i += end + 1
+		case strings.HasPrefix(stmt[i:], "/*"):
+			end := strings.Index(stmt[i+2:], "*/")
+			if end < 0 {
+				return "", false, len(stmt)
+			}
+			i += end + 4
+		case c == '"':
+			end := skipQuoted(stmt, i, '"')
+			if end >= len(stmt) {
+				return "", false, len(stmt)
+			}
+			return strings.Replace(stmt[i+1:end], `""`, `"`, -1), true, end + 1
+		case c == '\'':
+			return "'", false, skipQuoted(stmt, i, '\'') + 1
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(stmt) && isIdentChar(stmt[end]) {
+				end++
+			}
+			return strings.ToLower(stmt[i:end]), false, end
+		default:
+			return stmt[i : i+1], false, i + 1
+		}
+	}
+	return "", false, i
+}
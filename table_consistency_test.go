@@ -0,0 +1,94 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStatementTable(t *testing.T) {
+	tests := []struct {
+		stmt     string
+		keyspace string
+		table    string
+	}{
+		{"SELECT * FROM t WHERE id = ?", "", "t"},
+		{"select a, b from ks.t", "ks", "t"},
+		{"SELECT from_date FROM KS.Events", "ks", "events"},
+		{`SELECT * FROM "Ks"."My""Table"`, "Ks", `My"Table`},
+		{"INSERT INTO ks.t (a) VALUES ('from x')", "ks", "t"},
+		{"UPDATE t USING TTL 10 SET a = 1", "", "t"},
+		{"DELETE a FROM ks . t WHERE id = 1", "ks", "t"},
+		{"/* from x */ SELECT * -- from y\n FROM t", "", "t"},
+		{"CREATE TABLE ks.t (id int PRIMARY KEY)", "", ""},
+		{"SELECT now() FROM", "", ""},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		keyspace, table := statementTable(test.stmt)
+		if keyspace != test.keyspace || table != test.table {
+			t.Errorf("%q: expected %q.%q got %q.%q", test.stmt, test.keyspace, test.table, keyspace, table)
+		}
+	}
+}
+
+func TestTableConsistency(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(protoVersion4, srv.Address)
+	cluster.Consistency = LocalQuorum
+	cluster.TableConsistency = map[string]Consistency{
+		"app.accounts":     Quorum,
+		"metrics.requests": One,
+	}
+	cluster.TableSerialConsistency = map[string]SerialConsistency{
+		"app.accounts": LocalSerial,
+	}
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+	// the test server does not support USE
+	db.cfg.Keyspace = "app"
+
+	tests := []struct {
+		stmt   string
+		cons   Consistency
+		serial SerialConsistency
+	}{
+		{"SELECT * FROM accounts WHERE id = ?", Quorum, LocalSerial},
+		{"UPDATE app.accounts SET a = 1 WHERE id = 1 IF a = 0", Quorum, LocalSerial},
+		{"INSERT INTO metrics.requests (id) VALUES (1)", One, 0},
+		{"SELECT * FROM requests", LocalQuorum, 0},
+		{"SELECT * FROM system.local", LocalQuorum, 0},
+	}
+	for _, test := range tests {
+		qry := db.Query(test.stmt)
+		if qry.GetConsistency() != test.cons || qry.serialCons != test.serial {
+			t.Errorf("%q: expected %v/%v got %v/%v", test.stmt, test.cons, test.serial, qry.GetConsistency(), qry.serialCons)
+		}
+	}
+
+	// the consistency set on the query wins
+	if c := db.Query("SELECT * FROM accounts").Consistency(One).GetConsistency(); c != One {
+		t.Errorf("expected %v got %v", One, c)
+	}
+}
+
+func TestTableConsistencyValidation(t *testing.T) {
+	cluster := NewCluster("127.0.0.1")
+	cluster.TableConsistency = map[string]Consistency{"accounts": Quorum}
+	if _, err := NewSession(*cluster); err == nil {
+		t.Error("expected an error for a table without keyspace")
+	}
+
+	cluster = NewCluster("127.0.0.1")
+	cluster.TableSerialConsistency = map[string]SerialConsistency{"app.accounts": SerialConsistency(Quorum)}
+	if _, err := NewSession(*cluster); err == nil {
+		t.Error("expected an error for an invalid serial consistency")
+	}
+}
@@ -0,0 +1,271 @@
+package gocql
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+)
+
+const (
+	// scyllaTabletsRoutingV1 is the protocol extension negotiated in STARTUP,
+	// after which Scylla nodes send tablet information to the driver.
+	scyllaTabletsRoutingV1 = "TABLETS_ROUTING_V1"
+	// tabletsRoutingV1Payload is the custom payload key of the tablet information
+	// sent in responses to queries which were routed to a node which is not a replica.
+	tabletsRoutingV1Payload = "tablets-routing-v1"
+)
+
+// tabletReplica is a replica of a tablet.
+type tabletReplica struct {
+	hostID string
+	shard  int
+}
+
+// tablet is a range of tokens of a table which is replicated as a unit
+// in keyspaces using tablets. The tablet owns the tokens in (firstToken, lastToken].
+type tablet struct {
+	firstToken int64
+	lastToken  int64
+	replicas   []tabletReplica
+}
+
+var errInvalidTabletPayload = errors.New("gocql: invalid tablets routing payload")
+
+// serializedElems reads the [bytes] elements of a serialized tuple or list.
+type serializedElems []byte
+
+func (p *serializedElems) next() ([]byte, error) {
+	if len(*p) < 4 {
+		return nil, errInvalidTabletPayload
+	}
+	n := int(int32(binary.BigEndian.Uint32(*p)))
+	*p = (*p)[4:]
+	if n < 0 || len(*p) < n {
+		return nil, errInvalidTabletPayload
+	}
+	elem := (*p)[:n]
+	*p = (*p)[n:]
+	return elem, nil
+}
+
+func (p *serializedElems) nextFixed(size int) ([]byte, error) {
+	elem, err := p.next()
+	if err == nil && len(elem) != size {
+		err = errInvalidTabletPayload
+	}
+	return elem, err
+}
+
+// parseTabletPayload decodes the tablets-routing-v1 custom payload, which is the
+// serialized tuple<bigint, bigint, list<tuple<uuid, int>>> of the first token,
+// last token and replicas of the tablet.
+func parseTabletPayload(payload []byte) (tablet, error) {
+	p := serializedElems(payload)
+	first, err := p.nextFixed(8)
+	if err != nil {
+		return tablet{}, err
+	}
+	last, err := p.nextFixed(8)
+	if err != nil {
+		return tablet{}, err
+	}
+	list, err := p.next()
+	if err != nil || len(p) != 0 || len(list) < 4 {
+		return tablet{}, errInvalidTabletPayload
+	}
+
+	n := int(int32(binary.BigEndian.Uint32(list)))
+	if n < 0 {
+		return tablet{}, errInvalidTabletPayload
+	}
+	t := tablet{
+		firstToken: int64(binary.BigEndian.Uint64(first)),
+		lastToken:  int64(binary.BigEndian.Uint64(last)),
+		replicas:   make([]tabletReplica, 0, n),
+	}
+
+	elems := serializedElems(list[4:])
+	for i := 0; i < n; i++ {
+		replica, err := elems.next()
+		if err != nil {
+			return tablet{}, err
+		}
+		fields := serializedElems(replica)
+		hostID, err := fields.nextFixed(16)
+		if err != nil {
+			return tablet{}, err
+		}
+		shard, err := fields.nextFixed(4)
+		if err != nil || len(fields) != 0 {
+			return tablet{}, errInvalidTabletPayload
+		}
+		uuid, err := UUIDFromBytes(hostID)
+		if err != nil {
+			return tablet{}, errInvalidTabletPayload
+		}
+		t.replicas = append(t.replicas, tabletReplica{
+			hostID: uuid.String(),
+			shard:  int(int32(binary.BigEndian.Uint32(shard))),
+		})
+	}
+	if len(elems) != 0 {
+		return tablet{}, errInvalidTabletPayload
+	}
+
+	return t, nil
+}
+
+type tableName struct {
+	keyspace string
+	table    string
+}
+
+// tabletMap holds the tablets learned from the nodes, per table and sorted by their last token.
+type tabletMap struct {
+	mu      sync.RWMutex
+	tablets map[tableName][]tablet
+}
+
+func newTabletMap() *tabletMap {
+	return &tabletMap{tablets: make(map[tableName][]tablet)}
+}
+
+// add adds the tablet of the table, replacing the tablets it overlaps with.
+func (m *tabletMap) add(keyspace, table string, t tablet) {
+	name := tableName{keyspace: keyspace, table: table}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tablets := m.tablets[name]
+	updated := make([]tablet, 0, len(tablets)+1)
+	for _, existing := range tablets {
+		if existing.lastToken <= t.firstToken || existing.firstToken >= t.lastToken {
+			updated = append(updated, existing)
+		}
+	}
+	i := sort.Search(len(updated), func(i int) bool {
+		return updated[i].lastToken >= t.lastToken
+	})
+	updated = append(updated, tablet{})
+	copy(updated[i+1:], updated[i:])
+	updated[i] = t
+	m.tablets[name] = updated
+}
+
+// find returns the tablet of the table owning the token.
+func (m *tabletMap) find(keyspace, table string, token int64) (tablet, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tablets := m.tablets[tableName{keyspace: keyspace, table: table}]
+	i := sort.Search(len(tablets), func(i int) bool {
+		return tablets[i].lastToken >= token
+	})
+	if i < len(tablets) && tablets[i].firstToken < token {
+		return tablets[i], true
+	}
+	return tablet{}, false
+}
+
+// removeTablet removes the tablet of the table owning the token.
+func (m *tabletMap) removeTablet(keyspace, table string, token int64) {
+	name := tableName{keyspace: keyspace, table: table}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tablets := m.tablets[name]
+	for i, t := range tablets {
+		if t.firstToken < token && token <= t.lastToken {
+			updated := make([]tablet, 0, len(tablets)-1)
+			updated = append(updated, tablets[:i]...)
+			m.tablets[name] = append(updated, tablets[i+1:]...)
+			return
+		}
+	}
+}
+
+// removeTable removes the tablets of the table, or of all tables of the keyspace if table is empty.
+func (m *tabletMap) removeTable(keyspace, table string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name := range m.tablets {
+		if name.keyspace == keyspace && (table == "" || name.table == table) {
+			delete(m.tablets, name)
+		}
+	}
+}
+
+// removeHost removes the tablets replicated on the host.
+func (m *tabletMap) removeHost(hostID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, tablets := range m.tablets {
+		updated := make([]tablet, 0, len(tablets))
+		for _, t := range tablets {
+			if !t.hasReplica(hostID) {
+				updated = append(updated, t)
+			}
+		}
+		m.tablets[name] = updated
+	}
+}
+
+func (t tablet) hasReplica(hostID string) bool {
+	for _, r := range t.replicas {
+		if r.hostID == hostID {
+			return true
+		}
+	}
+	return false
+}
+
+// shardSelectedHost is a host selected by the token aware policy, together
+// with the token of the query and the shard which owns it, if known.
+type shardSelectedHost struct {
+	host  *HostInfo
+	token Token
+	// shard is the shard of the replica according to the tablet, or -1.
+	shard int
+
+	// tablets is set when the host was selected using a tablet, so the
+	// tablet can be invalidated when the host can not be reached.
+	tablets         *tabletMap
+	keyspace, table string
+}
+
+func (h *shardSelectedHost) Info() *HostInfo {
+	return h.host
+}
+
+func (h *shardSelectedHost) Mark(err error) {
+	if err == nil || h.tablets == nil {
+		return
+	}
+	// a node which is not a replica of the tablet anymore answers with the
+	// updated tablet, which replaced the cached one already. Other errors of
+	// the replica are no sign of the tablet moving, only when the replica can
+	// not be reached is the tablet dropped, so that the next query is routed
+	// by the token ring and gets the current tablet back.
+	if !errors.Is(err, ErrConnectionCategory) {
+		return
+	}
+	if t, ok := h.token.(murmur3Token); ok {
+		h.tablets.removeTablet(h.keyspace, h.table, int64(t))
+	}
+}
+
+// targetShard returns the shard of the host the query should be sent to, or -1 if unknown.
+func (h *shardSelectedHost) targetShard(sharding scyllaSupported) int {
+	if h.shard >= 0 {
+		return h.shard
+	}
+	if t, ok := h.token.(murmur3Token); ok && sharding.isShardAware() {
+		return sharding.shardForToken(int64(t))
+	}
+	return -1
+}
@@ -0,0 +1,186 @@
+package gocql
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"testing"
+)
+
+func appendSerializedElem(p, elem []byte) []byte {
+	p = appendInt(p, int32(len(elem)))
+	return append(p, elem...)
+}
+
+func tabletPayload(first, last int64, replicas ...tabletReplica) []byte {
+	var list []byte
+	list = appendInt(list, int32(len(replicas)))
+	for _, r := range replicas {
+		uuid, err := ParseUUID(r.hostID)
+		if err != nil {
+			panic(err)
+		}
+		shard := make([]byte, 4)
+		binary.BigEndian.PutUint32(shard, uint32(r.shard))
+
+		var replica []byte
+		replica = appendSerializedElem(replica, uuid.Bytes())
+		replica = appendSerializedElem(replica, shard)
+		list = appendSerializedElem(list, replica)
+	}
+
+	token := make([]byte, 8)
+	var p []byte
+	binary.BigEndian.PutUint64(token, uint64(first))
+	p = appendSerializedElem(p, token)
+	binary.BigEndian.PutUint64(token, uint64(last))
+	p = appendSerializedElem(p, token)
+	return appendSerializedElem(p, list)
+}
+
+const (
+	tabletHost1 = "1ad6b4c3-5b4a-4b6c-8e9a-1c3e2f0a7b01"
+	tabletHost2 = "2ad6b4c3-5b4a-4b6c-8e9a-1c3e2f0a7b02"
+	tabletHost3 = "3ad6b4c3-5b4a-4b6c-8e9a-1c3e2f0a7b03"
+)
+
+func TestParseTabletPayload(t *testing.T) {
+	payload := tabletPayload(-100, 100, tabletReplica{tabletHost1, 3}, tabletReplica{tabletHost2, 0})
+
+	tablet, err := parseTabletPayload(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tablet.firstToken != -100 || tablet.lastToken != 100 {
+		t.Fatalf("unexpected token range (%d, %d]", tablet.firstToken, tablet.lastToken)
+	}
+	assertDeepEqual(t, "replicas", []tabletReplica{{tabletHost1, 3}, {tabletHost2, 0}}, tablet.replicas)
+
+	for i := 0; i < len(payload); i++ {
+		if _, err := parseTabletPayload(payload[:i]); err != errInvalidTabletPayload {
+			t.Fatalf("expected truncated payload of %d bytes to be invalid, got %v", i, err)
+		}
+	}
+}
+
+func TestTabletMap(t *testing.T) {
+	m := newTabletMap()
+	m.add("ks", "tbl", tablet{firstToken: 0, lastToken: 100, replicas: []tabletReplica{{tabletHost1, 0}}})
+	m.add("ks", "tbl", tablet{firstToken: -100, lastToken: 0, replicas: []tabletReplica{{tabletHost2, 0}}})
+	m.add("ks", "other", tablet{firstToken: math.MinInt64, lastToken: math.MaxInt64, replicas: []tabletReplica{{tabletHost1, 0}}})
+
+	expectReplica := func(table string, token int64, hostID string) {
+		t.Helper()
+		tablet, ok := m.find("ks", table, token)
+		if hostID == "" {
+			if ok {
+				t.Fatalf("%s: expected no tablet for token %d, got %v", table, token, tablet)
+			}
+			return
+		}
+		if !ok || tablet.replicas[0].hostID != hostID {
+			t.Fatalf("%s: expected tablet on %s for token %d, got %v", table, hostID, token, tablet)
+		}
+	}
+
+	expectReplica("tbl", -100, "")
+	expectReplica("tbl", -50, tabletHost2)
+	expectReplica("tbl", 0, tabletHost2)
+	expectReplica("tbl", 1, tabletHost1)
+	expectReplica("tbl", 100, tabletHost1)
+	expectReplica("tbl", 101, "")
+
+	// a tablet which was split replaces the tablet it overlaps with
+	m.add("ks", "tbl", tablet{firstToken: 50, lastToken: 100, replicas: []tabletReplica{{tabletHost3, 0}}})
+	expectReplica("tbl", 1, "")
+	expectReplica("tbl", 75, tabletHost3)
+	expectReplica("tbl", -50, tabletHost2)
+
+	m.removeTablet("ks", "tbl", 75)
+	expectReplica("tbl", 75, "")
+
+	m.removeHost(tabletHost2)
+	expectReplica("tbl", -50, "")
+	expectReplica("other", 0, tabletHost1)
+
+	m.removeTable("ks", "")
+	expectReplica("other", 0, "")
+}
+
+func TestHostPolicy_TokenAware_Tablets(t *testing.T) {
+	const keyspace = "myKeyspace"
+	policy := TokenAwareHostPolicy(RoundRobinHostPolicy())
+	policyInternal := policy.(*tokenAwareHostPolicy)
+	policyInternal.getKeyspaceName = func() string { return keyspace }
+	policyInternal.getKeyspaceMetadata = func(ks string) (*KeyspaceMetadata, error) {
+		return nil, errors.New("not initalized")
+	}
+
+	hosts := [...]*HostInfo{
+		{hostId: tabletHost1, connectAddress: net.IPv4(10, 0, 0, 1), tokens: []string{"-5000000000000000000"}},
+		{hostId: tabletHost2, connectAddress: net.IPv4(10, 0, 0, 2), tokens: []string{"0"}},
+		{hostId: tabletHost3, connectAddress: net.IPv4(10, 0, 0, 3), tokens: []string{"5000000000000000000"}},
+	}
+	hostsByID := make(map[string]*HostInfo)
+	for _, host := range &hosts {
+		policy.AddHost(host)
+		hostsByID[host.HostID()] = host
+	}
+	policy.SetPartitioner("Murmur3Partitioner")
+
+	policyInternal.tablets = newTabletMap()
+	policyInternal.getHost = func(hostID string) *HostInfo { return hostsByID[hostID] }
+	policyInternal.tablets.add(keyspace, "tbl", tablet{
+		firstToken: math.MinInt64,
+		lastToken:  math.MaxInt64,
+		replicas:   []tabletReplica{{tabletHost3, 5}},
+	})
+
+	query := &Query{routingInfo: &queryRoutingInfo{keyspace: keyspace, table: "tbl", parsed: true}}
+	query.RoutingKey([]byte("key"))
+
+	iter := policy.Pick(query)
+	selected := iter()
+	if selected == nil || selected.Info().HostID() != tabletHost3 {
+		t.Fatalf("expected tablet replica %s to be picked first, got %v", tabletHost3, selected)
+	}
+	sh, ok := selected.(*shardSelectedHost)
+	if !ok {
+		t.Fatalf("expected *shardSelectedHost got %T", selected)
+	}
+	if shard := sh.targetShard(scyllaSupported{nrShards: 8}); shard != 5 {
+		t.Fatalf("expected shard 5 from the tablet got %d", shard)
+	}
+
+	// the errors of the replica keep the tablet
+	for _, err := range []error{
+		errors.New("invalid query"),
+		&RequestErrWriteTimeout{errorFrame: errorFrame{code: ErrCodeWriteTimeout}},
+		ErrTimeoutNoResponse,
+	} {
+		selected.Mark(err)
+		if _, ok := policyInternal.tablets.find(keyspace, "tbl", 0); !ok {
+			t.Fatalf("expected tablet to be kept after %v", err)
+		}
+	}
+
+	// a replica which can not be reached invalidates the tablet
+	selected.Mark(ErrConnectionClosed)
+	if _, ok := policyInternal.tablets.find(keyspace, "tbl", 0); ok {
+		t.Fatal("expected tablet to be removed after a connection error")
+	}
+
+	// without a tablet the token ring is used and the shard is computed from the token
+	iter = policy.Pick(query)
+	sh = iter().(*shardSelectedHost)
+	token := int64(murmur3Partitioner{}.Hash([]byte("key")).(murmur3Token))
+	sharding := scyllaSupported{
+		nrShards:          8,
+		partitioner:       "org.apache.cassandra.dht.Murmur3Partitioner",
+		shardingAlgorithm: "biased-token-round-robin",
+	}
+	if shard := sh.targetShard(sharding); shard != sharding.shardForToken(token) {
+		t.Fatalf("expected shard %d got %d", sharding.shardForToken(token), shard)
+	}
+}
@@ -0,0 +1,185 @@
+package gocql
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TimestampGenerator generates the client side timestamps of the queries and
+// batches of a session, see ClusterConfig.DefaultTimestamp. It is set for a
+// session with ClusterConfig.TimestampGenerator, and for a query or batch
+// with Query.TimestampGenerator or Batch.TimestampGenerator.
+type TimestampGenerator interface {
+	// Next returns the next timestamp, in microseconds since the Unix epoch,
+	// or 0 for the server to assign the timestamp.
+	Next() int64
+}
+
+// ServerTimestampGenerator generates no client side timestamp, the server
+// assigns the timestamps when it receives the queries.
+type ServerTimestampGenerator struct{}
+
+func (ServerTimestampGenerator) Next() int64 {
+	return 0
+}
+
+// MonotonicTimestampGenerator generates strictly increasing timestamps, even
+// when the clock goes backwards or two queries are timestamped in the same
+// microsecond: a timestamp is then the previous one plus one microsecond,
+// until the clock catches up. As such timestamps drift ahead of the clock, a
+// warning is logged when the drift exceeds WarningThreshold.
+//
+// The zero value is ready to use, it must not be copied after first use.
+type MonotonicTimestampGenerator struct {
+	// last and lastWarning are first for the alignment of atomic operations
+	last        int64
+	lastWarning int64
+
+	// Clock is the source of time of the generator, the system clock if nil.
+	Clock Clock
+	// WarningThreshold is the drift ahead of the clock above which warnings
+	// are logged, negative to disable them. (default: 1s)
+	WarningThreshold time.Duration
+	// WarningInterval is the minimum interval between warnings. (default: 1s)
+	WarningInterval time.Duration
+	// Logger receives the warnings, the package Logger if nil.
+	Logger StdLogger
+}
+
+func (g *MonotonicTimestampGenerator) Next() int64 {
+	clock := g.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	now := clock.Now().UnixNano() / 1000
+
+	for {
+		last := atomic.LoadInt64(&g.last)
+		next := now
+		if next <= last {
+			next = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&g.last, last, next) {
+			if next != now {
+				g.checkDrift(time.Duration(next-now)*time.Microsecond, now)
+			}
+			return next
+		}
+	}
+}
+
+func (g *MonotonicTimestampGenerator) checkDrift(drift time.Duration, now int64) {
+	warnDrift(&g.lastWarning, g.WarningThreshold, g.WarningInterval, g.Logger, drift, now)
+}
+
+// warnDrift logs a warning when the drift of timestamps ahead of the clock
+// exceeds threshold, at most once per interval, lastWarning being the time
+// of the last warning.
+func warnDrift(lastWarning *int64, threshold, interval time.Duration, logger StdLogger, drift time.Duration, now int64) {
+	if threshold == 0 {
+		threshold = time.Second
+	}
+	if threshold < 0 || drift <= threshold {
+		return
+	}
+
+	if interval <= 0 {
+		interval = time.Second
+	}
+	last := atomic.LoadInt64(lastWarning)
+	if last != 0 && time.Duration(now-last)*time.Microsecond < interval {
+		return
+	}
+	if !atomic.CompareAndSwapInt64(lastWarning, last, now) {
+		return
+	}
+
+	if logger == nil {
+		logger = Logger
+	}
+	logger.Printf("gocql: client timestamps are %v ahead of the clock, it went backwards or more than one query per microsecond is timestamped\n", drift)
+}
+
+// ShardedTimestampGenerator generates timestamps which strictly increase per
+// shard, like MonotonicTimestampGenerator does for all of its timestamps,
+// without a counter shared by all the goroutines. It has a shard per
+// processor (GOMAXPROCS at first use) and goroutines mostly use the shard of
+// the processor they run on, so goroutines generating many timestamps
+// concurrently do not contend on a single counter.
+//
+// Timestamps of different shards can be equal, and a goroutine moving to
+// another processor can get a timestamp lower than its previous one, by at
+// most the drift of the previous shard ahead of the clock. Use a
+// MonotonicTimestampGenerator when all the timestamps must be unique and
+// ordered.
+//
+// The zero value is ready to use, it must not be copied after first use.
+type ShardedTimestampGenerator struct {
+	// lastWarning is first for the alignment of atomic operations
+	lastWarning int64
+
+	// Clock is the source of time of the generator, the system clock if nil.
+	Clock Clock
+	// WarningThreshold is the drift ahead of the clock of a shard above which
+	// warnings are logged, negative to disable them. (default: 1s)
+	WarningThreshold time.Duration
+	// WarningInterval is the minimum interval between warnings. (default: 1s)
+	WarningInterval time.Duration
+	// Logger receives the warnings, the package Logger if nil.
+	Logger StdLogger
+
+	once   sync.Once
+	shards []timestampShard
+	// hints caches the index of a shard per processor. The shards are never
+	// dropped, a hint lost to garbage collection is replaced by the index of
+	// the next shard.
+	hints    sync.Pool
+	nextHint uint32
+}
+
+type timestampShard struct {
+	last int64
+	// the shards are on their own cache line
+	_ [56]byte
+}
+
+func (g *ShardedTimestampGenerator) Next() int64 {
+	g.once.Do(func() {
+		g.shards = make([]timestampShard, runtime.GOMAXPROCS(0))
+	})
+
+	hint, _ := g.hints.Get().(*int)
+	if hint == nil {
+		hint = new(int)
+		*hint = int(atomic.AddUint32(&g.nextHint, 1) % uint32(len(g.shards)))
+	}
+	ts := g.next(*hint)
+	g.hints.Put(hint)
+	return ts
+}
+
+// next returns the next timestamp of the shard at index i.
+func (g *ShardedTimestampGenerator) next(i int) int64 {
+	clock := g.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	now := clock.Now().UnixNano() / 1000
+
+	shard := &g.shards[i]
+	for {
+		last := atomic.LoadInt64(&shard.last)
+		next := now
+		if next <= last {
+			next = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&shard.last, last, next) {
+			if next != now {
+				warnDrift(&g.lastWarning, g.WarningThreshold, g.WarningInterval, g.Logger, time.Duration(next-now)*time.Microsecond, now)
+			}
+			return next
+		}
+	}
+}
@@ -0,0 +1,206 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"encoding/binary"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMonotonicTimestampGenerator(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clock := &recordingClock{now: start}
+	logger := &testLogger{}
+	g := &MonotonicTimestampGenerator{Clock: clock, WarningThreshold: 10 * time.Microsecond, Logger: logger}
+
+	micros := start.UnixNano() / 1000
+	if ts := g.Next(); ts != micros {
+		t.Fatalf("expected %d got %d", micros, ts)
+	}
+	// same microsecond
+	if ts := g.Next(); ts != micros+1 {
+		t.Fatalf("expected %d got %d", micros+1, ts)
+	}
+	// the clock goes backwards
+	clock.now = start.Add(-time.Second)
+	for i := 2; i < 20; i++ {
+		if ts := g.Next(); ts != micros+int64(i) {
+			t.Fatalf("expected %d got %d", micros+int64(i), ts)
+		}
+	}
+	if out := logger.String(); strings.Count(out, "ahead of the clock") != 1 {
+		t.Fatalf("expected a single drift warning got %q", out)
+	}
+	// the clock catches up
+	clock.now = start.Add(time.Second)
+	if ts := g.Next(); ts != micros+1000000 {
+		t.Fatalf("expected %d got %d", micros+1000000, ts)
+	}
+}
+
+func TestMonotonicTimestampGeneratorConcurrent(t *testing.T) {
+	g := &MonotonicTimestampGenerator{Clock: &recordingClock{now: time.Now()}, WarningThreshold: -1}
+
+	const goroutines, n = 8, 1000
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[int64]bool, goroutines*n)
+	)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ts := make([]int64, n)
+			for j := range ts {
+				ts[j] = g.Next()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for j, v := range ts {
+				if seen[v] || j > 0 && v <= ts[j-1] {
+					t.Errorf("timestamp %d is not unique and increasing", v)
+					return
+				}
+				seen[v] = true
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestShardedTimestampGenerator(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	micros := start.UnixNano() / 1000
+	g := &ShardedTimestampGenerator{Clock: &recordingClock{now: start}, WarningThreshold: -1}
+
+	// the timestamps of a shard increase
+	g.Next()
+	for i := range g.shards {
+		last := g.shards[i].last
+		for j := 0; j < 10; j++ {
+			ts := g.next(i)
+			if ts < micros || ts <= last {
+				t.Fatalf("timestamp %d of shard %d is not greater than %d", ts, i, last)
+			}
+			last = ts
+		}
+	}
+
+	// the clock does not move, so each shard generates each timestamp at
+	// most once even if the shard hints are dropped by the GC
+	const goroutines, n = 8, 1000
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		count = make(map[int64]int, goroutines*n)
+	)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ts := make([]int64, n)
+			for j := range ts {
+				if j%100 == 0 {
+					runtime.GC()
+				}
+				ts[j] = g.Next()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, v := range ts {
+				count[v]++
+			}
+		}()
+	}
+	wg.Wait()
+	for v, c := range count {
+		if v < micros || c > len(g.shards) {
+			t.Fatalf("timestamp %d was generated %d times by %d shards", v, c, len(g.shards))
+		}
+	}
+}
+
+func TestClusterTimestampGenerator(t *testing.T) {
+	cfg := NewCluster()
+	cfg.Clock = &recordingClock{now: time.Unix(1, 0)}
+	if ts := cfg.timestamp(); ts != 1000000 {
+		t.Fatalf("expected the clock timestamp got %d", ts)
+	}
+	cfg.TimestampGenerator = &MonotonicTimestampGenerator{Clock: cfg.Clock}
+	cfg.timestamp()
+	if ts := cfg.timestamp(); ts != 1000001 {
+		t.Fatalf("expected the generator timestamp got %d", ts)
+	}
+}
+
+func TestServerTimestampGenerator(t *testing.T) {
+	// the flags of the frames of queries without values and of batches of a
+	// single statement without values
+	var (
+		mu    sync.Mutex
+		flags []byte
+	)
+	srv := newTestServerOpts{
+		addr:     "127.0.0.1:0",
+		protocol: protoVersion4,
+		recvHook: func(f *framer) {
+			var offset int
+			switch f.header.op {
+			case opQuery:
+				offset = 4 + int(binary.BigEndian.Uint32(f.buf)) + 2
+			case opBatch:
+				offset = 1 + 2 + 1 + 4 + int(binary.BigEndian.Uint32(f.buf[4:])) + 2 + 2
+			default:
+				return
+			}
+			mu.Lock()
+			flags = append(flags, f.buf[offset])
+			mu.Unlock()
+		},
+	}.newServer(t, context.Background())
+	defer srv.Stop()
+
+	cluster := testCluster(protoVersion4, srv.Address)
+	cluster.TimestampGenerator = ServerTimestampGenerator{}
+	db, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Query("void").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Query("void").TimestampGenerator(&MonotonicTimestampGenerator{}).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Query("void").TimestampGenerator(&MonotonicTimestampGenerator{}).WithTimestamp(1).NoClientTimestamp().Exec(); err != nil {
+		t.Fatal(err)
+	}
+	batch := db.NewBatch(UnloggedBatch)
+	batch.Query("void")
+	// batches are not supported by the test server
+	db.ExecuteBatch(batch)
+	batch = db.NewBatch(UnloggedBatch).TimestampGenerator(&MonotonicTimestampGenerator{})
+	batch.Query("void")
+	db.ExecuteBatch(batch)
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []bool{false, true, false, false, true}
+	if len(flags) != len(expected) {
+		t.Fatalf("expected %d frames, got %d", len(expected), len(flags))
+	}
+	for i, f := range flags {
+		if set := f&flagDefaultTimestamp != 0; set != expected[i] {
+			t.Errorf("frame %d: expected the default timestamp flag %v, got %v", i, expected[i], set)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package gocql
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// TLSCertificate summarizes a certificate of the chain presented by a host.
+type TLSCertificate struct {
+	Subject  string
+	Issuer   string
+	DNSNames []string
+	NotAfter time.Time
+}
+
+// ObservedTLSHandshake describes a TLS handshake with a host.
+type ObservedTLSHandshake struct {
+	Host *HostInfo
+
+	Start time.Time // time immediately before the handshake
+	End   time.Time // time immediately after the handshake
+
+	// Version and CipherSuite are the negotiated TLS version and cipher
+	// suite, as the constants of crypto/tls, such as tls.VersionTLS13.
+	Version     uint16
+	CipherSuite uint16
+	// ServerName is the name the certificate of the host was verified
+	// against, if any.
+	ServerName string
+	// PeerCertificates is the certificate chain presented by the host,
+	// starting with its own certificate.
+	PeerCertificates []TLSCertificate
+
+	// Err is the handshake error, if any.
+	Err error
+}
+
+// TLSHandshakeObserver is the interface implemented by the observers of the
+// TLS handshakes of the connections of a session.
+type TLSHandshakeObserver interface {
+	// ObserveTLSHandshake gets called after each TLS handshake, successful
+	// or not.
+	ObserveTLSHandshake(ObservedTLSHandshake)
+}
+
+// wrapTLS wraps conn to host into a TLS session like WrapTLS, reporting the
+// handshake to the TLSHandshakeObserver of the dialer.
+func (hd *defaultHostDialer) wrapTLS(ctx context.Context, conn net.Conn, host *HostInfo) (*DialedHost, error) {
+	if hd.tlsObserver == nil || hd.tlsConfig == nil {
+		return WrapTLS(ctx, conn, host.HostnameAndPort(), hd.tlsConfig)
+	}
+
+	obs := ObservedTLSHandshake{Host: host, Start: time.Now()}
+	dialed, err := WrapTLS(ctx, conn, host.HostnameAndPort(), hd.tlsConfig)
+	obs.End = time.Now()
+	obs.Err = err
+	if err == nil {
+		if tconn, ok := dialed.Conn.(*tls.Conn); ok {
+			state := tconn.ConnectionState()
+			obs.Version = state.Version
+			obs.CipherSuite = state.CipherSuite
+			obs.ServerName = state.ServerName
+			for _, cert := range state.PeerCertificates {
+				obs.PeerCertificates = append(obs.PeerCertificates, TLSCertificate{
+					Subject:  cert.Subject.String(),
+					Issuer:   cert.Issuer.String(),
+					DNSNames: cert.DNSNames,
+					NotAfter: cert.NotAfter,
+				})
+			}
+		}
+	}
+	hd.tlsObserver.ObserveTLSHandshake(obs)
+	return dialed, err
+}
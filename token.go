@@ -19,14 +19,16 @@ import (
 // a token partitioner
 type partitioner interface {
 	Name() string
-	Hash([]byte) token
-	ParseString(string) token
+	Hash([]byte) Token
+	ParseString(string) Token
 }
 
-// a token
-type token interface {
+// Token is a token of the ring of the cluster, as computed by its
+// partitioner. String formats it like the CQL token function, and Less
+// orders the tokens of a same partitioner.
+type Token interface {
 	fmt.Stringer
-	Less(token) bool
+	Less(Token) bool
 }
 
 // murmur3 partitioner and token
@@ -37,13 +39,13 @@ func (p murmur3Partitioner) Name() string {
 	return "Murmur3Partitioner"
 }
 
-func (p murmur3Partitioner) Hash(partitionKey []byte) token {
+func (p murmur3Partitioner) Hash(partitionKey []byte) Token {
 	h1 := murmur.Murmur3H1(partitionKey)
 	return murmur3Token(h1)
 }
 
 // murmur3 little-endian, 128-bit hash, but returns only h1
-func (p murmur3Partitioner) ParseString(str string) token {
+func (p murmur3Partitioner) ParseString(str string) Token {
 	val, _ := strconv.ParseInt(str, 10, 64)
 	return murmur3Token(val)
 }
@@ -52,7 +54,7 @@ func (m murmur3Token) String() string {
 	return strconv.FormatInt(int64(m), 10)
 }
 
-func (m murmur3Token) Less(token token) bool {
+func (m murmur3Token) Less(token Token) bool {
 	return m < token.(murmur3Token)
 }
 
@@ -64,12 +66,12 @@ func (p orderedPartitioner) Name() string {
 	return "OrderedPartitioner"
 }
 
-func (p orderedPartitioner) Hash(partitionKey []byte) token {
+func (p orderedPartitioner) Hash(partitionKey []byte) Token {
 	// the partition key is the token
 	return orderedToken(partitionKey)
 }
 
-func (p orderedPartitioner) ParseString(str string) token {
+func (p orderedPartitioner) ParseString(str string) Token {
 	return orderedToken(str)
 }
 
@@ -77,7 +79,7 @@ func (o orderedToken) String() string {
 	return string(o)
 }
 
-func (o orderedToken) Less(token token) bool {
+func (o orderedToken) Less(token Token) bool {
 	return o < token.(orderedToken)
 }
 
@@ -92,7 +94,7 @@ func (r randomPartitioner) Name() string {
 // 2 ** 128
 var maxHashInt, _ = new(big.Int).SetString("340282366920938463463374607431768211456", 10)
 
-func (p randomPartitioner) Hash(partitionKey []byte) token {
+func (p randomPartitioner) Hash(partitionKey []byte) Token {
 	sum := md5.Sum(partitionKey)
 	val := new(big.Int)
 	val.SetBytes(sum[:])
@@ -104,7 +106,7 @@ func (p randomPartitioner) Hash(partitionKey []byte) token {
 	return (*randomToken)(val)
 }
 
-func (p randomPartitioner) ParseString(str string) token {
+func (p randomPartitioner) ParseString(str string) Token {
 	val := new(big.Int)
 	val.SetString(str, 10)
 	return (*randomToken)(val)
@@ -114,12 +116,12 @@ func (r *randomToken) String() string {
 	return (*big.Int)(r).String()
 }
 
-func (r *randomToken) Less(token token) bool {
+func (r *randomToken) Less(token Token) bool {
 	return -1 == (*big.Int)(r).Cmp((*big.Int)(token.(*randomToken)))
 }
 
 type hostToken struct {
-	token token
+	token Token
 	host  *HostInfo
 }
 
@@ -141,19 +143,42 @@ type tokenRing struct {
 	hosts []*HostInfo
 }
 
+// newPartitioner returns the partitioner of the class name.
+func newPartitioner(name string) (partitioner, error) {
+	if strings.HasSuffix(name, "Murmur3Partitioner") {
+		return murmur3Partitioner{}, nil
+	} else if strings.HasSuffix(name, "OrderedPartitioner") {
+		return orderedPartitioner{}, nil
+	} else if strings.HasSuffix(name, "RandomPartitioner") {
+		return randomPartitioner{}, nil
+	}
+	return nil, fmt.Errorf("unsupported partitioner '%s'", name)
+}
+
+// parseToken parses str as a token of the partitioner, unlike ParseString
+// returning an error if it is not one.
+func parseToken(p partitioner, str string) (Token, error) {
+	switch p.(type) {
+	case murmur3Partitioner:
+		if _, err := strconv.ParseInt(str, 10, 64); err != nil {
+			return nil, fmt.Errorf("gocql: invalid %s token %q", p.Name(), str)
+		}
+	case randomPartitioner:
+		if val, ok := new(big.Int).SetString(str, 10); !ok || val.Sign() < 0 || val.Cmp(maxHashInt) >= 0 {
+			return nil, fmt.Errorf("gocql: invalid %s token %q", p.Name(), str)
+		}
+	}
+	return p.ParseString(str), nil
+}
+
 func newTokenRing(partitioner string, hosts []*HostInfo) (*tokenRing, error) {
 	tokenRing := &tokenRing{
 		hosts: hosts,
 	}
 
-	if strings.HasSuffix(partitioner, "Murmur3Partitioner") {
-		tokenRing.partitioner = murmur3Partitioner{}
-	} else if strings.HasSuffix(partitioner, "OrderedPartitioner") {
-		tokenRing.partitioner = orderedPartitioner{}
-	} else if strings.HasSuffix(partitioner, "RandomPartitioner") {
-		tokenRing.partitioner = randomPartitioner{}
-	} else {
-		return nil, fmt.Errorf("unsupported partitioner '%s'", partitioner)
+	var err error
+	if tokenRing.partitioner, err = newPartitioner(partitioner); err != nil {
+		return nil, err
 	}
 
 	for _, host := range hosts {
@@ -202,7 +227,7 @@ func (t *tokenRing) String() string {
 	return string(buf.Bytes())
 }
 
-func (t *tokenRing) GetHostForToken(token token) (host *HostInfo, endToken token) {
+func (t *tokenRing) GetHostForToken(token Token) (host *HostInfo, endToken Token) {
 	if t == nil || len(t.tokens) == 0 {
 		return nil, nil
 	}
@@ -133,7 +133,7 @@ func TestRandomToken(t *testing.T) {
 type intToken int
 
 func (i intToken) String() string        { return strconv.Itoa(int(i)) }
-func (i intToken) Less(token token) bool { return i < token.(intToken) }
+func (i intToken) Less(token Token) bool { return i < token.(intToken) }
 
 // Test of the token ring implementation based on example at the start of this
 // page of documentation:
@@ -319,3 +319,27 @@ func TestTokenRing_Random(t *testing.T) {
 		t.Errorf("Expected address 1 for token \"24324545443332\", but was %s", actual.ConnectAddress())
 	}
 }
+
+func TestParseToken(t *testing.T) {
+	tests := []struct {
+		partitioner partitioner
+		token       string
+		valid       bool
+	}{
+		{murmur3Partitioner{}, "-4069959284402364209", true},
+		{murmur3Partitioner{}, "abc", false},
+		{murmur3Partitioner{}, "9223372036854775808", false},
+		{randomPartitioner{}, "170141183460469231731687303715884105727", true},
+		{randomPartitioner{}, "-1", false},
+		{randomPartitioner{}, "", false},
+		{orderedPartitioner{}, "any key", true},
+	}
+	for _, test := range tests {
+		tok, err := parseToken(test.partitioner, test.token)
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("%s %q: expected valid %t got %v", test.partitioner.Name(), test.token, test.valid, err)
+		} else if valid && tok.String() != test.token {
+			t.Errorf("%s %q: got the token %v", test.partitioner.Name(), test.token, tok)
+		}
+	}
+}
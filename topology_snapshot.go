@@ -0,0 +1,197 @@
+package gocql
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// TopologySnapshot is the view of the cluster of a session at a point in
+// time, see Session.TopologySnapshot. It is meant to be attached to support
+// tickets and compared between clients when debugging routing or
+// connectivity issues.
+type TopologySnapshot struct {
+	Time        time.Time      `json:"time"`
+	ClusterName string         `json:"cluster_name,omitempty"`
+	Partitioner string         `json:"partitioner,omitempty"`
+	Hosts       []HostSnapshot `json:"hosts"`
+}
+
+// HostSnapshot is a host of a TopologySnapshot.
+type HostSnapshot struct {
+	HostID         string   `json:"host_id"`
+	ConnectAddress string   `json:"connect_address"`
+	Port           int      `json:"port"`
+	DataCenter     string   `json:"data_center"`
+	Rack           string   `json:"rack"`
+	State          string   `json:"state"`
+	Version        string   `json:"version"`
+	Tokens         []string `json:"tokens,omitempty"`
+	// Ownership is the fraction of the token ring the host is the primary
+	// replica of, from 0 to 1. It is 0 if the partitioner is unknown or
+	// ordered.
+	Ownership float64 `json:"ownership"`
+	// Pool is nil if the session has no connection pool for the host, such
+	// as for filtered hosts.
+	Pool *PoolSnapshot `json:"pool,omitempty"`
+}
+
+// PoolSnapshot is the connection pool of a host in a TopologySnapshot.
+type PoolSnapshot struct {
+	// Size is the number of connections the pool is filled to.
+	Size int `json:"size"`
+	// Conns is the number of open connections.
+	Conns int `json:"conns"`
+	// Filling reports whether connections are being opened.
+	Filling bool `json:"filling"`
+	// Shards is the number of shards of a Scylla node, 0 for other nodes.
+	Shards int `json:"shards,omitempty"`
+	// InFlight is the number of requests waiting for a response on the
+	// connections.
+	InFlight int `json:"in_flight"`
+	// ShardConns are the connections to each shard of a Scylla node,
+	// ordered by shard, so that a hot shard or a shard without connections
+	// can be told apart. It is empty for other nodes.
+	ShardConns []ShardSnapshot `json:"shard_conns,omitempty"`
+}
+
+// ShardSnapshot is the connections of a PoolSnapshot to a shard.
+type ShardSnapshot struct {
+	Shard int `json:"shard"`
+	// Conns is the number of open connections to the shard.
+	Conns int `json:"conns"`
+	// InFlight is the number of requests waiting for a response on the
+	// connections to the shard.
+	InFlight int `json:"in_flight"`
+}
+
+// TopologySnapshot returns the hosts of the cluster known by the session,
+// with their ownership of the token ring and the state of their connection
+// pools, ordered by data center, rack and host ID.
+func (s *Session) TopologySnapshot() *TopologySnapshot {
+	s.metadata.mu.RLock()
+	partitioner := s.metadata.partitioner
+	s.metadata.mu.RUnlock()
+
+	snapshot := &TopologySnapshot{
+		Time:        time.Now(),
+		Partitioner: partitioner,
+		Hosts:       []HostSnapshot{},
+	}
+
+	hosts := s.ring.allHosts()
+	var ownership map[*HostInfo]float64
+	if partitioner != "" {
+		if ring, err := newTokenRing(partitioner, hosts); err == nil {
+			ownership = ring.ownership()
+		}
+	}
+
+	for _, host := range hosts {
+		if snapshot.ClusterName == "" {
+			snapshot.ClusterName = host.ClusterName()
+		}
+		h := HostSnapshot{
+			HostID:         host.HostID(),
+			ConnectAddress: host.ConnectAddress().String(),
+			Port:           host.Port(),
+			DataCenter:     host.DataCenter(),
+			Rack:           host.Rack(),
+			State:          host.State().String(),
+			Version:        host.Version().String(),
+			Tokens:         host.Tokens(),
+			Ownership:      ownership[host],
+		}
+		if pool, ok := s.pool.getPool(host); ok {
+			h.Pool = pool.snapshot()
+		}
+		snapshot.Hosts = append(snapshot.Hosts, h)
+	}
+
+	sort.Slice(snapshot.Hosts, func(i, j int) bool {
+		a, b := snapshot.Hosts[i], snapshot.Hosts[j]
+		if a.DataCenter != b.DataCenter {
+			return a.DataCenter < b.DataCenter
+		}
+		if a.Rack != b.Rack {
+			return a.Rack < b.Rack
+		}
+		return a.HostID < b.HostID
+	})
+	return snapshot
+}
+
+// WriteJSON writes the snapshot to w as indented JSON.
+func (t *TopologySnapshot) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+func (pool *hostConnPool) snapshot() *PoolSnapshot {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	p := &PoolSnapshot{
+		Size:    pool.size,
+		Conns:   len(pool.conns),
+		Filling: pool.filling,
+	}
+	if pool.sharding.nrShards > 1 {
+		p.Shards = pool.sharding.nrShards
+		p.ShardConns = make([]ShardSnapshot, p.Shards)
+		for shard := range p.ShardConns {
+			p.ShardConns[shard].Shard = shard
+		}
+	}
+	for _, conn := range pool.conns {
+		inFlight := conn.streams.NumStreams - 1 - conn.AvailableStreams()
+		p.InFlight += inFlight
+		if shard := conn.shard(); shard >= 0 && shard < len(p.ShardConns) {
+			p.ShardConns[shard].Conns++
+			p.ShardConns[shard].InFlight += inFlight
+		}
+	}
+	return p
+}
+
+// ownership returns the fraction of the ring each host is the primary
+// replica of, nil for the ordered partitioner whose token space is unbounded.
+func (t *tokenRing) ownership() map[*HostInfo]float64 {
+	if len(t.tokens) == 0 {
+		return nil
+	}
+	owned := make(map[*HostInfo]float64)
+	switch t.partitioner.(type) {
+	case murmur3Partitioner:
+		for i, ht := range t.tokens {
+			prev := t.tokens[(i+len(t.tokens)-1)%len(t.tokens)].token
+			size := uint64(ht.token.(murmur3Token)) - uint64(prev.(murmur3Token))
+			if size == 0 {
+				// a single token owns the whole ring
+				owned[ht.host] += 1
+				continue
+			}
+			owned[ht.host] += float64(size) / math.Pow(2, 64)
+		}
+	case randomPartitioner:
+		space := new(big.Int).Lsh(big.NewInt(1), 127)
+		for i, ht := range t.tokens {
+			prev := t.tokens[(i+len(t.tokens)-1)%len(t.tokens)].token
+			size := new(big.Int).Sub((*big.Int)(ht.token.(*randomToken)), (*big.Int)(prev.(*randomToken)))
+			size.Mod(size, space)
+			if size.Sign() == 0 {
+				owned[ht.host] += 1
+				continue
+			}
+			fraction, _ := new(big.Float).Quo(new(big.Float).SetInt(size), new(big.Float).SetInt(space)).Float64()
+			owned[ht.host] += fraction
+		}
+	default:
+		return nil
+	}
+	return owned
+}
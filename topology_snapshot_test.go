@@ -0,0 +1,106 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/gocql/gocql/internal/streams"
+)
+
+func TestTokenRingOwnership(t *testing.T) {
+	h1 := &HostInfo{hostId: "h1", tokens: []string{"-9223372036854775808", "0"}}
+	h2 := &HostInfo{hostId: "h2", tokens: []string{"4611686018427387904"}}
+	ring, err := newTokenRing("Murmur3Partitioner", []*HostInfo{h1, h2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	owned := ring.ownership()
+	if got := owned[h1]; math.Abs(got-0.75) > 1e-9 {
+		t.Errorf("expected h1 to own 0.75 of the ring got %v", got)
+	}
+	if got := owned[h2]; math.Abs(got-0.25) > 1e-9 {
+		t.Errorf("expected h2 to own 0.25 of the ring got %v", got)
+	}
+
+	single := &HostInfo{hostId: "single", tokens: []string{"42"}}
+	if ring, err = newTokenRing("RandomPartitioner", []*HostInfo{single}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ring.ownership()[single]; got != 1 {
+		t.Errorf("expected a single token to own the ring got %v", got)
+	}
+
+	if ring, err = newTokenRing("ByteOrderedPartitioner", []*HostInfo{single}); err != nil {
+		t.Fatal(err)
+	}
+	if owned := ring.ownership(); owned != nil {
+		t.Errorf("expected no ownership for the ordered partitioner got %v", owned)
+	}
+}
+
+func TestSessionTopologySnapshot(t *testing.T) {
+	srv := NewTestServer(t, protoVersion4, context.Background())
+	defer srv.Stop()
+
+	db, err := testCluster(protoVersion4, srv.Address).CreateSession()
+	if err != nil {
+		t.Fatalf("NewCluster: %v", err)
+	}
+	defer db.Close()
+
+	snapshot := db.TopologySnapshot()
+	if len(snapshot.Hosts) != 1 {
+		t.Fatalf("expected 1 host got %+v", snapshot.Hosts)
+	}
+	host := snapshot.Hosts[0]
+	if host.State != "UP" || host.Pool == nil || host.Pool.Conns == 0 {
+		t.Fatalf("expected an up host with connections got %+v", host)
+	}
+
+	var buf bytes.Buffer
+	if err := snapshot.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded TopologySnapshot
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Hosts) != 1 || decoded.Hosts[0].ConnectAddress != host.ConnectAddress ||
+		decoded.Hosts[0].Pool.Conns != host.Pool.Conns {
+		t.Fatalf("expected %+v got %+v", snapshot, decoded)
+	}
+}
+
+func TestPoolSnapshotShardConns(t *testing.T) {
+	sharding := scyllaSupported{nrShards: 3}
+	newConn := func(shard, inFlight int) *Conn {
+		conn := &Conn{streams: streams.New(protoVersion4), scyllaSupported: sharding}
+		conn.scyllaSupported.shard = shard
+		for i := 0; i < inFlight; i++ {
+			conn.streams.GetStream()
+		}
+		return conn
+	}
+	pool := &hostConnPool{
+		size:     3,
+		sharding: sharding,
+		conns:    []*Conn{newConn(0, 1), newConn(2, 2), newConn(2, 3)},
+	}
+
+	p := pool.snapshot()
+	expected := []ShardSnapshot{
+		{Shard: 0, Conns: 1, InFlight: 1},
+		{Shard: 1},
+		{Shard: 2, Conns: 2, InFlight: 5},
+	}
+	if p.Shards != 3 || p.InFlight != 6 || !reflect.DeepEqual(p.ShardConns, expected) {
+		t.Fatalf("expected the shard connections %+v got %+v", expected, p)
+	}
+}
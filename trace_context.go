@@ -0,0 +1,51 @@
+package gocql
+
+import (
+	"context"
+	"sort"
+)
+
+// PayloadCarrier is the custom payload of a request carrying propagated
+// context, such as the W3C traceparent of the span of the request. It
+// satisfies the TextMapCarrier interface of OpenTelemetry, so that the
+// propagators of the application inject into it directly, see
+// ClusterConfig.TracePropagator.
+type PayloadCarrier map[string][]byte
+
+// Get returns the value of key, empty if it is not set.
+func (c PayloadCarrier) Get(key string) string {
+	return string(c[key])
+}
+
+// Set sets the value of key.
+func (c PayloadCarrier) Set(key, value string) {
+	c[key] = []byte(value)
+}
+
+// Keys returns the sorted keys of the payload.
+func (c PayloadCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// requestPayload returns payload, the custom payload of a request executed
+// with ctx, with the trace context injected by ClusterConfig.TracePropagator.
+// payload is copied rather than modified, as it is shared by the attempts of
+// the request.
+func (c *Conn) requestPayload(ctx context.Context, payload map[string][]byte) map[string][]byte {
+	propagate := c.session.cfg.TracePropagator
+	if propagate == nil || c.version < protoVersion4 || ctx == nil {
+		return payload
+	}
+
+	carrier := make(PayloadCarrier, len(payload)+1)
+	for key, value := range payload {
+		carrier[key] = value
+	}
+	propagate(ctx, carrier)
+	return carrier
+}
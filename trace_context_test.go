@@ -0,0 +1,43 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type traceparentKey struct{}
+
+func TestRequestPayload(t *testing.T) {
+	cfg := NewCluster()
+	cfg.TracePropagator = func(ctx context.Context, carrier PayloadCarrier) {
+		if traceparent, ok := ctx.Value(traceparentKey{}).(string); ok {
+			carrier.Set("traceparent", traceparent)
+		}
+	}
+	conn := &Conn{version: protoVersion4, session: &Session{cfg: *cfg}}
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := context.WithValue(context.Background(), traceparentKey{}, traceparent)
+	payload := map[string][]byte{"key": []byte("value")}
+	got := conn.requestPayload(ctx, payload)
+	expected := map[string][]byte{"key": []byte("value"), "traceparent": []byte(traceparent)}
+	if !reflect.DeepEqual(map[string][]byte(got), expected) {
+		t.Errorf("expected payload %q, got %q", expected, got)
+	}
+	if len(payload) != 1 {
+		t.Errorf("the payload of the query was modified: %q", payload)
+	}
+	if keys := PayloadCarrier(got).Keys(); !reflect.DeepEqual(keys, []string{"key", "traceparent"}) {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	// custom payloads are not supported before protocol v4
+	conn.version = protoVersion3
+	if got := conn.requestPayload(ctx, nil); got != nil {
+		t.Errorf("expected no payload with protocol v3, got %q", got)
+	}
+}
@@ -0,0 +1,80 @@
+package gocql
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// WithTTL sets the time to live of the values written by the query, an
+// INSERT or UPDATE statement, by adding it to the USING clause of the
+// statement, so that it does not have to be formatted into the statement.
+// The TTL must be a whole number of seconds, 0 meaning no expiration, and the
+// statement must not set one already. Executing the query fails otherwise.
+//
+// The TTL is part of the statement, which is prepared once per distinct TTL:
+// use a bind marker for TTLs which take many values.
+func (q *Query) WithTTL(ttl time.Duration) *Query {
+	stmt, err := withTTL(q.stmt, ttl)
+	if err != nil {
+		if q.err == nil {
+			q.err = err
+		}
+		return q
+	}
+	q.stmt = stmt
+	return q
+}
+
+// withTTL returns stmt with ttl added to its USING clause.
+func withTTL(stmt string, ttl time.Duration) (string, error) {
+	if ttl < 0 || ttl%time.Second != 0 {
+		return "", fmt.Errorf("gocql: invalid TTL %v, must be a positive number of seconds", ttl)
+	}
+	param := "TTL " + strconv.FormatInt(int64(ttl/time.Second), 10)
+
+	kind, _, i := nextToken(stmt, 0)
+	if kind != "insert" && kind != "update" {
+		return "", fmt.Errorf("gocql: TTL can only be set on INSERT and UPDATE statements: %q", stmt)
+	}
+
+	// the end of the last token of the statement, the end of USING in
+	// the statement and the start of SET in UPDATE statements
+	var end, using, set int
+	prev := ""
+	for {
+		tok, quoted, next := nextToken(stmt, i)
+		if tok == "" && !quoted {
+			break
+		}
+		if !quoted {
+			switch {
+			case tok == "using" && using == 0:
+				using = next
+			case tok == "ttl" && using > 0 && (prev == "using" || prev == "and"):
+				return "", fmt.Errorf("gocql: statement has a TTL already: %q", stmt)
+			case tok == "set" && kind == "update" && set == 0:
+				set = next - len(tok)
+			}
+		}
+		if quoted || tok != ";" {
+			end = next
+		}
+		if quoted {
+			prev = ""
+		} else {
+			prev = tok
+		}
+		i = next
+	}
+
+	switch {
+	case using > 0 && (kind == "insert" || set == 0 || using < set):
+		return stmt[:using] + " " + param + " AND" + stmt[using:], nil
+	case kind == "update" && set > 0:
+		return stmt[:set] + "USING " + param + " " + stmt[set:], nil
+	case kind == "insert":
+		return stmt[:end] + " USING " + param + stmt[end:], nil
+	}
+	return "", fmt.Errorf("gocql: unable to set the TTL of statement %q", stmt)
+}
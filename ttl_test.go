@@ -0,0 +1,71 @@
+//go:build all || unit
+// +build all unit
+
+package gocql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithTTL(t *testing.T) {
+	tests := []struct {
+		stmt     string
+		ttl      time.Duration
+		expected string
+	}{
+		{"INSERT INTO t (id, ttl) VALUES (?, ?)", time.Hour, "INSERT INTO t (id, ttl) VALUES (?, ?) USING TTL 3600"},
+		{"INSERT INTO t (id) VALUES (?) IF NOT EXISTS;", time.Minute, "INSERT INTO t (id) VALUES (?) IF NOT EXISTS USING TTL 60;"},
+		{"INSERT INTO t (id) VALUES (?) USING TIMESTAMP ?", time.Second, "INSERT INTO t (id) VALUES (?) USING TTL 1 AND TIMESTAMP ?"},
+		{"INSERT INTO t JSON 'using ttl' -- comment", 0, "INSERT INTO t JSON 'using ttl' USING TTL 0 -- comment"},
+		{"update t SET a = ? WHERE id = ?", 10 * time.Second, "update t USING TTL 10 SET a = ? WHERE id = ?"},
+		{"UPDATE t USING TIMESTAMP 1 SET a = 'b' WHERE id = 1", time.Second, "UPDATE t USING TTL 1 AND TIMESTAMP 1 SET a = 'b' WHERE id = 1"},
+	}
+	for _, test := range tests {
+		stmt, err := withTTL(test.stmt, test.ttl)
+		if err != nil {
+			t.Errorf("%q: %v", test.stmt, err)
+		} else if stmt != test.expected {
+			t.Errorf("%q: expected %q got %q", test.stmt, test.expected, stmt)
+		}
+	}
+
+	invalid := []struct {
+		stmt string
+		ttl  time.Duration
+	}{
+		{"INSERT INTO t (id) VALUES (?)", -time.Second},
+		{"INSERT INTO t (id) VALUES (?)", 1500 * time.Millisecond},
+		{"INSERT INTO t (id) VALUES (?) USING TTL 10", time.Second},
+		{"UPDATE t USING TIMESTAMP 1 AND TTL 10 SET a = 1 WHERE id = 1", time.Second},
+		{"DELETE FROM t WHERE id = ?", time.Second},
+		{"SELECT * FROM t", time.Second},
+	}
+	for _, test := range invalid {
+		if stmt, err := withTTL(test.stmt, test.ttl); err == nil {
+			t.Errorf("%q with TTL %v: expected an error, got %q", test.stmt, test.ttl, stmt)
+		}
+	}
+}
+
+func TestQueryWithTTLError(t *testing.T) {
+	qry := (&Query{stmt: "DELETE FROM t WHERE id = 1"}).WithTTL(time.Second)
+	if qry.err == nil {
+		t.Fatal("expected the query to fail")
+	}
+	if qry.stmt != "DELETE FROM t WHERE id = 1" {
+		t.Errorf("expected the statement to be left unchanged, got %q", qry.stmt)
+	}
+}
+
+func TestWithWriteTimestamp(t *testing.T) {
+	ts := time.Date(2020, 1, 1, 0, 0, 0, 1000, time.UTC)
+	qry := (&Query{}).WithWriteTimestamp(ts)
+	if !qry.defaultTimestamp || qry.defaultTimestampValue != ts.UnixNano()/1000 {
+		t.Errorf("expected the default timestamp %d, got %v %d", ts.UnixNano()/1000, qry.defaultTimestamp, qry.defaultTimestampValue)
+	}
+	batch := (&Batch{}).WithWriteTimestamp(ts)
+	if !batch.defaultTimestamp || batch.defaultTimestampValue != ts.UnixNano()/1000 {
+		t.Errorf("expected the default timestamp %d, got %v %d", ts.UnixNano()/1000, batch.defaultTimestamp, batch.defaultTimestampValue)
+	}
+}
@@ -386,3 +386,50 @@ func TestTuple_NullableNestedCollection(t *testing.T) {
 		})
 	}
 }
+
+func TestTuple_SingleValue(t *testing.T) {
+	session := createSession(t)
+	defer session.Close()
+	if session.cfg.ProtoVersion < protoVersion3 {
+		t.Skip("tuple types are only available of proto>=3")
+	}
+
+	err := createTable(session, `CREATE TABLE gocql_test.tuple_single_value(
+		id int,
+		coord frozen<tuple<int, text>>,
+
+		primary key(id))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type coord struct {
+		X int
+		Y string
+	}
+
+	if err := session.Query("INSERT INTO tuple_single_value(id, coord) VALUES(?, ?)", 1, Tuple{100, "a"}).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Query("INSERT INTO tuple_single_value(id, coord) VALUES(?, ?)", 2, coord{200, "b"}).Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		id int
+		x  int
+		y  string
+	)
+	if err := session.Query("SELECT id, coord FROM tuple_single_value WHERE id=?", 1).Scan(&id, Tuple{&x, &y}); err != nil {
+		t.Fatal(err)
+	} else if id != 1 || x != 100 || y != "a" {
+		t.Errorf("expected (1, (100, a)) got (%d, (%d, %s))", id, x, y)
+	}
+
+	var c coord
+	if err := session.Query("SELECT id, coord FROM tuple_single_value WHERE id=?", 2).Scan(&id, &c); err != nil {
+		t.Fatal(err)
+	} else if id != 2 || c != (coord{200, "b"}) {
+		t.Errorf("expected (2, {200 b}) got (%d, %+v)", id, c)
+	}
+}